@@ -16,10 +16,15 @@ func registerAuthorityHints(r fiber.Router, store model.AuthorityHintsStore) {
 
 	g.Get(
 		"/", func(c *fiber.Ctx) error {
-			items, err := store.List()
+			opts, err := parseListOptions(c)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(err.Error()))
+			}
+			items, page, err := store.ListPage(opts)
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
 			}
+			setPageHeaders(c, page)
 			return c.JSON(items)
 		},
 	)
@@ -29,6 +29,11 @@ func (m *mockAuthorityHintsStore) List() ([]smodel.AuthorityHint, error) {
 	return m.listFn()
 }
 
+func (m *mockAuthorityHintsStore) ListPage(smodel.ListOptions) ([]smodel.AuthorityHint, smodel.PageInfo, error) {
+	items, err := m.listFn()
+	return items, smodel.PageInfo{Total: int64(len(items))}, err
+}
+
 func (m *mockAuthorityHintsStore) Create(item smodel.AddAuthorityHint) (*smodel.AuthorityHint, error) {
 	return m.createFn(item)
 }
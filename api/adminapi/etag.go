@@ -0,0 +1,157 @@
+package adminapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// etagMiddleware implements optimistic concurrency control for the admin API.
+//
+// Successful GET responses get an ETag derived from a hash of the response
+// body; if it matches the If-None-Match header, 304 Not Modified is returned.
+// PUT, PATCH, and DELETE requests with an If-Match header are only processed if
+// it matches the ETag of the current representation, i.e. of the response to a
+// GET request on the same path; otherwise 412 Precondition Failed is returned.
+// If there is no GET route for the path, the If-Match header is ignored.
+func etagMiddleware() fiber.Handler {
+	var (
+		locks           pathLocks
+		representations representationResolver
+	)
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet:
+			if err := c.Next(); err != nil {
+				return err
+			}
+			// Streamed bodies, e.g. of the stats tail, would have to be read
+			// completely to compute an ETag
+			if c.Response().StatusCode() != fiber.StatusOK || c.Response().IsBodyStream() {
+				return nil
+			}
+			etag := computeETag(c.Response().Body())
+			c.Set(fiber.HeaderETag, etag)
+			if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+				c.Response().ResetBody()
+				return c.SendStatus(fiber.StatusNotModified)
+			}
+			return nil
+		case fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+			ifMatch := c.Get(fiber.HeaderIfMatch)
+			if ifMatch == "" {
+				return c.Next()
+			}
+			unlock := locks.lock(c.Path())
+			defer unlock()
+			current, status := representations.currentETag(c)
+			if status == fiber.StatusMethodNotAllowed {
+				return c.Next()
+			}
+			if status != fiber.StatusOK || !etagMatches(ifMatch, current) {
+				return writePreconditionFailed(c, "resource was modified; If-Match does not match the current ETag")
+			}
+			return c.Next()
+		default:
+			return c.Next()
+		}
+	}
+}
+
+// pathLocks serializes conditional writes to the same path, so that the
+// resource cannot be modified by another conditional write between the
+// If-Match check and the write. Paths are mapped to a fixed number of locks,
+// so writes to different paths rarely wait for each other.
+type pathLocks [64]sync.Mutex
+
+func (l *pathLocks) lock(path string) (unlock func()) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path))
+	m := &l[h.Sum32()%uint32(len(l))]
+	m.Lock()
+	return m.Unlock
+}
+
+// computeETag returns a strong ETag for the passed representation.
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches checks if the passed If-Match / If-None-Match header value
+// matches the ETag; "*" matches any existing representation.
+func etagMatches(header, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// representationResolver renders the current representation of a resource.
+// It dispatches GET requests to an internal app that only contains the
+// handlers of the GET routes of the admin API app, so that middleware such as
+// authentication or request statistics does not run again.
+type representationResolver struct {
+	once sync.Once
+	app  *fiber.App
+}
+
+// init creates the internal app from the routes of the passed app; all
+// routes must be registered at this point. Requests without a matching GET
+// route are answered with 405 Method Not Allowed.
+func (r *representationResolver) init(app *fiber.App) {
+	r.once.Do(
+		func() {
+			r.app = fiber.New(app.Config())
+			for _, route := range app.GetRoutes(true) {
+				if route.Method == fiber.MethodGet {
+					r.app.Get(route.Path, route.Handlers...)
+				}
+			}
+			r.app.Use(
+				func(c *fiber.Ctx) error {
+					return c.SendStatus(fiber.StatusMethodNotAllowed)
+				},
+			)
+		},
+	)
+}
+
+// currentETag obtains the ETag of the current representation of the
+// requested resource by dispatching a GET request with the same path and
+// headers. status is the status code of the GET response; it is
+// fiber.StatusMethodNotAllowed if there is no GET route for the path.
+func (r *representationResolver) currentETag(c *fiber.Ctx) (etag string, status int) {
+	r.init(c.App())
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	c.Request().Header.CopyTo(&req.Header)
+	req.Header.SetMethod(fiber.MethodGet)
+	req.SetRequestURIBytes(c.Request().URI().Path())
+	for _, h := range []string{
+		fiber.HeaderIfMatch, fiber.HeaderIfNoneMatch, fiber.HeaderAcceptEncoding,
+		fiber.HeaderContentType, fiber.HeaderContentLength,
+	} {
+		req.Header.Del(h)
+	}
+
+	var ctx fasthttp.RequestCtx
+	ctx.Init(req, c.Context().RemoteAddr(), nil)
+	r.app.Handler()(&ctx)
+	status = ctx.Response.StatusCode()
+	if status != fiber.StatusOK {
+		return "", status
+	}
+	return computeETag(ctx.Response.Body()), status
+}
@@ -0,0 +1,243 @@
+package adminapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	istats "github.com/go-oidfed/lighthouse/internal/stats"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+func setupETagApp(t *testing.T) (*fiber.App, string) {
+	t.Helper()
+	store := newSubordinateTestStorage(t)
+	backends := store.Backends()
+	if err := backends.Subordinates.Add(
+		model.ExtendedSubordinateInfo{
+			BasicSubordinateInfo: model.BasicSubordinateInfo{
+				EntityID: "https://etag.example.org",
+				Status:   model.StatusPending,
+			},
+		},
+	); err != nil {
+		t.Fatalf("Failed to add subordinate: %v", err)
+	}
+	info, err := backends.Subordinates.Get("https://etag.example.org")
+	if err != nil {
+		t.Fatalf("Failed to get subordinate: %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(etagMiddleware())
+	registerSubordinatesBase(app, backends)
+	return app, fmt.Sprintf("/subordinates/%d", info.ID)
+}
+
+func TestETagMiddleware(t *testing.T) {
+	t.Parallel()
+
+	update := func(app *fiber.App, path, ifMatch, description string) (*http.Response, []byte) {
+		req := httptest.NewRequest(
+			http.MethodPut, path, strings.NewReader(fmt.Sprintf(`{"description":%q}`, description)),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		return doRequest(t, app, req)
+	}
+	getETag := func(t *testing.T, app *fiber.App, path string) string {
+		t.Helper()
+		resp, body := doRequest(t, app, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		requireStatus(t, resp, body, http.StatusOK)
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Fatal("Expected ETag header")
+		}
+		return etag
+	}
+
+	t.Run(
+		"StableETag", func(t *testing.T) {
+			t.Parallel()
+			app, path := setupETagApp(t)
+			if getETag(t, app, path) != getETag(t, app, path) {
+				t.Error("Expected ETag to be stable for unchanged resource")
+			}
+		},
+	)
+
+	t.Run(
+		"IfNoneMatch", func(t *testing.T) {
+			t.Parallel()
+			app, path := setupETagApp(t)
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			req.Header.Set("If-None-Match", getETag(t, app, path))
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusNotModified)
+		},
+	)
+
+	t.Run(
+		"IfMatch", func(t *testing.T) {
+			t.Parallel()
+			app, path := setupETagApp(t)
+			etag := getETag(t, app, path)
+
+			resp, body := update(app, path, etag, "first")
+			requireStatus(t, resp, body, http.StatusOK)
+			if getETag(t, app, path) == etag {
+				t.Error("Expected ETag to change after update")
+			}
+
+			// A second update based on the outdated ETag must be rejected.
+			resp, body = update(app, path, etag, "second")
+			assertErrorResponse(t, resp, body, http.StatusPreconditionFailed, "invalid_request")
+
+			resp, body = update(app, path, "*", "third")
+			requireStatus(t, resp, body, http.StatusOK)
+		},
+	)
+
+	t.Run(
+		"WithoutIfMatch", func(t *testing.T) {
+			t.Parallel()
+			app, path := setupETagApp(t)
+			resp, body := update(app, path, "", "unconditional")
+			requireStatus(t, resp, body, http.StatusOK)
+		},
+	)
+
+	t.Run(
+		"IfMatchWithoutGETRoute", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupETagApp(t)
+			app.Put(
+				"/subordinates/:subordinateID/touch", func(c *fiber.Ctx) error {
+					return c.SendStatus(fiber.StatusNoContent)
+				},
+			)
+			req := httptest.NewRequest(http.MethodPut, "/subordinates/1/touch", http.NoBody)
+			req.Header.Set("If-Match", `"outdated"`)
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusNoContent)
+		},
+	)
+
+	t.Run(
+		"MiddlewareNotRerun", func(t *testing.T) {
+			t.Parallel()
+			store := newSubordinateTestStorage(t)
+			backends := store.Backends()
+			if err := backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://etag.example.org",
+						Status:   model.StatusPending,
+					},
+				},
+			); err != nil {
+				t.Fatalf("Failed to add subordinate: %v", err)
+			}
+			info, err := backends.Subordinates.Get("https://etag.example.org")
+			if err != nil {
+				t.Fatalf("Failed to get subordinate: %v", err)
+			}
+			path := fmt.Sprintf("/subordinates/%d", info.ID)
+
+			var calls atomic.Int32
+			app := fiber.New()
+			app.Use(
+				func(c *fiber.Ctx) error {
+					calls.Add(1)
+					return c.Next()
+				},
+			)
+			app.Use(etagMiddleware())
+			registerSubordinatesBase(app, backends)
+
+			etag := getETag(t, app, path)
+			calls.Store(0)
+			resp, body := update(app, path, etag, "conditional")
+			requireStatus(t, resp, body, http.StatusOK)
+			if n := calls.Load(); n != 1 {
+				t.Errorf("Expected the middleware to run once for a conditional write, ran %d times", n)
+			}
+		},
+	)
+
+	t.Run(
+		"IfMatchOnMissingResource", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupETagApp(t)
+			req := httptest.NewRequest(http.MethodDelete, "/subordinates/999", http.NoBody)
+			req.Header.Set("If-Match", "*")
+			resp, body := doRequest(t, app, req)
+			assertErrorResponse(t, resp, body, http.StatusPreconditionFailed, "invalid_request")
+		},
+	)
+}
+
+// openStatsTail is a StatsTail whose stream stays open until it is
+// unsubscribed.
+type openStatsTail struct {
+	entry *istats.RequestLog
+}
+
+func (o openStatsTail) Subscribe(_ istats.TailFilter, size int) (<-chan *istats.RequestLog, func()) {
+	ch := make(chan *istats.RequestLog, size)
+	ch <- o.entry
+	return ch, func() {}
+}
+
+// TestETagMiddlewareStreamedBody checks with the full admin API router that
+// the live tail is streamed and not buffered by the ETag middleware.
+func TestETagMiddlewareStreamedBody(t *testing.T) {
+	t.Parallel()
+	backends := newSubordinateTestStorage(t).Backends()
+	backends.Stats = &mockStatsStorageBackend{}
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	if err := Register(
+		app, "http://localhost", backends, nil, KeyManagement{},
+		&Options{StatsTail: openStatsTail{entry: &istats.RequestLog{Endpoint: "fetch", StatusCode: 200}}},
+	); err != nil {
+		t.Fatalf("Failed to register admin API: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() { _ = app.ShutdownWithTimeout(time.Second) })
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + ln.Addr().String() + "/stats/tail")
+	if err != nil {
+		t.Fatalf("Failed to request tail: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if etag := resp.Header.Get(fiber.HeaderETag); etag != "" {
+		t.Errorf("Expected no ETag for a streamed body, got %q", etag)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read streamed entry: %v", err)
+	}
+	var entry istats.RequestLog
+	if err = json.Unmarshal([]byte(line), &entry); err != nil || entry.Endpoint != "fetch" {
+		t.Errorf("Unexpected streamed entry %q: %v", line, err)
+	}
+}
@@ -0,0 +1,257 @@
+package adminapi
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/go-oidfed/lib/oidfedconst"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+
+	"github.com/go-oidfed/lighthouse/internal/utils"
+)
+
+// defaultJWKSSnapshotLifetime is the lifetime of a JWKS snapshot if none is
+// requested.
+const defaultJWKSSnapshotLifetime = 30 * 24 * time.Hour
+
+// Output formats of the JWKS snapshot endpoint
+const (
+	jwksSnapshotFormatJWT        = "jwt"
+	jwksSnapshotFormatGoOIDFed   = "go-oidfed"
+	jwksSnapshotFormatFedservice = "fedservice"
+	jwksSnapshotFormatNimbus     = "nimbus"
+)
+
+// jwksSnapshot is the payload of a signed JWKS snapshot.
+type jwksSnapshot struct {
+	Issuer    string            `json:"iss"`
+	Subject   string            `json:"sub"`
+	IssuedAt  unixtime.Unixtime `json:"iat"`
+	ExpiresAt unixtime.Unixtime `json:"exp"`
+	Keys      []jwk.Key         `json:"keys"`
+}
+
+// jwksSnapshotHandlers groups handlers for exporting JWKS snapshots that can
+// be distributed out of band, e.g. to configure this entity as trust anchor.
+type jwksSnapshotHandlers struct {
+	keyManagement KeyManagement
+	entityID      string
+}
+
+// snapshotKeys returns the current and upcoming keys of the KMS-managed and
+// API-managed public key storages.
+func (h *jwksSnapshotHandlers) snapshotKeys() ([]jwk.Key, error) {
+	var keys []jwk.Key
+	for _, pkStorage := range []public.PublicKeyStorage{
+		h.keyManagement.KMSManagedPKs, h.keyManagement.APIManagedPKs,
+	} {
+		if pkStorage == nil {
+			continue
+		}
+		list, err := pkStorage.GetValid()
+		if err != nil {
+			return nil, err
+		}
+		for _, pk := range list {
+			k, err := pk.JWK()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (h *jwksSnapshotHandlers) getSnapshot(c *fiber.Ctx) error {
+	lifetime := defaultJWKSSnapshotLifetime
+	if v := c.Query("lifetime"); v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds <= 0 {
+			return writeBadRequest(c, "lifetime must be a positive number of seconds")
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	}
+	format := c.Query("format", jwksSnapshotFormatJWT)
+
+	keys, err := h.snapshotKeys()
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	if len(keys) == 0 {
+		return writeServerError(c, errors.New("no valid keys"))
+	}
+	now := time.Now()
+	snapshot := jwksSnapshot{
+		Issuer:    h.entityID,
+		Subject:   h.entityID,
+		IssuedAt:  unixtime.Unixtime{Time: now},
+		ExpiresAt: unixtime.Unixtime{Time: now.Add(lifetime)},
+		Keys:      keys,
+	}
+
+	switch format {
+	case jwksSnapshotFormatJWT:
+		jwt, err := h.sign(snapshot)
+		if err != nil {
+			return writeServerError(c, err)
+		}
+		c.Set(fiber.HeaderContentType, oidfedconst.ContentTypeJWKS)
+		return c.Send(jwt)
+	case jwksSnapshotFormatGoOIDFed, jwksSnapshotFormatFedservice, jwksSnapshotFormatNimbus:
+		snippet, contentType, err := trustAnchorSnippet(format, snapshot)
+		if err != nil {
+			return writeServerError(c, err)
+		}
+		c.Set(fiber.HeaderContentType, contentType)
+		return c.Send(snippet)
+	default:
+		return writeBadRequest(
+			c, "unsupported format; must be one of "+strings.Join(
+				[]string{
+					jwksSnapshotFormatJWT, jwksSnapshotFormatGoOIDFed, jwksSnapshotFormatFedservice,
+					jwksSnapshotFormatNimbus,
+				}, ", ",
+			),
+		)
+	}
+}
+
+// kidSigner is a crypto.Signer with a known key ID, which jwx.SignPayload
+// puts in the kid header.
+type kidSigner struct {
+	crypto.Signer
+	kid string
+}
+
+// KID returns the key ID of the signer.
+func (s kidSigner) KID() string {
+	return s.kid
+}
+
+// sign signs the snapshot with the default signing key of the federation
+// keys. The kid header is set to the key ID under which the signing key is
+// published in the snapshot.
+func (h *jwksSnapshotHandlers) sign(snapshot jwksSnapshot) ([]byte, error) {
+	signer, alg := h.keyManagement.BasicKeys.GetDefault()
+	if signer == nil {
+		return nil, errors.New("no signing key available")
+	}
+	kid, err := signingKeyID(signer, snapshot.Keys)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return jwx.SignWithType(payload, nil, oidfedconst.JWTTypeJWKS, alg, kidSigner{Signer: signer, kid: kid})
+}
+
+// signingKeyID returns the key ID of the key in keys that matches the public
+// key of signer.
+func signingKeyID(signer crypto.Signer, keys []jwk.Key) (string, error) {
+	pk, err := jwk.PublicKeyOf(signer.Public())
+	if err != nil {
+		return "", err
+	}
+	thumbprint, err := pk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range keys {
+		tp, err := k.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(tp, thumbprint) {
+			if kid, ok := k.KeyID(); ok && kid != "" {
+				return kid, nil
+			}
+		}
+	}
+	return "", errors.New("signing key is not part of the snapshot")
+}
+
+// snapshotJWKS returns the keys of the snapshot as JWKS.
+func snapshotJWKS(snapshot jwksSnapshot) jwx.JWKS {
+	set := jwx.NewJWKS()
+	for _, k := range snapshot.Keys {
+		_ = set.AddKey(k)
+	}
+	return set
+}
+
+// trustAnchorSnippet returns a configuration snippet that configures the
+// entity of the snapshot as trust anchor in a relying party library, and its
+// content type.
+func trustAnchorSnippet(format string, snapshot jwksSnapshot) ([]byte, string, error) {
+	jwks := snapshotJWKS(snapshot)
+	header := fmt.Sprintf(
+		"Trust anchor %s; keys exported at %s, re-export before %s",
+		snapshot.Issuer, snapshot.IssuedAt.Format(time.RFC3339), snapshot.ExpiresAt.Format(time.RFC3339),
+	)
+	switch format {
+	case jwksSnapshotFormatGoOIDFed:
+		data, err := utils.JSONToBlockYAML(
+			map[string]oidfed.TrustAnchors{
+				"trust_anchors": {
+					{
+						EntityID: snapshot.Issuer,
+						JWKS:     jwks,
+					},
+				},
+			},
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		return append([]byte("# "+header+"\n"), data...), "application/yaml", nil
+	case jwksSnapshotFormatFedservice:
+		// JSON has no comments, so no header is added
+		data, err := json.MarshalIndent(
+			map[string]map[string]jwx.JWKS{
+				"trust_anchors": {snapshot.Issuer: jwks},
+			}, "", "  ",
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		return append(data, '\n'), fiber.MIMEApplicationJSON, nil
+	case jwksSnapshotFormatNimbus:
+		data, err := json.Marshal(jwks)
+		if err != nil {
+			return nil, "", err
+		}
+		snippet := fmt.Sprintf(
+			`// %s
+Map<EntityID, JWKSet> trustAnchors = Map.of(
+    new EntityID(%s),
+    JWKSet.parse(%s)
+);
+`, header, strconv.Quote(snapshot.Issuer), strconv.Quote(string(data)),
+		)
+		return []byte(snippet), fiber.MIMETextPlain, nil
+	}
+	return nil, "", fmt.Errorf("unsupported format '%s'", format)
+}
+
+// registerJWKSSnapshot wires the route for exporting JWKS snapshots.
+func registerJWKSSnapshot(r fiber.Router, keyManagement KeyManagement, entityID string) {
+	h := &jwksSnapshotHandlers{
+		keyManagement: keyManagement,
+		entityID:      entityID,
+	}
+	r.Get("/entity-configuration/jwks/snapshot", h.getSnapshot)
+}
@@ -0,0 +1,262 @@
+package adminapi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/go-oidfed/lib/oidfedconst"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"gopkg.in/yaml.v3"
+)
+
+const snapshotEntityID = "https://ta.example.org"
+
+// mockSigningKMS is a basic KMS mock that signs with a fixed ES256 key.
+type mockSigningKMS struct {
+	mockBasicKMS
+	sk crypto.Signer
+}
+
+func (m *mockSigningKMS) GetDefault() (crypto.Signer, jwa.SignatureAlgorithm) {
+	return m.sk, jwa.ES256()
+}
+
+// addSnapshotTestKey generates an ES256 key, adds it to the storage with the
+// passed times and returns the private key.
+func addSnapshotTestKey(
+	t *testing.T, pks public.PublicKeyStorage, kid string, nbf, exp *time.Time, revoked bool,
+) *ecdsa.PrivateKey {
+	t.Helper()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pk, _, err := jwx.SignerToPublicJWK(sk, jwa.ES256())
+	if err != nil {
+		t.Fatalf("Failed to create JWK: %v", err)
+	}
+	_ = pk.Set("kid", kid)
+	entry := public.PublicKeyEntry{
+		KID: kid,
+		Key: public.JWKKey{Key: pk},
+	}
+	if nbf != nil {
+		entry.NotBefore = &unixtime.Unixtime{Time: *nbf}
+	}
+	if exp != nil {
+		entry.ExpiresAt = &unixtime.Unixtime{Time: *exp}
+	}
+	if revoked {
+		entry.RevokedAt = &unixtime.Unixtime{Time: time.Now().Add(-time.Minute)}
+		entry.Reason = "compromised"
+	}
+	if err = pks.Add(entry); err != nil {
+		t.Fatalf("Failed to add key %s: %v", kid, err)
+	}
+	return sk
+}
+
+func setupSnapshotApp(t *testing.T) (*fiber.App, *ecdsa.PrivateKey) {
+	t.Helper()
+	store := newTestStorage(t)
+	kmsPKs := store.DBPublicKeyStorage("federation")
+	apiPKs := store.DBPublicKeyStorage("api")
+	for _, pks := range []public.PublicKeyStorage{kmsPKs, apiPKs} {
+		if err := pks.Load(); err != nil {
+			t.Fatalf("Failed to load public key storage: %v", err)
+		}
+	}
+	now := time.Now()
+	past, future, expired := now.Add(-time.Hour), now.Add(24*time.Hour), now.Add(-time.Minute)
+	sk := addSnapshotTestKey(t, kmsPKs, "current", &past, nil, false)
+	addSnapshotTestKey(t, kmsPKs, "next", &future, nil, false)
+	addSnapshotTestKey(t, kmsPKs, "expired", &past, &expired, false)
+	addSnapshotTestKey(t, kmsPKs, "revoked", &past, nil, true)
+	addSnapshotTestKey(t, apiPKs, "api", nil, nil, false)
+
+	app := fiber.New()
+	registerJWKSSnapshot(
+		app, KeyManagement{
+			KMS:           "mock-kms",
+			KMSManagedPKs: kmsPKs,
+			APIManagedPKs: apiPKs,
+			BasicKeys:     &mockSigningKMS{sk: sk},
+		}, snapshotEntityID,
+	)
+	return app, sk
+}
+
+func getSnapshot(t *testing.T, app *fiber.App, query string) (*http.Response, []byte) {
+	t.Helper()
+	return doRequest(t, app, httptest.NewRequest(http.MethodGet, "/entity-configuration/jwks/snapshot"+query, http.NoBody))
+}
+
+func snapshotKIDs(t *testing.T, jwks jwx.JWKS) map[string]bool {
+	t.Helper()
+	kids := make(map[string]bool)
+	for i := 0; i < jwks.Len(); i++ {
+		k, _ := jwks.Key(i)
+		kid, _ := k.KeyID()
+		kids[kid] = true
+	}
+	return kids
+}
+
+func assertSnapshotKIDs(t *testing.T, kids map[string]bool) {
+	t.Helper()
+	for _, kid := range []string{"current", "next", "api"} {
+		if !kids[kid] {
+			t.Errorf("Expected key %s in snapshot", kid)
+		}
+	}
+	for _, kid := range []string{"expired", "revoked"} {
+		if kids[kid] {
+			t.Errorf("Expected key %s not to be in snapshot", kid)
+		}
+	}
+}
+
+func TestJWKSSnapshot(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SignedJWT", func(t *testing.T) {
+		t.Parallel()
+		app, sk := setupSnapshotApp(t)
+		resp, body := getSnapshot(t, app, "?lifetime=3600")
+		requireStatus(t, resp, body, http.StatusOK)
+		if ct := resp.Header.Get(fiber.HeaderContentType); ct != oidfedconst.ContentTypeJWKS {
+			t.Errorf("Expected content type %s, got %s", oidfedconst.ContentTypeJWKS, ct)
+		}
+		msg, err := jws.Parse(body)
+		if err != nil {
+			t.Fatalf("Failed to parse JWT: %v", err)
+		}
+		if typ, _ := msg.Signatures()[0].ProtectedHeaders().Type(); typ != oidfedconst.JWTTypeJWKS {
+			t.Errorf("Expected typ %s, got %s", oidfedconst.JWTTypeJWKS, typ)
+		}
+		if kid, _ := msg.Signatures()[0].ProtectedHeaders().KeyID(); kid != "current" {
+			t.Errorf("Expected kid current, got %s", kid)
+		}
+		payload, err := jws.Verify(body, jws.WithKey(jwa.ES256(), sk.Public()))
+		if err != nil {
+			t.Fatalf("Failed to verify snapshot: %v", err)
+		}
+		var keys jwx.JWKS
+		if err = json.Unmarshal(payload, &keys); err != nil {
+			t.Fatalf("Failed to parse snapshot keys: %v", err)
+		}
+		var claims struct {
+			Issuer    string            `json:"iss"`
+			Subject   string            `json:"sub"`
+			IssuedAt  unixtime.Unixtime `json:"iat"`
+			ExpiresAt unixtime.Unixtime `json:"exp"`
+		}
+		if err = json.Unmarshal(payload, &claims); err != nil {
+			t.Fatalf("Failed to parse snapshot claims: %v", err)
+		}
+		if claims.Issuer != snapshotEntityID || claims.Subject != snapshotEntityID {
+			t.Errorf("Expected iss and sub %s, got %s and %s", snapshotEntityID, claims.Issuer, claims.Subject)
+		}
+		if d := claims.ExpiresAt.Sub(claims.IssuedAt.Time); d != time.Hour {
+			t.Errorf("Expected lifetime of one hour, got %v", d)
+		}
+		assertSnapshotKIDs(t, snapshotKIDs(t, keys))
+	})
+
+	t.Run("DefaultLifetime", func(t *testing.T) {
+		t.Parallel()
+		app, sk := setupSnapshotApp(t)
+		resp, body := getSnapshot(t, app, "")
+		requireStatus(t, resp, body, http.StatusOK)
+		payload, err := jws.Verify(body, jws.WithKey(jwa.ES256(), sk.Public()))
+		if err != nil {
+			t.Fatalf("Failed to verify snapshot: %v", err)
+		}
+		var claims struct {
+			IssuedAt  unixtime.Unixtime `json:"iat"`
+			ExpiresAt unixtime.Unixtime `json:"exp"`
+		}
+		if err = json.Unmarshal(payload, &claims); err != nil {
+			t.Fatalf("Failed to parse snapshot claims: %v", err)
+		}
+		if d := claims.ExpiresAt.Sub(claims.IssuedAt.Time); d != defaultJWKSSnapshotLifetime {
+			t.Errorf("Expected default lifetime, got %v", d)
+		}
+	})
+
+	t.Run("GoOIDFedSnippet", func(t *testing.T) {
+		t.Parallel()
+		app, _ := setupSnapshotApp(t)
+		resp, body := getSnapshot(t, app, "?format=go-oidfed")
+		requireStatus(t, resp, body, http.StatusOK)
+		var conf struct {
+			TrustAnchors oidfed.TrustAnchors `yaml:"trust_anchors"`
+		}
+		if err := yaml.Unmarshal(body, &conf); err != nil {
+			t.Fatalf("Failed to parse snippet: %v\n%s", err, body)
+		}
+		if len(conf.TrustAnchors) != 1 || conf.TrustAnchors[0].EntityID != snapshotEntityID {
+			t.Fatalf("Expected one trust anchor %s, got %+v", snapshotEntityID, conf.TrustAnchors)
+		}
+		assertSnapshotKIDs(t, snapshotKIDs(t, conf.TrustAnchors[0].JWKS))
+	})
+
+	t.Run("FedserviceSnippet", func(t *testing.T) {
+		t.Parallel()
+		app, _ := setupSnapshotApp(t)
+		resp, body := getSnapshot(t, app, "?format=fedservice")
+		requireStatus(t, resp, body, http.StatusOK)
+		var conf struct {
+			TrustAnchors map[string]jwx.JWKS `json:"trust_anchors"`
+		}
+		if err := json.Unmarshal(body, &conf); err != nil {
+			t.Fatalf("Failed to parse snippet: %v\n%s", err, body)
+		}
+		jwks, ok := conf.TrustAnchors[snapshotEntityID]
+		if !ok {
+			t.Fatalf("Expected trust anchor %s, got %s", snapshotEntityID, body)
+		}
+		assertSnapshotKIDs(t, snapshotKIDs(t, jwks))
+	})
+
+	t.Run("NimbusSnippet", func(t *testing.T) {
+		t.Parallel()
+		app, _ := setupSnapshotApp(t)
+		resp, body := getSnapshot(t, app, "?format=nimbus")
+		requireStatus(t, resp, body, http.StatusOK)
+		for _, expected := range []string{
+			`new EntityID("` + snapshotEntityID + `")`, `JWKSet.parse("{\"keys\":[`, `\"kid\":\"next\"`,
+		} {
+			if !strings.Contains(string(body), expected) {
+				t.Errorf("Expected snippet to contain %s, got:\n%s", expected, body)
+			}
+		}
+	})
+
+	for name, query := range map[string]string{
+		"InvalidFormat":    "?format=xml",
+		"InvalidLifetime":  "?lifetime=1d",
+		"NegativeLifetime": "?lifetime=-5",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSnapshotApp(t)
+			resp, body := getSnapshot(t, app, query)
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package adminapi
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	smodel "github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// keySetInfo describes a key set with separate keys.
+type keySetInfo struct {
+	Name string   `json:"name"`
+	KMS  *kmsInfo `json:"kms"`
+}
+
+// keySetsHandlers groups handlers for the separate key sets.
+type keySetsHandlers struct {
+	names    []string
+	handlers map[string]*kmsHandlers
+}
+
+func (h *keySetsHandlers) list(c *fiber.Ctx) error {
+	sets := make([]keySetInfo, 0, len(h.names))
+	for _, name := range h.names {
+		info, err := h.handlers[name].buildKMSInfo()
+		if err != nil {
+			return writeServerError(c, err)
+		}
+		sets = append(
+			sets, keySetInfo{
+				Name: name,
+				KMS:  info,
+			},
+		)
+	}
+	return c.JSON(sets)
+}
+
+// registerKeySets wires the routes for key sets that use separate keys (e.g.
+// for trust marks or resolve responses). Each key set gets its own JWKS and
+// KMS routes under /key-sets/<name>; its signing settings are stored in a key
+// set specific scope of the key value store.
+func registerKeySets(
+	r fiber.Router, keySets map[string]KeyManagement, kvStorage smodel.KeyValueStore, events smodel.KeyEventStore,
+) {
+	h := &keySetsHandlers{handlers: make(map[string]*kmsHandlers, len(keySets))}
+	for name := range keySets {
+		h.names = append(h.names, name)
+	}
+	slices.Sort(h.names)
+	r.Get("/key-sets", h.list)
+
+	for _, name := range h.names {
+		keyManagement := keySets[name]
+		kmsH := &kmsHandlers{
+			keyManagement: keyManagement,
+			kvStorage:     storage.NewKeySetKeyValueStore(kvStorage, name),
+			keySet:        name,
+			events:        events,
+		}
+		h.handlers[name] = kmsH
+		g := r.Group("/key-sets/" + name)
+		jwksH := &jwksHandlers{keyManagement: keyManagement}
+		g.Get("/jwks", jwksH.getJWKS)
+		registerKMS(g, kmsH)
+	}
+}
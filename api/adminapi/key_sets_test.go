@@ -0,0 +1,84 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+func TestKeySets(t *testing.T) {
+	t.Parallel()
+	store := newTestStorage(t)
+	kv := store.KeyValue()
+	m := newMockMultiAlgKMS()
+	pks := store.DBPublicKeyStorage("trust_marks")
+	if err := pks.Load(); err != nil {
+		t.Fatalf("Failed to load public key storage: %v", err)
+	}
+	app := fiber.New()
+	registerKeySets(
+		app, map[string]KeyManagement{
+			"trust_marks": {
+				KMS:           "mock-kms",
+				KMSManagedPKs: pks,
+				BasicKeys:     m,
+				Keys:          m,
+			},
+		}, kv, store.KeyEventsStorage(),
+	)
+	if err := storage.SetRSAKeyLen(kv, 3072); err != nil {
+		t.Fatalf("SetRSAKeyLen failed: %v", err)
+	}
+
+	t.Run("List", func(t *testing.T) {
+		resp, body := doRequest(t, app, httptest.NewRequest(http.MethodGet, "/key-sets", http.NoBody))
+		requireStatus(t, resp, body, http.StatusOK)
+		var sets []keySetInfo
+		if err := json.Unmarshal(body, &sets); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(sets) != 1 || sets[0].Name != "trust_marks" || sets[0].KMS == nil {
+			t.Fatalf("Unexpected key sets %+v", sets)
+		}
+		if sets[0].KMS.RSAKeyLen != 3072 {
+			t.Errorf("Expected the federation RSA key length to be inherited, got %d", sets[0].KMS.RSAKeyLen)
+		}
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		resp, body := doRequest(
+			t, app, httptest.NewRequest(http.MethodGet, "/key-sets/trust_marks/jwks", http.NoBody),
+		)
+		requireStatus(t, resp, body, http.StatusOK)
+	})
+
+	t.Run("SeparateSettings", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/key-sets/trust_marks/kms/rsa-key-len", strings.NewReader("4096"))
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+
+		var l int
+		if _, err := kv.GetAs(
+			storage.KeySetSigningScope("trust_marks"), model.KeyValueKeyRSAKeyLen, &l,
+		); err != nil || l != 4096 {
+			t.Errorf("Expected key set RSA key length 4096, got %d (%v)", l, err)
+		}
+		if fedLen, _ := storage.GetRSAKeyLen(kv); fedLen != 3072 {
+			t.Errorf("Expected federation RSA key length to be unchanged, got %d", fedLen)
+		}
+	})
+
+	t.Run("UnknownKeySet", func(t *testing.T) {
+		resp, _ := doRequest(t, app, httptest.NewRequest(http.MethodGet, "/key-sets/resolve/kms", http.NoBody))
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for unknown key set, got %d", resp.StatusCode)
+		}
+	})
+}
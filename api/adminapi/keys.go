@@ -1,6 +1,7 @@
 package adminapi
 
 import (
+	"cmp"
 	"errors"
 	"slices"
 	"strconv"
@@ -31,12 +32,23 @@ type KeyManagement struct {
 }
 
 type kmsInfo struct {
-	KMS         string                `json:"kms"`
-	Alg         string                `json:"alg"`
-	PendingAlg  string                `json:"pending_alg,omitempty"`
-	AlgChangeAt *unixtime.Unixtime    `json:"alg_change_at,omitempty"`
-	RSAKeyLen   int                   `json:"rsa_key_len"`
-	Rotation    kms.KeyRotationConfig `json:"rotation"`
+	KMS          string                `json:"kms"`
+	Alg          string                `json:"alg"`
+	Algs         []string              `json:"algs"`
+	PendingAlg   string                `json:"pending_alg,omitempty"`
+	AlgChangeAt  *unixtime.Unixtime    `json:"alg_change_at,omitempty"`
+	PendingAlgs  []string              `json:"pending_algs,omitempty"`
+	AlgsChangeAt *unixtime.Unixtime    `json:"algs_change_at,omitempty"`
+	RSAKeyLen    int                   `json:"rsa_key_len"`
+	Rotation     kms.KeyRotationConfig `json:"rotation"`
+}
+
+// kmsAlgsReq is the request body for changing the set of signing algorithms.
+type kmsAlgsReq struct {
+	Algs []string `json:"algs"`
+	// Default is the algorithm used for signing; if empty, the current default
+	// algorithm is kept.
+	Default string `json:"default,omitempty"`
 }
 
 func addKeysToSet(set jwx.JWKS, keys public.PublicKeyEntryList) error {
@@ -67,8 +79,10 @@ func (h *jwksHandlers) getJWKS(c *fiber.Ctx) error {
 	if err := addValidKeys(h.keyManagement.KMSManagedPKs); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
 	}
-	if err := addValidKeys(h.keyManagement.APIManagedPKs); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+	if h.keyManagement.APIManagedPKs != nil {
+		if err := addValidKeys(h.keyManagement.APIManagedPKs); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+		}
 	}
 	return c.JSON(set)
 }
@@ -81,11 +95,83 @@ type publicKeyHandlers struct {
 }
 
 func (h *publicKeyHandlers) list(c *fiber.Ctx) error {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
 	keys, err := h.apiManagedPKs.GetAll()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
 	}
-	return c.JSON(keys)
+	page, info, err := pagePublicKeys(keys, opts)
+	if err != nil {
+		return writeListError(c, err)
+	}
+	setPageHeaders(c, info)
+	return c.JSON(page)
+}
+
+// pagePublicKeys applies the list options to the public keys. The public key
+// storage has no numeric ids, so keys are sorted by kid by default and by
+// issued at (created_at) on request; search matches the kid.
+func pagePublicKeys(
+	keys public.PublicKeyEntryList, opts smodel.ListOptions,
+) (public.PublicKeyEntryList, smodel.PageInfo, error) {
+	var info smodel.PageInfo
+	if opts.Sort != smodel.SortByID && opts.Sort != smodel.SortByCreatedAt {
+		return nil, info, smodel.ValidationErrorFmt("sorting by '%s' is not supported", opts.Sort)
+	}
+	cursorOf := func(k public.PublicKeyEntry) smodel.ListCursor {
+		c := smodel.ListCursor{String: k.KID}
+		if opts.Sort == smodel.SortByCreatedAt && k.IssuedAt != nil {
+			c.Int = k.IssuedAt.Unix()
+		}
+		return c
+	}
+	compare := func(a, b smodel.ListCursor) int {
+		res := cmp.Compare(a.Int, b.Int)
+		if res == 0 {
+			res = strings.Compare(a.String, b.String)
+		}
+		if opts.Desc {
+			return -res
+		}
+		return res
+	}
+
+	search := strings.ToLower(opts.Search)
+	out := make(public.PublicKeyEntryList, 0, len(keys))
+	for _, k := range keys {
+		if search == "" || strings.Contains(strings.ToLower(k.KID), search) {
+			out = append(out, k)
+		}
+	}
+	info.Total = int64(len(out))
+	slices.SortFunc(
+		out, func(a, b public.PublicKeyEntry) int {
+			return compare(cursorOf(a), cursorOf(b))
+		},
+	)
+	if opts.Cursor != "" {
+		after, err := smodel.DecodeListCursor(opts.Cursor)
+		if err != nil {
+			return nil, info, err
+		}
+		i, _ := slices.BinarySearchFunc(
+			out, *after, func(k public.PublicKeyEntry, c smodel.ListCursor) int {
+				if compare(cursorOf(k), c) <= 0 {
+					return -1
+				}
+				return 1
+			},
+		)
+		out = out[i:]
+	}
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		out = out[:opts.Limit]
+		info.NextCursor = cursorOf(out[len(out)-1]).Encode()
+	}
+	return out, info, nil
 }
 
 func (h *publicKeyHandlers) create(c *fiber.Ctx) error {
@@ -225,10 +311,16 @@ func (h *publicKeyHandlers) delete(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
+// keySetFederation is the name of the key set used for entity statements.
+const keySetFederation = "federation"
+
 // kmsHandlers groups handlers for KMS endpoints.
 type kmsHandlers struct {
 	keyManagement KeyManagement
 	kvStorage     smodel.KeyValueStore
+	// keySet is the name of the key set the handlers manage
+	keySet string
+	events smodel.KeyEventStore
 }
 
 func (h *kmsHandlers) getInfo(c *fiber.Ctx) error {
@@ -239,38 +331,167 @@ func (h *kmsHandlers) getInfo(c *fiber.Ctx) error {
 	return c.JSON(info)
 }
 
-func (h *kmsHandlers) putAlg(c *fiber.Ctx) error {
-	if h.keyManagement.Keys == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("kms does not support changing signing alg dynamically"))
-	}
-	alg := strings.TrimSpace(string(c.Body()))
-	if alg == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("empty body"))
-	}
+// lookupSigningAlg returns the signature algorithm for the passed name or an
+// error message if the algorithm is invalid or unsupported.
+func lookupSigningAlg(alg string) (jwa.SignatureAlgorithm, string) {
 	jwaAlg, ok := jwa.LookupSignatureAlgorithm(alg)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("invalid algorithm"))
+		return jwaAlg, "invalid algorithm"
 	}
 	if !slices.Contains(jwx.SupportedAlgsStrings(), alg) {
-		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("unsupported algorithm"))
+		return jwaAlg, "unsupported algorithm"
 	}
+	return jwaAlg, ""
+}
 
+func containsAlg(algs []jwa.SignatureAlgorithm, alg jwa.SignatureAlgorithm) bool {
+	return slices.ContainsFunc(
+		algs, func(a jwa.SignatureAlgorithm) bool { return a.String() == alg.String() },
+	)
+}
+
+func algStrings(algs []jwa.SignatureAlgorithm) []string {
+	s := make([]string, len(algs))
+	for i, a := range algs {
+		s[i] = a.String()
+	}
+	return s
+}
+
+// algSwitchTime returns the time at which a change of the signing algorithms
+// becomes effective, i.e. when all entity configurations issued before the
+// change (and therefore the JWKS without new keys) have expired.
+func (h *kmsHandlers) algSwitchTime() (unixtime.Unixtime, time.Duration, error) {
 	ecLifetime, err := storage.GetEntityConfigurationLifetime(h.kvStorage)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+		return unixtime.Unixtime{}, 0, err
 	}
 	rot, err := storage.GetKeyRotation(h.kvStorage)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+		return unixtime.Unixtime{}, 0, err
 	}
 	switchTime := unixtime.Unixtime{Time: time.Now().Add(ecLifetime).Add(10 * time.Second)}
-	if err = h.keyManagement.Keys.ChangeAlgsAt([]jwa.SignatureAlgorithm{jwaAlg}, switchTime, rot.Overlap.Duration()); err != nil {
+	return switchTime, rot.Overlap.Duration(), nil
+}
+
+// changeAlgs changes the set of signing algorithms and the default signing
+// algorithm. Keys for new algorithms are published immediately but only used
+// from the switch time on; keys for removed algorithms expire after the switch
+// time plus the rotation overlap. If the new default algorithm already has an
+// active key, the default is changed immediately, otherwise at the switch
+// time.
+func (h *kmsHandlers) changeAlgs(algs []jwa.SignatureAlgorithm, defaultAlg jwa.SignatureAlgorithm) error {
+	switchTime, overlap, err := h.algSwitchTime()
+	if err != nil {
+		return err
+	}
+	currentAlgs := h.keyManagement.BasicKeys.GetAlgs()
+	if err = h.keyManagement.Keys.ChangeAlgsAt(algs, switchTime, overlap); err != nil {
+		return err
+	}
+	if err = storage.SetSigningAlgs(
+		h.kvStorage, storage.SigningAlgsWithNbf{
+			SigningAlgs: algStrings(algs),
+			Nbf:         &switchTime,
+		},
+	); err != nil {
+		return err
+	}
+	if h.keyManagement.BasicKeys.GetDefaultAlg().String() == defaultAlg.String() {
+		return nil
+	}
+	defaultNbf := switchTime
+	if containsAlg(currentAlgs, defaultAlg) {
+		if err = h.keyManagement.Keys.ChangeDefaultAlgorithm(defaultAlg); err != nil {
+			return err
+		}
+		defaultNbf = unixtime.Now()
+	} else if err = h.keyManagement.Keys.ChangeDefaultAlgorithmAt(defaultAlg, switchTime); err != nil {
+		return err
+	}
+	return storage.SetSigningAlg(
+		h.kvStorage, storage.SigningAlgWithNbf{
+			SigningAlg: defaultAlg.String(),
+			Nbf:        &defaultNbf,
+		},
+	)
+}
+
+func (h *kmsHandlers) putAlg(c *fiber.Ctx) error {
+	if h.keyManagement.Keys == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("kms does not support changing signing alg dynamically"))
+	}
+	alg := strings.TrimSpace(string(c.Body()))
+	if alg == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("empty body"))
+	}
+	jwaAlg, errMsg := lookupSigningAlg(alg)
+	if errMsg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(errMsg))
+	}
+
+	// If there already are keys for the algorithm, only the default changes;
+	// otherwise the new algorithm replaces the current default algorithm.
+	algs := h.keyManagement.BasicKeys.GetAlgs()
+	if !containsAlg(algs, jwaAlg) {
+		oldDefault := h.keyManagement.BasicKeys.GetDefaultAlg()
+		newAlgs := []jwa.SignatureAlgorithm{jwaAlg}
+		for _, a := range algs {
+			if a.String() != oldDefault.String() {
+				newAlgs = append(newAlgs, a)
+			}
+		}
+		algs = newAlgs
+	}
+	if err := h.changeAlgs(algs, jwaAlg); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
 	}
-	if err = h.keyManagement.Keys.ChangeDefaultAlgorithmAt(jwaAlg, switchTime); err != nil {
+	info, err := h.buildKMSInfo()
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
 	}
-	if err = storage.SetSigningAlg(h.kvStorage, storage.SigningAlgWithNbf{SigningAlg: alg, Nbf: &switchTime}); err != nil {
+	return c.JSON(info)
+}
+
+func (h *kmsHandlers) putAlgs(c *fiber.Ctx) error {
+	if h.keyManagement.Keys == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("kms does not support changing signing algs dynamically"))
+	}
+	var req kmsAlgsReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("invalid body"))
+	}
+	if len(req.Algs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest("algs must not be empty"))
+	}
+	var algs []jwa.SignatureAlgorithm
+	for _, alg := range req.Algs {
+		jwaAlg, errMsg := lookupSigningAlg(alg)
+		if errMsg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(errMsg + ": " + alg))
+		}
+		if !containsAlg(algs, jwaAlg) {
+			algs = append(algs, jwaAlg)
+		}
+	}
+	defaultAlg := h.keyManagement.BasicKeys.GetDefaultAlg()
+	if req.Default != "" {
+		var errMsg string
+		defaultAlg, errMsg = lookupSigningAlg(req.Default)
+		if errMsg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(errMsg + ": " + req.Default))
+		}
+	}
+	if !containsAlg(algs, defaultAlg) {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			oidfed.ErrorInvalidRequest("algs must contain the default algorithm " + defaultAlg.String()),
+		)
+	}
+	// Keep the default algorithm first, as it is when loaded from storage
+	algs = slices.DeleteFunc(algs, func(a jwa.SignatureAlgorithm) bool { return a.String() == defaultAlg.String() })
+	algs = append([]jwa.SignatureAlgorithm{defaultAlg}, algs...)
+
+	if err := h.changeAlgs(algs, defaultAlg); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
 	}
 	info, err := h.buildKMSInfo()
@@ -381,23 +602,25 @@ func (h *kmsHandlers) buildKMSInfo() (*kmsInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	var pendingAlg string
-	var pendingEffective *unixtime.Unixtime
+	info := &kmsInfo{
+		KMS:       h.keyManagement.KMS,
+		Alg:       alg.String(),
+		Algs:      algStrings(h.keyManagement.BasicKeys.GetAlgs()),
+		RSAKeyLen: rsaKeyLen,
+		Rotation:  rotation,
+	}
 	if h.keyManagement.Keys != nil {
-		_, pending := h.keyManagement.Keys.GetPendingChanges()
+		pendingAlgs, pending := h.keyManagement.Keys.GetPendingChanges()
 		if pending != nil {
-			pendingAlg = pending.Alg.String()
-			pendingEffective = &pending.EffectiveAt
+			info.PendingAlg = pending.Alg.String()
+			info.AlgChangeAt = &pending.EffectiveAt
+		}
+		if pendingAlgs != nil {
+			info.PendingAlgs = algStrings(pendingAlgs.Algs)
+			info.AlgsChangeAt = &pendingAlgs.EffectiveAt
 		}
 	}
-	return &kmsInfo{
-		KMS:         h.keyManagement.KMS,
-		Alg:         alg.String(),
-		PendingAlg:  pendingAlg,
-		AlgChangeAt: pendingEffective,
-		RSAKeyLen:   rsaKeyLen,
-		Rotation:    rotation,
-	}, nil
+	return info, nil
 }
 
 // registerKeys wires routes for managing public keys and KMS-related endpoints.
@@ -411,6 +634,8 @@ func registerKeys(r fiber.Router, keyManagement KeyManagement, kvStorage smodel.
 	kmsH := &kmsHandlers{
 		keyManagement: keyManagement,
 		kvStorage:     kvStorage,
+		keySet:        keySetFederation,
+		events:        storages.KeyEvents,
 	}
 
 	// Published JWKS
@@ -426,13 +651,20 @@ func registerKeys(r fiber.Router, keyManagement KeyManagement, kvStorage smodel.
 	withCacheWipe.Post("/:kid", pkH.rotate)
 	withCacheWipe.Delete("/:kid", pkH.delete)
 
-	// KMS routes
+	registerKMS(r, kmsH)
+}
+
+// registerKMS wires the KMS routes for the passed handlers.
+func registerKMS(r fiber.Router, kmsH *kmsHandlers) {
 	kmsWithCacheWipe := r.Use(entityConfigurationCacheInvalidationMiddleware)
 	r.Get("/kms", kmsH.getInfo)
 	kmsWithCacheWipe.Put("/kms/alg", kmsH.putAlg)
+	kmsWithCacheWipe.Put("/kms/algs", kmsH.putAlgs)
 	kmsWithCacheWipe.Put("/kms/rsa-key-len", kmsH.putRSAKeyLen)
 	r.Get("/kms/rotation", kmsH.getRotation)
 	kmsWithCacheWipe.Put("/kms/rotation", kmsH.putRotation)
 	kmsWithCacheWipe.Patch("/kms/rotation", kmsH.patchRotation)
 	kmsWithCacheWipe.Post("/kms/rotate", kmsH.triggerRotate)
+	kmsWithCacheWipe.Post("/kms/import", kmsH.importKey)
+	r.Get("/kms/events", kmsH.getEvents)
 }
@@ -0,0 +1,409 @@
+package adminapi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/pkg/errors"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	smodel "github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// KeyImporter is implemented by key management systems that can import
+// existing private keys.
+type KeyImporter interface {
+	// ImportKey stores the private key and adds the passed public key entry
+	// to the public key storage. commit is called once both are stored; if
+	// it fails, the private and public key are removed again and its error
+	// is returned. The key is used for signing once it is active.
+	ImportKey(sk crypto.Signer, entry public.PublicKeyEntry, commit func() error) error
+}
+
+// Placements of an imported key.
+const (
+	// importPlacementNow makes the imported key active immediately; it
+	// replaces the current key of its algorithm as in a key rotation.
+	importPlacementNow = "now"
+	// importPlacementNext publishes the imported key as the next key; it
+	// becomes active once entity configurations without it have expired.
+	importPlacementNext = "next"
+)
+
+// importKeyReq is the request body for importing a private key.
+type importKeyReq struct {
+	// Key is the private key, either as a PEM string or as a JWK object.
+	Key json.RawMessage `json:"key"`
+	// Alg is the signing algorithm; if empty, the alg of the JWK or the
+	// first configured algorithm matching the key type is used.
+	Alg       string             `json:"alg"`
+	Placement string             `json:"placement"`
+	Nbf       *unixtime.Unixtime `json:"nbf"`
+	Exp       *unixtime.Unixtime `json:"exp"`
+}
+
+// parsePrivateKey parses a private key given as a PEM string or as a JWK
+// object. It also returns the alg of the JWK, if set.
+func parsePrivateKey(data json.RawMessage) (crypto.Signer, string, error) {
+	var pemData string
+	if err := json.Unmarshal(data, &pemData); err == nil {
+		sk, err := parsePrivateKeyPEM([]byte(pemData))
+		return sk, "", err
+	}
+	key, err := jwk.ParseKey(data)
+	if err != nil {
+		return nil, "", errors.New("key must be a PEM string or a JWK")
+	}
+	if isPrivate, _ := jwk.IsPrivateKey(key); !isPrivate {
+		return nil, "", errors.New("key is not a private key")
+	}
+	var raw any
+	if err = jwk.Export(key, &raw); err != nil {
+		return nil, "", errors.Wrap(err, "could not export key")
+	}
+	sk, ok := raw.(crypto.Signer)
+	if !ok {
+		return nil, "", errors.New("unsupported key type")
+	}
+	var alg string
+	if a, ok := key.Algorithm(); ok {
+		alg = a.String()
+	}
+	return sk, alg, nil
+}
+
+// parsePrivateKeyPEM parses an unencrypted PKCS#8, PKCS#1 or SEC 1 private
+// key.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("unsupported PEM block type '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid private key")
+	}
+	sk, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+	return sk, nil
+}
+
+// keyMatchesAlg checks if the private key can be used with the signing
+// algorithm.
+func keyMatchesAlg(sk crypto.Signer, alg jwa.SignatureAlgorithm) bool {
+	switch k := sk.(type) {
+	case *rsa.PrivateKey:
+		switch alg {
+		case jwa.RS256(), jwa.RS384(), jwa.RS512(), jwa.PS256(), jwa.PS384(), jwa.PS512():
+			return true
+		}
+	case *ecdsa.PrivateKey:
+		switch alg {
+		case jwa.ES256():
+			return k.Curve == elliptic.P256()
+		case jwa.ES384():
+			return k.Curve == elliptic.P384()
+		case jwa.ES512():
+			return k.Curve == elliptic.P521()
+		}
+	case ed25519.PrivateKey:
+		return alg == jwa.EdDSA()
+	}
+	return false
+}
+
+// importAlg determines the signing algorithm for an imported key. The
+// algorithm must be one of the configured signing algorithms.
+func (h *kmsHandlers) importAlg(sk crypto.Signer, reqAlg, jwkAlg string) (jwa.SignatureAlgorithm, string) {
+	algs := h.keyManagement.BasicKeys.GetAlgs()
+	if reqAlg == "" {
+		reqAlg = jwkAlg
+	}
+	if reqAlg == "" {
+		for _, a := range algs {
+			if keyMatchesAlg(sk, a) {
+				return a, ""
+			}
+		}
+		return jwa.SignatureAlgorithm{}, "no configured signing algorithm matches the key; specify alg"
+	}
+	alg, errMsg := lookupSigningAlg(reqAlg)
+	if errMsg != "" {
+		return alg, errMsg + ": " + reqAlg
+	}
+	if jwkAlg != "" && jwkAlg != alg.String() {
+		return alg, "alg does not match the alg of the JWK"
+	}
+	if !containsAlg(algs, alg) {
+		return alg, "algorithm " + alg.String() + " is not a configured signing algorithm; add it first"
+	}
+	if !keyMatchesAlg(sk, alg) {
+		return alg, "key cannot be used with algorithm " + alg.String()
+	}
+	return alg, ""
+}
+
+// importNbf determines the nbf of an imported key from the placement.
+func (h *kmsHandlers) importNbf(req importKeyReq, now unixtime.Unixtime) (*unixtime.Unixtime, string, error) {
+	switch req.Placement {
+	case "", importPlacementNow:
+		if req.Nbf == nil {
+			return &now, "", nil
+		}
+		if req.Nbf.After(now.Time) {
+			return nil, "nbf must not be in the future for placement 'now'; use placement 'next'", nil
+		}
+		return req.Nbf, "", nil
+	case importPlacementNext:
+		if req.Nbf != nil {
+			if !req.Nbf.After(now.Time) {
+				return nil, "nbf must be in the future for placement 'next'", nil
+			}
+			return req.Nbf, "", nil
+		}
+		// As for rotated keys, the next key becomes active once all entity
+		// configurations issued without it have expired
+		lifetime, err := storage.GetEntityConfigurationLifetime(h.kvStorage)
+		if err != nil {
+			return nil, "", err
+		}
+		return &unixtime.Unixtime{Time: now.Add(lifetime)}, "", nil
+	default:
+		return nil, fmt.Sprintf("invalid placement '%s'", req.Placement), nil
+	}
+}
+
+// phaseOutKeys lets the other keys of the algorithm expire after the
+// rotation overlap once the imported key is active, and revokes them if
+// requested. On error, the keys that were already updated are restored.
+func (h *kmsHandlers) phaseOutKeys(
+	alg jwa.SignatureAlgorithm, newKID string, nbf unixtime.Unixtime, overlap time.Duration, revoke bool,
+	reason string,
+) (restore func() error, err error) {
+	pks := h.keyManagement.KMSManagedPKs
+	valid, err := pks.GetValid()
+	if err != nil {
+		return nil, err
+	}
+	var updated []public.PublicKeyEntry
+	// Update does not clear metadata, so the original entries are restored
+	// by replacing them
+	restore = func() error {
+		for _, k := range updated {
+			if rErr := pks.Delete(k.KID); rErr != nil {
+				return errors.Wrapf(rErr, "could not restore key '%s'", k.KID)
+			}
+			if rErr := pks.Add(k); rErr != nil {
+				return errors.Wrapf(rErr, "could not restore key '%s'", k.KID)
+			}
+		}
+		return nil
+	}
+	newExp := &unixtime.Unixtime{Time: nbf.Add(overlap)}
+	for _, k := range valid.ByAlg()[alg] {
+		if k.KID == newKID {
+			continue
+		}
+		original := k
+		if revoke {
+			now := unixtime.Now()
+			k.RevokedAt = &now
+			k.Reason = reason
+		}
+		if k.ExpiresAt == nil || k.ExpiresAt.IsZero() || newExp.Before(k.ExpiresAt.Time) {
+			k.ExpiresAt = newExp
+		}
+		if err = pks.Update(k.KID, k.UpdateablePublicKeyMetadata); err != nil {
+			if rErr := restore(); rErr != nil {
+				return nil, errors.Wrap(err, rErr.Error())
+			}
+			return nil, err
+		}
+		updated = append(updated, original)
+	}
+	return restore, nil
+}
+
+func (h *kmsHandlers) importKey(c *fiber.Ctx) error {
+	importer, ok := h.keyManagement.Keys.(KeyImporter)
+	if !ok {
+		return writeBadRequest(c, "kms does not support importing keys")
+	}
+	var req importKeyReq
+	if err := c.BodyParser(&req); err != nil {
+		return writeBadBody(c)
+	}
+	if len(req.Key) == 0 {
+		return writeBadRequest(c, "missing key")
+	}
+	revoke := c.QueryBool("revoke", false)
+	reason := c.Query("reason")
+	if revoke && req.Placement == importPlacementNext {
+		return writeBadRequest(c, "revoke is only supported for placement 'now'")
+	}
+	sk, jwkAlg, err := parsePrivateKey(req.Key)
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+	alg, errMsg := h.importAlg(sk, req.Alg, jwkAlg)
+	if errMsg != "" {
+		return writeBadRequest(c, errMsg)
+	}
+	pk, kid, err := jwx.SignerToPublicJWK(sk, alg)
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	existing, err := h.keyManagement.KMSManagedPKs.Get(kid)
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	if existing != nil {
+		return writeConflict(c, "key '"+kid+"' already exists")
+	}
+
+	now := unixtime.Now()
+	nbf, errMsg, err := h.importNbf(req, now)
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	if errMsg != "" {
+		return writeBadRequest(c, errMsg)
+	}
+	rotation, err := storage.GetKeyRotation(h.kvStorage)
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	exp := req.Exp
+	if exp == nil && rotation.Enabled {
+		exp = &unixtime.Unixtime{Time: nbf.Add(rotation.Interval.Duration())}
+	}
+	if exp != nil && !exp.After(nbf.Time) {
+		return writeBadRequest(c, "exp must be after nbf")
+	}
+
+	entry := public.PublicKeyEntry{
+		KID:       kid,
+		Key:       public.JWKKey{Key: pk},
+		IssuedAt:  &now,
+		NotBefore: nbf,
+		UpdateablePublicKeyMetadata: public.UpdateablePublicKeyMetadata{
+			ExpiresAt: exp,
+		},
+	}
+	placement := req.Placement
+	if placement == "" {
+		placement = importPlacementNow
+	}
+	// The old keys are only phased out and the import is only recorded once
+	// the key is stored; if either fails, the import is rolled back
+	commit := func() error {
+		restore, err := h.phaseOutKeys(alg, kid, *nbf, rotation.Overlap.Duration(), revoke, reason)
+		if err != nil {
+			return err
+		}
+		if err = h.recordKeyEvent(
+			c, kid, smodel.KeyEventTypeImported,
+			fmt.Sprintf("alg: %s, placement: %s, nbf: %d", alg.String(), placement, nbf.Unix()),
+		); err != nil {
+			if rErr := restore(); rErr != nil {
+				return errors.Wrap(err, rErr.Error())
+			}
+			return err
+		}
+		return nil
+	}
+	if err = importer.ImportKey(sk, entry, commit); err != nil {
+		return writeServerError(c, err)
+	}
+
+	created, err := h.keyManagement.KMSManagedPKs.Get(kid)
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// recordKeyEvent records an event for the key set of the handlers.
+func (h *kmsHandlers) recordKeyEvent(c *fiber.Ctx, kid, eventType, msg string) error {
+	if h.events == nil {
+		return nil
+	}
+	event := smodel.KeyEvent{
+		KeySet:    h.keySet,
+		KID:       kid,
+		Timestamp: time.Now().Unix(),
+		Type:      eventType,
+	}
+	if msg != "" {
+		event.Message = &msg
+	}
+	if actor := GetActor(c); actor != "" {
+		event.Actor = &actor
+	}
+	return h.events.Add(event)
+}
+
+type keyEventResponse struct {
+	Timestamp int64   `json:"timestamp"`
+	Type      string  `json:"type"`
+	KID       string  `json:"kid"`
+	Message   *string `json:"message,omitempty"`
+	Actor     *string `json:"actor,omitempty"`
+}
+
+func (h *kmsHandlers) getEvents(c *fiber.Ctx) error {
+	if h.events == nil {
+		return writeBadRequest(c, "key events are not available")
+	}
+	opts, ok := parseEventQueryOpts(c)
+	if !ok {
+		return nil
+	}
+	eventsList, page, err := h.events.GetByKeySet(h.keySet, opts)
+	if err != nil {
+		return writeListError(c, err)
+	}
+	eventsResp := make([]keyEventResponse, len(eventsList))
+	for i, e := range eventsList {
+		eventsResp[i] = keyEventResponse{
+			Timestamp: e.Timestamp,
+			Type:      e.Type,
+			KID:       e.KID,
+			Message:   e.Message,
+			Actor:     e.Actor,
+		}
+	}
+	return c.JSON(
+		fiber.Map{
+			"events":     eventsResp,
+			"pagination": eventPagination(c, opts, page),
+		},
+	)
+}
@@ -0,0 +1,421 @@
+package adminapi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// mockImportKMS is a multi alg KMS mock that supports importing keys.
+type mockImportKMS struct {
+	*mockMultiAlgKMS
+	pks      public.PublicKeyStorage
+	imported map[string]crypto.Signer
+}
+
+func (m *mockImportKMS) ImportKey(sk crypto.Signer, entry public.PublicKeyEntry, commit func() error) error {
+	if err := m.pks.Add(entry); err != nil {
+		return err
+	}
+	if err := commit(); err != nil {
+		if dErr := m.pks.Delete(entry.KID); dErr != nil {
+			return dErr
+		}
+		return err
+	}
+	m.imported[entry.KID] = sk
+	return nil
+}
+
+// failingKeyEventStore is a KeyEventStore whose Add always fails.
+type failingKeyEventStore struct {
+	model.KeyEventStore
+}
+
+func (failingKeyEventStore) Add(model.KeyEvent) error {
+	return errors.New("event storage unavailable")
+}
+
+// setupImportApp creates an app whose KMS supports importing keys and which
+// has an active ES256 key "old-key".
+func setupImportApp(t *testing.T) (*fiber.App, *mockImportKMS, *storage.Storage) {
+	t.Helper()
+	store := newTestStorage(t)
+	app, m := newImportApp(t, store, store.KeyEventsStorage())
+	return app, m, store
+}
+
+// newImportApp creates the app of setupImportApp with the passed key event
+// store.
+func newImportApp(t *testing.T, store *storage.Storage, events model.KeyEventStore) (*fiber.App, *mockImportKMS) {
+	t.Helper()
+	pks := store.DBPublicKeyStorage("federation")
+	if err := pks.Load(); err != nil {
+		t.Fatalf("Failed to load public key storage: %v", err)
+	}
+	m := &mockImportKMS{
+		mockMultiAlgKMS: newMockMultiAlgKMS(),
+		pks:             pks,
+		imported:        make(map[string]crypto.Signer),
+	}
+	oldSK, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	oldPK, _, err := jwx.SignerToPublicJWK(oldSK, jwa.ES256())
+	if err != nil {
+		t.Fatalf("Failed to create JWK: %v", err)
+	}
+	nbf := unixtime.Unixtime{Time: time.Now().Add(-time.Hour)}
+	if err = pks.Add(
+		public.PublicKeyEntry{
+			KID:       "old-key",
+			Key:       public.JWKKey{Key: oldPK},
+			NotBefore: &nbf,
+		},
+	); err != nil {
+		t.Fatalf("Failed to add old key: %v", err)
+	}
+	km := KeyManagement{
+		KMS:           "mock-kms",
+		KMSManagedPKs: pks,
+		BasicKeys:     m,
+		Keys:          m,
+	}
+	app := fiber.New()
+	app.Use(actorMiddleware(ActorConfig{Source: ActorSourceHeader}))
+	backends := model.Backends{
+		KV:        store.KeyValue(),
+		KeyEvents: events,
+	}
+	registerKeys(app, km, store.KeyValue(), backends)
+	return app, m
+}
+
+func newECKeyPEM(t *testing.T, curve elliptic.Curve) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	sk, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(sk)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return sk, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func importRequest(t *testing.T, app *fiber.App, query string, body map[string]any) (*http.Response, []byte) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/kms/import"+query, strings.NewReader(string(data)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", "alice")
+	return doRequest(t, app, req)
+}
+
+func TestImportKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("PEMActivateNow", func(t *testing.T) {
+		t.Parallel()
+		app, m, store := setupImportApp(t)
+		_, keyPEM := newECKeyPEM(t, elliptic.P256())
+
+		resp, body := importRequest(t, app, "", map[string]any{"key": keyPEM})
+		requireStatus(t, resp, body, http.StatusCreated)
+		var created public.PublicKeyEntry
+		if err := json.Unmarshal(body, &created); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if _, ok := m.imported[created.KID]; !ok {
+			t.Fatalf("Expected key %s to be imported", created.KID)
+		}
+		if a, _ := created.Key.Algorithm(); a.String() != "ES256" {
+			t.Errorf("Expected alg ES256, got %v", a)
+		}
+		if created.NotBefore == nil || time.Since(created.NotBefore.Time) > time.Minute {
+			t.Errorf("Expected nbf now, got %v", created.NotBefore)
+		}
+
+		old, err := m.pks.Get("old-key")
+		if err != nil || old == nil {
+			t.Fatalf("Failed to get old key: %v", err)
+		}
+		if old.ExpiresAt == nil || (old.ExpiresAt.Sub(created.NotBefore.Time)-time.Hour).Abs() > time.Second {
+			t.Errorf("Expected old key to expire after the rotation overlap, got %v", old.ExpiresAt)
+		}
+		if old.RevokedAt != nil {
+			t.Error("Expected old key not to be revoked")
+		}
+
+		events, page, err := store.KeyEventsStorage().GetByKeySet("federation", model.EventQueryOpts{})
+		if err != nil || page.Total != 1 {
+			t.Fatalf("Expected one key event, got %d (%v)", page.Total, err)
+		}
+		if events[0].Type != model.KeyEventTypeImported || events[0].KID != created.KID ||
+			events[0].Actor == nil || *events[0].Actor != "alice" {
+			t.Errorf("Unexpected key event %+v", events[0])
+		}
+	})
+
+	t.Run("JWKNextKey", func(t *testing.T) {
+		t.Parallel()
+		app, m, _ := setupImportApp(t)
+		sk, _ := newECKeyPEM(t, elliptic.P256())
+		key, err := jwk.Import(sk)
+		if err != nil {
+			t.Fatalf("Failed to create JWK: %v", err)
+		}
+
+		resp, body := importRequest(t, app, "", map[string]any{"key": key, "placement": "next"})
+		requireStatus(t, resp, body, http.StatusCreated)
+		var created public.PublicKeyEntry
+		if err = json.Unmarshal(body, &created); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		expectedNbf := time.Now().Add(storage.DefaultEntityConfigurationLifetime)
+		if created.NotBefore == nil || created.NotBefore.Sub(expectedNbf).Abs() > time.Minute {
+			t.Errorf("Expected nbf after the entity configuration lifetime, got %v", created.NotBefore)
+		}
+		old, _ := m.pks.Get("old-key")
+		if old.ExpiresAt == nil || (old.ExpiresAt.Sub(created.NotBefore.Time)-time.Hour).Abs() > time.Second {
+			t.Errorf("Expected old key to expire after the next key's nbf plus overlap, got %v", old.ExpiresAt)
+		}
+	})
+
+	t.Run("RevokeOldKeys", func(t *testing.T) {
+		t.Parallel()
+		app, m, _ := setupImportApp(t)
+		_, keyPEM := newECKeyPEM(t, elliptic.P256())
+
+		resp, body := importRequest(t, app, "?revoke=true&reason=migration", map[string]any{"key": keyPEM})
+		requireStatus(t, resp, body, http.StatusCreated)
+		old, _ := m.pks.Get("old-key")
+		if old.RevokedAt == nil || old.Reason != "migration" {
+			t.Errorf("Expected old key to be revoked, got %+v", old)
+		}
+	})
+
+	t.Run("ExplicitTimes", func(t *testing.T) {
+		t.Parallel()
+		app, _, _ := setupImportApp(t)
+		_, keyPEM := newECKeyPEM(t, elliptic.P256())
+		nbf := time.Now().Add(-24 * time.Hour).Unix()
+		exp := time.Now().Add(30 * 24 * time.Hour).Unix()
+
+		resp, body := importRequest(t, app, "", map[string]any{"key": keyPEM, "alg": "ES256", "nbf": nbf, "exp": exp})
+		requireStatus(t, resp, body, http.StatusCreated)
+		var created public.PublicKeyEntry
+		if err := json.Unmarshal(body, &created); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if created.NotBefore.Unix() != nbf || created.ExpiresAt == nil || created.ExpiresAt.Unix() != exp {
+			t.Errorf("Expected nbf %d and exp %d, got %v and %v", nbf, exp, created.NotBefore, created.ExpiresAt)
+		}
+	})
+
+	t.Run("RollbackOnEventFailure", func(t *testing.T) {
+		t.Parallel()
+		store := newTestStorage(t)
+		app, m := newImportApp(t, store, failingKeyEventStore{})
+		sk, keyPEM := newECKeyPEM(t, elliptic.P256())
+		_, kid, err := jwx.SignerToPublicJWK(sk, jwa.ES256())
+		if err != nil {
+			t.Fatalf("Failed to create JWK: %v", err)
+		}
+		before, _ := m.pks.Get("old-key")
+
+		for _, query := range []string{"", "?revoke=true&reason=migration"} {
+			resp, body := importRequest(t, app, query, map[string]any{"key": keyPEM})
+			assertErrorResponse(t, resp, body, http.StatusInternalServerError, "server_error")
+			if len(m.imported) != 0 {
+				t.Error("Expected no key to be imported")
+			}
+			if pk, _ := m.pks.Get(kid); pk != nil {
+				t.Errorf("Expected public key %s to be removed, got %+v", kid, pk)
+			}
+			old, _ := m.pks.Get("old-key")
+			if old == nil || old.ExpiresAt != nil || old.RevokedAt != nil || old.Reason != "" ||
+				!old.NotBefore.Equal(before.NotBefore.Time) {
+				t.Errorf("Expected old key to be restored, got %+v", old)
+			}
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		t.Parallel()
+		app, _, _ := setupImportApp(t)
+		_, keyPEM := newECKeyPEM(t, elliptic.P256())
+
+		resp, body := importRequest(t, app, "", map[string]any{"key": keyPEM})
+		requireStatus(t, resp, body, http.StatusCreated)
+		resp, body = importRequest(t, app, "", map[string]any{"key": keyPEM})
+		assertStatus(t, resp, body, http.StatusConflict)
+	})
+
+	_, p256PEM := newECKeyPEM(t, elliptic.P256())
+	_, p384PEM := newECKeyPEM(t, elliptic.P384())
+	for name, tc := range map[string]struct {
+		query string
+		body  map[string]any
+	}{
+		"MissingKey":         {body: map[string]any{}},
+		"InvalidPEM":         {body: map[string]any{"key": "not a key"}},
+		"PublicJWK":          {body: map[string]any{"key": map[string]any{"kty": "RSA", "n": testRSAKeyN, "e": "AQAB"}}},
+		"AlgNotConfigured":   {body: map[string]any{"key": p384PEM}},
+		"AlgDoesNotMatchKey": {body: map[string]any{"key": p256PEM, "alg": "RS256"}},
+		"InvalidPlacement":   {body: map[string]any{"key": p256PEM, "placement": "later"}},
+		"FutureNbfForNow":    {body: map[string]any{"key": p256PEM, "nbf": time.Now().Add(time.Hour).Unix()}},
+		"PastNbfForNext": {
+			body: map[string]any{"key": p256PEM, "placement": "next", "nbf": time.Now().Add(-time.Hour).Unix()},
+		},
+		"ExpBeforeNbf":      {body: map[string]any{"key": p256PEM, "exp": time.Now().Add(-time.Minute).Unix()}},
+		"RevokeForNextKey":  {query: "?revoke=true", body: map[string]any{"key": p256PEM, "placement": "next"}},
+		"UnsupportedFormat": {body: map[string]any{"key": "-----BEGIN CERTIFICATE-----\nAA==\n-----END CERTIFICATE-----\n"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app, m, _ := setupImportApp(t)
+			resp, body := importRequest(t, app, tc.query, tc.body)
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+			if len(m.imported) != 0 {
+				t.Error("Expected no key to be imported")
+			}
+		})
+	}
+
+	t.Run("NotSupported", func(t *testing.T) {
+		t.Parallel()
+		store := newTestStorage(t)
+		m := newMockMultiAlgKMS()
+		km := KeyManagement{
+			KMS:       "mock-kms",
+			BasicKeys: m,
+			Keys:      m,
+		}
+		app := fiber.New()
+		registerKeys(app, km, store.KeyValue(), model.Backends{KV: store.KeyValue()})
+		resp, body := importRequest(t, app, "", map[string]any{"key": p256PEM})
+		assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+	})
+}
+
+func TestGetKeyEvents(t *testing.T) {
+	t.Parallel()
+	app, _, _ := setupImportApp(t)
+	_, keyPEM := newECKeyPEM(t, elliptic.P256())
+	resp, body := importRequest(t, app, "", map[string]any{"key": keyPEM})
+	requireStatus(t, resp, body, http.StatusCreated)
+
+	resp, body = doRequest(t, app, httptest.NewRequest(http.MethodGet, "/kms/events?type=imported", http.NoBody))
+	requireStatus(t, resp, body, http.StatusOK)
+	var res struct {
+		Events []keyEventResponse `json:"events"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(res.Events) != 1 || res.Events[0].Type != model.KeyEventTypeImported {
+		t.Errorf("Expected one import event, got %+v", res.Events)
+	}
+	if resp.Header.Get(headerTotalCount) != "1" {
+		t.Errorf("Expected total count 1, got %q", resp.Header.Get(headerTotalCount))
+	}
+
+	resp, body = doRequest(t, app, httptest.NewRequest(http.MethodGet, "/kms/events?limit=x", http.NoBody))
+	assertStatus(t, resp, body, http.StatusBadRequest)
+}
+
+func TestGetKeyEventsPaging(t *testing.T) {
+	t.Parallel()
+	app, _, store := setupImportApp(t)
+	events := store.KeyEventsStorage()
+	for _, kid := range []string{"kid-1", "kid-2", "kid-3", "other"} {
+		if err := events.Add(
+			model.KeyEvent{KeySet: keySetFederation, KID: kid, Type: model.KeyEventTypeImported},
+		); err != nil {
+			t.Fatalf("Failed to add event: %v", err)
+		}
+	}
+	if err := events.Add(model.KeyEvent{KeySet: "other-set", KID: "kid-4"}); err != nil {
+		t.Fatalf("Failed to add event: %v", err)
+	}
+
+	collect := func(query string) []string {
+		var kids []string
+		cursor := ""
+		for range 10 {
+			target := "/kms/events?limit=2" + query
+			if cursor != "" {
+				target += "&cursor=" + url.QueryEscape(cursor)
+			}
+			resp, body := doRequest(t, app, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+			requireStatus(t, resp, body, http.StatusOK)
+			var res struct {
+				Events     []keyEventResponse `json:"events"`
+				Pagination struct {
+					NextCursor string `json:"next_cursor"`
+				} `json:"pagination"`
+			}
+			if err := json.Unmarshal(body, &res); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			for _, e := range res.Events {
+				kids = append(kids, e.KID)
+			}
+			if res.Pagination.NextCursor != resp.Header.Get(headerNextCursor) {
+				t.Errorf("Expected next_cursor to match the %s header", headerNextCursor)
+			}
+			if cursor = res.Pagination.NextCursor; cursor == "" {
+				return kids
+			}
+		}
+		t.Fatal("Paging did not terminate")
+		return nil
+	}
+
+	// Newest events first by default
+	if got, want := collect(""), "other,kid-3,kid-2,kid-1"; strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+	if got, want := collect("&order=asc"), "kid-1,kid-2,kid-3,other"; strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+	if got, want := collect("&search=KID-&order=asc"), "kid-1,kid-2,kid-3"; strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+
+	resp, body := doRequest(
+		t, app, httptest.NewRequest(http.MethodGet, "/kms/events?sort=entity_id", http.NoBody),
+	)
+	assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+	resp, body = doRequest(
+		t, app, httptest.NewRequest(http.MethodGet, "/kms/events?offset=1&cursor=eyJpZCI6MX0", http.NoBody),
+	)
+	assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+}
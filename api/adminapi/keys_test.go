@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -30,6 +31,10 @@ func (*mockBasicKMS) GetDefaultAlg() jwa.SignatureAlgorithm {
 	return jwa.ES256()
 }
 
+func (*mockBasicKMS) GetAlgs() []jwa.SignatureAlgorithm {
+	return []jwa.SignatureAlgorithm{jwa.ES256()}
+}
+
 // mockFullKMS implements kms.KeyManagementSystem for testing endpoints that require
 // the full KMS interface (rotation, algorithm changes, etc.).
 type mockFullKMS struct {
@@ -45,6 +50,7 @@ func (*mockFullKMS) ChangeAlgsAt(_ []jwa.SignatureAlgorithm, _ unixtime.Unixtime
 func (*mockFullKMS) ChangeDefaultAlgorithmAt(_ jwa.SignatureAlgorithm, _ unixtime.Unixtime) error {
 	return nil
 }
+func (*mockFullKMS) ChangeDefaultAlgorithm(_ jwa.SignatureAlgorithm) error { return nil }
 func (*mockFullKMS) GetPendingChanges() (*kms.PendingAlgChange, *kms.PendingDefaultChange) {
 	return nil, nil
 }
@@ -245,6 +251,51 @@ func TestGetPublicKeys(t *testing.T) {
 			t.Errorf("Expected 0 keys, got %d", len(keys))
 		}
 	})
+
+	t.Run("Paging", func(t *testing.T) {
+		t.Parallel()
+		app, km, _ := setupPublicKeyApp(t)
+		now := time.Now()
+		for i, kid := range []string{"key-b", "key-c", "key-a", "other"} {
+			iat := unixtime.Unixtime{Time: now.Add(time.Duration(i) * time.Minute)}
+			if err := km.APIManagedPKs.Add(public.PublicKeyEntry{
+				KID:      kid,
+				Key:      public.JWKKey{Key: createTestKey(kid)},
+				IssuedAt: &iat,
+			}); err != nil {
+				t.Fatalf("Failed to add key: %v", err)
+			}
+		}
+		key := func(item public.PublicKeyEntry) string { return item.KID }
+
+		got := collectPages(t, app, "/entity-configuration/keys/?limit=3", "4", key)
+		want := []string{"key-a", "key-b", "key-c", "other"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		got = collectPages(t, app, "/entity-configuration/keys/?sort=created_at&order=desc&limit=1", "4", key)
+		want = []string{"other", "key-a", "key-c", "key-b"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		got = collectPages(t, app, "/entity-configuration/keys/?search=KEY&limit=2", "3", key)
+		want = []string{"key-a", "key-b", "key-c"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("UnsupportedSort", func(t *testing.T) {
+		t.Parallel()
+		app, _, _ := setupPublicKeyApp(t)
+
+		req := httptest.NewRequest("GET", "/entity-configuration/keys/?sort=entity_id", http.NoBody)
+		resp, respBody := doRequest(t, app, req)
+
+		assertErrorResponse(t, resp, respBody, http.StatusBadRequest, "invalid_request")
+	})
 }
 
 func TestDeletePublicKey(t *testing.T) {
@@ -684,6 +735,9 @@ func TestGetKMSInfo(t *testing.T) {
 		if info["alg"] != "ES256" {
 			t.Errorf("Expected alg 'ES256', got %q", info["alg"])
 		}
+		if algs, ok := info["algs"].([]any); !ok || len(algs) != 1 || algs[0] != "ES256" {
+			t.Errorf("Expected algs [ES256], got %v", info["algs"])
+		}
 		// Default RSA key length should be 2048
 		if rsaLen, ok := info["rsa_key_len"].(float64); !ok || int(rsaLen) != 2048 {
 			t.Errorf("Expected rsa_key_len 2048, got %v", info["rsa_key_len"])
@@ -871,6 +925,185 @@ func TestPutKMSAlg(t *testing.T) {
 	})
 }
 
+// mockMultiAlgKMS is a stateful KMS mock with multiple algorithms that applies
+// algorithm changes immediately and records scheduled changes.
+type mockMultiAlgKMS struct {
+	mockFullKMS
+	algs             []jwa.SignatureAlgorithm
+	defaultAlg       jwa.SignatureAlgorithm
+	scheduledDefault *jwa.SignatureAlgorithm
+}
+
+func newMockMultiAlgKMS() *mockMultiAlgKMS {
+	return &mockMultiAlgKMS{
+		algs:       []jwa.SignatureAlgorithm{jwa.ES256(), jwa.RS256()},
+		defaultAlg: jwa.ES256(),
+	}
+}
+
+func (m *mockMultiAlgKMS) GetAlgs() []jwa.SignatureAlgorithm     { return m.algs }
+func (m *mockMultiAlgKMS) GetDefaultAlg() jwa.SignatureAlgorithm { return m.defaultAlg }
+func (m *mockMultiAlgKMS) ChangeAlgsAt(algs []jwa.SignatureAlgorithm, _ unixtime.Unixtime, _ time.Duration) error {
+	m.algs = algs
+	return nil
+}
+func (m *mockMultiAlgKMS) ChangeDefaultAlgorithm(alg jwa.SignatureAlgorithm) error {
+	m.defaultAlg = alg
+	return nil
+}
+func (m *mockMultiAlgKMS) ChangeDefaultAlgorithmAt(alg jwa.SignatureAlgorithm, _ unixtime.Unixtime) error {
+	m.scheduledDefault = &alg
+	return nil
+}
+
+func newMultiAlgKMSApp(t *testing.T, m *mockMultiAlgKMS) (*fiber.App, *storage.Storage) {
+	t.Helper()
+	store := newTestStorage(t)
+	km := KeyManagement{
+		KMS:       "mock-kms",
+		BasicKeys: m,
+		Keys:      m,
+	}
+	app := fiber.New()
+	backends := model.Backends{
+		KV:         store.KeyValue(),
+		PKStorages: func(tid string) public.PublicKeyStorage { return store.DBPublicKeyStorage(tid) },
+	}
+	registerKeys(app, km, store.KeyValue(), backends)
+	return app, store
+}
+
+func TestPutKMSAlgWithMultipleAlgs(t *testing.T) {
+	t.Parallel()
+	t.Run("SwitchesDefaultWithinSet", func(t *testing.T) {
+		t.Parallel()
+		m := newMockMultiAlgKMS()
+		app, store := newMultiAlgKMSApp(t, m)
+
+		req := httptest.NewRequest("PUT", "/kms/alg", strings.NewReader(`RS256`))
+		resp, respBody := doRequest(t, app, req)
+		requireStatus(t, resp, respBody, 200)
+
+		if m.defaultAlg.String() != "RS256" || m.scheduledDefault != nil {
+			t.Errorf("Expected default to switch to RS256 immediately, got %s", m.defaultAlg)
+		}
+		if len(m.algs) != 2 {
+			t.Errorf("Expected the algorithm set to be kept, got %v", m.algs)
+		}
+		alg, err := storage.GetSigningAlg(store.KeyValue())
+		if err != nil {
+			t.Fatalf("GetSigningAlg failed: %v", err)
+		}
+		if alg.String() != "RS256" {
+			t.Errorf("Expected stored default RS256, got %s", alg)
+		}
+	})
+
+	t.Run("ReplacesDefaultWithNewAlg", func(t *testing.T) {
+		t.Parallel()
+		m := newMockMultiAlgKMS()
+		app, _ := newMultiAlgKMSApp(t, m)
+
+		req := httptest.NewRequest("PUT", "/kms/alg", strings.NewReader(`EdDSA`))
+		resp, respBody := doRequest(t, app, req)
+		requireStatus(t, resp, respBody, 200)
+
+		if got := algStrings(m.algs); !slices.Equal(got, []string{"EdDSA", "RS256"}) {
+			t.Errorf("Expected algs [EdDSA RS256], got %v", got)
+		}
+		if m.scheduledDefault == nil || m.scheduledDefault.String() != "EdDSA" {
+			t.Errorf("Expected scheduled default change to EdDSA, got %v", m.scheduledDefault)
+		}
+	})
+}
+
+func TestPutKMSAlgs(t *testing.T) {
+	t.Parallel()
+	t.Run("NotSupportedWhenKeysNil", func(t *testing.T) {
+		t.Parallel()
+		store := newTestStorage(t)
+		km := KeyManagement{
+			KMS:       "mock-kms",
+			BasicKeys: &mockBasicKMS{},
+		}
+		app := fiber.New()
+		registerKeys(app, km, store.KeyValue(), model.Backends{KV: store.KeyValue()})
+
+		req := httptest.NewRequest("PUT", "/kms/algs", strings.NewReader(`{"algs":["ES256"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, respBody := doRequest(t, app, req)
+		assertErrorResponse(t, resp, respBody, http.StatusBadRequest, "invalid_request")
+	})
+
+	for name, body := range map[string]string{
+		"InvalidBody":      `not json`,
+		"EmptyAlgs":        `{"algs":[]}`,
+		"InvalidAlgorithm": `{"algs":["ES256","INVALID-ALG"]}`,
+		"MissingDefault":   `{"algs":["RS256"]}`,
+		"DefaultNotInAlgs": `{"algs":["ES256"],"default":"RS256"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app, _ := newMultiAlgKMSApp(t, newMockMultiAlgKMS())
+			req := httptest.NewRequest("PUT", "/kms/algs", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, respBody := doRequest(t, app, req)
+			assertErrorResponse(t, resp, respBody, http.StatusBadRequest, "invalid_request")
+		})
+	}
+
+	t.Run("AddsAlgs", func(t *testing.T) {
+		t.Parallel()
+		m := newMockMultiAlgKMS()
+		app, store := newMultiAlgKMSApp(t, m)
+
+		req := httptest.NewRequest("PUT", "/kms/algs", strings.NewReader(`{"algs":["RS256","ES256","EdDSA"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, respBody := doRequest(t, app, req)
+		requireStatus(t, resp, respBody, 200)
+
+		if got := algStrings(m.algs); !slices.Equal(got, []string{"ES256", "RS256", "EdDSA"}) {
+			t.Errorf("Expected algs [ES256 RS256 EdDSA] with the default first, got %v", got)
+		}
+		if m.defaultAlg.String() != "ES256" || m.scheduledDefault != nil {
+			t.Errorf("Expected default to stay ES256, got %s", m.defaultAlg)
+		}
+		var info map[string]any
+		if err := json.Unmarshal(respBody, &info); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if algs, ok := info["algs"].([]any); !ok || len(algs) != 3 {
+			t.Errorf("Expected 3 algs in the response, got %v", info["algs"])
+		}
+
+		var stored []storage.SigningAlgsWithNbf
+		if _, err := store.KeyValue().GetAs(model.KeyValueScopeSigning, model.KeyValueKeyAlgs, &stored); err != nil {
+			t.Fatalf("Failed to read stored algs: %v", err)
+		}
+		if len(stored) != 1 || len(stored[0].SigningAlgs) != 3 || stored[0].Nbf == nil {
+			t.Errorf("Expected the algorithm set to be stored with a not-before time, got %+v", stored)
+		}
+	})
+
+	t.Run("ChangesDefault", func(t *testing.T) {
+		t.Parallel()
+		m := newMockMultiAlgKMS()
+		app, _ := newMultiAlgKMSApp(t, m)
+
+		req := httptest.NewRequest("PUT", "/kms/algs", strings.NewReader(`{"algs":["RS256"],"default":"RS256"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, respBody := doRequest(t, app, req)
+		requireStatus(t, resp, respBody, 200)
+
+		if m.defaultAlg.String() != "RS256" {
+			t.Errorf("Expected default RS256, got %s", m.defaultAlg)
+		}
+		if got := algStrings(m.algs); !slices.Equal(got, []string{"RS256"}) {
+			t.Errorf("Expected algs [RS256], got %v", got)
+		}
+	})
+}
+
 func TestPutKMSRSAKeyLen(t *testing.T) {
 	t.Parallel()
 	t.Run("Success", func(t *testing.T) {
@@ -0,0 +1,73 @@
+package adminapi
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// Response headers carrying paging information of list endpoints.
+const (
+	headerTotalCount = "X-Total-Count"
+	headerNextCursor = "X-Next-Cursor"
+)
+
+// parseListOptions parses the limit, cursor, sort, order, and search query
+// parameters of a list request.
+func parseListOptions(c *fiber.Ctx) (model.ListOptions, error) {
+	var opts model.ListOptions
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return opts, model.ValidationError("limit must be a non-negative integer")
+		}
+		opts.Limit = min(limit, model.MaxListLimit)
+	}
+	err := parseListOrdering(c, &opts)
+	return opts, err
+}
+
+// parseListOrdering parses the cursor, sort, order, and search query
+// parameters of a list request into opts.
+func parseListOrdering(c *fiber.Ctx, opts *model.ListOptions) error {
+	sort, err := model.ParseSortField(c.Query("sort"))
+	if err != nil {
+		return err
+	}
+	opts.Sort = sort
+	switch order := c.Query("order"); order {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return model.ValidationErrorFmt("invalid order '%s' (valid values: asc, desc)", order)
+	}
+	if opts.Cursor = c.Query("cursor"); opts.Cursor != "" {
+		if _, err = model.DecodeListCursor(opts.Cursor); err != nil {
+			return err
+		}
+	}
+	opts.Search = c.Query("search")
+	return nil
+}
+
+// setPageHeaders sets the paging response headers.
+func setPageHeaders(c *fiber.Ctx, page model.PageInfo) {
+	c.Set(headerTotalCount, strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		c.Set(headerNextCursor, page.NextCursor)
+	}
+}
+
+// writeListError writes the error response of a failed list query; invalid
+// list options result in a 400 response.
+func writeListError(c *fiber.Ctx, err error) error {
+	var validation model.ValidationError
+	if errors.As(err, &validation) {
+		return writeBadRequest(c, err.Error())
+	}
+	return writeServerError(c, err)
+}
@@ -0,0 +1,77 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// collectPages requests target page by page following the X-Next-Cursor
+// header and returns the keys of all returned entries in order. Every page
+// must report wantTotal in the X-Total-Count header.
+func collectPages[T any](t *testing.T, app *fiber.App, target, wantTotal string, key func(T) string) []string {
+	t.Helper()
+	sep := "?"
+	if strings.Contains(target, "?") {
+		sep = "&"
+	}
+	var got []string
+	cursor := ""
+	for range 10 {
+		u := target
+		if cursor != "" {
+			u += sep + "cursor=" + url.QueryEscape(cursor)
+		}
+		resp, body := doRequest(t, app, httptest.NewRequest("GET", u, http.NoBody))
+		requireStatus(t, resp, body, http.StatusOK)
+		if total := resp.Header.Get(headerTotalCount); total != wantTotal {
+			t.Errorf("Expected %s %s, got %q", headerTotalCount, wantTotal, total)
+		}
+		var items []T
+		if err := json.Unmarshal(body, &items); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		for _, item := range items {
+			got = append(got, key(item))
+		}
+		if cursor = resp.Header.Get(headerNextCursor); cursor == "" {
+			return got
+		}
+	}
+	t.Fatalf("Paging of %s did not terminate", target)
+	return nil
+}
+
+func TestParseListOptions(t *testing.T) {
+	t.Parallel()
+	app := fiber.New()
+	app.Get(
+		"/", func(c *fiber.Ctx) error {
+			opts, err := parseListOptions(c)
+			if err != nil {
+				return writeListError(c, err)
+			}
+			return c.JSON(opts)
+		},
+	)
+
+	resp, body := doRequest(
+		t, app, httptest.NewRequest("GET", "/?limit=5000&sort=entity_id&order=desc&search=foo", http.NoBody),
+	)
+	requireStatus(t, resp, body, http.StatusOK)
+	for _, want := range []string{`"Limit":1000`, `"Sort":"entity_id"`, `"Desc":true`, `"Search":"foo"`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected %s in %s", want, body)
+		}
+	}
+
+	for _, query := range []string{"limit=-1", "limit=abc", "sort=status", "order=up", "cursor=!!"} {
+		resp, body = doRequest(t, app, httptest.NewRequest("GET", "/?"+query, http.NoBody))
+		assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+	}
+}
@@ -1,11 +1,13 @@
 package adminapi
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"net"
 	neturl "net/url"
 	"strconv"
+	"time"
 
 	oidfed "github.com/go-oidfed/lib"
 	"github.com/gofiber/fiber/v2"
@@ -15,7 +17,7 @@ import (
 	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
-//go:embed swagger.html swagger-users.html openapi.yaml openapi-users.yaml
+//go:embed swagger.html swagger-users.html openapi.yaml openapi-users.yaml stats-dashboard.html
 var assets embed.FS
 
 // Options controls optional features of the admin API registration.
@@ -28,6 +30,22 @@ type Options struct {
 	// TrustMarkConfigInvalidator is called when entity configuration trust marks are modified
 	// to invalidate any cached configurations. Can be nil if not using trust mark refresh.
 	TrustMarkConfigInvalidator TrustMarkConfigInvalidator
+	// TrustMarkCacheInvalidator is called when issued trust marks are revoked
+	// to invalidate the caches of the trust mark endpoint. Can be nil.
+	TrustMarkCacheInvalidator TrustMarkCacheInvalidator
+	// KeySets holds the key management of the key sets that use separate
+	// keys (e.g. for trust marks), keyed by key set name.
+	KeySets map[string]KeyManagement
+	// StatsTail streams the recorded requests for the live tail of the
+	// stats API. Can be nil.
+	StatsTail StatsTail
+	// Context is canceled when LightHouse is stopped; background jobs of the
+	// admin API, e.g. topology crawls, are canceled with it. If nil,
+	// context.Background is used.
+	Context context.Context
+	// TopologyCrawlTimeout limits the duration of topology crawls; if zero,
+	// DefaultTopologyCrawlTimeout is used.
+	TopologyCrawlTimeout time.Duration
 	// Actor holds configuration for actor extraction from requests.
 	// The actor is recorded in subordinate event history.
 	Actor ActorConfig
@@ -120,6 +138,12 @@ func Register(
 			return c.Send(html)
 		},
 	)
+	// Stats dashboard page; it loads its data from the authenticated stats API
+	if storages.Stats != nil {
+		if err = registerStatsDashboard(r); err != nil {
+			return err
+		}
+	}
 	// Optional authentication middleware for all admin routes
 	r.Use(authMiddleware(storages.Users))
 
@@ -129,13 +153,26 @@ func Register(
 		actorCfg = opts.Actor
 	}
 	r.Use(actorMiddleware(actorCfg))
+	// ETags and If-Match preconditions for optimistic concurrency control
+	r.Use(etagMiddleware())
 
 	// Entity Configuration
 	registerEntityConfiguration(r, storages.AdditionalClaims, storages.KV, fedEntity)
 	// Authority Hints
 	registerAuthorityHints(r, storages.AuthorityHints)
+	var entityID string
+	if fedEntity != nil {
+		entityID = fedEntity.EntityID()
+	}
 	// Keys (with transaction support for key rotation)
 	registerKeys(r, keyManagement, storages.KV, storages)
+	registerJWKSSnapshot(r, keyManagement, entityID)
+	// Separate key sets
+	var keySets map[string]KeyManagement
+	if opts != nil {
+		keySets = opts.KeySets
+	}
+	registerKeySets(r, keySets, storages.KV, storages.KeyEvents)
 	// Entity Configuration Trust Marks
 	var trustMarkInvalidator TrustMarkConfigInvalidator
 	if opts != nil {
@@ -150,15 +187,35 @@ func Register(
 	registerTrustMarkOwners(r, storages.TrustMarkOwners, storages.TrustMarkTypes)
 	registerTrustMarkIssuers(r, storages.TrustMarkIssuers, storages.TrustMarkTypes)
 	registerTrustMarkIssuance(r, storages.TrustMarkSpecs)
+	// Issued trust mark instances and their revocation
+	var trustMarkCacheInvalidator TrustMarkCacheInvalidator
+	if opts != nil {
+		trustMarkCacheInvalidator = opts.TrustMarkCacheInvalidator
+	}
+	registerTrustMarkInstances(r, storages.TrustMarkInstances, trustMarkCacheInvalidator)
 	// Users management
 	if opts == nil || opts.UsersEnabled {
 		registerUsers(r, storages.Users)
 	}
 	// Stats API (if stats storage is available)
 	if storages.Stats != nil {
-		statsAPI := NewStatsAPI(storages.Stats)
+		var statsTail StatsTail
+		if opts != nil {
+			statsTail = opts.StatsTail
+		}
+		statsAPI := NewStatsAPI(storages.Stats, storages.Subordinates, statsTail)
 		statsAPI.RegisterRoutes(r.Group("/stats"))
 	}
+	// Federation topology crawls
+	ctx := context.Background()
+	var crawlTimeout time.Duration
+	if opts != nil {
+		if opts.Context != nil {
+			ctx = opts.Context
+		}
+		crawlTimeout = opts.TopologyCrawlTimeout
+	}
+	registerTopology(ctx, r, entityID, crawlTimeout)
 	return nil
 }
 
@@ -1,22 +1,57 @@
 package adminapi
 
 import (
+	"bufio"
+	"encoding/json"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
 
 	"github.com/go-oidfed/lighthouse/internal/stats"
 	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
+// defaultUnfetchedRange is the time range checked for subordinates that are
+// never fetched if none is requested.
+const defaultUnfetchedRange = 30 * 24 * time.Hour
+
+const (
+	// tailBufferSize is the number of entries buffered for a tail stream
+	// before entries are dropped.
+	tailBufferSize = 256
+	// tailHeartbeat is the interval of empty lines sent on an idle tail
+	// stream, so closed connections are noticed.
+	tailHeartbeat = 10 * time.Second
+	// tailMaxDuration limits the duration of a single tail stream; clients
+	// reconnect to continue.
+	tailMaxDuration = 10 * time.Minute
+)
+
+// StatsTail streams the recorded request logs, e.g. the stats.Collector.
+type StatsTail interface {
+	Subscribe(filter stats.TailFilter, size int) (<-chan *stats.RequestLog, func())
+}
+
 // StatsAPI provides REST endpoints for querying statistics.
 type StatsAPI struct {
-	storage model.StatsStorageBackend
+	storage      model.StatsStorageBackend
+	subordinates model.SubordinateStorageBackend
+	tail         StatsTail
 }
 
-// NewStatsAPI creates a new stats API instance.
-func NewStatsAPI(storage model.StatsStorageBackend) *StatsAPI {
-	return &StatsAPI{storage: storage}
+// NewStatsAPI creates a new stats API instance. The subordinate storage is
+// used to report subordinates that are never fetched and can be nil. The
+// tail streams the live requests and can be nil if they are not recorded in
+// this process.
+func NewStatsAPI(
+	storage model.StatsStorageBackend, subordinates model.SubordinateStorageBackend, tail StatsTail,
+) *StatsAPI {
+	return &StatsAPI{
+		storage:      storage,
+		subordinates: subordinates,
+		tail:         tail,
+	}
 }
 
 // RegisterRoutes registers all stats routes under the given router group.
@@ -27,18 +62,45 @@ func (api *StatsAPI) RegisterRoutes(r fiber.Router) {
 	r.Get("/top/clients", api.getTopClients)
 	r.Get("/top/countries", api.getTopCountries)
 	r.Get("/top/params", api.getTopParams)
+	r.Get("/top/subjects", api.getTopSubjects)
+	r.Get("/top/trust-mark-subjects", api.getTopTrustMarkSubjects)
+	r.Get("/subordinates/unfetched", api.getUnfetchedSubordinates)
 	r.Get("/timeseries", api.getTimeSeries)
 	r.Get("/latency", api.getLatency)
 	r.Get("/daily", api.getDailyStats)
 	r.Get("/export", api.export)
+	r.Get("/tail", api.streamTail)
+}
+
+// registerStatsDashboard serves the built-in stats dashboard at
+// /stats/dashboard. The page itself is static and does not need
+// authentication; it loads all data from the stats API, which does.
+func registerStatsDashboard(r fiber.Router) error {
+	dashboardHTML, err := assets.ReadFile("stats-dashboard.html")
+	if err != nil {
+		return errors.Wrap(err, "adminapi: failed to read stats-dashboard.html")
+	}
+	r.Get(
+		"/stats/dashboard", func(c *fiber.Ctx) error {
+			c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+			return c.Send(dashboardHTML)
+		},
+	)
+	return nil
 }
 
 // parseTimeRange extracts from/to time parameters from the request.
 // Defaults to last 24 hours if not specified.
 func parseTimeRange(c *fiber.Ctx) (from, to time.Time) {
+	return parseTimeRangeWithDefault(c, 24*time.Hour)
+}
+
+// parseTimeRangeWithDefault extracts from/to time parameters from the
+// request. Defaults to the passed duration up to now if not specified.
+func parseTimeRangeWithDefault(c *fiber.Ctx, d time.Duration) (from, to time.Time) {
 	now := time.Now().UTC()
 	to = now
-	from = now.Add(-24 * time.Hour)
+	from = now.Add(-d)
 
 	if fromStr := c.Query("from"); fromStr != "" {
 		if t, err := time.Parse(time.RFC3339, fromStr); err == nil {
@@ -198,6 +260,162 @@ func (api *StatsAPI) getTopParams(c *fiber.Ctx) error {
 	})
 }
 
+// getTopSubjects returns the most requested subjects, i.e. the sub
+// parameter, optionally for a single endpoint.
+// GET /stats/top/subjects?from=&to=&endpoint=fetch&limit=10
+func (api *StatsAPI) getTopSubjects(c *fiber.Ctx) error {
+	from, to := parseTimeRange(c)
+	limit := parseLimit(c)
+	endpoint := c.Query("endpoint")
+
+	entries, err := api.storage.GetTopSubjects(from, to, endpoint, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"from":     from,
+		"to":       to,
+		"limit":    limit,
+		"endpoint": endpoint,
+		"subjects": entries,
+	})
+}
+
+// getTopTrustMarkSubjects returns the most requested trust mark subjects per
+// trust mark type, optionally for a single trust mark type.
+// GET /stats/top/trust-mark-subjects?from=&to=&trust_mark_type=&limit=10
+func (api *StatsAPI) getTopTrustMarkSubjects(c *fiber.Ctx) error {
+	from, to := parseTimeRange(c)
+	limit := parseLimit(c)
+	trustMarkType := c.Query("trust_mark_type")
+
+	entries, err := api.storage.GetTopTrustMarkSubjects(from, to, trustMarkType, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"from":            from,
+		"to":              to,
+		"limit":           limit,
+		"trust_mark_type": trustMarkType,
+		"subjects":        entries,
+	})
+}
+
+// UnfetchedSubordinate is an active subordinate that was not fetched.
+type UnfetchedSubordinate struct {
+	ID        uint   `json:"id"`
+	EntityID  string `json:"entity_id"`
+	CreatedAt int    `json:"created_at"`
+}
+
+// UnfetchedSubordinates lists the active subordinates whose subordinate
+// statement was not fetched in a time range.
+type UnfetchedSubordinates struct {
+	From time.Time `json:"from"`
+	// RequestedFrom is set if From was moved to the oldest retained request
+	// log, since older requests are no longer known.
+	RequestedFrom *time.Time `json:"requested_from,omitempty"`
+	To            time.Time  `json:"to"`
+	Endpoint      string     `json:"endpoint"`
+	// Approximate is set if requests to the endpoint were sampled; fetches
+	// that were not recorded are not known, so fetched subordinates can be
+	// listed.
+	Approximate  bool                   `json:"approximate"`
+	Subordinates []UnfetchedSubordinate `json:"subordinates"`
+}
+
+// FindUnfetchedSubordinates returns the active subordinates whose subordinate
+// statement was never fetched at endpoint between from and to; they are
+// candidates for a clean-up. Only the detailed request logs are checked, so
+// from is moved to the oldest retained log. Subordinates created after from
+// are not reported.
+func FindUnfetchedSubordinates(
+	statsStorage model.StatsStorageBackend, subordinates model.SubordinateStorageBackend,
+	from, to time.Time, endpoint string,
+) (*UnfetchedSubordinates, error) {
+	result := &UnfetchedSubordinates{
+		From:         from,
+		To:           to,
+		Endpoint:     endpoint,
+		Subordinates: make([]UnfetchedSubordinate, 0),
+	}
+	first, err := statsStorage.FirstRequestLogTime()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get retained request logs")
+	}
+	if first.IsZero() || first.After(from) {
+		result.RequestedFrom = &from
+		result.From = first
+		if first.IsZero() || first.After(to) {
+			// Nothing is known about the range
+			result.From = to
+			return result, nil
+		}
+	}
+	result.Approximate, err = statsStorage.HasSampledRequests(result.From, to, endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check sampling")
+	}
+
+	fetched, err := statsStorage.GetRequestedSubjects(result.From, to, endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get fetched subjects")
+	}
+	active, err := subordinates.GetByStatus(model.StatusActive)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subordinates")
+	}
+	fetchedSet := make(map[string]struct{}, len(fetched))
+	for _, sub := range fetched {
+		fetchedSet[sub] = struct{}{}
+	}
+	for _, sub := range active {
+		if int64(sub.CreatedAt) > result.From.Unix() {
+			continue
+		}
+		if _, ok := fetchedSet[sub.EntityID]; ok {
+			continue
+		}
+		result.Subordinates = append(
+			result.Subordinates, UnfetchedSubordinate{
+				ID:        sub.ID,
+				EntityID:  sub.EntityID,
+				CreatedAt: sub.CreatedAt,
+			},
+		)
+	}
+	return result, nil
+}
+
+// getUnfetchedSubordinates returns the active subordinates whose subordinate
+// statement was never fetched in the time range, see
+// FindUnfetchedSubordinates. The range defaults to the last 30 days.
+// GET /stats/subordinates/unfetched?from=&to=&endpoint=fetch
+func (api *StatsAPI) getUnfetchedSubordinates(c *fiber.Ctx) error {
+	if api.subordinates == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "subordinate storage not available",
+		})
+	}
+	from, to := parseTimeRangeWithDefault(c, defaultUnfetchedRange)
+	endpoint := c.Query("endpoint", "fetch")
+
+	result, err := FindUnfetchedSubordinates(api.storage, api.subordinates, from, to, endpoint)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(result)
+}
+
 // getTimeSeries returns time series data.
 // GET /stats/timeseries?from=&to=&endpoint=&interval=hour
 func (api *StatsAPI) getTimeSeries(c *fiber.Ctx) error {
@@ -281,3 +499,69 @@ func (api *StatsAPI) export(c *fiber.Ctx) error {
 		return api.storage.ExportCSV(from, to, c.Response().BodyWriter())
 	}
 }
+
+// streamTail streams the recorded requests as newline-delimited JSON.
+// Streams end before the server's write timeout; clients reconnect to
+// continue.
+// GET /stats/tail?endpoint=&status=5xx&client=&subject=
+func (api *StatsAPI) streamTail(c *fiber.Ctx) error {
+	if api.tail == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "live requests are not available",
+		})
+	}
+	statusClass, err := stats.ParseStatusClass(c.Query("status"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter := stats.TailFilter{
+		Endpoint:    c.Query("endpoint"),
+		StatusClass: statusClass,
+		ClientIP:    c.Query("client"),
+		Subject:     c.Query("subject"),
+	}
+
+	duration := tailMaxDuration
+	if writeTimeout := c.App().Config().WriteTimeout; writeTimeout > 0 {
+		duration = min(duration, writeTimeout-writeTimeout/10)
+	}
+	entries, unsubscribe := api.tail.Subscribe(filter, tailBufferSize)
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(
+		func(w *bufio.Writer) {
+			defer unsubscribe()
+			heartbeat := time.NewTicker(tailHeartbeat)
+			defer heartbeat.Stop()
+			end := time.NewTimer(duration)
+			defer end.Stop()
+
+			enc := json.NewEncoder(w)
+			for {
+				select {
+				case entry, ok := <-entries:
+					if !ok {
+						return
+					}
+					if err := enc.Encode(entry); err != nil {
+						return
+					}
+				case <-heartbeat.C:
+					if err := w.WriteByte('\n'); err != nil {
+						return
+					}
+				case <-end.C:
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		},
+	)
+	return nil
+}
@@ -1,6 +1,7 @@
 package adminapi
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -24,6 +25,12 @@ type mockStatsStorageBackend struct {
 	getTopQueryParamsFn    func(time.Time, time.Time, string, int) ([]istats.TopEntry, error)
 	getTimeSeriesFn        func(time.Time, time.Time, string, istats.Interval) ([]istats.TimeSeriesPoint, error)
 	getLatencyFn           func(time.Time, time.Time, string) (*istats.LatencyStats, error)
+	getEndpointStatsFn     func(time.Time, time.Time) ([]istats.EndpointStats, error)
+	getTopSubjectsFn       func(time.Time, time.Time, string, int) ([]istats.SubjectStats, error)
+	getTopTMSubjectsFn     func(time.Time, time.Time, string, int) ([]istats.SubjectStats, error)
+	getRequestedSubjectsFn func(time.Time, time.Time, string) ([]string, error)
+	firstRequestLogTimeFn  func() (time.Time, error)
+	hasSampledRequestsFn   func(time.Time, time.Time, string) (bool, error)
 	aggregateDailyStatsFn  func(time.Time) error
 	getDailyStatsFn        func(time.Time, time.Time) ([]istats.DailyStats, error)
 	purgeDetailedLogsFn    func(time.Time) (int64, error)
@@ -71,6 +78,39 @@ func (m *mockStatsStorageBackend) GetLatencyPercentiles(from, to time.Time, endp
 	return m.getLatencyFn(from, to, endpoint)
 }
 
+func (m *mockStatsStorageBackend) GetEndpointStats(from, to time.Time) ([]istats.EndpointStats, error) {
+	if m.getEndpointStatsFn != nil {
+		return m.getEndpointStatsFn(from, to)
+	}
+	return nil, nil
+}
+
+func (m *mockStatsStorageBackend) GetTopSubjects(from, to time.Time, endpoint string, limit int) ([]istats.SubjectStats, error) {
+	return m.getTopSubjectsFn(from, to, endpoint, limit)
+}
+
+func (m *mockStatsStorageBackend) GetTopTrustMarkSubjects(from, to time.Time, trustMarkType string, limit int) ([]istats.SubjectStats, error) {
+	return m.getTopTMSubjectsFn(from, to, trustMarkType, limit)
+}
+
+func (m *mockStatsStorageBackend) GetRequestedSubjects(from, to time.Time, endpoint string) ([]string, error) {
+	return m.getRequestedSubjectsFn(from, to, endpoint)
+}
+
+func (m *mockStatsStorageBackend) FirstRequestLogTime() (time.Time, error) {
+	if m.firstRequestLogTimeFn != nil {
+		return m.firstRequestLogTimeFn()
+	}
+	return time.Time{}, nil
+}
+
+func (m *mockStatsStorageBackend) HasSampledRequests(from, to time.Time, endpoint string) (bool, error) {
+	if m.hasSampledRequestsFn != nil {
+		return m.hasSampledRequestsFn(from, to, endpoint)
+	}
+	return false, nil
+}
+
 func (m *mockStatsStorageBackend) AggregateDailyStats(date time.Time) error {
 	if m.aggregateDailyStatsFn != nil {
 		return m.aggregateDailyStatsFn(date)
@@ -89,6 +129,32 @@ func (m *mockStatsStorageBackend) PurgeDetailedLogs(before time.Time) (int64, er
 	return 0, nil
 }
 
+func (*mockStatsStorageBackend) GetRequestLogsAfter(uint64, int) ([]istats.RequestLog, error) {
+	return nil, nil
+}
+
+func (*mockStatsStorageBackend) AggregateHourlyStats(time.Time) error {
+	return nil
+}
+
+func (*mockStatsStorageBackend) LastHourlyAggregation() (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (*mockStatsStorageBackend) PurgeHourlyStats(time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (*mockStatsStorageBackend) AnonymizeRequestLogs(time.Time, time.Time, func(*istats.RequestLog) bool) (int64, error) {
+	return 0, nil
+}
+
+func (*mockStatsStorageBackend) AnonymizeDailyStats(
+	time.Time, time.Time, func(string, time.Time) string,
+) (int64, error) {
+	return 0, nil
+}
+
 func (m *mockStatsStorageBackend) PurgeAggregatedStats(before time.Time) (int64, error) {
 	if m.purgeAggregatedStatsFn != nil {
 		return m.purgeAggregatedStatsFn(before)
@@ -107,7 +173,7 @@ func (m *mockStatsStorageBackend) ExportJSON(from, to time.Time, w io.Writer) er
 func setupStatsTestApp(t *testing.T, store model.StatsStorageBackend) *fiber.App {
 	t.Helper()
 	app := fiber.New()
-	NewStatsAPI(store).RegisterRoutes(app.Group("/stats"))
+	NewStatsAPI(store, nil, nil).RegisterRoutes(app.Group("/stats"))
 	return app
 }
 
@@ -179,6 +245,28 @@ func TestStatsAPISummary(t *testing.T) {
 	})
 }
 
+func TestStatsDashboard(t *testing.T) {
+	t.Parallel()
+
+	app := fiber.New()
+	if err := registerStatsDashboard(app); err != nil {
+		t.Fatalf("registerStatsDashboard: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/dashboard", http.NoBody)
+	resp, body := doRequest(t, app, req)
+	requireStatus(t, resp, body, http.StatusOK)
+
+	if ct := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, fiber.MIMETextHTML) {
+		t.Fatalf("expected HTML content type, got %q", ct)
+	}
+	for _, path := range []string{"/summary", "/timeseries", "/latency", "/top/clients", "/top/countries", "/top/user-agents", "/daily"} {
+		if !strings.Contains(string(body), `"`+path+`"`) {
+			t.Errorf("expected dashboard to load %s", path)
+		}
+	}
+}
+
 func TestStatsAPITopEndpoints(t *testing.T) {
 	t.Parallel()
 
@@ -320,6 +408,201 @@ func TestStatsAPITopBreakdowns(t *testing.T) {
 	})
 }
 
+func TestStatsAPISubjects(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TopSubjects", func(t *testing.T) {
+		t.Parallel()
+
+		var gotEndpoint string
+		var gotLimit int
+		app := setupStatsTestApp(t, &mockStatsStorageBackend{
+			getTopSubjectsFn: func(_, _ time.Time, endpoint string, limit int) ([]istats.SubjectStats, error) {
+				gotEndpoint = endpoint
+				gotLimit = limit
+				return []istats.SubjectStats{{Subject: "https://rp.example", RequestCount: 7, ErrorCount: 1}}, nil
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/stats/top/subjects?endpoint=fetch&limit=3", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+		if gotEndpoint != "fetch" || gotLimit != 3 {
+			t.Fatalf("unexpected subjects call: endpoint=%q limit=%d", gotEndpoint, gotLimit)
+		}
+		if !strings.Contains(string(body), `"subject":"https://rp.example"`) || !strings.Contains(string(body), `"error_count":1`) {
+			t.Fatalf("expected subject entry in body, got %s", string(body))
+		}
+	})
+
+	t.Run("TopTrustMarkSubjects", func(t *testing.T) {
+		t.Parallel()
+
+		var gotType string
+		app := setupStatsTestApp(t, &mockStatsStorageBackend{
+			getTopTMSubjectsFn: func(_, _ time.Time, trustMarkType string, _ int) ([]istats.SubjectStats, error) {
+				gotType = trustMarkType
+				return []istats.SubjectStats{{
+					Subject: "https://rp.example", TrustMarkType: "https://tm.example/member", RequestCount: 2,
+				}}, nil
+			},
+		})
+
+		req := httptest.NewRequest(
+			http.MethodGet, "/stats/top/trust-mark-subjects?trust_mark_type=https://tm.example/member", http.NoBody,
+		)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+		if gotType != "https://tm.example/member" {
+			t.Fatalf("unexpected trust mark type %q", gotType)
+		}
+		if !strings.Contains(string(body), `"trust_mark_type":"https://tm.example/member"`) {
+			t.Fatalf("expected trust mark subject entry in body, got %s", string(body))
+		}
+	})
+
+	t.Run("UnfetchedSubordinates", func(t *testing.T) {
+		t.Parallel()
+
+		subordinates := addUnfetchedTestSubordinates(t)
+		var gotEndpoint string
+		var gotFrom, gotTo time.Time
+		app := fiber.New()
+		NewStatsAPI(&mockStatsStorageBackend{
+			getRequestedSubjectsFn: func(from, to time.Time, endpoint string) ([]string, error) {
+				gotFrom, gotTo, gotEndpoint = from, to, endpoint
+				return []string{"https://fetched.example", "https://not-a-subordinate.example"}, nil
+			},
+			firstRequestLogTimeFn: func() (time.Time, error) {
+				return time.Now().Add(-90 * 24 * time.Hour), nil
+			},
+		}, subordinates, nil).RegisterRoutes(app.Group("/stats"))
+
+		req := httptest.NewRequest(http.MethodGet, "/stats/subordinates/unfetched", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+		if gotEndpoint != "fetch" {
+			t.Fatalf("expected default endpoint fetch, got %q", gotEndpoint)
+		}
+		if d := gotTo.Sub(gotFrom); d < defaultUnfetchedRange-time.Minute || d > defaultUnfetchedRange+time.Minute {
+			t.Fatalf("expected default range of 30 days, got %s", d)
+		}
+		var result UnfetchedSubordinates
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(result.Subordinates) != 1 || result.Subordinates[0].EntityID != "https://unfetched.example" {
+			t.Fatalf("expected only the unfetched subordinate, got %s", string(body))
+		}
+		if result.RequestedFrom != nil || result.Approximate {
+			t.Fatalf("expected an exact result for the requested range, got %s", string(body))
+		}
+	})
+
+	t.Run("UnfetchedClampedToRetention", func(t *testing.T) {
+		t.Parallel()
+
+		subordinates := addUnfetchedTestSubordinates(t)
+		first := time.Now().Add(-70 * 24 * time.Hour).Truncate(time.Second)
+		var gotFrom time.Time
+		app := fiber.New()
+		NewStatsAPI(&mockStatsStorageBackend{
+			getRequestedSubjectsFn: func(from, _ time.Time, _ string) ([]string, error) {
+				gotFrom = from
+				return nil, nil
+			},
+			firstRequestLogTimeFn: func() (time.Time, error) {
+				return first, nil
+			},
+			hasSampledRequestsFn: func(time.Time, time.Time, string) (bool, error) {
+				return true, nil
+			},
+		}, subordinates, nil).RegisterRoutes(app.Group("/stats"))
+
+		requested := time.Now().Add(-100 * 24 * time.Hour).UTC().Format(time.RFC3339)
+		req := httptest.NewRequest(http.MethodGet, "/stats/subordinates/unfetched?from="+requested, http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+		if !gotFrom.Equal(first) {
+			t.Fatalf("expected the range to start at the first log %v, got %v", first, gotFrom)
+		}
+		var result UnfetchedSubordinates
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if !result.From.Equal(first) || result.RequestedFrom == nil ||
+			result.RequestedFrom.UTC().Format(time.RFC3339) != requested {
+			t.Fatalf("expected from clamped to the first log and the requested from, got %s", string(body))
+		}
+		if !result.Approximate {
+			t.Fatalf("expected an approximate result with sampling, got %s", string(body))
+		}
+		// fetched.example is not reported, since it was created after the
+		// first log
+		if len(result.Subordinates) != 1 || result.Subordinates[0].EntityID != "https://unfetched.example" {
+			t.Fatalf("expected only the unfetched subordinate, got %s", string(body))
+		}
+	})
+
+	t.Run("UnfetchedWithoutRequestLogs", func(t *testing.T) {
+		t.Parallel()
+
+		app := fiber.New()
+		NewStatsAPI(&mockStatsStorageBackend{}, addUnfetchedTestSubordinates(t), nil).
+			RegisterRoutes(app.Group("/stats"))
+		req := httptest.NewRequest(http.MethodGet, "/stats/subordinates/unfetched", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+		var result UnfetchedSubordinates
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(result.Subordinates) != 0 || result.RequestedFrom == nil || !result.From.Equal(result.To) {
+			t.Fatalf("expected no subordinates for an empty range, got %s", string(body))
+		}
+	})
+
+	t.Run("UnfetchedWithoutSubordinateStorage", func(t *testing.T) {
+		t.Parallel()
+
+		app := setupStatsTestApp(t, &mockStatsStorageBackend{})
+		req := httptest.NewRequest(http.MethodGet, "/stats/subordinates/unfetched", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusNotImplemented)
+	})
+}
+
+// addUnfetchedTestSubordinates adds subordinates for the unfetched
+// subordinates tests: two old active ones, a blocked and a new one.
+func addUnfetchedTestSubordinates(t *testing.T) model.SubordinateStorageBackend {
+	t.Helper()
+	store := newSubordinateTestStorage(t)
+	subordinates := store.Backends().Subordinates
+	old := int(time.Now().Add(-60 * 24 * time.Hour).Unix())
+	older := int(time.Now().Add(-80 * 24 * time.Hour).Unix())
+	for _, sub := range []struct {
+		entityID  string
+		status    model.Status
+		createdAt int
+	}{
+		{"https://fetched.example", model.StatusActive, old},
+		{"https://unfetched.example", model.StatusActive, older},
+		{"https://blocked.example", model.StatusBlocked, old},
+		{"https://new.example", model.StatusActive, 0},
+	} {
+		if err := subordinates.Add(model.ExtendedSubordinateInfo{
+			BasicSubordinateInfo: model.BasicSubordinateInfo{
+				EntityID:  sub.entityID,
+				Status:    sub.status,
+				CreatedAt: sub.createdAt,
+			},
+		}); err != nil {
+			t.Fatalf("Failed to add subordinate: %v", err)
+		}
+	}
+	return subordinates
+}
+
 func TestStatsAPITimeSeriesLatencyAndDaily(t *testing.T) {
 	t.Parallel()
 
@@ -484,3 +767,83 @@ func TestStatsAPIExport(t *testing.T) {
 		}
 	})
 }
+
+type mockStatsTail struct {
+	entries []*istats.RequestLog
+	filter  istats.TailFilter
+}
+
+func (m *mockStatsTail) Subscribe(filter istats.TailFilter, size int) (<-chan *istats.RequestLog, func()) {
+	m.filter = filter
+	ch := make(chan *istats.RequestLog, max(size, len(m.entries)))
+	for _, e := range m.entries {
+		if filter.Match(e) {
+			ch <- e
+		}
+	}
+	close(ch)
+	return ch, func() {}
+}
+
+func TestStatsAPITail(t *testing.T) {
+	t.Parallel()
+
+	t.Run("StreamsFilteredEntries", func(t *testing.T) {
+		t.Parallel()
+
+		tail := &mockStatsTail{
+			entries: []*istats.RequestLog{
+				{Endpoint: "fetch", StatusCode: 200, ClientIP: "192.0.2.1", Subject: "https://a.example"},
+				{Endpoint: "fetch", StatusCode: 404, ClientIP: "192.0.2.1", Subject: "https://b.example"},
+				{Endpoint: "resolve", StatusCode: 500, ClientIP: "192.0.2.2"},
+				{Endpoint: "fetch", StatusCode: 403, ClientIP: "192.0.2.2", Subject: "https://c.example"},
+			},
+		}
+		app := fiber.New()
+		NewStatsAPI(&mockStatsStorageBackend{}, nil, tail).RegisterRoutes(app.Group("/stats"))
+
+		req := httptest.NewRequest(http.MethodGet, "/stats/tail?endpoint=/fetch&status=4xx&client=192.0.2.1", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusOK)
+
+		want := istats.TailFilter{Endpoint: "/fetch", StatusClass: 4, ClientIP: "192.0.2.1"}
+		if tail.filter != want {
+			t.Fatalf("unexpected filter %+v, want %+v", tail.filter, want)
+		}
+		if ct := resp.Header.Get(fiber.HeaderContentType); ct != "application/x-ndjson" {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("expected one streamed entry, got %d: %s", len(lines), string(body))
+		}
+		var entry istats.RequestLog
+		if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+			t.Fatalf("failed to decode streamed entry: %v", err)
+		}
+		if entry.Subject != "https://b.example" || entry.StatusCode != 404 {
+			t.Fatalf("unexpected streamed entry %+v", entry)
+		}
+	})
+
+	t.Run("InvalidStatusClass", func(t *testing.T) {
+		t.Parallel()
+
+		app := fiber.New()
+		NewStatsAPI(&mockStatsStorageBackend{}, nil, &mockStatsTail{}).RegisterRoutes(app.Group("/stats"))
+
+		req := httptest.NewRequest(http.MethodGet, "/stats/tail?status=6xx", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusBadRequest)
+	})
+
+	t.Run("NotAvailable", func(t *testing.T) {
+		t.Parallel()
+
+		app := setupStatsTestApp(t, &mockStatsStorageBackend{})
+
+		req := httptest.NewRequest(http.MethodGet, "/stats/tail", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, http.StatusNotImplemented)
+	})
+}
@@ -10,6 +10,8 @@
 //   - subordinates_additional_claims.go: Additional claims endpoints
 //   - subordinates_statement.go: Statement preview endpoint
 //   - subordinates_lifetime.go: Lifetime configuration endpoint
+//   - subordinates_bulk.go: Bulk operations on subordinates selected by labels
+//   - subordinates_import_export.go: Bulk import and export of subordinates
 //   - subordinates_helpers.go: Shared helper functions
 package adminapi
 
@@ -20,9 +22,6 @@ import (
 	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
-// DefaultSubordinateStatus is the default status for newly created subordinates.
-var DefaultSubordinateStatus = model.StatusActive
-
 // RegisterSubordinateHandlers registers all subordinate-related handlers on the given router.
 // This includes basic CRUD, metadata, metadata policies, constraints, keys, additional claims,
 // statement preview, and lifetime configuration endpoints.
@@ -52,6 +51,12 @@ func RegisterSubordinateHandlers(
 	// General lifetime: /subordinates/lifetime
 	registerGeneralSubordinateLifetime(r, storages.KV)
 
+	// Bulk operations: /subordinates/bulk/*
+	registerSubordinatesBulk(r, storages)
+
+	// Import and export: /subordinates/import, /subordinates/export
+	registerSubordinatesImportExport(r, storages)
+
 	// Base CRUD operations: /subordinates, /subordinates/:subordinateID, etc.
 	registerSubordinatesBase(r, storages)
 
@@ -9,6 +9,7 @@ import (
 	oidfed "github.com/go-oidfed/lib"
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/go-oidfed/lighthouse/storage"
 	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
@@ -18,8 +19,9 @@ type subordinatesBaseHandlers struct {
 }
 
 type listSubordinatesRequest struct {
-	Status     *model.Status `query:"-"`
-	EntityType []string      `query:"entity_type"`
+	Status        *model.Status `query:"-"`
+	EntityType    []string      `query:"entity_type"`
+	LabelSelector string        `query:"label_selector"`
 }
 
 func (h *subordinatesBaseHandlers) list(c *fiber.Ctx) error {
@@ -38,29 +40,33 @@ func (h *subordinatesBaseHandlers) list(c *fiber.Ctx) error {
 		req.Status = &st
 	}
 
-	var infos []model.BasicSubordinateInfo
-	var err error
+	selector, err := model.ParseLabelSelector(req.LabelSelector)
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
 
-	switch {
-	case req.EntityType != nil && req.Status != nil:
-		infos, err = h.storages.Subordinates.GetByStatusAndAnyEntityType(*req.Status, req.EntityType)
-	case req.EntityType != nil:
-		infos, err = h.storages.Subordinates.GetByAnyEntityType(req.EntityType)
-	case req.Status != nil:
-		infos, err = h.storages.Subordinates.GetByStatus(*req.Status)
-	default:
-		infos, err = h.storages.Subordinates.GetAll()
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return writeBadRequest(c, err.Error())
 	}
 
+	infos, page, err := h.storages.Subordinates.FindPage(
+		model.SubordinateFilter{
+			Status:      req.Status,
+			EntityTypes: req.EntityType,
+			Labels:      selector,
+		}, opts,
+	)
 	if err != nil {
 		return writeServerError(c, err)
 	}
+	setPageHeaders(c, page)
 	return c.JSON(infos)
 }
 
 func (h *subordinatesBaseHandlers) create(c *fiber.Ctx) error {
 	var req model.AddSubordinate
-	req.Status = DefaultSubordinateStatus
+	req.Status = model.DefaultSubordinateStatus
 	if err := c.BodyParser(&req); err != nil {
 		return writeBadBody(c)
 	}
@@ -70,6 +76,9 @@ func (h *subordinatesBaseHandlers) create(c *fiber.Ctx) error {
 	if !req.Status.Valid() {
 		return writeBadRequest(c, "invalid status")
 	}
+	if err := model.ValidateLabels(req.Labels); err != nil {
+		return writeBadRequest(c, err.Error())
+	}
 	record := model.ExtendedSubordinateInfo{
 		BasicSubordinateInfo: model.BasicSubordinateInfo{
 			EntityID:    req.EntityID,
@@ -83,6 +92,7 @@ func (h *subordinatesBaseHandlers) create(c *fiber.Ctx) error {
 			record.SubordinateEntityTypes[i] = model.SubordinateEntityType{EntityType: et}
 		}
 	}
+	record.Labels = model.NewSubordinateLabels(req.Labels)
 	if req.JWKS != nil {
 		record.JWKS = *req.JWKS
 	}
@@ -101,13 +111,13 @@ func (h *subordinatesBaseHandlers) create(c *fiber.Ctx) error {
 		if err != nil {
 			return err
 		}
-		return RecordEvent(
+		return model.RecordEvent(
 			tx.SubordinateEvents,
 			stored.ID,
 			model.EventTypeCreated,
-			WithStatus(stored.Status),
-			WithMessage(fmt.Sprintf("subordinate created: %s", stored.EntityID)),
-			WithActor(GetActor(c)),
+			model.WithStatus(stored.Status),
+			model.WithMessage(fmt.Sprintf("subordinate created: %s", stored.EntityID)),
+			model.WithActor(GetActor(c)),
 		)
 	})
 	if err != nil {
@@ -139,6 +149,9 @@ func (h *subordinatesBaseHandlers) update(c *fiber.Ctx) error {
 	if err := c.BodyParser(&body); err != nil {
 		return writeBadBody(c)
 	}
+	if err := model.ValidateLabels(body.Labels); err != nil {
+		return writeBadRequest(c, err.Error())
+	}
 
 	var result *model.ExtendedSubordinateInfo
 	err := h.storages.InTransaction(func(tx *model.Backends) error {
@@ -157,10 +170,13 @@ func (h *subordinatesBaseHandlers) update(c *fiber.Ctx) error {
 			}
 			existing.SubordinateEntityTypes = subordinateEntityTypes
 		}
+		if body.Labels != nil {
+			existing.Labels = model.NewSubordinateLabels(body.Labels)
+		}
 		if err = tx.Subordinates.Update(existing.EntityID, *existing); err != nil {
 			return err
 		}
-		if err = RecordEvent(tx.SubordinateEvents, existing.ID, model.EventTypeUpdated, WithStatus(existing.Status), WithActor(GetActor(c))); err != nil {
+		if err = model.RecordEvent(tx.SubordinateEvents, existing.ID, model.EventTypeUpdated, model.WithStatus(existing.Status), model.WithActor(GetActor(c))); err != nil {
 			return err
 		}
 		result = existing
@@ -185,10 +201,7 @@ func (h *subordinatesBaseHandlers) delete(c *fiber.Ctx) error {
 		if err != nil {
 			return err
 		}
-		if err := tx.SubordinateEvents.DeleteBySubordinateID(existing.ID); err != nil {
-			return err
-		}
-		return tx.Subordinates.DeleteByDBID(id)
+		return tx.DeleteSubordinate(&existing.BasicSubordinateInfo, model.WithActor(GetActor(c)))
 	})
 
 	if err != nil {
@@ -219,12 +232,7 @@ func (h *subordinatesBaseHandlers) updateStatus(c *fiber.Ctx) error {
 		if err != nil {
 			return err
 		}
-		oldStatus := existing.Status
-
-		if status == model.StatusActive && !subordinateHasKeys(existing) {
-			return fmt.Errorf("status cannot be active without keys")
-		}
-		if err := tx.Subordinates.UpdateStatusByDBID(id, status); err != nil {
+		if err = tx.UpdateSubordinateStatus(existing, status, model.WithActor(GetActor(c))); err != nil {
 			return err
 		}
 
@@ -235,17 +243,6 @@ func (h *subordinatesBaseHandlers) updateStatus(c *fiber.Ctx) error {
 		if info == nil {
 			return model.NotFoundError("subordinate not found")
 		}
-
-		if err = RecordEvent(
-			tx.SubordinateEvents,
-			info.ID,
-			model.EventTypeStatusUpdated,
-			WithStatus(status),
-			WithMessage(fmt.Sprintf("status changed from %s to %s", oldStatus, status)),
-			WithActor(GetActor(c)),
-		); err != nil {
-			return err
-		}
 		result = info
 		return nil
 	})
@@ -255,7 +252,8 @@ func (h *subordinatesBaseHandlers) updateStatus(c *fiber.Ctx) error {
 		if errors.As(err, &nf) {
 			return writeNotFound(c, err.Error())
 		}
-		if err.Error() == "status cannot be active without keys" {
+		var validation model.ValidationError
+		if errors.As(err, &validation) {
 			return writeBadRequest(c, err.Error())
 		}
 		return writeServerError(c, err)
@@ -288,14 +286,14 @@ func (h *historyHandlers) getHistory(c *fiber.Ctx) error {
 		return writeNotFound(c, "subordinate not found")
 	}
 
-	opts, ok := h.parseQueryOpts(c)
+	opts, ok := parseEventQueryOpts(c)
 	if !ok {
 		return nil
 	}
 
-	eventsList, total, err := h.events.GetBySubordinateID(info.ID, opts)
+	eventsList, page, err := h.events.GetBySubordinateID(info.ID, opts)
 	if err != nil {
-		return writeServerError(c, err)
+		return writeListError(c, err)
 	}
 
 	eventsResp := make([]eventResponse, len(eventsList))
@@ -309,22 +307,49 @@ func (h *historyHandlers) getHistory(c *fiber.Ctx) error {
 		}
 	}
 
-	limit := h.normalizeLimit(opts.Limit)
-
 	return c.JSON(fiber.Map{
-		"events": eventsResp,
-		"pagination": fiber.Map{
-			"total":  total,
-			"limit":  limit,
-			"offset": opts.Offset,
-		},
+		"events":     eventsResp,
+		"pagination": eventPagination(c, opts, page),
 	})
 }
 
-// parseQueryOpts parses query parameters for event history requests.
+// eventPagination sets the paging response headers and returns the
+// pagination object of event list responses.
+func eventPagination(c *fiber.Ctx, opts model.EventQueryOpts, page model.PageInfo) fiber.Map {
+	setPageHeaders(c, page)
+	pagination := fiber.Map{
+		"total":  page.Total,
+		"limit":  storage.NormalizeEventLimit(opts.Limit),
+		"offset": opts.Offset,
+	}
+	if page.NextCursor != "" {
+		pagination["next_cursor"] = page.NextCursor
+	}
+	return pagination
+}
+
+// handleEventsError maps errors of event queries to the matching error
+// responses.
+func handleEventsError(c *fiber.Ctx, err error) error {
+	var validation model.ValidationError
+	if errors.As(err, &validation) {
+		return writeBadRequest(c, err.Error())
+	}
+	return writeServerError(c, err)
+}
+
+// parseEventQueryOpts parses query parameters for event history requests.
+// Besides the type and time filters, events support the cursor, sort, order,
+// and search parameters of other lists as well as offset paging; unless
+// order=asc is requested, the newest events are returned first.
 // Returns (opts, true) on success, or (zero, false) if an error response was written.
-func (*historyHandlers) parseQueryOpts(c *fiber.Ctx) (model.EventQueryOpts, bool) {
+func parseEventQueryOpts(c *fiber.Ctx) (model.EventQueryOpts, bool) {
 	var opts model.EventQueryOpts
+	if err := parseListOrdering(c, &opts.ListOptions); err != nil {
+		_ = writeBadRequest(c, err.Error())
+		return opts, false
+	}
+	opts.Desc = c.Query("order") != "asc"
 
 	if limitStr := c.Query("limit"); limitStr != "" {
 		limit, err := strconv.Atoi(limitStr)
@@ -369,16 +394,6 @@ func (*historyHandlers) parseQueryOpts(c *fiber.Ctx) (model.EventQueryOpts, bool
 	return opts, true
 }
 
-func (*historyHandlers) normalizeLimit(limit int) int {
-	if limit <= 0 {
-		return 50
-	}
-	if limit > 100 {
-		return 100
-	}
-	return limit
-}
-
 // registerSubordinatesBase registers basic CRUD endpoints for subordinates.
 func registerSubordinatesBase(r fiber.Router, storages model.Backends) {
 	g := r.Group("/subordinates")
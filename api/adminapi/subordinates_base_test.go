@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -216,6 +217,205 @@ func TestGetSubordinates(t *testing.T) {
 		},
 	)
 
+	t.Run(
+		"Success/ByLabelSelector", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBaseApp(t)
+
+			backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://de.example.org",
+						Status:   model.StatusActive,
+						Labels: model.NewSubordinateLabels(
+							map[string]string{
+								"country": "de",
+								"project": "alpha",
+							},
+						),
+					},
+				},
+			)
+			backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://at.example.org",
+						Status:   model.StatusActive,
+						Labels:   model.NewSubordinateLabels(map[string]string{"country": "at"}),
+					},
+				},
+			)
+			backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://unlabeled.example.org",
+						Status:   model.StatusActive,
+					},
+				},
+			)
+
+			tests := []struct {
+				selector string
+				want     []string
+			}{
+				{selector: "country=de", want: []string{"https://de.example.org"}},
+				{selector: "country in (de,at)", want: []string{"https://de.example.org", "https://at.example.org"}},
+				{selector: "country!=de", want: []string{"https://at.example.org", "https://unlabeled.example.org"}},
+				{selector: "!country", want: []string{"https://unlabeled.example.org"}},
+				{selector: "country,project=alpha", want: []string{"https://de.example.org"}},
+			}
+			for _, tt := range tests {
+				req := httptest.NewRequest(
+					"GET", "/subordinates?label_selector="+url.QueryEscape(tt.selector), http.NoBody,
+				)
+				resp, body := doRequest(t, app, req)
+				requireStatus(t, resp, body, http.StatusOK)
+
+				var subs []model.BasicSubordinateInfo
+				if err := json.Unmarshal(body, &subs); err != nil {
+					t.Fatalf("Failed to parse response: %v", err)
+				}
+				got := make(map[string]bool, len(subs))
+				for _, sub := range subs {
+					got[sub.EntityID] = true
+				}
+				if len(got) != len(tt.want) {
+					t.Errorf("Selector %q: expected %v, got %+v", tt.selector, tt.want, subs)
+					continue
+				}
+				for _, w := range tt.want {
+					if !got[w] {
+						t.Errorf("Selector %q: expected %s in result, got %+v", tt.selector, w, subs)
+					}
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"Success/Paging", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBaseApp(t)
+
+			for _, id := range []string{"https://c.example.org", "https://a.example.org", "https://b.example.org"} {
+				backends.Subordinates.Add(
+					model.ExtendedSubordinateInfo{
+						BasicSubordinateInfo: model.BasicSubordinateInfo{
+							EntityID: id,
+							Status:   model.StatusPending,
+						},
+					},
+				)
+			}
+
+			var got []string
+			cursor := ""
+			for range 3 {
+				target := "/subordinates?sort=entity_id&order=desc&limit=2"
+				if cursor != "" {
+					target += "&cursor=" + url.QueryEscape(cursor)
+				}
+				resp, body := doRequest(t, app, httptest.NewRequest("GET", target, http.NoBody))
+				requireStatus(t, resp, body, http.StatusOK)
+				if total := resp.Header.Get("X-Total-Count"); total != "3" {
+					t.Errorf("Expected X-Total-Count 3, got %q", total)
+				}
+				var subs []model.BasicSubordinateInfo
+				if err := json.Unmarshal(body, &subs); err != nil {
+					t.Fatalf("Failed to parse response: %v", err)
+				}
+				for _, sub := range subs {
+					got = append(got, sub.EntityID)
+				}
+				if cursor = resp.Header.Get("X-Next-Cursor"); cursor == "" {
+					break
+				}
+			}
+
+			want := []string{"https://c.example.org", "https://b.example.org", "https://a.example.org"}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+		},
+	)
+
+	t.Run(
+		"Success/Search", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBaseApp(t)
+
+			backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://rp.example.org",
+						Status:   model.StatusPending,
+					},
+				},
+			)
+			backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID:    "https://op.example.org",
+						Status:      model.StatusPending,
+						Description: "University 100% Login",
+					},
+				},
+			)
+
+			tests := map[string][]string{
+				"rp.example":  {"https://rp.example.org"},
+				"UNIVERSITY":  {"https://op.example.org"},
+				"100%":        {"https://op.example.org"},
+				"_":           nil,
+				"example.org": {"https://rp.example.org", "https://op.example.org"},
+			}
+			for search, want := range tests {
+				req := httptest.NewRequest("GET", "/subordinates?search="+url.QueryEscape(search), http.NoBody)
+				resp, body := doRequest(t, app, req)
+				requireStatus(t, resp, body, http.StatusOK)
+
+				var subs []model.BasicSubordinateInfo
+				if err := json.Unmarshal(body, &subs); err != nil {
+					t.Fatalf("Failed to parse response: %v", err)
+				}
+				if len(subs) != len(want) {
+					t.Errorf("Search %q: expected %v, got %+v", search, want, subs)
+				}
+				if total := resp.Header.Get("X-Total-Count"); total != strconv.Itoa(len(want)) {
+					t.Errorf("Search %q: expected X-Total-Count %d, got %q", search, len(want), total)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidListOptions", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateBaseApp(t)
+
+			for _, query := range []string{"limit=-1", "limit=abc", "sort=status", "order=up", "cursor=!!"} {
+				req := httptest.NewRequest("GET", "/subordinates?"+query, http.NoBody)
+				resp, respBody := doRequest(t, app, req)
+
+				assertErrorResponse(t, resp, respBody, http.StatusBadRequest, "invalid_request")
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidLabelSelector", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateBaseApp(t)
+
+			req := httptest.NewRequest(
+				"GET", "/subordinates?label_selector="+url.QueryEscape("country in (de"), http.NoBody,
+			)
+			resp, respBody := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, respBody, http.StatusBadRequest, "invalid_request")
+		},
+	)
+
 	t.Run(
 		"InvalidStatus", func(t *testing.T) {
 			t.Parallel()
@@ -341,6 +541,47 @@ func TestPostSubordinates(t *testing.T) {
 		},
 	)
 
+	t.Run(
+		"Success_WithLabels", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBaseApp(t)
+
+			body := `{
+			"entity_id": "https://labeled.example.org",
+			"status": "pending",
+			"labels": {"country": "de", "example.org/project": "alpha"}
+		}`
+			req := httptest.NewRequest("POST", "/subordinates", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, bodyBytes := doRequest(t, app, req)
+
+			requireStatus(t, resp, bodyBytes, http.StatusCreated)
+
+			saved, err := backends.Subordinates.Get("https://labeled.example.org")
+			if err != nil || saved == nil {
+				t.Fatalf("Failed to find saved subordinate in DB")
+			}
+			labels := saved.Labels.Map()
+			if len(labels) != 2 || labels["country"] != "de" || labels["example.org/project"] != "alpha" {
+				t.Errorf("Expected labels to be stored, got %v", labels)
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidLabels", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateBaseApp(t)
+
+			body := `{"entity_id": "https://sub.example.org", "status": "pending", "labels": {"not valid": "x"}}`
+			req := httptest.NewRequest("POST", "/subordinates", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, respBody := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, respBody, http.StatusBadRequest, "invalid_request")
+		},
+	)
+
 	t.Run(
 		"MissingEntityID", func(t *testing.T) {
 			t.Parallel()
@@ -642,10 +883,13 @@ func TestDeleteSubordinateByID(t *testing.T) {
 				t.Errorf("Expected subordinate to be deleted, but it still exists")
 			}
 
-			// Verify events were deleted
+			// Verify the history was replaced by a single deleted event
 			events, _, err := backends.SubordinateEvents.GetBySubordinateID(saved.ID, model.EventQueryOpts{})
-			if err == nil && len(events) > 0 {
-				t.Errorf("Expected subordinate events to be deleted, but found %d events", len(events))
+			if err != nil {
+				t.Fatalf("Failed to get subordinate events: %v", err)
+			}
+			if len(events) != 1 || events[0].Type != model.EventTypeDeleted {
+				t.Errorf("Expected only a deleted event, but found %+v", events)
 			}
 
 			// Verify JWKS was permanently deleted (to allow re-registration without FK constraints)
@@ -1041,6 +1285,76 @@ func TestGetSubordinateHistory(t *testing.T) {
 		},
 	)
 
+	t.Run(
+		"Success/CursorAndSearch", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBaseApp(t)
+
+			backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://history-cursor.example.org",
+						Status:   model.StatusPending,
+					},
+				},
+			)
+			saved, err := backends.Subordinates.Get("https://history-cursor.example.org")
+			if err != nil {
+				t.Fatalf("Failed to get subordinate: %v", err)
+			}
+			for _, msg := range []string{"first", "second", "third", "Key rotated"} {
+				backends.SubordinateEvents.Add(
+					model.SubordinateEvent{
+						SubordinateID: saved.ID,
+						Type:          model.EventTypeUpdated,
+						Message:       &msg,
+					},
+				)
+			}
+
+			collect := func(query string) []string {
+				var messages []string
+				cursor := ""
+				for range 10 {
+					target := fmt.Sprintf("/subordinates/%d/history?limit=3%s", saved.ID, query)
+					if cursor != "" {
+						target += "&cursor=" + url.QueryEscape(cursor)
+					}
+					resp, body := doRequest(t, app, httptest.NewRequest("GET", target, http.NoBody))
+					requireStatus(t, resp, body, http.StatusOK)
+					var result struct {
+						Events     []eventResponse `json:"events"`
+						Pagination struct {
+							NextCursor string `json:"next_cursor"`
+						} `json:"pagination"`
+					}
+					if err := json.Unmarshal(body, &result); err != nil {
+						t.Fatalf("Failed to parse response: %v", err)
+					}
+					for _, e := range result.Events {
+						messages = append(messages, *e.Message)
+					}
+					if cursor = result.Pagination.NextCursor; cursor == "" {
+						return messages
+					}
+				}
+				t.Fatal("Paging did not terminate")
+				return nil
+			}
+
+			// Newest events first by default
+			if got, want := collect(""), "Key rotated,third,second,first"; strings.Join(got, ",") != want {
+				t.Errorf("Expected %s, got %v", want, got)
+			}
+			if got, want := collect("&order=asc"), "first,second,third,Key rotated"; strings.Join(got, ",") != want {
+				t.Errorf("Expected %s, got %v", want, got)
+			}
+			if got, want := collect("&search=ROTATED"), "Key rotated"; strings.Join(got, ",") != want {
+				t.Errorf("Expected %s, got %v", want, got)
+			}
+		},
+	)
+
 	t.Run(
 		"NotFound", func(t *testing.T) {
 			t.Parallel()
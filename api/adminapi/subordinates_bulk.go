@@ -0,0 +1,178 @@
+package adminapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// subordinatesBulkHandlers groups handlers for bulk operations on all
+// subordinates matching a label selector.
+type subordinatesBulkHandlers struct {
+	storages model.Backends
+}
+
+// bulkResult is the response body of bulk operations.
+type bulkResult struct {
+	Matched      int      `json:"matched"`
+	Subordinates []string `json:"subordinates"`
+}
+
+// parseRequiredLabelSelector parses the label_selector query parameter.
+// Bulk operations require a non-empty selector so that a missing parameter
+// cannot accidentally affect all subordinates.
+// Returns (selector, true) on success, or (nil, false) if an error response was written.
+func parseRequiredLabelSelector(c *fiber.Ctx) (model.LabelSelector, bool) {
+	raw := c.Query("label_selector")
+	if strings.TrimSpace(raw) == "" {
+		_ = writeBadRequest(c, "label_selector is required for bulk operations")
+		return nil, false
+	}
+	selector, err := model.ParseLabelSelector(raw)
+	if err != nil {
+		_ = writeBadRequest(c, err.Error())
+		return nil, false
+	}
+	return selector, true
+}
+
+// forEachMatching runs fn for every subordinate matching the selector within a single transaction.
+// The returned bulkResult lists the entity ids of all matched subordinates.
+func (h *subordinatesBulkHandlers) forEachMatching(
+	selector model.LabelSelector, fn func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error,
+) (*bulkResult, error) {
+	result := &bulkResult{Subordinates: []string{}}
+	err := h.storages.InTransaction(
+		func(tx *model.Backends) error {
+			matched, err := tx.Subordinates.Find(model.SubordinateFilter{Labels: selector})
+			if err != nil {
+				return err
+			}
+			for _, m := range matched {
+				info, err := getSubordinateByDBID(tx.Subordinates, strconv.FormatUint(uint64(m.ID), 10))
+				if err != nil {
+					return err
+				}
+				if err = fn(tx, info); err != nil {
+					return err
+				}
+				result.Subordinates = append(result.Subordinates, info.EntityID)
+			}
+			result.Matched = len(result.Subordinates)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (h *subordinatesBulkHandlers) updateStatus(c *fiber.Ctx) error {
+	selector, ok := parseRequiredLabelSelector(c)
+	if !ok {
+		return nil
+	}
+	statusStr := strings.TrimSpace(string(c.Body()))
+	if statusStr == "" {
+		return writeBadRequest(c, "status is required")
+	}
+	status, err := model.ParseStatus(statusStr)
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+
+	result, err := h.forEachMatching(
+		selector, func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error {
+			return tx.UpdateSubordinateStatus(
+				info, status,
+				model.WithMessage(fmt.Sprintf("status changed from %s to %s (bulk: %s)", info.Status, status, selector)),
+				model.WithActor(GetActor(c)),
+			)
+		},
+	)
+	if err != nil {
+		return handleBulkError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *subordinatesBulkHandlers) updateMetadataPolicies(c *fiber.Ctx) error {
+	selector, ok := parseRequiredLabelSelector(c)
+	if !ok {
+		return nil
+	}
+	var body oidfed.MetadataPolicies
+	if err := c.BodyParser(&body); err != nil {
+		return writeBadBody(c)
+	}
+
+	result, err := h.forEachMatching(
+		selector, func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error {
+			policy := body
+			info.MetadataPolicy = &policy
+			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
+				return err
+			}
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated,
+				model.WithMessage(fmt.Sprintf("bulk: %s", selector)), model.WithActor(GetActor(c)),
+			)
+		},
+	)
+	if err != nil {
+		return handleBulkError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *subordinatesBulkHandlers) delete(c *fiber.Ctx) error {
+	selector, ok := parseRequiredLabelSelector(c)
+	if !ok {
+		return nil
+	}
+
+	result, err := h.forEachMatching(
+		selector, func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error {
+			return tx.DeleteSubordinate(
+				&info.BasicSubordinateInfo,
+				model.WithMessage(fmt.Sprintf("subordinate deleted: %s (bulk: %s)", info.EntityID, selector)),
+				model.WithActor(GetActor(c)),
+			)
+		},
+	)
+	if err != nil {
+		return handleBulkError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// handleBulkError maps validation errors to 400 responses and
+// delegates all other errors to handleTxError.
+func handleBulkError(c *fiber.Ctx, err error) error {
+	var validationErr model.ValidationError
+	if errors.As(err, &validationErr) {
+		return writeBadRequest(c, err.Error())
+	}
+	return handleTxError(c, err)
+}
+
+// registerSubordinatesBulk registers bulk endpoints for subordinates.
+// These must be registered before the subordinate-specific routes, so that
+// "bulk" is not interpreted as a subordinateID.
+func registerSubordinatesBulk(r fiber.Router, storages model.Backends) {
+	g := r.Group("/subordinates/bulk")
+	withCacheWipe := g.Use(subordinateStatementsCacheInvalidationMiddleware)
+
+	h := &subordinatesBulkHandlers{storages: storages}
+
+	withCacheWipe.Put("/status", h.updateStatus)
+	withCacheWipe.Put("/metadata-policies", h.updateMetadataPolicies)
+	withCacheWipe.Delete("/", h.delete)
+}
@@ -0,0 +1,242 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// setupSubordinateBulkApp creates a Fiber app with bulk and base subordinate endpoints
+// and three subordinates with labels.
+func setupSubordinateBulkApp(t *testing.T) (*fiber.App, testBackends) {
+	t.Helper()
+	store := newSubordinateTestStorage(t)
+	backends := store.Backends()
+
+	app := fiber.New()
+	app.Use(actorMiddleware(ActorConfig{Source: ActorSourceHeader}))
+	registerSubordinatesBulk(app, backends)
+	registerSubordinatesBase(app, backends)
+
+	set := jwk.NewSet()
+	_ = set.AddKey(createTestKey("bulk-key"))
+	subs := []struct {
+		entityID string
+		labels   map[string]string
+	}{
+		{entityID: "https://a.example.org", labels: map[string]string{"project": "alpha", "country": "de"}},
+		{entityID: "https://b.example.org", labels: map[string]string{"project": "alpha", "country": "at"}},
+		{entityID: "https://c.example.org", labels: map[string]string{"project": "beta"}},
+	}
+	for _, s := range subs {
+		if err := backends.Subordinates.Add(
+			model.ExtendedSubordinateInfo{
+				BasicSubordinateInfo: model.BasicSubordinateInfo{
+					EntityID: s.entityID,
+					Status:   model.StatusPending,
+					Labels:   model.NewSubordinateLabels(s.labels),
+				},
+				JWKS: model.JWKS{Keys: jwx.JWKS{Set: set}},
+			},
+		); err != nil {
+			t.Fatalf("Failed to add subordinate: %v", err)
+		}
+	}
+	return app, testBackends{
+		Backends: backends,
+		db:       store.DB(),
+	}
+}
+
+func TestBulkUpdateSubordinateStatus(t *testing.T) {
+	t.Parallel()
+	t.Run(
+		"Success", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBulkApp(t)
+
+			req := httptest.NewRequest(
+				"PUT", "/subordinates/bulk/status?label_selector="+url.QueryEscape("project=alpha"),
+				strings.NewReader("active"),
+			)
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			var result bulkResult
+			if err := json.Unmarshal(body, &result); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if result.Matched != 2 {
+				t.Errorf("Expected 2 matched subordinates, got %d", result.Matched)
+			}
+
+			for entityID, want := range map[string]model.Status{
+				"https://a.example.org": model.StatusActive,
+				"https://b.example.org": model.StatusActive,
+				"https://c.example.org": model.StatusPending,
+			} {
+				info, err := backends.Subordinates.Get(entityID)
+				if err != nil || info == nil {
+					t.Fatalf("Failed to get subordinate %s: %v", entityID, err)
+				}
+				if info.Status != want {
+					t.Errorf("Expected %s to have status %s, got %s", entityID, want, info.Status)
+				}
+				if want != model.StatusActive {
+					continue
+				}
+				events, _, err := backends.SubordinateEvents.GetBySubordinateID(info.ID, model.EventQueryOpts{})
+				if err != nil {
+					t.Fatalf("Failed to query events: %v", err)
+				}
+				if len(events) != 1 || events[0].Type != model.EventTypeStatusUpdated {
+					t.Errorf("Expected 1 status_updated event for %s, got %+v", entityID, events)
+				}
+			}
+		},
+	)
+
+	t.Run(
+		"ActiveRequiresKeys", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateBulkApp(t)
+			if err := backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://nokeys.example.org",
+						Status:   model.StatusPending,
+						Labels:   model.NewSubordinateLabels(map[string]string{"project": "beta"}),
+					},
+				},
+			); err != nil {
+				t.Fatalf("Failed to add subordinate: %v", err)
+			}
+
+			req := httptest.NewRequest(
+				"PUT", "/subordinates/bulk/status?label_selector="+url.QueryEscape("project=beta"),
+				strings.NewReader("active"),
+			)
+			resp, body := doRequest(t, app, req)
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+
+			// The whole bulk operation is rolled back
+			info, err := backends.Subordinates.Get("https://c.example.org")
+			if err != nil || info == nil {
+				t.Fatalf("Failed to get subordinate: %v", err)
+			}
+			if info.Status != model.StatusPending {
+				t.Errorf("Expected status to stay pending, got %s", info.Status)
+			}
+		},
+	)
+
+	t.Run(
+		"MissingSelector", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateBulkApp(t)
+
+			req := httptest.NewRequest("PUT", "/subordinates/bulk/status", strings.NewReader("blocked"))
+			resp, body := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+
+	t.Run(
+		"InvalidStatus", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateBulkApp(t)
+
+			req := httptest.NewRequest(
+				"PUT", "/subordinates/bulk/status?label_selector=project", strings.NewReader("unknown"),
+			)
+			resp, body := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+}
+
+func TestBulkUpdateSubordinateMetadataPolicies(t *testing.T) {
+	t.Parallel()
+	app, backends := setupSubordinateBulkApp(t)
+
+	policy := `{"openid_relying_party":{"contacts":{"add":["ops@example.org"]}}}`
+	req := httptest.NewRequest(
+		"PUT", "/subordinates/bulk/metadata-policies?label_selector="+url.QueryEscape("country in (de,at)"),
+		strings.NewReader(policy),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	resp, body := doRequest(t, app, req)
+	requireStatus(t, resp, body, http.StatusOK)
+
+	for entityID, want := range map[string]bool{
+		"https://a.example.org": true,
+		"https://b.example.org": true,
+		"https://c.example.org": false,
+	} {
+		info, err := backends.Subordinates.Get(entityID)
+		if err != nil || info == nil {
+			t.Fatalf("Failed to get subordinate %s: %v", entityID, err)
+		}
+		hasPolicy := info.MetadataPolicy != nil && info.MetadataPolicy.RelyingParty != nil
+		if hasPolicy != want {
+			t.Errorf("Expected policy set for %s to be %v, got %v", entityID, want, hasPolicy)
+		}
+	}
+}
+
+func TestBulkDeleteSubordinates(t *testing.T) {
+	t.Parallel()
+	app, backends := setupSubordinateBulkApp(t)
+	deletedInfo, err := backends.Subordinates.Get("https://a.example.org")
+	if err != nil || deletedInfo == nil {
+		t.Fatalf("Failed to get subordinate: %v", err)
+	}
+
+	req := httptest.NewRequest(
+		"DELETE", "/subordinates/bulk?label_selector="+url.QueryEscape("project=alpha,country=de"), http.NoBody,
+	)
+	req.Header.Set("X-Actor", "alice")
+	resp, body := doRequest(t, app, req)
+	requireStatus(t, resp, body, http.StatusOK)
+
+	var result bulkResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if result.Matched != 1 || result.Subordinates[0] != "https://a.example.org" {
+		t.Errorf("Expected only https://a.example.org to be deleted, got %+v", result)
+	}
+
+	all, err := backends.Subordinates.GetAll()
+	if err != nil {
+		t.Fatalf("Failed to list subordinates: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 remaining subordinates, got %d", len(all))
+	}
+
+	events, _, err := backends.SubordinateEvents.GetBySubordinateID(deletedInfo.ID, model.EventQueryOpts{})
+	if err != nil {
+		t.Fatalf("Failed to query events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != model.EventTypeDeleted {
+		t.Fatalf("Expected 1 deleted event, got %+v", events)
+	}
+	if events[0].Actor == nil || *events[0].Actor != "alice" {
+		t.Errorf("Expected actor alice, got %v", events[0].Actor)
+	}
+	if events[0].Message == nil || !strings.Contains(*events[0].Message, "bulk: ") {
+		t.Errorf("Expected bulk message, got %v", events[0].Message)
+	}
+}
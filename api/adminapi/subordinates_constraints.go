@@ -69,7 +69,7 @@ func (h *subordinateConstraintsHandlers) putAll(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -103,8 +103,8 @@ func (h *subordinateConstraintsHandlers) postAll(c *fiber.Ctx) error {
 				return err
 			}
 			result = info.Constraints
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, WithMessage("copied from general"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, model.WithMessage("copied from general"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -127,7 +127,7 @@ func (h *subordinateConstraintsHandlers) deleteAll(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -173,8 +173,8 @@ func (h *subordinateConstraintsHandlers) putMaxPathLength(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, WithMessage("max_path_length"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, model.WithMessage("max_path_length"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -198,8 +198,8 @@ func (h *subordinateConstraintsHandlers) deleteMaxPathLength(c *fiber.Ctx) error
 				if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 					return err
 				}
-				return RecordEvent(
-					tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, WithMessage("max_path_length"), WithActor(GetActor(c)),
+				return model.RecordEvent(
+					tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, model.WithMessage("max_path_length"), model.WithActor(GetActor(c)),
 				)
 			}
 			return nil
@@ -242,8 +242,8 @@ func (h *subordinateConstraintsHandlers) putNamingConstraints(c *fiber.Ctx) erro
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, WithMessage("naming_constraints"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, model.WithMessage("naming_constraints"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -267,8 +267,8 @@ func (h *subordinateConstraintsHandlers) deleteNamingConstraints(c *fiber.Ctx) e
 				if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 					return err
 				}
-				return RecordEvent(
-					tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, WithMessage("naming_constraints"), WithActor(GetActor(c)),
+				return model.RecordEvent(
+					tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, model.WithMessage("naming_constraints"), model.WithActor(GetActor(c)),
 				)
 			}
 			return nil
@@ -313,8 +313,8 @@ func (h *subordinateConstraintsHandlers) putAllowedEntityTypes(c *fiber.Ctx) err
 				return err
 			}
 			result = info.Constraints.AllowedEntityTypes
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, WithMessage("allowed_entity_types"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, model.WithMessage("allowed_entity_types"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -352,8 +352,8 @@ func (h *subordinateConstraintsHandlers) postAllowedEntityTypes(c *fiber.Ctx) er
 				return err
 			}
 			result = info.Constraints.AllowedEntityTypes
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, WithMessage("allowed_entity_types"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsUpdated, model.WithMessage("allowed_entity_types"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -396,8 +396,8 @@ func (h *subordinateConstraintsHandlers) deleteAllowedEntityType(c *fiber.Ctx) e
 				return err
 			}
 			result = info.Constraints.AllowedEntityTypes
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, WithMessage("allowed_entity_types"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypeConstraintsDeleted, model.WithMessage("allowed_entity_types"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -36,6 +36,11 @@ func writeConflict(c *fiber.Ctx, msg string) error {
 	return c.Status(fiber.StatusConflict).JSON(oidfed.ErrorInvalidRequest(msg))
 }
 
+// writePreconditionFailed returns a 412 JSON error response.
+func writePreconditionFailed(c *fiber.Ctx, msg string) error {
+	return c.Status(fiber.StatusPreconditionFailed).JSON(oidfed.ErrorInvalidRequest(msg))
+}
+
 // handleTxError handles errors from transactional operations.
 // It maps NotFoundError to 404 responses and other errors to 500 responses.
 func handleTxError(c *fiber.Ctx, err error) error {
@@ -206,14 +211,6 @@ func deleteEntityMetadata(md *oidfed.Metadata, et string) {
 	delete(md.Extra, et)
 }
 
-// subordinateHasKeys checks if a subordinate has any JWKS keys defined.
-func subordinateHasKeys(info *model.ExtendedSubordinateInfo) bool {
-	if info == nil {
-		return false
-	}
-	return info.JWKS.Keys.Set != nil && info.JWKS.Keys.Len() > 0
-}
-
 // jwksHasKeys checks if a JWKS has any keys defined.
 func jwksHasKeys(jwks *model.JWKS) bool {
 	if jwks == nil {
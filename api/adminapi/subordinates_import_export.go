@@ -0,0 +1,116 @@
+package adminapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// subordinatesImportExportHandlers groups handlers for bulk import and export
+// of subordinates.
+type subordinatesImportExportHandlers struct {
+	storages model.Backends
+}
+
+type exportSubordinatesRequest struct {
+	Format        string   `query:"format"`
+	EntityType    []string `query:"entity_type"`
+	LabelSelector string   `query:"label_selector"`
+}
+
+func (h *subordinatesImportExportHandlers) export(c *fiber.Ctx) error {
+	var req exportSubordinatesRequest
+	if err := c.QueryParser(&req); err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+	var ndjson bool
+	switch req.Format {
+	case "", "json":
+	case "ndjson":
+		ndjson = true
+	default:
+		return writeBadRequest(c, "invalid format (valid values: json, ndjson)")
+	}
+	filter := model.SubordinateFilter{EntityTypes: req.EntityType}
+	if s := c.Query("status"); s != "" {
+		st, err := model.ParseStatus(s)
+		if err != nil {
+			return writeBadRequest(c, fmt.Sprintf("invalid status: %s", err.Error()))
+		}
+		filter.Status = &st
+	}
+	selector, err := model.ParseLabelSelector(req.LabelSelector)
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+	filter.Labels = selector
+
+	records, err := storage.ExportSubordinates(h.storages.Subordinates, filter)
+	if err != nil {
+		return writeServerError(c, err)
+	}
+	var buf bytes.Buffer
+	if err = storage.EncodeSubordinateExports(&buf, records, ndjson); err != nil {
+		return writeServerError(c, err)
+	}
+	if ndjson {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	return c.Send(buf.Bytes())
+}
+
+func (h *subordinatesImportExportHandlers) importSubordinates(c *fiber.Ctx) error {
+	mode, err := storage.ParseSubordinateImportMode(c.Query("mode"))
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+	records, err := storage.DecodeSubordinateExports(c.Body())
+	if err != nil {
+		return handleImportError(c, err)
+	}
+	report, err := storage.ImportSubordinates(
+		h.storages, records, storage.SubordinateImportOptions{
+			Mode:   mode,
+			DryRun: c.QueryBool("dry_run"),
+			Actor:  GetActor(c),
+		},
+	)
+	if err != nil {
+		return handleImportError(c, err)
+	}
+	return c.JSON(report)
+}
+
+// handleImportError maps import errors to the matching error responses.
+func handleImportError(c *fiber.Ctx, err error) error {
+	var validation model.ValidationError
+	if errors.As(err, &validation) {
+		return writeBadRequest(c, err.Error())
+	}
+	var alreadyExists model.AlreadyExistsError
+	if errors.As(err, &alreadyExists) {
+		return writeConflict(c, err.Error())
+	}
+	return writeServerError(c, err)
+}
+
+// registerSubordinatesImportExport registers the bulk import and export
+// endpoints for subordinates. These must be registered before the
+// subordinate-specific routes, so that "export" is not interpreted as a
+// subordinateID.
+func registerSubordinatesImportExport(r fiber.Router, storages model.Backends) {
+	g := r.Group("/subordinates")
+	withCacheWipe := g.Use(subordinateStatementsCacheInvalidationMiddleware)
+
+	h := &subordinatesImportExportHandlers{storages: storages}
+
+	g.Get("/export", h.export)
+	withCacheWipe.Post("/import", h.importSubordinates)
+}
@@ -0,0 +1,318 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// setupSubordinateImportExportApp creates a Fiber app with import/export endpoints
+// and one existing subordinate with metadata policy and additional claims.
+func setupSubordinateImportExportApp(t *testing.T) (*fiber.App, testBackends) {
+	t.Helper()
+	store := newSubordinateTestStorage(t)
+	backends := store.Backends()
+
+	app := fiber.New()
+	registerSubordinatesImportExport(app, backends)
+
+	set := jwk.NewSet()
+	_ = set.AddKey(createTestKey("existing-key"))
+	if err := backends.Subordinates.Add(
+		model.ExtendedSubordinateInfo{
+			BasicSubordinateInfo: model.BasicSubordinateInfo{
+				EntityID:               "https://existing.example.org",
+				Status:                 model.StatusActive,
+				Description:            "existing",
+				SubordinateEntityTypes: []model.SubordinateEntityType{{EntityType: "openid_relying_party"}},
+				Labels:                 model.NewSubordinateLabels(map[string]string{"project": "alpha"}),
+			},
+			JWKS: model.JWKS{Keys: jwx.JWKS{Set: set}},
+			MetadataPolicy: &oidfed.MetadataPolicies{
+				RelyingParty: oidfed.MetadataPolicy{
+					"contacts": oidfed.MetadataPolicyEntry{"add": []any{"ops@example.org"}},
+				},
+			},
+			SubordinateAdditionalClaims: []model.SubordinateAdditionalClaim{
+				{
+					Claim: "custom",
+					Value: "value",
+					Crit:  true,
+				},
+			},
+		},
+	); err != nil {
+		t.Fatalf("Failed to add subordinate: %v", err)
+	}
+	return app, testBackends{
+		Backends: backends,
+		db:       store.DB(),
+	}
+}
+
+const testImportJWKS = `{"keys":[{"kty":"EC","crv":"P-256","kid":"imported","x":"f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU","y":"x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"}]}`
+
+func TestExportSubordinates(t *testing.T) {
+	t.Parallel()
+	t.Run(
+		"JSON", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("GET", "/subordinates/export", http.NoBody)
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			var records []model.SubordinateExport
+			if err := json.Unmarshal(body, &records); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			r := records[0]
+			if r.EntityID != "https://existing.example.org" || r.Status != model.StatusActive {
+				t.Errorf("Unexpected record: %+v", r)
+			}
+			if !r.HasKeys() {
+				t.Error("Expected exported record to contain keys")
+			}
+			if r.MetadataPolicy == nil || r.MetadataPolicy.RelyingParty == nil {
+				t.Error("Expected exported record to contain the metadata policy")
+			}
+			if len(r.AdditionalClaims) != 1 || !r.AdditionalClaims[0].Crit {
+				t.Errorf("Expected one critical additional claim, got %+v", r.AdditionalClaims)
+			}
+			if r.Labels["project"] != "alpha" {
+				t.Errorf("Expected label project=alpha, got %+v", r.Labels)
+			}
+		},
+	)
+
+	t.Run(
+		"NDJSON", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("GET", "/subordinates/export?format=ndjson", http.NoBody)
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("Expected NDJSON content type, got %q", ct)
+			}
+			if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 1 {
+				t.Errorf("Expected 1 line, got %d", len(lines))
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidFormat", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("GET", "/subordinates/export?format=xml", http.NoBody)
+			resp, body := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+}
+
+func TestImportSubordinates(t *testing.T) {
+	t.Parallel()
+
+	ndjson := `{"entity_id":"https://new.example.org","status":"pending","labels":{"project":"beta"},"additional_claims":[{"claim":"a","value":1,"crit":false}]}
+{"entity_id":"https://existing.example.org","status":"active","description":"replaced","jwks":` + testImportJWKS + `,"registered_entity_types":["openid_provider"]}
+`
+
+	t.Run(
+		"FailOnConflict", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("POST", "/subordinates/import", strings.NewReader(ndjson))
+			resp, body := doRequest(t, app, req)
+			assertStatus(t, resp, body, http.StatusConflict)
+
+			info, err := backends.Subordinates.Get("https://new.example.org")
+			if err != nil {
+				t.Fatalf("Failed to get subordinate: %v", err)
+			}
+			if info != nil {
+				t.Error("Expected the import to be rolled back")
+			}
+		},
+	)
+
+	t.Run(
+		"Skip", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("POST", "/subordinates/import?mode=skip", strings.NewReader(ndjson))
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			var report storage.SubordinateImportReport
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if len(report.Created) != 1 || len(report.Skipped) != 1 || len(report.Updated) != 0 {
+				t.Errorf("Unexpected report: %+v", report)
+			}
+
+			info, err := backends.Subordinates.Get("https://new.example.org")
+			if err != nil || info == nil {
+				t.Fatalf("Failed to get imported subordinate: %v", err)
+			}
+			if info.Status != model.StatusPending || info.Labels.Map()["project"] != "beta" {
+				t.Errorf("Unexpected imported subordinate: %+v", info.BasicSubordinateInfo)
+			}
+			events, _, err := backends.SubordinateEvents.GetBySubordinateID(info.ID, model.EventQueryOpts{})
+			if err != nil {
+				t.Fatalf("Failed to query events: %v", err)
+			}
+			if len(events) != 1 || events[0].Type != model.EventTypeCreated {
+				t.Errorf("Expected 1 created event, got %+v", events)
+			}
+
+			existing, err := backends.Subordinates.Get("https://existing.example.org")
+			if err != nil || existing == nil {
+				t.Fatalf("Failed to get existing subordinate: %v", err)
+			}
+			if existing.Description != "existing" {
+				t.Errorf("Expected existing subordinate to be unchanged, got description %q", existing.Description)
+			}
+		},
+	)
+
+	t.Run(
+		"Upsert", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("POST", "/subordinates/import?mode=upsert", strings.NewReader(ndjson))
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			existing, err := backends.Subordinates.FindExtended(model.SubordinateFilter{})
+			if err != nil {
+				t.Fatalf("Failed to list subordinates: %v", err)
+			}
+			if len(existing) != 2 {
+				t.Fatalf("Expected 2 subordinates, got %d", len(existing))
+			}
+			replaced := existing[0]
+			if replaced.EntityID != "https://existing.example.org" {
+				replaced = existing[1]
+			}
+			if replaced.Description != "replaced" {
+				t.Errorf("Expected description to be replaced, got %q", replaced.Description)
+			}
+			if len(replaced.SubordinateEntityTypes) != 1 ||
+				replaced.SubordinateEntityTypes[0].EntityType != "openid_provider" {
+				t.Errorf("Expected entity types to be replaced, got %+v", replaced.SubordinateEntityTypes)
+			}
+			if replaced.MetadataPolicy != nil || len(replaced.SubordinateAdditionalClaims) != 0 ||
+				len(replaced.Labels) != 0 {
+				t.Error("Expected metadata policy, additional claims, and labels to be removed")
+			}
+			if _, ok := replaced.JWKS.Keys.LookupKeyID("imported"); !ok {
+				t.Error("Expected JWKS to be replaced")
+			}
+		},
+	)
+
+	t.Run(
+		"DryRun", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest(
+				"POST", "/subordinates/import?mode=upsert&dry_run=true", strings.NewReader(ndjson),
+			)
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			var report storage.SubordinateImportReport
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if !report.DryRun || len(report.Created) != 1 || len(report.Updated) != 1 {
+				t.Errorf("Unexpected report: %+v", report)
+			}
+			all, err := backends.Subordinates.GetAll()
+			if err != nil {
+				t.Fatalf("Failed to list subordinates: %v", err)
+			}
+			if len(all) != 1 {
+				t.Errorf("Expected dry run to not change anything, got %d subordinates", len(all))
+			}
+		},
+	)
+
+	t.Run(
+		"JSONArrayRoundTrip", func(t *testing.T) {
+			t.Parallel()
+			app, backends := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("GET", "/subordinates/export", http.NoBody)
+			resp, exported := doRequest(t, app, req)
+			requireStatus(t, resp, exported, http.StatusOK)
+
+			if err := backends.Subordinates.Delete("https://existing.example.org"); err != nil {
+				t.Fatalf("Failed to delete subordinate: %v", err)
+			}
+
+			req = httptest.NewRequest("POST", "/subordinates/import", strings.NewReader(string(exported)))
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusOK)
+
+			infos, err := backends.Subordinates.FindExtended(model.SubordinateFilter{})
+			if err != nil || len(infos) != 1 {
+				t.Fatalf("Expected 1 subordinate after import, got %d (%v)", len(infos), err)
+			}
+			if infos[0].MetadataPolicy == nil || len(infos[0].SubordinateAdditionalClaims) != 1 {
+				t.Errorf("Expected re-imported subordinate to be complete, got %+v", infos[0])
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidRecord", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest(
+				"POST", "/subordinates/import", strings.NewReader(`[{"entity_id":"https://x.example.org","status":"active"}]`),
+			)
+			resp, body := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+
+	t.Run(
+		"InvalidMode", func(t *testing.T) {
+			t.Parallel()
+			app, _ := setupSubordinateImportExportApp(t)
+
+			req := httptest.NewRequest("POST", "/subordinates/import?mode=merge", strings.NewReader(ndjson))
+			resp, body := doRequest(t, app, req)
+
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+}
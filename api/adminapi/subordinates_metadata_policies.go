@@ -321,7 +321,7 @@ func (h *subordinatePolicyHandlers) putAll(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -355,8 +355,8 @@ func (h *subordinatePolicyHandlers) postAll(c *fiber.Ctx) error {
 				return err
 			}
 			result = info.MetadataPolicy
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithMessage("copied from general"), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithMessage("copied from general"), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -382,7 +382,7 @@ func (h *subordinatePolicyHandlers) deleteAll(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -428,8 +428,8 @@ func (h *subordinatePolicyHandlers) putEntityType(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithMessage("entity type: "+et), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithMessage("entity type: "+et), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -469,8 +469,8 @@ func (h *subordinatePolicyHandlers) postEntityType(c *fiber.Ctx) error {
 				return err
 			}
 			result = existing
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithMessage("entity type: "+et), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithMessage("entity type: "+et), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -497,8 +497,8 @@ func (h *subordinatePolicyHandlers) deleteEntityType(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, WithMessage("entity type: "+et), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, model.WithMessage("entity type: "+et), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -551,7 +551,7 @@ func (h *subordinatePolicyHandlers) putClaim(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithMessage(et+"."+claim), WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithMessage(et+"."+claim), model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -596,7 +596,7 @@ func (h *subordinatePolicyHandlers) postClaim(c *fiber.Ctx) error {
 				return err
 			}
 			result = existing
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithMessage(et+"."+claim), WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithMessage(et+"."+claim), model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -631,7 +631,7 @@ func (h *subordinatePolicyHandlers) deleteClaim(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, WithMessage(et+"."+claim), WithActor(GetActor(c)))
+			return model.RecordEvent(tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, model.WithMessage(et+"."+claim), model.WithActor(GetActor(c)))
 		},
 	)
 	if err != nil {
@@ -706,8 +706,8 @@ func (h *subordinatePolicyHandlers) putOperator(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, WithMessage(et+"."+claim+"."+string(op)), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyUpdated, model.WithMessage(et+"."+claim+"."+string(op)), model.WithActor(GetActor(c)),
 			)
 		},
 	)
@@ -755,8 +755,8 @@ func (h *subordinatePolicyHandlers) deleteOperator(c *fiber.Ctx) error {
 			if err := tx.Subordinates.Update(info.EntityID, *info); err != nil {
 				return err
 			}
-			return RecordEvent(
-				tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, WithMessage(et+"."+claim+"."+string(op)), WithActor(GetActor(c)),
+			return model.RecordEvent(
+				tx.SubordinateEvents, info.ID, model.EventTypePolicyDeleted, model.WithMessage(et+"."+claim+"."+string(op)), model.WithActor(GetActor(c)),
 			)
 		},
 	)
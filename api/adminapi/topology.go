@@ -0,0 +1,492 @@
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+	"github.com/go-oidfed/lighthouse/tracing"
+)
+
+// DefaultTopologyCrawlConcurrency is the default number of concurrent requests
+// of a topology crawl.
+const DefaultTopologyCrawlConcurrency = 8
+
+// maxTopologyCrawlConcurrency limits the number of concurrent requests of a
+// topology crawl.
+const maxTopologyCrawlConcurrency = 64
+
+// TopologyNode is an entity in the crawled federation topology.
+type TopologyNode struct {
+	EntityID    string   `json:"entity_id"`
+	EntityTypes []string `json:"entity_types,omitempty"`
+	TrustMarks  []string `json:"trust_marks,omitempty"`
+	// ConfigurationExpiresAt is the expiration of the entity's entity
+	// configuration.
+	ConfigurationExpiresAt *time.Time `json:"configuration_expires_at,omitempty"`
+	// StatementExpiresAt is the expiration of the subordinate statement
+	// issued about the entity by its superior in the tree.
+	StatementExpiresAt *time.Time `json:"statement_expires_at,omitempty"`
+	// Errors holds the validation errors found for the entity.
+	Errors []string `json:"errors,omitempty"`
+	// Revisited is set if the entity was already reached through another
+	// superior; its subordinates are only listed at the first occurrence.
+	Revisited bool `json:"revisited,omitempty"`
+	// Truncated is set if the entity has subordinates that were not crawled
+	// because the maximum depth was reached.
+	Truncated    bool            `json:"truncated,omitempty"`
+	Subordinates []*TopologyNode `json:"subordinates,omitempty"`
+}
+
+// Walk calls fn for the node and all its descendants.
+func (n *TopologyNode) Walk(fn func(node *TopologyNode)) {
+	fn(n)
+	for _, s := range n.Subordinates {
+		s.Walk(fn)
+	}
+}
+
+// TopologyCrawlOptions configures CrawlTopology.
+type TopologyCrawlOptions struct {
+	// Concurrency is the maximum number of concurrent requests; if zero,
+	// DefaultTopologyCrawlConcurrency is used.
+	Concurrency int `json:"concurrency,omitempty"`
+	// MaxDepth limits the depth of the crawl; zero means unlimited.
+	MaxDepth int `json:"max_depth,omitempty"`
+	// HTTPClient is used to query list endpoints; if nil, a client with a
+	// timeout of 30 seconds is used.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// Validate checks the options.
+func (o TopologyCrawlOptions) Validate() error {
+	if o.Concurrency < 0 || o.Concurrency > maxTopologyCrawlConcurrency {
+		return model.ValidationErrorFmt(
+			"concurrency must be between 1 and %d, or 0 for the default", maxTopologyCrawlConcurrency,
+		)
+	}
+	if o.MaxDepth < 0 {
+		return model.ValidationError("max_depth must not be negative")
+	}
+	return nil
+}
+
+type topologyCrawler struct {
+	ctx      context.Context
+	opts     TopologyCrawlOptions
+	client   *http.Client
+	sem      chan struct{}
+	anchor   *oidfed.EntityStatement
+	mu       sync.Mutex
+	expanded map[string]bool
+}
+
+// CrawlTopology crawls the federation below the passed entity. Starting at the
+// entity's configuration, it recursively queries the federation_list_endpoint
+// of each entity and fetches the subordinate statements and entity
+// configurations of all subordinates. Problems found along the way are
+// recorded as errors on the affected nodes; the crawl itself does not fail.
+func CrawlTopology(ctx context.Context, entityID string, opts TopologyCrawlOptions) (*TopologyNode, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = DefaultTopologyCrawlConcurrency
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = tracing.NewClient(&http.Client{Timeout: 30 * time.Second})
+	}
+	ctx, span := tracing.Start(ctx, "adminapi.CrawlTopology", attribute.String("oidfed.entity_id", entityID))
+	defer span.End()
+	c := &topologyCrawler{
+		ctx:      ctx,
+		opts:     opts,
+		client:   client,
+		sem:      make(chan struct{}, opts.Concurrency),
+		expanded: make(map[string]bool),
+	}
+	root := &TopologyNode{EntityID: entityID}
+	c.sem <- struct{}{}
+	c.crawl(root, nil, 0)
+	return root, ctx.Err()
+}
+
+// crawl fills the node and crawls its subordinates. superior is the entity
+// configuration of the node's superior in the tree, nil for the root. The
+// caller must hold a slot of the semaphore, which is released once the node
+// is visited. A goroutine for a subordinate is only started when a slot is
+// free, so the number of goroutines does not grow with the number of listed
+// subordinates.
+func (c *topologyCrawler) crawl(node *TopologyNode, superior *oidfed.EntityStatement, depth int) {
+	config, subordinates := c.visit(node, superior, depth)
+	<-c.sem
+	if len(subordinates) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, id := range subordinates {
+		child := &TopologyNode{EntityID: id}
+		node.Subordinates = append(node.Subordinates, child)
+		c.sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.crawl(child, config, depth+1)
+		}()
+	}
+	wg.Wait()
+}
+
+// visit fetches and validates the entity of the node and returns its entity
+// configuration and the subordinates that should be crawled.
+func (c *topologyCrawler) visit(node *TopologyNode, superior *oidfed.EntityStatement, depth int) (
+	*oidfed.EntityStatement, []string,
+) {
+	if c.ctx.Err() != nil {
+		node.Errors = append(node.Errors, "crawl was canceled")
+		return nil, nil
+	}
+
+	_, span := tracing.Start(
+		c.ctx, "oidfed.GetEntityConfiguration", attribute.String("oidfed.entity_id", node.EntityID),
+	)
+	config, err := oidfed.GetEntityConfiguration(node.EntityID)
+	tracing.End(span, err)
+	if err != nil {
+		node.Errors = append(node.Errors, fmt.Sprintf("could not obtain entity configuration: %s", err))
+		return nil, nil
+	}
+	if superior == nil && len(config.AuthorityHints) == 0 {
+		// The crawl starts at a trust anchor, so trust marks can be verified
+		// against it.
+		c.anchor = config
+	}
+	c.annotate(node, config)
+	if superior != nil {
+		c.checkSubordinateStatement(node, config, superior)
+	}
+
+	c.mu.Lock()
+	revisited := c.expanded[node.EntityID]
+	c.expanded[node.EntityID] = true
+	c.mu.Unlock()
+	if revisited {
+		node.Revisited = true
+		return config, nil
+	}
+
+	if config.Metadata == nil || config.Metadata.FederationEntity == nil ||
+		config.Metadata.FederationEntity.FederationListEndpoint == "" {
+		return config, nil
+	}
+	if c.opts.MaxDepth > 0 && depth >= c.opts.MaxDepth {
+		node.Truncated = true
+		return config, nil
+	}
+	subordinates, err := c.fetchList(config.Metadata.FederationEntity.FederationListEndpoint)
+	if err != nil {
+		node.Errors = append(node.Errors, fmt.Sprintf("could not query list endpoint: %s", err))
+		return config, nil
+	}
+	return config, subordinates
+}
+
+// annotate adds the information and validation errors from the entity
+// configuration to the node.
+func (c *topologyCrawler) annotate(node *TopologyNode, config *oidfed.EntityStatement) {
+	if config.Metadata != nil {
+		node.EntityTypes = config.Metadata.GuessEntityTypes()
+	}
+	expiresAt := config.ExpiresAt.Time
+	node.ConfigurationExpiresAt = &expiresAt
+	if config.Issuer != config.Subject {
+		node.Errors = append(node.Errors, "entity configuration issuer and subject differ")
+	}
+	if config.Subject != node.EntityID {
+		node.Errors = append(node.Errors, fmt.Sprintf("entity configuration is about '%s'", config.Subject))
+	}
+	if !config.Verify(config.JWKS) {
+		node.Errors = append(node.Errors, "entity configuration is not signed with one of its own keys")
+	}
+	if !config.TimeValid() {
+		node.Errors = append(node.Errors, "entity configuration is expired or not yet valid")
+	}
+	for _, info := range config.TrustMarks {
+		// info is a copy, since the entity configuration is shared through the
+		// resolver cache and parsing a trust mark caches it in the info.
+		node.TrustMarks = append(node.TrustMarks, info.TrustMarkType)
+		if _, err := info.TrustMark(); err != nil {
+			node.Errors = append(node.Errors, fmt.Sprintf("invalid trust mark '%s': %s", info.TrustMarkType, err))
+			continue
+		}
+		if c.anchor != nil {
+			if err := info.VerifyFederation(&c.anchor.EntityStatementPayload); err != nil {
+				node.Errors = append(
+					node.Errors, fmt.Sprintf("trust mark '%s' could not be verified: %s", info.TrustMarkType, err),
+				)
+			}
+		}
+	}
+}
+
+// checkSubordinateStatement fetches the subordinate statement about the node
+// from its superior and checks it against the entity configuration.
+func (c *topologyCrawler) checkSubordinateStatement(
+	node *TopologyNode, config, superior *oidfed.EntityStatement,
+) {
+	if superior.Metadata == nil || superior.Metadata.FederationEntity == nil ||
+		superior.Metadata.FederationEntity.FederationFetchEndpoint == "" {
+		node.Errors = append(node.Errors, "superior has no federation_fetch_endpoint")
+		return
+	}
+	statement, err := oidfed.FetchEntityStatement(
+		superior.Metadata.FederationEntity.FederationFetchEndpoint, node.EntityID, superior.Subject,
+	)
+	if err != nil {
+		node.Errors = append(node.Errors, fmt.Sprintf("could not fetch subordinate statement: %s", err))
+		return
+	}
+	expiresAt := statement.ExpiresAt.Time
+	node.StatementExpiresAt = &expiresAt
+	if statement.Issuer != superior.Subject || statement.Subject != node.EntityID {
+		node.Errors = append(node.Errors, "subordinate statement has wrong issuer or subject")
+	}
+	if !statement.Verify(superior.JWKS) {
+		node.Errors = append(node.Errors, "subordinate statement is not signed with a key of the superior")
+	}
+	if !statement.TimeValid() {
+		node.Errors = append(node.Errors, "subordinate statement is expired or not yet valid")
+	}
+	if !config.Verify(statement.JWKS) {
+		node.Errors = append(
+			node.Errors, "entity configuration is not signed with a key from the subordinate statement",
+		)
+	}
+	if !slices.Contains(config.AuthorityHints, superior.Subject) {
+		node.Errors = append(node.Errors, "authority_hints do not contain the superior")
+	}
+}
+
+func (c *topologyCrawler) fetchList(endpoint string) ([]string, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("list endpoint returned %d", resp.StatusCode)
+	}
+	var ids []string
+	if err = json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return nil, errors.Wrap(err, "invalid list response")
+	}
+	return ids, nil
+}
+
+// TopologyFormat is an export format for a crawled topology.
+type TopologyFormat string
+
+const (
+	// TopologyFormatJSON exports the topology tree as JSON.
+	TopologyFormatJSON TopologyFormat = "json"
+	// TopologyFormatDOT exports the topology graph in the GraphViz DOT
+	// language.
+	TopologyFormatDOT TopologyFormat = "dot"
+	// TopologyFormatMermaid exports the topology graph as a Mermaid flowchart.
+	TopologyFormatMermaid TopologyFormat = "mermaid"
+)
+
+// ParseTopologyFormat parses a TopologyFormat; an empty string results in
+// TopologyFormatJSON.
+func ParseTopologyFormat(s string) (TopologyFormat, error) {
+	switch f := TopologyFormat(s); f {
+	case "":
+		return TopologyFormatJSON, nil
+	case TopologyFormatJSON, TopologyFormatDOT, TopologyFormatMermaid:
+		return f, nil
+	default:
+		return "", model.ValidationErrorFmt("invalid format '%s' (valid values: json, dot, mermaid)", s)
+	}
+}
+
+// ContentType returns the content type of the format.
+func (f TopologyFormat) ContentType() string {
+	switch f {
+	case TopologyFormatDOT:
+		return "text/vnd.graphviz"
+	case TopologyFormatMermaid:
+		return "text/plain; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// EncodeTopology writes the topology in the passed format. The JSON format
+// contains the tree as crawled; the graph formats contain each entity once,
+// with an edge from each superior to its subordinates.
+func EncodeTopology(w io.Writer, root *TopologyNode, format TopologyFormat) error {
+	switch format {
+	case TopologyFormatDOT:
+		return encodeTopologyDOT(w, newTopologyGraph(root))
+	case TopologyFormatMermaid:
+		return encodeTopologyMermaid(w, newTopologyGraph(root))
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(root)
+	}
+}
+
+type topologyGraphNode struct {
+	id     string
+	node   *TopologyNode
+	errors []string
+}
+
+type topologyGraphEdge struct {
+	from, to  *topologyGraphNode
+	expiresAt *time.Time
+}
+
+type topologyGraph struct {
+	nodes []*topologyGraphNode
+	edges []topologyGraphEdge
+}
+
+// newTopologyGraph flattens the tree into a graph with one node per entity;
+// the errors of all occurrences of an entity are merged.
+func newTopologyGraph(root *TopologyNode) topologyGraph {
+	var g topologyGraph
+	byEntityID := make(map[string]*topologyGraphNode)
+	var add func(n *TopologyNode) *topologyGraphNode
+	add = func(n *TopologyNode) *topologyGraphNode {
+		gn, ok := byEntityID[n.EntityID]
+		if !ok {
+			gn = &topologyGraphNode{
+				id:   fmt.Sprintf("n%d", len(g.nodes)),
+				node: n,
+			}
+			byEntityID[n.EntityID] = gn
+			g.nodes = append(g.nodes, gn)
+		}
+		for _, e := range n.Errors {
+			if !slices.Contains(gn.errors, e) {
+				gn.errors = append(gn.errors, e)
+			}
+		}
+		for _, s := range n.Subordinates {
+			g.edges = append(
+				g.edges, topologyGraphEdge{
+					from:      gn,
+					to:        add(s),
+					expiresAt: s.StatementExpiresAt,
+				},
+			)
+		}
+		return gn
+	}
+	add(root)
+	return g
+}
+
+// label returns the lines describing the node.
+func (n *topologyGraphNode) label() []string {
+	lines := []string{n.node.EntityID}
+	if len(n.node.EntityTypes) > 0 {
+		lines = append(lines, strings.Join(n.node.EntityTypes, ", "))
+	}
+	if len(n.node.TrustMarks) > 0 {
+		lines = append(lines, "trust marks: "+strings.Join(n.node.TrustMarks, ", "))
+	}
+	if n.node.ConfigurationExpiresAt != nil {
+		lines = append(lines, "exp: "+n.node.ConfigurationExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if len(n.errors) > 0 {
+		lines = append(lines, fmt.Sprintf("%d error(s)", len(n.errors)))
+	}
+	return lines
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func encodeTopologyDOT(w io.Writer, g topologyGraph) error {
+	var b strings.Builder
+	b.WriteString("digraph federation {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.nodes {
+		fmt.Fprintf(&b, "  %s [label=%s", n.id, dotQuote(strings.Join(n.label(), "\n")))
+		if len(n.errors) > 0 {
+			fmt.Fprintf(&b, ", color=red, tooltip=%s", dotQuote(strings.Join(n.errors, "\n")))
+		}
+		b.WriteString("];\n")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&b, "  %s -> %s", e.from.id, e.to.id)
+		if e.expiresAt != nil {
+			fmt.Fprintf(&b, " [label=%s]", dotQuote("exp: "+e.expiresAt.UTC().Format(time.RFC3339)))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscape replaces the characters that would end or break a quoted
+// Mermaid label with entity codes.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "|", "#124;").Replace(s)
+}
+
+func encodeTopologyMermaid(w io.Writer, g topologyGraph) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for _, n := range g.nodes {
+		lines := n.label()
+		for i, l := range lines {
+			lines[i] = mermaidEscape(l)
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.id, strings.Join(lines, "<br/>"))
+	}
+	for _, e := range g.edges {
+		if e.expiresAt != nil {
+			fmt.Fprintf(
+				&b, "  %s -->|\"%s\"| %s\n", e.from.id,
+				mermaidEscape("exp: "+e.expiresAt.UTC().Format(time.RFC3339)), e.to.id,
+			)
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", e.from.id, e.to.id)
+		}
+	}
+	var withErrors []string
+	for _, n := range g.nodes {
+		if len(n.errors) > 0 {
+			withErrors = append(withErrors, n.id)
+		}
+	}
+	if len(withErrors) > 0 {
+		b.WriteString("  classDef error stroke:#d00,stroke-width:2px\n")
+		fmt.Fprintf(&b, "  class %s error\n", strings.Join(withErrors, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,241 @@
+package adminapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxTopologyCrawlJobs is the number of crawl jobs that are kept in memory;
+// when it is exceeded, the oldest job is dropped.
+const maxTopologyCrawlJobs = 20
+
+// DefaultTopologyCrawlTimeout is the default time limit of a topology crawl
+// job.
+const DefaultTopologyCrawlTimeout = 10 * time.Minute
+
+// TopologyCrawlJobStatus is the status of a topology crawl job.
+type TopologyCrawlJobStatus string
+
+const (
+	// TopologyCrawlJobRunning indicates that the crawl is still running.
+	TopologyCrawlJobRunning TopologyCrawlJobStatus = "running"
+	// TopologyCrawlJobFinished indicates that the crawl has finished.
+	TopologyCrawlJobFinished TopologyCrawlJobStatus = "finished"
+	// TopologyCrawlJobCanceled indicates that the crawl was canceled, either
+	// through the admin API or because LightHouse was stopped.
+	TopologyCrawlJobCanceled TopologyCrawlJobStatus = "canceled"
+)
+
+// TopologyCrawlJob is a topology crawl started through the admin API.
+type TopologyCrawlJob struct {
+	ID          string                 `json:"id"`
+	Status      TopologyCrawlJobStatus `json:"status"`
+	StartEntity string                 `json:"start_entity"`
+	StartedAt   time.Time              `json:"started_at"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty"`
+	// Entities is the number of distinct entities found.
+	Entities int `json:"entities"`
+	// EntitiesWithErrors is the number of distinct entities with validation
+	// errors.
+	EntitiesWithErrors int `json:"entities_with_errors"`
+	// Error is set if the crawl did not complete, e.g. because it timed out;
+	// the topology then only holds the entities crawled until then.
+	Error    string        `json:"error,omitempty"`
+	Topology *TopologyNode `json:"topology,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// startTopologyCrawl is the request body for starting a crawl.
+type startTopologyCrawl struct {
+	// TrustAnchor is the entity to start at; if empty, the crawl starts at
+	// this entity.
+	TrustAnchor string `json:"trust_anchor,omitempty"`
+	TopologyCrawlOptions
+}
+
+type topologyHandlers struct {
+	entityID string
+	// ctx is the parent context of all crawls; it is canceled when
+	// LightHouse is stopped.
+	ctx     context.Context
+	timeout time.Duration
+	mu      sync.Mutex
+	jobs    []*TopologyCrawlJob
+}
+
+// summary returns a copy of the job without the topology.
+func (j TopologyCrawlJob) summary() TopologyCrawlJob {
+	j.Topology = nil
+	return j
+}
+
+func (h *topologyHandlers) startCrawl(c *fiber.Ctx) error {
+	var req startTopologyCrawl
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return writeBadBody(c)
+		}
+	}
+	if err := req.Validate(); err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+	start := req.TrustAnchor
+	if start == "" {
+		start = h.entityID
+	}
+	if start == "" {
+		return writeBadRequest(c, "trust_anchor is required")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if slices.ContainsFunc(
+		h.jobs, func(j *TopologyCrawlJob) bool { return j.Status == TopologyCrawlJobRunning },
+	) {
+		return writeConflict(c, "a topology crawl is already running")
+	}
+	ctx, cancel := context.WithTimeout(h.ctx, h.timeout)
+	job := &TopologyCrawlJob{
+		ID:          uuid.NewString(),
+		Status:      TopologyCrawlJobRunning,
+		StartEntity: start,
+		StartedAt:   time.Now(),
+		cancel:      cancel,
+	}
+	h.jobs = append(h.jobs, job)
+	if len(h.jobs) > maxTopologyCrawlJobs {
+		h.jobs = h.jobs[1:]
+	}
+	go h.run(ctx, job, req.TopologyCrawlOptions)
+	return c.Status(fiber.StatusAccepted).JSON(job.summary())
+}
+
+func (h *topologyHandlers) run(ctx context.Context, job *TopologyCrawlJob, opts TopologyCrawlOptions) {
+	root, err := CrawlTopology(ctx, job.StartEntity, opts)
+	job.cancel()
+	withErrors := make(map[string]bool)
+	root.Walk(
+		func(n *TopologyNode) {
+			withErrors[n.EntityID] = withErrors[n.EntityID] || len(n.Errors) > 0
+		},
+	)
+	finished := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	job.Topology = root
+	job.Entities = len(withErrors)
+	for _, e := range withErrors {
+		if e {
+			job.EntitiesWithErrors++
+		}
+	}
+	job.FinishedAt = &finished
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		job.Status = TopologyCrawlJobFinished
+		job.Error = fmt.Sprintf("topology crawl timed out after %s", h.timeout)
+	case err != nil:
+		job.Status = TopologyCrawlJobCanceled
+		job.Error = "topology crawl was canceled"
+	default:
+		job.Status = TopologyCrawlJobFinished
+	}
+}
+
+func (h *topologyHandlers) find(id string) (TopologyCrawlJob, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, j := range h.jobs {
+		if j.ID == id {
+			return *j, true
+		}
+	}
+	return TopologyCrawlJob{}, false
+}
+
+func (h *topologyHandlers) listCrawls(c *fiber.Ctx) error {
+	h.mu.Lock()
+	jobs := make([]TopologyCrawlJob, len(h.jobs))
+	for i, j := range h.jobs {
+		// newest first
+		jobs[len(h.jobs)-1-i] = j.summary()
+	}
+	h.mu.Unlock()
+	return c.JSON(jobs)
+}
+
+func (h *topologyHandlers) getCrawl(c *fiber.Ctx) error {
+	job, ok := h.find(c.Params("jobID"))
+	if !ok {
+		return writeNotFound(c, "topology crawl not found")
+	}
+	return c.JSON(job)
+}
+
+func (h *topologyHandlers) getGraph(c *fiber.Ctx) error {
+	format, err := ParseTopologyFormat(c.Query("format"))
+	if err != nil {
+		return writeBadRequest(c, err.Error())
+	}
+	job, ok := h.find(c.Params("jobID"))
+	if !ok {
+		return writeNotFound(c, "topology crawl not found")
+	}
+	if job.Status == TopologyCrawlJobRunning {
+		return writeConflict(c, "topology crawl is still running")
+	}
+	var buf bytes.Buffer
+	if err = EncodeTopology(&buf, job.Topology, format); err != nil {
+		return writeServerError(c, err)
+	}
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	return c.Send(buf.Bytes())
+}
+
+func (h *topologyHandlers) cancelCrawl(c *fiber.Ctx) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, j := range h.jobs {
+		if j.ID != c.Params("jobID") {
+			continue
+		}
+		if j.Status != TopologyCrawlJobRunning {
+			return writeConflict(c, "topology crawl is not running")
+		}
+		// The job is updated once the running requests returned
+		j.cancel()
+		return c.Status(fiber.StatusAccepted).JSON(j.summary())
+	}
+	return writeNotFound(c, "topology crawl not found")
+}
+
+// registerTopology registers the endpoints for crawling the federation below
+// this entity or a given trust anchor. Crawls run in the background; their
+// results are kept in memory. Running crawls are canceled when ctx is done or
+// after the timeout.
+func registerTopology(ctx context.Context, r fiber.Router, entityID string, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultTopologyCrawlTimeout
+	}
+	h := &topologyHandlers{
+		entityID: entityID,
+		ctx:      ctx,
+		timeout:  timeout,
+	}
+	g := r.Group("/topology/crawls")
+	g.Get("/", h.listCrawls)
+	g.Post("/", h.startCrawl)
+	g.Get("/:jobID", h.getCrawl)
+	g.Delete("/:jobID", h.cancelCrawl)
+	g.Get("/:jobID/graph", h.getGraph)
+}
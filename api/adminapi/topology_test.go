@@ -0,0 +1,433 @@
+package adminapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+)
+
+type testFederationEntity struct {
+	server *httptest.Server
+	signer *jwx.EntityStatementSigner
+	jwks   jwx.JWKS
+}
+
+func newTestFederationEntity(t *testing.T, mux *http.ServeMux) *testFederationEntity {
+	t.Helper()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	jwks, err := jwx.KeyToJWKS(sk.Public(), jwa.ES256())
+	if err != nil {
+		t.Fatalf("Failed to create JWKS: %v", err)
+	}
+	e := &testFederationEntity{
+		server: httptest.NewServer(mux),
+		signer: jwx.NewGeneralJWTSigner(
+			jwx.NewSingleKeyVersatileSigner(sk, jwa.ES256()), []jwa.SignatureAlgorithm{jwa.ES256()},
+		).EntityStatementSigner(),
+		jwks: jwks,
+	}
+	t.Cleanup(e.server.Close)
+	return e
+}
+
+func (e *testFederationEntity) id() string {
+	return e.server.URL
+}
+
+func (e *testFederationEntity) sign(t *testing.T, payload oidfed.EntityStatementPayload) []byte {
+	t.Helper()
+	payload.Issuer = e.id()
+	payload.IssuedAt = unixtime.Now()
+	payload.ExpiresAt = unixtime.Unixtime{Time: time.Now().Add(time.Hour)}
+	data, err := e.signer.JWT(payload)
+	if err != nil {
+		t.Fatalf("Failed to sign statement: %v", err)
+	}
+	return data
+}
+
+func serveJWT(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "application/entity-statement+jwt")
+	_, _ = w.Write(data)
+}
+
+// setupTestFederation starts a trust anchor with a valid relying party and a
+// subordinate without an entity configuration.
+func setupTestFederation(t *testing.T) (ta, rp *testFederationEntity, broken string) {
+	t.Helper()
+	taMux, rpMux, brokenMux := http.NewServeMux(), http.NewServeMux(), http.NewServeMux()
+	ta = newTestFederationEntity(t, taMux)
+	rp = newTestFederationEntity(t, rpMux)
+	brokenServer := httptest.NewServer(brokenMux)
+	t.Cleanup(brokenServer.Close)
+	broken = brokenServer.URL
+
+	taMux.HandleFunc(
+		"/.well-known/openid-federation", func(w http.ResponseWriter, _ *http.Request) {
+			serveJWT(
+				w, ta.sign(
+					t, oidfed.EntityStatementPayload{
+						Subject: ta.id(),
+						JWKS:    ta.jwks,
+						Metadata: &oidfed.Metadata{
+							FederationEntity: &oidfed.FederationEntityMetadata{
+								FederationListEndpoint:  ta.id() + "/list",
+								FederationFetchEndpoint: ta.id() + "/fetch",
+							},
+						},
+					},
+				),
+			)
+		},
+	)
+	taMux.HandleFunc(
+		"/list", func(w http.ResponseWriter, _ *http.Request) {
+			_ = json.NewEncoder(w).Encode([]string{rp.id(), broken})
+		},
+	)
+	taMux.HandleFunc(
+		"/fetch", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("sub") != rp.id() {
+				http.NotFound(w, r)
+				return
+			}
+			serveJWT(w, ta.sign(t, oidfed.EntityStatementPayload{Subject: rp.id(), JWKS: rp.jwks}))
+		},
+	)
+	rpMux.HandleFunc(
+		"/.well-known/openid-federation", func(w http.ResponseWriter, _ *http.Request) {
+			serveJWT(
+				w, rp.sign(
+					t, oidfed.EntityStatementPayload{
+						Subject:        rp.id(),
+						JWKS:           rp.jwks,
+						AuthorityHints: []string{ta.id()},
+						Metadata: &oidfed.Metadata{
+							RelyingParty: &oidfed.OpenIDRelyingPartyMetadata{
+								RedirectURIS: []string{rp.id() + "/callback"},
+							},
+						},
+					},
+				),
+			)
+		},
+	)
+	return ta, rp, broken
+}
+
+func TestCrawlTopology(t *testing.T) {
+	t.Parallel()
+	ta, rp, broken := setupTestFederation(t)
+
+	root, err := CrawlTopology(context.Background(), ta.id(), TopologyCrawlOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("CrawlTopology failed: %v", err)
+	}
+	if len(root.Errors) > 0 {
+		t.Errorf("Expected no errors for the trust anchor, got %v", root.Errors)
+	}
+	if len(root.Subordinates) != 2 {
+		t.Fatalf("Expected 2 subordinates, got %d", len(root.Subordinates))
+	}
+	rpNode, brokenNode := root.Subordinates[0], root.Subordinates[1]
+	if rpNode.EntityID != rp.id() || brokenNode.EntityID != broken {
+		t.Fatalf("Unexpected subordinates %s, %s", rpNode.EntityID, brokenNode.EntityID)
+	}
+	if len(rpNode.Errors) > 0 {
+		t.Errorf("Expected no errors for the relying party, got %v", rpNode.Errors)
+	}
+	if rpNode.StatementExpiresAt == nil || rpNode.ConfigurationExpiresAt == nil {
+		t.Error("Expected expirations for the relying party")
+	}
+	if len(rpNode.EntityTypes) != 1 || rpNode.EntityTypes[0] != "openid_relying_party" {
+		t.Errorf("Unexpected entity types %v", rpNode.EntityTypes)
+	}
+	if len(brokenNode.Errors) == 0 {
+		t.Error("Expected errors for the subordinate without entity configuration")
+	}
+
+	t.Run(
+		"MaxDepth", func(t *testing.T) {
+			t.Parallel()
+			root, err := CrawlTopology(context.Background(), ta.id(), TopologyCrawlOptions{MaxDepth: 1})
+			if err != nil {
+				t.Fatalf("CrawlTopology failed: %v", err)
+			}
+			if len(root.Subordinates) != 2 || root.Truncated {
+				t.Errorf("Expected the subordinates of the root to be crawled")
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidOptions", func(t *testing.T) {
+			t.Parallel()
+			if _, err := CrawlTopology(context.Background(), ta.id(), TopologyCrawlOptions{MaxDepth: -1}); err == nil {
+				t.Error("Expected error for negative max depth")
+			}
+		},
+	)
+
+	t.Run(
+		"Export", func(t *testing.T) {
+			t.Parallel()
+			for format, expected := range map[TopologyFormat][]string{
+				TopologyFormatJSON:    {`"entity_id": "` + rp.id() + `"`, `"statement_expires_at"`},
+				TopologyFormatDOT:     {"digraph federation", "n0 -> n1", "n2 [label=", "color=red"},
+				TopologyFormatMermaid: {"flowchart TD", "n0 -->|", "class n2 error"},
+			} {
+				var buf bytes.Buffer
+				if err := EncodeTopology(&buf, root, format); err != nil {
+					t.Fatalf("EncodeTopology(%s) failed: %v", format, err)
+				}
+				for _, e := range expected {
+					if !strings.Contains(buf.String(), e) {
+						t.Errorf("Expected %s export to contain %q, got:\n%s", format, e, buf.String())
+					}
+				}
+			}
+		},
+	)
+}
+
+func TestTopologyCrawlHandlers(t *testing.T) {
+	t.Parallel()
+	ta, _, _ := setupTestFederation(t)
+	app := fiber.New()
+	registerTopology(context.Background(), app, "", 0)
+
+	t.Run(
+		"MissingStart", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/topology/crawls", http.NoBody)
+			resp, body := doRequest(t, app, req)
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+
+	t.Run(
+		"InvalidOptions", func(t *testing.T) {
+			req := httptest.NewRequest(
+				http.MethodPost, "/topology/crawls", strings.NewReader(`{"trust_anchor":"x","concurrency":1000}`),
+			)
+			req.Header.Set("Content-Type", "application/json")
+			resp, body := doRequest(t, app, req)
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+
+	t.Run(
+		"NotFound", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/topology/crawls/unknown", http.NoBody)
+			resp, body := doRequest(t, app, req)
+			assertErrorResponse(t, resp, body, http.StatusNotFound, "not_found")
+		},
+	)
+
+	t.Run(
+		"Crawl", func(t *testing.T) {
+			req := httptest.NewRequest(
+				http.MethodPost, "/topology/crawls", strings.NewReader(`{"trust_anchor":"`+ta.id()+`"}`),
+			)
+			req.Header.Set("Content-Type", "application/json")
+			resp, body := doRequest(t, app, req)
+			requireStatus(t, resp, body, http.StatusAccepted)
+			var job TopologyCrawlJob
+			if err := json.Unmarshal(body, &job); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+
+			deadline := time.Now().Add(10 * time.Second)
+			for job.Status != TopologyCrawlJobFinished {
+				if time.Now().After(deadline) {
+					t.Fatal("Crawl did not finish in time")
+				}
+				time.Sleep(20 * time.Millisecond)
+				resp, body = doRequest(t, app, httptest.NewRequest(http.MethodGet, "/topology/crawls/"+job.ID, http.NoBody))
+				requireStatus(t, resp, body, http.StatusOK)
+				if err := json.Unmarshal(body, &job); err != nil {
+					t.Fatalf("Failed to parse response: %v", err)
+				}
+			}
+			if job.Entities != 3 || job.EntitiesWithErrors != 1 {
+				t.Errorf("Expected 3 entities with 1 error, got %d with %d", job.Entities, job.EntitiesWithErrors)
+			}
+
+			resp, body = doRequest(
+				t, app, httptest.NewRequest(http.MethodGet, "/topology/crawls/"+job.ID+"/graph?format=mermaid", http.NoBody),
+			)
+			requireStatus(t, resp, body, http.StatusOK)
+			if !strings.HasPrefix(string(body), "flowchart TD") {
+				t.Errorf("Expected mermaid flowchart, got %s", body)
+			}
+
+			resp, body = doRequest(
+				t, app, httptest.NewRequest(http.MethodGet, "/topology/crawls/"+job.ID+"/graph?format=svg", http.NoBody),
+			)
+			assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+		},
+	)
+}
+
+func TestCrawlTopologySingleRequest(t *testing.T) {
+	t.Parallel()
+	ta, _, _ := setupTestFederation(t)
+
+	// Subordinates are only started when a request slot is free, so a single
+	// slot must not block the crawl
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	root, err := CrawlTopology(ctx, ta.id(), TopologyCrawlOptions{Concurrency: 1})
+	if err != nil {
+		t.Fatalf("CrawlTopology failed: %v", err)
+	}
+	if len(root.Subordinates) != 2 {
+		t.Errorf("Expected 2 subordinates, got %d", len(root.Subordinates))
+	}
+}
+
+// setupHangingFederation starts a trust anchor whose list endpoint only
+// answers when the request is canceled.
+func setupHangingFederation(t *testing.T) *testFederationEntity {
+	t.Helper()
+	mux := http.NewServeMux()
+	ta := newTestFederationEntity(t, mux)
+	mux.HandleFunc(
+		"/.well-known/openid-federation", func(w http.ResponseWriter, _ *http.Request) {
+			serveJWT(
+				w, ta.sign(
+					t, oidfed.EntityStatementPayload{
+						Subject: ta.id(),
+						JWKS:    ta.jwks,
+						Metadata: &oidfed.Metadata{
+							FederationEntity: &oidfed.FederationEntityMetadata{
+								FederationListEndpoint: ta.id() + "/list",
+							},
+						},
+					},
+				),
+			)
+		},
+	)
+	mux.HandleFunc(
+		"/list", func(_ http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	)
+	return ta
+}
+
+// startTestCrawl starts a crawl through the API and returns the job.
+func startTestCrawl(t *testing.T, app *fiber.App, trustAnchor string) TopologyCrawlJob {
+	t.Helper()
+	req := httptest.NewRequest(
+		http.MethodPost, "/topology/crawls", strings.NewReader(`{"trust_anchor":"`+trustAnchor+`"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	resp, body := doRequest(t, app, req)
+	requireStatus(t, resp, body, http.StatusAccepted)
+	var job TopologyCrawlJob
+	if err := json.Unmarshal(body, &job); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return job
+}
+
+// waitForCrawl polls the job until it is no longer running.
+func waitForCrawl(t *testing.T, app *fiber.App, id string) TopologyCrawlJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, body := doRequest(t, app, httptest.NewRequest(http.MethodGet, "/topology/crawls/"+id, http.NoBody))
+		requireStatus(t, resp, body, http.StatusOK)
+		var job TopologyCrawlJob
+		if err := json.Unmarshal(body, &job); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if job.Status != TopologyCrawlJobRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Crawl did not stop in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTopologyCrawlJobsStop(t *testing.T) {
+	t.Parallel()
+
+	t.Run(
+		"Cancel", func(t *testing.T) {
+			t.Parallel()
+			ta := setupHangingFederation(t)
+			app := fiber.New()
+			registerTopology(context.Background(), app, "", time.Hour)
+			job := startTestCrawl(t, app, ta.id())
+
+			resp, body := doRequest(t, app, httptest.NewRequest(http.MethodDelete, "/topology/crawls/"+job.ID, http.NoBody))
+			requireStatus(t, resp, body, http.StatusAccepted)
+			job = waitForCrawl(t, app, job.ID)
+			if job.Status != TopologyCrawlJobCanceled || job.Error == "" || job.FinishedAt == nil {
+				t.Errorf("Expected canceled job, got %+v", job)
+			}
+
+			resp, body = doRequest(t, app, httptest.NewRequest(http.MethodDelete, "/topology/crawls/"+job.ID, http.NoBody))
+			assertErrorResponse(t, resp, body, http.StatusConflict, "invalid_request")
+			resp, body = doRequest(t, app, httptest.NewRequest(http.MethodDelete, "/topology/crawls/unknown", http.NoBody))
+			assertErrorResponse(t, resp, body, http.StatusNotFound, "not_found")
+
+			// The partial result is available and a new crawl can be started
+			resp, body = doRequest(
+				t, app, httptest.NewRequest(http.MethodGet, "/topology/crawls/"+job.ID+"/graph", http.NoBody),
+			)
+			requireStatus(t, resp, body, http.StatusOK)
+			startTestCrawl(t, app, ta.id())
+		},
+	)
+
+	t.Run(
+		"Timeout", func(t *testing.T) {
+			t.Parallel()
+			ta := setupHangingFederation(t)
+			app := fiber.New()
+			registerTopology(context.Background(), app, "", 100*time.Millisecond)
+			job := waitForCrawl(t, app, startTestCrawl(t, app, ta.id()).ID)
+			if job.Status != TopologyCrawlJobFinished || !strings.Contains(job.Error, "timed out") {
+				t.Errorf("Expected timed out job, got %+v", job)
+			}
+		},
+	)
+
+	t.Run(
+		"Shutdown", func(t *testing.T) {
+			t.Parallel()
+			ta := setupHangingFederation(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			app := fiber.New()
+			registerTopology(ctx, app, "", time.Hour)
+			job := startTestCrawl(t, app, ta.id())
+			cancel()
+			job = waitForCrawl(t, app, job.ID)
+			if job.Status != TopologyCrawlJobCanceled {
+				t.Errorf("Expected canceled job, got %+v", job)
+			}
+		},
+	)
+}
@@ -0,0 +1,149 @@
+package adminapi
+
+import (
+	"errors"
+	"fmt"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// TrustMarkCacheInvalidator is implemented by types that cache eligibility
+// results and issued trust marks and need to be invalidated when trust marks
+// are revoked.
+type TrustMarkCacheInvalidator interface {
+	InvalidateTrustMark(trustMarkType, subject string)
+}
+
+// trustMarkInstanceHandlers groups handlers for issued trust mark instances.
+type trustMarkInstanceHandlers struct {
+	store       model.IssuedTrustMarkInstanceStore
+	invalidator TrustMarkCacheInvalidator
+}
+
+// registerTrustMarkInstances registers the endpoints to inspect and revoke
+// issued trust mark instances. The invalidator is called for every revoked
+// trust mark type and subject and can be nil.
+func registerTrustMarkInstances(
+	r fiber.Router, store model.IssuedTrustMarkInstanceStore, invalidator TrustMarkCacheInvalidator,
+) {
+	if store == nil {
+		return
+	}
+	h := &trustMarkInstanceHandlers{
+		store:       store,
+		invalidator: invalidator,
+	}
+
+	g := r.Group("/trust-marks/instances")
+	g.Get("/", h.list)
+	g.Post("/revoke", h.revokeBulk)
+	g.Get("/:jti", h.get)
+	g.Post("/:jti/revoke", h.revoke)
+}
+
+func (h *trustMarkInstanceHandlers) list(c *fiber.Ctx) error {
+	trustMarkType := c.Query("trust_mark_type")
+	sub := c.Query("sub")
+	if trustMarkType == "" || sub == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			oidfed.ErrorInvalidRequest("trust_mark_type and sub are required"),
+		)
+	}
+	instances, err := h.store.ListBySubject(trustMarkType, sub)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	if instances == nil {
+		instances = []model.IssuedTrustMarkInstance{}
+	}
+	return c.JSON(instances)
+}
+
+func (h *trustMarkInstanceHandlers) get(c *fiber.Ctx) error {
+	instance, err := h.store.GetByJTI(c.Params("jti"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(instance)
+}
+
+func (h *trustMarkInstanceHandlers) revoke(c *fiber.Ctx) error {
+	var req model.TrustMarkRevocation
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(err.Error()))
+		}
+	}
+	req.Actor = GetActor(c)
+	instance, revoked, err := h.store.Revoke(c.Params("jti"), req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	if !revoked {
+		return writeConflict(
+			c, fmt.Sprintf("trust mark '%s' was already %s", instance.JTI, instance.RevocationDescription()),
+		)
+	}
+	h.invalidate(instance.TrustMarkType, instance.Subject)
+	return c.JSON(instance)
+}
+
+func (h *trustMarkInstanceHandlers) revokeBulk(c *fiber.Ctx) error {
+	var req model.RevokeTrustMarkInstances
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(err.Error()))
+	}
+	if req.TrustMarkType == "" && req.Subject == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(
+			oidfed.ErrorInvalidRequest("trust_mark_type or sub is required"),
+		)
+	}
+	revoked, err := h.store.RevokeActive(
+		req.TrustMarkType, req.Subject, model.TrustMarkRevocation{
+			Reason: req.Reason,
+			Actor:  GetActor(c),
+		},
+	)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	res := model.RevokeTrustMarkInstancesResult{
+		Revoked: len(revoked),
+		JTIs:    make([]string, len(revoked)),
+	}
+	invalidated := make(map[[2]string]struct{})
+	for i, instance := range revoked {
+		res.JTIs[i] = instance.JTI
+		key := [2]string{instance.TrustMarkType, instance.Subject}
+		if _, ok := invalidated[key]; ok {
+			continue
+		}
+		invalidated[key] = struct{}{}
+		h.invalidate(instance.TrustMarkType, instance.Subject)
+	}
+	return c.JSON(res)
+}
+
+// invalidate removes the cached eligibility result and issued trust mark for
+// the trust mark type and subject.
+func (h *trustMarkInstanceHandlers) invalidate(trustMarkType, subject string) {
+	if h.invalidator != nil {
+		h.invalidator.InvalidateTrustMark(trustMarkType, subject)
+	}
+}
+
+func (*trustMarkInstanceHandlers) handleError(c *fiber.Ctx, err error) error {
+	var notFound model.NotFoundError
+	if errors.As(err, &notFound) {
+		return c.Status(fiber.StatusNotFound).JSON(oidfed.ErrorNotFound(string(notFound)))
+	}
+	var validation model.ValidationError
+	if errors.As(err, &validation) {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(string(validation)))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+}
@@ -0,0 +1,273 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	smodel "github.com/go-oidfed/lighthouse/storage/model"
+)
+
+type mockTrustMarkInstanceStore struct {
+	smodel.IssuedTrustMarkInstanceStore
+	revokeActiveFn func(string, string, smodel.TrustMarkRevocation) ([]smodel.IssuedTrustMarkInstance, error)
+}
+
+func (m *mockTrustMarkInstanceStore) RevokeActive(
+	trustMarkType, entityID string, revocation smodel.TrustMarkRevocation,
+) ([]smodel.IssuedTrustMarkInstance, error) {
+	return m.revokeActiveFn(trustMarkType, entityID, revocation)
+}
+
+type mockTrustMarkCacheInvalidator struct {
+	calls []string
+}
+
+func (m *mockTrustMarkCacheInvalidator) InvalidateTrustMark(trustMarkType, subject string) {
+	m.calls = append(m.calls, trustMarkType+"|"+subject)
+}
+
+func setupTrustMarkInstancesTestApp(
+	store smodel.IssuedTrustMarkInstanceStore, invalidator TrustMarkCacheInvalidator,
+) *fiber.App {
+	app := fiber.New()
+	app.Use(actorMiddleware(ActorConfig{Source: ActorSourceHeader}))
+	registerTrustMarkInstances(app, store, invalidator)
+	return app
+}
+
+func setupRealTrustMarkInstancesTestApp(t *testing.T) (
+	*fiber.App, smodel.IssuedTrustMarkInstanceStore, *mockTrustMarkCacheInvalidator,
+) {
+	t.Helper()
+	store := newTestStorage(t).Backends().TrustMarkInstances
+	future := int(time.Now().Add(time.Hour).Unix())
+	for _, instance := range []smodel.IssuedTrustMarkInstance{
+		{JTI: "a-rp1", TrustMarkType: "tm-a", Subject: "rp1", ExpiresAt: future},
+		{JTI: "a-rp2", TrustMarkType: "tm-a", Subject: "rp2", ExpiresAt: future},
+		{JTI: "b-rp1", TrustMarkType: "tm-b", Subject: "rp1", ExpiresAt: future},
+	} {
+		if err := store.Create(&instance); err != nil {
+			t.Fatalf("failed to create instance %s: %v", instance.JTI, err)
+		}
+	}
+	invalidator := &mockTrustMarkCacheInvalidator{}
+	return setupTrustMarkInstancesTestApp(store, invalidator), store, invalidator
+}
+
+func TestTrustMarkInstances(t *testing.T) {
+	t.Run("Get", func(t *testing.T) {
+		app, _, _ := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodGet, "/trust-marks/instances/a-rp1", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, fiber.StatusOK)
+		var instance smodel.IssuedTrustMarkInstance
+		if err := json.Unmarshal(body, &instance); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if instance.JTI != "a-rp1" || instance.Revoked {
+			t.Errorf("unexpected instance: %+v", instance)
+		}
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		app, _, _ := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodGet, "/trust-marks/instances/unknown", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		assertErrorResponse(t, resp, body, fiber.StatusNotFound, "not_found")
+	})
+
+	t.Run("ListRequiresTypeAndSubject", func(t *testing.T) {
+		app, _, _ := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodGet, "/trust-marks/instances?sub=rp1", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		assertErrorResponse(t, resp, body, fiber.StatusBadRequest, "invalid_request")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		app, _, _ := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodGet, "/trust-marks/instances?trust_mark_type=tm-a&sub=rp1", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, fiber.StatusOK)
+		var instances []smodel.IssuedTrustMarkInstance
+		if err := json.Unmarshal(body, &instances); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(instances) != 1 || instances[0].JTI != "a-rp1" {
+			t.Errorf("unexpected instances: %+v", instances)
+		}
+	})
+
+	t.Run("RevokeByJTI", func(t *testing.T) {
+		app, store, invalidator := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(
+			http.MethodPost, "/trust-marks/instances/a-rp1/revoke", strings.NewReader(`{"reason":"compromised"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "alice")
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, fiber.StatusOK)
+
+		instance, err := store.GetByJTI("a-rp1")
+		if err != nil {
+			t.Fatalf("failed to get instance: %v", err)
+		}
+		if !instance.Revoked || instance.RevokedAt == 0 ||
+			instance.RevocationReason != "compromised" || instance.RevokedBy != "alice" {
+			t.Errorf("instance not revoked as expected: %+v", instance)
+		}
+		if len(invalidator.calls) != 1 || invalidator.calls[0] != "tm-a|rp1" {
+			t.Errorf("unexpected cache invalidations: %v", invalidator.calls)
+		}
+	})
+
+	t.Run("RevokeByJTIWithoutBody", func(t *testing.T) {
+		app, _, _ := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodPost, "/trust-marks/instances/a-rp1/revoke", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		assertStatus(t, resp, body, fiber.StatusOK)
+	})
+
+	t.Run("RevokeByJTIAlreadyRevoked", func(t *testing.T) {
+		app, store, invalidator := setupRealTrustMarkInstancesTestApp(t)
+		if _, _, err := store.Revoke("a-rp1", smodel.TrustMarkRevocation{Reason: "compromised", Actor: "alice"}); err != nil {
+			t.Fatalf("failed to revoke instance: %v", err)
+		}
+		req := httptest.NewRequest(
+			http.MethodPost, "/trust-marks/instances/a-rp1/revoke", strings.NewReader(`{"reason":"other"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		resp, body := doRequest(t, app, req)
+		assertErrorResponse(t, resp, body, fiber.StatusConflict, "invalid_request")
+		if !strings.Contains(string(body), "already revoked") || !strings.Contains(string(body), "compromised") {
+			t.Errorf("expected the original revocation in the error, got %s", body)
+		}
+		instance, err := store.GetByJTI("a-rp1")
+		if err != nil {
+			t.Fatalf("failed to get instance: %v", err)
+		}
+		if instance.RevocationReason != "compromised" || instance.RevokedBy != "alice" {
+			t.Errorf("revocation was overwritten: %+v", instance)
+		}
+		if len(invalidator.calls) != 0 {
+			t.Errorf("unexpected cache invalidations: %v", invalidator.calls)
+		}
+	})
+
+	t.Run("RevokeByJTINotFound", func(t *testing.T) {
+		app, _, invalidator := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodPost, "/trust-marks/instances/unknown/revoke", http.NoBody)
+		resp, body := doRequest(t, app, req)
+		assertErrorResponse(t, resp, body, fiber.StatusNotFound, "not_found")
+		if len(invalidator.calls) != 0 {
+			t.Errorf("unexpected cache invalidations: %v", invalidator.calls)
+		}
+	})
+
+	t.Run("RevokeBySubject", func(t *testing.T) {
+		app, store, invalidator := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(
+			http.MethodPost, "/trust-marks/instances/revoke", strings.NewReader(`{"sub":"rp1","reason":"left"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, fiber.StatusOK)
+		var res smodel.RevokeTrustMarkInstancesResult
+		if err := json.Unmarshal(body, &res); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if res.Revoked != 2 || strings.Join(res.JTIs, ",") != "a-rp1,b-rp1" {
+			t.Errorf("unexpected response: %+v", res)
+		}
+		if strings.Join(invalidator.calls, ",") != "tm-a|rp1,tm-b|rp1" {
+			t.Errorf("unexpected cache invalidations: %v", invalidator.calls)
+		}
+		if active, _ := store.HasActiveInstance("tm-a", "rp2"); !active {
+			t.Error("instance of other subject must stay active")
+		}
+	})
+
+	t.Run("RevokeByType", func(t *testing.T) {
+		app, _, invalidator := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(
+			http.MethodPost, "/trust-marks/instances/revoke", strings.NewReader(`{"trust_mark_type":"tm-a"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, fiber.StatusOK)
+		var res smodel.RevokeTrustMarkInstancesResult
+		if err := json.Unmarshal(body, &res); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if res.Revoked != 2 || strings.Join(res.JTIs, ",") != "a-rp1,a-rp2" {
+			t.Errorf("unexpected response: %+v", res)
+		}
+		if strings.Join(invalidator.calls, ",") != "tm-a|rp1,tm-a|rp2" {
+			t.Errorf("unexpected cache invalidations: %v", invalidator.calls)
+		}
+	})
+
+	t.Run("RevokeBySubjectAndType", func(t *testing.T) {
+		var got [2]string
+		var gotRevocation smodel.TrustMarkRevocation
+		store := &mockTrustMarkInstanceStore{
+			revokeActiveFn: func(
+				trustMarkType, entityID string, revocation smodel.TrustMarkRevocation,
+			) ([]smodel.IssuedTrustMarkInstance, error) {
+				got = [2]string{trustMarkType, entityID}
+				gotRevocation = revocation
+				return []smodel.IssuedTrustMarkInstance{
+					{JTI: "1", TrustMarkType: trustMarkType, Subject: entityID},
+					{JTI: "2", TrustMarkType: trustMarkType, Subject: entityID},
+				}, nil
+			},
+		}
+		invalidator := &mockTrustMarkCacheInvalidator{}
+		app := setupTrustMarkInstancesTestApp(store, invalidator)
+		req := httptest.NewRequest(
+			http.MethodPost, "/trust-marks/instances/revoke",
+			strings.NewReader(`{"trust_mark_type":"tm-a","sub":"rp1","reason":"not eligible"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "bob")
+		resp, body := doRequest(t, app, req)
+		requireStatus(t, resp, body, fiber.StatusOK)
+		if got != [2]string{"tm-a", "rp1"} {
+			t.Errorf("unexpected filter: %v", got)
+		}
+		if gotRevocation.Reason != "not eligible" || gotRevocation.Actor != "bob" {
+			t.Errorf("unexpected revocation: %+v", gotRevocation)
+		}
+		// The caches are invalidated once per trust mark type and subject
+		if len(invalidator.calls) != 1 {
+			t.Errorf("unexpected cache invalidations: %v", invalidator.calls)
+		}
+	})
+
+	t.Run("RevokeBulkRequiresFilter", func(t *testing.T) {
+		app, _, _ := setupRealTrustMarkInstancesTestApp(t)
+		req := httptest.NewRequest(http.MethodPost, "/trust-marks/instances/revoke", strings.NewReader(`{"reason":"x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, body := doRequest(t, app, req)
+		assertErrorResponse(t, resp, body, fiber.StatusBadRequest, "invalid_request")
+	})
+
+	t.Run("RevokeBulkStoreError", func(t *testing.T) {
+		store := &mockTrustMarkInstanceStore{
+			revokeActiveFn: func(string, string, smodel.TrustMarkRevocation) ([]smodel.IssuedTrustMarkInstance, error) {
+				return nil, errors.New("db error")
+			},
+		}
+		app := setupTrustMarkInstancesTestApp(store, nil)
+		req := httptest.NewRequest(http.MethodPost, "/trust-marks/instances/revoke", strings.NewReader(`{"sub":"rp1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, body := doRequest(t, app, req)
+		assertErrorResponse(t, resp, body, fiber.StatusInternalServerError, "server_error")
+	})
+}
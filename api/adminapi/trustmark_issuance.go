@@ -107,10 +107,15 @@ func (h *trustMarkSubjectHandlers) list(c *fiber.Ctx) error {
 		}
 		statusFilter = &s
 	}
-	subjects, err := h.store.ListSubjects(specID, statusFilter)
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(oidfed.ErrorInvalidRequest(err.Error()))
+	}
+	subjects, page, err := h.store.ListSubjects(specID, statusFilter, opts)
 	if err != nil {
 		return h.handleError(c, err)
 	}
+	setPageHeaders(c, page)
 	return c.JSON(subjects)
 }
 
@@ -73,11 +73,14 @@ func (m *mockTrustMarkSpecStore) Delete(id string) error {
 	return nil
 }
 
-func (m *mockTrustMarkSpecStore) ListSubjects(specID string, status *model.Status) ([]model.TrustMarkSubject, error) {
+func (m *mockTrustMarkSpecStore) ListSubjects(specID string, status *model.Status, _ model.ListOptions) (
+	[]model.TrustMarkSubject, model.PageInfo, error,
+) {
 	if m.listSubjectsFn != nil {
-		return m.listSubjectsFn(specID, status)
+		subjects, err := m.listSubjectsFn(specID, status)
+		return subjects, model.PageInfo{Total: int64(len(subjects))}, err
 	}
-	return nil, nil
+	return nil, model.PageInfo{}, nil
 }
 func (m *mockTrustMarkSpecStore) CreateSubject(specID string, subject *model.AddTrustMarkSubject) (*model.TrustMarkSubject, error) {
 	if m.createSubjectFn != nil {
@@ -18,10 +18,15 @@ type trustMarkOwnersHandlers struct {
 }
 
 func (h *trustMarkOwnersHandlers) list(c *fiber.Ctx) error {
-	list, err := h.owners.List()
+	opts, err := parseListOptions(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+		return writeBadRequest(c, err.Error())
+	}
+	list, page, err := h.owners.ListPage(opts)
+	if err != nil {
+		return writeListError(c, err)
 	}
+	setPageHeaders(c, page)
 	return c.JSON(list)
 }
 
@@ -157,10 +162,15 @@ type globalTrustMarkIssuersHandlers struct {
 }
 
 func (h *globalTrustMarkIssuersHandlers) list(c *fiber.Ctx) error {
-	list, err := h.issuers.List()
+	opts, err := parseListOptions(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+		return writeBadRequest(c, err.Error())
+	}
+	list, page, err := h.issuers.ListPage(opts)
+	if err != nil {
+		return writeListError(c, err)
 	}
+	setPageHeaders(c, page)
 	return c.JSON(list)
 }
 
@@ -34,6 +34,11 @@ func (m *mockTrustMarkOwnersStore) List() ([]model.TrustMarkOwner, error) {
 	}
 	return nil, nil
 }
+
+func (m *mockTrustMarkOwnersStore) ListPage(model.ListOptions) ([]model.TrustMarkOwner, model.PageInfo, error) {
+	items, err := m.List()
+	return items, model.PageInfo{Total: int64(len(items))}, err
+}
 func (m *mockTrustMarkOwnersStore) Create(owner model.AddTrustMarkOwner) (*model.TrustMarkOwner, error) {
 	if m.createFn != nil {
 		return m.createFn(owner)
@@ -114,6 +119,11 @@ func (m *mockTrustMarkIssuersStore) List() ([]model.TrustMarkIssuer, error) {
 	}
 	return nil, nil
 }
+
+func (m *mockTrustMarkIssuersStore) ListPage(model.ListOptions) ([]model.TrustMarkIssuer, model.PageInfo, error) {
+	items, err := m.List()
+	return items, model.PageInfo{Total: int64(len(items))}, err
+}
 func (m *mockTrustMarkIssuersStore) Create(issuer model.AddTrustMarkIssuer) (*model.TrustMarkIssuer, error) {
 	if m.createFn != nil {
 		return m.createFn(issuer)
@@ -215,6 +225,29 @@ func TestTrustMarkOwnersHandlers_List(t *testing.T) {
 
 		assertErrorResponse(t, resp, body, http.StatusInternalServerError, "server_error")
 	})
+
+	t.Run("Paging", func(t *testing.T) {
+		t.Parallel()
+		backends := newSubordinateTestStorage(t).Backends()
+		for _, id := range []string{"https://b.example.org", "https://c.example.org", "https://a.example.org"} {
+			if _, err := backends.TrustMarkOwners.Create(model.AddTrustMarkOwner{EntityID: id}); err != nil {
+				t.Fatalf("Failed to create owner: %v", err)
+			}
+		}
+		app := setupTrustMarkOwnersApp(t, backends.TrustMarkOwners, backends.TrustMarkTypes)
+		key := func(item model.TrustMarkOwner) string { return item.EntityID }
+
+		got := collectPages(t, app, "/trust-marks/owners?sort=entity_id&order=desc&limit=2", "3", key)
+		want := []string{"https://c.example.org", "https://b.example.org", "https://a.example.org"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		got = collectPages(t, app, "/trust-marks/owners?search=A.EXAMPLE", "1", key)
+		if len(got) != 1 || got[0] != "https://a.example.org" {
+			t.Errorf("Expected only the searched owner, got %v", got)
+		}
+	})
 }
 
 func TestTrustMarkOwnersHandlers_Create(t *testing.T) {
@@ -730,6 +763,31 @@ func TestGlobalTrustMarkIssuersHandlers_List(t *testing.T) {
 
 		assertErrorResponse(t, resp, body, http.StatusInternalServerError, "server_error")
 	})
+
+	t.Run("Paging", func(t *testing.T) {
+		t.Parallel()
+		backends := newSubordinateTestStorage(t).Backends()
+		for _, issuer := range []string{"https://b.example.org", "https://c.example.org", "https://a.example.org"} {
+			if _, err := backends.TrustMarkIssuers.Create(model.AddTrustMarkIssuer{Issuer: issuer}); err != nil {
+				t.Fatalf("Failed to create issuer: %v", err)
+			}
+		}
+		app := setupTrustMarkIssuersApp(t, backends.TrustMarkIssuers, backends.TrustMarkTypes)
+		key := func(item model.TrustMarkIssuer) string { return item.Issuer }
+
+		// Default order is insertion order
+		got := collectPages(t, app, "/trust-marks/issuers?limit=1", "3", key)
+		want := []string{"https://b.example.org", "https://c.example.org", "https://a.example.org"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		got = collectPages(t, app, "/trust-marks/issuers?sort=entity_id&limit=2", "3", key)
+		want = []string{"https://a.example.org", "https://b.example.org", "https://c.example.org"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
 }
 
 func TestGlobalTrustMarkIssuersHandlers_Create(t *testing.T) {
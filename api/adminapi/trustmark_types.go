@@ -19,10 +19,15 @@ func (h *trustMarkTypesHandlers) store() model.TrustMarkTypesStore {
 }
 
 func (h *trustMarkTypesHandlers) list(c *fiber.Ctx) error {
-	items, err := h.store().List()
+	opts, err := parseListOptions(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(oidfed.ErrorServerError(err.Error()))
+		return writeBadRequest(c, err.Error())
+	}
+	items, page, err := h.store().ListPage(opts)
+	if err != nil {
+		return writeListError(c, err)
 	}
+	setPageHeaders(c, page)
 	return c.JSON(items)
 }
 
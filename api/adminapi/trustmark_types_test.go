@@ -43,6 +43,11 @@ func (m *mockTrustMarkTypesStore) List() ([]model.TrustMarkType, error) {
 	}
 	return nil, nil
 }
+
+func (m *mockTrustMarkTypesStore) ListPage(model.ListOptions) ([]model.TrustMarkType, model.PageInfo, error) {
+	items, err := m.List()
+	return items, model.PageInfo{Total: int64(len(items))}, err
+}
 func (m *mockTrustMarkTypesStore) Create(req model.AddTrustMarkType) (*model.TrustMarkType, error) {
 	if m.createFn != nil {
 		return m.createFn(req)
@@ -188,6 +193,47 @@ func TestTrustMarkTypesHandlers_List(t *testing.T) {
 
 		assertErrorResponse(t, resp, body, http.StatusInternalServerError, "server_error")
 	})
+
+	t.Run("Paging", func(t *testing.T) {
+		t.Parallel()
+		store := newSubordinateTestStorage(t).Backends().TrustMarkTypes
+		for _, tmt := range []string{"https://tm.example.org/b", "https://tm.example.org/c", "https://tm.example.org/a"} {
+			if _, err := store.Create(model.AddTrustMarkType{TrustMarkType: tmt}); err != nil {
+				t.Fatalf("Failed to create trust mark type: %v", err)
+			}
+		}
+		if _, err := store.Create(
+			model.AddTrustMarkType{TrustMarkType: "https://other.example.org/x", Description: "Special Type"},
+		); err != nil {
+			t.Fatalf("Failed to create trust mark type: %v", err)
+		}
+		app := setupTrustMarkTypesApp(t, store)
+		key := func(item model.TrustMarkType) string { return item.TrustMarkType }
+
+		got := collectPages(t, app, "/trust-marks/types?sort=entity_id&limit=2", "4", key)
+		want := []string{
+			"https://other.example.org/x", "https://tm.example.org/a", "https://tm.example.org/b",
+			"https://tm.example.org/c",
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		got = collectPages(t, app, "/trust-marks/types?search=special", "1", key)
+		if len(got) != 1 || got[0] != "https://other.example.org/x" {
+			t.Errorf("Expected only the searched type, got %v", got)
+		}
+	})
+
+	t.Run("InvalidListOptions", func(t *testing.T) {
+		t.Parallel()
+		app := setupTrustMarkTypesApp(t, &mockTrustMarkTypesStore{})
+
+		req := httptest.NewRequest("GET", "/trust-marks/types?sort=status", http.NoBody)
+		resp, body := doRequest(t, app, req)
+
+		assertErrorResponse(t, resp, body, http.StatusBadRequest, "invalid_request")
+	})
 }
 
 func TestTrustMarkTypesHandlers_Create(t *testing.T) {
@@ -0,0 +1,95 @@
+package stats
+
+import "time"
+
+// Metrics that can be used in alert rules
+const (
+	AlertMetricErrorRate    = "error_rate"
+	AlertMetricRequestCount = "request_count"
+	AlertMetricLatencyAvg   = "latency_avg"
+	AlertMetricLatencyP50   = "latency_p50"
+	AlertMetricLatencyP75   = "latency_p75"
+	AlertMetricLatencyP90   = "latency_p90"
+	AlertMetricLatencyP95   = "latency_p95"
+	AlertMetricLatencyP99   = "latency_p99"
+)
+
+// AlertMetrics holds all metrics that can be used in alert rules.
+var AlertMetrics = []string{
+	AlertMetricErrorRate,
+	AlertMetricRequestCount,
+	AlertMetricLatencyAvg,
+	AlertMetricLatencyP50,
+	AlertMetricLatencyP75,
+	AlertMetricLatencyP90,
+	AlertMetricLatencyP95,
+	AlertMetricLatencyP99,
+}
+
+// Conditions of alert rules
+const (
+	AlertConditionAbove = "above"
+	AlertConditionBelow = "below"
+)
+
+// AlertsConfig holds configuration for alerting on collected statistics.
+type AlertsConfig struct {
+	// Enabled controls whether alert rules are evaluated.
+	Enabled bool
+	// Interval is how often the alert rules are evaluated.
+	Interval time.Duration
+	// RepeatInterval is the interval in which a firing alert is notified
+	// again; if zero, it is only notified when it starts firing and when it
+	// is resolved.
+	RepeatInterval time.Duration
+	// Rules are the alert rules.
+	Rules []AlertRule
+
+	// Webhook configures the webhook sink; it is disabled if no URL is set.
+	Webhook WebhookSinkConfig
+	// SMTP configures the email sink; it is disabled if no host is set.
+	SMTP SMTPSinkConfig
+}
+
+// AlertRule is a threshold on a metric of the recent statistics of one or
+// more endpoints.
+type AlertRule struct {
+	// Name identifies the rule in notifications.
+	Name string
+	// Endpoints are the endpoints the rule is evaluated for, each on its own;
+	// if empty, the rule is evaluated for every endpoint with requests.
+	Endpoints []string
+	// Metric is one of AlertMetrics.
+	Metric string
+	// Condition is AlertConditionAbove or AlertConditionBelow.
+	Condition string
+	// Threshold is the value the metric is compared to. If BaselineWindow
+	// is set, it is a factor of the baseline value instead.
+	Threshold float64
+	// Window is the period of recent statistics the metric is computed over.
+	Window time.Duration
+	// BaselineWindow, if set, is the period before Window the baseline value
+	// of the metric is computed over.
+	BaselineWindow time.Duration
+	// MinRequests is the minimum number of requests in a window for rates
+	// and latencies to be evaluated.
+	MinRequests int64
+}
+
+// WebhookSinkConfig configures sending alerts as JSON to a webhook.
+type WebhookSinkConfig struct {
+	URL     string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+// SMTPSinkConfig configures sending alerts by email.
+type SMTPSinkConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	Timeout  time.Duration
+}
@@ -12,19 +12,36 @@ type Config struct {
 	FlushInterval  time.Duration
 	FlushThreshold float64
 
+	// Prefork must be set if the server runs in prefork mode. Child processes
+	// then forward their entries over a unix socket in SocketDir to the
+	// parent process, which writes them to the database.
+	Prefork   bool
+	SocketDir string
+
 	// Capture options
 	CaptureClientIP    bool
 	CaptureUserAgent   bool
 	CaptureQueryParams bool
+	CaptureSubject     bool
 
 	// GeoIP configuration
 	GeoIPEnabled bool
 	GeoIPDBPath  string
 
+	// Pseudonymisation of the captured client data
+	Privacy PrivacyConfig
+
+	// Sampling of the tracked requests
+	Sampling SamplingConfig
+
 	// Retention
 	DetailedRetention   time.Duration
+	HourlyRetention     time.Duration
 	AggregatedRetention time.Duration
 
 	// Endpoints to track (empty = all federation endpoints)
 	Endpoints []string
+
+	// Alerting on the collected statistics
+	Alerts AlertsConfig
 }
@@ -0,0 +1,37 @@
+package stats
+
+import "time"
+
+// Modes for storing client IPs
+const (
+	ClientIPModeFull     = "full"
+	ClientIPModeTruncate = "truncate"
+	ClientIPModeHash     = "hash"
+)
+
+// ClientIPModes holds all supported client IP modes.
+var ClientIPModes = []string{
+	ClientIPModeFull,
+	ClientIPModeTruncate,
+	ClientIPModeHash,
+}
+
+// PrivacyConfig holds configuration for the pseudonymisation of captured
+// client data.
+type PrivacyConfig struct {
+	// ClientIPMode is how client IPs are stored; one of ClientIPModes.
+	ClientIPMode string
+	// IPv4Prefix and IPv6Prefix are the prefix lengths that are kept of
+	// IPv4 and IPv6 addresses in ClientIPModeTruncate.
+	IPv4Prefix int
+	IPv6Prefix int
+	// HashKey is the secret from which the salts of ClientIPModeHash are
+	// derived. If empty, random salts are used, which are not shared between
+	// processes.
+	HashKey string
+	// SaltRotation is the period after which the salt of ClientIPModeHash
+	// changes; hashes of the same IP can only be linked within a period.
+	SaltRotation time.Duration
+	// DropQueryParams are query parameters that are not stored.
+	DropQueryParams []string
+}
@@ -0,0 +1,32 @@
+package stats
+
+import "time"
+
+// SamplingConfig holds configuration for the sampling of tracked requests.
+type SamplingConfig struct {
+	// Rate is the fraction of requests that is recorded, between 0 and 1.
+	// Zero is treated as 1.
+	Rate float64
+	// EndpointRates overrides Rate for single endpoints; keys are endpoint
+	// paths.
+	EndpointRates map[string]float64
+	// Adaptive lowers the rates while the buffer stays filled.
+	Adaptive AdaptiveSamplingConfig
+}
+
+// AdaptiveSamplingConfig holds configuration for the adaptive sampling.
+type AdaptiveSamplingConfig struct {
+	// Enabled controls whether the sample rates are adapted to the load.
+	Enabled bool
+	// Window is the period over which the buffer fill level is averaged
+	// before the rates are adapted.
+	Window time.Duration
+	// HighFill is the average fill level (0-1) above which the rates are
+	// halved.
+	HighFill float64
+	// LowFill is the average fill level (0-1) below which the rates are
+	// doubled again, up to the configured rates.
+	LowFill float64
+	// MinRate is the lowest rate the adaptive sampling goes down to.
+	MinRate float64
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+
+	"github.com/go-oidfed/lighthouse/storage"
+)
+
+// backupPassphraseEnv is the environment variable that can hold the
+// passphrase used to encrypt / decrypt private keys in a backup.
+const backupPassphraseEnv = "LH_BACKUP_PASSPHRASE"
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <file>",
+	Short: "Create a backup of the whole LightHouse database",
+	Long: `Create a backup of all LightHouse database tables, including subordinates, trust mark data,
+settings, authority hints, users, public keys, private keys stored in the database, and events.
+The backup is written as JSON; if the file name ends with .gz it is gzip compressed.
+
+Private keys are only encrypted if a passphrase is given with --passphrase-file or the
+` + backupPassphraseEnv + ` environment variable. Otherwise, the backup file must be protected accordingly.`,
+	Args: cobra.ExactArgs(1),
+	RunE: backup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore a backup into the LightHouse database",
+	Long: `Restore a backup created with 'lhcli backup' into the configured database.
+The database may use a different driver than the one the backup was created from.
+The whole restore runs in a single transaction. By default, the database must be empty;
+use --clear to delete all existing data first.`,
+	Args: cobra.ExactArgs(1),
+	RunE: restore,
+}
+
+var backupPassphraseFile string
+var backupWithoutStats bool
+var restoreClear bool
+var restoreYes bool
+
+func init() {
+	backupCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	backupCmd.Flags().StringVar(
+		&backupPassphraseFile, "passphrase-file", "",
+		"a file containing the passphrase used to encrypt private keys",
+	)
+	backupCmd.Flags().BoolVar(&backupWithoutStats, "without-stats", false, "do not include the statistics tables")
+	restoreCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	restoreCmd.Flags().StringVar(
+		&backupPassphraseFile, "passphrase-file", "",
+		"a file containing the passphrase used to decrypt private keys",
+	)
+	restoreCmd.Flags().BoolVar(&restoreClear, "clear", false, "delete all existing data before restoring")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "do not ask for confirmation when using --clear")
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+// backupPassphrase returns the passphrase from the passphrase file or the
+// environment.
+func backupPassphrase() (string, error) {
+	if backupPassphraseFile == "" {
+		return os.Getenv(backupPassphraseEnv), nil
+	}
+	data, err := fileutils.ReadFile(backupPassphraseFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read passphrase file")
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func backup(_ *cobra.Command, args []string) error {
+	passphrase, err := backupPassphrase()
+	if err != nil {
+		return err
+	}
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	b, err := storage.CreateBackup(
+		backends.DB, storage.BackupOptions{
+			Passphrase:   passphrase,
+			WithoutStats: backupWithoutStats,
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to create backup")
+	}
+
+	if err = writeBackupFile(args[0], b); err != nil {
+		return err
+	}
+
+	var rows int
+	for _, t := range b.Tables {
+		rows += len(t.Rows)
+	}
+	fmt.Printf("backup of %d table(s) with %d row(s) created successfully\n", len(b.Tables), rows)
+	if b.KeyEncryption == nil {
+		fmt.Println("WARNING: private keys are not encrypted; protect the backup file accordingly")
+	}
+	return nil
+}
+
+func restore(_ *cobra.Command, args []string) error {
+	passphrase, err := backupPassphrase()
+	if err != nil {
+		return err
+	}
+	b, err := readBackupFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	if restoreClear && !restoreYes &&
+		!promptApproval("This deletes all existing data in the database. Do you want to continue") {
+		return errors.New("restore aborted")
+	}
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	if err = storage.RestoreBackup(
+		backends.DB, b, storage.RestoreOptions{
+			Passphrase: passphrase,
+			Clear:      restoreClear,
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to restore backup")
+	}
+	fmt.Printf(
+		"backup from %s (LightHouse %s, %s) restored successfully\n",
+		b.CreatedAt.Format("2006-01-02 15:04:05 MST"), b.LighthouseVersion, b.Driver,
+	)
+	return nil
+}
+
+// writeBackupFile writes the backup to the file, gzip compressed if the file
+// name ends with .gz. The file is only kept if it was written completely.
+func writeBackupFile(path string, b *storage.Backup) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create backup file")
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(path)
+		}
+	}()
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	if err = json.NewEncoder(w).Encode(b); err != nil {
+		return errors.Wrap(err, "failed to write backup")
+	}
+	if gz != nil {
+		if err = gz.Close(); err != nil {
+			return errors.Wrap(err, "failed to write backup")
+		}
+	}
+	if err = f.Close(); err != nil {
+		return errors.Wrap(err, "failed to write backup")
+	}
+	return nil
+}
+
+// readBackupFile reads a backup written by writeBackupFile. Truncated or
+// corrupted gzip compressed backups are rejected.
+func readBackupFile(path string) (*storage.Backup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open backup file")
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decompress backup file")
+		}
+		r = gz
+	}
+	var b storage.Backup
+	if err = json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, errors.Wrap(err, "failed to parse backup file")
+	}
+	// The gzip checksum is only verified at the end of the stream
+	if _, err = io.Copy(io.Discard, r); err != nil {
+		return nil, errors.Wrap(err, "failed to decompress backup file")
+	}
+	if gz, ok := r.(*gzip.Reader); ok {
+		if err = gz.Close(); err != nil {
+			return nil, errors.Wrap(err, "failed to decompress backup file")
+		}
+	}
+	return &b, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-oidfed/lighthouse/storage"
+)
+
+func testBackup() *storage.Backup {
+	return &storage.Backup{
+		SchemaVersion: 1,
+		Driver:        "sqlite",
+		Tables: []storage.BackupTable{
+			{
+				Name: "users",
+				Rows: []map[string]json.RawMessage{{"username": json.RawMessage(`"admin"`)}},
+			},
+		},
+	}
+}
+
+func TestBackupFile(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"backup.json", "backup.json.gz"} {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+				path := filepath.Join(t.TempDir(), name)
+				if err := writeBackupFile(path, testBackup()); err != nil {
+					t.Fatalf("writeBackupFile failed: %v", err)
+				}
+				b, err := readBackupFile(path)
+				if err != nil {
+					t.Fatalf("readBackupFile failed: %v", err)
+				}
+				if len(b.Tables) != 1 || b.Tables[0].Name != "users" || len(b.Tables[0].Rows) != 1 {
+					t.Errorf("Unexpected backup: %+v", b)
+				}
+			},
+		)
+	}
+}
+
+func TestBackupFileTruncated(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "backup.json.gz")
+	if err := writeBackupFile(path, testBackup()); err != nil {
+		t.Fatalf("writeBackupFile failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	// Drop the gzip trailer, as an interrupted write would
+	if err = os.WriteFile(path, data[:len(data)-4], 0o600); err != nil {
+		t.Fatalf("Failed to truncate backup: %v", err)
+	}
+	if _, err = readBackupFile(path); err == nil {
+		t.Error("Expected error for truncated backup")
+	}
+}
+
+func TestBackupFileWriteError(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "backup.json.gz")
+	b := testBackup()
+	b.Tables[0].Rows[0]["username"] = json.RawMessage(`{`)
+	if err := writeBackupFile(path, b); err == nil {
+		t.Fatal("Expected error for invalid backup")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected partial backup file to be removed, got %v", err)
+	}
+}
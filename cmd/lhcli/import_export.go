@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+
+	"github.com/go-oidfed/lighthouse/storage"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+var subordinatesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export subordinates",
+	Long: `Export subordinates including their JWKS, metadata, metadata policies, constraints,
+additional claims, entity types, and labels to JSON or NDJSON.
+The output can be imported into another LightHouse with 'lhcli subordinates import'.`,
+	Args: cobra.NoArgs,
+	RunE: exportSubordinates,
+}
+var subordinatesImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import subordinates",
+	Long: `Import subordinates from a JSON or NDJSON file as created by 'lhcli subordinates export'.
+All subordinates are imported within a single transaction; if one fails, nothing is imported.
+--mode defines how already existing subordinates are handled:
+  fail:   abort the import (default)
+  skip:   keep the existing subordinate unchanged
+  upsert: replace the existing subordinate with the imported data`,
+	Args: cobra.ExactArgs(1),
+	RunE: importSubordinates,
+}
+
+var exportFormat string
+var exportOutput string
+var importMode string
+var importDryRun bool
+
+func init() {
+	subordinatesExportCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesExportCmd.Flags().StringVarP(&exportFormat, "format", "f", "json", "the output format: json or ndjson")
+	subordinatesExportCmd.Flags().StringVarP(
+		&exportOutput, "output", "o", "", "the file to write the export to; if not set it is written to stdout",
+	)
+	subordinatesExportCmd.Flags().StringVarP(
+		&labelSelector, "selector", "s", "", "only export subordinates matching this label selector",
+	)
+	subordinatesExportCmd.Flags().StringVar(&listStatus, "status", "", "only export subordinates with this status")
+	subordinatesExportCmd.Flags().StringArrayVarP(
+		&entityTypes, "entity_type", "t", []string{}, "only export subordinates with any of these entity types",
+	)
+	subordinatesImportCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesImportCmd.Flags().StringVarP(
+		&importMode, "mode", "m", string(storage.SubordinateImportModeFail),
+		"how to handle existing subordinates: fail, skip, or upsert",
+	)
+	subordinatesImportCmd.Flags().BoolVar(
+		&importDryRun, "dry-run", false, "only report what would be imported without changing anything",
+	)
+	subordinatesCmd.AddCommand(subordinatesExportCmd)
+	subordinatesCmd.AddCommand(subordinatesImportCmd)
+}
+
+func exportSubordinates(_ *cobra.Command, _ []string) error {
+	var ndjson bool
+	switch exportFormat {
+	case "json":
+	case "ndjson":
+		ndjson = true
+	default:
+		return errors.Errorf("invalid format '%s' (valid values: json, ndjson)", exportFormat)
+	}
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	sel, err := model.ParseLabelSelector(labelSelector)
+	if err != nil {
+		return err
+	}
+	filter := model.SubordinateFilter{
+		EntityTypes: entityTypes,
+		Labels:      sel,
+	}
+	if listStatus != "" {
+		status, err := model.ParseStatus(listStatus)
+		if err != nil {
+			return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
+		}
+		filter.Status = &status
+	}
+	records, err := storage.ExportSubordinates(subordinateStorage, filter)
+	if err != nil {
+		return errors.Wrap(err, "failed to export subordinates")
+	}
+
+	out := os.Stdout
+	if exportOutput != "" {
+		f, err := os.Create(exportOutput)
+		if err != nil {
+			return errors.Wrap(err, "failed to create output file")
+		}
+		defer f.Close()
+		out = f
+	}
+	if err = storage.EncodeSubordinateExports(out, records, ndjson); err != nil {
+		return errors.Wrap(err, "failed to write export")
+	}
+	if exportOutput != "" {
+		fmt.Printf("%d subordinate(s) exported successfully\n", len(records))
+	}
+	return nil
+}
+
+func importSubordinates(_ *cobra.Command, args []string) error {
+	mode, err := storage.ParseSubordinateImportMode(importMode)
+	if err != nil {
+		return err
+	}
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	data, err := fileutils.ReadFile(args[0])
+	if err != nil {
+		return errors.Wrap(err, "failed to read import file")
+	}
+	records, err := storage.DecodeSubordinateExports(data)
+	if err != nil {
+		return err
+	}
+	report, err := storage.ImportSubordinates(
+		backends, records, storage.SubordinateImportOptions{
+			Mode:   mode,
+			DryRun: importDryRun,
+			Actor:  "lhcli",
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to import subordinates")
+	}
+	if importDryRun {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, s := range []struct {
+		action   string
+		entities []string
+	}{
+		{"created", report.Created},
+		{"updated", report.Updated},
+		{"skipped", report.Skipped},
+	} {
+		if len(s.entities) == 0 {
+			continue
+		}
+		fmt.Printf("%s:\n  %s\n", s.action, strings.Join(s.entities, "\n  "))
+	}
+	fmt.Printf(
+		"%d subordinate(s) created, %d updated, %d skipped\n",
+		len(report.Created), len(report.Updated), len(report.Skipped),
+	)
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+
+	"github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+
+	"github.com/go-oidfed/lighthouse/internal/utils"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <entity_id|->",
+	Short: "Inspect an entity configuration and its trust chains",
+	Long: `Inspect an entity configuration and its trust chains.
+
+The entity configuration of the passed entity is fetched and its signature is verified, either with the keys
+from --jwks or with the keys contained in the entity configuration itself. Its claims and trust marks are
+printed. For each trust anchor given with --trust-anchor, the trust chains from the entity to that trust anchor
+are resolved; for each chain the path, the expiration, the trust marks that are valid in that federation, and
+the resolved metadata after applying the metadata policies are printed.
+
+A trust anchor is given by its entity ID. By default, its entity configuration is fetched and trusted as is;
+to pin its keys use the form <entity_id>=<jwks_file>.
+
+If '-' is passed instead of an entity ID, an entity configuration or entity statement JWT is read from stdin
+and inspected offline. In this case no network requests are made and trust chains are not resolved.`,
+	Args: cobra.ExactArgs(1),
+	RunE: inspect,
+}
+
+var inspectTrustAnchors []string
+
+func init() {
+	inspectCmd.Flags().StringArrayVarP(
+		&inspectTrustAnchors, "trust-anchor", "a", []string{},
+		"trust anchor to resolve trust chains to, in the form <entity_id> or <entity_id>=<jwks_file>",
+	)
+	inspectCmd.Flags().StringArrayVarP(
+		&entityTypes, "entity_type", "t", []string{}, "only resolve trust chains for these entity types",
+	)
+	inspectCmd.Flags().StringVarP(
+		&jwksFile, "jwks", "k",
+		"", "a file containing the entity's public key("+
+			"s) in the jwks format; used to verify that the entity's entity"+
+			" configuration is signed with a key from this set.",
+	)
+	inspectCmd.Flags().BoolVar(&useJSONOutput, "json", false, "output as JSON")
+	rootCmd.AddCommand(inspectCmd)
+}
+
+// inspectReport is the result of an inspection
+type inspectReport struct {
+	Statement   inspectStatement   `json:"entity_statement"`
+	TrustMarks  []inspectTrustMark `json:"trust_marks,omitempty"`
+	TrustChains []inspectChain     `json:"trust_chains,omitempty"`
+}
+
+type inspectStatement struct {
+	Signature string                        `json:"signature"`
+	IssuedAt  string                        `json:"issued_at"`
+	ExpiresAt string                        `json:"expires_at"`
+	TimeValid bool                          `json:"time_valid"`
+	Claims    oidfed.EntityStatementPayload `json:"claims"`
+}
+
+type inspectTrustMark struct {
+	TrustMarkType string            `json:"trust_mark_type"`
+	Error         string            `json:"error,omitempty"`
+	Claims        *oidfed.TrustMark `json:"claims,omitempty"`
+}
+
+type inspectChain struct {
+	TrustAnchor     string           `json:"trust_anchor"`
+	Path            []string         `json:"path"`
+	ExpiresAt       string           `json:"expires_at"`
+	ValidTrustMarks []string         `json:"valid_trust_marks,omitempty"`
+	MetadataError   string           `json:"metadata_error,omitempty"`
+	Metadata        *oidfed.Metadata `json:"metadata,omitempty"`
+}
+
+func inspect(cmd *cobra.Command, args []string) error {
+	var entityJWKS *jwx.JWKS
+	if jwksFile != "" {
+		jwks, err := readJWKSFile(jwksFile)
+		if err != nil {
+			return err
+		}
+		entityJWKS = &jwks
+	}
+
+	var statement *oidfed.EntityStatement
+	if args[0] == "-" {
+		if len(inspectTrustAnchors) > 0 {
+			return errors.New("trust chains cannot be resolved for a JWT read from stdin")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "failed to read JWT from stdin")
+		}
+		statement, err = oidfed.ParseEntityStatement(bytes.TrimSpace(data))
+		if err != nil {
+			return errors.Wrap(err, "failed to parse entity statement")
+		}
+	} else {
+		var err error
+		statement, err = oidfed.GetEntityConfiguration(args[0])
+		if err != nil {
+			return errors.Wrap(err, "failed to obtain entity configuration")
+		}
+	}
+
+	report := inspectReport{
+		Statement: inspectStatement{
+			Signature: verifyInspectedStatement(statement, entityJWKS),
+			IssuedAt:  statement.IssuedAt.Format(time.RFC3339),
+			ExpiresAt: statement.ExpiresAt.Format(time.RFC3339),
+			TimeValid: statement.TimeValid(),
+			Claims:    statement.EntityStatementPayload,
+		},
+	}
+	for _, info := range statement.TrustMarks {
+		tm := inspectTrustMark{TrustMarkType: info.TrustMarkType}
+		if parsed, err := info.TrustMark(); err != nil {
+			tm.Error = err.Error()
+		} else {
+			tm.Claims = parsed
+		}
+		report.TrustMarks = append(report.TrustMarks, tm)
+	}
+
+	if len(inspectTrustAnchors) > 0 {
+		anchors, err := parseInspectTrustAnchors(inspectTrustAnchors)
+		if err != nil {
+			return err
+		}
+		report.TrustChains = resolveInspectChains(statement, anchors)
+	}
+	return printInspectReport(cmd.OutOrStdout(), report)
+}
+
+// verifyInspectedStatement verifies the signature of the entity statement and
+// returns a description of the result. If no keys are passed, an entity
+// configuration is verified with its own keys.
+func verifyInspectedStatement(statement *oidfed.EntityStatement, keys *jwx.JWKS) string {
+	source := "keys from --jwks"
+	if keys == nil {
+		if statement.Issuer != statement.Subject {
+			return "not verified (entity statement issued by a superior; use --jwks to verify)"
+		}
+		keys = &statement.JWKS
+		source = "keys from the entity configuration"
+	}
+	if keys.Set == nil || !statement.Verify(*keys) {
+		return "invalid (" + source + ")"
+	}
+	return "valid (" + source + ")"
+}
+
+func readJWKSFile(file string) (jwx.JWKS, error) {
+	var jwks jwx.JWKS
+	data, err := fileutils.ReadFile(file)
+	if err != nil {
+		return jwks, errors.Wrap(err, "failed to read jwks file")
+	}
+	if err = json.Unmarshal(data, &jwks); err != nil {
+		return jwks, errors.Wrap(err, "failed to unmarshal jwks file")
+	}
+	return jwks, nil
+}
+
+func parseInspectTrustAnchors(args []string) (oidfed.TrustAnchors, error) {
+	anchors := make(oidfed.TrustAnchors, len(args))
+	for i, arg := range args {
+		entityID, file, hasJWKS := strings.Cut(arg, "=")
+		anchors[i].EntityID = entityID
+		if hasJWKS {
+			jwks, err := readJWKSFile(file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "trust anchor '%s'", entityID)
+			}
+			anchors[i].JWKS = jwks
+		}
+	}
+	return anchors, nil
+}
+
+// resolveInspectChains resolves the trust chains from the inspected entity to
+// the passed trust anchors and applies the metadata policies of each chain.
+func resolveInspectChains(statement *oidfed.EntityStatement, anchors oidfed.TrustAnchors) []inspectChain {
+	resolver := oidfed.TrustResolver{
+		TrustAnchors:   anchors,
+		StartingEntity: statement.Subject,
+		Types:          entityTypes,
+	}
+	chains := resolver.ResolveToValidChainsWithoutVerifyingMetadata()
+	results := make([]inspectChain, 0, len(chains))
+	for _, chain := range chains {
+		ta := chain[len(chain)-1]
+		result := inspectChain{
+			TrustAnchor: ta.Subject,
+			Path:        trustChainPath(chain),
+			ExpiresAt:   chain.ExpiresAt().Format(time.RFC3339),
+		}
+		for i := range statement.TrustMarks {
+			info := statement.TrustMarks[i]
+			if err := info.VerifyFederation(&ta.EntityStatementPayload); err == nil {
+				result.ValidTrustMarks = append(result.ValidTrustMarks, info.TrustMarkType)
+			}
+		}
+		metadata, err := chain.Metadata()
+		if err != nil {
+			result.MetadataError = err.Error()
+		} else {
+			result.Metadata = metadata
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// trustChainPath returns the entity IDs along a trust chain, starting with the
+// subject and ending with the trust anchor.
+func trustChainPath(chain oidfed.TrustChain) []string {
+	path := []string{chain[0].Subject}
+	for _, stmt := range chain[1:] {
+		if stmt.Issuer != path[len(path)-1] {
+			path = append(path, stmt.Issuer)
+		}
+	}
+	return path
+}
+
+// printInspectReport writes the report as JSON or, by default, as YAML to w;
+// the YAML is derived from the JSON representation, so both use the same
+// claim names.
+func printInspectReport(w io.Writer, report inspectReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal report")
+	}
+	if useJSONOutput {
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	out, err := utils.JSONToBlockYAML(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert report")
+	}
+	_, err = w.Write(out)
+	return err
+}
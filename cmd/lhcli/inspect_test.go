@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/oidfedconst"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/spf13/cobra"
+)
+
+// stubEntity is an entity of a stubFederation.
+type stubEntity struct {
+	id     string
+	jwks   jwx.JWKS
+	signer *jwx.EntityStatementSigner
+	// payload is the entity configuration payload without the time and key
+	// claims
+	payload oidfed.EntityStatementPayload
+	// subordinates are the JWKS of the subordinates of an intermediate or
+	// trust anchor
+	subordinates map[string]jwx.JWKS
+}
+
+// stubFederation serves the entity configurations and fetch endpoints of
+// a set of entities from an httptest server; the entity IDs are paths on the
+// server.
+type stubFederation struct {
+	srv      *httptest.Server
+	entities map[string]*stubEntity
+}
+
+func newStubFederation(t *testing.T) *stubFederation {
+	t.Helper()
+	f := &stubFederation{entities: make(map[string]*stubEntity)}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func newStubSigner(t *testing.T) (*jwx.EntityStatementSigner, jwx.JWKS) {
+	t.Helper()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	jwks, err := jwx.KeyToJWKS(sk.Public(), jwa.ES256())
+	if err != nil {
+		t.Fatalf("Failed to create JWKS: %v", err)
+	}
+	return jwx.NewEntityStatementSigner(jwx.NewSingleKeyVersatileSigner(sk, jwa.ES256())), jwks
+}
+
+// add adds an entity with the passed name below the passed superiors, which
+// must have been added before.
+func (f *stubFederation) add(t *testing.T, name string, metadata *oidfed.Metadata, superiors ...string) *stubEntity {
+	t.Helper()
+	signer, jwks := newStubSigner(t)
+	e := &stubEntity{
+		id:     f.srv.URL + "/" + name,
+		jwks:   jwks,
+		signer: signer,
+		payload: oidfed.EntityStatementPayload{
+			Metadata: metadata,
+		},
+		subordinates: make(map[string]jwx.JWKS),
+	}
+	if e.payload.Metadata == nil {
+		e.payload.Metadata = &oidfed.Metadata{}
+	}
+	if e.payload.Metadata.FederationEntity == nil {
+		e.payload.Metadata.FederationEntity = &oidfed.FederationEntityMetadata{}
+	}
+	e.payload.Metadata.FederationEntity.FederationFetchEndpoint = e.id + "/fetch"
+	for _, sup := range superiors {
+		superior := f.entities[sup]
+		superior.subordinates[e.id] = e.jwks
+		e.payload.AuthorityHints = append(e.payload.AuthorityHints, superior.id)
+	}
+	f.entities[e.id] = e
+	return e
+}
+
+func (f *stubFederation) handle(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	if id, ok := strings.CutSuffix(f.srv.URL+r.URL.Path, oidfedconst.FederationSuffix); ok {
+		e := f.entities[id]
+		if e == nil {
+			http.NotFound(w, r)
+			return
+		}
+		payload := e.payload
+		payload.Issuer, payload.Subject = e.id, e.id
+		payload.IssuedAt = unixtime.Unixtime{Time: now}
+		payload.ExpiresAt = unixtime.Unixtime{Time: now.Add(time.Hour)}
+		payload.JWKS = e.jwks
+		f.write(w, e.signer, payload)
+		return
+	}
+	if id, ok := strings.CutSuffix(f.srv.URL+r.URL.Path, "/fetch"); ok {
+		e := f.entities[id]
+		sub := r.URL.Query().Get("sub")
+		if e == nil {
+			http.NotFound(w, r)
+			return
+		}
+		jwks, ok := e.subordinates[sub]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f.write(
+			w, e.signer, oidfed.EntityStatementPayload{
+				Issuer:    e.id,
+				Subject:   sub,
+				IssuedAt:  unixtime.Unixtime{Time: now},
+				ExpiresAt: unixtime.Unixtime{Time: now.Add(time.Hour)},
+				JWKS:      jwks,
+			},
+		)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (*stubFederation) write(w http.ResponseWriter, signer *jwx.EntityStatementSigner, payload oidfed.EntityStatementPayload) {
+	jwt, err := signer.JWT(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/entity-statement+jwt")
+	_, _ = w.Write(jwt)
+}
+
+// writeJWKSFile writes the JWKS to a temporary file and returns its path.
+func writeJWKSFile(t *testing.T, jwks jwx.JWKS) string {
+	t.Helper()
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("Failed to marshal JWKS: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+	return file
+}
+
+// runInspect runs the inspect command with JSON output and returns the
+// parsed report. The command uses package level flag variables, so tests
+// calling it must not run in parallel.
+func runInspect(t *testing.T, entityID, jwks string, anchors ...string) inspectReport {
+	t.Helper()
+	useJSONOutput, jwksFile, inspectTrustAnchors, entityTypes = true, jwks, anchors, nil
+	t.Cleanup(
+		func() {
+			useJSONOutput, jwksFile, inspectTrustAnchors = false, "", nil
+		},
+	)
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	if err := inspect(cmd, []string{entityID}); err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	var report inspectReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse report: %v\n%s", err, out.String())
+	}
+	return report
+}
+
+func TestInspect(t *testing.T) {
+	t.Run(
+		"TrustChain", func(t *testing.T) {
+			fed := newStubFederation(t)
+			ta := fed.add(t, "ta", nil)
+			ia := fed.add(t, "ia", nil, ta.id)
+			rp := fed.add(
+				t, "rp", &oidfed.Metadata{
+					RelyingParty: &oidfed.OpenIDRelyingPartyMetadata{
+						ClientName: "Stub RP",
+					},
+				}, ia.id,
+			)
+
+			report := runInspect(t, rp.id, "", ta.id)
+
+			if want := "valid (keys from the entity configuration)"; report.Statement.Signature != want {
+				t.Errorf("Expected signature %q, got %q", want, report.Statement.Signature)
+			}
+			if !report.Statement.TimeValid {
+				t.Error("Expected entity configuration to be time valid")
+			}
+			if report.Statement.Claims.Subject != rp.id {
+				t.Errorf("Expected subject %s, got %s", rp.id, report.Statement.Claims.Subject)
+			}
+			if len(report.TrustChains) != 1 {
+				t.Fatalf("Expected 1 trust chain, got %+v", report.TrustChains)
+			}
+			chain := report.TrustChains[0]
+			if chain.TrustAnchor != ta.id {
+				t.Errorf("Expected trust anchor %s, got %s", ta.id, chain.TrustAnchor)
+			}
+			if want := []string{rp.id, ia.id, ta.id}; strings.Join(chain.Path, " ") != strings.Join(want, " ") {
+				t.Errorf("Expected path %v, got %v", want, chain.Path)
+			}
+			if chain.MetadataError != "" || chain.Metadata == nil || chain.Metadata.RelyingParty == nil ||
+				chain.Metadata.RelyingParty.ClientName != "Stub RP" {
+				t.Errorf("Expected resolved RP metadata, got %+v (error %q)", chain.Metadata, chain.MetadataError)
+			}
+		},
+	)
+
+	t.Run(
+		"PinnedTrustAnchorKeys", func(t *testing.T) {
+			fed := newStubFederation(t)
+			ta := fed.add(t, "ta", nil)
+			rp := fed.add(t, "rp", nil, ta.id)
+
+			report := runInspect(t, rp.id, "", ta.id+"="+writeJWKSFile(t, ta.jwks))
+			if len(report.TrustChains) != 1 {
+				t.Errorf("Expected 1 trust chain with the pinned keys, got %+v", report.TrustChains)
+			}
+
+			// Resolved chains are cached by the library, so use a fresh
+			// federation with different entity IDs
+			fed = newStubFederation(t)
+			ta = fed.add(t, "ta", nil)
+			rp = fed.add(t, "rp", nil, ta.id)
+			_, otherJWKS := newStubSigner(t)
+			report = runInspect(t, rp.id, "", ta.id+"="+writeJWKSFile(t, otherJWKS))
+			if len(report.TrustChains) != 0 {
+				t.Errorf("Expected no trust chain with wrong pinned keys, got %+v", report.TrustChains)
+			}
+		},
+	)
+
+	t.Run(
+		"SignatureFailure", func(t *testing.T) {
+			fed := newStubFederation(t)
+			ta := fed.add(t, "ta", nil)
+			rp := fed.add(t, "rp", nil, ta.id)
+			// The entity configuration is signed with a key that is not in its JWKS
+			rp.signer, _ = newStubSigner(t)
+
+			report := runInspect(t, rp.id, "", ta.id)
+			if want := "invalid (keys from the entity configuration)"; report.Statement.Signature != want {
+				t.Errorf("Expected signature %q, got %q", want, report.Statement.Signature)
+			}
+			if len(report.TrustChains) != 0 {
+				t.Errorf("Expected no trust chain for an invalid signature, got %+v", report.TrustChains)
+			}
+		},
+	)
+
+	t.Run(
+		"JWKSFlag", func(t *testing.T) {
+			fed := newStubFederation(t)
+			rp := fed.add(t, "rp", nil)
+
+			report := runInspect(t, rp.id, writeJWKSFile(t, rp.jwks))
+			if want := "valid (keys from --jwks)"; report.Statement.Signature != want {
+				t.Errorf("Expected signature %q, got %q", want, report.Statement.Signature)
+			}
+
+			_, otherJWKS := newStubSigner(t)
+			report = runInspect(t, rp.id, writeJWKSFile(t, otherJWKS))
+			if want := "invalid (keys from --jwks)"; report.Statement.Signature != want {
+				t.Errorf("Expected signature %q, got %q", want, report.Statement.Signature)
+			}
+		},
+	)
+}
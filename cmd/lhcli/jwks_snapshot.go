@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/oidfedconst"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+
+	"github.com/go-oidfed/lib"
+)
+
+var (
+	snapshotFormat   string
+	snapshotLifetime time.Duration
+	snapshotOutput   string
+	snapshotEntityID string
+)
+
+var verifySnapshotCmd = &cobra.Command{
+	Use:   "verify-snapshot <snapshot_file>",
+	Short: "Verify a signed JWKS snapshot against a live instance",
+	Long: `Verify a signed JWKS snapshot, as exported with 'lhcli remote keys snapshot', against the live
+entity it was exported from.
+
+The entity configuration of the snapshot's issuer (or of --entity-id) is fetched and the snapshot's
+signature is verified with the keys published in it. The command also checks that the snapshot is not
+expired and that it contains all keys that the entity currently publishes; keys missing from the snapshot
+cannot be verified by relying parties that were configured with it. Keys in the snapshot that are no longer
+published are reported as stale.
+
+The command exits with an error if the snapshot is invalid or incomplete.`,
+	Args: cobra.ExactArgs(1),
+	RunE: verifySnapshot,
+}
+
+func init() {
+	remoteKeysSnapshotCmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export a signed JWKS snapshot for out of band distribution",
+		Long: `Export a snapshot of the current and upcoming signing keys, e.g. to configure this entity as trust
+anchor in relying parties.
+
+By default the snapshot is a JWT of type jwk-set+jwt signed with the current signing key; it contains the
+entity ID, iat and exp, and can be checked with 'lhcli verify-snapshot'. With --format a ready-to-use trust
+anchor configuration snippet is exported instead:
+
+  go-oidfed   YAML trust_anchors configuration for the go-oidfed library
+  fedservice  JSON trust_anchors configuration for the idpy fedservice library
+  nimbus      Java snippet for the Nimbus OpenID Connect SDK`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			query := url.Values{}
+			if snapshotFormat != "" {
+				query.Set("format", snapshotFormat)
+			}
+			if snapshotLifetime > 0 {
+				query.Set("lifetime", strconv.FormatInt(int64(snapshotLifetime/time.Second), 10))
+			}
+			var raw rawResponse
+			if _, err := remote.do(http.MethodGet, "/entity-configuration/jwks/snapshot", query, nil, &raw); err != nil {
+				return errors.Wrap(err, "failed to export snapshot")
+			}
+			if snapshotOutput == "" {
+				fmt.Println(string(bytes.TrimSpace(raw)))
+				return nil
+			}
+			if err := os.WriteFile(snapshotOutput, raw, 0o644); err != nil {
+				return errors.Wrap(err, "failed to write snapshot")
+			}
+			fmt.Printf("snapshot written to %s\n", snapshotOutput)
+			return nil
+		},
+	}
+	remoteKeysSnapshotCmd.Flags().StringVar(
+		&snapshotFormat, "format", "", "output format: jwt (default), go-oidfed, fedservice, or nimbus",
+	)
+	remoteKeysSnapshotCmd.Flags().DurationVar(
+		&snapshotLifetime, "lifetime", 0, "lifetime of the snapshot, e.g. 720h (default 30 days)",
+	)
+	remoteKeysSnapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "write the snapshot to this file")
+	remoteKeysCmd.AddCommand(remoteKeysSnapshotCmd)
+
+	verifySnapshotCmd.Flags().StringVar(
+		&snapshotEntityID, "entity-id", "", "entity ID of the live instance (default: the snapshot's issuer)",
+	)
+	rootCmd.AddCommand(verifySnapshotCmd)
+}
+
+// snapshotClaims are the claims of a JWKS snapshot besides its keys.
+type snapshotClaims struct {
+	Issuer    string            `json:"iss"`
+	Subject   string            `json:"sub"`
+	IssuedAt  unixtime.Unixtime `json:"iat"`
+	ExpiresAt unixtime.Unixtime `json:"exp"`
+}
+
+func verifySnapshot(_ *cobra.Command, args []string) error {
+	data, err := fileutils.ReadFile(args[0])
+	if err != nil {
+		return errors.Wrap(err, "failed to read snapshot")
+	}
+	data = bytes.TrimSpace(data)
+	msg, err := jws.Parse(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse snapshot; only the jwt format can be verified")
+	}
+	if typ, ok := msg.Signatures()[0].ProtectedHeaders().Type(); !ok || typ != oidfedconst.JWTTypeJWKS {
+		return errors.Errorf("snapshot has typ '%s', expected '%s'", typ, oidfedconst.JWTTypeJWKS)
+	}
+	var claims snapshotClaims
+	if err = json.Unmarshal(msg.Payload(), &claims); err != nil {
+		return errors.Wrap(err, "failed to parse snapshot claims")
+	}
+	var keys jwx.JWKS
+	if err = json.Unmarshal(msg.Payload(), &keys); err != nil {
+		return errors.Wrap(err, "failed to parse snapshot keys")
+	}
+
+	entityID := firstNonEmpty(snapshotEntityID, claims.Issuer)
+	if entityID == "" {
+		return errors.New("snapshot has no issuer; use --entity-id")
+	}
+	if claims.Issuer != entityID {
+		return errors.Errorf("snapshot was issued by '%s', not by '%s'", claims.Issuer, entityID)
+	}
+	ec, err := oidfed.GetEntityConfiguration(entityID)
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain entity configuration")
+	}
+	if ec.JWKS.Set == nil || !ec.Verify(ec.JWKS) {
+		return errors.New("entity configuration of the live instance is not validly signed")
+	}
+
+	fmt.Printf("issuer:     %s\n", claims.Issuer)
+	fmt.Printf("issued at:  %s\n", claims.IssuedAt.Format(time.RFC3339))
+	fmt.Printf("expires at: %s\n", claims.ExpiresAt.Format(time.RFC3339))
+
+	var problems int
+	if _, err = jws.Verify(data, jws.WithKeySet(ec.JWKS.Set, jws.WithInferAlgorithmFromKey(true))); err != nil {
+		fmt.Println("signature:  invalid (not signed with a key of the live instance)")
+		problems++
+	} else {
+		fmt.Println("signature:  valid (keys of the live instance)")
+	}
+	if err = unixtime.VerifyTime(&claims.IssuedAt, &claims.ExpiresAt); err != nil {
+		fmt.Printf("time:       invalid (%s)\n", err)
+		problems++
+	} else {
+		fmt.Println("time:       valid")
+	}
+
+	snapshotKIDs := jwksKIDs(keys)
+	liveKIDs := jwksKIDs(ec.JWKS)
+	for kid := range liveKIDs {
+		if !snapshotKIDs[kid] {
+			fmt.Printf("missing key: %s is published by the live instance but not in the snapshot\n", kid)
+			problems++
+		}
+	}
+	for kid := range snapshotKIDs {
+		if !liveKIDs[kid] {
+			fmt.Printf("stale key:   %s is in the snapshot but no longer published\n", kid)
+		}
+	}
+	if problems > 0 {
+		return errors.Errorf("snapshot verification failed with %d problem(s)", problems)
+	}
+	fmt.Println("snapshot is valid and up to date")
+	return nil
+}
+
+// jwksKIDs returns the key IDs of the keys in the passed JWKS.
+func jwksKIDs(set jwx.JWKS) map[string]bool {
+	kids := make(map[string]bool)
+	if set.Set == nil {
+		return kids
+	}
+	for i := 0; i < set.Len(); i++ {
+		k, ok := set.Key(i)
+		if !ok {
+			continue
+		}
+		if kid, ok := k.KeyID(); ok {
+			kids[kid] = true
+		}
+	}
+	return kids
+}
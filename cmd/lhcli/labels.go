@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+
+	"github.com/go-oidfed/lib"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+var subordinatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List subordinates",
+	Long:  `List subordinates, optionally filtered by status, entity type and a label selector`,
+	Args:  cobra.NoArgs,
+	RunE:  listSubordinates,
+}
+var subordinatesLabelsCmd = &cobra.Command{
+	Use:   "labels <entity_id> [key=value|key-]...",
+	Short: "Show or change the labels of a subordinate",
+	Long: `Show or change the labels of a subordinate.
+Without further arguments the labels are printed.
+Arguments of the form key=value set a label, arguments of the form key- remove a label.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: subordinateLabels,
+}
+var subordinatesSetPolicyCmd = &cobra.Command{
+	Use:   "set-policy <metadata_policy_file>",
+	Short: "Set the metadata policy of all subordinates matching a label selector",
+	Long: `Set the metadata policy of all subordinates matching the label selector given with --selector.
+The file must contain the metadata policy in JSON format.`,
+	Args: cobra.ExactArgs(1),
+	RunE: setSubordinatesPolicy,
+}
+
+var labelArgs []string
+var labelSelector string
+var listStatus string
+
+func init() {
+	subordinatesListCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesListCmd.Flags().StringVarP(&labelSelector, "selector", "s", "", "only list subordinates matching this label selector")
+	subordinatesListCmd.Flags().StringVar(&listStatus, "status", "", "only list subordinates with this status")
+	subordinatesListCmd.Flags().StringArrayVarP(&entityTypes, "entity_type", "t", []string{}, "only list subordinates with any of these entity types")
+	subordinatesListCmd.Flags().BoolVar(
+		&onlyIDs, "only-ids", false, "if set only the entity ids are printed, not all subordinate info",
+	)
+	subordinatesLabelsCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesSetPolicyCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesSetPolicyCmd.Flags().StringVarP(
+		&labelSelector, "selector", "s", "", "set the policy for all subordinates matching this label selector",
+	)
+	_ = subordinatesSetPolicyCmd.MarkFlagRequired("selector")
+	subordinatesCmd.AddCommand(subordinatesListCmd)
+	subordinatesCmd.AddCommand(subordinatesLabelsCmd)
+	subordinatesCmd.AddCommand(subordinatesSetPolicyCmd)
+}
+
+// argsWithOrWithoutSelector returns a cobra.PositionalArgs that requires n
+// arguments, or nWithSelector arguments if the --selector flag is used.
+func argsWithOrWithoutSelector(n, nWithSelector int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if f := cmd.Flags().Lookup("selector"); f != nil && f.Changed {
+			return cobra.ExactArgs(nWithSelector)(cmd, args)
+		}
+		return cobra.ExactArgs(n)(cmd, args)
+	}
+}
+
+// parseLabelArgs parses labels given in the form key=value.
+func parseLabelArgs(args []string) (map[string]string, error) {
+	labels := make(map[string]string, len(args))
+	for _, a := range args {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid label %q: must be of the form key=value", a)
+		}
+		labels[k] = v
+	}
+	if err := model.ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// forEachSelectedSubordinate runs fn for all subordinates matching the
+// selector within a single transaction and prints the affected subordinates.
+func forEachSelectedSubordinate(
+	selector, action string, fn func(tx *model.Backends, info model.BasicSubordinateInfo) error,
+) error {
+	sel, err := model.ParseLabelSelector(selector)
+	if err != nil {
+		return err
+	}
+	if sel.Empty() {
+		return errors.New("label selector must not be empty")
+	}
+	var affected []string
+	err = backends.InTransaction(
+		func(tx *model.Backends) error {
+			infos, err := tx.Subordinates.Find(model.SubordinateFilter{Labels: sel})
+			if err != nil {
+				return err
+			}
+			for _, info := range infos {
+				if err = fn(tx, info); err != nil {
+					return errors.Wrapf(err, "failed for subordinate '%s'", info.EntityID)
+				}
+				affected = append(affected, info.EntityID)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+	for _, entityID := range affected {
+		fmt.Println(entityID)
+	}
+	fmt.Printf("%d subordinate(s) %s successfully\n", len(affected), action)
+	return nil
+}
+
+// withSubordinate runs fn for the subordinate with the passed entity id
+// within a single transaction.
+func withSubordinate(entityID string, fn func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error) error {
+	return backends.InTransaction(
+		func(tx *model.Backends) error {
+			info, err := tx.Subordinates.Get(entityID)
+			if err != nil {
+				return err
+			}
+			if info == nil {
+				return errors.Errorf("subordinate '%s' not found", entityID)
+			}
+			return fn(tx, info)
+		},
+	)
+}
+
+func listSubordinates(_ *cobra.Command, _ []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	sel, err := model.ParseLabelSelector(labelSelector)
+	if err != nil {
+		return err
+	}
+	filter := model.SubordinateFilter{
+		EntityTypes: entityTypes,
+		Labels:      sel,
+	}
+	if listStatus != "" {
+		status, err := model.ParseStatus(listStatus)
+		if err != nil {
+			return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
+		}
+		filter.Status = &status
+	}
+	infos, err := subordinateStorage.Find(filter)
+	if err != nil {
+		return errors.Wrap(err, "failed to list subordinates")
+	}
+	for _, info := range infos {
+		if onlyIDs {
+			fmt.Println(info.EntityID)
+			continue
+		}
+		data, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+	return nil
+}
+
+func subordinateLabels(_ *cobra.Command, args []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	entityID := args[0]
+	info, err := subordinateStorage.Get(entityID)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return errors.Errorf("subordinate '%s' not found", entityID)
+	}
+	labels := info.Labels.Map()
+	if len(args) == 1 {
+		data, err := json.MarshalIndent(labels, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var set []string
+	for _, a := range args[1:] {
+		if k, ok := strings.CutSuffix(a, "-"); ok && !strings.Contains(a, "=") {
+			delete(labels, k)
+			continue
+		}
+		set = append(set, a)
+	}
+	newLabels, err := parseLabelArgs(set)
+	if err != nil {
+		return err
+	}
+	for k, v := range newLabels {
+		labels[k] = v
+	}
+	info.Labels = model.NewSubordinateLabels(labels)
+	if err = subordinateStorage.Update(entityID, *info); err != nil {
+		return errors.Wrap(err, "failed to update subordinate labels")
+	}
+	fmt.Println("subordinate labels updated successfully")
+	return nil
+}
+
+func setSubordinatesPolicy(_ *cobra.Command, args []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	data, err := fileutils.ReadFile(args[0])
+	if err != nil {
+		return errors.Wrap(err, "failed to read metadata policy file")
+	}
+	var policy oidfed.MetadataPolicies
+	if err = json.Unmarshal(data, &policy); err != nil {
+		return errors.Wrap(err, "failed to unmarshal metadata policy file")
+	}
+	return forEachSelectedSubordinate(
+		labelSelector, "updated", func(tx *model.Backends, basic model.BasicSubordinateInfo) error {
+			info, err := tx.Subordinates.Get(basic.EntityID)
+			if err != nil {
+				return err
+			}
+			p := policy
+			info.MetadataPolicy = &p
+			return tx.Subordinates.Update(info.EntityID, *info)
+		},
+	)
+}
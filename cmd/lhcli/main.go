@@ -17,6 +17,7 @@ var rootCmd = &cobra.Command{
 }
 
 var configFile string
+var backends model.Backends
 var subordinateStorage model.SubordinateStorageBackend
 var trustMarkedEntitiesStorage model.TrustMarkedEntitiesStorageBackend
 var trustMarkSpecsStorage model.TrustMarkSpecStore
@@ -32,6 +33,7 @@ func loadConfig() error {
 	if err != nil {
 		return err
 	}
+	backends = backs
 	subordinateStorage = backs.Subordinates
 	trustMarkedEntitiesStorage = backs.TrustMarks
 	trustMarkSpecsStorage = backs.TrustMarkSpecs
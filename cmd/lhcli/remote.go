@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+)
+
+const adminAPIBasePath = "/api/v1/admin"
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage a running LightHouse through its admin API",
+	Long: `Manage a running LightHouse through its admin API instead of writing directly to the storage backend.
+Changes made this way go through the admin API's validation, cache invalidation, and event recording.
+
+The admin API is selected with --url (or LH_ADMIN_URL). Credentials for HTTP Basic authentication are
+given with --user (or LH_ADMIN_USER) and --password-file (or LH_ADMIN_PASSWORD). The admin API only
+supports HTTP Basic authentication with the credentials of an admin user.`,
+	PersistentPreRunE: initRemoteClient,
+}
+
+var (
+	remoteURL          string
+	remoteUser         string
+	remotePasswordFile string
+	remoteTimeout      time.Duration
+)
+
+var remote *remoteClient
+
+func init() {
+	remoteCmd.PersistentFlags().StringVar(
+		&remoteURL, "url", "", "base URL of the LightHouse admin API, e.g. https://lighthouse.example.org:8081",
+	)
+	remoteCmd.PersistentFlags().StringVar(&remoteUser, "user", "", "username for HTTP Basic authentication")
+	remoteCmd.PersistentFlags().StringVar(
+		&remotePasswordFile, "password-file", "", "file containing the password for HTTP Basic authentication",
+	)
+	remoteCmd.PersistentFlags().DurationVar(&remoteTimeout, "timeout", 30*time.Second, "timeout for API requests")
+	rootCmd.AddCommand(remoteCmd)
+}
+
+// remoteClient is a minimal client for the LightHouse admin API.
+type remoteClient struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+}
+
+// remoteAPIError is the error returned by the admin API.
+type remoteAPIError struct {
+	StatusCode       int    `json:"-"`
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e remoteAPIError) Error() string {
+	if e.ErrorDescription == "" {
+		return fmt.Sprintf("admin API returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("admin API returned %d: %s: %s", e.StatusCode, e.ErrorCode, e.ErrorDescription)
+}
+
+func initRemoteClient(_ *cobra.Command, _ []string) error {
+	password, err := readSecret(remotePasswordFile, "LH_ADMIN_PASSWORD")
+	if err != nil {
+		return errors.Wrap(err, "failed to read password")
+	}
+	remote, err = newRemoteClient(
+		firstNonEmpty(remoteURL, os.Getenv("LH_ADMIN_URL")),
+		firstNonEmpty(remoteUser, os.Getenv("LH_ADMIN_USER")), password,
+	)
+	return err
+}
+
+// newRemoteClient creates a remoteClient for the admin API at base; the
+// admin API path is appended if it is missing.
+func newRemoteClient(base, username, password string) (*remoteClient, error) {
+	if base == "" {
+		return nil, errors.New("no admin API URL given; use --url or LH_ADMIN_URL")
+	}
+	base = strings.TrimSuffix(base, "/")
+	if !strings.HasSuffix(base, adminAPIBasePath) {
+		base += adminAPIBasePath
+	}
+	return &remoteClient{
+		baseURL:  base,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: remoteTimeout},
+	}, nil
+}
+
+// readSecret reads a secret from the passed file or, if no file is given,
+// from the passed environment variable.
+func readSecret(file, env string) (string, error) {
+	if file == "" {
+		return os.Getenv(env), nil
+	}
+	data, err := fileutils.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// rawResponse can be passed as out to remoteClient.do to obtain the response
+// body without decoding it.
+type rawResponse []byte
+
+// do sends a request to the admin API. A string or []byte body is sent as
+// plain text, any other non-nil body as JSON. If out is non-nil, the JSON
+// response is decoded into it.
+func (c *remoteClient) do(method, path string, query url.Values, body, out any) (http.Header, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reqBody, contentType = strings.NewReader(b), "text/plain"
+	case []byte:
+		reqBody, contentType = bytes.NewReader(b), "text/plain"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode request body")
+		}
+		reqBody, contentType = bytes.NewReader(data), "application/json"
+	}
+	req, err := http.NewRequest(method, target, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	c.authorize(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "admin API request failed")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read admin API response")
+	}
+	if resp.StatusCode >= 400 {
+		apiErr := remoteAPIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(data, &apiErr)
+		return resp.Header, apiErr
+	}
+	if out != nil && len(data) > 0 {
+		if raw, ok := out.(*rawResponse); ok {
+			*raw = data
+		} else if err = json.Unmarshal(data, out); err != nil {
+			return resp.Header, errors.Wrap(err, "failed to decode admin API response")
+		}
+	}
+	return resp.Header, nil
+}
+
+// authorize adds the HTTP Basic credentials to the request.
+func (c *remoteClient) authorize(req *http.Request) {
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// printRemote sends a request to the admin API and prints the indented JSON
+// response.
+func (c *remoteClient) printRemote(method, path string, query url.Values, body any) error {
+	var raw rawResponse
+	if _, err := c.do(method, path, query, body, &raw); err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		fmt.Println(string(raw))
+		return nil
+	}
+	fmt.Println(buf.String())
+	return nil
+}
+
+// resolveID returns the database ID of the entry in the list at listPath
+// whose field has the passed value. If ident already is a numeric ID, it is
+// returned as is. The search query parameter narrows down the list on
+// endpoints that support it.
+func (c *remoteClient) resolveID(listPath, field, ident string) (string, error) {
+	if _, err := strconv.ParseUint(ident, 10, 64); err == nil {
+		return ident, nil
+	}
+	var items []map[string]any
+	if _, err := c.do(http.MethodGet, listPath, url.Values{"search": {ident}}, nil, &items); err != nil {
+		return "", err
+	}
+	for _, item := range items {
+		if v, _ := item[field].(string); v == ident {
+			if id, ok := item["id"].(float64); ok {
+				return strconv.FormatUint(uint64(id), 10), nil
+			}
+		}
+	}
+	return "", errors.Errorf("'%s' not found", ident)
+}
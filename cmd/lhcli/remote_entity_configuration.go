@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+var remoteKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the entity configuration signing keys",
+}
+
+var remoteAuthorityHintsCmd = &cobra.Command{
+	Use:   "authority-hints",
+	Short: "Manage authority hints",
+	Long: `Manage the authority hints of the entity configuration. Authority hints can be referenced by
+their entity ID or by their numeric ID in the admin API.`,
+}
+
+var (
+	remoteRevoke       bool
+	remoteRevokeReason string
+	remoteDefaultAlg   string
+)
+
+var (
+	importAlg    string
+	importNext   bool
+	importNbf    string
+	importExp    string
+	importKeySet string
+)
+
+// importKeyRequest builds the request body for importing the private key in
+// the passed file, which holds a PEM encoded key or a JWK.
+func importKeyRequest(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read key file")
+	}
+	req := map[string]any{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		req["key"] = json.RawMessage(trimmed)
+	} else {
+		req["key"] = string(data)
+	}
+	if importAlg != "" {
+		req["alg"] = importAlg
+	}
+	if importNext {
+		req["placement"] = "next"
+	}
+	for name, value := range map[string]string{
+		"nbf": importNbf,
+		"exp": importExp,
+	} {
+		if value == "" {
+			continue
+		}
+		t, err := parseDate(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid --%s", name)
+		}
+		req[name] = t.Unix()
+	}
+	return req, nil
+}
+
+// revokeQuery returns the query parameters for the --revoke and --reason
+// flags.
+func revokeQuery() url.Values {
+	query := url.Values{}
+	if remoteRevoke {
+		query.Set("revoke", "true")
+		if remoteRevokeReason != "" {
+			query.Set("reason", remoteRevokeReason)
+		}
+	}
+	return query
+}
+
+func init() {
+	remoteKeysRotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate all signing keys now",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if _, err := remote.do(http.MethodPost, "/kms/rotate", revokeQuery(), nil, nil); err != nil {
+				return errors.Wrap(err, "failed to rotate keys")
+			}
+			fmt.Println("key rotation triggered successfully")
+			return nil
+		},
+	}
+	remoteKeysRemoveCmd := &cobra.Command{
+		Use:   "remove <kid>",
+		Short: "Remove a signing key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			path := "/entity-configuration/keys/" + url.PathEscape(args[0])
+			if _, err := remote.do(http.MethodDelete, path, revokeQuery(), nil, nil); err != nil {
+				return errors.Wrap(err, "failed to remove key")
+			}
+			fmt.Println("key removed successfully")
+			return nil
+		},
+	}
+	remoteKeysAlgsCmd := &cobra.Command{
+		Use:   "algs <alg>...",
+		Short: "Set the signing algorithms for which keys are published",
+		Long: `Set the signing algorithms for which keys are published in the JWKS. The set must contain the
+default signing algorithm, which can be changed with --default.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			req := map[string]any{"algs": args}
+			if remoteDefaultAlg != "" {
+				req["default"] = remoteDefaultAlg
+			}
+			return remote.printRemote(http.MethodPut, "/kms/algs", nil, req)
+		},
+	}
+	remoteKeysAlgsCmd.Flags().StringVar(&remoteDefaultAlg, "default", "", "the default signing algorithm")
+	remoteKeysImportCmd := &cobra.Command{
+		Use:   "import <key_file>",
+		Short: "Import an existing private key into the KMS",
+		Long: `Import an existing private key, e.g. the signing key of a trust anchor that is migrated to
+LightHouse. The key file contains a PEM encoded private key or a private JWK. The key's algorithm must be
+one of the configured signing algorithms.
+
+By default the key is activated now and the current key of the same algorithm is phased out as in a key
+rotation; with --revoke the current key is revoked instead. With --next the key is published as the next
+key and activated once all entity configurations issued without it have expired.
+
+Keys can only be imported through the admin API of the running instance; there is no local import.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			req, err := importKeyRequest(args[0])
+			if err != nil {
+				return err
+			}
+			path := "/kms/import"
+			if importKeySet != "" {
+				path = "/key-sets/" + url.PathEscape(importKeySet) + path
+			}
+			return remote.printRemote(http.MethodPost, path, revokeQuery(), req)
+		},
+	}
+	remoteKeysImportCmd.Flags().StringVar(&importAlg, "alg", "", "the signing algorithm of the key")
+	remoteKeysImportCmd.Flags().BoolVar(&importNext, "next", false, "publish the key as the next key")
+	remoteKeysImportCmd.Flags().StringVar(&importNbf, "nbf", "", "not before time of the key (YYYY-MM-DD or RFC3339)")
+	remoteKeysImportCmd.Flags().StringVar(&importExp, "exp", "", "expiration time of the key (YYYY-MM-DD or RFC3339)")
+	remoteKeysImportCmd.Flags().StringVar(
+		&importKeySet, "key-set", "", "import into a separate key set (e.g. trust_marks) instead of the federation keys",
+	)
+	for _, cmd := range []*cobra.Command{
+		remoteKeysRotateCmd,
+		remoteKeysRemoveCmd,
+		remoteKeysImportCmd,
+	} {
+		cmd.Flags().BoolVar(&remoteRevoke, "revoke", false, "revoke the old key(s) instead of letting them expire")
+		cmd.Flags().StringVar(&remoteRevokeReason, "reason", "", "revocation reason")
+	}
+	remoteKeysCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the signing keys and their lifetimes",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/entity-configuration/keys", nil, nil)
+			},
+		},
+		&cobra.Command{
+			Use:   "jwks",
+			Short: "Show the published JWKS",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/entity-configuration/jwks", nil, nil)
+			},
+		},
+		&cobra.Command{
+			Use:   "kms",
+			Short: "Show the KMS configuration",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/kms", nil, nil)
+			},
+		},
+		&cobra.Command{
+			Use:   "events",
+			Short: "Show the key events, e.g. imported keys",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/kms/events", nil, nil)
+			},
+		},
+		remoteKeysAlgsCmd,
+		remoteKeysRotateCmd,
+		remoteKeysRemoveCmd,
+		remoteKeysImportCmd,
+	)
+
+	remoteAuthorityHintsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List authority hints",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			query := url.Values{}
+			if remoteSearch != "" {
+				query.Set("search", remoteSearch)
+			}
+			return remote.printRemote(http.MethodGet, "/entity-configuration/authority-hints", query, nil)
+		},
+	}
+	remoteAuthorityHintsListCmd.Flags().StringVar(
+		&remoteSearch, "search", "", "only list authority hints whose entity ID or description contains this string",
+	)
+	remoteAuthorityHintsAddCmd := &cobra.Command{
+		Use:   "add <entity_id>",
+		Short: "Add an authority hint",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			req := model.AddAuthorityHint{
+				EntityID:    args[0],
+				Description: remoteDescription,
+			}
+			if _, err := remote.do(http.MethodPost, "/entity-configuration/authority-hints", nil, req, nil); err != nil {
+				return errors.Wrap(err, "failed to add authority hint")
+			}
+			fmt.Println("authority hint added successfully")
+			return nil
+		},
+	}
+	remoteAuthorityHintsAddCmd.Flags().StringVar(&remoteDescription, "description", "", "description")
+	remoteAuthorityHintsCmd.AddCommand(
+		remoteAuthorityHintsListCmd,
+		remoteAuthorityHintsAddCmd,
+		&cobra.Command{
+			Use:   "remove <entity_id|id>",
+			Short: "Remove an authority hint",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				id, err := remote.resolveID("/entity-configuration/authority-hints", "entity_id", args[0])
+				if err != nil {
+					return errors.Wrap(err, "failed to find authority hint")
+				}
+				if _, err = remote.do(
+					http.MethodDelete, "/entity-configuration/authority-hints/"+id, nil, nil, nil,
+				); err != nil {
+					return errors.Wrap(err, "failed to remove authority hint")
+				}
+				fmt.Println("authority hint removed successfully")
+				return nil
+			},
+		},
+	)
+
+	remoteCmd.AddCommand(remoteKeysCmd)
+	remoteCmd.AddCommand(remoteAuthorityHintsCmd)
+}
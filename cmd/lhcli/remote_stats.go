@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/internal/stats"
+)
+
+var remoteStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "View statistics",
+	Long:  `View statistics for federation endpoints; the time range defaults to the last 24 hours.`,
+}
+
+// statsQuery returns the query parameters for the stats flags.
+func statsQuery() url.Values {
+	query := url.Values{}
+	if statsFromDate != "" {
+		query.Set("from", statsFromDate)
+	}
+	if statsToDate != "" {
+		query.Set("to", statsToDate)
+	}
+	if statsEndpoint != "" {
+		query.Set("endpoint", statsEndpoint)
+	}
+	if statsTrustMarkType != "" {
+		query.Set("trust_mark_type", statsTrustMarkType)
+	}
+	return query
+}
+
+func init() {
+	remoteStatsCmd.PersistentFlags().StringVar(&statsFromDate, "from", "", "start date (YYYY-MM-DD or RFC3339)")
+	remoteStatsCmd.PersistentFlags().StringVar(&statsToDate, "to", "", "end date (YYYY-MM-DD or RFC3339)")
+
+	remoteStatsTopCmd := &cobra.Command{
+		Use: "top <endpoints|user-agents|clients|countries|subjects|trust-mark-subjects>",
+		Short: "Show top endpoints, user agents, clients, countries, or requested subjects by request " +
+			"count",
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{
+			"endpoints", "user-agents", "clients", "countries", "subjects", "trust-mark-subjects",
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			query := statsQuery()
+			query.Set("limit", fmt.Sprint(statsLimit))
+			return remote.printRemote(http.MethodGet, "/stats/top/"+args[0], query, nil)
+		},
+	}
+	remoteStatsTopCmd.Flags().IntVar(&statsLimit, "limit", 10, "number of results to show")
+	remoteStatsTopCmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "filter subjects by endpoint")
+	remoteStatsTopCmd.Flags().StringVar(
+		&statsTrustMarkType, "trust-mark-type", "", "filter trust mark subjects by trust mark type",
+	)
+
+	remoteStatsUnfetchedCmd := &cobra.Command{
+		Use:   "unfetched",
+		Short: "Show active subordinates that are never fetched",
+		Long: `Show active subordinates whose subordinate statement was not fetched in the time range; they are
+candidates for a clean-up. The time range defaults to the last 30 days.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return remote.printRemote(http.MethodGet, "/stats/subordinates/unfetched", statsQuery(), nil)
+		},
+	}
+	remoteStatsUnfetchedCmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "the fetch endpoint (default fetch)")
+
+	remoteStatsTimeseriesCmd := &cobra.Command{
+		Use:   "timeseries",
+		Short: "Show time series data",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			query := statsQuery()
+			query.Set("interval", statsInterval)
+			return remote.printRemote(http.MethodGet, "/stats/timeseries", query, nil)
+		},
+	}
+	remoteStatsTimeseriesCmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "filter by endpoint")
+	remoteStatsTimeseriesCmd.Flags().StringVar(
+		&statsInterval, "interval", "hour", "time interval (minute, hour, day, week, month)",
+	)
+
+	remoteStatsLatencyCmd := &cobra.Command{
+		Use:   "latency",
+		Short: "Show latency percentiles",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return remote.printRemote(http.MethodGet, "/stats/latency", statsQuery(), nil)
+		},
+	}
+	remoteStatsLatencyCmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "filter by endpoint")
+
+	remoteStatsExportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export statistics to file",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			query := statsQuery()
+			query.Set("format", statsFormat)
+			var data []byte
+			if _, err := remote.do(http.MethodGet, "/stats/export", query, nil, (*rawResponse)(&data)); err != nil {
+				return errors.Wrap(err, "failed to export statistics")
+			}
+			if statsOutput == "" {
+				_, err := os.Stdout.Write(data)
+				return err
+			}
+			return os.WriteFile(statsOutput, data, 0644)
+		},
+	}
+	remoteStatsExportCmd.Flags().StringVar(&statsFormat, "format", "csv", "export format (csv or json)")
+	remoteStatsExportCmd.Flags().StringVarP(&statsOutput, "output", "o", "", "output file (default: stdout)")
+
+	remoteStatsTailCmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Stream recorded requests",
+		Long: `Stream the requests as they are recorded by the LightHouse serving the admin API, optionally
+filtered by endpoint, status class, client, and subject.
+
+With --rates a rolling per-endpoint view of the request rate, errors, and latency is shown instead,
+which is updated every --refresh interval.
+
+With prefork, the requests of all processes are only streamed if the admin API runs on its own port;
+they then appear once the child processes flush them. If the admin API is mounted on the main server,
+only the requests handled by the child process serving the stream are shown; use 'lhcli stats tail'
+to see all requests.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			filter, err := tailFilter()
+			if err != nil {
+				return err
+			}
+			query := url.Values{}
+			if filter.Endpoint != "" {
+				query.Set("endpoint", filter.Endpoint)
+			}
+			if statsTailStatus != "" {
+				query.Set("status", statsTailStatus)
+			}
+			if filter.ClientIP != "" {
+				query.Set("client", filter.ClientIP)
+			}
+			if filter.Subject != "" {
+				query.Set("subject", filter.Subject)
+			}
+			return runTail(
+				func(ctx context.Context, entries chan<- *stats.RequestLog) error {
+					return remote.streamTail(ctx, query, entries)
+				},
+			)
+		},
+	}
+	addTailFlags(remoteStatsTailCmd)
+
+	remoteStatsCmd.AddCommand(
+		&cobra.Command{
+			Use:   "summary",
+			Short: "Show statistics summary",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/stats/summary", statsQuery(), nil)
+			},
+		},
+		remoteStatsTopCmd,
+		remoteStatsUnfetchedCmd,
+		remoteStatsTimeseriesCmd,
+		remoteStatsLatencyCmd,
+		remoteStatsExportCmd,
+		remoteStatsTailCmd,
+	)
+	remoteCmd.AddCommand(remoteStatsCmd)
+}
+
+// streamTail sends the requests streamed by the admin API to entries, until
+// ctx is done. The admin API ends streams after a while, so the stream is
+// reopened until an error occurs.
+func (c *remoteClient) streamTail(ctx context.Context, query url.Values, entries chan<- *stats.RequestLog) error {
+	// The client timeout would end the stream
+	client := &http.Client{Transport: c.http.Transport}
+	target := c.baseURL + "/stats/tail"
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/x-ndjson")
+		c.authorize(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "admin API request failed")
+		}
+		if resp.StatusCode >= 400 {
+			apiErr := remoteAPIError{StatusCode: resp.StatusCode}
+			data, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			_ = json.Unmarshal(data, &apiErr)
+			return apiErr
+		}
+		err = readTailStream(ctx, resp.Body, entries)
+		_ = resp.Body.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read the stream of the admin API")
+		}
+		// Do not hammer the admin API if streams end immediately
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// readTailStream sends the newline-delimited request logs of r to entries.
+func readTailStream(ctx context.Context, r io.Reader, entries chan<- *stats.RequestLog) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			// Heartbeat
+			continue
+		}
+		var entry stats.RequestLog
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return errors.Wrap(err, "invalid request log")
+		}
+		select {
+		case entries <- &entry:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+var remoteSubordinatesCmd = &cobra.Command{
+	Use:   "subordinates",
+	Short: "Manage subordinates",
+	Long: `Manage subordinates. Subordinates can be referenced by their entity ID or by their
+numeric ID in the admin API.`,
+}
+
+var remoteSubordinatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List subordinates",
+	Args:  cobra.NoArgs,
+	RunE:  remoteListSubordinates,
+}
+
+var remoteSubordinatesGetCmd = &cobra.Command{
+	Use:   "get <entity_id|id>",
+	Short: "Show a subordinate",
+	Args:  cobra.ExactArgs(1),
+	RunE:  remoteGetSubordinate,
+}
+
+var remoteSubordinatesAddCmd = &cobra.Command{
+	Use:   "add <entity_id>",
+	Short: "Add a subordinate",
+	Long: `Add a subordinate. The entity configuration is fetched and verified (with the keys from --jwks if given)
+to obtain the entity's keys and, if no --entity_type is given, its entity types.`,
+	Args: cobra.ExactArgs(1),
+	RunE: remoteAddSubordinate,
+}
+
+var remoteSubordinatesRemoveCmd = &cobra.Command{
+	Use:   "remove <entity_id|id>",
+	Short: "Remove a subordinate",
+	Args:  cobra.ExactArgs(1),
+	RunE:  remoteRemoveSubordinate,
+}
+
+var remoteSubordinatesBlockCmd = &cobra.Command{
+	Use:   "block <entity_id|id>",
+	Short: "Block a subordinate",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return remoteSetSubordinateStatus(args[0], model.StatusBlocked)
+	},
+}
+
+var remoteSubordinatesStatusCmd = &cobra.Command{
+	Use:   "status <entity_id|id> <status>",
+	Short: "Update subordinate status",
+	Long:  `Update the status of a subordinate to one of: active, blocked, pending, inactive.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		status, err := model.ParseStatus(args[1])
+		if err != nil {
+			return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
+		}
+		return remoteSetSubordinateStatus(args[0], status)
+	},
+}
+
+var remoteSubordinatesHistoryCmd = &cobra.Command{
+	Use:   "history <entity_id|id>",
+	Short: "Show the event history of a subordinate",
+	Args:  cobra.ExactArgs(1),
+	RunE:  remoteSubordinateHistory,
+}
+
+var (
+	remoteStatus        string
+	remoteInitialStatus string
+	remoteSearch        string
+	remoteDescription   string
+	remoteLimit         int
+)
+
+func init() {
+	remoteSubordinatesListCmd.Flags().StringVar(&remoteStatus, "status", "", "only list subordinates with this status")
+	remoteSubordinatesListCmd.Flags().StringArrayVarP(
+		&entityTypes, "entity_type", "t", []string{}, "only list subordinates with this entity type",
+	)
+	remoteSubordinatesListCmd.Flags().StringVarP(
+		&labelSelector, "selector", "s", "", "only list subordinates matching this label selector",
+	)
+	remoteSubordinatesListCmd.Flags().StringVar(
+		&remoteSearch, "search", "", "only list subordinates whose entity ID or description contains this string",
+	)
+	remoteSubordinatesAddCmd.Flags().StringArrayVarP(&entityTypes, "entity_type", "t", []string{}, "entity type")
+	remoteSubordinatesAddCmd.Flags().StringVarP(
+		&jwksFile, "jwks", "k",
+		"", "a file containing the entity's public key("+
+			"s) in the jwks format; used to verify that the entity's entity"+
+			" configuration is signed with a key from this set.",
+	)
+	remoteSubordinatesAddCmd.Flags().StringArrayVarP(&labelArgs, "label", "l", []string{}, "label in the form key=value")
+	remoteSubordinatesAddCmd.Flags().StringVar(&remoteInitialStatus, "status", "active", "initial status of the subordinate")
+	remoteSubordinatesAddCmd.Flags().StringVar(&remoteDescription, "description", "", "description of the subordinate")
+	remoteSubordinatesHistoryCmd.Flags().IntVar(&remoteLimit, "limit", 50, "maximum number of events to show")
+
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesListCmd)
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesGetCmd)
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesAddCmd)
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesRemoveCmd)
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesBlockCmd)
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesStatusCmd)
+	remoteSubordinatesCmd.AddCommand(remoteSubordinatesHistoryCmd)
+	remoteCmd.AddCommand(remoteSubordinatesCmd)
+}
+
+func remoteSubordinatePath(ident string) (string, error) {
+	id, err := remote.resolveID("/subordinates", "entity_id", ident)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find subordinate")
+	}
+	return "/subordinates/" + id, nil
+}
+
+func remoteListSubordinates(_ *cobra.Command, _ []string) error {
+	query := url.Values{}
+	if remoteStatus != "" {
+		query.Set("status", remoteStatus)
+	}
+	for _, t := range entityTypes {
+		query.Add("entity_type", t)
+	}
+	if labelSelector != "" {
+		query.Set("label_selector", labelSelector)
+	}
+	if remoteSearch != "" {
+		query.Set("search", remoteSearch)
+	}
+	return remote.printRemote(http.MethodGet, "/subordinates", query, nil)
+}
+
+func remoteGetSubordinate(_ *cobra.Command, args []string) error {
+	path, err := remoteSubordinatePath(args[0])
+	if err != nil {
+		return err
+	}
+	return remote.printRemote(http.MethodGet, path, nil, nil)
+}
+
+func remoteAddSubordinate(_ *cobra.Command, args []string) error {
+	status, err := model.ParseStatus(remoteInitialStatus)
+	if err != nil {
+		return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
+	}
+	labels, err := parseLabelArgs(labelArgs)
+	if err != nil {
+		return err
+	}
+	entityConfig, err := fetchEntityConfiguration(args[0])
+	if err != nil {
+		return err
+	}
+	if len(entityTypes) == 0 {
+		entityTypes = entityConfig.Metadata.GuessEntityTypes()
+	}
+	jwks := model.NewJWKS(entityConfig.JWKS)
+	req := model.AddSubordinate{
+		EntityID:              entityConfig.Subject,
+		Status:                status,
+		Description:           remoteDescription,
+		RegisteredEntityTypes: entityTypes,
+		Labels:                labels,
+		JWKS:                  &jwks,
+	}
+	if _, err = remote.do(http.MethodPost, "/subordinates", nil, req, nil); err != nil {
+		return errors.Wrap(err, "failed to add subordinate")
+	}
+	fmt.Println("subordinate added successfully")
+	return nil
+}
+
+func remoteRemoveSubordinate(_ *cobra.Command, args []string) error {
+	path, err := remoteSubordinatePath(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err = remote.do(http.MethodDelete, path, nil, nil, nil); err != nil {
+		return errors.Wrap(err, "failed to remove subordinate")
+	}
+	fmt.Println("subordinate removed successfully")
+	return nil
+}
+
+func remoteSetSubordinateStatus(ident string, status model.Status) error {
+	path, err := remoteSubordinatePath(ident)
+	if err != nil {
+		return err
+	}
+	if _, err = remote.do(http.MethodPut, path+"/status", nil, status.String(), nil); err != nil {
+		return errors.Wrap(err, "failed to update subordinate status")
+	}
+	fmt.Printf("subordinate status updated to '%s' successfully\n", status)
+	return nil
+}
+
+func remoteSubordinateHistory(_ *cobra.Command, args []string) error {
+	path, err := remoteSubordinatePath(args[0])
+	if err != nil {
+		return err
+	}
+	query := url.Values{"limit": {fmt.Sprint(remoteLimit)}}
+	return remote.printRemote(http.MethodGet, path+"/history", query, nil)
+}
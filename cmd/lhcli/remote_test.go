@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newTestRemote starts an httptest server with the passed handler and
+// returns a remoteClient for it.
+func newTestRemote(t *testing.T, handler http.HandlerFunc, username, password string) *remoteClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := newRemoteClient(srv.URL, username, password)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestNewRemoteClient(t *testing.T) {
+	t.Parallel()
+	for base, want := range map[string]string{
+		"https://lh.example.org":                   "https://lh.example.org/api/v1/admin",
+		"https://lh.example.org/":                  "https://lh.example.org/api/v1/admin",
+		"https://lh.example.org/api/v1/admin":      "https://lh.example.org/api/v1/admin",
+		"https://lh.example.org/api/v1/admin/":     "https://lh.example.org/api/v1/admin",
+		"https://lh.example.org/prefix":            "https://lh.example.org/prefix/api/v1/admin",
+		"https://lh.example.org:8081/api/v1/admin": "https://lh.example.org:8081/api/v1/admin",
+	} {
+		c, err := newRemoteClient(base, "", "")
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", base, err)
+		}
+		if c.baseURL != want {
+			t.Errorf("Expected base URL %s for %s, got %s", want, base, c.baseURL)
+		}
+	}
+
+	if _, err := newRemoteClient("", "", ""); err == nil {
+		t.Error("Expected error for missing URL")
+	}
+}
+
+func TestRemoteClientDo(t *testing.T) {
+	t.Parallel()
+
+	t.Run(
+		"JSONRoundTrip", func(t *testing.T) {
+			t.Parallel()
+			c := newTestRemote(
+				t, func(w http.ResponseWriter, r *http.Request) {
+					if r.Method != http.MethodPost || r.URL.Path != "/api/v1/admin/subordinates" {
+						t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+					}
+					if r.URL.Query().Get("dry_run") != "true" {
+						t.Errorf("Expected query to be passed, got %s", r.URL.RawQuery)
+					}
+					if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+						t.Errorf("Expected JSON content type, got %q", ct)
+					}
+					var body map[string]string
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["entity_id"] != "https://rp.example.org" {
+						t.Errorf("Unexpected body %v: %v", body, err)
+					}
+					w.Header().Set("X-Total-Count", "1")
+					w.WriteHeader(http.StatusCreated)
+					_, _ = io.WriteString(w, `{"id":7}`)
+				}, "", "",
+			)
+			var out struct {
+				ID int `json:"id"`
+			}
+			header, err := c.do(
+				http.MethodPost, "/subordinates", url.Values{"dry_run": {"true"}},
+				map[string]string{"entity_id": "https://rp.example.org"}, &out,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out.ID != 7 {
+				t.Errorf("Expected decoded id 7, got %d", out.ID)
+			}
+			if header.Get("X-Total-Count") != "1" {
+				t.Errorf("Expected response headers to be returned, got %v", header)
+			}
+		},
+	)
+
+	t.Run(
+		"PlainTextAndRawResponse", func(t *testing.T) {
+			t.Parallel()
+			c := newTestRemote(
+				t, func(w http.ResponseWriter, r *http.Request) {
+					if ct := r.Header.Get("Content-Type"); ct != "text/plain" {
+						t.Errorf("Expected plain text content type, got %q", ct)
+					}
+					data, _ := io.ReadAll(r.Body)
+					if string(data) != "blocked" {
+						t.Errorf("Expected body 'blocked', got %q", data)
+					}
+					_, _ = io.WriteString(w, `not json`)
+				}, "", "",
+			)
+			var raw rawResponse
+			if _, err := c.do(http.MethodPut, "/subordinates/1/status", nil, "blocked", &raw); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(raw) != "not json" {
+				t.Errorf("Expected raw body, got %q", raw)
+			}
+		},
+	)
+
+	t.Run(
+		"APIError", func(t *testing.T) {
+			t.Parallel()
+			c := newTestRemote(
+				t, func(w http.ResponseWriter, _ *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = io.WriteString(w, `{"error":"not_found","error_description":"subordinate not found"}`)
+				}, "", "",
+			)
+			_, err := c.do(http.MethodGet, "/subordinates/1", nil, nil, nil)
+			var apiErr remoteAPIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected remoteAPIError, got %v", err)
+			}
+			if apiErr.StatusCode != http.StatusNotFound || apiErr.ErrorCode != "not_found" ||
+				apiErr.ErrorDescription != "subordinate not found" {
+				t.Errorf("Unexpected error %+v", apiErr)
+			}
+			if want := "admin API returned 404: not_found: subordinate not found"; err.Error() != want {
+				t.Errorf("Expected error message %q, got %q", want, err.Error())
+			}
+		},
+	)
+
+	t.Run(
+		"NonJSONError", func(t *testing.T) {
+			t.Parallel()
+			c := newTestRemote(
+				t, func(w http.ResponseWriter, _ *http.Request) {
+					http.Error(w, "bad gateway", http.StatusBadGateway)
+				}, "", "",
+			)
+			_, err := c.do(http.MethodGet, "/subordinates", nil, nil, nil)
+			if want := "admin API returned 502 Bad Gateway"; err == nil || err.Error() != want {
+				t.Errorf("Expected error message %q, got %v", want, err)
+			}
+		},
+	)
+
+	t.Run(
+		"InvalidJSONResponse", func(t *testing.T) {
+			t.Parallel()
+			c := newTestRemote(
+				t, func(w http.ResponseWriter, _ *http.Request) {
+					_, _ = io.WriteString(w, `[`)
+				}, "", "",
+			)
+			var out []map[string]any
+			if _, err := c.do(http.MethodGet, "/subordinates", nil, nil, &out); err == nil {
+				t.Error("Expected decoding error")
+			}
+		},
+	)
+}
+
+func TestRemoteClientAuthorization(t *testing.T) {
+	t.Parallel()
+	for name, tc := range map[string]struct {
+		username, password string
+		check              func(t *testing.T, r *http.Request)
+	}{
+		"Basic": {
+			username: "admin", password: "secret",
+			check: func(t *testing.T, r *http.Request) {
+				user, pass, ok := r.BasicAuth()
+				if !ok || user != "admin" || pass != "secret" {
+					t.Errorf("Expected basic auth admin:secret, got %q", r.Header.Get("Authorization"))
+				}
+			},
+		},
+		"None": {
+			check: func(t *testing.T, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != "" {
+					t.Errorf("Expected no Authorization header, got %q", got)
+				}
+			},
+		},
+	} {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+				c := newTestRemote(
+					t, func(w http.ResponseWriter, r *http.Request) {
+						tc.check(t, r)
+						if got := r.Header.Get("Accept"); got != "application/json" {
+							t.Errorf("Expected Accept application/json, got %q", got)
+						}
+						w.WriteHeader(http.StatusNoContent)
+					}, tc.username, tc.password,
+				)
+				if _, err := c.do(http.MethodGet, "/users", nil, nil, nil); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			},
+		)
+	}
+}
+
+func TestRemoteClientResolveID(t *testing.T) {
+	t.Parallel()
+	var requests int
+	c := newTestRemote(
+		t, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.URL.Path != "/api/v1/admin/trust-marks/types" {
+				t.Errorf("Unexpected path %s", r.URL.Path)
+			}
+			items := []map[string]any{
+				{"id": 3, "trust_mark_type": "https://tm.example.org/member-extended"},
+				{"id": 4, "trust_mark_type": "https://tm.example.org/member"},
+			}
+			search := r.URL.Query().Get("search")
+			if search == "" {
+				t.Error("Expected search query parameter")
+			}
+			_ = json.NewEncoder(w).Encode(items)
+		}, "", "",
+	)
+
+	id, err := c.resolveID("/trust-marks/types", "trust_mark_type", "42")
+	if err != nil || id != "42" {
+		t.Errorf("Expected numeric ID to be returned as is, got %q, %v", id, err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request for a numeric ID, got %d", requests)
+	}
+
+	// Only exact matches count, not other entries found by the substring search
+	id, err = c.resolveID("/trust-marks/types", "trust_mark_type", "https://tm.example.org/member")
+	if err != nil || id != "4" {
+		t.Errorf("Expected ID 4, got %q, %v", id, err)
+	}
+
+	if _, err = c.resolveID("/trust-marks/types", "trust_mark_type", "https://tm.example.org/other"); err == nil {
+		t.Error("Expected error for unknown entry")
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+var remoteTrustMarksCmd = &cobra.Command{
+	Use:   "trustmarks",
+	Short: "Manage trust mark types, issuance specs, subjects, and issued trust marks",
+}
+
+var remoteTrustMarkTypesCmd = &cobra.Command{
+	Use:   "types",
+	Short: "Manage trust mark types",
+}
+
+var remoteTrustMarkSpecsCmd = &cobra.Command{
+	Use:   "specs",
+	Short: "Manage trust mark issuance specs",
+}
+
+var remoteTrustMarkSubjectsCmd = &cobra.Command{
+	Use:   "subjects",
+	Short: "Manage the subjects a trust mark can be issued to",
+	Long: `Manage the subjects a trust mark can be issued to. The issuance spec is referenced by its
+trust mark type or numeric ID, subjects by their entity ID or numeric ID.`,
+}
+
+var remoteTrustMarkInstancesCmd = &cobra.Command{
+	Use:   "instances",
+	Short: "Show issued trust marks",
+}
+
+var remoteTrustMarkRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke issued trust marks",
+	Long: `Revoke issued trust marks, either a single one by its JTI or all active
+ones of a subject (--subject), of a trust mark type (--type), or of a subject
+for a trust mark type (both). The cached eligibility results and issued trust
+marks of the server are invalidated.
+
+Revoking does not change the eligibility of the subject; use 'subjects status'
+or 'subjects remove' to prevent new trust marks from being issued.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if revokeJTI != "" {
+			var instance model.IssuedTrustMarkInstance
+			if _, err := remote.do(
+				http.MethodPost, "/trust-marks/instances/"+url.PathEscape(revokeJTI)+"/revoke", nil,
+				model.TrustMarkRevocation{Reason: revokeReason}, &instance,
+			); err != nil {
+				var apiErr remoteAPIError
+				if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+					fmt.Println(apiErr.ErrorDescription)
+					return nil
+				}
+				return errors.Wrap(err, "failed to revoke trust mark")
+			}
+			fmt.Printf(
+				"trust mark '%s' of type '%s' for '%s' revoked\n", instance.JTI, instance.TrustMarkType,
+				instance.Subject,
+			)
+			return nil
+		}
+
+		req := model.RevokeTrustMarkInstances{
+			TrustMarkType: revokeType,
+			Subject:       revokeSubject,
+			Reason:        revokeReason,
+		}
+		var res model.RevokeTrustMarkInstancesResult
+		if _, err := remote.do(http.MethodPost, "/trust-marks/instances/revoke", nil, req, &res); err != nil {
+			return errors.Wrap(err, "failed to revoke trust marks")
+		}
+		for _, jti := range res.JTIs {
+			fmt.Println(jti)
+		}
+		fmt.Printf("%d trust mark(s) revoked\n", res.Revoked)
+		return nil
+	},
+}
+
+var remoteTrustMarkLifetime uint
+
+func init() {
+	remoteTrustMarkTypesCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List trust mark types",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/trust-marks/types", nil, nil)
+			},
+		},
+		&cobra.Command{
+			Use:   "get <trust_mark_type|id>",
+			Short: "Show a trust mark type",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				path, err := remoteTrustMarkTypePath(args[0])
+				if err != nil {
+					return err
+				}
+				return remote.printRemote(http.MethodGet, path, nil, nil)
+			},
+		},
+		remoteTrustMarkTypesAddCmd,
+		&cobra.Command{
+			Use:   "remove <trust_mark_type|id>",
+			Short: "Remove a trust mark type",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				path, err := remoteTrustMarkTypePath(args[0])
+				if err != nil {
+					return err
+				}
+				if _, err = remote.do(http.MethodDelete, path, nil, nil, nil); err != nil {
+					return errors.Wrap(err, "failed to remove trust mark type")
+				}
+				fmt.Println("trust mark type removed successfully")
+				return nil
+			},
+		},
+	)
+	remoteTrustMarkTypesAddCmd.Flags().StringVar(&remoteDescription, "description", "", "description")
+
+	remoteTrustMarkSpecsCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List trust mark issuance specs",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/trust-marks/issuance-spec", nil, nil)
+			},
+		},
+		&cobra.Command{
+			Use:   "get <trust_mark_type|id>",
+			Short: "Show a trust mark issuance spec",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				path, err := remoteTrustMarkSpecPath(args[0])
+				if err != nil {
+					return err
+				}
+				return remote.printRemote(http.MethodGet, path, nil, nil)
+			},
+		},
+		remoteTrustMarkSpecsAddCmd,
+		&cobra.Command{
+			Use:   "remove <trust_mark_type|id>",
+			Short: "Remove a trust mark issuance spec",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				path, err := remoteTrustMarkSpecPath(args[0])
+				if err != nil {
+					return err
+				}
+				if _, err = remote.do(http.MethodDelete, path, nil, nil, nil); err != nil {
+					return errors.Wrap(err, "failed to remove trust mark issuance spec")
+				}
+				fmt.Println("trust mark issuance spec removed successfully")
+				return nil
+			},
+		},
+	)
+	remoteTrustMarkSpecsAddCmd.Flags().UintVar(
+		&remoteTrustMarkLifetime, "lifetime", 0, "lifetime of issued trust marks in seconds",
+	)
+	remoteTrustMarkSpecsAddCmd.Flags().StringVar(&remoteDescription, "description", "", "description")
+
+	remoteTrustMarkSubjectsListCmd.Flags().StringVar(&remoteStatus, "status", "", "only list subjects with this status")
+	remoteTrustMarkSubjectsListCmd.Flags().StringVar(
+		&remoteSearch, "search", "", "only list subjects whose entity ID or description contains this string",
+	)
+	remoteTrustMarkSubjectsAddCmd.Flags().StringVar(&remoteInitialStatus, "status", "active", "initial status of the subject")
+	remoteTrustMarkSubjectsAddCmd.Flags().StringVar(&remoteDescription, "description", "", "description")
+	remoteTrustMarkSubjectsCmd.AddCommand(
+		remoteTrustMarkSubjectsListCmd,
+		remoteTrustMarkSubjectsAddCmd,
+		&cobra.Command{
+			Use:   "remove <trust_mark_type|spec_id> <entity_id|id>",
+			Short: "Remove a subject",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(_ *cobra.Command, args []string) error {
+				path, err := remoteTrustMarkSubjectPath(args[0], args[1])
+				if err != nil {
+					return err
+				}
+				if _, err = remote.do(http.MethodDelete, path, nil, nil, nil); err != nil {
+					return errors.Wrap(err, "failed to remove subject")
+				}
+				fmt.Println("subject removed successfully")
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "status <trust_mark_type|spec_id> <entity_id|id> <status>",
+			Short: "Update subject status",
+			Long:  `Update the status of a subject to one of: active, blocked, pending, inactive.`,
+			Args:  cobra.ExactArgs(3),
+			RunE: func(_ *cobra.Command, args []string) error {
+				status, err := model.ParseStatus(args[2])
+				if err != nil {
+					return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
+				}
+				path, err := remoteTrustMarkSubjectPath(args[0], args[1])
+				if err != nil {
+					return err
+				}
+				if _, err = remote.do(http.MethodPut, path+"/status", nil, status.String(), nil); err != nil {
+					return errors.Wrap(err, "failed to update subject status")
+				}
+				fmt.Printf("subject status updated to '%s' successfully\n", status)
+				return nil
+			},
+		},
+	)
+
+	remoteTrustMarkInstancesCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list <trust_mark_type> <entity_id>",
+			Short: "List the trust marks issued to a subject",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(_ *cobra.Command, args []string) error {
+				query := url.Values{}
+				query.Set("trust_mark_type", args[0])
+				query.Set("sub", args[1])
+				return remote.printRemote(http.MethodGet, "/trust-marks/instances", query, nil)
+			},
+		},
+		&cobra.Command{
+			Use:   "get <jti>",
+			Short: "Show an issued trust mark",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				return remote.printRemote(http.MethodGet, "/trust-marks/instances/"+url.PathEscape(args[0]), nil, nil)
+			},
+		},
+	)
+	addRevokeFlags(remoteTrustMarkRevokeCmd)
+
+	remoteTrustMarksCmd.AddCommand(remoteTrustMarkTypesCmd)
+	remoteTrustMarksCmd.AddCommand(remoteTrustMarkSpecsCmd)
+	remoteTrustMarksCmd.AddCommand(remoteTrustMarkSubjectsCmd)
+	remoteTrustMarksCmd.AddCommand(remoteTrustMarkInstancesCmd)
+	remoteTrustMarksCmd.AddCommand(remoteTrustMarkRevokeCmd)
+	remoteCmd.AddCommand(remoteTrustMarksCmd)
+}
+
+var remoteTrustMarkTypesAddCmd = &cobra.Command{
+	Use:   "add <trust_mark_type>",
+	Short: "Add a trust mark type",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		req := model.AddTrustMarkType{
+			TrustMarkType: args[0],
+			Description:   remoteDescription,
+		}
+		if _, err := remote.do(http.MethodPost, "/trust-marks/types", nil, req, nil); err != nil {
+			return errors.Wrap(err, "failed to add trust mark type")
+		}
+		fmt.Println("trust mark type added successfully")
+		return nil
+	},
+}
+
+var remoteTrustMarkSpecsAddCmd = &cobra.Command{
+	Use:   "add <trust_mark_type>",
+	Short: "Add a trust mark issuance spec",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		req := model.AddTrustMarkSpec{
+			TrustMarkType: args[0],
+			Lifetime:      remoteTrustMarkLifetime,
+			Description:   remoteDescription,
+		}
+		if _, err := remote.do(http.MethodPost, "/trust-marks/issuance-spec", nil, req, nil); err != nil {
+			return errors.Wrap(err, "failed to add trust mark issuance spec")
+		}
+		fmt.Println("trust mark issuance spec added successfully")
+		return nil
+	},
+}
+
+var remoteTrustMarkSubjectsListCmd = &cobra.Command{
+	Use:   "list <trust_mark_type|spec_id>",
+	Short: "List the subjects of an issuance spec",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		path, err := remoteTrustMarkSpecPath(args[0])
+		if err != nil {
+			return err
+		}
+		query := url.Values{}
+		if remoteStatus != "" {
+			query.Set("status", remoteStatus)
+		}
+		if remoteSearch != "" {
+			query.Set("search", remoteSearch)
+		}
+		return remote.printRemote(http.MethodGet, path+"/subjects", query, nil)
+	},
+}
+
+var remoteTrustMarkSubjectsAddCmd = &cobra.Command{
+	Use:   "add <trust_mark_type|spec_id> <entity_id>",
+	Short: "Add a subject to an issuance spec",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		status, err := model.ParseStatus(remoteInitialStatus)
+		if err != nil {
+			return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
+		}
+		path, err := remoteTrustMarkSpecPath(args[0])
+		if err != nil {
+			return err
+		}
+		req := model.AddTrustMarkSubject{
+			EntityID:    args[1],
+			Status:      status,
+			Description: remoteDescription,
+		}
+		if _, err = remote.do(http.MethodPost, path+"/subjects", nil, req, nil); err != nil {
+			return errors.Wrap(err, "failed to add subject")
+		}
+		fmt.Println("subject added successfully")
+		return nil
+	},
+}
+
+func remoteTrustMarkTypePath(ident string) (string, error) {
+	id, err := remote.resolveID("/trust-marks/types", "trust_mark_type", ident)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find trust mark type")
+	}
+	return "/trust-marks/types/" + id, nil
+}
+
+func remoteTrustMarkSpecPath(ident string) (string, error) {
+	id, err := remote.resolveID("/trust-marks/issuance-spec", "trust_mark_type", ident)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find trust mark issuance spec")
+	}
+	return "/trust-marks/issuance-spec/" + id, nil
+}
+
+func remoteTrustMarkSubjectPath(specIdent, subjectIdent string) (string, error) {
+	specPath, err := remoteTrustMarkSpecPath(specIdent)
+	if err != nil {
+		return "", err
+	}
+	id, err := remote.resolveID(specPath+"/subjects", "entity_id", subjectIdent)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find subject")
+	}
+	return specPath + "/subjects/" + id, nil
+}
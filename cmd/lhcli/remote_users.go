@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+)
+
+var remoteUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage admin API users",
+}
+
+var (
+	remoteNewPasswordFile string
+	remoteDisplayName     string
+)
+
+// readNewPassword reads the password for a user from --new-password-file or,
+// if not given, from the first line of stdin.
+func readNewPassword() (string, error) {
+	if remoteNewPasswordFile != "" {
+		data, err := fileutils.ReadFile(remoteNewPasswordFile)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read password file")
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(err, "failed to read password")
+	}
+	password := strings.TrimSpace(line)
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	return password, nil
+}
+
+func init() {
+	remoteUsersAddCmd := &cobra.Command{
+		Use:   "add <username>",
+		Short: "Add a user",
+		Long: `Add a user. The password is read from --new-password-file or, if not given,
+from the first line of stdin.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			password, err := readNewPassword()
+			if err != nil {
+				return err
+			}
+			req := map[string]string{
+				"username":     args[0],
+				"password":     password,
+				"display_name": remoteDisplayName,
+			}
+			if _, err = remote.do(http.MethodPost, "/users", nil, req, nil); err != nil {
+				return errors.Wrap(err, "failed to add user")
+			}
+			fmt.Println("user added successfully")
+			return nil
+		},
+	}
+	remoteUsersAddCmd.Flags().StringVar(&remoteDisplayName, "display-name", "", "display name of the user")
+	remoteUsersPasswdCmd := &cobra.Command{
+		Use:   "passwd <username>",
+		Short: "Change the password of a user",
+		Long: `Change the password of a user. The password is read from --new-password-file or, if not given,
+from the first line of stdin.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			password, err := readNewPassword()
+			if err != nil {
+				return err
+			}
+			if _, err = remote.do(
+				http.MethodPut, "/users/"+url.PathEscape(args[0]), nil, map[string]string{"password": password}, nil,
+			); err != nil {
+				return errors.Wrap(err, "failed to change password")
+			}
+			fmt.Println("password changed successfully")
+			return nil
+		},
+	}
+	for _, cmd := range []*cobra.Command{
+		remoteUsersAddCmd,
+		remoteUsersPasswdCmd,
+	} {
+		cmd.Flags().StringVar(&remoteNewPasswordFile, "new-password-file", "", "file containing the user's password")
+	}
+	setDisabled := func(disabled bool) func(*cobra.Command, []string) error {
+		return func(_ *cobra.Command, args []string) error {
+			if _, err := remote.do(
+				http.MethodPut, "/users/"+url.PathEscape(args[0]), nil, map[string]bool{"disabled": disabled}, nil,
+			); err != nil {
+				return errors.Wrap(err, "failed to update user")
+			}
+			fmt.Println("user updated successfully")
+			return nil
+		}
+	}
+
+	remoteUsersCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List users",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return remote.printRemote(http.MethodGet, "/users", nil, nil)
+			},
+		},
+		remoteUsersAddCmd,
+		remoteUsersPasswdCmd,
+		&cobra.Command{
+			Use:   "disable <username>",
+			Short: "Disable a user",
+			Args:  cobra.ExactArgs(1),
+			RunE:  setDisabled(true),
+		},
+		&cobra.Command{
+			Use:   "enable <username>",
+			Short: "Enable a disabled user",
+			Args:  cobra.ExactArgs(1),
+			RunE:  setDisabled(false),
+		},
+		&cobra.Command{
+			Use:   "remove <username>",
+			Short: "Remove a user",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				if _, err := remote.do(http.MethodDelete, "/users/"+url.PathEscape(args[0]), nil, nil, nil); err != nil {
+					return errors.Wrap(err, "failed to remove user")
+				}
+				fmt.Println("user removed successfully")
+				return nil
+			},
+		},
+	)
+	remoteCmd.AddCommand(remoteUsersCmd)
+}
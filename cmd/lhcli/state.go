@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zachmann/go-utils/fileutils"
+
+	"github.com/go-oidfed/lighthouse/storage"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Sync the database with a declarative desired-state file",
+	Long: `Sync the database with a declarative desired-state file (YAML or JSON).
+The file can contain subordinates, authority hints, entity configuration metadata and
+additional claims, lifetimes, trust mark types (with owners and issuers), and trust mark specs.
+Sections that are not present in the file are not changed.`,
+}
+
+var statePlanCmd = &cobra.Command{
+	Use:   "plan <file>",
+	Short: "Show the changes needed to reach the desired state",
+	Args:  cobra.ExactArgs(1),
+	RunE:  planState,
+}
+
+var stateApplyCmd = &cobra.Command{
+	Use:   "apply <file>",
+	Short: "Apply the changes needed to reach the desired state",
+	Long: `Apply the changes needed to reach the desired state.
+All changes are applied within a single transaction; if one fails, nothing is changed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: applyState,
+}
+
+var statePrune bool
+var stateJSON bool
+var stateYes bool
+
+func init() {
+	for _, cmd := range []*cobra.Command{
+		statePlanCmd,
+		stateApplyCmd,
+	} {
+		cmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+		cmd.Flags().BoolVar(
+			&statePrune, "prune", false,
+			"delete entries of the managed sections that are not in the desired state",
+		)
+		cmd.Flags().BoolVar(&stateJSON, "json", false, "print the plan as JSON")
+	}
+	stateApplyCmd.Flags().BoolVarP(&stateYes, "yes", "y", false, "apply without asking for confirmation")
+	stateCmd.AddCommand(statePlanCmd)
+	stateCmd.AddCommand(stateApplyCmd)
+	rootCmd.AddCommand(stateCmd)
+}
+
+func loadDesiredState(file string) (*storage.DesiredState, error) {
+	data, err := fileutils.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read desired state file")
+	}
+	return storage.ParseDesiredState(data)
+}
+
+func printPlan(plan *storage.DesiredStatePlan) error {
+	if stateJSON {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Println("No changes. The database matches the desired state.")
+		return nil
+	}
+	symbols := map[storage.DesiredStateAction]string{
+		storage.DesiredStateActionCreate: "+",
+		storage.DesiredStateActionUpdate: "~",
+		storage.DesiredStateActionDelete: "-",
+	}
+	for _, c := range plan.Changes {
+		fmt.Printf("%s %s %s\n", symbols[c.Action], c.Kind, c.Name)
+	}
+	verb := "Plan"
+	if plan.Applied {
+		verb = "Applied"
+	}
+	fmt.Printf(
+		"\n%s: %d to create, %d to update, %d to delete\n", verb,
+		plan.Count(storage.DesiredStateActionCreate),
+		plan.Count(storage.DesiredStateActionUpdate),
+		plan.Count(storage.DesiredStateActionDelete),
+	)
+	return nil
+}
+
+func planState(_ *cobra.Command, args []string) error {
+	state, err := loadDesiredState(args[0])
+	if err != nil {
+		return err
+	}
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	plan, err := storage.PlanDesiredState(backends, state, storage.DesiredStateOptions{Prune: statePrune})
+	if err != nil {
+		return errors.Wrap(err, "failed to compute plan")
+	}
+	return printPlan(plan)
+}
+
+func applyState(_ *cobra.Command, args []string) error {
+	state, err := loadDesiredState(args[0])
+	if err != nil {
+		return err
+	}
+	if err = loadConfig(); err != nil {
+		return err
+	}
+	opts := storage.DesiredStateOptions{
+		Prune: statePrune,
+		Actor: "lhcli",
+	}
+	if !stateYes {
+		plan, err := storage.PlanDesiredState(backends, state, opts)
+		if err != nil {
+			return errors.Wrap(err, "failed to compute plan")
+		}
+		if err = printPlan(plan); err != nil {
+			return err
+		}
+		if len(plan.Changes) == 0 {
+			return nil
+		}
+		if !promptApproval("Do you want to apply these changes") {
+			return errors.New("apply aborted")
+		}
+	}
+	plan, err := storage.ApplyDesiredState(backends, state, opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to apply desired state")
+	}
+	return printPlan(plan)
+}
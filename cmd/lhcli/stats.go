@@ -9,13 +9,18 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/go-oidfed/lighthouse/api/adminapi"
+	apistats "github.com/go-oidfed/lighthouse/api/stats"
 	"github.com/go-oidfed/lighthouse/cmd/lighthouse/config"
 	"github.com/go-oidfed/lighthouse/internal/stats"
 	"github.com/go-oidfed/lighthouse/storage"
 	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
-var statsStorage model.StatsStorageBackend
+var (
+	statsStorage      model.StatsStorageBackend
+	statsSubordinates model.SubordinateStorageBackend
+)
 
 var statsCmd = &cobra.Command{
 	Use:   "stats",
@@ -60,6 +65,32 @@ var statsTopCountriesCmd = &cobra.Command{
 	RunE:  showTopCountries,
 }
 
+var statsTopSubjectsCmd = &cobra.Command{
+	Use:   "subjects",
+	Short: "Show the most requested subjects (sub parameter)",
+	Long: `Show the most requested subjects, i.e. the entities requested with the sub parameter at the
+fetch, resolve, and trust mark endpoints. Use --endpoint to restrict to a single endpoint, e.g. fetch.`,
+	RunE: showTopSubjects,
+}
+
+var statsTopTrustMarkSubjectsCmd = &cobra.Command{
+	Use:   "trust-mark-subjects",
+	Short: "Show the most requested trust mark subjects per trust mark type",
+	RunE:  showTopTrustMarkSubjects,
+}
+
+var statsUnfetchedCmd = &cobra.Command{
+	Use:   "unfetched",
+	Short: "Show active subordinates that are never fetched",
+	Long: `Show active subordinates whose subordinate statement was not fetched in the time range; they are
+candidates for a clean-up. Subordinates created after the start of the range are not reported. Without
+--from, the range is given by --since (default 30 days).
+
+Only the detailed request logs are checked: the range starts at the oldest retained log at the latest,
+and with sampling, fetched subordinates may be listed; both are noted in the output.`,
+	RunE: showUnfetchedSubordinates,
+}
+
 var statsTimeseriesCmd = &cobra.Command{
 	Use:   "timeseries",
 	Short: "Show time series data",
@@ -90,10 +121,20 @@ var statsPurgeCmd = &cobra.Command{
 var statsAggregateCmd = &cobra.Command{
 	Use:   "aggregate",
 	Short: "Run daily aggregation manually",
-	Long:  `Aggregate detailed logs into daily statistics for a specific date`,
+	Long:  `Aggregate detailed logs into hourly and daily statistics for a specific date`,
 	RunE:  aggregateStats,
 }
 
+var statsAnonymizeCmd = &cobra.Command{
+	Use:   "anonymize",
+	Short: "Pseudonymise client data of stored statistics",
+	Long: `Apply the configured privacy settings to already stored request logs
+and to the top clients of the daily statistics. Client data can also be
+removed completely with the --clear-* flags.
+Without --from all stored data is processed.`,
+	RunE: anonymizeStats,
+}
+
 // Flags
 var (
 	statsFromDate     string
@@ -105,6 +146,13 @@ var (
 	statsOutput       string
 	statsPurgeDryRun  bool
 	statsAggregateDay string
+
+	statsTrustMarkType  string
+	statsUnfetchedRange time.Duration
+
+	statsClearIP          bool
+	statsClearUserAgent   bool
+	statsClearQueryParams bool
 )
 
 func init() {
@@ -116,6 +164,16 @@ func init() {
 	// Limit flag for top commands
 	statsTopCmd.PersistentFlags().IntVar(&statsLimit, "limit", 10, "number of results to show")
 
+	// Subject flags
+	statsTopSubjectsCmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "filter by endpoint")
+	statsTopTrustMarkSubjectsCmd.Flags().StringVar(
+		&statsTrustMarkType, "trust-mark-type", "", "filter by trust mark type",
+	)
+	statsUnfetchedCmd.Flags().StringVar(&statsEndpoint, "endpoint", "fetch", "the fetch endpoint")
+	statsUnfetchedCmd.Flags().DurationVar(
+		&statsUnfetchedRange, "since", 30*24*time.Hour, "time range to check if --from is not given",
+	)
+
 	// Timeseries flags
 	statsTimeseriesCmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "filter by endpoint")
 	statsTimeseriesCmd.Flags().StringVar(&statsInterval, "interval", "hour", "time interval (minute, hour, day, week, month)")
@@ -133,19 +191,28 @@ func init() {
 	// Aggregate flags
 	statsAggregateCmd.Flags().StringVar(&statsAggregateDay, "date", "", "date to aggregate (YYYY-MM-DD, default: yesterday)")
 
+	// Anonymize flags
+	statsAnonymizeCmd.Flags().BoolVar(&statsClearIP, "clear-ip", false, "remove client IPs")
+	statsAnonymizeCmd.Flags().BoolVar(&statsClearUserAgent, "clear-user-agent", false, "remove user agents")
+	statsAnonymizeCmd.Flags().BoolVar(&statsClearQueryParams, "clear-query-params", false, "remove query parameters")
+
 	// Build command tree
 	statsTopCmd.AddCommand(statsTopEndpointsCmd)
 	statsTopCmd.AddCommand(statsTopUserAgentsCmd)
 	statsTopCmd.AddCommand(statsTopClientsCmd)
 	statsTopCmd.AddCommand(statsTopCountriesCmd)
+	statsTopCmd.AddCommand(statsTopSubjectsCmd)
+	statsTopCmd.AddCommand(statsTopTrustMarkSubjectsCmd)
 
 	statsCmd.AddCommand(statsSummaryCmd)
 	statsCmd.AddCommand(statsTopCmd)
+	statsCmd.AddCommand(statsUnfetchedCmd)
 	statsCmd.AddCommand(statsTimeseriesCmd)
 	statsCmd.AddCommand(statsLatencyCmd)
 	statsCmd.AddCommand(statsExportCmd)
 	statsCmd.AddCommand(statsPurgeCmd)
 	statsCmd.AddCommand(statsAggregateCmd)
+	statsCmd.AddCommand(statsAnonymizeCmd)
 
 	rootCmd.AddCommand(statsCmd)
 }
@@ -172,6 +239,7 @@ func loadStatsStorage() error {
 	}
 
 	statsStorage = backs.Stats
+	statsSubordinates = backs.Subordinates
 	if statsStorage == nil {
 		return errors.New("stats storage backend not available")
 	}
@@ -366,6 +434,107 @@ func printTopEntries(entries []stats.TopEntry) {
 	}
 }
 
+func showTopSubjects(_ *cobra.Command, _ []string) error {
+	if err := loadStatsStorage(); err != nil {
+		return err
+	}
+
+	from, to, err := parseStatsTimeRange()
+	if err != nil {
+		return err
+	}
+
+	entries, err := statsStorage.GetTopSubjects(from, to, statsEndpoint, statsLimit)
+	if err != nil {
+		return errors.Wrap(err, "failed to get top subjects")
+	}
+
+	fmt.Printf("Top %d Subjects (%s to %s)\n", statsLimit, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if statsEndpoint != "" {
+		fmt.Printf("Endpoint: %s\n", statsEndpoint)
+	}
+	printSubjectStats(entries, false)
+	return nil
+}
+
+func showTopTrustMarkSubjects(_ *cobra.Command, _ []string) error {
+	if err := loadStatsStorage(); err != nil {
+		return err
+	}
+
+	from, to, err := parseStatsTimeRange()
+	if err != nil {
+		return err
+	}
+
+	entries, err := statsStorage.GetTopTrustMarkSubjects(from, to, statsTrustMarkType, statsLimit)
+	if err != nil {
+		return errors.Wrap(err, "failed to get top trust mark subjects")
+	}
+
+	fmt.Printf(
+		"Top %d Trust Mark Subjects (%s to %s)\n", statsLimit, from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)
+	printSubjectStats(entries, true)
+	return nil
+}
+
+func printSubjectStats(entries []stats.SubjectStats, withTrustMarkType bool) {
+	if len(entries) == 0 {
+		fmt.Println("No data available")
+		return
+	}
+	for i, e := range entries {
+		fmt.Printf("%3d. %s\n", i+1, e.Subject)
+		if withTrustMarkType {
+			fmt.Printf("     Trust Mark Type: %s\n", e.TrustMarkType)
+		}
+		fmt.Printf(
+			"     Requests: %d  Errors: %d  Last: %s\n", e.RequestCount, e.ErrorCount,
+			e.LastRequested.Format("2006-01-02 15:04"),
+		)
+	}
+}
+
+func showUnfetchedSubordinates(_ *cobra.Command, _ []string) error {
+	if err := loadStatsStorage(); err != nil {
+		return err
+	}
+
+	from, to, err := parseStatsTimeRange()
+	if err != nil {
+		return err
+	}
+	if statsFromDate == "" {
+		from = to.Add(-statsUnfetchedRange)
+	}
+
+	result, err := adminapi.FindUnfetchedSubordinates(statsStorage, statsSubordinates, from, to, statsEndpoint)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Unfetched Subordinates (%s to %s)\n", result.From.Format("2006-01-02"), result.To.Format("2006-01-02"),
+	)
+	if result.RequestedFrom != nil {
+		fmt.Printf(
+			"  Note: request logs are only retained since %s; older fetches are not known\n",
+			result.From.Format("2006-01-02"),
+		)
+	}
+	if result.Approximate {
+		fmt.Println("  Note: requests were sampled; fetched subordinates may be listed")
+	}
+	for _, sub := range result.Subordinates {
+		fmt.Printf("  %s (created %s)\n", sub.EntityID, time.Unix(int64(sub.CreatedAt), 0).Format("2006-01-02"))
+	}
+	if len(result.Subordinates) == 0 {
+		fmt.Println("All active subordinates were fetched")
+	}
+	return nil
+}
+
 func showTimeseries(_ *cobra.Command, _ []string) error {
 	if err := loadStatsStorage(); err != nil {
 		return err
@@ -477,11 +646,13 @@ func purgeStats(_ *cobra.Command, _ []string) error {
 
 	c := config.Get()
 	detailedCutoff := time.Now().UTC().Add(-c.Stats.DetailedRetention())
+	hourlyCutoff := time.Now().UTC().Add(-c.Stats.HourlyRetention())
 	aggregatedCutoff := time.Now().UTC().Add(-c.Stats.AggregatedRetention())
 
 	if statsPurgeDryRun {
 		fmt.Println("Dry run mode - no data will be deleted")
 		fmt.Printf("Would purge detailed logs before: %s\n", detailedCutoff.Format("2006-01-02"))
+		fmt.Printf("Would purge hourly stats before: %s\n", hourlyCutoff.Format("2006-01-02"))
 		fmt.Printf("Would purge aggregated stats before: %s\n", aggregatedCutoff.Format("2006-01-02"))
 		return nil
 	}
@@ -491,12 +662,18 @@ func purgeStats(_ *cobra.Command, _ []string) error {
 		return errors.Wrap(err, "failed to purge detailed logs")
 	}
 
+	hourly, err := statsStorage.PurgeHourlyStats(hourlyCutoff)
+	if err != nil {
+		return errors.Wrap(err, "failed to purge hourly stats")
+	}
+
 	aggregated, err := statsStorage.PurgeAggregatedStats(aggregatedCutoff)
 	if err != nil {
 		return errors.Wrap(err, "failed to purge aggregated stats")
 	}
 
 	fmt.Printf("Purged %d detailed log entries\n", detailed)
+	fmt.Printf("Purged %d hourly stat entries\n", hourly)
 	fmt.Printf("Purged %d aggregated stat entries\n", aggregated)
 
 	return nil
@@ -522,16 +699,80 @@ func aggregateStats(_ *cobra.Command, _ []string) error {
 
 	fmt.Printf("Aggregating stats for %s...\n", date.Format("2006-01-02"))
 
-	// We need to create an aggregator to run the aggregation
-	// Since we just need to call AggregateDailyStats, we can call it directly
-	if agg, ok := statsStorage.(interface{ AggregateDailyStats(time.Time) error }); ok {
-		if err := agg.AggregateDailyStats(date); err != nil {
-			return errors.Wrap(err, "failed to aggregate stats")
-		}
-	} else {
-		return errors.New("stats storage does not support aggregation")
+	// Aggregates the hours of the day and the day itself
+	c := config.Get()
+	agg := stats.NewAggregator(
+		statsStorage, c.Stats.DetailedRetention(), c.Stats.HourlyRetention(), c.Stats.AggregatedRetention(),
+	)
+	if err := agg.RunOnce(date); err != nil {
+		return errors.Wrap(err, "failed to aggregate stats")
 	}
 
 	fmt.Println("Aggregation completed successfully")
 	return nil
 }
+
+func anonymizeStats(_ *cobra.Command, _ []string) error {
+	if err := loadStatsStorage(); err != nil {
+		return err
+	}
+
+	from, to, err := parseStatsTimeRange()
+	if err != nil {
+		return err
+	}
+	if statsFromDate == "" {
+		from = time.Unix(0, 0).UTC()
+	}
+
+	c := config.Get()
+	privacy := c.Stats.ToAPIConfig().Privacy
+	// Random salts only live in the memory of a process, so the hashes would
+	// not match those of the server
+	if !statsClearIP && privacy.ClientIPMode == apistats.ClientIPModeHash && privacy.HashKey == "" {
+		return errors.New("privacy.hash_key is required to hash the client IPs of stored statistics")
+	}
+	anonymizer := stats.NewAnonymizer(privacy)
+	if anonymizer == nil && !statsClearIP && !statsClearUserAgent && !statsClearQueryParams {
+		return errors.New("no privacy settings configured and no --clear-* flag given, nothing to do")
+	}
+
+	clientIP := func(ip string, at time.Time) string {
+		if statsClearIP {
+			return ""
+		}
+		return anonymizer.ClientIP(ip, at)
+	}
+
+	logs, err := statsStorage.AnonymizeRequestLogs(
+		from, to, func(entry *stats.RequestLog) bool {
+			changed := anonymizer.Apply(entry)
+			if statsClearIP && entry.ClientIP != "" {
+				entry.ClientIP = ""
+				changed = true
+			}
+			if statsClearUserAgent && entry.UserAgent != "" {
+				entry.UserAgent = ""
+				entry.UserAgentHash = 0
+				changed = true
+			}
+			if statsClearQueryParams && len(entry.QueryParams) > 0 {
+				entry.QueryParams = nil
+				changed = true
+			}
+			return changed
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to anonymize request logs")
+	}
+	fmt.Printf("Anonymized %d request log entries\n", logs)
+
+	daily, err := statsStorage.AnonymizeDailyStats(from, to, clientIP)
+	if err != nil {
+		return errors.Wrap(err, "failed to anonymize daily stats")
+	}
+	fmt.Printf("Anonymized %d daily stat entries\n", daily)
+
+	return nil
+}
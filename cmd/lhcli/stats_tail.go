@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/internal/stats"
+)
+
+// tailPollBatch is the number of request logs read at once when polling the
+// database.
+const tailPollBatch = 500
+
+var statsTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Stream recorded requests",
+	Long: `Stream the requests as they are recorded, optionally filtered by endpoint,
+status class, client, and subject.
+
+With --rates a rolling per-endpoint view of the request rate, errors, and
+latency is shown instead, which is updated every --refresh interval.
+
+The requests are read from the database, so they appear once they are
+flushed from the buffer of the server. Unlike 'lhcli remote stats tail',
+this includes the requests of all processes with prefork, regardless of
+where the admin API is served.`,
+	Args: cobra.NoArgs,
+	RunE: tailStats,
+}
+
+// Tail flags, shared with remote stats tail
+var (
+	statsTailStatus  string
+	statsTailClient  string
+	statsTailSubject string
+	statsTailRates   bool
+	statsTailRefresh time.Duration
+	statsTailWindow  time.Duration
+	statsTailJSON    bool
+	statsTailPoll    time.Duration
+)
+
+// addTailFlags adds the flags of the tail commands to cmd.
+func addTailFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&statsEndpoint, "endpoint", "", "filter by endpoint")
+	cmd.Flags().StringVar(&statsTailStatus, "status", "", "filter by status class, e.g. 5xx")
+	cmd.Flags().StringVar(&statsTailClient, "client", "", "filter by client IP")
+	cmd.Flags().StringVar(&statsTailSubject, "subject", "", "filter by requested subject")
+	cmd.Flags().BoolVar(&statsTailRates, "rates", false, "show a rolling per-endpoint rate and latency view")
+	cmd.Flags().DurationVar(&statsTailRefresh, "refresh", 5*time.Second, "refresh interval of the --rates view")
+	cmd.Flags().DurationVar(&statsTailWindow, "window", time.Minute, "rolling window of the --rates view")
+	cmd.Flags().BoolVar(&statsTailJSON, "json", false, "print the requests as JSON lines")
+}
+
+func init() {
+	addTailFlags(statsTailCmd)
+	statsTailCmd.Flags().DurationVar(&statsTailPoll, "poll", time.Second, "interval to poll the database")
+	statsCmd.AddCommand(statsTailCmd)
+}
+
+// tailFilter returns the filter given by the tail flags.
+func tailFilter() (stats.TailFilter, error) {
+	statusClass, err := stats.ParseStatusClass(statsTailStatus)
+	if err != nil {
+		return stats.TailFilter{}, err
+	}
+	return stats.TailFilter{
+		Endpoint:    statsEndpoint,
+		StatusClass: statusClass,
+		ClientIP:    statsTailClient,
+		Subject:     statsTailSubject,
+	}, nil
+}
+
+func tailStats(_ *cobra.Command, _ []string) error {
+	if err := loadStatsStorage(); err != nil {
+		return err
+	}
+	filter, err := tailFilter()
+	if err != nil {
+		return err
+	}
+	return runTail(
+		func(ctx context.Context, entries chan<- *stats.RequestLog) error {
+			return pollRequestLogs(ctx, filter, entries)
+		},
+	)
+}
+
+// pollRequestLogs sends the request logs that are stored from now on and
+// match the filter to entries, until ctx is done.
+func pollRequestLogs(ctx context.Context, filter stats.TailFilter, entries chan<- *stats.RequestLog) error {
+	var lastID uint64
+	latest, err := statsStorage.GetRequestLogsAfter(0, 1)
+	if err != nil {
+		return errors.Wrap(err, "failed to read request logs")
+	}
+	if len(latest) > 0 {
+		lastID = latest[0].ID
+	}
+
+	ticker := time.NewTicker(statsTailPoll)
+	defer ticker.Stop()
+	for {
+		for {
+			logs, err := statsStorage.GetRequestLogsAfter(lastID, tailPollBatch)
+			if err != nil {
+				return errors.Wrap(err, "failed to read request logs")
+			}
+			for i := range logs {
+				lastID = logs[i].ID
+				if !filter.Match(&logs[i]) {
+					continue
+				}
+				select {
+				case entries <- &logs[i]:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			if len(logs) < tailPollBatch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTail runs source until it fails or the command is interrupted, and
+// prints the entries it sends according to the tail flags.
+func runTail(source func(ctx context.Context, entries chan<- *stats.RequestLog) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	entries := make(chan *stats.RequestLog, 100)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- source(ctx, entries)
+	}()
+
+	var rates *tailRates
+	var refresh <-chan time.Time
+	if statsTailRates {
+		rates = newTailRates(statsTailWindow)
+		ticker := time.NewTicker(statsTailRefresh)
+		defer ticker.Stop()
+		refresh = ticker.C
+		rates.print()
+	}
+	for {
+		select {
+		case err := <-errCh:
+			return err
+		case entry := <-entries:
+			if rates != nil {
+				rates.add(entry)
+			} else {
+				printTailEntry(entry)
+			}
+		case <-refresh:
+			rates.print()
+		}
+	}
+}
+
+// printTailEntry prints a single request.
+func printTailEntry(entry *stats.RequestLog) {
+	if statsTailJSON {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			fmt.Println(string(data))
+		}
+		return
+	}
+	line := fmt.Sprintf(
+		"%s  %-6s %3d %6dms  %-30s %-15s",
+		entry.Timestamp.Local().Format("15:04:05.000"), entry.Method, entry.StatusCode, entry.DurationMs,
+		entry.Endpoint, entry.ClientIP,
+	)
+	if entry.Subject != "" {
+		line += "  " + entry.Subject
+	}
+	if entry.ErrorType != "" {
+		line += "  [" + entry.ErrorType + "]"
+	}
+	fmt.Println(strings.TrimRight(line, " "))
+}
+
+// tailRates holds the requests of a rolling window to show per-endpoint
+// rates and latencies.
+type tailRates struct {
+	window  time.Duration
+	started time.Time
+	entries []*stats.RequestLog
+}
+
+func newTailRates(window time.Duration) *tailRates {
+	return &tailRates{
+		window:  window,
+		started: time.Now(),
+	}
+}
+
+func (r *tailRates) add(entry *stats.RequestLog) {
+	r.entries = append(r.entries, entry)
+}
+
+// tailEndpointRate holds the statistics of an endpoint in the window.
+type tailEndpointRate struct {
+	endpoint  string
+	requests  int64
+	errors    int64
+	durations []int
+	totalMs   int64
+}
+
+// print removes the requests that left the window and prints the
+// statistics per endpoint. Counts are extrapolated with the sample rates.
+func (r *tailRates) print() {
+	now := time.Now()
+	cutoff := now.Add(-r.window)
+	kept := r.entries[:0]
+	for _, e := range r.entries {
+		if e.Timestamp.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	clear(r.entries[len(kept):])
+	r.entries = kept
+
+	byEndpoint := make(map[string]*tailEndpointRate)
+	for _, e := range r.entries {
+		rate, ok := byEndpoint[e.Endpoint]
+		if !ok {
+			rate = &tailEndpointRate{endpoint: e.Endpoint}
+			byEndpoint[e.Endpoint] = rate
+		}
+		weight := int64(max(e.SampleRate, 1))
+		rate.requests += weight
+		if e.StatusCode >= 400 {
+			rate.errors += weight
+		}
+		rate.durations = append(rate.durations, e.DurationMs)
+		rate.totalMs += int64(e.DurationMs)
+	}
+	rates := make([]*tailEndpointRate, 0, len(byEndpoint))
+	for _, rate := range byEndpoint {
+		rates = append(rates, rate)
+	}
+	sort.Slice(
+		rates, func(i, j int) bool {
+			if rates[i].requests != rates[j].requests {
+				return rates[i].requests > rates[j].requests
+			}
+			return rates[i].endpoint < rates[j].endpoint
+		},
+	)
+
+	// Until the window is filled, rates refer to the elapsed time
+	seconds := min(now.Sub(r.started), r.window).Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+
+	if isTerminal(os.Stdout) {
+		fmt.Print("\033[H\033[2J")
+	} else {
+		fmt.Println()
+	}
+	fmt.Printf("Requests of the last %s (%s)\n", r.window, now.Format("15:04:05"))
+	fmt.Println(strings.Repeat("=", 84))
+	fmt.Printf("%-30s %10s %10s %8s %10s %10s\n", "ENDPOINT", "REQ/S", "ERRORS", "ERR%", "AVG", "P95")
+	if len(rates) == 0 {
+		fmt.Println("No requests")
+		return
+	}
+	for _, rate := range rates {
+		sort.Ints(rate.durations)
+		p95 := rate.durations[(len(rate.durations)-1)*95/100]
+		endpoint := rate.endpoint
+		if len(endpoint) > 30 {
+			endpoint = endpoint[:27] + "..."
+		}
+		fmt.Printf(
+			"%-30s %10.2f %10d %7.1f%% %8.1fms %8dms\n",
+			endpoint, float64(rate.requests)/seconds, rate.errors,
+			100*float64(rate.errors)/float64(rate.requests),
+			float64(rate.totalMs)/float64(len(rate.durations)), p95,
+		)
+	}
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/go-oidfed/lib/jwx"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"github.com/zachmann/go-utils/fileutils"
 
 	"github.com/go-oidfed/lib"
 
@@ -30,8 +29,8 @@ var subordinatesAddCmd = &cobra.Command{
 var subordinatesRemoveCmd = &cobra.Command{
 	Use:   "remove",
 	Short: "Remove a subordinate",
-	Long:  `Remove a subordinate`,
-	Args:  cobra.ExactArgs(1),
+	Long:  `Remove a subordinate, or all subordinates matching the label selector given with --selector`,
+	Args:  argsWithOrWithoutSelector(1, 0),
 	RunE:  removeSubordinate,
 }
 var subordinatesBlockCmd = &cobra.Command{
@@ -50,8 +49,9 @@ var subordinatesManageRequestsCmd = &cobra.Command{
 var subordinatesStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Update subordinate status",
-	Long:  `Update the status of a subordinate to one of: active, blocked, pending, inactive`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Update the status of a subordinate to one of: active, blocked, pending, inactive.
+If --selector is given, only the status is passed and all subordinates matching the label selector are updated.`,
+	Args: argsWithOrWithoutSelector(2, 1),
 	RunE:  updateSubordinateStatus,
 }
 
@@ -68,9 +68,16 @@ func init() {
 			"s) in the jwks format; used to verify that the entity's entity"+
 			" configuration is signed with a key from this set.",
 	)
+	subordinatesAddCmd.Flags().StringArrayVarP(&labelArgs, "label", "l", []string{}, "label in the form key=value")
 	subordinatesRemoveCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesRemoveCmd.Flags().StringVarP(
+		&labelSelector, "selector", "s", "", "remove all subordinates matching this label selector",
+	)
 	subordinatesBlockCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
 	subordinatesStatusCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	subordinatesStatusCmd.Flags().StringVarP(
+		&labelSelector, "selector", "s", "", "update all subordinates matching this label selector",
+	)
 	subordinatesManageRequestsCmd.Flags().StringVarP(
 		&configFile, "config", "c", "config.yaml", "the config file to use",
 	)
@@ -96,19 +103,47 @@ func addSubordinate(_ *cobra.Command, args []string) error {
 	if err := subordinateStorage.Load(); err != nil {
 		return errors.Wrap(err, "failed to load subordinates from storage")
 	}
+	entityConfig, err := fetchEntityConfiguration(args[0])
+	if err != nil {
+		return err
+	}
+	if len(entityTypes) == 0 {
+		entityTypes = entityConfig.Metadata.GuessEntityTypes()
+	}
+	labels, err := parseLabelArgs(labelArgs)
+	if err != nil {
+		return err
+	}
+	subEntityTypes := make([]model.SubordinateEntityType, len(entityTypes))
+	for i, t := range entityTypes {
+		subEntityTypes[i] = model.SubordinateEntityType{EntityType: t}
+	}
+	info := model.ExtendedSubordinateInfo{
+		JWKS: model.NewJWKS(entityConfig.JWKS),
+		BasicSubordinateInfo: model.BasicSubordinateInfo{
+			EntityID:               entityConfig.Subject,
+			SubordinateEntityTypes: subEntityTypes,
+			Labels:                 model.NewSubordinateLabels(labels),
+		},
+	}
+	if err := subordinateStorage.Add(info); err != nil {
+		return errors.Wrap(err, "failed to add subordinate to storage")
+	}
+	fmt.Println("subordinate added successfully")
+	return nil
+}
+
+// fetchEntityConfiguration obtains and verifies the entity configuration of
+// the passed entity, using the keys from --jwks if given.
+func fetchEntityConfiguration(entityID string) (*oidfed.EntityStatement, error) {
 	var entityJWKS jwx.JWKS
 	if jwksFile != "" {
-		data, err := fileutils.ReadFile(jwksFile)
-		if err != nil {
-			return errors.Wrap(err, "failed to read jwks file")
-		}
-		if err = json.Unmarshal(data, &entityJWKS); err != nil {
-			return errors.Wrap(err, "failed to unmarshal jwks file")
+		var err error
+		if entityJWKS, err = readJWKSFile(jwksFile); err != nil {
+			return nil, err
 		}
 	}
 
-	entityID := args[0]
-
 	// We use a TrustResolver to obtain the entity configuration
 	// instead of simply fetching the entity configuration,
 	// because this will also verify the signature.
@@ -124,28 +159,9 @@ func addSubordinate(_ *cobra.Command, args []string) error {
 	}
 	chains := resolver.ResolveToValidChainsWithoutVerifyingMetadata()
 	if len(chains) == 0 {
-		return errors.New("could not obtain or verify entity configuration")
-	}
-	entityConfig := chains[0][0]
-	if len(entityTypes) == 0 {
-		entityTypes = entityConfig.Metadata.GuessEntityTypes()
+		return nil, errors.New("could not obtain or verify entity configuration")
 	}
-	subEntityTypes := make([]model.SubordinateEntityType, len(entityTypes))
-	for i, t := range entityTypes {
-		subEntityTypes[i] = model.SubordinateEntityType{EntityType: t}
-	}
-	info := model.ExtendedSubordinateInfo{
-		JWKS: model.NewJWKS(entityConfig.JWKS),
-		BasicSubordinateInfo: model.BasicSubordinateInfo{
-			EntityID:               entityConfig.Subject,
-			SubordinateEntityTypes: subEntityTypes,
-		},
-	}
-	if err := subordinateStorage.Add(info); err != nil {
-		return errors.Wrap(err, "failed to add subordinate to storage")
-	}
-	fmt.Println("subordinate added successfully")
-	return nil
+	return chains[0][0], nil
 }
 
 func removeSubordinate(_ *cobra.Command, args []string) error {
@@ -156,9 +172,25 @@ func removeSubordinate(_ *cobra.Command, args []string) error {
 		return errors.Wrap(err, "failed to load subordinates from storage")
 	}
 
+	if labelSelector != "" {
+		return forEachSelectedSubordinate(
+			labelSelector, "removed", func(tx *model.Backends, info model.BasicSubordinateInfo) error {
+				return tx.DeleteSubordinate(
+					&info,
+					model.WithMessage(fmt.Sprintf("subordinate deleted: %s (bulk: %s)", info.EntityID, labelSelector)),
+					model.WithActor("lhcli"),
+				)
+			},
+		)
+	}
+
 	entityID := args[0]
 
-	if err := subordinateStorage.Delete(entityID); err != nil {
+	if err := withSubordinate(
+		entityID, func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error {
+			return tx.DeleteSubordinate(&info.BasicSubordinateInfo, model.WithActor("lhcli"))
+		},
+	); err != nil {
 		return errors.Wrap(err, "failed to remove subordinate from storage")
 	}
 	fmt.Println("subordinate removed successfully")
@@ -175,7 +207,11 @@ func blockSubordinate(_ *cobra.Command, args []string) error {
 
 	entityID := args[0]
 
-	if err := subordinateStorage.UpdateStatus(entityID, model.StatusBlocked); err != nil {
+	if err := withSubordinate(
+		entityID, func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error {
+			return tx.UpdateSubordinateStatus(info, model.StatusBlocked, model.WithActor("lhcli"))
+		},
+	); err != nil {
 		return errors.Wrap(err, "failed to block subordinate in storage")
 	}
 	fmt.Println("subordinate blocked successfully")
@@ -190,15 +226,38 @@ func updateSubordinateStatus(_ *cobra.Command, args []string) error {
 		return errors.Wrap(err, "failed to load subordinates from storage")
 	}
 
-	entityID := args[0]
-	statusStr := args[1]
-
+	statusStr := args[len(args)-1]
 	status, err := model.ParseStatus(statusStr)
 	if err != nil {
 		return errors.Wrap(err, "invalid status (valid values: active, blocked, pending, inactive)")
 	}
 
-	if err := subordinateStorage.UpdateStatus(entityID, status); err != nil {
+	if labelSelector != "" {
+		return forEachSelectedSubordinate(
+			labelSelector, fmt.Sprintf("updated to '%s'", status),
+			func(tx *model.Backends, basic model.BasicSubordinateInfo) error {
+				info, err := tx.Subordinates.Get(basic.EntityID)
+				if err != nil {
+					return err
+				}
+				return tx.UpdateSubordinateStatus(
+					info, status,
+					model.WithMessage(
+						fmt.Sprintf("status changed from %s to %s (bulk: %s)", info.Status, status, labelSelector),
+					),
+					model.WithActor("lhcli"),
+				)
+			},
+		)
+	}
+
+	entityID := args[0]
+
+	if err := withSubordinate(
+		entityID, func(tx *model.Backends, info *model.ExtendedSubordinateInfo) error {
+			return tx.UpdateSubordinateStatus(info, status, model.WithActor("lhcli"))
+		},
+	); err != nil {
 		return errors.Wrap(err, "failed to update subordinate status")
 	}
 	fmt.Printf("subordinate status updated to '%s' successfully\n", status)
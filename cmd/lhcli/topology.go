@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/api/adminapi"
+	"github.com/go-oidfed/lighthouse/cmd/lighthouse/config"
+)
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology [trust_anchor]",
+	Short: "Crawl and export the federation topology",
+	Long: `Crawl the federation below this entity or the passed trust anchor and export it.
+
+Starting at the entity configuration of the start entity, the federation_list_endpoint of each entity is queried
+recursively, and the subordinate statements and entity configurations of all subordinates are fetched. Each entity
+is annotated with its entity types, trust marks, the expiration of its entity configuration and subordinate
+statement, and any validation errors found.
+
+If no trust anchor is passed, the crawl starts at the entity ID from the configuration file.
+The topology is exported as a JSON tree, as a GraphViz DOT graph, or as a Mermaid flowchart.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: crawlTopology,
+}
+
+var (
+	topologyFormat      string
+	topologyOutput      string
+	topologyConcurrency int
+	topologyMaxDepth    int
+)
+
+func init() {
+	topologyCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	topologyCmd.Flags().StringVarP(
+		&topologyFormat, "format", "f", string(adminapi.TopologyFormatJSON),
+		"the output format: json, dot, or mermaid",
+	)
+	topologyCmd.Flags().StringVarP(
+		&topologyOutput, "output", "o", "", "the file to write the topology to; if not set it is written to stdout",
+	)
+	topologyCmd.Flags().IntVar(
+		&topologyConcurrency, "concurrency", adminapi.DefaultTopologyCrawlConcurrency,
+		"maximum number of concurrent requests",
+	)
+	topologyCmd.Flags().IntVar(&topologyMaxDepth, "max-depth", 0, "maximum depth of the crawl; 0 means unlimited")
+	rootCmd.AddCommand(topologyCmd)
+}
+
+func crawlTopology(_ *cobra.Command, args []string) error {
+	format, err := adminapi.ParseTopologyFormat(topologyFormat)
+	if err != nil {
+		return err
+	}
+	var start string
+	if len(args) > 0 {
+		start = args[0]
+	} else {
+		if err = config.Load(configFile); err != nil {
+			return err
+		}
+		start = config.Get().EntityID
+	}
+
+	root, err := adminapi.CrawlTopology(
+		context.Background(), start, adminapi.TopologyCrawlOptions{
+			Concurrency: topologyConcurrency,
+			MaxDepth:    topologyMaxDepth,
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to crawl topology")
+	}
+
+	out := os.Stdout
+	if topologyOutput != "" {
+		f, err := os.Create(topologyOutput)
+		if err != nil {
+			return errors.Wrap(err, "failed to create output file")
+		}
+		defer f.Close()
+		out = f
+	}
+	if err = adminapi.EncodeTopology(out, root, format); err != nil {
+		return errors.Wrap(err, "failed to write topology")
+	}
+	return nil
+}
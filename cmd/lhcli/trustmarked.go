@@ -8,6 +8,8 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
 var tmCmd = &cobra.Command{
@@ -49,9 +51,46 @@ var trustmarkManageRequestsCmd = &cobra.Command{
 	RunE:  manageTrustMarkRequests,
 }
 
+var trustmarkRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke issued trust marks",
+	Long: `Revoke issued trust marks, either a single one by its JTI or all active
+ones of a subject (--subject), of a trust mark type (--type), or of a subject
+for a trust mark type (both).
+
+Revoking does not change the eligibility of the subject; use 'block' or
+'remove' to prevent new trust marks from being issued.
+
+The trust marks are revoked directly in the database; a running server
+keeps serving cached trust marks until their cache TTL expires. Use
+'lhcli remote trustmarks revoke' to also invalidate the caches of the
+server.`,
+	Args: cobra.NoArgs,
+	RunE: revokeTrustMarks,
+}
+
 var trustMarkType string
 var printFlag bool
 
+// Revocation flags, shared with remote trustmarks revoke
+var (
+	revokeJTI     string
+	revokeType    string
+	revokeSubject string
+	revokeReason  string
+)
+
+// addRevokeFlags adds the flags of the revoke commands to cmd.
+func addRevokeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&revokeJTI, "jti", "", "revoke the trust mark with this JTI")
+	cmd.Flags().StringVar(&revokeType, "type", "", "revoke the active trust marks of this trust mark type")
+	cmd.Flags().StringVar(&revokeSubject, "subject", "", "revoke the active trust marks of this subject")
+	cmd.Flags().StringVar(&revokeReason, "reason", "", "the reason for the revocation")
+	cmd.MarkFlagsMutuallyExclusive("jti", "type")
+	cmd.MarkFlagsMutuallyExclusive("jti", "subject")
+	cmd.MarkFlagsOneRequired("jti", "type", "subject")
+}
+
 func init() {
 	trustmarkAddCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
 	trustmarkRemoveCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
@@ -61,11 +100,14 @@ func init() {
 		&printFlag, "print", "p", false, "if set only the requests will be printed, no management is triggered",
 	)
 	trustmarkManageRequestsCmd.Flags().StringVar(&trustMarkType, "id", "", "if set only this trust mark id is handled")
+	trustmarkRevokeCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
+	addRevokeFlags(trustmarkRevokeCmd)
 	tmCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "the config file to use")
 	tmCmd.AddCommand(trustmarkAddCmd)
 	tmCmd.AddCommand(trustmarkRemoveCmd)
 	tmCmd.AddCommand(trustmarkBlockCmd)
 	tmCmd.AddCommand(trustmarkManageRequestsCmd)
+	tmCmd.AddCommand(trustmarkRevokeCmd)
 	rootCmd.AddCommand(tmCmd)
 }
 
@@ -123,6 +165,42 @@ func blockTrustMark(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func revokeTrustMarks(_ *cobra.Command, _ []string) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	revocation := model.TrustMarkRevocation{
+		Reason: revokeReason,
+		Actor:  "lhcli",
+	}
+
+	if revokeJTI != "" {
+		instance, revoked, err := backends.TrustMarkInstances.Revoke(revokeJTI, revocation)
+		if err != nil {
+			return errors.Wrap(err, "failed to revoke trust mark")
+		}
+		if !revoked {
+			fmt.Printf("trust mark '%s' was already %s\n", instance.JTI, instance.RevocationDescription())
+			return nil
+		}
+		fmt.Printf(
+			"trust mark '%s' of type '%s' for '%s' revoked\n", instance.JTI, instance.TrustMarkType,
+			instance.Subject,
+		)
+		return nil
+	}
+
+	revoked, err := backends.TrustMarkInstances.RevokeActive(revokeType, revokeSubject, revocation)
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke trust marks")
+	}
+	for _, instance := range revoked {
+		fmt.Printf("%s\t%s\t%s\n", instance.JTI, instance.TrustMarkType, instance.Subject)
+	}
+	fmt.Printf("%d trust mark(s) revoked\n", len(revoked))
+	return nil
+}
+
 func manageTrustMarkRequests(cmd *cobra.Command, _ []string) error {
 	if err := loadConfig(); err != nil {
 		return err
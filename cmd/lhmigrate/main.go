@@ -21,13 +21,14 @@ func usage() {
 	_, _ = fmt.Fprintf(os.Stderr, "\n")
 	_, _ = fmt.Fprintf(os.Stderr, "Subcommands:\n")
 	_, _ = fmt.Fprintf(
-		os.Stderr, "  all       Run all migration steps in sequence (config, keys, config2db, db, cleanup)\n",
+		os.Stderr, "  all         Run all migration steps in sequence (config, keys, config2db, db, cleanup)\n",
 	)
-	_, _ = fmt.Fprintf(os.Stderr, "  keys      Migrate signing keys (subcommands: public, kms) [alias: signing]\n")
-	_, _ = fmt.Fprintf(os.Stderr, "  pem-to-db Migrate filesystem KMS private keys to database\n")
-	_, _ = fmt.Fprintf(os.Stderr, "  config2db Migrate config file values to database\n")
-	_, _ = fmt.Fprintf(os.Stderr, "  db        Migrate legacy storage data (JSON/Badger) to GORM-based database\n")
-	_, _ = fmt.Fprintf(os.Stderr, "  config    Migrate or update configuration to new format\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  keys        Migrate signing keys (subcommands: public, kms) [alias: signing]\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  pem-to-db   Migrate filesystem KMS private keys to database\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  pem-encrypt Encrypt or re-key private keys stored in the database\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  config2db   Migrate config file values to database\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  db          Migrate legacy storage data (JSON/Badger) to GORM-based database\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  config      Migrate or update configuration to new format\n")
 	_, _ = fmt.Fprintf(os.Stderr, "\n")
 	_, _ = fmt.Fprintf(os.Stderr, "Use 'lhmigrate <subcommand> -h' for help on a subcommand.\n")
 }
@@ -429,6 +430,8 @@ func main() {
 		code = keysCmd(os.Args[2:])
 	case "pem-to-db":
 		code = runPEMMigration(os.Args[2:])
+	case "pem-encrypt":
+		code = pemEncryptCmd(os.Args[2:])
 	case "config2db":
 		code = config2dbCmd(os.Args[2:])
 	case "db":
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/go-oidfed/lighthouse/storage"
+)
+
+// Environment variables that hold the key-encryption keys if no files are
+// passed; these are the same variables that are used by lighthouse.
+const (
+	envKEK          = "LH_SIGNING_DB_ENCRYPTION_KEK"
+	envPreviousKEKs = "LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEKS"
+)
+
+// kekFlags are the flags to pass key-encryption keys.
+type kekFlags struct {
+	kekFile          string
+	previousKEKFiles string
+}
+
+func (f *kekFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(
+		&f.kekFile, "kek-file", "",
+		"File with the base64 encoded key-encryption key (default: $"+envKEK+")",
+	)
+	fs.StringVar(
+		&f.previousKEKFiles, "previous-kek-files", "",
+		"Comma-separated files with previous key-encryption keys (default: $"+envPreviousKEKs+")",
+	)
+}
+
+// provider returns the KEKProvider for the flags, or nil if no
+// key-encryption key was passed.
+func (f *kekFlags) provider() (storage.KEKProvider, error) {
+	var current storage.KEK
+	var err error
+	switch {
+	case f.kekFile != "":
+		current, err = storage.ReadKEKFile(f.kekFile)
+	case os.Getenv(envKEK) != "":
+		current, err = storage.ParseKEK(os.Getenv(envKEK))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var previous []storage.KEK
+	if f.previousKEKFiles != "" {
+		for _, file := range strings.Split(f.previousKEKFiles, ",") {
+			kek, err := storage.ReadKEKFile(strings.TrimSpace(file))
+			if err != nil {
+				return nil, err
+			}
+			previous = append(previous, kek)
+		}
+	} else if env := os.Getenv(envPreviousKEKs); env != "" {
+		for _, k := range strings.Split(env, ",") {
+			kek, err := storage.ParseKEK(k)
+			if err != nil {
+				return nil, err
+			}
+			previous = append(previous, kek)
+		}
+	}
+	return storage.NewStaticKEKProvider(current, previous...), nil
+}
+
+// pemEncryptCmd encrypts the private keys stored by the database KMS and
+// re-keys private keys encrypted with a previous key-encryption key.
+func pemEncryptCmd(args []string) int {
+	fs := flag.NewFlagSet("pem-encrypt", flag.ExitOnError)
+	var (
+		types   string
+		dbDSN   string
+		dbType  string
+		verbose bool
+		keks    kekFlags
+	)
+
+	fs.StringVar(&types, "type", "federation", "Comma-separated key type identifiers (e.g., 'federation,trust_marks')")
+	fs.StringVar(&types, "t", "federation", "Comma-separated key type identifiers (shorthand)")
+	fs.StringVar(&dbType, "db-type", "sqlite", "Database type: sqlite|mysql|postgres")
+	fs.StringVar(&dbDSN, "db-dsn", "", "Database DSN (file path for sqlite, connection string for mysql/postgres)")
+	keks.register(fs)
+	fs.BoolVar(&verbose, "verbose", false, "Verbose logging")
+	fs.BoolVar(&verbose, "v", false, "Verbose logging (shorthand)")
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(
+			os.Stderr,
+			"Usage: lhmigrate pem-encrypt --db-dsn <dsn> [--db-type=<type>] [--type <typeIDs>] "+
+				"[--kek-file <file>] [--previous-kek-files <files>]\n",
+		)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if dbDSN == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "--db-dsn is required")
+		fs.Usage()
+		return 2
+	}
+
+	kek, err := keks.provider()
+	if err != nil {
+		log.WithError(err).Error("failed to load key-encryption keys")
+		return 1
+	}
+	if kek == nil {
+		_, _ = fmt.Fprintf(os.Stderr, "--kek-file or $%s is required\n", envKEK)
+		fs.Usage()
+		return 2
+	}
+
+	driver, err := storage.ParseDriverType(dbType)
+	if err != nil {
+		log.WithError(err).Error("invalid database type")
+		return 1
+	}
+	db, err := storage.Connect(
+		storage.Config{
+			Driver: driver,
+			DSN:    dbDSN,
+			Debug:  verbose,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to initialize database")
+		return 1
+	}
+
+	for _, typeID := range strings.Split(types, ",") {
+		typeID = strings.TrimSpace(typeID)
+		res, err := storage.NewEncryptedDBPEMStorer(db, typeID, kek).Reencrypt()
+		if err != nil {
+			log.WithError(err).WithField("type", typeID).Error("failed to encrypt private keys")
+			return 1
+		}
+		log.WithFields(
+			log.Fields{
+				"type":      typeID,
+				"encrypted": res.Encrypted,
+				"rekeyed":   res.Rekeyed,
+			},
+		).Info("encrypted private keys")
+	}
+
+	log.Info("encryption completed")
+	log.Info("\nTo use the encrypted private keys, configure the key-encryption key:")
+	log.Info("  signing:")
+	log.Info("    db:")
+	log.Info("      encryption:")
+	log.Info("        kek_file: <file>")
+	return 0
+}
@@ -31,6 +31,7 @@ func pemToDBCmd(args []string) int {
 		destDSN string
 		dbType  string
 		verbose bool
+		keks    kekFlags
 	)
 
 	fs.StringVar(&source, "source", "", "Path to legacy filesystem KMS directory")
@@ -39,13 +40,15 @@ func pemToDBCmd(args []string) int {
 	fs.StringVar(&typeID, "t", "federation", "Key type identifier (shorthand)")
 	fs.StringVar(&dbType, "db-type", "sqlite", "Database type: sqlite|mysql|postgres")
 	fs.StringVar(&destDSN, "db-dsn", "", "Database DSN (file path for sqlite, connection string for mysql/postgres)")
+	keks.register(fs)
 	fs.BoolVar(&verbose, "verbose", false, "Verbose logging")
 	fs.BoolVar(&verbose, "v", false, "Verbose logging (shorthand)")
 
 	fs.Usage = func() {
 		_, _ = fmt.Fprintf(
 			os.Stderr,
-			"Usage: lhmigrate pem-to-db --source <kms_dir> --type <typeID> --db-dsn <dsn> [--db-type=<type>]\n",
+			"Usage: lhmigrate pem-to-db --source <kms_dir> --type <typeID> --db-dsn <dsn> [--db-type=<type>] "+
+				"[--kek-file <file>]\n",
 		)
 		fs.PrintDefaults()
 	}
@@ -97,8 +100,17 @@ func pemToDBCmd(args []string) int {
 		return 1
 	}
 
-	// Initialize DBPEMStorer and ensure table exists
+	// Initialize DBPEMStorer and ensure table exists; if a key-encryption key
+	// is given, private keys are stored encrypted
+	kek, err := keks.provider()
+	if err != nil {
+		log.WithError(err).Error("failed to load key-encryption keys")
+		return 1
+	}
 	pemStorer := storage.NewDBPEMStorer(db, typeID)
+	if kek != nil {
+		pemStorer = storage.NewEncryptedDBPEMStorer(db, typeID, kek)
+	}
 	if err = pemStorer.Load(); err != nil {
 		log.WithError(err).Error("failed to initialize private_keys table")
 		return 1
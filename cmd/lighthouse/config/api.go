@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/go-oidfed/lighthouse"
 	"github.com/go-oidfed/lighthouse/storage"
 )
@@ -31,6 +33,7 @@ type apiConf struct {
 //   - LH_API_ADMIN_PASSWORD_HASHING_*: Password hashing parameters
 //   - LH_API_ADMIN_ACTOR_HEADER: HTTP header name for actor extraction
 //   - LH_API_ADMIN_ACTOR_SOURCE: Preferred actor source ("basic_auth" or "header")
+//   - LH_API_ADMIN_TOPOLOGY_CRAWL_TIMEOUT: Time limit of topology crawls
 //   - LH_API_ADMIN_CORS_*: CORS configuration (see CORSConf)
 //   - LH_API_ADMIN_TLS_ENABLED: Enable TLS for admin API
 //   - LH_API_ADMIN_TLS_CERT: Path to TLS certificate for admin API
@@ -56,6 +59,11 @@ type adminAPIConf struct {
 	// The system tries the preferred source first, then falls back to the other.
 	// Env: LH_API_ADMIN_ACTOR_SOURCE
 	ActorSource string `yaml:"actor_source" envconfig:"ACTOR_SOURCE"`
+	// TopologyCrawlTimeout limits the duration of topology crawls started
+	// through the admin API.
+	// Default: 10m
+	// Env: LH_API_ADMIN_TOPOLOGY_CRAWL_TIMEOUT
+	TopologyCrawlTimeout time.Duration `yaml:"topology_crawl_timeout" envconfig:"TOPOLOGY_CRAWL_TIMEOUT"`
 	// CORS holds CORS configuration for the admin API.
 	// Env prefix: LH_API_ADMIN_CORS_
 	CORS lighthouse.CORSConf `yaml:"cors" envconfig:"CORS"`
@@ -77,8 +85,9 @@ var defaultAPIConf = apiConf{
 			KeyLen:      64,
 			SaltLen:     32,
 		},
-		ActorHeader: "X-Actor",
-		ActorSource: "basic_auth",
+		ActorHeader:          "X-Actor",
+		ActorSource:          "basic_auth",
+		TopologyCrawlTimeout: 10 * time.Minute,
 		CORS: lighthouse.CORSConf{
 			Enabled:          false,
 			AllowOrigins:     "*",
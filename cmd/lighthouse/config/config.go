@@ -33,6 +33,7 @@ import (
 //   - LH_FEDERATION_DATA_*: Federation configuration (see federationConf)
 //   - LH_API_*: API configuration (see apiConf)
 //   - LH_STATS_*: Statistics configuration (see StatsConf)
+//   - LH_TRACING_*: Tracing configuration (see TracingConf)
 type Config struct {
 	// EntityID is the entity identifier URL.
 	// Env: LH_ENTITY_ID
@@ -61,6 +62,9 @@ type Config struct {
 	// Stats holds statistics configuration.
 	// Env prefix: LH_STATS_
 	Stats StatsConf `yaml:"stats" envconfig:"STATS"`
+	// Tracing holds OpenTelemetry tracing configuration.
+	// Env prefix: LH_TRACING_
+	Tracing TracingConf `yaml:"tracing" envconfig:"TRACING"`
 }
 
 type configValidator interface {
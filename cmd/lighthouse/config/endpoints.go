@@ -17,7 +17,7 @@ import (
 //
 // Environment variables (with prefix LH_ENDPOINTS_):
 //   - LH_ENDPOINTS_FETCH_PATH, LH_ENDPOINTS_FETCH_URL, LH_ENDPOINTS_FETCH_STATEMENT_LIFETIME
-//   - LH_ENDPOINTS_LIST_PATH, LH_ENDPOINTS_LIST_URL
+//   - LH_ENDPOINTS_LIST_PATH, LH_ENDPOINTS_LIST_URL, LH_ENDPOINTS_LIST_ALLOW_LABEL_SELECTOR
 //   - LH_ENDPOINTS_RESOLVE_PATH, LH_ENDPOINTS_RESOLVE_URL, LH_ENDPOINTS_RESOLVE_*
 //   - LH_ENDPOINTS_TRUST_MARK_STATUS_PATH, LH_ENDPOINTS_TRUST_MARK_STATUS_URL
 //   - LH_ENDPOINTS_TRUST_MARK_LIST_PATH, LH_ENDPOINTS_TRUST_MARK_LIST_URL
@@ -33,7 +33,7 @@ type Endpoints struct {
 	FetchEndpoint lighthouse.EndpointConf `yaml:"fetch" envconfig:"FETCH"`
 	// ListEndpoint configures the list endpoint.
 	// Env prefix: LH_ENDPOINTS_LIST_
-	ListEndpoint lighthouse.EndpointConf `yaml:"list" envconfig:"LIST"`
+	ListEndpoint listEndpointConf `yaml:"list" envconfig:"LIST"`
 	// ResolveEndpoint configures the resolve endpoint.
 	// Env prefix: LH_ENDPOINTS_RESOLVE_
 	ResolveEndpoint resolveEndpointConf `yaml:"resolve" envconfig:"RESOLVE"`
@@ -79,6 +79,19 @@ type checkedEndpointConf struct {
 	CheckerConfig lighthouse.EntityCheckerConfig `yaml:"checker" envconfig:"-"`
 }
 
+// listEndpointConf holds subordinate listing endpoint configuration.
+//
+// Environment variables (with prefix LH_ENDPOINTS_LIST_):
+//   - LH_ENDPOINTS_LIST_PATH: Endpoint path
+//   - LH_ENDPOINTS_LIST_URL: Endpoint URL
+//   - LH_ENDPOINTS_LIST_ALLOW_LABEL_SELECTOR: Allow filtering by subordinate labels
+type listEndpointConf struct {
+	lighthouse.EndpointConf `yaml:",inline"`
+	// AllowLabelSelector enables the non-standard label_selector query parameter.
+	// Env: LH_ENDPOINTS_LIST_ALLOW_LABEL_SELECTOR
+	AllowLabelSelector bool `yaml:"allow_label_selector" envconfig:"ALLOW_LABEL_SELECTOR"`
+}
+
 // resolveEndpointConf holds resolve endpoint configuration.
 //
 // Environment variables (with prefix LH_ENDPOINTS_RESOLVE_):
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"slices"
+
 	"github.com/pkg/errors"
 
 	"github.com/go-oidfed/lighthouse"
@@ -12,7 +14,7 @@ import (
 // or use the Admin API to manage them at runtime.
 //
 // Environment variables (with prefix LH_SIGNING_):
-//   - LH_SIGNING_KMS: Key management system ("filesystem", "pkcs11", or "db")
+//   - LH_SIGNING_KMS: Key management system ("filesystem", "pkcs11", "db", or "transit")
 //   - LH_SIGNING_PK_BACKEND: Public key storage backend ("filesystem" or "db")
 //   - LH_SIGNING_AUTO_GENERATE_KEYS: Auto-generate keys if missing
 //   - LH_SIGNING_FILESYSTEM_KEY_FILE: Path to single key file
@@ -28,6 +30,22 @@ import (
 //   - LH_SIGNING_PKCS11_NO_LOGIN: Token doesn't support login
 //   - LH_SIGNING_PKCS11_LABEL_PREFIX: Prefix for object labels
 //   - LH_SIGNING_PKCS11_LOAD_LABELS: Extra labels to load (comma-separated)
+//   - LH_SIGNING_SEPARATE_KEY_SETS: Key sets with separate keys (comma-separated)
+//   - LH_SIGNING_DB_ENCRYPTION_KEK: Base64 encoded key-encryption key for private keys
+//   - LH_SIGNING_DB_ENCRYPTION_KEK_FILE: File containing the key-encryption key
+//   - LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEKS: Previous key-encryption keys (comma-separated)
+//   - LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEK_FILES: Files with previous key-encryption keys (comma-separated)
+//   - LH_SIGNING_DB_ENCRYPTION_PROVIDER: Name of a registered key-encryption key provider
+//   - LH_SIGNING_DB_ENCRYPTION_PROVIDER_CONFIG: Provider configuration (comma-separated key:value pairs)
+//   - LH_SIGNING_TRANSIT_ADDRESS: Base URL of the transit signing service
+//   - LH_SIGNING_TRANSIT_MOUNT: Mount path of the transit engine
+//   - LH_SIGNING_TRANSIT_KEY_PREFIX: Prefix of the transit key names
+//   - LH_SIGNING_TRANSIT_TOKEN: Token for the signing service
+//   - LH_SIGNING_TRANSIT_TOKEN_FILE: File containing the token
+//   - LH_SIGNING_TRANSIT_NAMESPACE: Namespace of the signing service
+//   - LH_SIGNING_TRANSIT_CA_CERT: CA certificates to verify the signing service
+//   - LH_SIGNING_TRANSIT_CLIENT_CERT: Client certificate for mTLS
+//   - LH_SIGNING_TRANSIT_CLIENT_KEY: Client key for mTLS
 type SigningConf struct {
 	lighthouse.SigningConf `yaml:",inline"`
 }
@@ -41,7 +59,7 @@ var defaultSigningConf = SigningConf{
 
 func (c *SigningConf) validate() error {
 	if c.KMS == "" {
-		return errors.New("error in signing conf: kms must be specified ('filesystem', 'pkcs11', or 'db')")
+		return errors.New("error in signing conf: kms must be specified ('filesystem', 'pkcs11', 'db', or 'transit')")
 	}
 	switch c.KMS {
 	case lighthouse.KMSFilesystem:
@@ -62,11 +80,57 @@ func (c *SigningConf) validate() error {
 		if c.PKBackend != lighthouse.PKBackendDatabase {
 			return errors.New("error in signing conf: kms=db requires pk_backend=db")
 		}
+	case lighthouse.KMSTransit:
+		t := c.TransitBackend
+		if t.Address == "" {
+			return errors.New("error in signing conf: transit.address must be specified")
+		}
+		if t.Token == "" && t.TokenFile == "" && t.ClientCert == "" {
+			return errors.New("error in signing conf: transit.token, transit.token_file or transit.client_cert must be specified")
+		}
+		if (t.ClientCert == "") != (t.ClientKey == "") {
+			return errors.New("error in signing conf: transit.client_cert and transit.client_key must be specified together")
+		}
 	default:
 		return errors.Errorf("error in signing conf: unknown kms '%s'", c.KMS)
 	}
 	if c.PKBackend == lighthouse.PKBackendFilesystem && c.FileSystemBackend.KeyDir == "" {
 		return errors.New("error in signing conf: filesystem.key_dir must be specified")
 	}
+	for _, keySet := range c.SeparateKeySets {
+		if !slices.Contains(lighthouse.SeparateKeySetsSupported, keySet) {
+			return errors.Errorf(
+				"error in signing conf: unknown key set '%s' in separate_key_sets; supported are %v",
+				keySet, lighthouse.SeparateKeySetsSupported,
+			)
+		}
+	}
+	if err := c.validateEncryption(); err != nil {
+		return err
+	}
+	if len(c.SeparateKeySets) > 0 && c.KMS == lighthouse.KMSFilesystem && c.FileSystemBackend.KeyDir == "" {
+		return errors.New("error in signing conf: separate_key_sets require filesystem.key_dir")
+	}
+	return nil
+}
+
+func (c *SigningConf) validateEncryption() error {
+	enc := c.DBBackend.Encryption
+	if !enc.Enabled() {
+		if len(enc.PreviousKEKs) > 0 || len(enc.PreviousKEKFiles) > 0 {
+			return errors.New("error in signing conf: db.encryption previous keys require kek or kek_file")
+		}
+		return nil
+	}
+	if c.KMS != lighthouse.KMSDatabase {
+		return errors.New("error in signing conf: db.encryption requires kms=db")
+	}
+	if enc.KEK != "" && enc.KEKFile != "" {
+		return errors.New("error in signing conf: db.encryption.kek and db.encryption.kek_file are mutually exclusive")
+	}
+	if enc.Provider != "" && (enc.KEK != "" || enc.KEKFile != "" ||
+		len(enc.PreviousKEKs) > 0 || len(enc.PreviousKEKFiles) > 0) {
+		return errors.New("error in signing conf: db.encryption.provider cannot be combined with kek options")
+	}
 	return nil
 }
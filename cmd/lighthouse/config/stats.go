@@ -1,6 +1,8 @@
 package config
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,10 +22,18 @@ import (
 //   - LH_STATS_CAPTURE_CLIENT_IP: Capture client IP
 //   - LH_STATS_CAPTURE_USER_AGENT: Capture User-Agent
 //   - LH_STATS_CAPTURE_QUERY_PARAMS: Capture query parameters
+//   - LH_STATS_CAPTURE_SUBJECT: Capture requested subjects
 //   - LH_STATS_CAPTURE_GEO_IP_ENABLED: Enable GeoIP lookup
 //   - LH_STATS_CAPTURE_GEO_IP_DATABASE_PATH: Path to GeoLite2 database
 //   - LH_STATS_RETENTION_DETAILED_DAYS: Days to keep detailed logs
+//   - LH_STATS_RETENTION_HOURLY_DAYS: Days to keep hourly aggregated stats
 //   - LH_STATS_RETENTION_AGGREGATED_DAYS: Days to keep aggregated stats
+//   - LH_STATS_ALERTS_ENABLED: Enable alerting
+//   - LH_STATS_ALERTS_INTERVAL: Evaluation interval (e.g., "1m")
+//   - LH_STATS_ALERTS_REPEAT_INTERVAL: Interval to re-notify firing alerts
+//   - LH_STATS_ALERTS_WEBHOOK_URL: Webhook URL
+//   - LH_STATS_ALERTS_SMTP_HOST: SMTP server host
+//   - LH_STATS_ALERTS_SMTP_PASSWORD: SMTP password
 //
 // YAML example:
 //
@@ -37,13 +47,38 @@ import (
 //	    client_ip: true
 //	    user_agent: true
 //	    query_params: true
+//	    subject: true
 //	    geo_ip:
 //	      enabled: false
 //	      database_path: /path/to/GeoLite2-Country.mmdb
+//	  sampling:
+//	    rate: 1.0
+//	    endpoints:
+//	      /.well-known/openid-federation: 0.1
+//	    adaptive:
+//	      enabled: true
+//	      min_rate: 0.01
+//	  privacy:
+//	    client_ip: truncate
+//	    ipv4_prefix: 24
+//	    ipv6_prefix: 48
+//	    drop_query_params: ["code"]
 //	  retention:
 //	    detailed_days: 90
+//	    hourly_days: 30
 //	    aggregated_days: 365
 //	  endpoints: []
+//	  alerts:
+//	    enabled: true
+//	    rules:
+//	      - name: fetch-errors
+//	        endpoints: ["/fetch"]
+//	        metric: error_rate
+//	        threshold: 0.05
+//	        window: 5m
+//	        min_requests: 20
+//	    webhook:
+//	      url: https://hooks.example.org/lighthouse
 type StatsConf struct {
 	// Enabled controls whether statistics collection is active.
 	// Env: LH_STATS_ENABLED
@@ -57,6 +92,14 @@ type StatsConf struct {
 	// Env prefix: LH_STATS_CAPTURE_
 	Capture StatsCaptureConf `yaml:"capture" envconfig:"CAPTURE"`
 
+	// Sampling controls which fraction of the tracked requests is recorded.
+	// Env prefix: LH_STATS_SAMPLING_
+	Sampling StatsSamplingConf `yaml:"sampling" envconfig:"SAMPLING"`
+
+	// Privacy controls the pseudonymisation of captured client data.
+	// Env prefix: LH_STATS_PRIVACY_
+	Privacy StatsPrivacyConf `yaml:"privacy" envconfig:"PRIVACY"`
+
 	// Retention defines how long data is kept.
 	// Env prefix: LH_STATS_RETENTION_
 	Retention StatsRetentionConf `yaml:"retention" envconfig:"RETENTION"`
@@ -66,6 +109,10 @@ type StatsConf struct {
 	// Example: ["/.well-known/openid-federation", "/fetch", "/resolve"]
 	// Env: LH_STATS_ENDPOINTS (comma-separated)
 	Endpoints []string `yaml:"endpoints" envconfig:"ENDPOINTS"`
+
+	// Alerts configures alert rules evaluated over the collected statistics.
+	// Env prefix: LH_STATS_ALERTS_
+	Alerts StatsAlertsConf `yaml:"alerts" envconfig:"ALERTS"`
 }
 
 // StatsBufferConf configures the in-memory ring buffer.
@@ -74,6 +121,7 @@ type StatsConf struct {
 //   - LH_STATS_BUFFER_SIZE: Ring buffer size
 //   - LH_STATS_BUFFER_FLUSH_INTERVAL: Flush interval (e.g., "5s")
 //   - LH_STATS_BUFFER_FLUSH_THRESHOLD: Flush threshold (0-1)
+//   - LH_STATS_BUFFER_SOCKET_DIR: Directory for the prefork stats socket
 type StatsBufferConf struct {
 	// Size is the maximum number of entries in the ring buffer.
 	// Default: 10000
@@ -89,6 +137,12 @@ type StatsBufferConf struct {
 	// Value between 0 and 1. Default: 0.8
 	// Env: LH_STATS_BUFFER_FLUSH_THRESHOLD
 	FlushThreshold float64 `yaml:"flush_threshold" envconfig:"FLUSH_THRESHOLD"`
+
+	// SocketDir is the directory of the unix socket over which prefork
+	// child processes forward their entries to the parent process.
+	// Default: the os temp directory
+	// Env: LH_STATS_BUFFER_SOCKET_DIR
+	SocketDir string `yaml:"socket_dir" envconfig:"SOCKET_DIR"`
 }
 
 // StatsCaptureConf controls what request data is captured.
@@ -97,6 +151,7 @@ type StatsBufferConf struct {
 //   - LH_STATS_CAPTURE_CLIENT_IP: Capture client IP
 //   - LH_STATS_CAPTURE_USER_AGENT: Capture User-Agent
 //   - LH_STATS_CAPTURE_QUERY_PARAMS: Capture query parameters
+//   - LH_STATS_CAPTURE_SUBJECT: Capture requested subjects
 //   - LH_STATS_CAPTURE_GEO_IP_ENABLED: Enable GeoIP lookup
 //   - LH_STATS_CAPTURE_GEO_IP_DATABASE_PATH: Path to GeoLite2 database
 type StatsCaptureConf struct {
@@ -112,6 +167,11 @@ type StatsCaptureConf struct {
 	// Env: LH_STATS_CAPTURE_QUERY_PARAMS
 	QueryParams bool `yaml:"query_params" envconfig:"QUERY_PARAMS"`
 
+	// Subject records the requested subject and trust mark type from the
+	// sub and trust_mark_type parameters for per-entity statistics.
+	// Env: LH_STATS_CAPTURE_SUBJECT
+	Subject bool `yaml:"subject" envconfig:"SUBJECT"`
+
 	// GeoIP enables country lookup from IP addresses.
 	// Env prefix: LH_STATS_CAPTURE_GEO_IP_
 	GeoIP StatsGeoIPConf `yaml:"geo_ip" envconfig:"GEO_IP"`
@@ -132,10 +192,115 @@ type StatsGeoIPConf struct {
 	DatabasePath string `yaml:"database_path" envconfig:"DATABASE_PATH"`
 }
 
+// StatsSamplingConf controls the sampling of tracked requests. Each recorded
+// request stores its sample rate, so counts are extrapolated.
+//
+// Environment variables (with prefix LH_STATS_SAMPLING_):
+//   - LH_STATS_SAMPLING_RATE: Fraction of requests that is recorded
+//   - LH_STATS_SAMPLING_ENDPOINTS: Per-endpoint rates (endpoint:rate,...)
+//   - LH_STATS_SAMPLING_ADAPTIVE_ENABLED: Enable adaptive sampling
+//   - LH_STATS_SAMPLING_ADAPTIVE_WINDOW: Window of the fill level average
+//   - LH_STATS_SAMPLING_ADAPTIVE_HIGH_FILL: Fill level to lower the rates
+//   - LH_STATS_SAMPLING_ADAPTIVE_LOW_FILL: Fill level to raise the rates
+//   - LH_STATS_SAMPLING_ADAPTIVE_MIN_RATE: Lowest adapted rate
+type StatsSamplingConf struct {
+	// Rate is the fraction of requests that is recorded, between 0 and 1.
+	// Default: 1
+	// Env: LH_STATS_SAMPLING_RATE
+	Rate float64 `yaml:"rate" envconfig:"RATE"`
+
+	// Endpoints overrides the rate for single endpoints.
+	// Env: LH_STATS_SAMPLING_ENDPOINTS (e.g. "/fetch:0.1,/resolve:0.5")
+	Endpoints map[string]float64 `yaml:"endpoints" envconfig:"ENDPOINTS"`
+
+	// Adaptive lowers the rates while the buffer stays filled.
+	// Env prefix: LH_STATS_SAMPLING_ADAPTIVE_
+	Adaptive StatsAdaptiveSamplingConf `yaml:"adaptive" envconfig:"ADAPTIVE"`
+}
+
+// StatsAdaptiveSamplingConf configures the adaptive sampling.
+type StatsAdaptiveSamplingConf struct {
+	// Enabled turns on adaptive sampling.
+	// Env: LH_STATS_SAMPLING_ADAPTIVE_ENABLED
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED"`
+
+	// Window is the period over which the buffer fill level is averaged
+	// before the rates are adapted.
+	// Default: 1m
+	// Env: LH_STATS_SAMPLING_ADAPTIVE_WINDOW
+	Window time.Duration `yaml:"window" envconfig:"WINDOW"`
+
+	// HighFill is the average buffer fill level (0-1) above which the rates
+	// are halved.
+	// Default: 0.5
+	// Env: LH_STATS_SAMPLING_ADAPTIVE_HIGH_FILL
+	HighFill float64 `yaml:"high_fill" envconfig:"HIGH_FILL"`
+
+	// LowFill is the average buffer fill level (0-1) below which the rates
+	// are doubled again, up to the configured rates.
+	// Default: high_fill / 4
+	// Env: LH_STATS_SAMPLING_ADAPTIVE_LOW_FILL
+	LowFill float64 `yaml:"low_fill" envconfig:"LOW_FILL"`
+
+	// MinRate is the lowest rate the adaptive sampling goes down to.
+	// Default: 0.01
+	// Env: LH_STATS_SAMPLING_ADAPTIVE_MIN_RATE
+	MinRate float64 `yaml:"min_rate" envconfig:"MIN_RATE"`
+}
+
+// StatsPrivacyConf controls the pseudonymisation of captured client data.
+// GeoIP lookups are done before client IPs are pseudonymised.
+//
+// Environment variables (with prefix LH_STATS_PRIVACY_):
+//   - LH_STATS_PRIVACY_CLIENT_IP: How client IPs are stored
+//   - LH_STATS_PRIVACY_IPV4_PREFIX: Prefix length kept of IPv4 addresses
+//   - LH_STATS_PRIVACY_IPV6_PREFIX: Prefix length kept of IPv6 addresses
+//   - LH_STATS_PRIVACY_HASH_KEY: Secret for the salts of hashed IPs
+//   - LH_STATS_PRIVACY_HASH_KEY_FILE: File containing the hash key
+//   - LH_STATS_PRIVACY_SALT_ROTATION: Rotation period of the salt
+//   - LH_STATS_PRIVACY_DROP_QUERY_PARAMS: Query parameters not to store
+type StatsPrivacyConf struct {
+	// ClientIP is how client IPs are stored: "full", "truncate" to the
+	// network prefix, or "hash" with a keyed hash and rotating salt.
+	// Default: full
+	// Env: LH_STATS_PRIVACY_CLIENT_IP
+	ClientIP string `yaml:"client_ip" envconfig:"CLIENT_IP"`
+
+	// IPv4Prefix is the prefix length kept of IPv4 addresses when truncating.
+	// Default: 24
+	// Env: LH_STATS_PRIVACY_IPV4_PREFIX
+	IPv4Prefix int `yaml:"ipv4_prefix" envconfig:"IPV4_PREFIX"`
+
+	// IPv6Prefix is the prefix length kept of IPv6 addresses when truncating.
+	// Default: 48
+	// Env: LH_STATS_PRIVACY_IPV6_PREFIX
+	IPv6Prefix int `yaml:"ipv6_prefix" envconfig:"IPV6_PREFIX"`
+
+	// HashKey is the secret from which the salts of hashed IPs are derived.
+	// If not set, random salts are used, which change on restart. It is
+	// required with prefork and for hashing stored data with lhcli.
+	// Env: LH_STATS_PRIVACY_HASH_KEY
+	HashKey string `yaml:"hash_key" envconfig:"HASH_KEY"`
+
+	// HashKeyFile is a file containing the hash key.
+	// Env: LH_STATS_PRIVACY_HASH_KEY_FILE
+	HashKeyFile string `yaml:"hash_key_file" envconfig:"HASH_KEY_FILE"`
+
+	// SaltRotation is the period after which the salt of hashed IPs changes.
+	// Default: 24h
+	// Env: LH_STATS_PRIVACY_SALT_ROTATION
+	SaltRotation time.Duration `yaml:"salt_rotation" envconfig:"SALT_ROTATION"`
+
+	// DropQueryParams are query parameters that are not stored.
+	// Env: LH_STATS_PRIVACY_DROP_QUERY_PARAMS (comma-separated)
+	DropQueryParams []string `yaml:"drop_query_params" envconfig:"DROP_QUERY_PARAMS"`
+}
+
 // StatsRetentionConf defines data retention periods.
 //
 // Environment variables (with prefix LH_STATS_RETENTION_):
 //   - LH_STATS_RETENTION_DETAILED_DAYS: Days to keep detailed logs
+//   - LH_STATS_RETENTION_HOURLY_DAYS: Days to keep hourly aggregated stats
 //   - LH_STATS_RETENTION_AGGREGATED_DAYS: Days to keep aggregated stats
 type StatsRetentionConf struct {
 	// DetailedDays is how many days to keep individual request logs.
@@ -143,12 +308,151 @@ type StatsRetentionConf struct {
 	// Env: LH_STATS_RETENTION_DETAILED_DAYS
 	DetailedDays int `yaml:"detailed_days" envconfig:"DETAILED_DAYS"`
 
+	// HourlyDays is how many days to keep hourly aggregated statistics.
+	// Default: 30
+	// Env: LH_STATS_RETENTION_HOURLY_DAYS
+	HourlyDays int `yaml:"hourly_days" envconfig:"HOURLY_DAYS"`
+
 	// AggregatedDays is how many days to keep daily aggregated statistics.
 	// Default: 365
 	// Env: LH_STATS_RETENTION_AGGREGATED_DAYS
 	AggregatedDays int `yaml:"aggregated_days" envconfig:"AGGREGATED_DAYS"`
 }
 
+// StatsAlertsConf configures alerting on the collected statistics.
+//
+// Environment variables (with prefix LH_STATS_ALERTS_):
+//   - LH_STATS_ALERTS_ENABLED: Enable alerting
+//   - LH_STATS_ALERTS_INTERVAL: Evaluation interval (e.g., "1m")
+//   - LH_STATS_ALERTS_REPEAT_INTERVAL: Interval to re-notify firing alerts
+//   - LH_STATS_ALERTS_WEBHOOK_URL: Webhook URL
+//   - LH_STATS_ALERTS_WEBHOOK_TIMEOUT: Webhook request timeout
+//   - LH_STATS_ALERTS_SMTP_HOST: SMTP server host
+//   - LH_STATS_ALERTS_SMTP_PORT: SMTP server port
+//   - LH_STATS_ALERTS_SMTP_USERNAME: SMTP username
+//   - LH_STATS_ALERTS_SMTP_PASSWORD: SMTP password
+//   - LH_STATS_ALERTS_SMTP_PASSWORD_FILE: File containing the SMTP password
+//   - LH_STATS_ALERTS_SMTP_FROM: Sender address
+//   - LH_STATS_ALERTS_SMTP_TO: Recipient addresses (comma-separated)
+//   - LH_STATS_ALERTS_SMTP_TIMEOUT: Timeout for sending an alert
+type StatsAlertsConf struct {
+	// Enabled controls whether alert rules are evaluated.
+	// Env: LH_STATS_ALERTS_ENABLED
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED"`
+
+	// Interval is how often the alert rules are evaluated.
+	// Default: 1m
+	// Env: LH_STATS_ALERTS_INTERVAL
+	Interval time.Duration `yaml:"interval" envconfig:"INTERVAL"`
+
+	// RepeatInterval is the interval in which a still firing alert is
+	// notified again. If not set, alerts are only notified when they start
+	// firing and when they are resolved.
+	// Env: LH_STATS_ALERTS_REPEAT_INTERVAL
+	RepeatInterval time.Duration `yaml:"repeat_interval" envconfig:"REPEAT_INTERVAL"`
+
+	// Rules are the alert rules. They can only be configured in the config
+	// file.
+	Rules []StatsAlertRuleConf `yaml:"rules" envconfig:"-"`
+
+	// Webhook configures sending alerts as JSON to a webhook.
+	// Env prefix: LH_STATS_ALERTS_WEBHOOK_
+	Webhook StatsAlertWebhookConf `yaml:"webhook" envconfig:"WEBHOOK"`
+
+	// SMTP configures sending alerts by email.
+	// Env prefix: LH_STATS_ALERTS_SMTP_
+	SMTP StatsAlertSMTPConf `yaml:"smtp" envconfig:"SMTP"`
+}
+
+// StatsAlertRuleConf is a single alert rule.
+type StatsAlertRuleConf struct {
+	// Name identifies the rule in notifications; it must be unique.
+	Name string `yaml:"name"`
+
+	// Endpoints the rule is evaluated for, each on its own.
+	// If empty, the rule is evaluated for every endpoint with requests.
+	Endpoints []string `yaml:"endpoints"`
+
+	// Metric is one of error_rate, request_count, latency_avg, latency_p50,
+	// latency_p75, latency_p90, latency_p95, latency_p99.
+	Metric string `yaml:"metric"`
+
+	// Condition is "above" or "below".
+	// Default: above
+	Condition string `yaml:"condition"`
+
+	// Threshold is the value the metric is compared to; error rates are
+	// fractions (0.05 = 5%), latencies are in milliseconds. If
+	// baseline_window is set, it is a factor of the baseline value instead,
+	// e.g. 2 alerts when the metric doubles.
+	Threshold float64 `yaml:"threshold"`
+
+	// Window is the period of recent statistics the metric is computed over.
+	// Default: 5m
+	Window time.Duration `yaml:"window"`
+
+	// BaselineWindow, if set, is the period before window the baseline value
+	// of the metric is computed over.
+	BaselineWindow time.Duration `yaml:"baseline_window"`
+
+	// MinRequests is the minimum number of requests in a window for error
+	// rates and latencies to be evaluated. Firing alerts are resolved if a
+	// window has fewer requests.
+	MinRequests int64 `yaml:"min_requests"`
+}
+
+// StatsAlertWebhookConf configures the webhook alert sink.
+type StatsAlertWebhookConf struct {
+	// URL the alerts are posted to. The sink is disabled if not set.
+	// Env: LH_STATS_ALERTS_WEBHOOK_URL
+	URL string `yaml:"url" envconfig:"URL"`
+
+	// Headers are additional HTTP headers, e.g. for authorization.
+	Headers map[string]string `yaml:"headers" envconfig:"-"`
+
+	// Timeout of the webhook request.
+	// Default: 10s
+	// Env: LH_STATS_ALERTS_WEBHOOK_TIMEOUT
+	Timeout time.Duration `yaml:"timeout" envconfig:"TIMEOUT"`
+}
+
+// StatsAlertSMTPConf configures the email alert sink.
+type StatsAlertSMTPConf struct {
+	// Host of the SMTP server. The sink is disabled if not set.
+	// Env: LH_STATS_ALERTS_SMTP_HOST
+	Host string `yaml:"host" envconfig:"HOST"`
+
+	// Port of the SMTP server.
+	// Default: 587
+	// Env: LH_STATS_ALERTS_SMTP_PORT
+	Port int `yaml:"port" envconfig:"PORT"`
+
+	// Username for authentication; if not set, no authentication is used.
+	// Env: LH_STATS_ALERTS_SMTP_USERNAME
+	Username string `yaml:"username" envconfig:"USERNAME"`
+
+	// Password for authentication.
+	// Env: LH_STATS_ALERTS_SMTP_PASSWORD
+	Password string `yaml:"password" envconfig:"PASSWORD"`
+
+	// PasswordFile is a file containing the password.
+	// Env: LH_STATS_ALERTS_SMTP_PASSWORD_FILE
+	PasswordFile string `yaml:"password_file" envconfig:"PASSWORD_FILE"`
+
+	// From is the sender address.
+	// Env: LH_STATS_ALERTS_SMTP_FROM
+	From string `yaml:"from" envconfig:"FROM"`
+
+	// To are the recipient addresses.
+	// Env: LH_STATS_ALERTS_SMTP_TO (comma-separated)
+	To []string `yaml:"to" envconfig:"TO"`
+
+	// Timeout for sending an alert, including connecting to the server.
+	// Default: 10s
+	// Env: LH_STATS_ALERTS_SMTP_TIMEOUT
+	Timeout time.Duration `yaml:"timeout" envconfig:"TIMEOUT"`
+}
+
 // validate checks the stats configuration for errors.
 func (s *StatsConf) validate() error {
 	if !s.Enabled {
@@ -171,6 +475,10 @@ func (s *StatsConf) validate() error {
 		s.Retention.DetailedDays = 90
 	}
 
+	if s.Retention.HourlyDays <= 0 {
+		s.Retention.HourlyDays = 30
+	}
+
 	if s.Retention.AggregatedDays <= 0 {
 		s.Retention.AggregatedDays = 365
 	}
@@ -184,14 +492,231 @@ func (s *StatsConf) validate() error {
 		}
 	}
 
+	if err := s.Sampling.validate(); err != nil {
+		return err
+	}
+
+	if err := s.Privacy.validate(); err != nil {
+		return err
+	}
+
+	return s.Alerts.validate()
+}
+
+// validate checks the sampling configuration for errors.
+func (c *StatsSamplingConf) validate() error {
+	if c.Rate == 0 {
+		c.Rate = 1
+	}
+	if c.Rate < 0 || c.Rate > 1 {
+		return errors.New("sampling.rate must be between 0 and 1")
+	}
+	for endpoint, rate := range c.Endpoints {
+		if rate <= 0 || rate > 1 {
+			return errors.Errorf("sampling.endpoints '%s': rate must be between 0 and 1", endpoint)
+		}
+	}
+	a := &c.Adaptive
+	if !a.Enabled {
+		return nil
+	}
+	if a.Window <= 0 {
+		a.Window = time.Minute
+	}
+	if a.HighFill == 0 {
+		a.HighFill = 0.5
+	}
+	if a.LowFill == 0 {
+		a.LowFill = a.HighFill / 4
+	}
+	if a.HighFill < 0 || a.HighFill > 1 || a.LowFill < 0 || a.LowFill >= a.HighFill {
+		return errors.New("sampling.adaptive: fill levels must be between 0 and 1 and low_fill below high_fill")
+	}
+	if a.MinRate == 0 {
+		a.MinRate = 0.01
+	}
+	if a.MinRate < 0 || a.MinRate > 1 {
+		return errors.New("sampling.adaptive.min_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// toAPIConfig converts StatsSamplingConf to api/stats.SamplingConfig.
+func (c *StatsSamplingConf) toAPIConfig() apistats.SamplingConfig {
+	return apistats.SamplingConfig{
+		Rate:          c.Rate,
+		EndpointRates: c.Endpoints,
+		Adaptive: apistats.AdaptiveSamplingConfig{
+			Enabled:  c.Adaptive.Enabled,
+			Window:   c.Adaptive.Window,
+			HighFill: c.Adaptive.HighFill,
+			LowFill:  c.Adaptive.LowFill,
+			MinRate:  c.Adaptive.MinRate,
+		},
+	}
+}
+
+// validate checks the privacy configuration for errors.
+func (p *StatsPrivacyConf) validate() error {
+	if p.ClientIP == "" {
+		p.ClientIP = apistats.ClientIPModeFull
+	}
+	p.ClientIP = strings.ToLower(p.ClientIP)
+	if !slices.Contains(apistats.ClientIPModes, p.ClientIP) {
+		return errors.Errorf("privacy.client_ip: unknown mode '%s'; supported are %v", p.ClientIP, apistats.ClientIPModes)
+	}
+	if p.IPv4Prefix == 0 {
+		p.IPv4Prefix = 24
+	}
+	if p.IPv6Prefix == 0 {
+		p.IPv6Prefix = 48
+	}
+	if p.IPv4Prefix < 0 || p.IPv4Prefix > 32 {
+		return errors.New("privacy.ipv4_prefix must be between 1 and 32")
+	}
+	if p.IPv6Prefix < 0 || p.IPv6Prefix > 128 {
+		return errors.New("privacy.ipv6_prefix must be between 1 and 128")
+	}
+	if p.SaltRotation <= 0 {
+		p.SaltRotation = 24 * time.Hour
+	}
+	if p.HashKeyFile != "" {
+		if p.HashKey != "" {
+			return errors.New("privacy: hash_key and hash_key_file are mutually exclusive")
+		}
+		data, err := fileutils.ReadFile(p.HashKeyFile)
+		if err != nil {
+			return errors.Wrap(err, "privacy: failed to read hash_key_file")
+		}
+		p.HashKey = strings.TrimSpace(string(data))
+	}
 	return nil
 }
 
+// toAPIConfig converts StatsPrivacyConf to api/stats.PrivacyConfig.
+func (p *StatsPrivacyConf) toAPIConfig() apistats.PrivacyConfig {
+	return apistats.PrivacyConfig{
+		ClientIPMode:    p.ClientIP,
+		IPv4Prefix:      p.IPv4Prefix,
+		IPv6Prefix:      p.IPv6Prefix,
+		HashKey:         p.HashKey,
+		SaltRotation:    p.SaltRotation,
+		DropQueryParams: p.DropQueryParams,
+	}
+}
+
+// validate checks the alerts configuration for errors.
+func (a *StatsAlertsConf) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if a.Interval <= 0 {
+		a.Interval = time.Minute
+	}
+
+	names := make(map[string]bool, len(a.Rules))
+	for i := range a.Rules {
+		rule := &a.Rules[i]
+		if rule.Name == "" {
+			return errors.Errorf("alerts.rules[%d]: name is required", i)
+		}
+		if names[rule.Name] {
+			return errors.Errorf("alerts.rules: duplicate rule name '%s'", rule.Name)
+		}
+		names[rule.Name] = true
+		if !slices.Contains(apistats.AlertMetrics, rule.Metric) {
+			return errors.Errorf(
+				"alerts.rules '%s': unknown metric '%s'; supported are %v", rule.Name, rule.Metric,
+				apistats.AlertMetrics,
+			)
+		}
+		switch rule.Condition {
+		case "":
+			rule.Condition = apistats.AlertConditionAbove
+		case apistats.AlertConditionAbove, apistats.AlertConditionBelow:
+		default:
+			return errors.Errorf(
+				"alerts.rules '%s': condition must be '%s' or '%s'", rule.Name, apistats.AlertConditionAbove,
+				apistats.AlertConditionBelow,
+			)
+		}
+		if rule.Window <= 0 {
+			rule.Window = 5 * time.Minute
+		}
+		if rule.BaselineWindow < 0 || rule.MinRequests < 0 {
+			return errors.Errorf("alerts.rules '%s': baseline_window and min_requests must not be negative", rule.Name)
+		}
+	}
+
+	if a.SMTP.Host != "" {
+		if a.SMTP.Port <= 0 {
+			a.SMTP.Port = 587
+		}
+		if a.SMTP.From == "" || len(a.SMTP.To) == 0 {
+			return errors.New("alerts.smtp: from and to are required when host is set")
+		}
+		if a.SMTP.PasswordFile != "" {
+			if a.SMTP.Password != "" {
+				return errors.New("alerts.smtp: password and password_file are mutually exclusive")
+			}
+			data, err := fileutils.ReadFile(a.SMTP.PasswordFile)
+			if err != nil {
+				return errors.Wrap(err, "alerts.smtp: failed to read password_file")
+			}
+			a.SMTP.Password = strings.TrimSpace(string(data))
+		}
+	}
+	return nil
+}
+
+// toAPIConfig converts StatsAlertsConf to api/stats.AlertsConfig.
+func (a *StatsAlertsConf) toAPIConfig() apistats.AlertsConfig {
+	rules := make([]apistats.AlertRule, len(a.Rules))
+	for i, r := range a.Rules {
+		rules[i] = apistats.AlertRule{
+			Name:           r.Name,
+			Endpoints:      r.Endpoints,
+			Metric:         r.Metric,
+			Condition:      r.Condition,
+			Threshold:      r.Threshold,
+			Window:         r.Window,
+			BaselineWindow: r.BaselineWindow,
+			MinRequests:    r.MinRequests,
+		}
+	}
+	return apistats.AlertsConfig{
+		Enabled:        a.Enabled,
+		Interval:       a.Interval,
+		RepeatInterval: a.RepeatInterval,
+		Rules:          rules,
+		Webhook: apistats.WebhookSinkConfig{
+			URL:     a.Webhook.URL,
+			Headers: a.Webhook.Headers,
+			Timeout: a.Webhook.Timeout,
+		},
+		SMTP: apistats.SMTPSinkConfig{
+			Host:     a.SMTP.Host,
+			Port:     a.SMTP.Port,
+			Username: a.SMTP.Username,
+			Password: a.SMTP.Password,
+			From:     a.SMTP.From,
+			To:       a.SMTP.To,
+			Timeout:  a.SMTP.Timeout,
+		},
+	}
+}
+
 // DetailedRetention returns the retention period for detailed logs as a Duration.
 func (s *StatsConf) DetailedRetention() time.Duration {
 	return time.Duration(s.Retention.DetailedDays) * 24 * time.Hour
 }
 
+// HourlyRetention returns the retention period for hourly stats as a Duration.
+func (s *StatsConf) HourlyRetention() time.Duration {
+	return time.Duration(s.Retention.HourlyDays) * 24 * time.Hour
+}
+
 // AggregatedRetention returns the retention period for aggregated stats as a Duration.
 func (s *StatsConf) AggregatedRetention() time.Duration {
 	return time.Duration(s.Retention.AggregatedDays) * 24 * time.Hour
@@ -204,14 +729,20 @@ func (s *StatsConf) ToAPIConfig() apistats.Config {
 		BufferSize:          s.Buffer.Size,
 		FlushInterval:       s.Buffer.FlushInterval,
 		FlushThreshold:      s.Buffer.FlushThreshold,
+		SocketDir:           s.Buffer.SocketDir,
 		CaptureClientIP:     s.Capture.ClientIP,
 		CaptureUserAgent:    s.Capture.UserAgent,
 		CaptureQueryParams:  s.Capture.QueryParams,
+		CaptureSubject:      s.Capture.Subject,
 		GeoIPEnabled:        s.Capture.GeoIP.Enabled,
 		GeoIPDBPath:         s.Capture.GeoIP.DatabasePath,
+		Privacy:             s.Privacy.toAPIConfig(),
+		Sampling:            s.Sampling.toAPIConfig(),
 		DetailedRetention:   s.DetailedRetention(),
+		HourlyRetention:     s.HourlyRetention(),
 		AggregatedRetention: s.AggregatedRetention(),
 		Endpoints:           s.Endpoints,
+		Alerts:              s.Alerts.toAPIConfig(),
 	}
 }
 
@@ -226,12 +757,14 @@ var defaultStatsConf = StatsConf{
 		ClientIP:    true,
 		UserAgent:   true,
 		QueryParams: true,
+		Subject:     true,
 		GeoIP: StatsGeoIPConf{
 			Enabled: false,
 		},
 	},
 	Retention: StatsRetentionConf{
 		DetailedDays:   90,
+		HourlyDays:     30,
 		AggregatedDays: 365,
 	},
 	Endpoints: nil,
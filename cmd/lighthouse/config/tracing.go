@@ -0,0 +1,117 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/go-oidfed/lighthouse/tracing"
+)
+
+// TracingConf configures OpenTelemetry tracing.
+//
+// Environment variables (with prefix LH_TRACING_):
+//   - LH_TRACING_ENABLED: Enable tracing
+//   - LH_TRACING_SERVICE_NAME: Reported service name
+//   - LH_TRACING_EXPORTER: Span exporter ("otlp" or "stdout")
+//   - LH_TRACING_SAMPLE_RATIO: Ratio of sampled traces (0-1)
+//   - LH_TRACING_OTLP_PROTOCOL: OTLP protocol ("http" or "grpc")
+//   - LH_TRACING_OTLP_ENDPOINT: OTLP collector address
+//   - LH_TRACING_OTLP_INSECURE: Disable TLS for the collector connection
+//   - LH_TRACING_OTLP_HEADERS: Headers for the collector (key:value,...)
+//
+// Example YAML:
+//
+//	tracing:
+//	  enabled: true
+//	  otlp:
+//	    endpoint: otel-collector:4318
+//	    insecure: true
+type TracingConf struct {
+	// Enabled controls whether spans are recorded and exported.
+	// Env: LH_TRACING_ENABLED
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED"`
+
+	// ServiceName is reported as service.name.
+	// Default: lighthouse
+	// Env: LH_TRACING_SERVICE_NAME
+	ServiceName string `yaml:"service_name" envconfig:"SERVICE_NAME"`
+
+	// Exporter selects the span exporter: "otlp" or "stdout".
+	// Default: otlp
+	// Env: LH_TRACING_EXPORTER
+	Exporter string `yaml:"exporter" envconfig:"EXPORTER"`
+
+	// SampleRatio is the ratio of new traces that are sampled.
+	// Value between 0 and 1. Default: 1
+	// Env: LH_TRACING_SAMPLE_RATIO
+	SampleRatio float64 `yaml:"sample_ratio" envconfig:"SAMPLE_RATIO"`
+
+	// OTLP configures the OTLP exporter.
+	// Env prefix: LH_TRACING_OTLP_
+	OTLP TracingOTLPConf `yaml:"otlp" envconfig:"OTLP"`
+}
+
+// TracingOTLPConf configures the OTLP exporter. Empty options fall back to
+// the standard OTEL_EXPORTER_OTLP_* environment variables.
+type TracingOTLPConf struct {
+	// Protocol is "http" (OTLP/HTTP protobuf) or "grpc".
+	// Default: http
+	// Env: LH_TRACING_OTLP_PROTOCOL
+	Protocol string `yaml:"protocol" envconfig:"PROTOCOL"`
+
+	// Endpoint is the collector address, e.g. "localhost:4318".
+	// Env: LH_TRACING_OTLP_ENDPOINT
+	Endpoint string `yaml:"endpoint" envconfig:"ENDPOINT"`
+
+	// Insecure disables TLS for the connection to the collector.
+	// Env: LH_TRACING_OTLP_INSECURE
+	Insecure bool `yaml:"insecure" envconfig:"INSECURE"`
+
+	// Headers are sent with each export request, e.g. for authentication.
+	// Env: LH_TRACING_OTLP_HEADERS (key:value,key2:value2)
+	Headers map[string]string `yaml:"headers" envconfig:"HEADERS"`
+}
+
+func (t *TracingConf) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	t.Exporter = strings.ToLower(t.Exporter)
+	if t.Exporter == "" {
+		t.Exporter = tracing.ExporterOTLP
+	}
+	if t.Exporter != tracing.ExporterOTLP && t.Exporter != tracing.ExporterStdout {
+		return errors.Errorf("unsupported exporter '%s', must be 'otlp' or 'stdout'", t.Exporter)
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return errors.New("sample_ratio must be between 0 and 1")
+	}
+	if t.SampleRatio == 0 {
+		t.SampleRatio = 1
+	}
+	t.OTLP.Protocol = strings.ToLower(t.OTLP.Protocol)
+	if t.OTLP.Protocol == "" {
+		t.OTLP.Protocol = tracing.ProtocolHTTP
+	}
+	if t.OTLP.Protocol != tracing.ProtocolHTTP && t.OTLP.Protocol != tracing.ProtocolGRPC {
+		return errors.Errorf("unsupported otlp protocol '%s', must be 'http' or 'grpc'", t.OTLP.Protocol)
+	}
+	return nil
+}
+
+// ToTracingConfig converts TracingConf to tracing.Config.
+func (t *TracingConf) ToTracingConfig() tracing.Config {
+	return tracing.Config{
+		Enabled:     t.Enabled,
+		ServiceName: t.ServiceName,
+		Exporter:    t.Exporter,
+		SampleRatio: t.SampleRatio,
+		OTLP: tracing.OTLPConfig{
+			Protocol: t.OTLP.Protocol,
+			Endpoint: t.OTLP.Endpoint,
+			Insecure: t.OTLP.Insecure,
+			Headers:  t.OTLP.Headers,
+		},
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"strings"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/go-oidfed/lighthouse/internal/logger"
 	"github.com/go-oidfed/lighthouse/storage"
 	"github.com/go-oidfed/lighthouse/storage/model"
+	"github.com/go-oidfed/lighthouse/tracing"
 )
 
 func main() {
@@ -30,11 +32,17 @@ func main() {
 	log.Info("Loaded Config")
 	c := config.Get()
 
+	// The server runs until the process exits, so the tracer provider is
+	// not shut down explicitly.
+	if _, err := tracing.Init(context.Background(), c.Tracing.ToTracingConfig()); err != nil {
+		log.WithError(err).Fatal("failed to initialize tracing")
+	}
+
 	if err := initCache(&c.Caching); err != nil {
 		log.WithError(err).Fatal("failed to initialize cache")
 	}
 
-	backs, err := initStorage(&c.Storage, c.API.Admin.Argon2idParams)
+	backs, err := initStorage(&c.Storage, c.API.Admin.Argon2idParams, c.Tracing.Enabled)
 	if err != nil {
 		log.WithError(err).Fatal("failed to initialize storage")
 	}
@@ -99,13 +107,16 @@ func initCache(caching *config.CachingConf) error {
 	return nil
 }
 
-func initStorage(storageConf *config.StorageConf, usersHash storage.Argon2idParams) (model.Backends, error) {
+func initStorage(
+	storageConf *config.StorageConf, usersHash storage.Argon2idParams, tracingEnabled bool,
+) (model.Backends, error) {
 	cfg := storage.Config{
 		Driver:    storageConf.Driver,
 		DSN:       storageConf.DSN,
 		DataDir:   storageConf.DataDir,
 		Debug:     storageConf.Debug,
 		UsersHash: usersHash,
+		Tracing:   tracingEnabled,
 	}
 	return storage.LoadStorageBackends(cfg)
 }
@@ -145,6 +156,8 @@ func initLighthouse(c *config.Config, backs model.Backends, statsConfig stats.Co
 			ActorSource:  c.API.Admin.ActorSource,
 			CORS:         c.API.Admin.CORS,
 			TLS:          c.API.Admin.TLS,
+
+			TopologyCrawlTimeout: c.API.Admin.TopologyCrawlTimeout,
 		},
 		statsConfig,
 	)
@@ -160,7 +173,7 @@ func initLighthouse(c *config.Config, backs model.Backends, statsConfig stats.Co
 
 func setupTrustMarkIssuer(lh *lighthouse.LightHouse, entityID string, backs *model.Backends) {
 	lh.TrustMarkIssuer = oidfed.NewTrustMarkIssuer(
-		entityID, lh.GeneralJWTSigner.TrustMarkSigner(),
+		entityID, lh.KeySetSigner(lighthouse.KeySetTrustMarks).TrustMarkSigner(),
 		nil,
 	)
 
@@ -181,7 +194,13 @@ func registerEndpoints(lh *lighthouse.LightHouse, c *config.Config, backs *model
 	}
 
 	if endpoint := c.Endpoints.ListEndpoint; endpoint.IsSet() {
-		lh.AddSubordinateListingEndpoint(endpoint, backs.Subordinates, backs.TrustMarks)
+		lh.AddSubordinateListingEndpointWithConfig(
+			endpoint.EndpointConf, lighthouse.SubordinateListingConfig{
+				Store:              backs.Subordinates,
+				TrustMarkStore:     backs.TrustMarks,
+				AllowLabelSelector: endpoint.AllowLabelSelector,
+			},
+		)
 	}
 
 	if endpoint := c.Endpoints.ResolveEndpoint; endpoint.IsSet() {
@@ -193,7 +212,7 @@ func registerEndpoints(lh *lighthouse.LightHouse, c *config.Config, backs *model
 					StoreJWT:  endpoint.ProactiveResolver.ResponseStorage.StoreJWT,
 					StoreJSON: endpoint.ProactiveResolver.ResponseStorage.StoreJSON,
 				},
-				Signer:      lh.ResolveResponseSigner(),
+				Signer:      lh.KeySetSigner(lighthouse.KeySetResolve).ResolveResponseSigner(),
 				RefreshLead: endpoint.GracePeriod.Duration(),
 				Concurrency: endpoint.ProactiveResolver.ConcurrencyLimit,
 				QueueSize:   endpoint.ProactiveResolver.QueueSize,
@@ -215,11 +234,11 @@ func registerEndpoints(lh *lighthouse.LightHouse, c *config.Config, backs *model
 	}
 
 	if endpoint := c.Endpoints.TrustMarkEndpoint; endpoint.IsSet() {
-		eligibilityCache := lighthouse.NewEligibilityCache()
+		eligibilityCache := lh.EligibilityCache()
 		stopEligibilityCacheCleanup := eligibilityCache.StartCleanupRoutine(5 * time.Minute)
 		defer stopEligibilityCacheCleanup()
 
-		issuedTrustMarkCache := lighthouse.NewIssuedTrustMarkCache()
+		issuedTrustMarkCache := lh.IssuedTrustMarkCache()
 		stopIssuedCacheCleanup := issuedTrustMarkCache.StartCleanupRoutine(5 * time.Minute)
 		defer stopIssuedCacheCleanup()
 
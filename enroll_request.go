@@ -57,7 +57,7 @@ func (fed *LightHouse) AddEnrollRequestEndpoint(
 				}
 			}
 
-			entityConfig, err := oidfed.GetEntityConfiguration(req.Subject)
+			entityConfig, err := fetchEntityConfiguration(ctx.UserContext(), req.Subject)
 			if err != nil {
 				ctx.Status(fiber.StatusBadRequest)
 				return ctx.JSON(oidfed.ErrorInvalidRequest("could not obtain entity configuration"))
@@ -1,6 +1,7 @@
 package lighthouse
 
 import (
+	"context"
 	"fmt"
 	"slices"
 
@@ -259,7 +260,7 @@ func (c TrustMarkEntityChecker) Check(
 		}
 	} else {
 		for _, ta := range c.TrustAnchors {
-			taConfig, err := oidfed.GetEntityConfiguration(ta.EntityID)
+			taConfig, err := fetchEntityConfiguration(context.Background(), ta.EntityID)
 			if err != nil {
 				continue
 			}
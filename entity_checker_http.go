@@ -1,6 +1,7 @@
 package lighthouse
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -16,6 +17,8 @@ import (
 	oidfed "github.com/go-oidfed/lib"
 	"github.com/go-oidfed/lib/apimodel"
 	"github.com/go-oidfed/lib/jwx"
+
+	"github.com/go-oidfed/lighthouse/tracing"
 )
 
 // HTTPListEntityChecker fetches a list of entity IDs from an HTTP endpoint
@@ -95,7 +98,7 @@ func (c *HTTPListEntityChecker) fetchList() ([]string, error) {
 		timeout = 30
 	}
 
-	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	client := tracing.NewClient(&http.Client{Timeout: time.Duration(timeout) * time.Second})
 	req, err := http.NewRequest(method, c.URL, http.NoBody)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create HTTP request")
@@ -280,7 +283,7 @@ func (c *HTTPListJWTEntityChecker) fetchJWT() (string, error) {
 		timeout = 30
 	}
 
-	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	client := tracing.NewClient(&http.Client{Timeout: time.Duration(timeout) * time.Second})
 	req, err := http.NewRequest(method, c.URL, http.NoBody)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create HTTP request")
@@ -351,7 +354,7 @@ func (c *HTTPListJWTEntityChecker) verifyWithTrustAnchor(jwtString string) (jwt.
 		}
 
 		// Get the issuer's entity configuration to get their signing keys
-		issuerConfig, err := oidfed.GetEntityConfiguration(issuer)
+		issuerConfig, err := fetchEntityConfiguration(context.Background(), issuer)
 		if err != nil {
 			lastErr = err
 			continue
@@ -0,0 +1,22 @@
+package lighthouse
+
+import (
+	"context"
+
+	oidfed "github.com/go-oidfed/lib"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/go-oidfed/lighthouse/tracing"
+)
+
+// fetchEntityConfiguration obtains the entity configuration of entityID and
+// records the fetch as a span under ctx.
+func fetchEntityConfiguration(ctx context.Context, entityID string) (*oidfed.EntityStatement, error) {
+	_, span := tracing.Start(
+		ctx, "oidfed.GetEntityConfiguration",
+		attribute.String("oidfed.entity_id", entityID),
+	)
+	entityConfig, err := oidfed.GetEntityConfiguration(entityID)
+	tracing.End(span, err)
+	return entityConfig, err
+}
@@ -9,15 +9,19 @@ import (
 
 // AggregatorStorage is the interface for aggregation storage operations.
 type AggregatorStorage interface {
+	AggregateHourlyStats(hour time.Time) error
+	LastHourlyAggregation() (time.Time, error)
 	AggregateDailyStats(date time.Time) error
 	PurgeDetailedLogs(before time.Time) (int64, error)
+	PurgeHourlyStats(before time.Time) (int64, error)
 	PurgeAggregatedStats(before time.Time) (int64, error)
 }
 
-// Aggregator handles daily aggregation and data retention.
+// Aggregator handles hourly and daily aggregation and data retention.
 type Aggregator struct {
 	storage             AggregatorStorage
 	detailedRetention   time.Duration
+	hourlyRetention     time.Duration
 	aggregatedRetention time.Duration
 
 	// lastAggregation tracks the last date we aggregated
@@ -25,17 +29,23 @@ type Aggregator struct {
 }
 
 // NewAggregator creates a new aggregator instance.
-func NewAggregator(storage AggregatorStorage, detailedRetention, aggregatedRetention time.Duration) *Aggregator {
+func NewAggregator(
+	storage AggregatorStorage, detailedRetention, hourlyRetention, aggregatedRetention time.Duration,
+) *Aggregator {
 	return &Aggregator{
 		storage:             storage,
 		detailedRetention:   detailedRetention,
+		hourlyRetention:     hourlyRetention,
 		aggregatedRetention: aggregatedRetention,
 	}
 }
 
-// Run starts the aggregation loop. It runs once per day at 2 AM UTC.
+// Run starts the aggregation loops. Complete hours are aggregated every hour,
+// the daily aggregation and purge run once per day at 2 AM UTC.
 // This method blocks until the context is cancelled.
 func (a *Aggregator) Run(ctx context.Context) error {
+	go a.runHourly(ctx)
+
 	// Calculate time until next 2 AM UTC
 	now := time.Now().UTC()
 	next2AM := time.Date(now.Year(), now.Month(), now.Day(), 2, 0, 0, 0, time.UTC)
@@ -47,6 +57,7 @@ func (a *Aggregator) Run(ctx context.Context) error {
 	log.WithFields(log.Fields{
 		"next_run":             next2AM,
 		"detailed_retention":   a.detailedRetention,
+		"hourly_retention":     a.hourlyRetention,
 		"aggregated_retention": a.aggregatedRetention,
 	}).Info("stats aggregator started")
 
@@ -74,6 +85,60 @@ func (a *Aggregator) Run(ctx context.Context) error {
 	}
 }
 
+// runHourly aggregates all complete hours that were not aggregated yet, and
+// then does so every hour, shortly after the hour is complete.
+func (a *Aggregator) runHourly(ctx context.Context) {
+	for {
+		a.runHourlyAggregation()
+
+		now := time.Now().UTC()
+		next := now.Truncate(time.Hour).Add(time.Hour + time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next.Sub(now)):
+		}
+	}
+}
+
+// runHourlyAggregation aggregates the complete hours since the last
+// aggregated hour. Hours whose detailed logs may already be purged are
+// skipped.
+func (a *Aggregator) runHourlyAggregation() {
+	now := time.Now().UTC()
+	current := now.Truncate(time.Hour)
+
+	start := now.Add(-a.detailedRetention).Truncate(time.Hour).Add(time.Hour)
+	if a.hourlyRetention < a.detailedRetention {
+		start = now.Add(-a.hourlyRetention).Truncate(time.Hour)
+	}
+	last, err := a.storage.LastHourlyAggregation()
+	if err != nil {
+		log.WithError(err).Error("failed to get last hourly stats aggregation")
+		return
+	}
+	// The last aggregated hour is aggregated again, since it might have been
+	// aggregated before all entries of the hour were flushed
+	if !last.IsZero() && last.After(start) {
+		start = last
+	}
+
+	var aggregated int
+	for hour := start; hour.Before(current); hour = hour.Add(time.Hour) {
+		if err = a.storage.AggregateHourlyStats(hour); err != nil {
+			log.WithError(err).WithField("hour", hour).Error("failed to aggregate hourly stats")
+			return
+		}
+		aggregated++
+	}
+	if aggregated > 0 {
+		log.WithFields(log.Fields{
+			"hours": aggregated,
+			"until": current,
+		}).Debug("hourly stats aggregated")
+	}
+}
+
 // runAggregation performs the daily aggregation and purge tasks.
 func (a *Aggregator) runAggregation() {
 	log.Info("starting daily stats aggregation")
@@ -102,6 +167,18 @@ func (a *Aggregator) runAggregation() {
 		}).Info("purged detailed logs")
 	}
 
+	// Purge old hourly stats
+	hourlyCutoff := time.Now().UTC().Add(-a.hourlyRetention)
+	purged, err = a.storage.PurgeHourlyStats(hourlyCutoff)
+	if err != nil {
+		log.WithError(err).Error("failed to purge hourly stats")
+	} else if purged > 0 {
+		log.WithFields(log.Fields{
+			"purged": purged,
+			"before": hourlyCutoff,
+		}).Info("purged hourly stats")
+	}
+
 	// Purge old aggregated stats
 	aggregatedCutoff := time.Now().UTC().Add(-a.aggregatedRetention)
 	purged, err = a.storage.PurgeAggregatedStats(aggregatedCutoff)
@@ -117,21 +194,33 @@ func (a *Aggregator) runAggregation() {
 	log.WithField("duration", time.Since(start)).Info("daily stats aggregation completed")
 }
 
-// RunOnce performs a single aggregation for the specified date.
+// RunOnce performs a single aggregation for the specified date, including
+// the hours of the date.
 // This is useful for CLI commands or manual aggregation.
 func (a *Aggregator) RunOnce(date time.Time) error {
 	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	for hour := date; hour.Before(date.Add(24 * time.Hour)); hour = hour.Add(time.Hour) {
+		if err := a.storage.AggregateHourlyStats(hour); err != nil {
+			return err
+		}
+	}
 	return a.storage.AggregateDailyStats(date)
 }
 
 // Purge manually purges data older than the retention periods.
-func (a *Aggregator) Purge() (detailed int64, aggregated int64, err error) {
+func (a *Aggregator) Purge() (detailed, hourly, aggregated int64, err error) {
 	detailedCutoff := time.Now().UTC().Add(-a.detailedRetention)
 	detailed, err = a.storage.PurgeDetailedLogs(detailedCutoff)
 	if err != nil {
 		return
 	}
 
+	hourlyCutoff := time.Now().UTC().Add(-a.hourlyRetention)
+	hourly, err = a.storage.PurgeHourlyStats(hourlyCutoff)
+	if err != nil {
+		return
+	}
+
 	aggregatedCutoff := time.Now().UTC().Add(-a.aggregatedRetention)
 	aggregated, err = a.storage.PurgeAggregatedStats(aggregatedCutoff)
 	return
@@ -0,0 +1,373 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	apistats "github.com/go-oidfed/lighthouse/api/stats"
+	"github.com/go-oidfed/lighthouse/internal/stats"
+)
+
+// defaultInterval is the evaluation interval if none is configured.
+const defaultInterval = time.Minute
+
+// Storage is the interface for the statistics queries used to evaluate alert
+// rules.
+type Storage interface {
+	GetEndpointStats(from, to time.Time) ([]stats.EndpointStats, error)
+	GetLatencyPercentiles(from, to time.Time, endpoint string) (*stats.LatencyStats, error)
+}
+
+// Status of an alert
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Alert is a notification about an alert rule that started firing, is still
+// firing, or was resolved for an endpoint.
+type Alert struct {
+	Status    string  `json:"status"`
+	Rule      string  `json:"rule"`
+	Endpoint  string  `json:"endpoint"`
+	Metric    string  `json:"metric"`
+	Condition string  `json:"condition"`
+	Value     float64 `json:"value"`
+	// Threshold is the effective threshold; for rules with a baseline it is
+	// the configured factor applied to the baseline value.
+	Threshold float64    `json:"threshold"`
+	Baseline  *float64   `json:"baseline,omitempty"`
+	Instance  string     `json:"instance,omitempty"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+}
+
+// Summary returns a one-line description of the alert.
+func (a Alert) Summary() string {
+	if a.Status == StatusResolved {
+		return fmt.Sprintf(
+			"RESOLVED %s: %s %s is %.4g (threshold %.4g)", a.Rule, a.Endpoint, a.Metric, a.Value, a.Threshold,
+		)
+	}
+	return fmt.Sprintf(
+		"FIRING %s: %s %s is %.4g, %s threshold %.4g", a.Rule, a.Endpoint, a.Metric, a.Value, a.Condition,
+		a.Threshold,
+	)
+}
+
+// activeAlert is a firing alert and when it was last notified.
+type activeAlert struct {
+	alert        Alert
+	lastNotified time.Time
+}
+
+// Evaluator periodically evaluates alert rules over the recent statistics
+// and notifies the sinks when an alert starts firing or is resolved.
+type Evaluator struct {
+	config   apistats.AlertsConfig
+	storage  Storage
+	sinks    []Sink
+	instance string
+
+	mu     sync.Mutex
+	active map[string]*activeAlert
+	now    func() time.Time
+}
+
+// NewEvaluator creates a new Evaluator. The instance is included in the
+// alerts to identify this LightHouse, e.g. its entity ID.
+func NewEvaluator(cfg apistats.AlertsConfig, storage Storage, instance string, sinks ...Sink) *Evaluator {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	return &Evaluator{
+		config:   cfg,
+		storage:  storage,
+		sinks:    sinks,
+		instance: instance,
+		active:   make(map[string]*activeAlert),
+		now:      time.Now,
+	}
+}
+
+// Run evaluates the alert rules in the configured interval.
+// This method blocks until the context is cancelled.
+func (e *Evaluator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	log.WithFields(log.Fields{
+		"interval": e.config.Interval,
+		"rules":    len(e.config.Rules),
+	}).Info("stats alert evaluator started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			e.Evaluate(ctx)
+		}
+	}
+}
+
+// Active returns the currently firing alerts.
+func (e *Evaluator) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		alerts = append(alerts, a.alert)
+	}
+	slices.SortFunc(
+		alerts, func(a, b Alert) int {
+			return a.StartsAt.Compare(b.StartsAt)
+		},
+	)
+	return alerts
+}
+
+// Evaluate evaluates all alert rules once. The sinks are notified after the
+// evaluation, so that slow sinks do not block Active or other evaluations.
+func (e *Evaluator) Evaluate(ctx context.Context) {
+	for _, alert := range e.evaluate() {
+		e.notify(ctx, alert)
+	}
+}
+
+// evaluate evaluates all alert rules and returns the alerts to notify.
+func (e *Evaluator) evaluate() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	counts := &countCache{
+		storage: e.storage,
+		windows: make(map[[2]time.Time]map[string]stats.EndpointStats),
+	}
+	var notifications []Alert
+	for _, rule := range e.config.Rules {
+		alerts, err := e.evaluateRule(rule, now, counts)
+		if err != nil {
+			log.WithError(err).WithField("rule", rule.Name).Error("failed to evaluate stats alert rule")
+		}
+		notifications = append(notifications, alerts...)
+	}
+	return notifications
+}
+
+func (e *Evaluator) evaluateRule(rule apistats.AlertRule, now time.Time, counts *countCache) ([]Alert, error) {
+	from := now.Add(-rule.Window)
+	current, err := counts.get(from, now)
+	if err != nil {
+		return nil, err
+	}
+	var notifications []Alert
+	for _, endpoint := range e.ruleEndpoints(rule, current) {
+		value, ok, err := e.metric(rule, endpoint, from, now, current)
+		if err != nil {
+			return notifications, err
+		}
+		if !ok {
+			// Without enough requests the rule cannot be evaluated; a firing
+			// alert would otherwise never be resolved.
+			if alert, ok := e.resolveNoData(rule, endpoint, now); ok {
+				notifications = append(notifications, alert)
+			}
+			continue
+		}
+		threshold := rule.Threshold
+		var baseline *float64
+		if rule.BaselineWindow > 0 {
+			baselineFrom := from.Add(-rule.BaselineWindow)
+			previous, err := counts.get(baselineFrom, from)
+			if err != nil {
+				return notifications, err
+			}
+			b, ok, err := e.metric(rule, endpoint, baselineFrom, from, previous)
+			if err != nil {
+				return notifications, err
+			}
+			if !ok {
+				if alert, ok := e.resolveNoData(rule, endpoint, now); ok {
+					notifications = append(notifications, alert)
+				}
+				continue
+			}
+			if rule.Metric == apistats.AlertMetricRequestCount {
+				// Scale the count to the length of the evaluation window
+				b = b * float64(rule.Window) / float64(rule.BaselineWindow)
+			}
+			baseline = &b
+			threshold = rule.Threshold * b
+		}
+		violated := value > threshold
+		if rule.Condition == apistats.AlertConditionBelow {
+			violated = value < threshold
+		}
+		if alert, ok := e.update(rule, endpoint, violated, value, threshold, baseline, now); ok {
+			notifications = append(notifications, alert)
+		}
+	}
+	return notifications, nil
+}
+
+// ruleEndpoints returns the endpoints a rule is evaluated for: the
+// configured endpoints or all endpoints with requests and all endpoints for
+// which the rule is firing.
+func (e *Evaluator) ruleEndpoints(rule apistats.AlertRule, current map[string]stats.EndpointStats) []string {
+	if len(rule.Endpoints) > 0 {
+		return rule.Endpoints
+	}
+	endpoints := make([]string, 0, len(current))
+	for endpoint := range current {
+		endpoints = append(endpoints, endpoint)
+	}
+	for _, a := range e.active {
+		if a.alert.Rule == rule.Name && !slices.Contains(endpoints, a.alert.Endpoint) {
+			endpoints = append(endpoints, a.alert.Endpoint)
+		}
+	}
+	slices.Sort(endpoints)
+	return endpoints
+}
+
+// metric returns the value of the rule's metric for an endpoint. It returns
+// false if there are not enough requests to compute it.
+func (e *Evaluator) metric(
+	rule apistats.AlertRule, endpoint string, from, to time.Time, counts map[string]stats.EndpointStats,
+) (float64, bool, error) {
+	c := counts[endpoint]
+	if rule.Metric == apistats.AlertMetricRequestCount {
+		return float64(c.RequestCount), true, nil
+	}
+	if c.RequestCount == 0 || c.RequestCount < rule.MinRequests {
+		return 0, false, nil
+	}
+	if rule.Metric == apistats.AlertMetricErrorRate {
+		return float64(c.ErrorCount) / float64(c.RequestCount), true, nil
+	}
+	latency, err := e.storage.GetLatencyPercentiles(from, to, endpoint)
+	if err != nil {
+		return 0, false, err
+	}
+	switch rule.Metric {
+	case apistats.AlertMetricLatencyAvg:
+		return latency.AvgMs, true, nil
+	case apistats.AlertMetricLatencyP50:
+		return float64(latency.P50Ms), true, nil
+	case apistats.AlertMetricLatencyP75:
+		return float64(latency.P75Ms), true, nil
+	case apistats.AlertMetricLatencyP90:
+		return float64(latency.P90Ms), true, nil
+	case apistats.AlertMetricLatencyP95:
+		return float64(latency.P95Ms), true, nil
+	case apistats.AlertMetricLatencyP99:
+		return float64(latency.P99Ms), true, nil
+	}
+	return 0, false, fmt.Errorf("unknown metric '%s'", rule.Metric)
+}
+
+// update updates the state of the rule for the endpoint and returns the alert
+// to notify if it started firing, is resolved, or is due to be repeated.
+func (e *Evaluator) update(
+	rule apistats.AlertRule, endpoint string, violated bool, value, threshold float64,
+	baseline *float64, now time.Time,
+) (Alert, bool) {
+	key := rule.Name + " " + endpoint
+	active, firing := e.active[key]
+	switch {
+	case violated && !firing:
+		alert := Alert{
+			Status:    StatusFiring,
+			Rule:      rule.Name,
+			Endpoint:  endpoint,
+			Metric:    rule.Metric,
+			Condition: rule.Condition,
+			Value:     value,
+			Threshold: threshold,
+			Baseline:  baseline,
+			Instance:  e.instance,
+			StartsAt:  now,
+		}
+		e.active[key] = &activeAlert{
+			alert:        alert,
+			lastNotified: now,
+		}
+		return alert, true
+	case violated:
+		active.alert.Value = value
+		active.alert.Threshold = threshold
+		active.alert.Baseline = baseline
+		if e.config.RepeatInterval > 0 && now.Sub(active.lastNotified) >= e.config.RepeatInterval {
+			active.lastNotified = now
+			return active.alert, true
+		}
+	case firing:
+		delete(e.active, key)
+		alert := active.alert
+		alert.Status = StatusResolved
+		alert.Value = value
+		alert.Threshold = threshold
+		alert.Baseline = baseline
+		alert.EndsAt = &now
+		return alert, true
+	}
+	return Alert{}, false
+}
+
+// resolveNoData resolves the alert of the rule for the endpoint, if it is
+// firing, because there are not enough requests to evaluate the rule. The
+// resolved alert keeps the last evaluated value.
+func (e *Evaluator) resolveNoData(rule apistats.AlertRule, endpoint string, now time.Time) (Alert, bool) {
+	key := rule.Name + " " + endpoint
+	active, firing := e.active[key]
+	if !firing {
+		return Alert{}, false
+	}
+	delete(e.active, key)
+	alert := active.alert
+	alert.Status = StatusResolved
+	alert.EndsAt = &now
+	return alert, true
+}
+
+func (e *Evaluator) notify(ctx context.Context, alert Alert) {
+	for _, sink := range e.sinks {
+		if err := sink.Notify(ctx, alert); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"rule":     alert.Rule,
+				"endpoint": alert.Endpoint,
+				"sink":     fmt.Sprintf("%T", sink),
+			}).Error("failed to send stats alert")
+		}
+	}
+}
+
+// countCache caches the endpoint stats of the windows queried during one
+// evaluation, since rules often share windows.
+type countCache struct {
+	storage Storage
+	windows map[[2]time.Time]map[string]stats.EndpointStats
+}
+
+func (c *countCache) get(from, to time.Time) (map[string]stats.EndpointStats, error) {
+	key := [2]time.Time{from, to}
+	if counts, ok := c.windows[key]; ok {
+		return counts, nil
+	}
+	list, err := c.storage.GetEndpointStats(from, to)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]stats.EndpointStats, len(list))
+	for _, s := range list {
+		counts[s.Endpoint] = s
+	}
+	c.windows[key] = counts
+	return counts, nil
+}
@@ -0,0 +1,380 @@
+package alerting
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	apistats "github.com/go-oidfed/lighthouse/api/stats"
+	"github.com/go-oidfed/lighthouse/internal/stats"
+)
+
+// fakeStorage returns fixed statistics; the baseline statistics are returned
+// for windows ending before the current window.
+type fakeStorage struct {
+	current  []stats.EndpointStats
+	baseline []stats.EndpointStats
+	latency  map[string]*stats.LatencyStats
+	now      time.Time
+}
+
+func (s *fakeStorage) GetEndpointStats(_, to time.Time) ([]stats.EndpointStats, error) {
+	if to.Before(s.now) {
+		return s.baseline, nil
+	}
+	return s.current, nil
+}
+
+func (s *fakeStorage) GetLatencyPercentiles(_, _ time.Time, endpoint string) (*stats.LatencyStats, error) {
+	if l, ok := s.latency[endpoint]; ok {
+		return l, nil
+	}
+	return &stats.LatencyStats{}, nil
+}
+
+// recordingSink records all notified alerts.
+type recordingSink struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (s *recordingSink) Notify(_ context.Context, alert Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func (s *recordingSink) take() []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alerts := s.alerts
+	s.alerts = nil
+	return alerts
+}
+
+func newTestEvaluator(cfg apistats.AlertsConfig, storage *fakeStorage) (*Evaluator, *recordingSink) {
+	sink := &recordingSink{}
+	e := NewEvaluator(cfg, storage, "https://lh.example.org", sink)
+	storage.now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time {
+		return storage.now
+	}
+	return e, sink
+}
+
+func requireAlerts(t *testing.T, alerts []Alert, statuses ...string) {
+	t.Helper()
+	if len(alerts) != len(statuses) {
+		t.Fatalf("Expected %d alerts, got %d: %+v", len(statuses), len(alerts), alerts)
+	}
+	for i, status := range statuses {
+		if alerts[i].Status != status {
+			t.Errorf("Expected alert %d to be %s, got %s", i, status, alerts[i].Status)
+		}
+	}
+}
+
+func TestEvaluator_ErrorRate(t *testing.T) {
+	storage := &fakeStorage{
+		current: []stats.EndpointStats{
+			{Endpoint: "/fetch", RequestCount: 100, ErrorCount: 20},
+			{Endpoint: "/resolve", RequestCount: 100, ErrorCount: 1},
+		},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:      "errors",
+					Metric:    apistats.AlertMetricErrorRate,
+					Condition: apistats.AlertConditionAbove,
+					Threshold: 0.1,
+					Window:    5 * time.Minute,
+				},
+			},
+		}, storage,
+	)
+	ctx := context.Background()
+
+	e.Evaluate(ctx)
+	alerts := sink.take()
+	requireAlerts(t, alerts, StatusFiring)
+	a := alerts[0]
+	if a.Rule != "errors" || a.Endpoint != "/fetch" || a.Value != 0.2 || a.Threshold != 0.1 {
+		t.Errorf("Unexpected alert: %+v", a)
+	}
+	if a.Instance != "https://lh.example.org" || !a.StartsAt.Equal(storage.now) {
+		t.Errorf("Unexpected alert: %+v", a)
+	}
+	if len(e.Active()) != 1 {
+		t.Errorf("Expected one active alert, got %+v", e.Active())
+	}
+
+	// Still firing: de-duplicated
+	storage.now = storage.now.Add(time.Minute)
+	e.Evaluate(ctx)
+	requireAlerts(t, sink.take())
+
+	// Recovered
+	storage.now = storage.now.Add(time.Minute)
+	storage.current[0].ErrorCount = 2
+	e.Evaluate(ctx)
+	alerts = sink.take()
+	requireAlerts(t, alerts, StatusResolved)
+	if alerts[0].EndsAt == nil || !alerts[0].EndsAt.Equal(storage.now) || alerts[0].Value != 0.02 {
+		t.Errorf("Unexpected resolved alert: %+v", alerts[0])
+	}
+	if len(e.Active()) != 0 {
+		t.Errorf("Expected no active alerts, got %+v", e.Active())
+	}
+}
+
+func TestEvaluator_RepeatInterval(t *testing.T) {
+	storage := &fakeStorage{
+		current: []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 10, ErrorCount: 10}},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			RepeatInterval: 10 * time.Minute,
+			Rules: []apistats.AlertRule{
+				{
+					Name:      "errors",
+					Endpoints: []string{"/fetch"},
+					Metric:    apistats.AlertMetricErrorRate,
+					Condition: apistats.AlertConditionAbove,
+					Threshold: 0.5,
+					Window:    5 * time.Minute,
+				},
+			},
+		}, storage,
+	)
+	ctx := context.Background()
+	e.Evaluate(ctx)
+	requireAlerts(t, sink.take(), StatusFiring)
+	storage.now = storage.now.Add(5 * time.Minute)
+	e.Evaluate(ctx)
+	requireAlerts(t, sink.take())
+	storage.now = storage.now.Add(5 * time.Minute)
+	e.Evaluate(ctx)
+	requireAlerts(t, sink.take(), StatusFiring)
+}
+
+func TestEvaluator_MinRequests(t *testing.T) {
+	storage := &fakeStorage{
+		current: []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 3, ErrorCount: 3}},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:        "errors",
+					Metric:      apistats.AlertMetricErrorRate,
+					Condition:   apistats.AlertConditionAbove,
+					Threshold:   0.5,
+					Window:      5 * time.Minute,
+					MinRequests: 10,
+				},
+			},
+		}, storage,
+	)
+	e.Evaluate(context.Background())
+	requireAlerts(t, sink.take())
+}
+
+func TestEvaluator_LatencyBaseline(t *testing.T) {
+	storage := &fakeStorage{
+		current:  []stats.EndpointStats{{Endpoint: "/resolve", RequestCount: 50}},
+		baseline: []stats.EndpointStats{{Endpoint: "/resolve", RequestCount: 500}},
+		latency:  map[string]*stats.LatencyStats{"/resolve": {P95Ms: 250}},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:           "latency",
+					Endpoints:      []string{"/resolve"},
+					Metric:         apistats.AlertMetricLatencyP95,
+					Condition:      apistats.AlertConditionAbove,
+					Threshold:      2,
+					Window:         5 * time.Minute,
+					BaselineWindow: time.Hour,
+				},
+			},
+		}, storage,
+	)
+	// The fake returns the same latency for both windows, so the p95 has not
+	// doubled
+	e.Evaluate(context.Background())
+	requireAlerts(t, sink.take())
+
+	storage.latency["/resolve"] = &stats.LatencyStats{P95Ms: 100}
+	e.storage = &baselineLatencyStorage{
+		fakeStorage: storage,
+		current:     &stats.LatencyStats{P95Ms: 250},
+	}
+	e.Evaluate(context.Background())
+	alerts := sink.take()
+	requireAlerts(t, alerts, StatusFiring)
+	if alerts[0].Baseline == nil || *alerts[0].Baseline != 100 || alerts[0].Threshold != 200 {
+		t.Errorf("Unexpected alert: %+v", alerts[0])
+	}
+}
+
+// baselineLatencyStorage returns different latencies for the current and the
+// baseline window.
+type baselineLatencyStorage struct {
+	*fakeStorage
+	current *stats.LatencyStats
+}
+
+func (s *baselineLatencyStorage) GetLatencyPercentiles(
+	from, to time.Time, endpoint string,
+) (*stats.LatencyStats, error) {
+	if to.Before(s.now) {
+		return s.fakeStorage.GetLatencyPercentiles(from, to, endpoint)
+	}
+	return s.current, nil
+}
+
+func TestEvaluator_RequestCountBelow(t *testing.T) {
+	storage := &fakeStorage{
+		current:  []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 10}},
+		baseline: []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 1200}},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:           "traffic-drop",
+					Endpoints:      []string{"/fetch", "/list"},
+					Metric:         apistats.AlertMetricRequestCount,
+					Condition:      apistats.AlertConditionBelow,
+					Threshold:      0.5,
+					Window:         5 * time.Minute,
+					BaselineWindow: time.Hour,
+				},
+			},
+		}, storage,
+	)
+	// Baseline of /fetch is 1200 per hour, i.e. 100 per 5 minutes; /list has
+	// no requests at all, so its baseline of 0 is not violated
+	e.Evaluate(context.Background())
+	alerts := sink.take()
+	requireAlerts(t, alerts, StatusFiring)
+	if alerts[0].Endpoint != "/fetch" || *alerts[0].Baseline != 100 || alerts[0].Threshold != 50 {
+		t.Errorf("Unexpected alert: %+v", alerts[0])
+	}
+}
+
+func TestEvaluator_ResolvesVanishedEndpoint(t *testing.T) {
+	storage := &fakeStorage{
+		current: []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 500}},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:      "volume",
+					Metric:    apistats.AlertMetricRequestCount,
+					Condition: apistats.AlertConditionAbove,
+					Threshold: 100,
+					Window:    5 * time.Minute,
+				},
+			},
+		}, storage,
+	)
+	e.Evaluate(context.Background())
+	requireAlerts(t, sink.take(), StatusFiring)
+	storage.current = nil
+	e.Evaluate(context.Background())
+	alerts := sink.take()
+	requireAlerts(t, alerts, StatusResolved)
+	if alerts[0].Endpoint != "/fetch" || alerts[0].Value != 0 {
+		t.Errorf("Unexpected alert: %+v", alerts[0])
+	}
+}
+
+func TestEvaluator_ResolvesOnTooFewRequests(t *testing.T) {
+	storage := &fakeStorage{
+		current: []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 20, ErrorCount: 15}},
+	}
+	e, sink := newTestEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:        "errors",
+					Metric:      apistats.AlertMetricErrorRate,
+					Condition:   apistats.AlertConditionAbove,
+					Threshold:   0.5,
+					Window:      5 * time.Minute,
+					MinRequests: 10,
+				},
+			},
+		}, storage,
+	)
+	e.Evaluate(context.Background())
+	requireAlerts(t, sink.take(), StatusFiring)
+
+	storage.current = []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 3, ErrorCount: 3}}
+	e.Evaluate(context.Background())
+	alerts := sink.take()
+	requireAlerts(t, alerts, StatusResolved)
+	if alerts[0].Value != 0.75 || alerts[0].EndsAt == nil {
+		t.Errorf("Expected the last value to be kept, got %+v", alerts[0])
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Errorf("Expected no active alerts, got %+v", active)
+	}
+	e.Evaluate(context.Background())
+	requireAlerts(t, sink.take())
+}
+
+// blockingSink blocks until it is released.
+type blockingSink struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSink) Notify(ctx context.Context, _ Alert) error {
+	s.called <- struct{}{}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func TestEvaluator_SlowSink(t *testing.T) {
+	storage := &fakeStorage{
+		current: []stats.EndpointStats{{Endpoint: "/fetch", RequestCount: 500}},
+	}
+	sink := &blockingSink{called: make(chan struct{}, 1), release: make(chan struct{})}
+	e := NewEvaluator(
+		apistats.AlertsConfig{
+			Rules: []apistats.AlertRule{
+				{
+					Name:      "volume",
+					Metric:    apistats.AlertMetricRequestCount,
+					Condition: apistats.AlertConditionAbove,
+					Threshold: 100,
+					Window:    5 * time.Minute,
+				},
+			},
+		}, storage, "", sink,
+	)
+	done := make(chan struct{})
+	go func() {
+		e.Evaluate(context.Background())
+		close(done)
+	}()
+	<-sink.called
+
+	// The evaluator state is not locked while the sink is notified
+	if active := e.Active(); len(active) != 1 {
+		t.Errorf("Expected one active alert, got %+v", active)
+	}
+	close(sink.release)
+	<-done
+}
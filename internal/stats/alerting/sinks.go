@@ -0,0 +1,213 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	apistats "github.com/go-oidfed/lighthouse/api/stats"
+)
+
+// defaultWebhookTimeout is the timeout of webhook requests if none is
+// configured.
+const defaultWebhookTimeout = 10 * time.Second
+
+// defaultSMTPTimeout is the timeout for sending an email if none is
+// configured.
+const defaultSMTPTimeout = 10 * time.Second
+
+// Sink delivers alert notifications.
+type Sink interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NewSinks creates the sinks for the passed configuration. Alerts are always
+// logged; the webhook and SMTP sinks are added if configured.
+func NewSinks(cfg apistats.AlertsConfig) []Sink {
+	sinks := []Sink{LogSink{}}
+	if cfg.Webhook.URL != "" {
+		sinks = append(sinks, NewWebhookSink(cfg.Webhook))
+	}
+	if cfg.SMTP.Host != "" {
+		sinks = append(sinks, NewSMTPSink(cfg.SMTP))
+	}
+	return sinks
+}
+
+// LogSink logs alerts; firing alerts are logged as warnings.
+type LogSink struct{}
+
+// Notify implements the Sink interface.
+func (LogSink) Notify(_ context.Context, alert Alert) error {
+	entry := log.WithFields(log.Fields{
+		"rule":      alert.Rule,
+		"endpoint":  alert.Endpoint,
+		"metric":    alert.Metric,
+		"value":     alert.Value,
+		"threshold": alert.Threshold,
+	})
+	if alert.Status == StatusFiring {
+		entry.Warn("stats alert firing")
+	} else {
+		entry.Info("stats alert resolved")
+	}
+	return nil
+}
+
+// WebhookSink posts alerts as JSON to a URL.
+type WebhookSink struct {
+	config apistats.WebhookSinkConfig
+	client *http.Client
+}
+
+// NewWebhookSink creates a new WebhookSink.
+func NewWebhookSink(cfg apistats.WebhookSinkConfig) *WebhookSink {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookSink{
+		config: cfg,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify implements the Sink interface.
+func (s *WebhookSink) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPSink sends alerts by email.
+type SMTPSink struct {
+	config apistats.SMTPSinkConfig
+}
+
+// NewSMTPSink creates a new SMTPSink.
+func NewSMTPSink(cfg apistats.SMTPSinkConfig) *SMTPSink {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultSMTPTimeout
+	}
+	return &SMTPSink{config: cfg}
+}
+
+// Notify implements the Sink interface. Unlike smtp.SendMail, sending is
+// bounded by the configured timeout and the context.
+func (s *SMTPSink) Notify(ctx context.Context, alert Alert) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Cancelling the context aborts a pending read or write
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.config.From); err != nil {
+		return err
+	}
+	for _, to := range s.config.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(s.message(alert)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message returns the email message for the alert.
+func (s *SMTPSink) message(alert Alert) []byte {
+	var b strings.Builder
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", s.config.From)
+	header("To", strings.Join(s.config.To, ", "))
+	header("Subject", "[LightHouse] "+alert.Summary())
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+
+	line := func(k, v string) {
+		b.WriteString(fmt.Sprintf("%-11s %s\r\n", k+":", v))
+	}
+	line("Status", alert.Status)
+	line("Rule", alert.Rule)
+	line("Endpoint", alert.Endpoint)
+	line("Metric", alert.Metric)
+	line("Value", strconv.FormatFloat(alert.Value, 'g', 6, 64))
+	line("Threshold", alert.Condition+" "+strconv.FormatFloat(alert.Threshold, 'g', 6, 64))
+	if alert.Baseline != nil {
+		line("Baseline", strconv.FormatFloat(*alert.Baseline, 'g', 6, 64))
+	}
+	if alert.Instance != "" {
+		line("Instance", alert.Instance)
+	}
+	line("Started at", alert.StartsAt.Format(time.RFC3339))
+	if alert.EndsAt != nil {
+		line("Resolved at", alert.EndsAt.Format(time.RFC3339))
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,252 @@
+package alerting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apistats "github.com/go-oidfed/lighthouse/api/stats"
+)
+
+func testAlert() Alert {
+	return Alert{
+		Status:    StatusFiring,
+		Rule:      "errors",
+		Endpoint:  "/fetch",
+		Metric:    apistats.AlertMetricErrorRate,
+		Condition: apistats.AlertConditionAbove,
+		Value:     0.2,
+		Threshold: 0.1,
+		Instance:  "https://lh.example.org",
+		StartsAt:  time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received Alert
+	var auth string
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer srv.Close()
+
+	sink := NewWebhookSink(
+		apistats.WebhookSinkConfig{
+			URL:     srv.URL,
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+		},
+	)
+	if err := sink.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Expected configured header, got %q", auth)
+	}
+	if received.Rule != "errors" || received.Endpoint != "/fetch" || received.Status != StatusFiring {
+		t.Errorf("Unexpected alert received: %+v", received)
+	}
+}
+
+func TestWebhookSink_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		),
+	)
+	defer srv.Close()
+
+	sink := NewWebhookSink(apistats.WebhookSinkConfig{URL: srv.URL})
+	if err := sink.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("Expected error for failed webhook")
+	}
+}
+
+// startSMTPServer starts a minimal local SMTP server that accepts a single
+// message and sends its data to the returned channel.
+func startSMTPServer(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		write := func(s string) {
+			_, _ = conn.Write([]byte(s + "\r\n"))
+		}
+		write("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				write("250 OK")
+			case cmd == "DATA":
+				write("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				messages <- data.String()
+				write("250 OK")
+			case cmd == "QUIT":
+				write("221 Bye")
+				return
+			default:
+				write("250 OK")
+			}
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+func TestSMTPSink(t *testing.T) {
+	host, port, messages := startSMTPServer(t)
+	sink := NewSMTPSink(
+		apistats.SMTPSinkConfig{
+			Host: host,
+			Port: port,
+			From: "lighthouse@example.org",
+			To:   []string{"ops@example.org"},
+		},
+	)
+	alert := testAlert()
+	ends := alert.StartsAt.Add(time.Hour)
+	alert.Status = StatusResolved
+	alert.EndsAt = &ends
+	if err := sink.Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	select {
+	case msg := <-messages:
+		for _, expected := range []string{
+			"To: ops@example.org",
+			"Subject: [LightHouse] RESOLVED errors: /fetch error_rate",
+			"Endpoint:   /fetch",
+			"Resolved at: " + ends.Format(time.RFC3339),
+		} {
+			if !strings.Contains(msg, expected) {
+				t.Errorf("Expected message to contain %q, got:\n%s", expected, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received by SMTP server on port " + strconv.Itoa(port))
+	}
+}
+
+func TestNewSinks(t *testing.T) {
+	sinks := NewSinks(apistats.AlertsConfig{})
+	if len(sinks) != 1 {
+		t.Fatalf("Expected only the log sink, got %d sinks", len(sinks))
+	}
+	sinks = NewSinks(
+		apistats.AlertsConfig{
+			Webhook: apistats.WebhookSinkConfig{URL: "https://hooks.example.org"},
+			SMTP:    apistats.SMTPSinkConfig{Host: "smtp.example.org"},
+		},
+	)
+	if len(sinks) != 3 {
+		t.Fatalf("Expected three sinks, got %d", len(sinks))
+	}
+}
+
+func TestSMTPSinkTimeout(t *testing.T) {
+	// A server that accepts connections but never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(
+		func() {
+			_ = l.Close()
+			mu.Lock()
+			defer mu.Unlock()
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		},
+	)
+	addr := l.Addr().(*net.TCPAddr)
+	sink := NewSMTPSink(
+		apistats.SMTPSinkConfig{
+			Host:    addr.IP.String(),
+			Port:    addr.Port,
+			From:    "lighthouse@example.org",
+			To:      []string{"ops@example.org"},
+			Timeout: 100 * time.Millisecond,
+		},
+	)
+
+	start := time.Now()
+	if err = sink.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("Expected a timeout error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Expected Notify to time out after the configured timeout, took %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink.config.Timeout = time.Minute
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start = time.Now()
+	if err = sink.Notify(ctx, testAlert()); err == nil {
+		t.Fatal("Expected an error for a cancelled context")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Expected Notify to return when the context is cancelled, took %v", d)
+	}
+}
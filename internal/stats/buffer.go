@@ -18,6 +18,9 @@ type RingBuffer struct {
 	// It's a buffered channel (size 1) so sends never block.
 	NotifyThreshold chan struct{}
 	threshold       float64
+
+	// tail receives all written entries; it is set before the buffer is used
+	tail *Tail
 }
 
 // NewRingBuffer creates a new ring buffer with the given capacity and flush threshold.
@@ -38,10 +41,18 @@ func NewRingBuffer(capacity int, threshold float64) *RingBuffer {
 	}
 }
 
+// SetTail sets the Tail to which all written entries are published. It must
+// be called before the buffer is used.
+func (b *RingBuffer) SetTail(tail *Tail) {
+	b.tail = tail
+}
+
 // Write adds an entry to the buffer. This operation is non-blocking and always succeeds.
 // If the buffer is full, the oldest entry is overwritten.
 // Returns true if the threshold was reached (caller may want to trigger a flush).
 func (b *RingBuffer) Write(entry *RequestLog) bool {
+	b.tail.Publish(entry)
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
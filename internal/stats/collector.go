@@ -2,9 +2,11 @@ package stats
 
 import (
 	"context"
+	"os"
 	"sync"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/go-oidfed/lighthouse/api/stats"
@@ -18,6 +20,16 @@ type Collector struct {
 	geoIP   GeoIPProvider
 	storage StorageBackend
 
+	anonymizer *Anonymizer
+	sampler    *Sampler
+	tail       *Tail
+
+	// In prefork mode the parent receives the entries of the children
+	// through the receiver, while the children forward them with the
+	// forwarder instead of writing to the database.
+	receiver  *Receiver
+	forwarder *Forwarder
+
 	trackedEndpoints map[string]bool
 
 	ctx    context.Context
@@ -31,6 +43,12 @@ func NewCollector(cfg stats.Config, storage StorageBackend) (*Collector, error)
 		return nil, nil
 	}
 
+	// Random salts are per process, so prefork processes would hash the
+	// same client differently
+	if cfg.Prefork && cfg.Privacy.ClientIPMode == stats.ClientIPModeHash && cfg.Privacy.HashKey == "" {
+		return nil, errors.New("privacy: a hash key is required to hash client IPs in prefork mode")
+	}
+
 	// Create ring buffer
 	buffer := NewRingBuffer(cfg.BufferSize, cfg.FlushThreshold)
 
@@ -47,11 +65,29 @@ func NewCollector(cfg stats.Config, storage StorageBackend) (*Collector, error)
 		}
 	}
 
+	// All recorded entries, including those of prefork children, pass the
+	// buffer and are published to the tail
+	tail := NewTail()
+	buffer.SetTail(tail)
+
 	// Build tracked endpoints map
 	trackedEndpoints := BuildTrackedEndpoints(cfg.Endpoints)
 
+	// In prefork mode children forward their entries to the parent process
+	var receiver *Receiver
+	var forwarder *Forwarder
+	flushTarget := storage
+	if cfg.Prefork {
+		if fiber.IsChild() {
+			forwarder = NewForwarder(ForwardSocketPath(cfg.SocketDir, os.Getppid()))
+			flushTarget = forwarder
+		} else {
+			receiver = NewReceiver(ForwardSocketPath(cfg.SocketDir, os.Getpid()), buffer)
+		}
+	}
+
 	// Create flusher
-	flusher := NewFlusher(buffer, storage, cfg.FlushInterval)
+	flusher := NewFlusher(buffer, flushTarget, cfg.FlushInterval)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -61,6 +97,11 @@ func NewCollector(cfg stats.Config, storage StorageBackend) (*Collector, error)
 		flusher:          flusher,
 		geoIP:            geoIP,
 		storage:          storage,
+		anonymizer:       NewAnonymizer(cfg.Privacy),
+		sampler:          NewSampler(cfg.Sampling),
+		tail:             tail,
+		receiver:         receiver,
+		forwarder:        forwarder,
 		trackedEndpoints: trackedEndpoints,
 		ctx:              ctx,
 		cancel:           cancel,
@@ -79,19 +120,30 @@ func (c *Collector) Middleware() fiber.Handler {
 		CaptureClientIP:    c.config.CaptureClientIP,
 		CaptureUserAgent:   c.config.CaptureUserAgent,
 		CaptureQueryParams: c.config.CaptureQueryParams,
+		CaptureSubject:     c.config.CaptureSubject,
 		GeoIP:              c.geoIP,
+		Anonymizer:         c.anonymizer,
+		Sampler:            c.sampler,
 		TrackedEndpoints:   c.trackedEndpoints,
 		Buffer:             c.buffer,
 	})
 }
 
 // Start begins the background flushing goroutine.
+// In prefork mode the parent process also starts receiving the entries of
+// the child processes.
 // This method is non-blocking and returns immediately.
 func (c *Collector) Start() {
 	if c == nil {
 		return
 	}
 
+	if c.receiver != nil {
+		if err := c.receiver.Listen(); err != nil {
+			log.WithError(err).Error("failed to start stats receiver, requests of prefork children are not recorded")
+		}
+	}
+
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
@@ -99,6 +151,13 @@ func (c *Collector) Start() {
 			log.WithError(err).Error("stats flusher exited with error")
 		}
 	}()
+	if c.sampler != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.sampler.RunAdaptive(c.ctx, c.buffer)
+		}()
+	}
 
 	log.Info("stats collector started")
 }
@@ -111,8 +170,20 @@ func (c *Collector) Stop() error {
 	}
 
 	log.Info("stopping stats collector")
+	// Receive the remaining entries of the children before the final flush
+	if c.receiver != nil {
+		if err := c.receiver.Close(); err != nil {
+			log.WithError(err).Warn("failed to close stats receiver")
+		}
+	}
 	c.cancel()
 	c.wg.Wait()
+	c.tail.Close()
+	if c.forwarder != nil {
+		if err := c.forwarder.Close(); err != nil {
+			log.WithError(err).Warn("failed to close stats forwarder")
+		}
+	}
 
 	// Close GeoIP database if applicable
 	if closer, ok := c.geoIP.(interface{ Close() error }); ok {
@@ -134,6 +205,20 @@ func (c *Collector) Record(entry *RequestLog) {
 	c.buffer.Write(entry)
 }
 
+// Subscribe streams the recorded entries matching the filter, see
+// Tail.Subscribe. In prefork mode only the collector of the parent sees the
+// entries of all children, which reach it with each flush of the children.
+// The collector of a child only streams the entries of that child, e.g. if
+// the admin API is mounted on the main server and thus served by a child.
+func (c *Collector) Subscribe(filter TailFilter, size int) (<-chan *RequestLog, func()) {
+	if c == nil {
+		entries := make(chan *RequestLog)
+		close(entries)
+		return entries, func() {}
+	}
+	return c.tail.Subscribe(filter, size)
+}
+
 // BufferStats returns current buffer statistics.
 func (c *Collector) BufferStats() BufferStats {
 	if c == nil || c.buffer == nil {
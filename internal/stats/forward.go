@@ -0,0 +1,214 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// In prefork mode requests are served by child processes, while only the
+// parent process writes statistics to the database. Each child flushes its
+// ring buffer to a Forwarder, which sends the entries over a unix socket to
+// the Receiver of the parent, which writes them into the parent's ring buffer.
+
+// forwardDialTimeout is the timeout for connecting to the parent's socket.
+const forwardDialTimeout = 2 * time.Second
+
+// receiverCloseGracePeriod is how long the receiver keeps reading from open
+// connections when it is closed.
+const receiverCloseGracePeriod = time.Second
+
+// ForwardSocketPath returns the path of the unix socket on which the stats
+// receiver of the process with the given pid listens. If dir is empty, the
+// os temp dir is used.
+func ForwardSocketPath(dir string, pid int) string {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("lighthouse-stats-%d.sock", pid))
+}
+
+// Forwarder implements StorageBackend by sending batches of request logs to
+// a Receiver over a unix socket. Each batch is sent as a single JSON line.
+type Forwarder struct {
+	path string
+
+	mu   sync.Mutex
+	conn net.Conn
+	enc  *json.Encoder
+}
+
+// NewForwarder creates a new Forwarder that sends to the socket at path.
+// The connection is established lazily and re-established after errors.
+func NewForwarder(path string) *Forwarder {
+	return &Forwarder{path: path}
+}
+
+// InsertBatch sends the entries to the receiver. If sending over an
+// existing connection fails, e.g. because the parent process was restarted,
+// the entries are sent once more over a new connection.
+func (f *Forwarder) InsertBatch(entries []*RequestLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reused := f.conn != nil
+	err := f.send(entries)
+	if err != nil && reused {
+		err = f.send(entries)
+	}
+	return err
+}
+
+// send sends the entries, connecting to the receiver if needed.
+func (f *Forwarder) send(entries []*RequestLog) error {
+	if f.conn == nil {
+		conn, err := net.DialTimeout("unix", f.path, forwardDialTimeout)
+		if err != nil {
+			return errors.Wrap(err, "failed to connect to stats receiver")
+		}
+		f.conn = conn
+		f.enc = json.NewEncoder(conn)
+	}
+	if err := f.enc.Encode(entries); err != nil {
+		_ = f.closeConn()
+		return errors.Wrap(err, "failed to forward stats")
+	}
+	return nil
+}
+
+// Close closes the connection to the receiver.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closeConn()
+}
+
+func (f *Forwarder) closeConn() error {
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	f.enc = nil
+	return err
+}
+
+// Receiver accepts request logs from Forwarders on a unix socket and writes
+// them into a ring buffer.
+type Receiver struct {
+	path   string
+	buffer *RingBuffer
+
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewReceiver creates a new Receiver that listens on the socket at path and
+// writes the received entries into buffer.
+func NewReceiver(path string, buffer *RingBuffer) *Receiver {
+	return &Receiver{
+		path:   path,
+		buffer: buffer,
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen creates the socket and starts accepting connections in the
+// background. A stale socket file at the path is removed.
+func (r *Receiver) Listen() error {
+	if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove stale stats socket")
+	}
+	listener, err := net.Listen("unix", r.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on stats socket")
+	}
+	if err = os.Chmod(r.path, 0600); err != nil {
+		_ = listener.Close()
+		return errors.Wrap(err, "failed to set permissions of stats socket")
+	}
+	r.listener = listener
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.accept()
+	}()
+	log.WithField("socket", r.path).Info("stats receiver listening for prefork children")
+	return nil
+}
+
+func (r *Receiver) accept() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.WithError(err).Error("stats receiver stopped accepting connections")
+			}
+			return
+		}
+		r.mu.Lock()
+		r.conns[conn] = struct{}{}
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.handle(conn)
+		}()
+	}
+}
+
+// handle reads batches from a single connection until it is closed.
+func (r *Receiver) handle(conn net.Conn) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var entries []*RequestLog
+		if err := dec.Decode(&entries); err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if entry != nil {
+				r.buffer.Write(entry)
+			}
+		}
+	}
+}
+
+// Close stops accepting connections, gives open connections a short grace
+// period to deliver pending entries into the buffer, and removes the socket.
+func (r *Receiver) Close() error {
+	if r.listener == nil {
+		return nil
+	}
+	err := r.listener.Close()
+	deadline := time.Now().Add(receiverCloseGracePeriod)
+	r.mu.Lock()
+	for conn := range r.conns {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	_ = os.Remove(r.path)
+	return err
+}
@@ -0,0 +1,356 @@
+package stats
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-oidfed/lighthouse/api/stats"
+)
+
+// recordingStorage is a StorageBackend that records all inserted entries.
+type recordingStorage struct {
+	mu      sync.Mutex
+	entries []*RequestLog
+}
+
+func (s *recordingStorage) InsertBatch(entries []*RequestLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *recordingStorage) endpoints() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return logEndpoints(s.entries)
+}
+
+func logEndpoints(entries []*RequestLog) []string {
+	endpoints := make([]string, len(entries))
+	for i, e := range entries {
+		endpoints[i] = e.Endpoint
+	}
+	return endpoints
+}
+
+func newTestLogs(endpoints ...string) []*RequestLog {
+	entries := make([]*RequestLog, len(endpoints))
+	for i, endpoint := range endpoints {
+		entries[i] = &RequestLog{
+			Timestamp:  time.Now(),
+			Endpoint:   endpoint,
+			Method:     "GET",
+			StatusCode: 200,
+			SampleRate: 1,
+		}
+	}
+	return entries
+}
+
+// newTestReceiver starts a Receiver on a socket in a temporary directory.
+func newTestReceiver(t *testing.T, path string) (*Receiver, *RingBuffer) {
+	t.Helper()
+	buffer := NewRingBuffer(100, 1)
+	r := NewReceiver(path, buffer)
+	if err := r.Listen(); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r, buffer
+}
+
+// waitForEntries drains the buffer until it has received n entries in total
+// and returns their endpoints.
+func waitForEntries(t *testing.T, buffer *RingBuffer, n int) []string {
+	t.Helper()
+	var got []*RequestLog
+	deadline := time.Now().Add(5 * time.Second)
+	for len(got) < n && time.Now().Before(deadline) {
+		got = append(got, buffer.Drain()...)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(got) != n {
+		t.Fatalf("Expected %d entries, got %v", n, logEndpoints(got))
+	}
+	return logEndpoints(got)
+}
+
+func assertEndpoints(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected entries %v, got %v", want, got)
+		}
+	}
+}
+
+func TestForwardSocketPath(t *testing.T) {
+	t.Parallel()
+	if got := ForwardSocketPath("/run/lighthouse", 42); got != "/run/lighthouse/lighthouse-stats-42.sock" {
+		t.Errorf("Unexpected socket path %s", got)
+	}
+	if got := ForwardSocketPath("", 42); got != os.TempDir()+"/lighthouse-stats-42.sock" {
+		t.Errorf("Expected socket in the temp dir, got %s", got)
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	t.Parallel()
+
+	t.Run(
+		"RoundTrip", func(t *testing.T) {
+			t.Parallel()
+			path := ForwardSocketPath(t.TempDir(), 1)
+			_, buffer := newTestReceiver(t, path)
+			f := NewForwarder(path)
+			defer f.Close()
+
+			if err := f.InsertBatch(nil); err != nil {
+				t.Fatalf("Unexpected error for empty batch: %v", err)
+			}
+			if err := f.InsertBatch(newTestLogs("fetch", "resolve")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			if err := f.InsertBatch(newTestLogs("list")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			assertEndpoints(t, waitForEntries(t, buffer, 3), "fetch", "resolve", "list")
+		},
+	)
+
+	t.Run(
+		"ReceiverNotRunning", func(t *testing.T) {
+			t.Parallel()
+			f := NewForwarder(ForwardSocketPath(t.TempDir(), 1))
+			if err := f.InsertBatch(newTestLogs("fetch")); err == nil {
+				t.Error("Expected error without receiver")
+			}
+		},
+	)
+
+	t.Run(
+		"ParentRestart", func(t *testing.T) {
+			t.Parallel()
+			path := ForwardSocketPath(t.TempDir(), 1)
+			r, buffer := newTestReceiver(t, path)
+			f := NewForwarder(path)
+			defer f.Close()
+
+			if err := f.InsertBatch(newTestLogs("fetch")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			waitForEntries(t, buffer, 1)
+			if err := r.Close(); err != nil {
+				t.Fatalf("Failed to close receiver: %v", err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Expected socket to be removed, got %v", err)
+			}
+
+			// Entries forwarded while the parent is down are lost, but the
+			// forwarder recovers once it is back
+			if err := f.InsertBatch(newTestLogs("lost")); err == nil {
+				t.Error("Expected error while the receiver is down")
+			}
+			_, buffer = newTestReceiver(t, path)
+			if err := f.InsertBatch(newTestLogs("resolve")); err != nil {
+				t.Fatalf("Failed to forward after restart: %v", err)
+			}
+			assertEndpoints(t, waitForEntries(t, buffer, 1), "resolve")
+		},
+	)
+
+	t.Run(
+		"StaleConnection", func(t *testing.T) {
+			t.Parallel()
+			path := ForwardSocketPath(t.TempDir(), 1)
+			r, buffer := newTestReceiver(t, path)
+			f := NewForwarder(path)
+			defer f.Close()
+
+			if err := f.InsertBatch(newTestLogs("fetch")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			waitForEntries(t, buffer, 1)
+			if err := r.Close(); err != nil {
+				t.Fatalf("Failed to close receiver: %v", err)
+			}
+			_, buffer = newTestReceiver(t, path)
+
+			// The first batch after the restart is not lost on the stale
+			// connection
+			if err := f.InsertBatch(newTestLogs("resolve")); err != nil {
+				t.Fatalf("Failed to forward after restart: %v", err)
+			}
+			assertEndpoints(t, waitForEntries(t, buffer, 1), "resolve")
+		},
+	)
+}
+
+func TestReceiverClose(t *testing.T) {
+	t.Parallel()
+
+	t.Run(
+		"DrainsPendingEntries", func(t *testing.T) {
+			t.Parallel()
+			path := ForwardSocketPath(t.TempDir(), 1)
+			r, buffer := newTestReceiver(t, path)
+			f := NewForwarder(path)
+			defer f.Close()
+
+			if err := f.InsertBatch(newTestLogs("fetch")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			waitForEntries(t, buffer, 1)
+
+			// The connection stays open; the receiver reads the pending
+			// batches within the grace period
+			for range 10 {
+				if err := f.InsertBatch(newTestLogs("resolve", "list")); err != nil {
+					t.Fatalf("Failed to forward: %v", err)
+				}
+			}
+			start := time.Now()
+			if err := r.Close(); err != nil {
+				t.Fatalf("Failed to close receiver: %v", err)
+			}
+			if d := time.Since(start); d > receiverCloseGracePeriod+time.Second {
+				t.Errorf("Expected close to return after the grace period, took %v", d)
+			}
+			if got := buffer.Drain(); len(got) != 20 {
+				t.Errorf("Expected 20 pending entries, got %d", len(got))
+			}
+		},
+	)
+
+	t.Run(
+		"ClosedForwarder", func(t *testing.T) {
+			t.Parallel()
+			path := ForwardSocketPath(t.TempDir(), 1)
+			r, buffer := newTestReceiver(t, path)
+			f := NewForwarder(path)
+
+			if err := f.InsertBatch(newTestLogs("fetch")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			waitForEntries(t, buffer, 1)
+			if err := f.InsertBatch(newTestLogs("resolve")); err != nil {
+				t.Fatalf("Failed to forward: %v", err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatalf("Failed to close forwarder: %v", err)
+			}
+
+			// Closed connections do not delay closing the receiver
+			start := time.Now()
+			if err := r.Close(); err != nil {
+				t.Fatalf("Failed to close receiver: %v", err)
+			}
+			if d := time.Since(start); d >= receiverCloseGracePeriod {
+				t.Errorf("Expected close without waiting for the grace period, took %v", d)
+			}
+			assertEndpoints(t, logEndpoints(buffer.Drain()), "resolve")
+		},
+	)
+
+	t.Run(
+		"NotListening", func(t *testing.T) {
+			t.Parallel()
+			r := NewReceiver(ForwardSocketPath(t.TempDir(), 1), NewRingBuffer(10, 1))
+			if err := r.Close(); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		},
+	)
+}
+
+func TestReceiverListenRemovesStaleSocket(t *testing.T) {
+	t.Parallel()
+	path := ForwardSocketPath(t.TempDir(), 1)
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	newTestReceiver(t, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected socket with mode 0600, got %v", info.Mode())
+	}
+}
+
+// TestCollectorPrefork runs a parent and a child collector in the same
+// process; the child is determined by fiber from the environment, so the
+// test must not run in parallel.
+func TestCollectorPrefork(t *testing.T) {
+	dir := t.TempDir()
+	cfg := stats.Config{
+		Enabled:       true,
+		Prefork:       true,
+		SocketDir:     dir,
+		BufferSize:    100,
+		FlushInterval: time.Hour,
+	}
+
+	// The parent of a child is the process with the ppid; here the parent
+	// collector listens on the socket of this process, so the child forwards
+	// to a receiver emulating the parent at the ppid socket.
+	parentStorage := &recordingStorage{}
+	parent, err := NewCollector(cfg, parentStorage)
+	if err != nil {
+		t.Fatalf("Failed to create parent collector: %v", err)
+	}
+	parent.Start()
+	if _, err = os.Stat(ForwardSocketPath(dir, os.Getpid())); err != nil {
+		t.Fatalf("Expected parent to listen on its socket: %v", err)
+	}
+	_, ppidBuffer := newTestReceiver(t, ForwardSocketPath(dir, os.Getppid()))
+
+	t.Setenv("FIBER_PREFORK_CHILD", "1")
+	childStorage := &recordingStorage{}
+	child, err := NewCollector(cfg, childStorage)
+	if err != nil {
+		t.Fatalf("Failed to create child collector: %v", err)
+	}
+	if child.receiver != nil {
+		t.Error("Expected child not to start a receiver")
+	}
+	child.Start()
+	for _, entry := range newTestLogs("fetch", "resolve") {
+		child.Record(entry)
+	}
+	// Stopping the child forwards the remaining entries with the final flush
+	if err = child.Stop(); err != nil {
+		t.Fatalf("Failed to stop child: %v", err)
+	}
+	if got := childStorage.endpoints(); len(got) != 0 {
+		t.Errorf("Expected child not to write to storage, got %v", got)
+	}
+	assertEndpoints(t, waitForEntries(t, ppidBuffer, 2), "fetch", "resolve")
+
+	// Entries of children reach the parent's storage with its final flush
+	parent.Record(newTestLogs("trust_mark")[0])
+	f := NewForwarder(ForwardSocketPath(dir, os.Getpid()))
+	if err = f.InsertBatch(newTestLogs("list")); err != nil {
+		t.Fatalf("Failed to forward to parent: %v", err)
+	}
+	_ = f.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for parent.BufferStats().Size < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err = parent.Stop(); err != nil {
+		t.Fatalf("Failed to stop parent: %v", err)
+	}
+	assertEndpoints(t, parentStorage.endpoints(), "trust_mark", "list")
+	if _, err = os.Stat(ForwardSocketPath(dir, os.Getpid())); !os.IsNotExist(err) {
+		t.Errorf("Expected parent socket to be removed, got %v", err)
+	}
+}
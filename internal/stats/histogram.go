@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// latencyBucketBounds are the upper bounds (inclusive, in milliseconds) of
+// the buckets of a LatencyHistogram. The bounds grow by roughly 25%, so
+// percentiles estimated from the histogram are within about 12% of the exact
+// value. Durations above the last bound fall into an overflow bucket.
+var latencyBucketBounds = func() []int {
+	bounds := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for b := 10.0; b < 60000; {
+		b *= 1.25
+		bounds = append(bounds, int(math.Round(b)))
+	}
+	return bounds
+}()
+
+// LatencyHistogram counts request durations in fixed buckets. Unlike
+// percentiles, histograms can be merged, so latency percentiles for a range
+// can be estimated from the histograms of the hours in the range.
+type LatencyHistogram []int64
+
+// NewLatencyHistogram returns an empty histogram.
+func NewLatencyHistogram() LatencyHistogram {
+	return make(LatencyHistogram, len(latencyBucketBounds)+1)
+}
+
+// Add counts a duration in milliseconds n times, e.g. for a sampled request
+// that represents n requests.
+func (h LatencyHistogram) Add(durationMs int, n int64) {
+	i := sort.SearchInts(latencyBucketBounds, durationMs)
+	h[i] += n
+}
+
+// Merge adds the counts of other to h. Histograms of different length are
+// merged bucket by bucket as far as h reaches.
+func (h LatencyHistogram) Merge(other LatencyHistogram) {
+	for i := range other {
+		if i < len(h) {
+			h[i] += other[i]
+		}
+	}
+}
+
+// Count returns the number of counted durations.
+func (h LatencyHistogram) Count() int64 {
+	var n int64
+	for _, c := range h {
+		n += c
+	}
+	return n
+}
+
+// Percentile estimates the p-th percentile by linear interpolation within the
+// bucket that contains it. minMs and maxMs are the smallest and largest
+// counted durations; they bound the estimate of the first and last bucket.
+func (h LatencyHistogram) Percentile(p, minMs, maxMs int) int {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(float64(p) / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h {
+		if c == 0 || seen+c < rank {
+			seen += c
+			continue
+		}
+		lower, upper := minMs, maxMs
+		if i > 0 && i-1 < len(latencyBucketBounds) {
+			lower = max(lower, latencyBucketBounds[i-1]+1)
+		}
+		if i < len(latencyBucketBounds) {
+			upper = min(upper, latencyBucketBounds[i])
+		}
+		if upper <= lower {
+			return max(lower, upper)
+		}
+		fraction := float64(rank-seen) / float64(c)
+		return lower + int(math.Round(fraction*float64(upper-lower)))
+	}
+	return maxMs
+}
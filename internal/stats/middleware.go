@@ -1,6 +1,7 @@
 package stats
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"hash/fnv"
 	"strings"
@@ -20,9 +21,19 @@ type MiddlewareConfig struct {
 	// CaptureQueryParams enables capturing URL query parameters.
 	CaptureQueryParams bool
 
+	// CaptureSubject enables capturing the requested subject and trust mark
+	// type from the sub and trust_mark_type parameters.
+	CaptureSubject bool
+
 	// GeoIP provides country lookup from IP addresses. Can be nil.
 	GeoIP GeoIPProvider
 
+	// Anonymizer pseudonymises the captured client data. Can be nil.
+	Anonymizer *Anonymizer
+
+	// Sampler decides which requests are recorded. Can be nil.
+	Sampler *Sampler
+
 	// TrackedEndpoints is a map of endpoints to track. If empty, all requests are tracked.
 	// Keys should be normalized endpoint names (e.g., "well-known", "fetch", "resolve").
 	TrackedEndpoints map[string]bool
@@ -44,6 +55,10 @@ func Middleware(cfg MiddlewareConfig) fiber.Handler {
 		if !shouldTrack(endpointName, cfg.TrackedEndpoints) {
 			return c.Next()
 		}
+		sampleRate, record := cfg.Sampler.Sample(endpointName)
+		if !record {
+			return c.Next()
+		}
 
 		// Capture start time
 		start := time.Now()
@@ -59,6 +74,7 @@ func Middleware(cfg MiddlewareConfig) fiber.Handler {
 			Method:       c.Method(),
 			StatusCode:   c.Response().StatusCode(),
 			DurationMs:   int(duration.Milliseconds()),
+			SampleRate:   sampleRate,
 			ResponseSize: len(c.Response().Body()),
 			RequestSize:  len(c.Request().Body()),
 		}
@@ -71,6 +87,8 @@ func Middleware(cfg MiddlewareConfig) fiber.Handler {
 			if cfg.GeoIP != nil && entry.ClientIP != "" {
 				entry.CountryCode = cfg.GeoIP.LookupCountry(entry.ClientIP)
 			}
+			// Anonymise after the lookup, so the country stays exact
+			entry.ClientIP = cfg.Anonymizer.ClientIP(entry.ClientIP, start)
 		}
 
 		// Capture User-Agent
@@ -84,6 +102,7 @@ func Middleware(cfg MiddlewareConfig) fiber.Handler {
 		// Capture query parameters
 		if cfg.CaptureQueryParams {
 			params := captureQueryParams(c)
+			cfg.Anonymizer.QueryParams(params)
 			if len(params) > 0 {
 				if jsonBytes, err := json.Marshal(params); err == nil {
 					entry.QueryParams = jsonBytes
@@ -91,6 +110,11 @@ func Middleware(cfg MiddlewareConfig) fiber.Handler {
 			}
 		}
 
+		// Capture requested subject
+		if cfg.CaptureSubject {
+			entry.Subject, entry.TrustMarkType = captureSubject(c)
+		}
+
 		// Capture error type if present
 		if err != nil {
 			entry.ErrorType = categorizeError(err, entry.StatusCode)
@@ -136,6 +160,46 @@ func captureQueryParams(c *fiber.Ctx) map[string]string {
 	return params
 }
 
+// captureSubject extracts the requested subject and trust mark type from the
+// sub and trust_mark_type query or form parameters. For trust mark status
+// requests they are taken from the (unverified) trust_mark JWT.
+func captureSubject(c *fiber.Ctx) (subject, trustMarkType string) {
+	subject = strings.Clone(c.FormValue("sub"))
+	trustMarkType = strings.Clone(c.FormValue("trust_mark_type"))
+	if subject != "" {
+		return subject, trustMarkType
+	}
+	if tm := c.FormValue("trust_mark"); tm != "" {
+		claims := jwtSubjectClaims(tm)
+		subject = claims.Subject
+		if trustMarkType == "" {
+			trustMarkType = claims.TrustMarkType
+		}
+	}
+	return subject, trustMarkType
+}
+
+// subjectClaims are the claims of a trust mark that identify its subject.
+type subjectClaims struct {
+	Subject       string `json:"sub"`
+	TrustMarkType string `json:"trust_mark_type"`
+}
+
+// jwtSubjectClaims decodes the subject claims from the payload of a JWT
+// without verifying it; the values are only used for statistics.
+func jwtSubjectClaims(token string) (claims subjectClaims) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(payload, &claims)
+	return
+}
+
 // hashString computes a FNV-1a hash of a string for efficient grouping.
 func hashString(s string) uint32 {
 	h := fnv.New32a()
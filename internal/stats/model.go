@@ -19,12 +19,21 @@ type RequestLog struct {
 	StatusCode int    `gorm:"type:smallint;not null" json:"status_code"`
 	DurationMs int    `gorm:"not null" json:"duration_ms"`
 
+	// SampleRate is the number of requests this entry represents: with
+	// sampling only one in SampleRate requests is recorded. Counts are
+	// extrapolated by summing the sample rates.
+	SampleRate int `gorm:"not null;default:1" json:"sample_rate"`
+
 	// Client info
 	ClientIP      string `gorm:"size:45;index:idx_rl_ip" json:"client_ip,omitempty"`
 	CountryCode   string `gorm:"size:2" json:"country_code,omitempty"`
 	UserAgent     string `gorm:"type:text" json:"user_agent,omitempty"`
 	UserAgentHash uint32 `gorm:"index:idx_rl_ua_hash" json:"user_agent_hash,omitempty"`
 
+	// Requested entity, taken from the sub and trust_mark_type parameters
+	Subject       string `gorm:"size:255;index:idx_rl_subject" json:"subject,omitempty"`
+	TrustMarkType string `gorm:"size:255;index:idx_rl_tm_type" json:"trust_mark_type,omitempty"`
+
 	// Request details
 	QueryParams  json.RawMessage `gorm:"type:json" json:"query_params,omitempty"`
 	RequestSize  int             `gorm:"default:0" json:"request_size"`
@@ -39,6 +48,34 @@ func (RequestLog) TableName() string {
 	return "federation_request_logs"
 }
 
+// HourlyStats represents aggregated statistics for a single hour. It is the
+// tier between the detailed logs and the daily stats, used for time series
+// and latency percentiles over longer ranges.
+type HourlyStats struct {
+	ID uint `gorm:"primaryKey;autoIncrement" json:"id"`
+
+	// Primary key fields for grouping
+	Hour       time.Time `gorm:"uniqueIndex:idx_hs_hour_endpoint_status;not null" json:"hour"`
+	Endpoint   string    `gorm:"size:100;uniqueIndex:idx_hs_hour_endpoint_status;not null" json:"endpoint"`
+	StatusCode int       `gorm:"type:smallint;uniqueIndex:idx_hs_hour_endpoint_status;not null" json:"status_code"`
+
+	// Counts
+	RequestCount int64 `gorm:"not null;default:0" json:"request_count"`
+	ErrorCount   int64 `gorm:"not null;default:0" json:"error_count"`
+
+	// Duration statistics (in milliseconds). The sum and the histogram can be
+	// merged across hours, unlike averages and percentiles.
+	DurationSumMs    int64           `gorm:"not null;default:0" json:"duration_sum_ms"`
+	DurationMinMs    int             `gorm:"default:0" json:"duration_min_ms"`
+	DurationMaxMs    int             `gorm:"default:0" json:"duration_max_ms"`
+	LatencyHistogram json.RawMessage `gorm:"type:json" json:"latency_histogram,omitempty"`
+}
+
+// TableName returns the table name for HourlyStats.
+func (HourlyStats) TableName() string {
+	return "federation_hourly_stats"
+}
+
 // DailyStats represents aggregated statistics for a single day.
 // This is used for long-term retention with smaller storage footprint.
 type DailyStats struct {
@@ -96,6 +133,22 @@ type Summary struct {
 	RequestsByEndpoint map[string]int64 `json:"requests_by_endpoint"`
 }
 
+// EndpointStats holds the request and error counts of a single endpoint.
+type EndpointStats struct {
+	Endpoint     string `json:"endpoint"`
+	RequestCount int64  `json:"request_count"`
+	ErrorCount   int64  `json:"error_count"`
+}
+
+// SubjectStats holds the request statistics of a single requested subject.
+type SubjectStats struct {
+	Subject       string    `json:"subject"`
+	TrustMarkType string    `json:"trust_mark_type,omitempty"`
+	RequestCount  int64     `json:"request_count"`
+	ErrorCount    int64     `json:"error_count"`
+	LastRequested time.Time `json:"last_requested"`
+}
+
 // TopEntry represents a single entry in a top-N list.
 type TopEntry struct {
 	Value string `json:"value"`
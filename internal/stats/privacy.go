@@ -0,0 +1,163 @@
+package stats
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-oidfed/lighthouse/api/stats"
+)
+
+// Anonymizer pseudonymises the client data of request logs according to the
+// privacy configuration. A nil Anonymizer keeps all data.
+type Anonymizer struct {
+	mode         string
+	ipv4Mask     net.IPMask
+	ipv6Mask     net.IPMask
+	key          []byte
+	saltRotation time.Duration
+	dropParams   map[string]bool
+
+	// salts holds the random salts per rotation period if no key is
+	// configured
+	saltsMu sync.Mutex
+	salts   map[int64][]byte
+}
+
+// NewAnonymizer creates a new Anonymizer from the privacy configuration. It
+// returns nil if the configuration keeps all data.
+func NewAnonymizer(cfg stats.PrivacyConfig) *Anonymizer {
+	mode := cfg.ClientIPMode
+	if mode == "" {
+		mode = stats.ClientIPModeFull
+	}
+	if mode == stats.ClientIPModeFull && len(cfg.DropQueryParams) == 0 {
+		return nil
+	}
+	ipv4Prefix, ipv6Prefix := cfg.IPv4Prefix, cfg.IPv6Prefix
+	if ipv4Prefix <= 0 || ipv4Prefix > 32 {
+		ipv4Prefix = 24
+	}
+	if ipv6Prefix <= 0 || ipv6Prefix > 128 {
+		ipv6Prefix = 48
+	}
+	saltRotation := cfg.SaltRotation
+	if saltRotation <= 0 {
+		saltRotation = 24 * time.Hour
+	}
+	dropParams := make(map[string]bool, len(cfg.DropQueryParams))
+	for _, p := range cfg.DropQueryParams {
+		dropParams[p] = true
+	}
+	a := &Anonymizer{
+		mode:         mode,
+		ipv4Mask:     net.CIDRMask(ipv4Prefix, 32),
+		ipv6Mask:     net.CIDRMask(ipv6Prefix, 128),
+		saltRotation: saltRotation,
+		dropParams:   dropParams,
+		salts:        make(map[int64][]byte),
+	}
+	if cfg.HashKey != "" {
+		a.key = []byte(cfg.HashKey)
+	}
+	return a
+}
+
+// ClientIP returns the client IP as it should be stored for a request at the
+// given time. Values that are not an IP, e.g. IPs that were already hashed,
+// are returned unchanged.
+func (a *Anonymizer) ClientIP(ip string, at time.Time) string {
+	if a == nil || ip == "" {
+		return ip
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	switch a.mode {
+	case stats.ClientIPModeTruncate:
+		if v4 := parsed.To4(); v4 != nil {
+			return v4.Mask(a.ipv4Mask).String()
+		}
+		return parsed.Mask(a.ipv6Mask).String()
+	case stats.ClientIPModeHash:
+		mac := hmac.New(sha256.New, a.salt(at))
+		_, _ = mac.Write([]byte(parsed.String()))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	default:
+		return ip
+	}
+}
+
+// salt returns the salt of the rotation period that contains at.
+func (a *Anonymizer) salt(at time.Time) []byte {
+	period := at.UnixNano() / int64(a.saltRotation)
+	if a.key != nil {
+		mac := hmac.New(sha256.New, a.key)
+		_, _ = mac.Write([]byte("lighthouse-stats-salt:" + strconv.FormatInt(period, 10)))
+		return mac.Sum(nil)
+	}
+
+	a.saltsMu.Lock()
+	defer a.saltsMu.Unlock()
+	if salt, ok := a.salts[period]; ok {
+		return salt
+	}
+	salt := make([]byte, 32)
+	_, _ = rand.Read(salt)
+	// Salts of past periods are forgotten, so their hashes cannot be
+	// linked to new requests
+	for p := range a.salts {
+		if p < period-1 {
+			delete(a.salts, p)
+		}
+	}
+	a.salts[period] = salt
+	return salt
+}
+
+// QueryParams removes the dropped query parameters from params.
+func (a *Anonymizer) QueryParams(params map[string]string) {
+	if a == nil {
+		return
+	}
+	for p := range params {
+		if a.dropParams[p] {
+			delete(params, p)
+		}
+	}
+}
+
+// Apply pseudonymises the client data of an already stored request log. It
+// returns true if the entry was changed.
+func (a *Anonymizer) Apply(entry *RequestLog) bool {
+	if a == nil {
+		return false
+	}
+	changed := false
+	if ip := a.ClientIP(entry.ClientIP, entry.Timestamp); ip != entry.ClientIP {
+		entry.ClientIP = ip
+		changed = true
+	}
+	if len(a.dropParams) > 0 && len(entry.QueryParams) > 0 {
+		var params map[string]string
+		if err := json.Unmarshal(entry.QueryParams, &params); err == nil {
+			n := len(params)
+			a.QueryParams(params)
+			if len(params) != n {
+				entry.QueryParams = nil
+				if len(params) > 0 {
+					entry.QueryParams, _ = json.Marshal(params)
+				}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,206 @@
+package stats
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-oidfed/lighthouse/api/stats"
+)
+
+func TestNewAnonymizer(t *testing.T) {
+	t.Parallel()
+	if a := NewAnonymizer(stats.PrivacyConfig{}); a != nil {
+		t.Errorf("Expected no anonymizer for the default configuration, got %+v", a)
+	}
+	if a := NewAnonymizer(stats.PrivacyConfig{ClientIPMode: stats.ClientIPModeFull}); a != nil {
+		t.Errorf("Expected no anonymizer for full client IPs, got %+v", a)
+	}
+	if a := NewAnonymizer(stats.PrivacyConfig{DropQueryParams: []string{"code"}}); a == nil {
+		t.Error("Expected an anonymizer for dropped query parameters")
+	}
+
+	// A nil anonymizer keeps all data
+	var a *Anonymizer
+	if ip := a.ClientIP("192.0.2.17", time.Now()); ip != "192.0.2.17" {
+		t.Errorf("Expected IP to be kept, got %s", ip)
+	}
+	entry := &RequestLog{ClientIP: "192.0.2.17", QueryParams: json.RawMessage(`{"code":"x"}`)}
+	if a.Apply(entry) || entry.ClientIP != "192.0.2.17" || string(entry.QueryParams) != `{"code":"x"}` {
+		t.Errorf("Expected entry to be unchanged, got %+v", entry)
+	}
+}
+
+func TestAnonymizerTruncate(t *testing.T) {
+	t.Parallel()
+	for name, tc := range map[string]struct {
+		cfg  stats.PrivacyConfig
+		ip   string
+		want string
+	}{
+		"IPv4Default":        {ip: "192.0.2.17", want: "192.0.2.0"},
+		"IPv6Default":        {ip: "2001:db8:1234:5678::1", want: "2001:db8:1234::"},
+		"IPv4Prefix":         {cfg: stats.PrivacyConfig{IPv4Prefix: 16}, ip: "192.0.2.17", want: "192.0.0.0"},
+		"IPv6Prefix":         {cfg: stats.PrivacyConfig{IPv6Prefix: 32}, ip: "2001:db8:1234::1", want: "2001:db8::"},
+		"IPv4MappedIPv6":     {ip: "::ffff:192.0.2.17", want: "192.0.2.0"},
+		"InvalidPrefixes":    {cfg: stats.PrivacyConfig{IPv4Prefix: 33, IPv6Prefix: -1}, ip: "192.0.2.17", want: "192.0.2.0"},
+		"NotAnIP":            {ip: "0123456789abcdef", want: "0123456789abcdef"},
+		"Empty":              {ip: "", want: ""},
+		"AlreadyTruncatedV4": {ip: "192.0.2.0", want: "192.0.2.0"},
+	} {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+				cfg := tc.cfg
+				cfg.ClientIPMode = stats.ClientIPModeTruncate
+				if got := NewAnonymizer(cfg).ClientIP(tc.ip, time.Now()); got != tc.want {
+					t.Errorf("Expected %q, got %q", tc.want, got)
+				}
+			},
+		)
+	}
+}
+
+func TestAnonymizerHash(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run(
+		"HashKey", func(t *testing.T) {
+			t.Parallel()
+			cfg := stats.PrivacyConfig{ClientIPMode: stats.ClientIPModeHash, HashKey: "secret", SaltRotation: time.Hour}
+			a := NewAnonymizer(cfg)
+			hash := a.ClientIP("192.0.2.17", at)
+			if net.ParseIP(hash) != nil || len(hash) != 32 {
+				t.Fatalf("Expected a 32 character hex hash, got %q", hash)
+			}
+			if got := a.ClientIP("192.0.2.17", at.Add(59*time.Minute)); got != hash {
+				t.Error("Expected the same hash within a rotation period")
+			}
+			if got := a.ClientIP("192.0.2.18", at); got == hash {
+				t.Error("Expected different IPs to have different hashes")
+			}
+			if got := a.ClientIP("192.0.2.17", at.Add(time.Hour)); got == hash {
+				t.Error("Expected the hash to change after the salt rotation")
+			}
+			// Different processes, e.g. prefork children or lhcli, hash
+			// equally with the same key
+			if got := NewAnonymizer(cfg).ClientIP("192.0.2.17", at); got != hash {
+				t.Error("Expected another anonymizer with the same key to hash equally")
+			}
+			cfg.HashKey = "other"
+			if got := NewAnonymizer(cfg).ClientIP("192.0.2.17", at); got == hash {
+				t.Error("Expected a different key to result in a different hash")
+			}
+			// Hashed IPs are not hashed again
+			if got := a.ClientIP(hash, at); got != hash {
+				t.Errorf("Expected hash to be kept, got %q", got)
+			}
+		},
+	)
+
+	t.Run(
+		"RandomSalts", func(t *testing.T) {
+			t.Parallel()
+			cfg := stats.PrivacyConfig{ClientIPMode: stats.ClientIPModeHash, SaltRotation: time.Hour}
+			a := NewAnonymizer(cfg)
+			hash := a.ClientIP("192.0.2.17", at)
+			if got := a.ClientIP("192.0.2.17", at.Add(30*time.Minute)); got != hash {
+				t.Error("Expected the same hash within a rotation period")
+			}
+			if got := NewAnonymizer(cfg).ClientIP("192.0.2.17", at); got == hash {
+				t.Error("Expected another anonymizer without key to use different salts")
+			}
+
+			// Only the salts of the current and the previous period are kept
+			for i := range 5 {
+				a.ClientIP("192.0.2.17", at.Add(time.Duration(i)*time.Hour))
+			}
+			if len(a.salts) != 2 {
+				t.Errorf("Expected 2 salts to be kept, got %d", len(a.salts))
+			}
+			if got := a.ClientIP("192.0.2.17", at); got == hash {
+				t.Error("Expected the forgotten salt not to be restored")
+			}
+		},
+	)
+
+	t.Run(
+		"DefaultRotation", func(t *testing.T) {
+			t.Parallel()
+			a := NewAnonymizer(stats.PrivacyConfig{ClientIPMode: stats.ClientIPModeHash, HashKey: "secret"})
+			if a.saltRotation != 24*time.Hour {
+				t.Errorf("Expected a default rotation of 24h, got %v", a.saltRotation)
+			}
+		},
+	)
+}
+
+func TestAnonymizerDropQueryParams(t *testing.T) {
+	t.Parallel()
+	a := NewAnonymizer(stats.PrivacyConfig{DropQueryParams: []string{"code", "state"}})
+
+	params := map[string]string{"sub": "https://rp.example.org", "code": "x", "state": "y"}
+	a.QueryParams(params)
+	if len(params) != 1 || params["sub"] != "https://rp.example.org" {
+		t.Errorf("Expected only sub to be kept, got %v", params)
+	}
+
+	entry := &RequestLog{ClientIP: "192.0.2.17", QueryParams: json.RawMessage(`{"sub":"a","code":"x"}`)}
+	if !a.Apply(entry) {
+		t.Error("Expected entry to be changed")
+	}
+	if string(entry.QueryParams) != `{"sub":"a"}` {
+		t.Errorf("Expected code to be dropped, got %s", entry.QueryParams)
+	}
+	if entry.ClientIP != "192.0.2.17" {
+		t.Errorf("Expected full client IP to be kept, got %s", entry.ClientIP)
+	}
+
+	entry = &RequestLog{QueryParams: json.RawMessage(`{"code":"x"}`)}
+	if !a.Apply(entry) || entry.QueryParams != nil {
+		t.Errorf("Expected query params to be removed, got %s", entry.QueryParams)
+	}
+
+	entry = &RequestLog{QueryParams: json.RawMessage(`{"sub":"a"}`)}
+	if a.Apply(entry) || string(entry.QueryParams) != `{"sub":"a"}` {
+		t.Errorf("Expected entry without dropped params to be unchanged, got %s", entry.QueryParams)
+	}
+}
+
+func TestAnonymizerApply(t *testing.T) {
+	t.Parallel()
+	a := NewAnonymizer(stats.PrivacyConfig{ClientIPMode: stats.ClientIPModeTruncate})
+	entry := &RequestLog{ClientIP: "192.0.2.17", Timestamp: time.Now()}
+	if !a.Apply(entry) || entry.ClientIP != "192.0.2.0" {
+		t.Errorf("Expected client IP to be truncated, got %s", entry.ClientIP)
+	}
+	if a.Apply(entry) {
+		t.Error("Expected already truncated entry to be unchanged")
+	}
+}
+
+func TestNewCollectorPreforkHashKey(t *testing.T) {
+	t.Parallel()
+	cfg := stats.Config{
+		Enabled:   true,
+		Prefork:   true,
+		SocketDir: t.TempDir(),
+		Privacy:   stats.PrivacyConfig{ClientIPMode: stats.ClientIPModeHash},
+	}
+	if _, err := NewCollector(cfg, &recordingStorage{}); err == nil {
+		t.Error("Expected error for hashing without hash key in prefork mode")
+	}
+
+	cfg.Privacy.HashKey = "secret"
+	if c, err := NewCollector(cfg, &recordingStorage{}); err != nil || c == nil {
+		t.Errorf("Expected collector with hash key, got %v", err)
+	}
+
+	cfg.Privacy.HashKey = ""
+	cfg.Prefork = false
+	if c, err := NewCollector(cfg, &recordingStorage{}); err != nil || c == nil {
+		t.Errorf("Expected collector without prefork, got %v", err)
+	}
+}
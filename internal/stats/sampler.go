@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/go-oidfed/lighthouse/api/stats"
+)
+
+// Sampler decides which tracked requests are recorded. Rates are handled as
+// "one in n": a recorded request represents n requests, and n is stored as
+// the sample rate of the entry, so counts can be extrapolated.
+// A nil Sampler records all requests.
+type Sampler struct {
+	every         int
+	endpointEvery map[string]int
+
+	// factor multiplies the configured n in adaptive mode; maxFactor is the
+	// n of the minimum rate
+	factor    atomic.Int64
+	maxFactor int64
+
+	adaptive stats.AdaptiveSamplingConfig
+	fillMu   sync.Mutex
+	fillSum  float64
+	fillObs  int
+}
+
+// NewSampler creates a new Sampler from the sampling configuration. It
+// returns nil if all requests are recorded.
+func NewSampler(cfg stats.SamplingConfig) *Sampler {
+	every := sampleEvery(cfg.Rate)
+	endpointEvery := make(map[string]int, len(cfg.EndpointRates))
+	for endpoint, rate := range cfg.EndpointRates {
+		endpointEvery[normalizeEndpoint(endpoint)] = sampleEvery(rate)
+	}
+	if every == 1 && len(endpointEvery) == 0 && !cfg.Adaptive.Enabled {
+		return nil
+	}
+	s := &Sampler{
+		every:         every,
+		endpointEvery: endpointEvery,
+		adaptive:      cfg.Adaptive,
+		maxFactor:     1,
+	}
+	s.factor.Store(1)
+	if cfg.Adaptive.Enabled {
+		if s.adaptive.Window <= 0 {
+			s.adaptive.Window = time.Minute
+		}
+		if s.adaptive.HighFill <= 0 || s.adaptive.HighFill > 1 {
+			s.adaptive.HighFill = 0.5
+		}
+		if s.adaptive.LowFill <= 0 || s.adaptive.LowFill >= s.adaptive.HighFill {
+			s.adaptive.LowFill = s.adaptive.HighFill / 4
+		}
+		s.maxFactor = int64(sampleEvery(s.adaptive.MinRate))
+		if s.maxFactor == 1 {
+			s.maxFactor = 100
+		}
+	}
+	return s
+}
+
+// sampleEvery converts a rate to "one in n".
+func sampleEvery(rate float64) int {
+	if rate <= 0 || rate >= 1 {
+		return 1
+	}
+	return int(math.Round(1 / rate))
+}
+
+// Sample decides if a request to endpoint is recorded. It returns the sample
+// rate to store with the entry, i.e. the number of requests the entry
+// represents.
+func (s *Sampler) Sample(endpoint string) (sampleRate int, record bool) {
+	if s == nil {
+		return 1, true
+	}
+	every := s.every
+	if e, ok := s.endpointEvery[endpoint]; ok {
+		every = e
+	}
+	// The adaptive sampling lowers the rate down to the minimum rate, but
+	// never below the configured rate
+	if factor := int(s.factor.Load()); factor > 1 {
+		every = max(every, min(every*factor, int(s.maxFactor)))
+	}
+	if every <= 1 {
+		return 1, true
+	}
+	return every, rand.IntN(every) == 0
+}
+
+// Factor returns the current factor by which the adaptive sampling divides
+// the configured rates.
+func (s *Sampler) Factor() int {
+	if s == nil {
+		return 1
+	}
+	return int(s.factor.Load())
+}
+
+// RunAdaptive observes the fill level of buffer and adapts the sample rates
+// at the end of each window. It blocks until the context is cancelled.
+func (s *Sampler) RunAdaptive(ctx context.Context, buffer *RingBuffer) {
+	if s == nil || !s.adaptive.Enabled {
+		return
+	}
+	observe := time.NewTicker(time.Second)
+	defer observe.Stop()
+	window := time.NewTicker(s.adaptive.Window)
+	defer window.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-observe.C:
+			s.observe(buffer.FillPercentage())
+		case <-window.C:
+			s.adapt()
+		}
+	}
+}
+
+func (s *Sampler) observe(fill float64) {
+	s.fillMu.Lock()
+	defer s.fillMu.Unlock()
+	s.fillSum += fill
+	s.fillObs++
+}
+
+// adapt halves the rates if the average fill level of the last window was
+// high, and doubles them again if it was low.
+func (s *Sampler) adapt() {
+	s.fillMu.Lock()
+	if s.fillObs == 0 {
+		s.fillMu.Unlock()
+		return
+	}
+	fill := s.fillSum / float64(s.fillObs)
+	s.fillSum, s.fillObs = 0, 0
+	s.fillMu.Unlock()
+
+	factor := s.factor.Load()
+	switch {
+	case fill >= s.adaptive.HighFill && factor < s.maxFactor:
+		factor = min(factor*2, s.maxFactor)
+	case fill <= s.adaptive.LowFill && factor > 1:
+		factor /= 2
+	default:
+		return
+	}
+	s.factor.Store(factor)
+	log.WithFields(
+		log.Fields{
+			"buffer_fill": fill,
+			"factor":      factor,
+		},
+	).Info("stats sampling adapted to load")
+}
@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TailFilter selects the request logs that are streamed to a tail
+// subscriber. Empty fields match all requests.
+type TailFilter struct {
+	// Endpoint is the endpoint path, e.g. "fetch" or "/fetch".
+	Endpoint string
+	// StatusClass is the first digit of the status code, e.g. 5 for 5xx.
+	StatusClass int
+	// ClientIP is the client IP as it is stored.
+	ClientIP string
+	// Subject is the requested subject.
+	Subject string
+}
+
+// ParseStatusClass parses a status class given as "5xx" or "5".
+func ParseStatusClass(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	class, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(s), "xx"))
+	if err != nil || class < 1 || class > 5 {
+		return 0, errors.Errorf("invalid status class '%s', use 1xx to 5xx", s)
+	}
+	return class, nil
+}
+
+// Match reports whether the entry is selected by the filter.
+func (f TailFilter) Match(entry *RequestLog) bool {
+	if f.Endpoint != "" && normalizeEndpoint(f.Endpoint) != entry.Endpoint {
+		return false
+	}
+	if f.StatusClass != 0 && entry.StatusCode/100 != f.StatusClass {
+		return false
+	}
+	if f.ClientIP != "" && f.ClientIP != entry.ClientIP {
+		return false
+	}
+	if f.Subject != "" && f.Subject != entry.Subject {
+		return false
+	}
+	return true
+}
+
+// Tail streams recorded request logs to subscribers, e.g. for a live view
+// of the incoming requests. Publishing never blocks; subscribers that do not
+// keep up miss entries.
+type Tail struct {
+	mu          sync.RWMutex
+	subscribers map[*tailSubscriber]struct{}
+	closed      bool
+}
+
+type tailSubscriber struct {
+	filter  TailFilter
+	entries chan *RequestLog
+}
+
+// NewTail creates a new Tail.
+func NewTail() *Tail {
+	return &Tail{
+		subscribers: make(map[*tailSubscriber]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives copies of the published entries
+// matching the filter, and a function to end the subscription. The channel
+// buffers up to size entries and is closed when the subscription ends or
+// the Tail is closed.
+func (t *Tail) Subscribe(filter TailFilter, size int) (<-chan *RequestLog, func()) {
+	if size <= 0 {
+		size = 100
+	}
+	sub := &tailSubscriber{
+		filter:  filter,
+		entries: make(chan *RequestLog, size),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		close(sub.entries)
+		return sub.entries, func() {}
+	}
+	t.subscribers[sub] = struct{}{}
+
+	return sub.entries, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subscribers[sub]; ok {
+			delete(t.subscribers, sub)
+			close(sub.entries)
+		}
+	}
+}
+
+// Publish passes the entry to all subscribers whose filter matches it.
+func (t *Tail) Publish(entry *RequestLog) {
+	if t == nil {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.subscribers) == 0 {
+		return
+	}
+
+	// The entry is still written to the database, which sets its ID, so
+	// subscribers get a copy
+	var published *RequestLog
+	for sub := range t.subscribers {
+		if !sub.filter.Match(entry) {
+			continue
+		}
+		if published == nil {
+			e := *entry
+			published = &e
+		}
+		select {
+		case sub.entries <- published:
+		default:
+			// Subscriber does not keep up, drop the entry
+		}
+	}
+}
+
+// Close ends all subscriptions; later subscriptions are closed immediately.
+func (t *Tail) Close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for sub := range t.subscribers {
+		close(sub.entries)
+	}
+	t.subscribers = make(map[*tailSubscriber]struct{})
+}
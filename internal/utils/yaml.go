@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSONToBlockYAML marshals v to YAML via its JSON representation, so that the
+// JSON field names are kept and numbers are not reformatted. All nodes are
+// printed in block style.
+func JSONToBlockYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err = yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearYAMLStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// clearYAMLStyle resets the flow style of nodes parsed from JSON, so that they
+// are printed in block style.
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		clearYAMLStyle(n)
+	}
+}
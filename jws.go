@@ -1,20 +1,24 @@
 package lighthouse
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/go-oidfed/lib/jwx/keymanagement/kms"
 	"github.com/go-oidfed/lib/jwx/keymanagement/public"
-	"github.com/lestrrat-go/jwx/v3/jwa"
 	"github.com/pkg/errors"
 
 	"github.com/go-oidfed/lighthouse/api/adminapi"
 	"github.com/go-oidfed/lighthouse/storage"
 	"github.com/go-oidfed/lighthouse/storage/model"
+	"github.com/go-oidfed/lighthouse/transitkms"
 )
 
 // SigningConf holds signing configuration.
 //
 // Environment variables (with prefix LH_SIGNING_):
-//   - LH_SIGNING_KMS: Key management system ("filesystem", "pkcs11", or "db")
+//   - LH_SIGNING_KMS: Key management system ("filesystem", "pkcs11", "db", or "transit")
 //   - LH_SIGNING_PK_BACKEND: Public key storage backend ("filesystem" or "db")
 //   - LH_SIGNING_AUTO_GENERATE_KEYS: Auto-generate keys if missing (bool)
 //   - LH_SIGNING_FILESYSTEM_KEY_FILE: Path to single key file
@@ -30,6 +34,17 @@ import (
 //   - LH_SIGNING_PKCS11_NO_LOGIN: Token doesn't support login (bool)
 //   - LH_SIGNING_PKCS11_LABEL_PREFIX: Prefix for object labels
 //   - LH_SIGNING_PKCS11_LOAD_LABELS: Extra labels to load (comma-separated)
+//   - LH_SIGNING_SEPARATE_KEY_SETS: Key sets with separate keys (comma-separated)
+//   - LH_SIGNING_DB_ENCRYPTION_*: Encryption of private keys at rest (see PEMEncryptionConf)
+//   - LH_SIGNING_TRANSIT_ADDRESS: Base URL of the transit signing service
+//   - LH_SIGNING_TRANSIT_MOUNT: Mount path of the transit engine
+//   - LH_SIGNING_TRANSIT_KEY_PREFIX: Prefix of the transit key names
+//   - LH_SIGNING_TRANSIT_TOKEN: Token for the signing service
+//   - LH_SIGNING_TRANSIT_TOKEN_FILE: File containing the token
+//   - LH_SIGNING_TRANSIT_NAMESPACE: Namespace of the signing service
+//   - LH_SIGNING_TRANSIT_CA_CERT: CA certificates to verify the signing service
+//   - LH_SIGNING_TRANSIT_CLIENT_CERT: Client certificate for mTLS
+//   - LH_SIGNING_TRANSIT_CLIENT_KEY: Client key for mTLS
 type SigningConf struct {
 	// KMS specifies the key management system to use.
 	// Env: LH_SIGNING_KMS
@@ -40,6 +55,10 @@ type SigningConf struct {
 	// AutoGenerateKeys enables automatic key generation if keys are missing.
 	// Env: LH_SIGNING_AUTO_GENERATE_KEYS
 	AutoGenerateKeys bool `yaml:"auto_generate_keys" envconfig:"AUTO_GENERATE_KEYS"`
+	// SeparateKeySets lists the key sets (KeySetTrustMarks, KeySetResolve)
+	// that use their own keys instead of the federation keys.
+	// Env: LH_SIGNING_SEPARATE_KEY_SETS (comma-separated)
+	SeparateKeySets []string `yaml:"separate_key_sets" envconfig:"SEPARATE_KEY_SETS"`
 	// FileSystemBackend holds filesystem-based key storage configuration.
 	// Env prefix: LH_SIGNING_FILESYSTEM_
 	FileSystemBackend struct {
@@ -95,37 +114,119 @@ type SigningConf struct {
 		// Env: LH_SIGNING_PKCS11_LOAD_LABELS (comma-separated)
 		ExtraLabels []string `yaml:"load_labels" envconfig:"LOAD_LABELS"`
 	} `yaml:"pkcs11" envconfig:"PKCS11"`
+	// DBBackend holds configuration for the database KMS.
+	// Env prefix: LH_SIGNING_DB_
+	DBBackend struct {
+		// Encryption configures the encryption of stored private keys.
+		// Env prefix: LH_SIGNING_DB_ENCRYPTION_
+		Encryption PEMEncryptionConf `yaml:"encryption" envconfig:"ENCRYPTION"`
+	} `yaml:"db" envconfig:"DB"`
+	// TransitBackend holds configuration for a remote signing service with
+	// an API compatible with the Vault transit engine.
+	// Env prefix: LH_SIGNING_TRANSIT_
+	TransitBackend struct {
+		// Address is the base URL of the signing service.
+		// Env: LH_SIGNING_TRANSIT_ADDRESS
+		Address string `yaml:"address" envconfig:"ADDRESS"`
+		// Mount is the mount path of the transit engine (default "transit").
+		// Env: LH_SIGNING_TRANSIT_MOUNT
+		Mount string `yaml:"mount" envconfig:"MOUNT"`
+		// KeyPrefix is the prefix of the transit key names; the key set and
+		// the algorithm are appended (default "lighthouse-").
+		// Env: LH_SIGNING_TRANSIT_KEY_PREFIX
+		KeyPrefix string `yaml:"key_prefix" envconfig:"KEY_PREFIX"`
+		// Token is the token used to authenticate to the signing service.
+		// Env: LH_SIGNING_TRANSIT_TOKEN
+		Token string `yaml:"token" envconfig:"TOKEN"`
+		// TokenFile is a file containing the token, e.g. written by an agent.
+		// Env: LH_SIGNING_TRANSIT_TOKEN_FILE
+		TokenFile string `yaml:"token_file" envconfig:"TOKEN_FILE"`
+		// Namespace is the namespace of the signing service, if any.
+		// Env: LH_SIGNING_TRANSIT_NAMESPACE
+		Namespace string `yaml:"namespace" envconfig:"NAMESPACE"`
+		// CACert is a PEM file with the CA certificates of the signing service.
+		// Env: LH_SIGNING_TRANSIT_CA_CERT
+		CACert string `yaml:"ca_cert" envconfig:"CA_CERT"`
+		// ClientCert and ClientKey are PEM files with the client certificate
+		// and key for mTLS authentication.
+		// Env: LH_SIGNING_TRANSIT_CLIENT_CERT, LH_SIGNING_TRANSIT_CLIENT_KEY
+		ClientCert string `yaml:"client_cert" envconfig:"CLIENT_CERT"`
+		ClientKey  string `yaml:"client_key" envconfig:"CLIENT_KEY"`
+	} `yaml:"transit" envconfig:"TRANSIT"`
 }
 
 const (
 	KMSFilesystem = "filesystem"
 	KMSPKCS11     = "pkcs11"
 	KMSDatabase   = "db"
+	KMSTransit    = "transit"
 )
 
+// defaultTransitKeyPrefix is the default prefix of the transit key names
+const defaultTransitKeyPrefix = "lighthouse-"
+
 const (
 	PKBackendFilesystem = "filesystem"
 	PKBackendDatabase   = "db"
 )
 
-func initKey(c SigningConf, storages model.Backends) (
+// Key sets; each key set has its own keys and signing settings.
+const (
+	// KeySetFederation is the key set for entity statements; it is
+	// published in the jwks of the entity configuration. It also signs trust
+	// marks and resolve responses unless separate key sets are configured.
+	KeySetFederation = "federation"
+	// KeySetTrustMarks is the key set for trust marks
+	KeySetTrustMarks = "trust_marks"
+	// KeySetResolve is the key set for resolve responses
+	KeySetResolve = "resolve"
+)
+
+// SeparateKeySetsSupported are the key sets that can be configured in
+// SigningConf.SeparateKeySets
+var SeparateKeySetsSupported = []string{
+	KeySetTrustMarks,
+	KeySetResolve,
+}
+
+// initKey initializes the key management for the passed key set. Only the
+// federation key set has API-managed public keys. The signing settings of
+// separate key sets are stored in their own key value scope and default to
+// the settings of the federation key set.
+func initKey(c SigningConf, storages model.Backends, keySet string) (
 	keyManagement adminapi.KeyManagement,
 	err error,
 ) {
 	keyManagement.KMS = c.KMS
+	var kv model.KeyValueStore = storages.KV
+	keyDir := c.FileSystemBackend.KeyDir
+	if keySet != KeySetFederation {
+		kv = storage.NewKeySetKeyValueStore(storages.KV, keySet)
+		if keyDir != "" {
+			// The filesystem KMS keeps a single state file per directory
+			keyDir = filepath.Join(keyDir, keySet)
+			if err = os.MkdirAll(keyDir, 0o700); err != nil {
+				return
+			}
+		}
+	}
 	switch c.PKBackend {
 	case PKBackendFilesystem:
 		keyManagement.KMSManagedPKs = &public.FilesystemPublicKeyStorage{
-			Dir:    c.FileSystemBackend.KeyDir,
-			TypeID: "federation",
+			Dir:    keyDir,
+			TypeID: keySet,
 		}
-		keyManagement.APIManagedPKs = &public.FilesystemPublicKeyStorage{
-			Dir:    c.FileSystemBackend.KeyDir,
-			TypeID: "api",
+		if keySet == KeySetFederation {
+			keyManagement.APIManagedPKs = &public.FilesystemPublicKeyStorage{
+				Dir:    keyDir,
+				TypeID: "api",
+			}
 		}
 	case PKBackendDatabase:
-		keyManagement.KMSManagedPKs = storages.PKStorages("federation")
-		keyManagement.APIManagedPKs = storages.PKStorages("api")
+		keyManagement.KMSManagedPKs = storages.PKStorages(keySet)
+		if keySet == KeySetFederation {
+			keyManagement.APIManagedPKs = storages.PKStorages("api")
+		}
 	default:
 		err = errors.Errorf("unsupported public key backend '%s'", c.PKBackend)
 		return
@@ -133,53 +234,67 @@ func initKey(c SigningConf, storages model.Backends) (
 	if err = keyManagement.KMSManagedPKs.Load(); err != nil {
 		return
 	}
-	if err = keyManagement.APIManagedPKs.Load(); err != nil {
-		return
+	if keyManagement.APIManagedPKs != nil {
+		if err = keyManagement.APIManagedPKs.Load(); err != nil {
+			return
+		}
 	}
-	alg, e := storage.GetSigningAlg(storages.KV)
+	// algs[0] is the default algorithm used for signing; keys for all algs
+	// are published
+	algs, e := storage.GetSigningAlgs(kv)
 	if e != nil {
 		err = e
 		return
 	}
-	rsaKeyLen, e := storage.GetRSAKeyLen(storages.KV)
+	rsaKeyLen, e := storage.GetRSAKeyLen(kv)
 	if e != nil {
 		err = e
 		return
 	}
-	rotationConf, e := storage.GetKeyRotation(storages.KV)
+	rotationConf, e := storage.GetKeyRotation(kv)
 	if e != nil {
 		err = e
 		return
 	}
+	kmsConfig := kms.KMSConfig{
+		GenerateKeys: c.AutoGenerateKeys,
+		Algs:         algs,
+		DefaultAlg:   algs[0],
+		RSAKeyLen:    rsaKeyLen,
+		KeyRotation:  rotationConf,
+	}
 	switch c.KMS {
 	case KMSFilesystem:
 		if c.FileSystemBackend.KeyFile != "" {
+			if keySet != KeySetFederation {
+				err = errors.Errorf("a single key file cannot be used with the separate key set '%s'", keySet)
+				return
+			}
+			if len(algs) > 1 {
+				err = errors.New("a single key file cannot be used with multiple signing algorithms")
+				return
+			}
 			keyManagement.BasicKeys = &kms.SingleSigningKeyFile{
-				Alg:  alg,
+				Alg:  algs[0],
 				Path: c.FileSystemBackend.KeyFile,
 			}
 		} else {
-			keyManagement.Keys = kms.NewSingleAlgFilesystemKMS(
-				alg, kms.FilesystemKMSConfig{
-					KMSConfig: kms.KMSConfig{
-						GenerateKeys: c.AutoGenerateKeys,
-						RSAKeyLen:    rsaKeyLen,
-						KeyRotation:  rotationConf,
-					},
-					Dir:    c.FileSystemBackend.KeyDir,
-					TypeID: "federation",
-				}, keyManagement.KMSManagedPKs,
+			keyManagement.Keys = newImportablePEMKMS(
+				kmsConfig,
+				&filesystemPEMStorage{FilesystemPEMStorage: kms.FilesystemPEMStorage{Dir: keyDir}},
+				&kms.FilesystemStateStorer{Dir: keyDir},
+				keyManagement.KMSManagedPKs,
 			)
 		}
 	case KMSPKCS11:
-		keyManagement.Keys = kms.NewSingleAlgPKCS11KMS(
-			alg, kms.PKCS11KMSConfig{
-				KMSConfig: kms.KMSConfig{
-					GenerateKeys: c.AutoGenerateKeys,
-					RSAKeyLen:    rsaKeyLen,
-					KeyRotation:  rotationConf,
-				},
-				TypeID:            "federation",
+		extraLabels := c.PKCS11Backend.ExtraLabels
+		if keySet != KeySetFederation {
+			extraLabels = nil
+		}
+		pkcs11KMS := kms.NewSingleAlgPKCS11KMS(
+			algs[0], kms.PKCS11KMSConfig{
+				KMSConfig:         kmsConfig,
+				TypeID:            keySet,
 				StorageDir:        c.PKCS11Backend.StorageDir,
 				ModulePath:        c.PKCS11Backend.ModulePath,
 				TokenLabel:        c.PKCS11Backend.TokenLabel,
@@ -190,23 +305,48 @@ func initKey(c SigningConf, storages model.Backends) (
 				UserType:          c.PKCS11Backend.UserType,
 				LoginNotSupported: c.PKCS11Backend.LoginNotSupported,
 				LabelPrefix:       c.PKCS11Backend.LabelPrefix,
-				ExtraLabels:       c.PKCS11Backend.ExtraLabels,
+				ExtraLabels:       extraLabels,
 			}, keyManagement.KMSManagedPKs,
 		)
+		// The lib only offers a single alg constructor, but the KMS itself
+		// supports multiple algs
+		if p, ok := pkcs11KMS.(*kms.PKCS11KMS); ok {
+			p.Algs = algs
+		}
+		keyManagement.Keys = pkcs11KMS
 	case KMSDatabase:
-		pemStorer := storage.NewDBPEMStorer(storages.DB, "federation")
-		stateStorer := storage.NewDBStateStorer(storages.KV, "federation")
-		keyManagement.Keys = kms.NewPEMStorageKMS(
-			kms.KMSConfig{
-				GenerateKeys: c.AutoGenerateKeys,
-				Algs:         []jwa.SignatureAlgorithm{alg},
-				RSAKeyLen:    rsaKeyLen,
-				KeyRotation:  rotationConf,
-			},
+		var kek storage.KEKProvider
+		if kek, err = c.DBBackend.Encryption.KEKProvider(); err != nil {
+			err = errors.Wrap(err, "could not initialize private key encryption")
+			return
+		}
+		pemStorer := storage.NewDBPEMStorer(storages.DB, keySet)
+		if kek != nil {
+			pemStorer = storage.NewEncryptedDBPEMStorer(storages.DB, keySet, kek)
+		}
+		stateStorer := storage.NewDBStateStorer(storages.KV, keySet)
+		keyManagement.Keys = newImportablePEMKMS(
+			kmsConfig,
 			pemStorer,
 			stateStorer,
 			keyManagement.KMSManagedPKs,
 		)
+	case KMSTransit:
+		var client *transitkms.Client
+		if client, err = newTransitClient(c); err != nil {
+			return
+		}
+		keyPrefix := c.TransitBackend.KeyPrefix
+		if keyPrefix == "" {
+			keyPrefix = defaultTransitKeyPrefix
+		}
+		keyManagement.Keys = transitkms.NewKMS(
+			kmsConfig,
+			client,
+			keyPrefix+keySet+"-",
+			storage.NewDBStateStorer(storages.KV, keySet),
+			keyManagement.KMSManagedPKs,
+		)
 	default:
 		err = errors.Errorf("unsupported kms '%s'", c.KMS)
 		return
@@ -223,3 +363,26 @@ func initKey(c SigningConf, storages model.Backends) (
 	}
 	return
 }
+
+// newTransitClient creates the client for the transit signing service.
+func newTransitClient(c SigningConf) (*transitkms.Client, error) {
+	token := c.TransitBackend.Token
+	if c.TransitBackend.TokenFile != "" {
+		data, err := os.ReadFile(c.TransitBackend.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read transit token file")
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	return transitkms.NewClient(
+		transitkms.ClientConfig{
+			Address:        c.TransitBackend.Address,
+			Mount:          c.TransitBackend.Mount,
+			Token:          token,
+			Namespace:      c.TransitBackend.Namespace,
+			CACertFile:     c.TransitBackend.CACert,
+			ClientCertFile: c.TransitBackend.ClientCert,
+			ClientKeyFile:  c.TransitBackend.ClientKey,
+		},
+	)
+}
@@ -0,0 +1,208 @@
+package lighthouse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx/keymanagement/kms"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/go-oidfed/lighthouse/api/adminapi"
+)
+
+// importablePEMKMS is a kms.PEMStorageKMS that supports importing existing
+// private keys through the admin API.
+//
+// The PEMStorageKMS only loads the private keys of keys that are active when
+// it is loaded. Keys with a future nbf, such as keys imported as the next key
+// or future keys generated before a restart, are therefore loaded once they
+// become active.
+type importablePEMKMS struct {
+	*kms.PEMStorageKMS
+	pemStorer kms.PEMStorer
+
+	mu sync.Mutex
+	// nextLoad is the earliest nbf of a key that is not yet active; it is
+	// zero if there is no such key
+	nextLoad time.Time
+}
+
+var (
+	_ kms.KeyManagementSystem = (*importablePEMKMS)(nil)
+	_ adminapi.KeyImporter    = (*importablePEMKMS)(nil)
+)
+
+// newImportablePEMKMS creates a new importablePEMKMS; the caller must call
+// Load.
+func newImportablePEMKMS(
+	config kms.KMSConfig, pemStorer kms.PEMStorer, stateStorer kms.KMSStateStorer, pks public.PublicKeyStorage,
+) *importablePEMKMS {
+	return &importablePEMKMS{
+		PEMStorageKMS: kms.NewPEMStorageKMS(config, pemStorer, stateStorer, pks),
+		pemStorer:     pemStorer,
+	}
+}
+
+// Load loads the private keys of all active keys and generates missing keys
+// if configured to do so.
+func (k *importablePEMKMS) Load() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.load()
+}
+
+func (k *importablePEMKMS) load() error {
+	err := k.PEMStorageKMS.Load()
+	// nextLoad is also updated on errors, so that a failing load is not
+	// retried on every signature
+	valid, vErr := k.PKs.GetValid()
+	if vErr != nil {
+		return errors.Wrap(vErr, "could not get valid public keys")
+	}
+	now := time.Now()
+	k.nextLoad = time.Time{}
+	for _, pk := range valid {
+		if pk.NotBefore == nil || !pk.NotBefore.After(now) {
+			continue
+		}
+		if k.nextLoad.IsZero() || pk.NotBefore.Before(k.nextLoad) {
+			k.nextLoad = pk.NotBefore.Time
+		}
+	}
+	return err
+}
+
+// loadActivatedKeys reloads the private keys if a key became active since the
+// last load.
+func (k *importablePEMKMS) loadActivatedKeys() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.nextLoad.IsZero() || time.Now().Before(k.nextLoad) {
+		return
+	}
+	if err := k.load(); err != nil {
+		log.WithError(err).Error("could not load activated signing keys")
+	}
+}
+
+// GetDefault returns a crypto.Signer for the default algorithm and the
+// algorithm.
+func (k *importablePEMKMS) GetDefault() (crypto.Signer, jwa.SignatureAlgorithm) {
+	k.loadActivatedKeys()
+	return k.PEMStorageKMS.GetDefault()
+}
+
+// GetForAlgs returns a crypto.Signer for one of the passed algorithms and
+// the algorithm.
+func (k *importablePEMKMS) GetForAlgs(algs ...string) (crypto.Signer, jwa.SignatureAlgorithm) {
+	k.loadActivatedKeys()
+	return k.PEMStorageKMS.GetForAlgs(algs...)
+}
+
+// ImportKey implements the adminapi.KeyImporter interface. The keys are only
+// loaded for signing once commit succeeded; otherwise the stored private and
+// public key are removed again.
+func (k *importablePEMKMS) ImportKey(sk crypto.Signer, entry public.PublicKeyEntry, commit func() error) error {
+	pemData, err := privateKeyToPEM(sk)
+	if err != nil {
+		return err
+	}
+	// Holding the lock prevents loading the key for signing before the
+	// import is committed
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err = k.pemStorer.WritePEM(entry.KID, pemData); err != nil {
+		return errors.Wrap(err, "could not store private key")
+	}
+	if err = k.PKs.Add(entry); err != nil {
+		k.removePEM(entry.KID)
+		return errors.Wrap(err, "could not store public key")
+	}
+	if err = commit(); err != nil {
+		if dErr := k.PKs.Delete(entry.KID); dErr != nil {
+			log.WithError(dErr).WithField("kid", entry.KID).Error("could not remove public key of failed import")
+		}
+		k.removePEM(entry.KID)
+		return err
+	}
+	return k.load()
+}
+
+// pemDeleter is implemented by kms.PEMStorers that can delete private keys.
+type pemDeleter interface {
+	Delete(kid string) error
+}
+
+// removePEM removes the private key of a failed import, if the PEMStorer
+// supports it.
+func (k *importablePEMKMS) removePEM(kid string) {
+	d, ok := k.pemStorer.(pemDeleter)
+	if !ok {
+		log.WithField("kid", kid).Warn("private key of failed import cannot be removed")
+		return
+	}
+	if err := d.Delete(kid); err != nil {
+		log.WithError(err).WithField("kid", kid).Error("could not remove private key of failed import")
+	}
+}
+
+// filesystemPEMStorage is a kms.FilesystemPEMStorage that can delete private
+// keys.
+type filesystemPEMStorage struct {
+	kms.FilesystemPEMStorage
+}
+
+// Delete removes the PEM file of the key.
+func (fps *filesystemPEMStorage) Delete(kid string) error {
+	err := os.Remove(filepath.Join(fps.Dir, kid+".pem"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return errors.WithStack(err)
+}
+
+// privateKeyToPEM encodes the private key in the format expected by the
+// PEMStorageKMS.
+func privateKeyToPEM(sk crypto.Signer) ([]byte, error) {
+	var block *pem.Block
+	switch sk := sk.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(sk),
+		}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(sk)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		block = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(sk)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		block = &pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+	return pem.EncodeToMemory(block), nil
+}
@@ -1,6 +1,7 @@
 package lighthouse
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
@@ -22,16 +23,19 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/go-oidfed/lighthouse/api/adminapi"
 	apistats "github.com/go-oidfed/lighthouse/api/stats"
 	"github.com/go-oidfed/lighthouse/internal"
 	"github.com/go-oidfed/lighthouse/internal/stats"
+	"github.com/go-oidfed/lighthouse/internal/stats/alerting"
 	"github.com/go-oidfed/lighthouse/internal/utils"
 	"github.com/go-oidfed/lighthouse/internal/version"
 	"github.com/go-oidfed/lighthouse/storage"
 	"github.com/go-oidfed/lighthouse/storage/model"
+	"github.com/go-oidfed/lighthouse/tracing"
 )
 
 const MaximumEntityConfigurationCachePeriod = 8 * time.Hour
@@ -85,7 +89,72 @@ type LightHouse struct {
 	VersionBanner           bool
 	storages                model.Backends
 	statsCollector          *stats.Collector
+	statsAlerts             *alerting.Evaluator
+	stopStatsAlerts         context.CancelFunc
+	statsAggregator         *stats.Aggregator
+	stopStatsAggregator     context.CancelFunc
+	stopAdminAPI            context.CancelFunc
 	trustMarkConfigProvider *storage.TrustMarkConfigProvider
+	eligibilityCache        *EligibilityCache
+	issuedTrustMarkCache    *IssuedTrustMarkCache
+	// keySets holds the key management of the separate key sets
+	keySets map[string]adminapi.KeyManagement
+	// keySetSigners holds the signers of the separate key sets
+	keySetSigners map[string]*jwx.GeneralJWTSigner
+}
+
+// KeySetSigner returns the jwx.GeneralJWTSigner for the passed key set. If no
+// separate keys are configured for the key set, the federation signer is
+// returned.
+func (fed *LightHouse) KeySetSigner(keySet string) *jwx.GeneralJWTSigner {
+	if signer, ok := fed.keySetSigners[keySet]; ok {
+		return signer
+	}
+	return fed.GeneralJWTSigner
+}
+
+// EligibilityCache returns the cache for the eligibility results of the
+// trust mark endpoint.
+func (fed *LightHouse) EligibilityCache() *EligibilityCache {
+	return fed.eligibilityCache
+}
+
+// IssuedTrustMarkCache returns the cache for the trust marks issued by the
+// trust mark endpoint.
+func (fed *LightHouse) IssuedTrustMarkCache() *IssuedTrustMarkCache {
+	return fed.issuedTrustMarkCache
+}
+
+// InvalidateTrustMark removes the cached eligibility result and issued trust
+// mark for the trust mark type and subject, e.g. after its trust marks were
+// revoked.
+func (fed *LightHouse) InvalidateTrustMark(trustMarkType, subject string) {
+	fed.eligibilityCache.Invalidate(trustMarkType, subject)
+	fed.issuedTrustMarkCache.Invalidate(trustMarkType, subject)
+}
+
+// separateKeySetsJWKS returns the JWKS with the valid keys of all separate
+// key sets; they are published in the federation_entity metadata.
+func (fed *LightHouse) separateKeySetsJWKS() (jwx.JWKS, error) {
+	set := jwx.NewJWKS()
+	for _, keySet := range SeparateKeySetsSupported {
+		km, ok := fed.keySets[keySet]
+		if !ok {
+			continue
+		}
+		keys, err := km.KMSManagedPKs.GetValid()
+		if err != nil {
+			return jwx.JWKS{}, err
+		}
+		for _, k := range keys {
+			kk, err := k.JWK()
+			if err != nil {
+				return jwx.JWKS{}, err
+			}
+			_ = set.AddKey(kk)
+		}
+	}
+	return set, nil
 }
 
 // FiberServerConfig is the fiber.Config that is used to init the http fiber.App
@@ -111,7 +180,7 @@ func NewLightHouse(
 	*LightHouse,
 	error,
 ) {
-	keyManagement, err := initKey(signingConf, storages)
+	keyManagement, err := initKey(signingConf, storages, KeySetFederation)
 	if err != nil {
 		return nil, err
 	}
@@ -123,26 +192,48 @@ func NewLightHouse(
 
 	generalSigner := jwx.NewGeneralJWTSigner(versatileSigner, keyManagement.BasicKeys.GetAlgs())
 
+	keySets := make(map[string]adminapi.KeyManagement)
+	keySetSigners := make(map[string]*jwx.GeneralJWTSigner)
+	for _, keySet := range signingConf.SeparateKeySets {
+		km, err := initKey(signingConf, storages, keySet)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to initialize key set '%s'", keySet)
+		}
+		signer, err := createVersatileSigner(km)
+		if err != nil {
+			return nil, err
+		}
+		keySets[keySet] = km
+		keySetSigners[keySet] = jwx.NewGeneralJWTSigner(signer, km.BasicKeys.GetAlgs())
+	}
+
 	server, err := initFiberServer(serverConf)
 	if err != nil {
 		return nil, err
 	}
 
+	statsConfig.Prefork = serverConf.Prefork
 	statsCollector, err := initStatsCollector(statsConfig, storages, server)
 	if err != nil {
 		return nil, err
 	}
 
+	trustMarkSigner := generalSigner
+	if signer, ok := keySetSigners[KeySetTrustMarks]; ok {
+		trustMarkSigner = signer
+	}
 	trustMarkConfigProvider := storage.NewTrustMarkConfigProvider(
 		storages.PublishedTrustMarks,
 		entityID,
 		"",
-		func() *jwx.TrustMarkSigner { return generalSigner.TrustMarkSigner() },
+		func() *jwx.TrustMarkSigner { return trustMarkSigner.TrustMarkSigner() },
 	)
 
 	entity := &LightHouse{
-		TrustMarkIssuer:         oidfed.NewTrustMarkIssuer(entityID, generalSigner.TrustMarkSigner(), nil),
+		TrustMarkIssuer:         oidfed.NewTrustMarkIssuer(entityID, trustMarkSigner.TrustMarkSigner(), nil),
 		GeneralJWTSigner:        generalSigner,
+		keySets:                 keySets,
+		keySetSigners:           keySetSigners,
 		server:                  server,
 		serverConf:              serverConf,
 		LogoBanner:              true,
@@ -150,20 +241,32 @@ func NewLightHouse(
 		keyManagement:           keyManagement,
 		storages:                storages,
 		statsCollector:          statsCollector,
+		statsAlerts:             initStatsAlerts(statsConfig, storages, entityID),
+		statsAggregator:         initStatsAggregator(statsConfig, storages),
 		trustMarkConfigProvider: trustMarkConfigProvider,
+		eligibilityCache:        NewEligibilityCache(),
+		issuedTrustMarkCache:    NewIssuedTrustMarkCache(),
 	}
 
 	entity.FederationEntity = buildDynamicFederationEntity(entity, entityID, storages)
 
 	registerEntityConfigurationEndpoint(server, entity)
 
+	var statsTail adminapi.StatsTail
+	if statsCollector != nil {
+		statsTail = statsCollector
+	}
+	// Background jobs of the admin API are canceled on Stop
+	adminCtx, stopAdminAPI := context.WithCancel(context.Background())
 	adminAPIServer, err := initAdminAPI(
-		admin, serverConf, server, entityID, storages,
-		entity.FederationEntity, keyManagement, trustMarkConfigProvider,
+		adminCtx, admin, serverConf, server, entityID, storages,
+		entity.FederationEntity, keyManagement, keySets, trustMarkConfigProvider, entity, statsTail,
 	)
 	if err != nil {
+		stopAdminAPI()
 		return nil, err
 	}
+	entity.stopAdminAPI = stopAdminAPI
 	entity.adminAPIServer = adminAPIServer
 	entity.serverConf.AdminAPIPort = admin.Port
 
@@ -174,15 +277,17 @@ func createVersatileSigner(keyManagement adminapi.KeyManagement) (jwx.VersatileS
 	return kms.KMSToVersatileSignerWithJWKSFunc(
 		keyManagement.BasicKeys,
 		func() (jwx.JWKS, error) {
-			kmsHistory, err := keyManagement.KMSManagedPKs.GetValid()
+			allEntries, err := keyManagement.KMSManagedPKs.GetValid()
 			if err != nil {
 				return jwx.JWKS{}, err
 			}
-			apiHistory, err := keyManagement.APIManagedPKs.GetValid()
-			if err != nil {
-				return jwx.JWKS{}, err
+			if keyManagement.APIManagedPKs != nil {
+				apiHistory, err := keyManagement.APIManagedPKs.GetValid()
+				if err != nil {
+					return jwx.JWKS{}, err
+				}
+				allEntries = append(allEntries, apiHistory...)
 			}
-			allEntries := append(kmsHistory, apiHistory...)
 			set := jwx.NewJWKS()
 			for _, k := range allEntries {
 				kk, err := k.JWK()
@@ -211,6 +316,9 @@ func initFiberServer(serverConf ServerConf) (*fiber.App, error) {
 	server.Use(compress.New())
 	server.Use(logger.New())
 	server.Use(requestid.New())
+	if tracing.Enabled() {
+		server.Use(tracing.Middleware("main"))
+	}
 
 	if serverConf.CORS.Enabled {
 		server.Use(cors.New(corsConfigFromConf(serverConf.CORS)))
@@ -237,6 +345,25 @@ func initStatsCollector(statsConfig apistats.Config, storages model.Backends, se
 	return collector, nil
 }
 
+func initStatsAlerts(statsConfig apistats.Config, storages model.Backends, entityID string) *alerting.Evaluator {
+	if !statsConfig.Enabled || !statsConfig.Alerts.Enabled || storages.Stats == nil {
+		return nil
+	}
+	return alerting.NewEvaluator(
+		statsConfig.Alerts, storages.Stats, entityID, alerting.NewSinks(statsConfig.Alerts)...,
+	)
+}
+
+func initStatsAggregator(statsConfig apistats.Config, storages model.Backends) *stats.Aggregator {
+	if !statsConfig.Enabled || storages.Stats == nil {
+		return nil
+	}
+	return stats.NewAggregator(
+		storages.Stats, statsConfig.DetailedRetention, statsConfig.HourlyRetention,
+		statsConfig.AggregatedRetention,
+	)
+}
+
 func buildDynamicFederationEntity(
 	entity *LightHouse, entityID string, storages model.Backends,
 ) oidfed.FederationEntity {
@@ -287,6 +414,15 @@ func buildDynamicFederationEntity(
 				extraBase = map[string]any{}
 			}
 			mergedFE.Extra = utils.MergeMaps(true, extraBase, entity.fedMetadata.Extra)
+			if len(entity.keySets) > 0 {
+				// Keys of separate key sets (e.g. for trust marks) are
+				// published in the federation_entity metadata
+				jwks, err := entity.separateKeySetsJWKS()
+				if err != nil {
+					return nil, err
+				}
+				mergedFE.Extra["jwks"] = jwks
+			}
 			m.FederationEntity = &mergedFE
 			return m, nil
 		},
@@ -356,6 +492,7 @@ func registerEntityConfigurationEndpoint(server *fiber.App, entity *LightHouse)
 }
 
 func initAdminAPI(
+	ctx context.Context,
 	admin AdminAPIOptions,
 	serverConf ServerConf,
 	server *fiber.App,
@@ -363,7 +500,10 @@ func initAdminAPI(
 	storages model.Backends,
 	fedEntity oidfed.FederationEntity,
 	keyManagement adminapi.KeyManagement,
+	keySets map[string]adminapi.KeyManagement,
 	trustMarkConfigProvider *storage.TrustMarkConfigProvider,
+	trustMarkCacheInvalidator adminapi.TrustMarkCacheInvalidator,
+	statsTail adminapi.StatsTail,
 ) (
 	*fiber.App,
 	error,
@@ -379,6 +519,9 @@ func initAdminAPI(
 		adminAPIServer.Use(compress.New())
 		adminAPIServer.Use(logger.New())
 		adminAPIServer.Use(requestid.New())
+		if tracing.Enabled() {
+			adminAPIServer.Use(tracing.Middleware("admin"))
+		}
 
 		if admin.CORS.Enabled {
 			adminAPIServer.Use(cors.New(corsConfigFromConf(admin.CORS)))
@@ -403,6 +546,11 @@ func initAdminAPI(
 			UsersEnabled:               admin.UsersEnabled,
 			Port:                       admin.Port,
 			TrustMarkConfigInvalidator: trustMarkConfigProvider,
+			TrustMarkCacheInvalidator:  trustMarkCacheInvalidator,
+			KeySets:                    keySets,
+			StatsTail:                  statsTail,
+			Context:                    ctx,
+			TopologyCrawlTimeout:       admin.TopologyCrawlTimeout,
 			Actor: adminapi.ActorConfig{
 				Header: admin.ActorHeader,
 				Source: adminapi.ActorSource(admin.ActorSource),
@@ -447,10 +595,26 @@ func (fed *LightHouse) Start() {
 	fed.banner()
 
 	// Start stats collector if enabled
-	// In prefork mode, only start in parent process to avoid duplicate stats
-	if fed.statsCollector != nil && !fiber.IsChild() {
+	// In prefork mode, children forward their stats to the parent process
+	if fed.statsCollector != nil {
 		fed.statsCollector.Start()
 	}
+	// Alerts are evaluated from the database, so one process is enough
+	if fed.statsAlerts != nil && !fiber.IsChild() {
+		ctx, cancel := context.WithCancel(context.Background())
+		fed.stopStatsAlerts = cancel
+		go func() {
+			_ = fed.statsAlerts.Run(ctx)
+		}()
+	}
+	// Aggregation and retention work on the database, so one process is enough
+	if fed.statsAggregator != nil && !fiber.IsChild() {
+		ctx, cancel := context.WithCancel(context.Background())
+		fed.stopStatsAggregator = cancel
+		go func() {
+			_ = fed.statsAggregator.Run(ctx)
+		}()
+	}
 
 	conf := fed.serverConf
 	adminTLS := fed.adminAPIServer != nil && fed.adminAPIServer != fed.server && fed.serverConf.AdminTLS.Enabled
@@ -510,6 +674,15 @@ func (fed *LightHouse) Stop() error {
 			log.WithError(err).Warn("error stopping stats collector")
 		}
 	}
+	if fed.stopStatsAlerts != nil {
+		fed.stopStatsAlerts()
+	}
+	if fed.stopStatsAggregator != nil {
+		fed.stopStatsAggregator()
+	}
+	if fed.stopAdminAPI != nil {
+		fed.stopAdminAPI()
+	}
 
 	// Shutdown fiber servers
 	if err := fed.server.Shutdown(); err != nil {
@@ -636,6 +809,9 @@ type AdminAPIOptions struct {
 	CORS CORSConf
 	// TLS holds TLS configuration for the admin API.
 	TLS TLSConf
+	// TopologyCrawlTimeout limits the duration of topology crawls.
+	// Default: 10m
+	TopologyCrawlTimeout time.Duration
 }
 
 // corsConfigFromConf converts a CORSConf to a Fiber CORS middleware configuration.
@@ -0,0 +1,100 @@
+package lighthouse
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/go-oidfed/lighthouse/storage"
+)
+
+// PEMEncryptionConf configures the encryption at rest of the private keys
+// stored by the database KMS. Private keys are encrypted with a random data
+// key, which is wrapped with a key-encryption key (KEK). The KEK is either a
+// base64 encoded 32 byte key (given directly, typically through the
+// environment, or in a file), or is obtained from a KEK provider registered
+// with RegisterKEKProvider.
+//
+// Environment variables (with prefix LH_SIGNING_DB_ENCRYPTION_):
+//   - LH_SIGNING_DB_ENCRYPTION_KEK: Base64 encoded key-encryption key
+//   - LH_SIGNING_DB_ENCRYPTION_KEK_FILE: File containing the key-encryption key
+//   - LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEKS: Previous keys (comma-separated)
+//   - LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEK_FILES: Files with previous keys (comma-separated)
+//   - LH_SIGNING_DB_ENCRYPTION_PROVIDER: Name of a registered KEK provider
+//   - LH_SIGNING_DB_ENCRYPTION_PROVIDER_CONFIG: Provider configuration (key:value pairs, comma-separated)
+type PEMEncryptionConf struct {
+	// KEK is the base64 encoded key-encryption key.
+	// Env: LH_SIGNING_DB_ENCRYPTION_KEK
+	KEK string `yaml:"kek" envconfig:"KEK"`
+	// KEKFile is the path to a file containing the base64 encoded
+	// key-encryption key.
+	// Env: LH_SIGNING_DB_ENCRYPTION_KEK_FILE
+	KEKFile string `yaml:"kek_file" envconfig:"KEK_FILE"`
+	// PreviousKEKs are base64 encoded key-encryption keys that are only used
+	// for decryption; they are needed while re-keying.
+	// Env: LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEKS (comma-separated)
+	PreviousKEKs []string `yaml:"previous_keks" envconfig:"PREVIOUS_KEKS"`
+	// PreviousKEKFiles are files containing previous key-encryption keys.
+	// Env: LH_SIGNING_DB_ENCRYPTION_PREVIOUS_KEK_FILES (comma-separated)
+	PreviousKEKFiles []string `yaml:"previous_kek_files" envconfig:"PREVIOUS_KEK_FILES"`
+	// Provider is the name of a KEK provider registered with
+	// RegisterKEKProvider; it cannot be combined with the options above.
+	// Env: LH_SIGNING_DB_ENCRYPTION_PROVIDER
+	Provider string `yaml:"provider" envconfig:"PROVIDER"`
+	// ProviderConfig is passed to the KEK provider constructor.
+	// Env: LH_SIGNING_DB_ENCRYPTION_PROVIDER_CONFIG
+	ProviderConfig map[string]string `yaml:"provider_config" envconfig:"PROVIDER_CONFIG"`
+}
+
+// Enabled reports whether private keys should be encrypted.
+func (c PEMEncryptionConf) Enabled() bool {
+	return c.KEK != "" || c.KEKFile != "" || c.Provider != ""
+}
+
+var kekProviderRegistry = make(map[string]func(config map[string]string) (storage.KEKProvider, error))
+
+// RegisterKEKProvider registers a custom storage.KEKProvider, e.g. backed by
+// an external key management system, so it can be selected with
+// PEMEncryptionConf.Provider
+func RegisterKEKProvider(name string, constructor func(config map[string]string) (storage.KEKProvider, error)) {
+	kekProviderRegistry[name] = constructor
+}
+
+// KEKProvider returns the storage.KEKProvider for this configuration, or
+// nil if encryption is not enabled.
+func (c PEMEncryptionConf) KEKProvider() (storage.KEKProvider, error) {
+	if c.Provider != "" {
+		constructor, ok := kekProviderRegistry[c.Provider]
+		if !ok {
+			return nil, errors.Errorf("unknown kek provider '%s'", c.Provider)
+		}
+		return constructor(c.ProviderConfig)
+	}
+	if !c.Enabled() {
+		return nil, nil
+	}
+	var current storage.KEK
+	var err error
+	if c.KEK != "" {
+		current, err = storage.ParseKEK(c.KEK)
+	} else {
+		current, err = storage.ReadKEKFile(c.KEKFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	previous := make([]storage.KEK, 0, len(c.PreviousKEKs)+len(c.PreviousKEKFiles))
+	for _, k := range c.PreviousKEKs {
+		kek, err := storage.ParseKEK(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid previous key-encryption key")
+		}
+		previous = append(previous, kek)
+	}
+	for _, f := range c.PreviousKEKFiles {
+		kek, err := storage.ReadKEKFile(f)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, kek)
+	}
+	return storage.NewStaticKEKProvider(current, previous...), nil
+}
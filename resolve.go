@@ -9,6 +9,9 @@ import (
 	"github.com/go-oidfed/lib/oidfedconst"
 	"github.com/go-oidfed/lib/unixtime"
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/go-oidfed/lighthouse/tracing"
 )
 
 // AddResolveEndpoint adds a resolve endpoint
@@ -21,7 +24,7 @@ func (fed *LightHouse) AddResolveEndpoint(
 	}
 
 	writeResponse := func(ctx *fiber.Ctx, res *oidfed.ResolveResponse) error {
-		jwt, err := fed.GeneralJWTSigner.ResolveResponseSigner().JWT(res)
+		jwt, err := fed.KeySetSigner(KeySetResolve).ResolveResponseSigner().JWT(res)
 		if err != nil {
 			ctx.Status(fiber.StatusInternalServerError)
 			return ctx.JSON(oidfed.ErrorServerError(err.Error()))
@@ -100,7 +103,14 @@ func createResolveResponse(
 		StartingEntity: req.Subject,
 		Types:          req.EntityTypes,
 	}
+	_, span := tracing.Start(
+		ctx.UserContext(), "oidfed.ResolveTrustChains",
+		attribute.String("oidfed.subject", req.Subject),
+		attribute.StringSlice("oidfed.trust_anchors", req.TrustAnchor),
+	)
 	chains := resolver.ResolveToValidChainsWithoutVerifyingMetadata()
+	span.SetAttributes(attribute.Int("oidfed.trust_chains", len(chains)))
+	span.End()
 	if len(chains) == 0 {
 		ctx.Status(fiber.StatusNotFound)
 		return nil, ctx.JSON(
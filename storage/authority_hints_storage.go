@@ -24,6 +24,21 @@ func (s *AuthorityHintsStorage) List() ([]model.AuthorityHint, error) {
 	return items, nil
 }
 
+// ListPage returns a single page of authority hints, sorted and searched
+// according to the list options.
+func (s *AuthorityHintsStorage) ListPage(opts model.ListOptions) ([]model.AuthorityHint, model.PageInfo, error) {
+	items, page, err := findPage(
+		s.db.Model(&model.AuthorityHint{}), opts, entityListColumns,
+		func(h model.AuthorityHint) model.ListCursor {
+			return listCursor(opts.Sort, h.ID, h.CreatedAt, h.UpdatedAt, h.EntityID)
+		},
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "authority_hints: list failed")
+	}
+	return items, page, nil
+}
+
 func (s *AuthorityHintsStorage) Create(hint model.AddAuthorityHint) (*model.AuthorityHint, error) {
 	var existing model.AuthorityHint
 	result := s.db.Unscoped().Where("entity_id = ?", hint.EntityID).First(&existing)
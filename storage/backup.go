@@ -0,0 +1,575 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"github.com/go-oidfed/lighthouse/internal/version"
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// schemaChanges describes every change to the database schema; the entry at
+// index i was introduced with schema version i+1. A change to the models, e.g.
+// a new table or column, must be appended here, so that SchemaVersion is
+// increased and backups created by this version are not restored into an
+// older schema.
+var schemaChanges = [...]string{
+	"initial schema",
+	"signing settings per key set in the key value store",
+	"key events table",
+	"hourly statistics table",
+	"revocation reason, actor and time for issued trust marks",
+}
+
+// SchemaVersion is the version of the database schema. It is derived from
+// schemaChanges.
+const SchemaVersion = len(schemaChanges)
+
+// minRestorableSchemaVersion is the oldest schema version of a backup that
+// can still be restored into the current schema. It must be increased if a
+// schema change is not compatible with older backups, e.g. when a column is
+// renamed or its type changes.
+const minRestorableSchemaVersion = 1
+
+// backupKeyEncryptionAlg identifies the algorithm used to encrypt private
+// keys in a backup.
+const backupKeyEncryptionAlg = "argon2id+A256GCM"
+
+const (
+	publicKeysTablePrefix  = "public_keys"
+	privateKeysTablePrefix = "private_keys"
+)
+
+// Backup is a snapshot of all LightHouse database tables. It is independent
+// of the database driver, so it can be restored into a database using a
+// different driver.
+type Backup struct {
+	SchemaVersion     int                  `json:"schema_version"`
+	LighthouseVersion string               `json:"lighthouse_version"`
+	Driver            string               `json:"driver"`
+	CreatedAt         time.Time            `json:"created_at"`
+	KeyEncryption     *BackupKeyEncryption `json:"key_encryption,omitempty"`
+	Tables            []BackupTable        `json:"tables"`
+}
+
+// BackupTable holds all rows of a single table; each row maps column names to
+// JSON encoded values.
+type BackupTable struct {
+	Name string                       `json:"name"`
+	Rows []map[string]json.RawMessage `json:"rows"`
+}
+
+// BackupKeyEncryption describes how private keys in a Backup are encrypted.
+// The encryption key is derived from a passphrase with argon2id.
+type BackupKeyEncryption struct {
+	Algorithm   string `json:"alg"`
+	Salt        []byte `json:"salt"`
+	Time        uint32 `json:"time"`
+	MemoryKiB   uint32 `json:"memory_kib"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// BackupOptions configures CreateBackup.
+type BackupOptions struct {
+	// Passphrase is used to encrypt private keys; if empty, private keys are
+	// stored unencrypted.
+	Passphrase string
+	// WithoutStats excludes the statistics tables from the backup.
+	WithoutStats bool
+}
+
+// RestoreOptions configures RestoreBackup.
+type RestoreOptions struct {
+	// Passphrase is used to decrypt private keys; it is required if the
+	// backup has encrypted private keys.
+	Passphrase string
+	// Clear deletes all existing data before restoring. If not set, the
+	// restore fails if the database is not empty.
+	Clear bool
+}
+
+// backupTableSpec describes a table that is part of a backup.
+type backupTableSpec struct {
+	name   string
+	schema *schema.Schema
+}
+
+// CreateBackup creates a Backup of all tables in the passed database.
+// The snapshot is taken within a single transaction, so it is consistent.
+func CreateBackup(db *gorm.DB, opts BackupOptions) (*Backup, error) {
+	backup := &Backup{
+		SchemaVersion:     SchemaVersion,
+		LighthouseVersion: version.VERSION,
+		Driver:            db.Dialector.Name(),
+		CreatedAt:         time.Now().UTC(),
+	}
+	var encKey []byte
+	if opts.Passphrase != "" {
+		enc, err := newBackupKeyEncryption()
+		if err != nil {
+			return nil, err
+		}
+		backup.KeyEncryption = enc
+		encKey = enc.key(opts.Passphrase)
+	}
+
+	specs, err := backupTableSpecs(db, !opts.WithoutStats)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Transaction(
+		func(tx *gorm.DB) error {
+			for _, spec := range specs {
+				table, err := dumpTable(tx, spec, encKey)
+				if err != nil {
+					return err
+				}
+				backup.Tables = append(backup.Tables, *table)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// RestoreBackup restores the passed Backup into the passed database within a
+// single transaction. The schema version of the backup must be compatible with
+// the current schema.
+func RestoreBackup(db *gorm.DB, backup *Backup, opts RestoreOptions) error {
+	if backup.SchemaVersion > SchemaVersion {
+		return errors.Errorf(
+			"backup has schema version %d, but this LightHouse only supports schema version %d; "+
+				"please use a newer version to restore this backup", backup.SchemaVersion, SchemaVersion,
+		)
+	}
+	if backup.SchemaVersion < minRestorableSchemaVersion {
+		return errors.Errorf(
+			"backup has schema version %d, which is no longer supported (minimum %d)",
+			backup.SchemaVersion, minRestorableSchemaVersion,
+		)
+	}
+	var decKey []byte
+	if backup.KeyEncryption != nil {
+		if backup.KeyEncryption.Algorithm != backupKeyEncryptionAlg {
+			return errors.Errorf("unsupported key encryption algorithm '%s'", backup.KeyEncryption.Algorithm)
+		}
+		if opts.Passphrase == "" {
+			return errors.New("backup contains encrypted private keys, but no passphrase was given")
+		}
+		decKey = backup.KeyEncryption.key(opts.Passphrase)
+	}
+
+	// Tables must exist before the transaction is started, since some
+	// databases implicitly commit on schema changes.
+	if err := migrateBackupTables(db, backup); err != nil {
+		return err
+	}
+	withStats := slices.ContainsFunc(
+		backup.Tables, func(t BackupTable) bool {
+			return isStatsTable(db, t.Name)
+		},
+	)
+	specs, err := backupTableSpecs(db, withStats)
+	if err != nil {
+		return err
+	}
+	tables := make(map[string]BackupTable, len(backup.Tables))
+	for _, t := range backup.Tables {
+		if !slices.ContainsFunc(
+			specs, func(s backupTableSpec) bool {
+				return s.name == t.Name
+			},
+		) {
+			return errors.Errorf("backup contains unknown table '%s'", t.Name)
+		}
+		tables[t.Name] = t
+	}
+
+	return db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := prepareRestoreTarget(tx, specs, opts.Clear); err != nil {
+				return err
+			}
+			for _, spec := range specs {
+				t, ok := tables[spec.name]
+				if !ok {
+					continue
+				}
+				if err := restoreTable(tx, spec, t, decKey); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// backupTableSpecs returns all tables that are part of a backup in an order
+// that satisfies foreign key constraints, i.e. referenced tables come first.
+func backupTableSpecs(db *gorm.DB, withStats bool) ([]backupTableSpec, error) {
+	var specs []backupTableSpec
+	add := func(m any, name string) error {
+		stmt := &gorm.Statement{DB: db}
+		var err error
+		if name == "" {
+			err = stmt.Parse(m)
+		} else {
+			err = stmt.ParseWithSpecialTableName(m, name)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse schema of %T", m)
+		}
+		name = stmt.Schema.Table
+		if slices.ContainsFunc(
+			specs, func(s backupTableSpec) bool {
+				return s.name == name
+			},
+		) {
+			return nil
+		}
+		specs = append(
+			specs, backupTableSpec{
+				name:   name,
+				schema: stmt.Schema,
+			},
+		)
+		for _, rel := range stmt.Schema.Relationships.Many2Many {
+			if rel.JoinTable != nil && !slices.ContainsFunc(
+				specs, func(s backupTableSpec) bool {
+					return s.name == rel.JoinTable.Table
+				},
+			) {
+				specs = append(
+					specs, backupTableSpec{
+						name:   rel.JoinTable.Table,
+						schema: rel.JoinTable,
+					},
+				)
+			}
+		}
+		return nil
+	}
+
+	for _, m := range models {
+		if err := add(m, ""); err != nil {
+			return nil, err
+		}
+	}
+	if withStats {
+		for _, m := range statsModels {
+			if !db.Migrator().HasTable(m) {
+				continue
+			}
+			if err := add(m, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	tableNames, err := db.Migrator().GetTables()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tables")
+	}
+	slices.Sort(tableNames)
+	for _, name := range tableNames {
+		switch {
+		case isKeyTable(name, publicKeysTablePrefix):
+			err = add(&public.PublicKeyEntry{}, name)
+		case isKeyTable(name, privateKeysTablePrefix):
+			err = add(&model.PrivateKeyEntry{}, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortBackupTableSpecs(specs), nil
+}
+
+// isKeyTable checks if the table name is a key table with the passed prefix,
+// i.e. either equals the prefix or is of the form <prefix>_<typeID>.
+func isKeyTable(name, prefix string) bool {
+	return name == prefix || strings.HasPrefix(name, prefix+"_")
+}
+
+// isStatsTable checks if the table name belongs to a statistics model.
+func isStatsTable(db *gorm.DB, name string) bool {
+	for _, m := range statsModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err == nil && stmt.Schema.Table == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortBackupTableSpecs sorts the specs topologically by their foreign key
+// dependencies while otherwise keeping the given order.
+func sortBackupTableSpecs(specs []backupTableSpec) []backupTableSpec {
+	deps := make(map[string][]string, len(specs))
+	for _, s := range specs {
+		for _, rel := range s.schema.Relationships.Relations {
+			if rel.FieldSchema == nil || rel.FieldSchema.Table == s.name {
+				continue
+			}
+			switch rel.Type {
+			case schema.BelongsTo:
+				deps[s.name] = append(deps[s.name], rel.FieldSchema.Table)
+			case schema.HasOne, schema.HasMany:
+				deps[rel.FieldSchema.Table] = append(deps[rel.FieldSchema.Table], s.name)
+			case schema.Many2Many:
+				if rel.JoinTable != nil {
+					deps[rel.JoinTable.Table] = append(deps[rel.JoinTable.Table], s.name, rel.FieldSchema.Table)
+				}
+			}
+		}
+	}
+
+	sorted := make([]backupTableSpec, 0, len(specs))
+	done := make(map[string]bool, len(specs))
+	visiting := make(map[string]bool, len(specs))
+	byName := make(map[string]backupTableSpec, len(specs))
+	for _, s := range specs {
+		byName[s.name] = s
+	}
+	var visit func(name string)
+	visit = func(name string) {
+		if done[name] || visiting[name] {
+			return
+		}
+		visiting[name] = true
+		for _, d := range deps[name] {
+			visit(d)
+		}
+		visiting[name] = false
+		done[name] = true
+		if s, ok := byName[name]; ok {
+			sorted = append(sorted, s)
+		}
+	}
+	for _, s := range specs {
+		visit(s.name)
+	}
+	return sorted
+}
+
+// dumpTable reads all rows, including soft-deleted ones, of a table.
+func dumpTable(tx *gorm.DB, spec backupTableSpec, encKey []byte) (*BackupTable, error) {
+	rows := reflect.New(reflect.SliceOf(spec.schema.ModelType))
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Table(spec.name).Find(
+		rows.Interface(),
+	).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to read table '%s'", spec.name)
+	}
+	encrypt := encKey != nil && isKeyTable(spec.name, privateKeysTablePrefix)
+
+	ctx := context.Background()
+	table := &BackupTable{
+		Name: spec.name,
+		Rows: make([]map[string]json.RawMessage, rows.Elem().Len()),
+	}
+	for i := range rows.Elem().Len() {
+		row := rows.Elem().Index(i)
+		values := make(map[string]json.RawMessage, len(spec.schema.DBNames))
+		for _, f := range spec.schema.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			v := f.ReflectValueOf(ctx, row).Interface()
+			if encrypt && f.DBName == "pem_data" {
+				pem, _ := v.(model.PEMData)
+				encrypted, err := encryptBackupValue(encKey, pem)
+				if err != nil {
+					return nil, err
+				}
+				v = encrypted
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to encode column '%s' of table '%s'", f.DBName, spec.name)
+			}
+			values[f.DBName] = data
+		}
+		table.Rows[i] = values
+	}
+	return table, nil
+}
+
+// migrateBackupTables creates the dynamically named key tables and the
+// statistics tables contained in the backup.
+func migrateBackupTables(db *gorm.DB, backup *Backup) error {
+	for _, t := range backup.Tables {
+		var err error
+		switch {
+		case isKeyTable(t.Name, publicKeysTablePrefix):
+			err = db.Table(t.Name).AutoMigrate(&public.PublicKeyEntry{})
+		case isKeyTable(t.Name, privateKeysTablePrefix):
+			err = db.Table(t.Name).AutoMigrate(&model.PrivateKeyEntry{})
+		case isStatsTable(db, t.Name):
+			err = MigrateStats(db)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to create table '%s'", t.Name)
+		}
+	}
+	return nil
+}
+
+// prepareRestoreTarget ensures that all tables are empty, either by deleting
+// all rows (if clear is set) or by returning an error.
+func prepareRestoreTarget(tx *gorm.DB, specs []backupTableSpec, clear bool) error {
+	for i := len(specs) - 1; i >= 0; i-- {
+		spec := specs[i]
+		if clear {
+			if err := tx.Exec(
+				fmt.Sprintf("DELETE FROM %s", tx.Statement.Quote(spec.name)),
+			).Error; err != nil {
+				return errors.Wrapf(err, "failed to clear table '%s'", spec.name)
+			}
+			continue
+		}
+		var count int64
+		if err := tx.Session(&gorm.Session{NewDB: true}).Table(spec.name).Count(&count).Error; err != nil {
+			return errors.Wrapf(err, "failed to count rows of table '%s'", spec.name)
+		}
+		if count > 0 {
+			return errors.Errorf("table '%s' is not empty; clear the database before restoring", spec.name)
+		}
+	}
+	return nil
+}
+
+// restoreTable inserts all rows of the backup table.
+func restoreTable(tx *gorm.DB, spec backupTableSpec, t BackupTable, decKey []byte) error {
+	if len(t.Rows) == 0 {
+		return nil
+	}
+	decrypt := decKey != nil && isKeyTable(spec.name, privateKeysTablePrefix)
+
+	ctx := context.Background()
+	rows := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(spec.schema.ModelType)), len(t.Rows), len(t.Rows))
+	for i, values := range t.Rows {
+		row := reflect.New(spec.schema.ModelType)
+		for column, data := range values {
+			f := spec.schema.LookUpField(column)
+			if f == nil || f.DBName != column {
+				return errors.Errorf("backup contains unknown column '%s' in table '%s'", column, spec.name)
+			}
+			v := reflect.New(f.FieldType)
+			if err := json.Unmarshal(data, v.Interface()); err != nil {
+				return errors.Wrapf(err, "failed to decode column '%s' of table '%s'", column, spec.name)
+			}
+			if decrypt && column == "pem_data" {
+				pem, err := decryptBackupValue(decKey, v.Elem().Interface().(model.PEMData))
+				if err != nil {
+					return err
+				}
+				v.Elem().Set(reflect.ValueOf(model.PEMData(pem)))
+			}
+			f.ReflectValueOf(ctx, row).Set(v.Elem())
+		}
+		rows.Index(i).Set(row)
+	}
+	if err := tx.Session(
+		&gorm.Session{
+			NewDB:     true,
+			SkipHooks: true,
+		},
+	).Table(spec.name).Omit(clause.Associations).CreateInBatches(rows.Interface(), 100).Error; err != nil {
+		return errors.Wrapf(err, "failed to restore table '%s'", spec.name)
+	}
+	return resetSequence(tx, spec)
+}
+
+// resetSequence updates the primary key sequence of a table after rows with
+// explicit IDs have been inserted. This is only needed for postgres.
+func resetSequence(tx *gorm.DB, spec backupTableSpec) error {
+	pk := spec.schema.PrioritizedPrimaryField
+	if tx.Dialector.Name() != string(DriverPostgres) || pk == nil || !pk.AutoIncrement {
+		return nil
+	}
+	return errors.Wrapf(
+		tx.Exec(
+			fmt.Sprintf(
+				"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 1)) FROM %s",
+				spec.name, pk.DBName, tx.Statement.Quote(pk.DBName), tx.Statement.Quote(spec.name),
+			),
+		).Error, "failed to reset sequence of table '%s'", spec.name,
+	)
+}
+
+// newBackupKeyEncryption creates a BackupKeyEncryption with a random salt.
+func newBackupKeyEncryption() (*BackupKeyEncryption, error) {
+	params := defaultArgon2idParams()
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+	return &BackupKeyEncryption{
+		Algorithm:   backupKeyEncryptionAlg,
+		Salt:        salt,
+		Time:        params.Time,
+		MemoryKiB:   params.MemoryKiB,
+		Parallelism: params.Parallelism,
+	}, nil
+}
+
+// key derives the encryption key from the passphrase.
+func (e BackupKeyEncryption) key(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), e.Salt, e.Time, e.MemoryKiB, e.Parallelism, 32)
+}
+
+// encryptBackupValue encrypts data with AES-GCM; the nonce is prepended to
+// the ciphertext.
+func encryptBackupValue(key, data []byte) ([]byte, error) {
+	gcm, err := newBackupGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptBackupValue decrypts data encrypted with encryptBackupValue.
+func decryptBackupValue(key, data []byte) ([]byte, error) {
+	gcm, err := newBackupGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted private key is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt private key: wrong passphrase?")
+	}
+	return plain, nil
+}
+
+func newBackupGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cipher.NewGCM(block)
+}
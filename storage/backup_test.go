@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+func newBackupTestStorage(t *testing.T, name string) *Storage {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", url.PathEscape(t.Name()+"_"+name))
+	store, err := NewStorage(
+		Config{
+			Driver: DriverSQLite,
+			DSN:    dsn,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return store
+}
+
+// fillBackupTestStorage adds data to the most relevant tables, including a
+// many2many join table and dynamically named key tables.
+func fillBackupTestStorage(t *testing.T, store *Storage) {
+	t.Helper()
+	backends := store.Backends()
+
+	key, err := jwk.Import([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	_ = key.Set(jwk.KeyIDKey, "sub-key")
+	set := jwk.NewSet()
+	_ = set.AddKey(key)
+	for _, entityID := range []string{"https://a.example.org", "https://deleted.example.org"} {
+		if err = backends.Subordinates.Add(
+			model.ExtendedSubordinateInfo{
+				BasicSubordinateInfo: model.BasicSubordinateInfo{
+					EntityID:               entityID,
+					Status:                 model.StatusActive,
+					SubordinateEntityTypes: []model.SubordinateEntityType{{EntityType: "openid_provider"}},
+					Labels:                 model.NewSubordinateLabels(map[string]string{"project": "alpha"}),
+				},
+				JWKS: model.JWKS{Keys: jwx.JWKS{Set: set}},
+				SubordinateAdditionalClaims: []model.SubordinateAdditionalClaim{
+					{
+						Claim: "custom",
+						Value: "value",
+						Crit:  true,
+					},
+				},
+			},
+		); err != nil {
+			t.Fatalf("Failed to add subordinate: %v", err)
+		}
+	}
+	if err = backends.Subordinates.Delete("https://deleted.example.org"); err != nil {
+		t.Fatalf("Failed to delete subordinate: %v", err)
+	}
+	if _, err = backends.TrustMarkTypes.Create(
+		model.AddTrustMarkType{
+			TrustMarkType:    "https://tm.example.org",
+			TrustMarkIssuers: []model.AddTrustMarkIssuer{{Issuer: "https://issuer.example.org"}},
+		},
+	); err != nil {
+		t.Fatalf("Failed to create trust mark type: %v", err)
+	}
+	if err = backends.KV.Set("test", "key", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Failed to set kv: %v", err)
+	}
+	if _, err = backends.Users.Create("admin", "secret", "Admin"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err = NewDBPEMStorer(store.DB(), "federation").WritePEM("kid1", []byte("PRIVATE KEY")); err != nil {
+		t.Fatalf("Failed to write pem: %v", err)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	src := newBackupTestStorage(t, "src")
+	fillBackupTestStorage(t, src)
+
+	backup, err := CreateBackup(src.DB(), BackupOptions{Passphrase: "passphrase"})
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	data, err := json.Marshal(backup)
+	if err != nil {
+		t.Fatalf("Failed to marshal backup: %v", err)
+	}
+	var decoded Backup
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal backup: %v", err)
+	}
+	for _, table := range decoded.Tables {
+		if table.Name != "private_keys_federation" {
+			continue
+		}
+		if len(table.Rows) != 1 || string(table.Rows[0]["pem_data"]) == `"UFJJVkFURSBLRVk="` {
+			t.Error("Expected private key to be encrypted")
+		}
+	}
+
+	t.Run(
+		"Success", func(t *testing.T) {
+			dst := newBackupTestStorage(t, "dst")
+			if err := RestoreBackup(dst.DB(), &decoded, RestoreOptions{Passphrase: "passphrase"}); err != nil {
+				t.Fatalf("RestoreBackup failed: %v", err)
+			}
+			backends := dst.Backends()
+
+			info, err := backends.Subordinates.Get("https://a.example.org")
+			if err != nil || info == nil {
+				t.Fatalf("Failed to get restored subordinate: %v", err)
+			}
+			if info.JWKS.Keys.Len() != 1 || len(info.SubordinateEntityTypes) != 1 || len(info.Labels) != 1 ||
+				len(info.SubordinateAdditionalClaims) != 1 {
+				t.Errorf("Restored subordinate is incomplete: %+v", info)
+			}
+			if deleted, _ := backends.Subordinates.Get("https://deleted.example.org"); deleted != nil {
+				t.Error("Expected soft-deleted subordinate to stay deleted")
+			}
+			var count int64
+			dst.DB().Unscoped().Model(&model.ExtendedSubordinateInfo{}).Count(&count)
+			if count != 2 {
+				t.Errorf("Expected 2 subordinate rows including the soft-deleted one, got %d", count)
+			}
+
+			issuers, err := backends.TrustMarkTypes.ListIssuers("https://tm.example.org")
+			if err != nil || len(issuers) != 1 {
+				t.Errorf("Expected 1 restored trust mark issuer, got %d (%v)", len(issuers), err)
+			}
+			if _, err = backends.Users.Authenticate("admin", "secret"); err != nil {
+				t.Errorf("Failed to authenticate restored user: %v", err)
+			}
+			pem, err := NewDBPEMStorer(dst.DB(), "federation").ReadPEM("kid1")
+			if err != nil || string(pem) != "PRIVATE KEY" {
+				t.Errorf("Expected decrypted private key, got %q (%v)", pem, err)
+			}
+
+			// New rows must not conflict with restored IDs
+			if err = backends.Subordinates.Add(
+				model.ExtendedSubordinateInfo{
+					BasicSubordinateInfo: model.BasicSubordinateInfo{
+						EntityID: "https://b.example.org",
+						Status:   model.StatusPending,
+					},
+				},
+			); err != nil {
+				t.Errorf("Failed to add subordinate after restore: %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"NotEmpty", func(t *testing.T) {
+			dst := newBackupTestStorage(t, "dst")
+			fillBackupTestStorage(t, dst)
+			if err := RestoreBackup(dst.DB(), &decoded, RestoreOptions{Passphrase: "passphrase"}); err == nil {
+				t.Fatal("Expected restore into non-empty database to fail")
+			}
+			if err := RestoreBackup(
+				dst.DB(), &decoded, RestoreOptions{
+					Passphrase: "passphrase",
+					Clear:      true,
+				},
+			); err != nil {
+				t.Fatalf("RestoreBackup with clear failed: %v", err)
+			}
+		},
+	)
+
+	t.Run(
+		"WrongPassphrase", func(t *testing.T) {
+			dst := newBackupTestStorage(t, "dst")
+			if err := RestoreBackup(dst.DB(), &decoded, RestoreOptions{Passphrase: "wrong"}); err == nil {
+				t.Fatal("Expected restore with wrong passphrase to fail")
+			}
+			info, _ := dst.Backends().Subordinates.Get("https://a.example.org")
+			if info != nil {
+				t.Error("Expected failed restore to be rolled back")
+			}
+		},
+	)
+
+	t.Run(
+		"NewerSchemaVersion", func(t *testing.T) {
+			dst := newBackupTestStorage(t, "dst")
+			newer := decoded
+			newer.SchemaVersion = SchemaVersion + 1
+			if err := RestoreBackup(dst.DB(), &newer, RestoreOptions{Passphrase: "passphrase"}); err == nil {
+				t.Fatal("Expected restore of newer schema version to fail")
+			}
+		},
+	)
+
+	t.Run(
+		"MissingSchemaVersion", func(t *testing.T) {
+			dst := newBackupTestStorage(t, "dst")
+			unknown := decoded
+			unknown.SchemaVersion = 0
+			if err := RestoreBackup(dst.DB(), &unknown, RestoreOptions{Passphrase: "passphrase"}); err == nil {
+				t.Fatal("Expected restore without schema version to fail")
+			}
+		},
+	)
+
+	t.Run(
+		"OlderSchemaVersion", func(t *testing.T) {
+			dst := newBackupTestStorage(t, "dst")
+			older := decoded
+			older.SchemaVersion = minRestorableSchemaVersion
+			if err := RestoreBackup(dst.DB(), &older, RestoreOptions{Passphrase: "passphrase"}); err != nil {
+				t.Fatalf("Expected restore of older compatible schema version to succeed: %v", err)
+			}
+		},
+	)
+}
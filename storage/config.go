@@ -13,6 +13,7 @@ import (
 	"gorm.io/gorm/logger"
 
 	"github.com/go-oidfed/lighthouse/storage/model"
+	"github.com/go-oidfed/lighthouse/tracing"
 )
 
 // DriverType represents the type of database driver
@@ -116,6 +117,8 @@ type Config struct {
 	DataDir string `yaml:"data_dir"`
 	// Debug enables debug logging
 	Debug bool `yaml:"debug"`
+	// Tracing records an OpenTelemetry span for each database operation
+	Tracing bool `yaml:"-"`
 	// UsersHash defines parameters for hashing admin user passwords
 	UsersHash Argon2idParams
 }
@@ -171,12 +174,21 @@ func Connect(cfg Config) (*gorm.DB, error) {
 		logMode = logger.Info
 	}
 
-	return gorm.Open(
+	db, err := gorm.Open(
 		dialector, &gorm.Config{
 			Logger:                 logger.Default.LogMode(logMode),
 			SkipDefaultTransaction: true,
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Tracing {
+		if err = db.Use(tracing.NewGormPlugin()); err != nil {
+			return nil, errors.Wrap(err, "failed to enable database tracing")
+		}
+	}
+	return db, nil
 }
 
 // LoadStorageBackends initializes a warehouse and returns grouped backends.
@@ -201,6 +213,7 @@ func (s *Storage) backendsWithDB(db *gorm.DB, withTransaction bool) model.Backen
 		DB:                  db,
 		Subordinates:        &SubordinateStorage{db: db},
 		SubordinateEvents:   NewSubordinateEventsStorage(db),
+		KeyEvents:           NewKeyEventsStorage(db),
 		TrustMarks:          &TrustMarkedEntitiesStorage{db: db},
 		TrustMarkSpecs:      &TrustMarkSpecStorage{db: db},
 		TrustMarkInstances:  NewIssuedTrustMarkInstanceStorage(db),
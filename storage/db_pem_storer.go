@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"bytes"
+
 	"github.com/go-oidfed/lib/jwx/keymanagement/public"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
@@ -10,9 +12,12 @@ import (
 )
 
 // DBPEMStorer implements kms.PEMStorer using a database backend.
+// If a KEKProvider is set, private keys are stored with envelope encryption;
+// private keys that were stored unencrypted can still be read.
 type DBPEMStorer struct {
 	db  *gorm.DB
 	tbl string
+	kek KEKProvider
 }
 
 // NewDBPEMStorer creates a new DBPEMStorer.
@@ -32,6 +37,15 @@ func NewDBPEMStorer(db *gorm.DB, typeID string) *DBPEMStorer {
 	return d
 }
 
+// NewEncryptedDBPEMStorer creates a new DBPEMStorer that encrypts private
+// keys with data keys wrapped by the key-encryption keys of the passed
+// KEKProvider.
+func NewEncryptedDBPEMStorer(db *gorm.DB, typeID string, kek KEKProvider) *DBPEMStorer {
+	d := NewDBPEMStorer(db, typeID)
+	d.kek = kek
+	return d
+}
+
 // Load initializes the database table.
 func (d *DBPEMStorer) Load() error {
 	return d.db.Table(d.tbl).AutoMigrate(&model.PrivateKeyEntry{})
@@ -47,11 +61,24 @@ func (d *DBPEMStorer) ReadPEM(kid string) ([]byte, error) {
 		}
 		return nil, errors.WithStack(err)
 	}
-	return []byte(row.PEMData), nil
+	if !IsEncryptedPEM(row.PEMData) {
+		return []byte(row.PEMData), nil
+	}
+	if d.kek == nil {
+		return nil, errors.Errorf("private key '%s' is encrypted, but no key-encryption key is configured", kid)
+	}
+	pem, err := decryptPEM(d.kek, kid, row.PEMData)
+	return pem, errors.Wrapf(err, "private key '%s'", kid)
 }
 
 // WritePEM stores or updates a PEM-encoded private key by KID.
 func (d *DBPEMStorer) WritePEM(kid string, data []byte) error {
+	if d.kek != nil {
+		var err error
+		if data, err = encryptPEM(d.kek, kid, data); err != nil {
+			return err
+		}
+	}
 	entry := model.PrivateKeyEntry{
 		KID:     kid,
 		PEMData: model.PEMData(data),
@@ -89,3 +116,58 @@ func (d *DBPEMStorer) GetAll() ([]model.PrivateKeyEntry, error) {
 	}
 	return rows, err
 }
+
+// ReencryptResult holds the number of private keys changed by Reencrypt.
+type ReencryptResult struct {
+	// Encrypted is the number of unencrypted private keys that were
+	// encrypted.
+	Encrypted int
+	// Rekeyed is the number of private keys whose data key was wrapped
+	// with the current key-encryption key.
+	Rekeyed int
+}
+
+// Reencrypt encrypts all unencrypted private keys and re-keys all private
+// keys whose data key is wrapped with a previous key-encryption key. Re-keying
+// only re-wraps the data keys; the encrypted private keys are unchanged.
+// All changes are made in a single transaction.
+func (d *DBPEMStorer) Reencrypt() (res ReencryptResult, err error) {
+	if d.kek == nil {
+		return res, errors.New("no key-encryption key configured")
+	}
+	err = d.db.Transaction(
+		func(tx *gorm.DB) error {
+			var rows []model.PrivateKeyEntry
+			if err := tx.Table(d.tbl).Find(&rows).Error; err != nil {
+				return errors.WithStack(err)
+			}
+			for _, row := range rows {
+				var data []byte
+				var err error
+				if IsEncryptedPEM(row.PEMData) {
+					data, err = rewrapPEM(d.kek, row.PEMData)
+				} else {
+					data, err = encryptPEM(d.kek, row.KID, row.PEMData)
+				}
+				if err != nil {
+					return errors.Wrapf(err, "private key '%s'", row.KID)
+				}
+				if bytes.Equal(data, row.PEMData) {
+					continue
+				}
+				if err = tx.Table(d.tbl).Where("kid = ?", row.KID).Update(
+					"pem_data", model.PEMData(data),
+				).Error; err != nil {
+					return errors.WithStack(err)
+				}
+				if IsEncryptedPEM(row.PEMData) {
+					res.Rekeyed++
+				} else {
+					res.Encrypted++
+				}
+			}
+			return nil
+		},
+	)
+	return res, err
+}
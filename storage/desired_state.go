@@ -0,0 +1,804 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// DesiredState is a declarative description of the federation configuration.
+// Sections that are omitted (nil) are not managed and left untouched by a
+// sync; for list sections an empty list together with pruning deletes all
+// existing entries.
+type DesiredState struct {
+	Subordinates        []model.SubordinateExport   `json:"subordinates,omitempty"`
+	AuthorityHints      []model.AddAuthorityHint    `json:"authority_hints,omitempty"`
+	EntityConfiguration *DesiredEntityConfiguration `json:"entity_configuration,omitempty"`
+	Lifetimes           *DesiredLifetimes           `json:"lifetimes,omitempty"`
+	TrustMarkTypes      []DesiredTrustMarkType      `json:"trust_mark_types,omitempty"`
+	TrustMarkSpecs      []model.AddTrustMarkSpec    `json:"trust_mark_specs,omitempty"`
+}
+
+// DesiredEntityConfiguration describes the metadata and additional claims of
+// the entity configuration.
+type DesiredEntityConfiguration struct {
+	Metadata         map[string]map[string]any  `json:"metadata,omitempty"`
+	AdditionalClaims []model.AddAdditionalClaim `json:"additional_claims,omitempty"`
+}
+
+// DesiredLifetimes describes the lifetimes (in seconds) of the entity
+// configuration and of subordinate statements.
+type DesiredLifetimes struct {
+	EntityConfiguration  *int `json:"entity_configuration,omitempty"`
+	SubordinateStatement *int `json:"subordinate_statement,omitempty"`
+}
+
+// DesiredTrustMarkType describes a trust mark type together with its owner
+// and the entity IDs of its issuers.
+type DesiredTrustMarkType struct {
+	TrustMarkType string                 `json:"trust_mark_type"`
+	Description   string                 `json:"description,omitempty"`
+	Owner         *DesiredTrustMarkOwner `json:"owner,omitempty"`
+	Issuers       []string               `json:"issuers,omitempty"`
+}
+
+// DesiredTrustMarkOwner describes the owner of a trust mark type.
+type DesiredTrustMarkOwner struct {
+	EntityID string   `json:"entity_id"`
+	JWKS     jwx.JWKS `json:"jwks"`
+}
+
+// DesiredStateAction is the action a sync performs on a single object.
+type DesiredStateAction string
+
+const (
+	// DesiredStateActionCreate creates a missing object.
+	DesiredStateActionCreate DesiredStateAction = "create"
+	// DesiredStateActionUpdate changes an existing object.
+	DesiredStateActionUpdate DesiredStateAction = "update"
+	// DesiredStateActionDelete deletes an object that is not part of the
+	// desired state; only done when pruning.
+	DesiredStateActionDelete DesiredStateAction = "delete"
+)
+
+// Kinds of objects managed by a desired state sync.
+const (
+	DesiredStateKindSubordinate     = "subordinate"
+	DesiredStateKindAuthorityHint   = "authority_hint"
+	DesiredStateKindMetadata        = "entity_configuration_metadata"
+	DesiredStateKindAdditionalClaim = "entity_configuration_claim"
+	DesiredStateKindLifetime        = "lifetime"
+	DesiredStateKindTrustMarkType   = "trust_mark_type"
+	DesiredStateKindTrustMarkSpec   = "trust_mark_spec"
+)
+
+// Names of the lifetimes in DesiredLifetimes.
+const (
+	desiredStateLifetimeEntityConfiguration  = "entity_configuration"
+	desiredStateLifetimeSubordinateStatement = "subordinate_statement"
+)
+
+// DesiredStateChange is a single change of a DesiredStatePlan.
+type DesiredStateChange struct {
+	Kind   string             `json:"kind"`
+	Name   string             `json:"name"`
+	Action DesiredStateAction `json:"action"`
+}
+
+// DesiredStatePlan lists the changes needed to reach the desired state.
+type DesiredStatePlan struct {
+	Applied bool                 `json:"applied"`
+	Changes []DesiredStateChange `json:"changes"`
+}
+
+// Count returns the number of changes with the passed action.
+func (p DesiredStatePlan) Count(action DesiredStateAction) (n int) {
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return
+}
+
+// DesiredStateOptions configures PlanDesiredState and ApplyDesiredState.
+type DesiredStateOptions struct {
+	// Prune deletes objects of managed sections that are not part of the
+	// desired state.
+	Prune bool
+	// Actor is recorded in the subordinate events created by the sync.
+	Actor string
+}
+
+// ParseDesiredState parses a desired state from YAML or JSON. Unknown fields
+// are rejected and subordinates without a status get
+// model.DefaultSubordinateStatus.
+func ParseDesiredState(data []byte) (*DesiredState, error) {
+	if !json.Valid(data) {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, model.ValidationErrorFmt("invalid YAML: %s", err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, model.ValidationErrorFmt("invalid desired state: %s", err)
+		}
+	}
+	var state DesiredState
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&state); err != nil {
+		return nil, model.ValidationErrorFmt("invalid desired state: %s", err)
+	}
+
+	// Decode subordinates again, so defaults are applied as for imports
+	var raw struct {
+		Subordinates json.RawMessage `json:"subordinates"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, model.ValidationErrorFmt("invalid desired state: %s", err)
+	}
+	if state.Subordinates != nil {
+		records, err := DecodeSubordinateExports(raw.Subordinates)
+		if err != nil {
+			return nil, err
+		}
+		state.Subordinates = append([]model.SubordinateExport{}, records...)
+	}
+	if err := state.validate(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// validate checks the desired state for consistency.
+func (s *DesiredState) validate() error {
+	if err := validateSubordinateExports(s.Subordinates); err != nil {
+		return errors.Wrap(err, "subordinates")
+	}
+	if err := validateUniqueNames(
+		"authority_hints", "entity_id", s.AuthorityHints,
+		func(h model.AddAuthorityHint) string { return h.EntityID },
+	); err != nil {
+		return err
+	}
+	if ec := s.EntityConfiguration; ec != nil {
+		if err := validateUniqueNames(
+			"entity_configuration.additional_claims", "claim", ec.AdditionalClaims,
+			func(c model.AddAdditionalClaim) string { return c.Claim },
+		); err != nil {
+			return err
+		}
+	}
+	if l := s.Lifetimes; l != nil {
+		for name, v := range map[string]*int{
+			desiredStateLifetimeEntityConfiguration:  l.EntityConfiguration,
+			desiredStateLifetimeSubordinateStatement: l.SubordinateStatement,
+		} {
+			if v != nil && *v <= 0 {
+				return model.ValidationErrorFmt("lifetimes.%s: must be positive", name)
+			}
+		}
+	}
+	if err := validateUniqueNames(
+		"trust_mark_types", "trust_mark_type", s.TrustMarkTypes,
+		func(t DesiredTrustMarkType) string { return t.TrustMarkType },
+	); err != nil {
+		return err
+	}
+	owners := make(map[string]string)
+	for _, t := range s.TrustMarkTypes {
+		if t.Owner == nil {
+			continue
+		}
+		if t.Owner.EntityID == "" {
+			return model.ValidationErrorFmt("trust_mark_types (%s): owner without entity_id", t.TrustMarkType)
+		}
+		jwks := canonicalJSON(t.Owner.JWKS)
+		if other, ok := owners[t.Owner.EntityID]; ok && other != jwks {
+			return model.ValidationErrorFmt(
+				"trust_mark_types (%s): conflicting jwks for owner '%s'", t.TrustMarkType, t.Owner.EntityID,
+			)
+		}
+		owners[t.Owner.EntityID] = jwks
+	}
+	return validateUniqueNames(
+		"trust_mark_specs", "trust_mark_type", s.TrustMarkSpecs,
+		func(spec model.AddTrustMarkSpec) string { return spec.TrustMarkType },
+	)
+}
+
+// validateUniqueNames checks that all items have a non-empty and unique name.
+func validateUniqueNames[T any](section, field string, items []T, name func(T) string) error {
+	seen := make(map[string]struct{}, len(items))
+	for i, item := range items {
+		n := name(item)
+		if n == "" {
+			return model.ValidationErrorFmt("%s: entry %d: missing %s", section, i+1, field)
+		}
+		if _, ok := seen[n]; ok {
+			return model.ValidationErrorFmt("%s: duplicate %s '%s'", section, field, n)
+		}
+		seen[n] = struct{}{}
+	}
+	return nil
+}
+
+// PlanDesiredState computes the changes needed to reach the desired state
+// without changing anything.
+func PlanDesiredState(
+	backends model.Backends, state *DesiredState, opts DesiredStateOptions,
+) (*DesiredStatePlan, error) {
+	s := desiredStateSyncer{
+		tx:   &backends,
+		opts: opts,
+	}
+	if err := s.sync(state); err != nil {
+		return nil, err
+	}
+	return &s.plan, nil
+}
+
+// ApplyDesiredState applies the changes needed to reach the desired state
+// within a single transaction and returns the applied plan.
+func ApplyDesiredState(
+	backends model.Backends, state *DesiredState, opts DesiredStateOptions,
+) (*DesiredStatePlan, error) {
+	var plan DesiredStatePlan
+	err := backends.InTransaction(
+		func(tx *model.Backends) error {
+			s := desiredStateSyncer{
+				tx:    tx,
+				opts:  opts,
+				apply: true,
+			}
+			if err := s.sync(state); err != nil {
+				return err
+			}
+			plan = s.plan
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	plan.Applied = true
+	return &plan, nil
+}
+
+// desiredStateSyncer compares the desired state with the stored state and
+// records (and optionally applies) the needed changes.
+type desiredStateSyncer struct {
+	tx    *model.Backends
+	opts  DesiredStateOptions
+	apply bool
+	plan  DesiredStatePlan
+}
+
+// change records a change and executes fn if the syncer applies changes.
+func (s *desiredStateSyncer) change(kind, name string, action DesiredStateAction, fn func() error) error {
+	s.plan.Changes = append(
+		s.plan.Changes, DesiredStateChange{
+			Kind:   kind,
+			Name:   name,
+			Action: action,
+		},
+	)
+	if !s.apply {
+		return nil
+	}
+	if err := fn(); err != nil {
+		return errors.Wrapf(err, "failed to %s %s '%s'", action, kind, name)
+	}
+	return nil
+}
+
+func (s *desiredStateSyncer) sync(state *DesiredState) error {
+	s.plan.Changes = []DesiredStateChange{}
+	if state.Subordinates != nil {
+		if err := s.syncSubordinates(state.Subordinates); err != nil {
+			return err
+		}
+	}
+	if state.AuthorityHints != nil {
+		if err := s.syncAuthorityHints(state.AuthorityHints); err != nil {
+			return err
+		}
+	}
+	if ec := state.EntityConfiguration; ec != nil {
+		if ec.Metadata != nil {
+			if err := s.syncMetadata(ec.Metadata); err != nil {
+				return err
+			}
+		}
+		if ec.AdditionalClaims != nil {
+			if err := s.syncAdditionalClaims(ec.AdditionalClaims); err != nil {
+				return err
+			}
+		}
+	}
+	if state.Lifetimes != nil {
+		if err := s.syncLifetimes(*state.Lifetimes); err != nil {
+			return err
+		}
+	}
+	if state.TrustMarkTypes != nil {
+		if err := s.syncTrustMarkTypes(state.TrustMarkTypes); err != nil {
+			return err
+		}
+	}
+	if state.TrustMarkSpecs != nil {
+		return s.syncTrustMarkSpecs(state.TrustMarkSpecs)
+	}
+	return nil
+}
+
+func (s *desiredStateSyncer) syncSubordinates(desired []model.SubordinateExport) error {
+	existing, err := ExportSubordinates(s.tx.Subordinates, model.SubordinateFilter{})
+	if err != nil {
+		return err
+	}
+	current := make(map[string]model.SubordinateExport, len(existing))
+	for _, e := range existing {
+		current[e.EntityID] = e
+	}
+	importOpts := SubordinateImportOptions{
+		Mode:  SubordinateImportModeUpsert,
+		Actor: s.opts.Actor,
+	}
+	var report SubordinateImportReport
+	for _, d := range desired {
+		action := DesiredStateActionCreate
+		if e, ok := current[d.EntityID]; ok {
+			delete(current, d.EntityID)
+			if canonicalSubordinateExport(e) == canonicalSubordinateExport(d) {
+				continue
+			}
+			action = DesiredStateActionUpdate
+		}
+		if err = s.change(
+			DesiredStateKindSubordinate, d.EntityID, action, func() error {
+				return importSubordinate(s.tx, d, importOpts, &report)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	if !s.opts.Prune {
+		return nil
+	}
+	for _, e := range existing {
+		if _, ok := current[e.EntityID]; !ok {
+			continue
+		}
+		if err = s.change(
+			DesiredStateKindSubordinate, e.EntityID, DesiredStateActionDelete, func() error {
+				info, err := s.tx.Subordinates.Get(e.EntityID)
+				if err != nil {
+					return err
+				}
+				if err = s.tx.SubordinateEvents.DeleteBySubordinateID(info.ID); err != nil {
+					return err
+				}
+				return s.tx.Subordinates.Delete(e.EntityID)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalSubordinateExport returns a representation of the record that can
+// be compared independently of the order of entity types and claims.
+func canonicalSubordinateExport(e model.SubordinateExport) string {
+	e.RegisteredEntityTypes = slices.Sorted(slices.Values(e.RegisteredEntityTypes))
+	e.AdditionalClaims = slices.SortedFunc(
+		slices.Values(e.AdditionalClaims), func(a, b model.AddAdditionalClaim) int {
+			return strings.Compare(a.Claim, b.Claim)
+		},
+	)
+	if len(e.Labels) == 0 {
+		e.Labels = nil
+	}
+	if !e.HasKeys() {
+		e.JWKS = nil
+	}
+	return canonicalJSON(e)
+}
+
+func (s *desiredStateSyncer) syncAuthorityHints(desired []model.AddAuthorityHint) error {
+	existing, err := s.tx.AuthorityHints.List()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]model.AuthorityHint, len(existing))
+	for _, e := range existing {
+		current[e.EntityID] = e
+	}
+	for _, d := range desired {
+		e, ok := current[d.EntityID]
+		delete(current, d.EntityID)
+		switch {
+		case !ok:
+			err = s.change(
+				DesiredStateKindAuthorityHint, d.EntityID, DesiredStateActionCreate, func() error {
+					_, err := s.tx.AuthorityHints.Create(d)
+					return err
+				},
+			)
+		case e.Description != d.Description:
+			err = s.change(
+				DesiredStateKindAuthorityHint, d.EntityID, DesiredStateActionUpdate, func() error {
+					_, err := s.tx.AuthorityHints.Update(d.EntityID, d)
+					return err
+				},
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !s.opts.Prune {
+		return nil
+	}
+	for _, e := range existing {
+		if _, ok := current[e.EntityID]; !ok {
+			continue
+		}
+		if err = s.change(
+			DesiredStateKindAuthorityHint, e.EntityID, DesiredStateActionDelete, func() error {
+				return s.tx.AuthorityHints.Delete(e.EntityID)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *desiredStateSyncer) syncMetadata(desired map[string]map[string]any) error {
+	raw, err := s.tx.KV.Get(model.KeyValueScopeEntityConfiguration, model.KeyValueKeyMetadata)
+	if err != nil {
+		return err
+	}
+	action := DesiredStateActionCreate
+	if raw != nil {
+		var current any
+		if err = json.Unmarshal(raw, &current); err != nil {
+			return errors.New("invalid stored metadata")
+		}
+		if canonicalJSON(current) == canonicalJSON(desired) {
+			return nil
+		}
+		action = DesiredStateActionUpdate
+	}
+	return s.change(
+		DesiredStateKindMetadata, "metadata", action, func() error {
+			return s.tx.KV.SetAny(model.KeyValueScopeEntityConfiguration, model.KeyValueKeyMetadata, desired)
+		},
+	)
+}
+
+func (s *desiredStateSyncer) syncAdditionalClaims(desired []model.AddAdditionalClaim) error {
+	existing, err := s.tx.AdditionalClaims.List()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]model.EntityConfigurationAdditionalClaim, len(existing))
+	for _, e := range existing {
+		current[e.Claim] = e
+	}
+	for _, d := range desired {
+		e, ok := current[d.Claim]
+		delete(current, d.Claim)
+		switch {
+		case !ok:
+			err = s.change(
+				DesiredStateKindAdditionalClaim, d.Claim, DesiredStateActionCreate, func() error {
+					_, err := s.tx.AdditionalClaims.Create(d)
+					return err
+				},
+			)
+		case e.Crit != d.Crit || canonicalJSON(e.Value) != canonicalJSON(d.Value):
+			err = s.change(
+				DesiredStateKindAdditionalClaim, d.Claim, DesiredStateActionUpdate, func() error {
+					_, err := s.tx.AdditionalClaims.Update(d.Claim, d)
+					return err
+				},
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !s.opts.Prune {
+		return nil
+	}
+	for _, e := range existing {
+		if _, ok := current[e.Claim]; !ok {
+			continue
+		}
+		if err = s.change(
+			DesiredStateKindAdditionalClaim, e.Claim, DesiredStateActionDelete, func() error {
+				return s.tx.AdditionalClaims.Delete(e.Claim)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *desiredStateSyncer) syncLifetimes(desired DesiredLifetimes) error {
+	if desired.EntityConfiguration != nil {
+		current, err := GetEntityConfigurationLifetime(s.tx.KV)
+		if err != nil {
+			return err
+		}
+		d := time.Duration(*desired.EntityConfiguration) * time.Second
+		if current != d {
+			if err = s.change(
+				DesiredStateKindLifetime, desiredStateLifetimeEntityConfiguration, DesiredStateActionUpdate,
+				func() error {
+					return SetEntityConfigurationLifetime(s.tx.KV, d)
+				},
+			); err != nil {
+				return err
+			}
+		}
+	}
+	if desired.SubordinateStatement != nil {
+		current, err := GetSubordinateStatementLifetime(s.tx.KV)
+		if err != nil {
+			return err
+		}
+		seconds := *desired.SubordinateStatement
+		if current != time.Duration(seconds)*time.Second {
+			return s.change(
+				DesiredStateKindLifetime, desiredStateLifetimeSubordinateStatement, DesiredStateActionUpdate,
+				func() error {
+					return s.tx.KV.SetAny(
+						model.KeyValueScopeSubordinateStatement, model.KeyValueKeyLifetime, seconds,
+					)
+				},
+			)
+		}
+	}
+	return nil
+}
+
+func (s *desiredStateSyncer) syncTrustMarkTypes(desired []DesiredTrustMarkType) error {
+	existing, err := s.tx.TrustMarkTypes.List()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]model.TrustMarkType, len(existing))
+	for _, e := range existing {
+		current[e.TrustMarkType] = e
+	}
+	for _, d := range desired {
+		e, ok := current[d.TrustMarkType]
+		delete(current, d.TrustMarkType)
+		if !ok {
+			if err = s.change(
+				DesiredStateKindTrustMarkType, d.TrustMarkType, DesiredStateActionCreate, func() error {
+					item, err := s.tx.TrustMarkTypes.Create(
+						model.AddTrustMarkType{
+							TrustMarkType: d.TrustMarkType,
+							Description:   d.Description,
+						},
+					)
+					if err != nil {
+						return err
+					}
+					return s.applyTrustMarkTypeRelations(item, d)
+				},
+			); err != nil {
+				return err
+			}
+			continue
+		}
+		equal, err := s.trustMarkTypeEqual(e, d)
+		if err != nil {
+			return err
+		}
+		if equal {
+			continue
+		}
+		if err = s.change(
+			DesiredStateKindTrustMarkType, d.TrustMarkType, DesiredStateActionUpdate, func() error {
+				item, err := s.tx.TrustMarkTypes.Update(
+					d.TrustMarkType, model.AddTrustMarkType{
+						TrustMarkType: d.TrustMarkType,
+						Description:   d.Description,
+					},
+				)
+				if err != nil {
+					return err
+				}
+				return s.applyTrustMarkTypeRelations(item, d)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	if !s.opts.Prune {
+		return nil
+	}
+	for _, e := range existing {
+		if _, ok := current[e.TrustMarkType]; !ok {
+			continue
+		}
+		if err = s.change(
+			DesiredStateKindTrustMarkType, e.TrustMarkType, DesiredStateActionDelete, func() error {
+				return s.tx.TrustMarkTypes.Delete(e.TrustMarkType)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trustMarkTypeEqual reports whether the stored trust mark type, including
+// its owner and issuers, matches the desired one.
+func (s *desiredStateSyncer) trustMarkTypeEqual(e model.TrustMarkType, d DesiredTrustMarkType) (bool, error) {
+	if e.Description != d.Description {
+		return false, nil
+	}
+	if (e.OwnerID == nil) != (d.Owner == nil) {
+		return false, nil
+	}
+	if e.OwnerID != nil {
+		owner, err := s.tx.TrustMarkOwners.Get(strconv.FormatUint(uint64(*e.OwnerID), 10))
+		if err != nil {
+			return false, err
+		}
+		if owner.EntityID != d.Owner.EntityID || canonicalJSON(owner.JWKS.Keys) != canonicalJSON(d.Owner.JWKS) {
+			return false, nil
+		}
+	}
+	issuers, err := s.tx.TrustMarkTypes.ListIssuers(e.TrustMarkType)
+	if err != nil {
+		return false, err
+	}
+	current := make([]string, len(issuers))
+	for i, iss := range issuers {
+		current[i] = iss.Issuer
+	}
+	slices.Sort(current)
+	return slices.Equal(current, slices.Sorted(slices.Values(d.Issuers))), nil
+}
+
+// applyTrustMarkTypeRelations sets the owner and issuers of a trust mark type.
+// Owners are global and may be shared between types; they are linked or
+// created as needed, but never deleted.
+func (s *desiredStateSyncer) applyTrustMarkTypeRelations(item *model.TrustMarkType, d DesiredTrustMarkType) error {
+	if d.Owner == nil {
+		if item.OwnerID != nil {
+			ownerIdent := strconv.FormatUint(uint64(*item.OwnerID), 10)
+			if _, err := s.tx.TrustMarkOwners.DeleteType(ownerIdent, item.ID); err != nil {
+				return err
+			}
+		}
+	} else {
+		req := model.AddTrustMarkOwner{
+			EntityID: d.Owner.EntityID,
+			JWKS:     model.NewJWKS(d.Owner.JWKS),
+		}
+		owner, err := s.tx.TrustMarkOwners.Get(d.Owner.EntityID)
+		var nf model.NotFoundError
+		switch {
+		case errors.As(err, &nf):
+			owner, err = s.tx.TrustMarkOwners.Create(req)
+		case err == nil && canonicalJSON(owner.JWKS.Keys) != canonicalJSON(d.Owner.JWKS):
+			owner, err = s.tx.TrustMarkOwners.Update(d.Owner.EntityID, req)
+		}
+		if err != nil {
+			return err
+		}
+		if item.OwnerID == nil || *item.OwnerID != owner.ID {
+			if _, err = s.tx.TrustMarkOwners.AddType(d.Owner.EntityID, item.ID); err != nil {
+				return err
+			}
+		}
+	}
+	issuers := make([]model.AddTrustMarkIssuer, len(d.Issuers))
+	for i, iss := range d.Issuers {
+		issuers[i] = model.AddTrustMarkIssuer{Issuer: iss}
+	}
+	_, err := s.tx.TrustMarkTypes.SetIssuers(d.TrustMarkType, issuers)
+	return err
+}
+
+func (s *desiredStateSyncer) syncTrustMarkSpecs(desired []model.AddTrustMarkSpec) error {
+	existing, err := s.tx.TrustMarkSpecs.List()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]model.TrustMarkSpec, len(existing))
+	for _, e := range existing {
+		current[e.TrustMarkType] = e
+	}
+	for _, d := range desired {
+		e, ok := current[d.TrustMarkType]
+		delete(current, d.TrustMarkType)
+		switch {
+		case !ok:
+			err = s.change(
+				DesiredStateKindTrustMarkSpec, d.TrustMarkType, DesiredStateActionCreate, func() error {
+					_, err := s.tx.TrustMarkSpecs.Create(&d)
+					return err
+				},
+			)
+		case canonicalJSON(addTrustMarkSpecFromStored(e)) != canonicalJSON(d):
+			err = s.change(
+				DesiredStateKindTrustMarkSpec, d.TrustMarkType, DesiredStateActionUpdate, func() error {
+					_, err := s.tx.TrustMarkSpecs.Update(d.TrustMarkType, &d)
+					return err
+				},
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !s.opts.Prune {
+		return nil
+	}
+	for _, e := range existing {
+		if _, ok := current[e.TrustMarkType]; !ok {
+			continue
+		}
+		if err = s.change(
+			DesiredStateKindTrustMarkSpec, e.TrustMarkType, DesiredStateActionDelete, func() error {
+				return s.tx.TrustMarkSpecs.Delete(e.TrustMarkType)
+			},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTrustMarkSpecFromStored converts a stored spec into the comparable
+// request representation.
+func addTrustMarkSpecFromStored(spec model.TrustMarkSpec) model.AddTrustMarkSpec {
+	return model.AddTrustMarkSpec{
+		TrustMarkType:     spec.TrustMarkType,
+		Lifetime:          spec.Lifetime,
+		Ref:               spec.Ref,
+		LogoURI:           spec.LogoURI,
+		DelegationJWT:     spec.DelegationJWT,
+		AdditionalClaims:  spec.AdditionalClaims,
+		Description:       spec.Description,
+		EligibilityConfig: spec.EligibilityConfig,
+		CacheTTL:          spec.CacheTTL,
+	}
+}
+
+// canonicalJSON returns a JSON representation of v with sorted object keys,
+// so that semantically equal values compare equal.
+func canonicalJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	var generic any
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return string(data)
+	}
+	data, _ = json.Marshal(generic)
+	return string(data)
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+const testDesiredStateYAML = `
+subordinates:
+  - entity_id: https://rp.example.org
+    status: pending
+    description: managed RP
+    registered_entity_types: [openid_relying_party]
+    labels:
+      project: alpha
+authority_hints:
+  - entity_id: https://ta.example.org
+    description: trust anchor
+entity_configuration:
+  metadata:
+    federation_entity:
+      organization_name: Example
+  additional_claims:
+    - claim: custom
+      value: [1, 2]
+lifetimes:
+  entity_configuration: 3600
+  subordinate_statement: 7200
+trust_mark_types:
+  - trust_mark_type: https://tm.example.org
+    description: example trust mark
+    issuers: [https://issuer.example.org]
+trust_mark_specs:
+  - trust_mark_type: https://tm.example.org
+    lifetime: 86400
+`
+
+func newDesiredStateTestBackends(t *testing.T) model.Backends {
+	t.Helper()
+	backends := newBackupTestStorage(t, "state").Backends()
+	if err := backends.Subordinates.Add(
+		model.ExtendedSubordinateInfo{
+			BasicSubordinateInfo: model.BasicSubordinateInfo{
+				EntityID: "https://unmanaged.example.org",
+				Status:   model.StatusPending,
+			},
+		},
+	); err != nil {
+		t.Fatalf("Failed to add subordinate: %v", err)
+	}
+	if _, err := backends.AuthorityHints.Create(model.AddAuthorityHint{EntityID: "https://old-ta.example.org"}); err != nil {
+		t.Fatalf("Failed to create authority hint: %v", err)
+	}
+	return backends
+}
+
+func TestParseDesiredState(t *testing.T) {
+	t.Parallel()
+	t.Run(
+		"YAML", func(t *testing.T) {
+			t.Parallel()
+			state, err := ParseDesiredState([]byte(testDesiredStateYAML))
+			if err != nil {
+				t.Fatalf("ParseDesiredState failed: %v", err)
+			}
+			if len(state.Subordinates) != 1 || state.Subordinates[0].Status != model.StatusPending {
+				t.Errorf("Expected 1 pending subordinate, got %+v", state.Subordinates)
+			}
+			if state.Lifetimes == nil || *state.Lifetimes.EntityConfiguration != 3600 {
+				t.Errorf("Unexpected lifetimes: %+v", state.Lifetimes)
+			}
+			if state.TrustMarkSpecs[0].Lifetime != 86400 {
+				t.Errorf("Unexpected trust mark specs: %+v", state.TrustMarkSpecs)
+			}
+		},
+	)
+	t.Run(
+		"JSON", func(t *testing.T) {
+			t.Parallel()
+			state, err := ParseDesiredState([]byte(`{"authority_hints":[]}`))
+			if err != nil {
+				t.Fatalf("ParseDesiredState failed: %v", err)
+			}
+			if state.AuthorityHints == nil || state.Subordinates != nil {
+				t.Error("Expected only authority hints to be managed")
+			}
+		},
+	)
+	for name, data := range map[string]string{
+		"UnknownField":        `{"authority_hint":[]}`,
+		"DuplicateHint":       `{"authority_hints":[{"entity_id":"https://a"},{"entity_id":"https://a"}]}`,
+		"ActiveWithoutKeys":   `{"subordinates":[{"entity_id":"https://a"}]}`,
+		"NonPositiveLifetime": `{"lifetimes":{"entity_configuration":0}}`,
+	} {
+		t.Run(
+			name, func(t *testing.T) {
+				t.Parallel()
+				if _, err := ParseDesiredState([]byte(data)); err == nil {
+					t.Error("Expected validation error")
+				}
+			},
+		)
+	}
+}
+
+func TestDesiredStateSync(t *testing.T) {
+	t.Parallel()
+	state, err := ParseDesiredState([]byte(testDesiredStateYAML))
+	if err != nil {
+		t.Fatalf("ParseDesiredState failed: %v", err)
+	}
+
+	t.Run(
+		"PlanDoesNotChangeAnything", func(t *testing.T) {
+			t.Parallel()
+			backends := newDesiredStateTestBackends(t)
+			plan, err := PlanDesiredState(backends, state, DesiredStateOptions{Prune: true})
+			if err != nil {
+				t.Fatalf("PlanDesiredState failed: %v", err)
+			}
+			if plan.Applied {
+				t.Error("Expected plan to not be applied")
+			}
+			// 1 subordinate, 1 hint, metadata, 1 claim, 2 lifetimes, 1 type, 1 spec
+			if n := plan.Count(DesiredStateActionCreate); n != 6 {
+				t.Errorf("Expected 6 creates, got %d: %+v", n, plan.Changes)
+			}
+			if n := plan.Count(DesiredStateActionUpdate); n != 2 {
+				t.Errorf("Expected 2 updates, got %d: %+v", n, plan.Changes)
+			}
+			if n := plan.Count(DesiredStateActionDelete); n != 2 {
+				t.Errorf("Expected 2 deletes, got %d: %+v", n, plan.Changes)
+			}
+			if info, _ := backends.Subordinates.Get("https://rp.example.org"); info != nil {
+				t.Error("Expected plan to not create subordinates")
+			}
+		},
+	)
+
+	t.Run(
+		"ApplyIsIdempotent", func(t *testing.T) {
+			t.Parallel()
+			backends := newDesiredStateTestBackends(t)
+			if _, err := ApplyDesiredState(backends, state, DesiredStateOptions{Actor: "test"}); err != nil {
+				t.Fatalf("ApplyDesiredState failed: %v", err)
+			}
+
+			info, err := backends.Subordinates.Get("https://rp.example.org")
+			if err != nil || info == nil {
+				t.Fatalf("Failed to get subordinate: %v", err)
+			}
+			if info.Description != "managed RP" || info.Labels.Map()["project"] != "alpha" {
+				t.Errorf("Unexpected subordinate: %+v", info.BasicSubordinateInfo)
+			}
+			if unmanaged, _ := backends.Subordinates.Get("https://unmanaged.example.org"); unmanaged == nil {
+				t.Error("Expected unmanaged subordinate to be kept without pruning")
+			}
+			issuers, err := backends.TrustMarkTypes.ListIssuers("https://tm.example.org")
+			if err != nil || len(issuers) != 1 {
+				t.Errorf("Expected 1 trust mark issuer, got %d (%v)", len(issuers), err)
+			}
+			if _, err = backends.TrustMarkSpecs.GetByType("https://tm.example.org"); err != nil {
+				t.Errorf("Failed to get trust mark spec: %v", err)
+			}
+
+			plan, err := PlanDesiredState(backends, state, DesiredStateOptions{})
+			if err != nil {
+				t.Fatalf("PlanDesiredState failed: %v", err)
+			}
+			if len(plan.Changes) != 0 {
+				t.Errorf("Expected no changes after apply, got %+v", plan.Changes)
+			}
+		},
+	)
+
+	t.Run(
+		"Prune", func(t *testing.T) {
+			t.Parallel()
+			backends := newDesiredStateTestBackends(t)
+			if _, err := ApplyDesiredState(backends, state, DesiredStateOptions{Prune: true}); err != nil {
+				t.Fatalf("ApplyDesiredState failed: %v", err)
+			}
+			if unmanaged, _ := backends.Subordinates.Get("https://unmanaged.example.org"); unmanaged != nil {
+				t.Error("Expected unmanaged subordinate to be pruned")
+			}
+			hints, err := backends.AuthorityHints.List()
+			if err != nil || len(hints) != 1 || hints[0].EntityID != "https://ta.example.org" {
+				t.Errorf("Expected only the desired authority hint, got %+v (%v)", hints, err)
+			}
+		},
+	)
+
+	t.Run(
+		"UpdateTrustMarkType", func(t *testing.T) {
+			t.Parallel()
+			backends := newDesiredStateTestBackends(t)
+			if _, err := ApplyDesiredState(backends, state, DesiredStateOptions{}); err != nil {
+				t.Fatalf("ApplyDesiredState failed: %v", err)
+			}
+			changed, err := ParseDesiredState(
+				[]byte(`{"trust_mark_types":[{"trust_mark_type":"https://tm.example.org","description":"changed"}]}`),
+			)
+			if err != nil {
+				t.Fatalf("ParseDesiredState failed: %v", err)
+			}
+			plan, err := ApplyDesiredState(backends, changed, DesiredStateOptions{})
+			if err != nil {
+				t.Fatalf("ApplyDesiredState failed: %v", err)
+			}
+			if plan.Count(DesiredStateActionUpdate) != 1 {
+				t.Errorf("Expected 1 update, got %+v", plan.Changes)
+			}
+			tmt, err := backends.TrustMarkTypes.Get("https://tm.example.org")
+			if err != nil || tmt.Description != "changed" {
+				t.Errorf("Expected description to be updated, got %+v (%v)", tmt, err)
+			}
+			issuers, err := backends.TrustMarkTypes.ListIssuers("https://tm.example.org")
+			if err != nil || len(issuers) != 0 {
+				t.Errorf("Expected issuers to be removed, got %d (%v)", len(issuers), err)
+			}
+		},
+	)
+}
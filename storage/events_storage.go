@@ -25,29 +25,29 @@ func (s *SubordinateEventsStorage) Add(event model.SubordinateEvent) error {
 	return nil
 }
 
-// GetBySubordinateID returns events for a subordinate with optional filtering and pagination.
-// Returns the events, total count (for pagination), and any error.
+// GetBySubordinateID returns a single page of events for a subordinate with
+// optional filtering.
 func (s *SubordinateEventsStorage) GetBySubordinateID(
 	subordinateID uint, opts model.EventQueryOpts,
-) ([]model.SubordinateEvent, int64, error) {
-	// Apply defaults
-	limit := opts.Limit
-	if limit <= 0 {
-		limit = 50
-	}
-	if limit > 100 {
-		limit = 100
-	}
-
-	offset := opts.Offset
-	if offset < 0 {
-		offset = 0
+) ([]model.SubordinateEvent, model.PageInfo, error) {
+	events, page, err := findEventPage(
+		s.db.Model(&model.SubordinateEvent{}).Where("subordinate_id = ?", subordinateID), opts,
+		listColumns{search: []string{"type", "message", "actor"}},
+		func(e model.SubordinateEvent) model.ListCursor {
+			return listCursor(opts.Sort, e.ID, e.CreatedAt, e.UpdatedAt, "")
+		},
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "subordinate_events: failed to get events")
 	}
+	return events, page, nil
+}
 
-	// Build query
-	query := s.db.Model(&model.SubordinateEvent{}).Where("subordinate_id = ?", subordinateID)
-
-	// Apply filters
+// findEventPage applies the event filters to the query and loads a single
+// page of events. The limit defaults to 50 and is capped at 100.
+func findEventPage[T any](
+	query *gorm.DB, opts model.EventQueryOpts, columns listColumns, cursorOf func(T) model.ListCursor,
+) ([]T, model.PageInfo, error) {
 	if opts.EventType != nil && *opts.EventType != "" {
 		query = query.Where("type = ?", *opts.EventType)
 	}
@@ -57,23 +57,19 @@ func (s *SubordinateEventsStorage) GetBySubordinateID(
 	if opts.ToTime != nil {
 		query = query.Where("timestamp <= ?", *opts.ToTime)
 	}
+	list := opts.ListOptions
+	list.Limit = NormalizeEventLimit(list.Limit)
+	list.Offset = max(list.Offset, 0)
+	return findPage(query, list, columns, cursorOf)
+}
 
-	// Get total count before pagination
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, errors.Wrap(err, "subordinate_events: failed to count events")
-	}
-
-	// Apply pagination and ordering (newest first)
-	var events []model.SubordinateEvent
-	if err := query.Order("timestamp DESC, id DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&events).Error; err != nil {
-		return nil, 0, errors.Wrap(err, "subordinate_events: failed to get events")
+// NormalizeEventLimit returns the number of events returned for the
+// requested limit: 50 if no limit is set, and at most 100.
+func NormalizeEventLimit(limit int) int {
+	if limit <= 0 {
+		return 50
 	}
-
-	return events, total, nil
+	return min(limit, 100)
 }
 
 // DeleteBySubordinateID removes all events for a subordinate.
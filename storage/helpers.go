@@ -116,39 +116,46 @@ func GetEntityConfigurationAdditionalClaims(store model.AdditionalClaimsStore) (
 
 var DefaultSigningAlg = jwa.ES512()
 
-// sortAlgsByNbf sorts signing algorithms by their not-before time.
-// Algorithms with nil Nbf come first, then sorted by Nbf ascending.
-func sortAlgsByNbf(algs []SigningAlgWithNbf) {
+// nbfSetting is a stored signing setting with a not-before time.
+type nbfSetting interface {
+	notBefore() *unixtime.Unixtime
+}
+
+// sortAlgsByNbf sorts signing settings by their not-before time.
+// Settings with nil Nbf come first, then sorted by Nbf ascending.
+func sortAlgsByNbf[T nbfSetting](algs []T) {
 	slices.SortFunc(
-		algs, func(a, b SigningAlgWithNbf) int {
-			if a.Nbf == nil && b.Nbf != nil {
+		algs, func(a, b T) int {
+			aNbf, bNbf := a.notBefore(), b.notBefore()
+			if aNbf == nil && bNbf != nil {
 				return -1
 			}
-			if b.Nbf == nil && a.Nbf != nil {
+			if bNbf == nil && aNbf != nil {
 				return 1
 			}
-			if a.Nbf == nil && b.Nbf == nil {
+			if aNbf == nil && bNbf == nil {
 				return 0
 			}
-			return cmp.Compare(a.Nbf.UnixNano(), b.Nbf.UnixNano())
+			return cmp.Compare(aNbf.UnixNano(), bNbf.UnixNano())
 		},
 	)
 }
 
-// findCurrentAlgIndex finds the index of the currently active algorithm.
-// Returns -1 if no current algorithm is found.
-func findCurrentAlgIndex(algs []SigningAlgWithNbf, now time.Time) int {
+// findCurrentAlgIndex finds the index of the currently active setting.
+// Returns -1 if no current setting is found.
+func findCurrentAlgIndex[T nbfSetting](algs []T, now time.Time) int {
 	currentIndex := -1
 	for i, a := range algs {
-		if a.Nbf != nil && a.Nbf.Before(now) {
+		nbf := a.notBefore()
+		if nbf != nil && nbf.Before(now) {
 			currentIndex = i
 		}
-		if currentIndex != -1 && a.Nbf != nil && a.Nbf.After(now) {
+		if currentIndex != -1 && nbf != nil && nbf.After(now) {
 			break
 		}
 	}
-	// Check if first algorithm has no Nbf (always valid)
-	if currentIndex == -1 && len(algs) > 0 && algs[0].Nbf == nil {
+	// Check if first setting has no Nbf (always valid)
+	if currentIndex == -1 && len(algs) > 0 && algs[0].notBefore() == nil {
 		currentIndex = 0
 	}
 	return currentIndex
@@ -202,6 +209,10 @@ type SigningAlgWithNbf struct {
 	Nbf        *unixtime.Unixtime
 }
 
+func (a SigningAlgWithNbf) notBefore() *unixtime.Unixtime {
+	return a.Nbf
+}
+
 // SetSigningAlg sets the signing algorithm
 func SetSigningAlg(kvStorage model.KeyValueStore, alg SigningAlgWithNbf) error {
 	if kvStorage == nil {
@@ -215,6 +226,83 @@ func SetSigningAlg(kvStorage model.KeyValueStore, alg SigningAlgWithNbf) error {
 	return kvStorage.SetAny(model.KeyValueScopeSigning, model.KeyValueKeyAlg, append(stored, alg))
 }
 
+// SigningAlgsWithNbf is a set of signing algorithms with a not-before time
+// used for database storage
+type SigningAlgsWithNbf struct {
+	SigningAlgs []string
+	Nbf         *unixtime.Unixtime
+}
+
+func (a SigningAlgsWithNbf) notBefore() *unixtime.Unixtime {
+	return a.Nbf
+}
+
+// GetSigningAlgs returns the set of signing algorithms for which keys are
+// published. The default signing algorithm (see GetSigningAlg) is always part
+// of the returned set and is the first element. If no set was stored, only the
+// default signing algorithm is returned.
+func GetSigningAlgs(kvStorage model.KeyValueStore) ([]jwa.SignatureAlgorithm, error) {
+	defaultAlg, err := GetSigningAlg(kvStorage)
+	if err != nil {
+		return nil, err
+	}
+	algs := []jwa.SignatureAlgorithm{defaultAlg}
+	if kvStorage == nil {
+		return algs, nil
+	}
+	var stored []SigningAlgsWithNbf
+	found, err := kvStorage.GetAs(
+		model.KeyValueScopeSigning,
+		model.KeyValueKeyAlgs, &stored,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return algs, nil
+	}
+
+	sortAlgsByNbf(stored)
+	currentIndex := findCurrentAlgIndex(stored, time.Now())
+	if currentIndex == -1 {
+		// Only future sets stored
+		return algs, nil
+	}
+	for _, alg := range stored[currentIndex].SigningAlgs {
+		a, ok := jwa.LookupSignatureAlgorithm(alg)
+		if !ok {
+			return nil, errors.Errorf("invalid signing algorithm: %s", alg)
+		}
+		if !slices.ContainsFunc(
+			algs, func(b jwa.SignatureAlgorithm) bool { return b.String() == a.String() },
+		) {
+			algs = append(algs, a)
+		}
+	}
+
+	// Clean up expired sets
+	if err = kvStorage.SetAny(
+		model.KeyValueScopeSigning,
+		model.KeyValueKeyAlgs, stored[currentIndex:],
+	); err != nil {
+		log.WithError(err).Error("failed to remove expired signing algorithm sets")
+	}
+	return algs, nil
+}
+
+// SetSigningAlgs sets the set of signing algorithms
+func SetSigningAlgs(kvStorage model.KeyValueStore, algs SigningAlgsWithNbf) error {
+	if kvStorage == nil {
+		return errors.New("key value store is not set")
+	}
+	var stored []SigningAlgsWithNbf
+	_, err := kvStorage.GetAs(model.KeyValueScopeSigning, model.KeyValueKeyAlgs, &stored)
+	if err != nil {
+		return err
+	}
+	return kvStorage.SetAny(model.KeyValueScopeSigning, model.KeyValueKeyAlgs, append(stored, algs))
+}
+
 // GetRSAKeyLen returns the RSA key length
 func GetRSAKeyLen(kvStorage model.KeyValueStore) (int, error) {
 	const d = 2048
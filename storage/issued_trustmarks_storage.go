@@ -44,62 +44,109 @@ func (s *IssuedTrustMarkInstanceStorage) GetByJTI(jti string) (*model.IssuedTrus
 	return &instance, nil
 }
 
-// Revoke marks a trust mark instance as revoked.
-func (s *IssuedTrustMarkInstanceStorage) Revoke(jti string) error {
+// revocationUpdates returns the column updates to revoke instances.
+func revocationUpdates(revocation model.TrustMarkRevocation, now int) map[string]any {
+	return map[string]any{
+		"revoked":           true,
+		"revoked_at":        now,
+		"revocation_reason": revocation.Reason,
+		"revoked_by":        revocation.Actor,
+		"updated_at":        now,
+	}
+}
+
+// Revoke marks a trust mark instance as revoked and returns it.
+// Revoking an already revoked instance keeps the original revocation;
+// revoked is false in that case.
+func (s *IssuedTrustMarkInstanceStorage) Revoke(
+	jti string, revocation model.TrustMarkRevocation,
+) (*model.IssuedTrustMarkInstance, bool, error) {
 	result := s.db.Model(&model.IssuedTrustMarkInstance{}).
-		Where("jti = ?", jti).
-		Updates(map[string]any{
-			"revoked":    true,
-			"updated_at": int(time.Now().Unix()),
-		})
+		Where("jti = ? AND revoked = ?", jti, false).
+		Updates(revocationUpdates(revocation, int(time.Now().Unix())))
 	if result.Error != nil {
-		return errors.Wrap(result.Error, "issued_trust_mark_instances: revoke failed")
+		return nil, false, errors.Wrap(result.Error, "issued_trust_mark_instances: revoke failed")
 	}
-	if result.RowsAffected == 0 {
-		return model.NotFoundErrorFmt("trust mark instance not found: %s", jti)
+	instance, err := s.GetByJTI(jti)
+	if err != nil {
+		return nil, false, err
 	}
-	return nil
+	return instance, result.RowsAffected > 0, nil
 }
 
 // RevokeBySubjectID revokes all instances for a given TrustMarkSubjectID.
 // Returns the number of revoked instances.
-func (s *IssuedTrustMarkInstanceStorage) RevokeBySubjectID(subjectID uint) (int64, error) {
+func (s *IssuedTrustMarkInstanceStorage) RevokeBySubjectID(
+	subjectID uint, revocation model.TrustMarkRevocation,
+) (int64, error) {
 	result := s.db.Model(&model.IssuedTrustMarkInstance{}).
 		Where("trust_mark_subject_id = ? AND revoked = ?", subjectID, false).
-		Updates(map[string]any{
-			"revoked":    true,
-			"updated_at": int(time.Now().Unix()),
-		})
+		Updates(revocationUpdates(revocation, int(time.Now().Unix())))
 	if result.Error != nil {
 		return 0, errors.Wrap(result.Error, "issued_trust_mark_instances: revoke by subject ID failed")
 	}
 	return result.RowsAffected, nil
 }
 
+// RevokeActive revokes all active (non-revoked, non-expired) instances
+// matching the trust mark type and entity ID. An empty value matches all,
+// but at least one must be given. Returns the revoked instances.
+func (s *IssuedTrustMarkInstanceStorage) RevokeActive(
+	trustMarkType, entityID string, revocation model.TrustMarkRevocation,
+) ([]model.IssuedTrustMarkInstance, error) {
+	if trustMarkType == "" && entityID == "" {
+		return nil, model.ValidationError("trust mark type or subject is required")
+	}
+	var instances []model.IssuedTrustMarkInstance
+	now := int(time.Now().Unix())
+	err := s.db.Transaction(
+		func(tx *gorm.DB) error {
+			query := tx.Where("revoked = ? AND (expires_at = 0 OR expires_at > ?)", false, now)
+			if trustMarkType != "" {
+				query = query.Where("trust_mark_type = ?", trustMarkType)
+			}
+			if entityID != "" {
+				query = query.Where("subject = ?", entityID)
+			}
+			if err := query.Order("created_at, jti").Find(&instances).Error; err != nil {
+				return err
+			}
+			if len(instances) == 0 {
+				return nil
+			}
+			jtis := make([]string, len(instances))
+			for i := range instances {
+				jtis[i] = instances[i].JTI
+			}
+			if err := tx.Model(&model.IssuedTrustMarkInstance{}).
+				Where("jti IN ?", jtis).
+				Updates(revocationUpdates(revocation, now)).Error; err != nil {
+				return err
+			}
+			for i := range instances {
+				instances[i].Revoked = true
+				instances[i].RevokedAt = now
+				instances[i].RevocationReason = revocation.Reason
+				instances[i].RevokedBy = revocation.Actor
+				instances[i].UpdatedAt = now
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "issued_trust_mark_instances: revoke active failed")
+	}
+	return instances, nil
+}
+
 // GetStatus returns the status of a trust mark instance.
 // Status is determined by: revoked flag, expiration time, and existence.
 func (s *IssuedTrustMarkInstanceStorage) GetStatus(jti string) (model.TrustMarkInstanceStatus, error) {
 	instance, err := s.GetByJTI(jti)
 	if err != nil {
-		var notFound model.NotFoundError
-		if errors.As(err, &notFound) {
-			// Not found means we don't know about this trust mark
-			return "", err
-		}
 		return "", err
 	}
-
-	// Check revocation first
-	if instance.Revoked {
-		return model.TrustMarkStatusRevoked, nil
-	}
-
-	// Check expiration
-	if instance.ExpiresAt > 0 && int(time.Now().Unix()) > instance.ExpiresAt {
-		return model.TrustMarkStatusExpired, nil
-	}
-
-	return model.TrustMarkStatusActive, nil
+	return instance.Status(), nil
 }
 
 // ListBySubject returns all instances for a given trust mark type and subject.
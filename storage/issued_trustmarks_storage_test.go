@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+func newIssuedTrustMarksTestStorage(t *testing.T) *IssuedTrustMarkInstanceStorage {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", url.PathEscape(t.Name()))
+	db, err := Connect(
+		Config{
+			Driver: DriverSQLite,
+			DSN:    dsn,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err = db.AutoMigrate(&model.IssuedTrustMarkInstance{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return NewIssuedTrustMarkInstanceStorage(db)
+}
+
+func createTestInstance(t *testing.T, s *IssuedTrustMarkInstanceStorage, jti, trustMarkType, subject string, expiresAt int) {
+	t.Helper()
+	if err := s.Create(
+		&model.IssuedTrustMarkInstance{
+			JTI:           jti,
+			ExpiresAt:     expiresAt,
+			TrustMarkType: trustMarkType,
+			Subject:       subject,
+		},
+	); err != nil {
+		t.Fatalf("Create(%s) error: %v", jti, err)
+	}
+}
+
+func TestIssuedTrustMarksRevoke(t *testing.T) {
+	s := newIssuedTrustMarksTestStorage(t)
+	createTestInstance(t, s, "jti-1", "https://tm.example.com", "https://rp.example.com", 0)
+
+	instance, revoked, err := s.Revoke("jti-1", model.TrustMarkRevocation{Reason: "compromised", Actor: "alice"})
+	if err != nil {
+		t.Fatalf("Revoke() error: %v", err)
+	}
+	if !revoked {
+		t.Error("expected Revoke() to report the revocation")
+	}
+	if !instance.Revoked || instance.RevokedAt == 0 {
+		t.Fatalf("expected revoked instance with timestamp, got %+v", instance)
+	}
+	if instance.RevocationReason != "compromised" || instance.RevokedBy != "alice" {
+		t.Errorf("unexpected revocation details: reason=%q by=%q", instance.RevocationReason, instance.RevokedBy)
+	}
+	if instance.Status() != model.TrustMarkStatusRevoked {
+		t.Errorf("Status() = %s, want %s", instance.Status(), model.TrustMarkStatusRevoked)
+	}
+
+	// Revoking again keeps the original revocation
+	instance, revoked, err = s.Revoke("jti-1", model.TrustMarkRevocation{Reason: "other", Actor: "bob"})
+	if err != nil {
+		t.Fatalf("second Revoke() error: %v", err)
+	}
+	if revoked {
+		t.Error("expected second Revoke() to report an already revoked instance")
+	}
+	if instance.RevocationReason != "compromised" || instance.RevokedBy != "alice" {
+		t.Errorf("revocation was overwritten: reason=%q by=%q", instance.RevocationReason, instance.RevokedBy)
+	}
+
+	_, _, err = s.Revoke("unknown", model.TrustMarkRevocation{})
+	var notFound model.NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected NotFoundError for unknown JTI, got %v", err)
+	}
+}
+
+func TestIssuedTrustMarksRevokeActive(t *testing.T) {
+	const (
+		typeA = "https://tm.example.com/a"
+		typeB = "https://tm.example.com/b"
+		rp1   = "https://rp1.example.com"
+		rp2   = "https://rp2.example.com"
+	)
+	s := newIssuedTrustMarksTestStorage(t)
+	future := int(time.Now().Add(time.Hour).Unix())
+	past := int(time.Now().Add(-time.Hour).Unix())
+	createTestInstance(t, s, "a-rp1-1", typeA, rp1, future)
+	createTestInstance(t, s, "a-rp1-2", typeA, rp1, 0)
+	createTestInstance(t, s, "a-rp1-expired", typeA, rp1, past)
+	createTestInstance(t, s, "a-rp2", typeA, rp2, future)
+	createTestInstance(t, s, "b-rp1", typeB, rp1, future)
+	createTestInstance(t, s, "b-rp2", typeB, rp2, future)
+
+	if _, err := s.RevokeActive("", "", model.TrustMarkRevocation{}); err == nil {
+		t.Fatal("expected error without trust mark type and subject")
+	}
+
+	revocation := model.TrustMarkRevocation{Reason: "not eligible", Actor: "admin"}
+	revoked, err := s.RevokeActive(typeA, rp1, revocation)
+	if err != nil {
+		t.Fatalf("RevokeActive(type, subject) error: %v", err)
+	}
+	if got := revokedJTIs(revoked); fmt.Sprint(got) != "[a-rp1-1 a-rp1-2]" {
+		t.Errorf("RevokeActive(type, subject) revoked %v", got)
+	}
+	for _, jti := range []string{"a-rp1-1", "a-rp1-2"} {
+		instance, err := s.GetByJTI(jti)
+		if err != nil {
+			t.Fatalf("GetByJTI(%s) error: %v", jti, err)
+		}
+		if !instance.Revoked || instance.RevocationReason != "not eligible" || instance.RevokedBy != "admin" {
+			t.Errorf("instance %s not revoked as expected: %+v", jti, instance)
+		}
+	}
+	if instance, _ := s.GetByJTI("a-rp1-expired"); instance.Revoked {
+		t.Error("expired instance must not be revoked")
+	}
+
+	revoked, err = s.RevokeActive("", rp1, revocation)
+	if err != nil {
+		t.Fatalf("RevokeActive(subject) error: %v", err)
+	}
+	if got := revokedJTIs(revoked); fmt.Sprint(got) != "[b-rp1]" {
+		t.Errorf("RevokeActive(subject) revoked %v", got)
+	}
+
+	revoked, err = s.RevokeActive(typeB, "", revocation)
+	if err != nil {
+		t.Fatalf("RevokeActive(type) error: %v", err)
+	}
+	if got := revokedJTIs(revoked); fmt.Sprint(got) != "[b-rp2]" {
+		t.Errorf("RevokeActive(type) revoked %v", got)
+	}
+
+	active, err := s.HasActiveInstance(typeA, rp2)
+	if err != nil {
+		t.Fatalf("HasActiveInstance() error: %v", err)
+	}
+	if !active {
+		t.Error("instance of other subject must stay active")
+	}
+}
+
+func revokedJTIs(instances []model.IssuedTrustMarkInstance) []string {
+	jtis := make([]string, len(instances))
+	for i, instance := range instances {
+		jtis[i] = instance.JTI
+	}
+	return jtis
+}
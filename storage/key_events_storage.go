@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// KeyEventsStorage implements the KeyEventStore interface using GORM.
+type KeyEventsStorage struct {
+	db *gorm.DB
+}
+
+// NewKeyEventsStorage creates a new KeyEventsStorage.
+func NewKeyEventsStorage(db *gorm.DB) *KeyEventsStorage {
+	return &KeyEventsStorage{db: db}
+}
+
+// Add creates a new event record.
+func (s *KeyEventsStorage) Add(event model.KeyEvent) error {
+	if err := s.db.Create(&event).Error; err != nil {
+		return errors.Wrap(err, "key_events: failed to create event")
+	}
+	return nil
+}
+
+// GetByKeySet returns a single page of events for a key set with optional
+// filtering.
+func (s *KeyEventsStorage) GetByKeySet(keySet string, opts model.EventQueryOpts) (
+	[]model.KeyEvent, model.PageInfo, error,
+) {
+	events, page, err := findEventPage(
+		s.db.Model(&model.KeyEvent{}).Where("key_set = ?", keySet), opts,
+		listColumns{search: []string{"k_id", "type", "message", "actor"}},
+		func(e model.KeyEvent) model.ListCursor {
+			return listCursor(opts.Sort, e.ID, e.CreatedAt, e.UpdatedAt, "")
+		},
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "key_events: failed to get events")
+	}
+	return events, page, nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"gorm.io/datatypes"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// KeySetKeyValueStore is a model.KeyValueStore for the signing settings of a
+// separate key set (e.g. for trust marks). Signing settings (algorithms, RSA
+// key length, key rotation) are stored in a key set specific scope; as long as
+// a setting was not set for the key set, the setting of the federation key set
+// is returned. All other scopes are passed through unchanged.
+type KeySetKeyValueStore struct {
+	model.KeyValueStore
+	scope string
+}
+
+// NewKeySetKeyValueStore returns a KeySetKeyValueStore for the passed key set.
+func NewKeySetKeyValueStore(kv model.KeyValueStore, keySet string) *KeySetKeyValueStore {
+	return &KeySetKeyValueStore{
+		KeyValueStore: kv,
+		scope:         KeySetSigningScope(keySet),
+	}
+}
+
+// KeySetSigningScope returns the key value scope for the signing settings of
+// the passed key set.
+func KeySetSigningScope(keySet string) string {
+	return model.KeyValueScopeSigning + ":" + keySet
+}
+
+func isKeySetSetting(scope, key string) bool {
+	if scope != model.KeyValueScopeSigning {
+		return false
+	}
+	switch key {
+	case model.KeyValueKeyAlg, model.KeyValueKeyAlgs, model.KeyValueKeyRSAKeyLen, model.KeyValueKeyKeyRotation:
+		return true
+	default:
+		return false
+	}
+}
+
+// Get implements the model.KeyValueStore interface
+func (s *KeySetKeyValueStore) Get(scope, key string) (datatypes.JSON, error) {
+	if !isKeySetSetting(scope, key) {
+		return s.KeyValueStore.Get(scope, key)
+	}
+	v, err := s.KeyValueStore.Get(s.scope, key)
+	if err != nil || v != nil {
+		return v, err
+	}
+	return s.KeyValueStore.Get(scope, key)
+}
+
+// GetAs implements the model.KeyValueStore interface
+func (s *KeySetKeyValueStore) GetAs(scope, key string, out any) (bool, error) {
+	raw, err := s.Get(scope, key)
+	if err != nil {
+		return false, err
+	}
+	if raw == nil {
+		return false, nil
+	}
+	if err = json.Unmarshal(raw, out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Set implements the model.KeyValueStore interface
+func (s *KeySetKeyValueStore) Set(scope, key string, value datatypes.JSON) error {
+	if isKeySetSetting(scope, key) {
+		scope = s.scope
+	}
+	return s.KeyValueStore.Set(scope, key, value)
+}
+
+// SetAny implements the model.KeyValueStore interface
+func (s *KeySetKeyValueStore) SetAny(scope, key string, v any) error {
+	if isKeySetSetting(scope, key) {
+		scope = s.scope
+	}
+	return s.KeyValueStore.SetAny(scope, key, v)
+}
+
+// Delete implements the model.KeyValueStore interface
+func (s *KeySetKeyValueStore) Delete(scope, key string) error {
+	if isKeySetSetting(scope, key) {
+		scope = s.scope
+	}
+	return s.KeyValueStore.Delete(scope, key)
+}
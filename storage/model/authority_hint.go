@@ -22,6 +22,9 @@ type AddAuthorityHint struct {
 // AuthorityHintsStore is the abstraction used by handlers.
 type AuthorityHintsStore interface {
 	List() ([]AuthorityHint, error)
+	// ListPage returns a single page of authority hints, sorted and searched
+	// according to the list options.
+	ListPage(opts ListOptions) ([]AuthorityHint, PageInfo, error)
 	Create(hint AddAuthorityHint) (*AuthorityHint, error)
 	Get(ident string) (*AuthorityHint, error)
 	Update(ident string, update AddAuthorityHint) (*AuthorityHint, error)
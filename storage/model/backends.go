@@ -17,6 +17,7 @@ type Backends struct {
 	DB                  *gorm.DB
 	Subordinates        SubordinateStorageBackend
 	SubordinateEvents   SubordinateEventStore
+	KeyEvents           KeyEventStore
 	TrustMarks          TrustMarkedEntitiesStorageBackend
 	TrustMarkSpecs      TrustMarkSpecStore
 	TrustMarkInstances  IssuedTrustMarkInstanceStore
@@ -5,8 +5,9 @@ type SubordinateEventStore interface {
 	// Add creates a new event record.
 	Add(event SubordinateEvent) error
 
-	// GetBySubordinateID returns events for a subordinate with optional filtering and pagination.
-	GetBySubordinateID(subordinateID uint, opts EventQueryOpts) ([]SubordinateEvent, int64, error)
+	// GetBySubordinateID returns a single page of events for a subordinate
+	// with optional filtering.
+	GetBySubordinateID(subordinateID uint, opts EventQueryOpts) ([]SubordinateEvent, PageInfo, error)
 
 	// DeleteBySubordinateID removes all events for a subordinate (used on subordinate deletion).
 	DeleteBySubordinateID(subordinateID uint) error
@@ -14,10 +15,11 @@ type SubordinateEventStore interface {
 
 // EventQueryOpts contains options for querying events.
 type EventQueryOpts struct {
-	// Limit is the maximum number of events to return (default: 50, max: 100).
-	Limit int
-	// Offset is the number of events to skip for pagination.
-	Offset int
+	// ListOptions configures paging, sorting, and searching. Events can be
+	// sorted by created_at and updated_at (entity_id is not supported) and are
+	// searched by type, message, and actor. The Limit defaults to 50 and is
+	// capped at 100.
+	ListOptions
 	// EventType filters events by type (e.g., "created", "deleted").
 	EventType *string
 	// FromTime filters events with timestamp >= this value (unix seconds).
@@ -1,23 +1,21 @@
-package adminapi
+package model
 
 import (
 	"errors"
 	"testing"
 	"time"
-
-	smodel "github.com/go-oidfed/lighthouse/storage/model"
 )
 
 type mockSubordinateEventStore struct {
-	addFn func(smodel.SubordinateEvent) error
+	addFn func(SubordinateEvent) error
 }
 
-func (m *mockSubordinateEventStore) Add(event smodel.SubordinateEvent) error {
+func (m *mockSubordinateEventStore) Add(event SubordinateEvent) error {
 	return m.addFn(event)
 }
 
-func (*mockSubordinateEventStore) GetBySubordinateID(_ uint, _ smodel.EventQueryOpts) ([]smodel.SubordinateEvent, int64, error) {
-	return nil, 0, nil
+func (*mockSubordinateEventStore) GetBySubordinateID(_ uint, _ EventQueryOpts) ([]SubordinateEvent, PageInfo, error) {
+	return nil, PageInfo{}, nil
 }
 
 func (*mockSubordinateEventStore) DeleteBySubordinateID(_ uint) error {
@@ -27,15 +25,15 @@ func (*mockSubordinateEventStore) DeleteBySubordinateID(_ uint) error {
 func TestEventOptions(t *testing.T) {
 	t.Parallel()
 
-	event := &smodel.SubordinateEvent{}
+	event := &SubordinateEvent{}
 	WithMessage("updated subordinate")(event)
-	WithStatus(smodel.StatusActive)(event)
+	WithStatus(StatusActive)(event)
 	WithActor("admin@example.com")(event)
 
 	if event.Message == nil || *event.Message != "updated subordinate" {
 		t.Fatalf("unexpected message option result: %+v", event)
 	}
-	if event.Status == nil || *event.Status != smodel.StatusActive.String() {
+	if event.Status == nil || *event.Status != StatusActive.String() {
 		t.Fatalf("unexpected status option result: %+v", event)
 	}
 	if event.Actor == nil || *event.Actor != "admin@example.com" {
@@ -49,7 +47,7 @@ func TestRecordEvent(t *testing.T) {
 	t.Run("NilStoreIsNoop", func(t *testing.T) {
 		t.Parallel()
 
-		if err := RecordEvent(nil, 7, smodel.EventTypeCreated); err != nil {
+		if err := RecordEvent(nil, 7, EventTypeCreated); err != nil {
 			t.Fatalf("expected nil error for nil store, got %v", err)
 		}
 	})
@@ -57,9 +55,9 @@ func TestRecordEvent(t *testing.T) {
 	t.Run("AddsEventWithOptions", func(t *testing.T) {
 		t.Parallel()
 
-		var got smodel.SubordinateEvent
+		var got SubordinateEvent
 		store := &mockSubordinateEventStore{
-			addFn: func(event smodel.SubordinateEvent) error {
+			addFn: func(event SubordinateEvent) error {
 				got = event
 				return nil
 			},
@@ -69,8 +67,8 @@ func TestRecordEvent(t *testing.T) {
 		err := RecordEvent(
 			store,
 			9,
-			smodel.EventTypeUpdated,
-			WithStatus(smodel.StatusBlocked),
+			EventTypeUpdated,
+			WithStatus(StatusBlocked),
 			WithMessage("blocked by admin"),
 			WithActor("alice"),
 		)
@@ -78,13 +76,13 @@ func TestRecordEvent(t *testing.T) {
 		if err != nil {
 			t.Fatalf("expected nil error, got %v", err)
 		}
-		if got.SubordinateID != 9 || got.Type != smodel.EventTypeUpdated {
+		if got.SubordinateID != 9 || got.Type != EventTypeUpdated {
 			t.Fatalf("unexpected event core fields: %+v", got)
 		}
 		if got.Timestamp < before || got.Timestamp > after {
 			t.Fatalf("expected timestamp between %d and %d, got %d", before, after, got.Timestamp)
 		}
-		if got.Status == nil || *got.Status != smodel.StatusBlocked.String() {
+		if got.Status == nil || *got.Status != StatusBlocked.String() {
 			t.Fatalf("unexpected event status: %+v", got)
 		}
 		if got.Message == nil || *got.Message != "blocked by admin" {
@@ -100,12 +98,12 @@ func TestRecordEvent(t *testing.T) {
 
 		wantErr := errors.New("write failed")
 		store := &mockSubordinateEventStore{
-			addFn: func(_ smodel.SubordinateEvent) error {
+			addFn: func(_ SubordinateEvent) error {
 				return wantErr
 			},
 		}
 
-		err := RecordEvent(store, 3, smodel.EventTypeDeleted)
+		err := RecordEvent(store, 3, EventTypeDeleted)
 		if !errors.Is(err, wantErr) {
 			t.Fatalf("expected error %v, got %v", wantErr, err)
 		}
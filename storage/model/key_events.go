@@ -0,0 +1,37 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// Event type constants for signing key events.
+const (
+	// KeyEventTypeImported is recorded when an existing private key is
+	// imported into the KMS.
+	KeyEventTypeImported = "imported"
+)
+
+// KeyEvent stores an event related to the signing keys of a key set.
+type KeyEvent struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt int            `json:"created_at"`
+	UpdatedAt int            `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	KeySet    string         `gorm:"index" json:"key_set"`
+	KID       string         `gorm:"index" json:"kid"`
+	Timestamp int64          `gorm:"index" json:"timestamp"`
+	Type      string         `gorm:"index" json:"type"`
+	Message   *string        `json:"message,omitempty"`
+	Actor     *string        `json:"actor,omitempty"`
+}
+
+// KeyEventStore is an interface for storing and retrieving signing key
+// events.
+type KeyEventStore interface {
+	// Add creates a new event record.
+	Add(event KeyEvent) error
+
+	// GetByKeySet returns a single page of events for a key set with optional
+	// filtering.
+	GetByKeySet(keySet string, opts EventQueryOpts) ([]KeyEvent, PageInfo, error)
+}
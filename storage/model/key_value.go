@@ -16,6 +16,7 @@ const (
 	KeyValueKeyMetadata           = "metadata"
 	KeyValueKeyConstraints        = "constraints"
 	KeyValueKeyAlg                = "alg"
+	KeyValueKeyAlgs               = "algs"
 	KeyValueKeyRSAKeyLen          = "rsa_key_len"
 	KeyValueKeyKeyRotation        = "key_rotation"
 	KeyValueKeyAdditionalClaims   = "additional_claims"
@@ -0,0 +1,293 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SubordinateLabel is a join row holding a single key/value label of a subordinate.
+type SubordinateLabel struct {
+	SubordinateID uint   `gorm:"index;uniqueIndex:uidx_sub_label" json:"-"`
+	Key           string `gorm:"size:255;uniqueIndex:uidx_sub_label" json:"key"`
+	Value         string `gorm:"size:255;index" json:"value"`
+}
+
+// SubordinateLabels is a collection of SubordinateLabel rows.
+// In JSON it is represented as a simple {"key": "value"} object.
+type SubordinateLabels []SubordinateLabel
+
+// NewSubordinateLabels creates SubordinateLabels from a key/value map.
+// The resulting rows are sorted by key to give a stable order.
+func NewSubordinateLabels(labels map[string]string) SubordinateLabels {
+	if labels == nil {
+		return nil
+	}
+	rows := make(SubordinateLabels, 0, len(labels))
+	for k, v := range labels {
+		rows = append(
+			rows, SubordinateLabel{
+				Key:   k,
+				Value: v,
+			},
+		)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
+
+// Map returns the labels as a key/value map.
+func (l SubordinateLabels) Map() map[string]string {
+	m := make(map[string]string, len(l))
+	for _, label := range l {
+		m[label.Key] = label.Value
+	}
+	return m
+}
+
+// MarshalJSON encodes the labels as a {"key": "value"} object.
+func (l SubordinateLabels) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Map())
+}
+
+// UnmarshalJSON decodes the labels from a {"key": "value"} object.
+func (l *SubordinateLabels) UnmarshalJSON(b []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*l = NewSubordinateLabels(m)
+	return nil
+}
+
+var (
+	labelKeyRegex   = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?/)?[a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?$`)
+	labelValueRegex = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?)?$`)
+)
+
+// ValidateLabels checks that all label keys and values are well-formed.
+// Keys consist of an optional DNS-like prefix followed by a slash and a name
+// made of alphanumerics, '-', '_' and '.'; values follow the same rules as
+// names but may be empty.
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if len(k) > 255 || !labelKeyRegex.MatchString(k) {
+			return ValidationErrorFmt("invalid label key: %q", k)
+		}
+		if len(v) > 255 || !labelValueRegex.MatchString(v) {
+			return ValidationErrorFmt("invalid value for label %q: %q", k, v)
+		}
+	}
+	return nil
+}
+
+// LabelSelectorOperator is the operator of a single LabelRequirement.
+type LabelSelectorOperator string
+
+// Constants for LabelSelectorOperator
+const (
+	LabelSelectorOpEquals       LabelSelectorOperator = "="
+	LabelSelectorOpNotEquals    LabelSelectorOperator = "!="
+	LabelSelectorOpIn           LabelSelectorOperator = "in"
+	LabelSelectorOpNotIn        LabelSelectorOperator = "notin"
+	LabelSelectorOpExists       LabelSelectorOperator = "exists"
+	LabelSelectorOpDoesNotExist LabelSelectorOperator = "!"
+)
+
+// LabelRequirement is a single requirement of a LabelSelector, e.g. `env=prod`
+// or `country in (de,at)`.
+type LabelRequirement struct {
+	Key      string
+	Operator LabelSelectorOperator
+	Values   []string
+}
+
+// Matches reports whether the passed labels satisfy the requirement.
+func (r LabelRequirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case LabelSelectorOpExists:
+		return ok
+	case LabelSelectorOpDoesNotExist:
+		return !ok
+	case LabelSelectorOpEquals, LabelSelectorOpIn:
+		return ok && slices.Contains(r.Values, v)
+	case LabelSelectorOpNotEquals, LabelSelectorOpNotIn:
+		return !ok || !slices.Contains(r.Values, v)
+	}
+	return false
+}
+
+// String returns the canonical string representation of the requirement.
+func (r LabelRequirement) String() string {
+	switch r.Operator {
+	case LabelSelectorOpExists:
+		return r.Key
+	case LabelSelectorOpDoesNotExist:
+		return "!" + r.Key
+	case LabelSelectorOpEquals, LabelSelectorOpNotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	default:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+}
+
+// LabelSelector selects subordinates by their labels. All requirements must
+// be satisfied (logical AND). An empty selector matches everything.
+type LabelSelector []LabelRequirement
+
+// Empty reports whether the selector has no requirements.
+func (s LabelSelector) Empty() bool {
+	return len(s) == 0
+}
+
+// Matches reports whether the passed labels satisfy all requirements.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the canonical string representation of the selector.
+func (s LabelSelector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseLabelSelector parses a label selector string.
+//
+// The syntax is a comma separated list of requirements, each of which is one of:
+//   - `key=value` or `key==value`
+//   - `key!=value`
+//   - `key in (v1,v2)`
+//   - `key notin (v1,v2)`
+//   - `key` (the label exists)
+//   - `!key` (the label does not exist)
+//
+// An empty string results in an empty selector that matches everything.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var sel LabelSelector
+	parts, err := splitSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		req, err := parseLabelRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// splitSelector splits a selector at top-level commas, i.e. commas that are
+// not part of a parenthesized value list.
+func splitSelector(selector string) ([]string, error) {
+	var parts []string
+	depth := 0
+	start := 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, ValidationErrorFmt("invalid label selector %q: nested parentheses", selector)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, ValidationErrorFmt("invalid label selector %q: unbalanced parentheses", selector)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, ValidationErrorFmt("invalid label selector %q: unbalanced parentheses", selector)
+	}
+	parts = append(parts, selector[start:])
+	result := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+var setRequirementRegex = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\(([^()]*)\)$`)
+
+func parseLabelRequirement(s string) (LabelRequirement, error) {
+	if m := setRequirementRegex.FindStringSubmatch(s); m != nil {
+		values := strings.Split(m[3], ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		req := LabelRequirement{
+			Key:      m[1],
+			Operator: LabelSelectorOperator(m[2]),
+			Values:   values,
+		}
+		return req, validateRequirement(req, s)
+	}
+	var req LabelRequirement
+	switch {
+	case strings.Contains(s, "!="):
+		k, v, _ := strings.Cut(s, "!=")
+		req = LabelRequirement{
+			Key:      strings.TrimSpace(k),
+			Operator: LabelSelectorOpNotEquals,
+			Values:   []string{strings.TrimSpace(v)},
+		}
+	case strings.Contains(s, "=="):
+		k, v, _ := strings.Cut(s, "==")
+		req = LabelRequirement{
+			Key:      strings.TrimSpace(k),
+			Operator: LabelSelectorOpEquals,
+			Values:   []string{strings.TrimSpace(v)},
+		}
+	case strings.Contains(s, "="):
+		k, v, _ := strings.Cut(s, "=")
+		req = LabelRequirement{
+			Key:      strings.TrimSpace(k),
+			Operator: LabelSelectorOpEquals,
+			Values:   []string{strings.TrimSpace(v)},
+		}
+	case strings.HasPrefix(s, "!"):
+		req = LabelRequirement{
+			Key:      strings.TrimSpace(s[1:]),
+			Operator: LabelSelectorOpDoesNotExist,
+		}
+	default:
+		req = LabelRequirement{
+			Key:      s,
+			Operator: LabelSelectorOpExists,
+		}
+	}
+	return req, validateRequirement(req, s)
+}
+
+func validateRequirement(req LabelRequirement, raw string) error {
+	if !labelKeyRegex.MatchString(req.Key) {
+		return ValidationErrorFmt("invalid label selector requirement %q: invalid key", raw)
+	}
+	for _, v := range req.Values {
+		if !labelValueRegex.MatchString(v) {
+			return ValidationErrorFmt("invalid label selector requirement %q: invalid value %q", raw, v)
+		}
+	}
+	return nil
+}
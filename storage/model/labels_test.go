@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+		wantErr  bool
+	}{
+		{selector: "", want: ""},
+		{selector: "env=prod", want: "env=prod"},
+		{selector: "env==prod", want: "env=prod"},
+		{selector: "env != prod", want: "env!=prod"},
+		{selector: "country in (de, at),env", want: "country in (de,at),env"},
+		{selector: "country notin (de),!deprecated", want: "country notin (de),!deprecated"},
+		{selector: "example.org/project=lighthouse", want: "example.org/project=lighthouse"},
+		{selector: "env=pr od", wantErr: true},
+		{selector: "country in (de,at", wantErr: true},
+		{selector: "country in ((de))", wantErr: true},
+		{selector: "=prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		sel, err := ParseLabelSelector(tt.selector)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLabelSelector(%q) expected error, got %q", tt.selector, sel)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLabelSelector(%q) returned error: %v", tt.selector, err)
+			continue
+		}
+		if got := sel.String(); got != tt.want {
+			t.Errorf("ParseLabelSelector(%q) = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{
+		"env":     "prod",
+		"country": "de",
+	}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{selector: "", want: true},
+		{selector: "env=prod", want: true},
+		{selector: "env=test", want: false},
+		{selector: "env!=test", want: true},
+		{selector: "project!=x", want: true},
+		{selector: "country in (at,de)", want: true},
+		{selector: "country notin (at,de)", want: false},
+		{selector: "env", want: true},
+		{selector: "!env", want: false},
+		{selector: "!project", want: true},
+		{selector: "env=prod,country=at", want: false},
+	}
+
+	for _, tt := range tests {
+		sel, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q) returned error: %v", tt.selector, err)
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	if err := ValidateLabels(map[string]string{"env": "prod", "example.org/team": ""}); err != nil {
+		t.Errorf("ValidateLabels returned error for valid labels: %v", err)
+	}
+	if err := ValidateLabels(map[string]string{"in valid": "x"}); err == nil {
+		t.Error("ValidateLabels expected error for invalid key")
+	}
+	if err := ValidateLabels(map[string]string{"env": "not valid"}); err == nil {
+		t.Error("ValidateLabels expected error for invalid value")
+	}
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// SortField is a field list results can be sorted by.
+type SortField string
+
+// Supported sort fields; SortByID is the default and sorts by insertion order.
+const (
+	SortByID        SortField = ""
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByEntityID  SortField = "entity_id"
+)
+
+// MaxListLimit is the maximum number of entries returned in a single page.
+const MaxListLimit = 1000
+
+// ParseSortField parses a SortField; an empty string results in SortByID.
+func ParseSortField(s string) (SortField, error) {
+	switch f := SortField(s); f {
+	case SortByID, SortByCreatedAt, SortByUpdatedAt, SortByEntityID:
+		return f, nil
+	case "id":
+		return SortByID, nil
+	default:
+		return "", ValidationErrorFmt("invalid sort field '%s' (valid values: created_at, updated_at, entity_id)", s)
+	}
+}
+
+// ListOptions configures paging, sorting, and searching of list results.
+type ListOptions struct {
+	// Limit is the maximum number of returned entries; 0 returns all entries.
+	Limit int
+	// Cursor is the opaque cursor returned as PageInfo.NextCursor of the
+	// previous page.
+	Cursor string
+	// Sort is the field to sort by.
+	Sort SortField
+	// Desc sorts in descending order.
+	Desc bool
+	// Search restricts the result to entries whose entity ID or description
+	// contains this string (case-insensitive).
+	Search string
+	// Offset skips the first entries. It is only supported for lists that
+	// offered offset paging before cursors were introduced and cannot be
+	// combined with Cursor.
+	Offset int
+}
+
+// PageInfo describes a page of list results.
+type PageInfo struct {
+	// Total is the number of all entries matching the filter and search,
+	// independent of paging.
+	Total int64
+	// NextCursor is the cursor for the next page; empty if this is the last
+	// page.
+	NextCursor string
+}
+
+// ListCursor is the decoded position of a cursor: the value of the sort field
+// and the ID of the last entry of the previous page.
+type ListCursor struct {
+	String string `json:"s,omitempty"`
+	Int    int64  `json:"n,omitempty"`
+	ID     uint   `json:"id"`
+}
+
+// Encode returns the opaque string representation of the cursor.
+func (c ListCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeListCursor decodes a cursor created by ListCursor.Encode.
+func DecodeListCursor(s string) (*ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, ValidationError("invalid cursor")
+	}
+	var c ListCursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, ValidationError("invalid cursor")
+	}
+	return &c, nil
+}
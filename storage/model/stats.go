@@ -26,14 +26,45 @@ type StatsStorageBackend interface {
 	GetTimeSeries(from, to time.Time, endpoint string, interval stats.Interval) ([]stats.TimeSeriesPoint, error)
 	GetLatencyPercentiles(from, to time.Time, endpoint string) (*stats.LatencyStats, error)
 
+	// Per-subject queries, based on the sub and trust_mark_type parameters
+	GetTopSubjects(from, to time.Time, endpoint string, limit int) ([]stats.SubjectStats, error)
+	GetTopTrustMarkSubjects(from, to time.Time, trustMarkType string, limit int) ([]stats.SubjectStats, error)
+	GetRequestedSubjects(from, to time.Time, endpoint string) ([]string, error)
+
+	// FirstRequestLogTime returns the time of the oldest retained request
+	// log, or the zero time if there are none.
+	FirstRequestLogTime() (time.Time, error)
+	// HasSampledRequests reports whether requests to endpoint between from
+	// and to were recorded with sampling, i.e. not all requests are logged.
+	HasSampledRequests(from, to time.Time, endpoint string) (bool, error)
+
+	// GetRequestLogsAfter returns up to limit request logs with an ID greater
+	// than afterID in ascending order; with afterID 0 the latest logs are
+	// returned.
+	GetRequestLogsAfter(afterID uint64, limit int) ([]stats.RequestLog, error)
+
+	// GetEndpointStats returns the request and error counts per endpoint.
+	GetEndpointStats(from, to time.Time) ([]stats.EndpointStats, error)
+
+	// Hourly aggregation
+	AggregateHourlyStats(hour time.Time) error
+	// LastHourlyAggregation returns the start of the latest aggregated hour,
+	// or the zero time if no hour was aggregated yet.
+	LastHourlyAggregation() (time.Time, error)
+
 	// Daily aggregation
 	AggregateDailyStats(date time.Time) error
 	GetDailyStats(from, to time.Time) ([]stats.DailyStats, error)
 
 	// Maintenance
 	PurgeDetailedLogs(before time.Time) (int64, error)
+	PurgeHourlyStats(before time.Time) (int64, error)
 	PurgeAggregatedStats(before time.Time) (int64, error)
 
+	// Privacy
+	AnonymizeRequestLogs(from, to time.Time, anonymize func(entry *stats.RequestLog) bool) (int64, error)
+	AnonymizeDailyStats(from, to time.Time, clientIP func(ip string, date time.Time) string) (int64, error)
+
 	// Export
 	ExportCSV(from, to time.Time, w io.Writer) error
 	ExportJSON(from, to time.Time, w io.Writer) error
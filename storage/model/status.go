@@ -17,6 +17,9 @@ const (
 	StatusInactive
 )
 
+// DefaultSubordinateStatus is the default status for newly created subordinates.
+var DefaultSubordinateStatus = StatusActive
+
 // String returns the canonical string representation for the status.
 func (s Status) String() string {
 	switch s {
@@ -28,6 +28,7 @@ type BasicSubordinateInfo struct {
 	EntityID               string                  `gorm:"size:255;uniqueIndex" json:"entity_id"`
 	Description            string                  `gorm:"type:text" json:"description,omitempty"`
 	SubordinateEntityTypes []SubordinateEntityType `gorm:"foreignKey:SubordinateID;constraint:OnDelete:CASCADE" json:"registered_entity_types,omitempty"`
+	Labels                 SubordinateLabels       `gorm:"foreignKey:SubordinateID;constraint:OnDelete:CASCADE" json:"labels,omitempty"`
 	Status                 Status                  `gorm:"index" json:"status"`
 }
 
@@ -131,15 +132,18 @@ func (et PolicyOperators) ToPolicyOperatorNames() []oidfed.PolicyOperatorName {
 
 // AddSubordinate represents the payload for creating a new subordinate.
 type AddSubordinate struct {
-	EntityID              string   `json:"entity_id"`
-	Status                Status   `json:"status"`
-	Description           string   `json:"description,omitempty"`
-	RegisteredEntityTypes []string `json:"registered_entity_types,omitempty"`
-	JWKS                  *JWKS    `json:"jwks,omitempty"`
+	EntityID              string            `json:"entity_id"`
+	Status                Status            `json:"status"`
+	Description           string            `json:"description,omitempty"`
+	RegisteredEntityTypes []string          `json:"registered_entity_types,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
+	JWKS                  *JWKS             `json:"jwks,omitempty"`
 }
 
 // UpdateSubordinate represents the payload for updating a subordinate.
+// A nil Labels map leaves the labels untouched, an empty map removes all labels.
 type UpdateSubordinate struct {
-	Description           *string  `json:"description,omitempty"`
-	RegisteredEntityTypes []string `json:"registered_entity_types,omitempty"`
+	Description           *string           `json:"description,omitempty"`
+	RegisteredEntityTypes []string          `json:"registered_entity_types,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
 }
@@ -0,0 +1,89 @@
+package model
+
+import (
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/jwx"
+)
+
+// SubordinateExport is the portable representation of a subordinate used for
+// bulk export and import. It does not contain any database IDs, so it can be
+// imported into a different LightHouse instance.
+type SubordinateExport struct {
+	EntityID              string                          `json:"entity_id"`
+	Status                Status                          `json:"status"`
+	Description           string                          `json:"description,omitempty"`
+	RegisteredEntityTypes []string                        `json:"registered_entity_types,omitempty"`
+	Labels                map[string]string               `json:"labels,omitempty"`
+	JWKS                  *jwx.JWKS                       `json:"jwks,omitempty"`
+	Metadata              *oidfed.Metadata                `json:"metadata,omitempty"`
+	MetadataPolicy        *oidfed.MetadataPolicies        `json:"metadata_policy,omitempty"`
+	Constraints           *oidfed.ConstraintSpecification `json:"constraints,omitempty"`
+	AdditionalClaims      []AddAdditionalClaim            `json:"additional_claims,omitempty"`
+}
+
+// NewSubordinateExport creates a SubordinateExport from the stored info.
+func NewSubordinateExport(info ExtendedSubordinateInfo) SubordinateExport {
+	e := SubordinateExport{
+		EntityID:       info.EntityID,
+		Status:         info.Status,
+		Description:    info.Description,
+		Labels:         info.Labels.Map(),
+		Metadata:       info.Metadata,
+		MetadataPolicy: info.MetadataPolicy,
+		Constraints:    info.Constraints,
+	}
+	for _, et := range info.SubordinateEntityTypes {
+		e.RegisteredEntityTypes = append(e.RegisteredEntityTypes, et.EntityType)
+	}
+	if info.JWKS.Keys.Set != nil && info.JWKS.Keys.Len() > 0 {
+		keys := info.JWKS.Keys
+		e.JWKS = &keys
+	}
+	for _, c := range info.SubordinateAdditionalClaims {
+		e.AdditionalClaims = append(
+			e.AdditionalClaims, AddAdditionalClaim{
+				Claim: c.Claim,
+				Value: c.Value,
+				Crit:  c.Crit,
+			},
+		)
+	}
+	return e
+}
+
+// ExtendedSubordinateInfo converts the SubordinateExport into an
+// ExtendedSubordinateInfo that can be stored.
+func (e SubordinateExport) ExtendedSubordinateInfo() ExtendedSubordinateInfo {
+	info := ExtendedSubordinateInfo{
+		BasicSubordinateInfo: BasicSubordinateInfo{
+			EntityID:    e.EntityID,
+			Status:      e.Status,
+			Description: e.Description,
+			Labels:      NewSubordinateLabels(e.Labels),
+		},
+		Metadata:       e.Metadata,
+		MetadataPolicy: e.MetadataPolicy,
+		Constraints:    e.Constraints,
+	}
+	for _, et := range e.RegisteredEntityTypes {
+		info.SubordinateEntityTypes = append(info.SubordinateEntityTypes, SubordinateEntityType{EntityType: et})
+	}
+	if e.JWKS != nil {
+		info.JWKS = NewJWKS(*e.JWKS)
+	}
+	for _, c := range e.AdditionalClaims {
+		info.SubordinateAdditionalClaims = append(
+			info.SubordinateAdditionalClaims, SubordinateAdditionalClaim{
+				Claim: c.Claim,
+				Value: c.Value,
+				Crit:  c.Crit,
+			},
+		)
+	}
+	return info
+}
+
+// HasKeys reports whether the SubordinateExport contains any keys.
+func (e SubordinateExport) HasKeys() bool {
+	return e.JWKS != nil && e.JWKS.Set != nil && e.JWKS.Len() > 0
+}
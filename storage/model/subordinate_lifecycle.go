@@ -0,0 +1,68 @@
+package model
+
+import (
+	"fmt"
+)
+
+// HasKeys reports whether the subordinate has any JWKS keys defined.
+func (e *ExtendedSubordinateInfo) HasKeys() bool {
+	if e == nil {
+		return false
+	}
+	return e.JWKS.Keys.Set != nil && e.JWKS.Keys.Len() > 0
+}
+
+// ValidateStatusChange checks that the subordinate can be set to the passed
+// status. A subordinate cannot become active without keys.
+func (e *ExtendedSubordinateInfo) ValidateStatusChange(status Status) error {
+	if status == StatusActive && !e.HasKeys() {
+		return ValidationErrorFmt("status cannot be active without keys: %s", e.EntityID)
+	}
+	return nil
+}
+
+// UpdateSubordinateStatus sets the status of the subordinate and records a
+// status updated event. If the status does not change, nothing is done.
+// The passed options are applied to the event after the default message, so
+// callers can override it.
+// This should be called within a transaction, so that the status change is
+// rolled back if the event cannot be recorded.
+func (b *Backends) UpdateSubordinateStatus(info *ExtendedSubordinateInfo, status Status, opts ...EventOption) error {
+	oldStatus := info.Status
+	if oldStatus == status {
+		return nil
+	}
+	if err := info.ValidateStatusChange(status); err != nil {
+		return err
+	}
+	if err := b.Subordinates.UpdateStatus(info.EntityID, status); err != nil {
+		return err
+	}
+	info.Status = status
+	opts = append(
+		[]EventOption{
+			WithStatus(status),
+			WithMessage(fmt.Sprintf("status changed from %s to %s", oldStatus, status)),
+		}, opts...,
+	)
+	return RecordEvent(b.SubordinateEvents, info.ID, EventTypeStatusUpdated, opts...)
+}
+
+// DeleteSubordinate deletes the subordinate together with its history and
+// records a deleted event, so the deletion itself remains auditable.
+// This should be called within a transaction.
+func (b *Backends) DeleteSubordinate(info *BasicSubordinateInfo, opts ...EventOption) error {
+	if err := b.SubordinateEvents.DeleteBySubordinateID(info.ID); err != nil {
+		return err
+	}
+	if err := b.Subordinates.Delete(info.EntityID); err != nil {
+		return err
+	}
+	opts = append(
+		[]EventOption{
+			WithStatus(info.Status),
+			WithMessage(fmt.Sprintf("subordinate deleted: %s", info.EntityID)),
+		}, opts...,
+	)
+	return RecordEvent(b.SubordinateEvents, info.ID, EventTypeDeleted, opts...)
+}
@@ -40,6 +40,16 @@ type SubordinateStorageBackend interface {
 	GetByAnyEntityType(entityTypes []string) ([]BasicSubordinateInfo, error)
 	GetByStatusAndEntityTypes(status Status, entityTypes []string) ([]BasicSubordinateInfo, error)
 	GetByStatusAndAnyEntityType(status Status, entityTypes []string) ([]BasicSubordinateInfo, error)
+	Find(filter SubordinateFilter) ([]BasicSubordinateInfo, error)
+	// FindPage returns a single page of subordinates matching the filter,
+	// sorted and searched according to the list options.
+	FindPage(filter SubordinateFilter, opts ListOptions) ([]BasicSubordinateInfo, PageInfo, error)
+	// FindExtended returns the full info of all subordinates matching the
+	// filter without applying general fallbacks.
+	FindExtended(filter SubordinateFilter) ([]ExtendedSubordinateInfo, error)
+	// Replace overwrites all data of an existing subordinate, including its
+	// entity types, labels, JWKS, and additional claims.
+	Replace(entityID string, info ExtendedSubordinateInfo) error
 	Load() error
 
 	// Additional claims CRUD for a specific subordinate
@@ -50,3 +60,14 @@ type SubordinateStorageBackend interface {
 	UpdateAdditionalClaim(subordinateDBID string, claimID string, claim AddAdditionalClaim) (*SubordinateAdditionalClaim, error)
 	DeleteAdditionalClaim(subordinateDBID string, claimID string) error
 }
+
+// SubordinateFilter describes a combined query for subordinates.
+// All set fields must match (logical AND); zero values are ignored.
+type SubordinateFilter struct {
+	// Status restricts the result to subordinates with this status.
+	Status *Status
+	// EntityTypes restricts the result to subordinates having any of these entity types.
+	EntityTypes []string
+	// Labels restricts the result to subordinates matching this label selector.
+	Labels LabelSelector
+}
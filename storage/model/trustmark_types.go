@@ -83,6 +83,9 @@ type AddTrustMarkType struct {
 type TrustMarkTypesStore interface {
 	// Types
 	List() ([]TrustMarkType, error)
+	// ListPage returns a single page of trust mark types, sorted and searched
+	// according to the list options; SortByEntityID sorts by the trust mark type.
+	ListPage(opts ListOptions) ([]TrustMarkType, PageInfo, error)
 	Create(req AddTrustMarkType) (*TrustMarkType, error)
 	Get(ident string) (*TrustMarkType, error)
 	Update(ident string, req AddTrustMarkType) (*TrustMarkType, error)
@@ -152,6 +155,9 @@ type TrustMarkIssuer struct {
 // TrustMarkOwnersStore manages global owners and their relations to types.
 type TrustMarkOwnersStore interface {
 	List() ([]TrustMarkOwner, error)
+	// ListPage returns a single page of trust mark owners, sorted and searched
+	// according to the list options; SortByEntityID sorts by the entity id.
+	ListPage(opts ListOptions) ([]TrustMarkOwner, PageInfo, error)
 	Create(req AddTrustMarkOwner) (*TrustMarkOwner, error)
 	Get(ident string) (*TrustMarkOwner, error)
 	Update(ident string, req AddTrustMarkOwner) (*TrustMarkOwner, error)
@@ -166,6 +172,9 @@ type TrustMarkOwnersStore interface {
 // TrustMarkIssuersStore manages global issuers and their relations to types.
 type TrustMarkIssuersStore interface {
 	List() ([]TrustMarkIssuer, error)
+	// ListPage returns a single page of trust mark issuers, sorted and searched
+	// according to the list options; SortByEntityID sorts by the issuer.
+	ListPage(opts ListOptions) ([]TrustMarkIssuer, PageInfo, error)
 	Create(req AddTrustMarkIssuer) (*TrustMarkIssuer, error)
 	Get(ident string) (*TrustMarkIssuer, error)
 	Update(ident string, req AddTrustMarkIssuer) (*TrustMarkIssuer, error)
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -75,11 +77,17 @@ type TrustMarkSubject struct {
 // Each record tracks a specific trust mark JWT that was issued, enabling
 // revocation checking and status queries per the OIDC Federation spec.
 type IssuedTrustMarkInstance struct {
-	JTI                string           `gorm:"primaryKey" json:"jti"`
-	CreatedAt          int              `json:"created_at"`
-	UpdatedAt          int              `json:"updated_at"`
-	ExpiresAt          int              `gorm:"index" json:"expires_at"`
-	Revoked            bool             `gorm:"index" json:"revoked"`
+	JTI       string `gorm:"primaryKey" json:"jti"`
+	CreatedAt int    `json:"created_at"`
+	UpdatedAt int    `json:"updated_at"`
+	ExpiresAt int    `gorm:"index" json:"expires_at"`
+	Revoked   bool   `gorm:"index" json:"revoked"`
+	// RevokedAt is the time of the revocation as unix timestamp
+	RevokedAt int `json:"revoked_at,omitempty"`
+	// RevocationReason is the reason given for the revocation
+	RevocationReason string `gorm:"type:text" json:"revocation_reason,omitempty"`
+	// RevokedBy is the actor that revoked the instance
+	RevokedBy          string           `gorm:"size:255" json:"revoked_by,omitempty"`
 	TrustMarkSubjectID uint             `gorm:"index" json:"trust_mark_subject_id"`
 	TrustMarkSubject   TrustMarkSubject `json:"trust_mark_subject"`
 	// TrustMarkType is denormalized for efficient lookups without joins
@@ -88,6 +96,55 @@ type IssuedTrustMarkInstance struct {
 	Subject string `gorm:"size:255;index" json:"subject"`
 }
 
+// Status returns the status of the instance, determined by the revoked flag
+// and the expiration time.
+func (i IssuedTrustMarkInstance) Status() TrustMarkInstanceStatus {
+	if i.Revoked {
+		return TrustMarkStatusRevoked
+	}
+	if i.ExpiresAt > 0 && int(time.Now().Unix()) > i.ExpiresAt {
+		return TrustMarkStatusExpired
+	}
+	return TrustMarkStatusActive
+}
+
+// RevocationDescription describes when, by whom and why the instance was
+// revoked.
+func (i IssuedTrustMarkInstance) RevocationDescription() string {
+	desc := "revoked at " + time.Unix(int64(i.RevokedAt), 0).UTC().Format(time.RFC3339)
+	if i.RevokedBy != "" {
+		desc += " by " + i.RevokedBy
+	}
+	if i.RevocationReason != "" {
+		desc += ", reason: " + i.RevocationReason
+	}
+	return desc
+}
+
+// TrustMarkRevocation holds the details recorded when trust mark instances
+// are revoked.
+type TrustMarkRevocation struct {
+	// Reason is the reason for the revocation
+	Reason string `json:"reason,omitempty"`
+	// Actor is the user or system that revokes the instances
+	Actor string `json:"-"`
+}
+
+// RevokeTrustMarkInstances selects the active trust mark instances to revoke
+// in a bulk revocation. At least one of TrustMarkType and Subject must be
+// given.
+type RevokeTrustMarkInstances struct {
+	TrustMarkType string `json:"trust_mark_type,omitempty"`
+	Subject       string `json:"sub,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// RevokeTrustMarkInstancesResult is the result of a bulk revocation.
+type RevokeTrustMarkInstancesResult struct {
+	Revoked int      `json:"revoked"`
+	JTIs    []string `json:"jtis"`
+}
+
 // TrustMarkInstanceStatus represents the status of an issued trust mark instance
 type TrustMarkInstanceStatus string
 
@@ -108,11 +165,17 @@ type IssuedTrustMarkInstanceStore interface {
 	Create(instance *IssuedTrustMarkInstance) error
 	// GetByJTI retrieves an instance by its JTI (JWT ID)
 	GetByJTI(jti string) (*IssuedTrustMarkInstance, error)
-	// Revoke marks a trust mark instance as revoked
-	Revoke(jti string) error
+	// Revoke marks a trust mark instance as revoked and returns it.
+	// Revoking an already revoked instance keeps the original revocation;
+	// revoked is false in that case.
+	Revoke(jti string, revocation TrustMarkRevocation) (instance *IssuedTrustMarkInstance, revoked bool, err error)
 	// RevokeBySubjectID revokes all instances for a given TrustMarkSubjectID.
 	// Returns the number of revoked instances.
-	RevokeBySubjectID(subjectID uint) (int64, error)
+	RevokeBySubjectID(subjectID uint, revocation TrustMarkRevocation) (int64, error)
+	// RevokeActive revokes all active (non-revoked, non-expired) instances
+	// matching the trust mark type and entity ID. An empty value matches all,
+	// but at least one must be given. Returns the revoked instances.
+	RevokeActive(trustMarkType, entityID string, revocation TrustMarkRevocation) ([]IssuedTrustMarkInstance, error)
 	// GetStatus returns the status of a trust mark instance
 	GetStatus(jti string) (TrustMarkInstanceStatus, error)
 	// ListBySubject returns all instances for a given trust mark type and subject
@@ -141,7 +204,9 @@ type TrustMarkSpecStore interface {
 	Delete(ident string) error
 
 	// Subject operations
-	ListSubjects(specIdent string, status *Status) ([]TrustMarkSubject, error)
+	// ListSubjects returns a single page of subjects, sorted and searched
+	// according to the list options.
+	ListSubjects(specIdent string, status *Status, opts ListOptions) ([]TrustMarkSubject, PageInfo, error)
 	CreateSubject(specIdent string, subject *AddTrustMarkSubject) (*TrustMarkSubject, error)
 	GetSubject(specIdent, subjectIdent string) (*TrustMarkSubject, error)
 	UpdateSubject(specIdent, subjectIdent string, subject *AddTrustMarkSubject) (*TrustMarkSubject, error)
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// likeEscaper escapes the LIKE wildcards; '!' is used as escape character
+// because, unlike '\', it has no special meaning in string literals of any
+// supported database.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// listColumns names the columns of a table used by findPage.
+type listColumns struct {
+	// entityID is the column used for model.SortByEntityID; if empty, the
+	// table cannot be sorted by entity ID.
+	entityID string
+	// search are the columns matched case-insensitively against the search
+	// string.
+	search []string
+}
+
+// entityListColumns are the listColumns of tables with an entity_id and a
+// description column.
+var entityListColumns = listColumns{
+	entityID: "entity_id",
+	search:   []string{"entity_id", "description"},
+}
+
+// findPage applies the list options to the query and loads a single page of
+// results into a slice of T. The queried table must have an id, a created_at,
+// and an updated_at column; columns names the other columns used for sorting
+// and searching. cursorOf returns the cursor position of an entry, and
+// preloads are preloaded for the returned entries.
+func findPage[T any](
+	query *gorm.DB, opts model.ListOptions, columns listColumns, cursorOf func(T) model.ListCursor,
+	preloads ...string,
+) ([]T, model.PageInfo, error) {
+	var info model.PageInfo
+	sortColumn := string(opts.Sort)
+	if opts.Sort == model.SortByEntityID {
+		if columns.entityID == "" {
+			return nil, info, model.ValidationErrorFmt("sorting by '%s' is not supported", opts.Sort)
+		}
+		sortColumn = columns.entityID
+	}
+	if opts.Offset > 0 && opts.Cursor != "" {
+		return nil, info, model.ValidationError("offset and cursor cannot be combined")
+	}
+	if opts.Search != "" && len(columns.search) > 0 {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(opts.Search)) + "%"
+		conds := make([]string, len(columns.search))
+		args := make([]any, len(columns.search))
+		for i, col := range columns.search {
+			conds[i] = fmt.Sprintf("LOWER(%s) LIKE ? ESCAPE '!'", col)
+			args[i] = pattern
+		}
+		query = query.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	if err := query.Session(&gorm.Session{}).Count(&info.Total).Error; err != nil {
+		return nil, info, errors.Wrap(err, "failed to count entries")
+	}
+
+	op, dir := ">", "ASC"
+	if opts.Desc {
+		op, dir = "<", "DESC"
+	}
+	if opts.Cursor != "" {
+		c, err := model.DecodeListCursor(opts.Cursor)
+		if err != nil {
+			return nil, info, err
+		}
+		switch opts.Sort {
+		case model.SortByID:
+			query = query.Where("id "+op+" ?", c.ID)
+		default:
+			var v any = c.Int
+			if opts.Sort == model.SortByEntityID {
+				v = c.String
+			}
+			query = query.Where(
+				fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortColumn, op), v, v, c.ID,
+			)
+		}
+	}
+	if opts.Sort != model.SortByID {
+		query = query.Order(fmt.Sprintf("%s %s", sortColumn, dir))
+	}
+	query = query.Order("id " + dir)
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit + 1)
+	}
+	if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+	for _, p := range preloads {
+		query = query.Preload(p)
+	}
+
+	var items []T
+	if err := query.Find(&items).Error; err != nil {
+		return nil, info, errors.Wrap(err, "failed to list entries")
+	}
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		items = items[:opts.Limit]
+		info.NextCursor = cursorOf(items[len(items)-1]).Encode()
+	}
+	return items, info, nil
+}
+
+// listCursor returns the cursor position of an entry for the passed sort
+// field.
+func listCursor(sort model.SortField, id uint, createdAt, updatedAt int, entityID string) model.ListCursor {
+	c := model.ListCursor{ID: id}
+	switch sort {
+	case model.SortByCreatedAt:
+		c.Int = int64(createdAt)
+	case model.SortByUpdatedAt:
+		c.Int = int64(updatedAt)
+	case model.SortByEntityID:
+		c.String = entityID
+	}
+	return c
+}
@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// encryptedPEMPrefix marks private keys that are stored encrypted. The
+// encrypted format is
+//
+//	lhenc1.<kek id>.<wrapped data key>.<encrypted pem>
+//
+// where the wrapped data key and the encrypted pem are base64url encoded
+// AES-256-GCM ciphertexts with the nonce prepended.
+const encryptedPEMPrefix = "lhenc1."
+
+// kekLen is the length of key-encryption keys and data keys (AES-256).
+const kekLen = 32
+
+// KEK is a key-encryption key used for the envelope encryption of private
+// keys stored in the database.
+type KEK struct {
+	// ID identifies the key-encryption key; it is stored alongside the
+	// encrypted private keys, so that the correct key can be selected for
+	// decryption.
+	ID string
+	// Key is the 32 byte AES-256 key.
+	Key []byte
+}
+
+// NewKEK creates a KEK from the raw key; the ID is derived from the key.
+func NewKEK(key []byte) (KEK, error) {
+	if len(key) != kekLen {
+		return KEK{}, errors.Errorf("key-encryption key must be %d bytes, got %d", kekLen, len(key))
+	}
+	sum := sha256.Sum256(key)
+	return KEK{
+		ID:  hex.EncodeToString(sum[:8]),
+		Key: key,
+	}, nil
+}
+
+// ParseKEK parses a base64 (standard or url encoding) encoded key-encryption
+// key, e.g. as generated by `openssl rand -base64 32`.
+func ParseKEK(encoded string) (KEK, error) {
+	encoded = strings.TrimSpace(encoded)
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if key, err := enc.DecodeString(encoded); err == nil {
+			return NewKEK(key)
+		}
+	}
+	return KEK{}, errors.New("key-encryption key is not base64 encoded")
+}
+
+// ReadKEKFile reads a base64 encoded key-encryption key from a file.
+func ReadKEKFile(path string) (KEK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return KEK{}, errors.Wrap(err, "failed to read key-encryption key file")
+	}
+	kek, err := ParseKEK(string(data))
+	return kek, errors.Wrapf(err, "invalid key-encryption key in '%s'", path)
+}
+
+// KEKProvider provides the key-encryption keys for the encryption of
+// private keys at rest. Implementations can be backed by external key
+// management systems.
+type KEKProvider interface {
+	// CurrentKEK returns the key-encryption key that is used to encrypt
+	// data keys.
+	CurrentKEK() (KEK, error)
+	// KEK returns the key-encryption key with the passed ID; it is used to
+	// decrypt data keys. Previous keys must be returned until all private
+	// keys have been re-keyed.
+	KEK(id string) (KEK, error)
+}
+
+// StaticKEKProvider is a KEKProvider with a fixed set of keys.
+type StaticKEKProvider struct {
+	current  KEK
+	previous map[string]KEK
+}
+
+// NewStaticKEKProvider returns a StaticKEKProvider that encrypts with the
+// current key and can additionally decrypt with the previous keys.
+func NewStaticKEKProvider(current KEK, previous ...KEK) *StaticKEKProvider {
+	p := &StaticKEKProvider{
+		current:  current,
+		previous: make(map[string]KEK, len(previous)),
+	}
+	for _, k := range previous {
+		p.previous[k.ID] = k
+	}
+	return p
+}
+
+// CurrentKEK implements the KEKProvider interface.
+func (p *StaticKEKProvider) CurrentKEK() (KEK, error) {
+	return p.current, nil
+}
+
+// KEK implements the KEKProvider interface.
+func (p *StaticKEKProvider) KEK(id string) (KEK, error) {
+	if id == p.current.ID {
+		return p.current, nil
+	}
+	if k, ok := p.previous[id]; ok {
+		return k, nil
+	}
+	return KEK{}, errors.Errorf("unknown key-encryption key '%s'", id)
+}
+
+// IsEncryptedPEM reports whether the stored private key data is encrypted.
+func IsEncryptedPEM(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedPEMPrefix))
+}
+
+// encryptedPEM is the parsed form of an encrypted private key.
+type encryptedPEM struct {
+	kekID      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+func parseEncryptedPEM(data []byte) (*encryptedPEM, error) {
+	parts := strings.Split(strings.TrimPrefix(string(data), encryptedPEMPrefix), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed encrypted private key")
+	}
+	wrappedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed encrypted private key")
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed encrypted private key")
+	}
+	return &encryptedPEM{
+		kekID:      parts[0],
+		wrappedKey: wrappedKey,
+		ciphertext: ciphertext,
+	}, nil
+}
+
+func (e encryptedPEM) marshal() []byte {
+	return []byte(
+		encryptedPEMPrefix + e.kekID + "." +
+			base64.RawURLEncoding.EncodeToString(e.wrappedKey) + "." +
+			base64.RawURLEncoding.EncodeToString(e.ciphertext),
+	)
+}
+
+// encryptPEM encrypts the private key with a fresh data key and wraps the
+// data key with the current key-encryption key. The kid is bound to the
+// ciphertext, so that encrypted keys cannot be swapped between rows.
+func encryptPEM(p KEKProvider, kid string, pem []byte) ([]byte, error) {
+	kek, err := p.CurrentKEK()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get key-encryption key")
+	}
+	dataKey := make([]byte, kekLen)
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, errors.Wrap(err, "failed to generate data key")
+	}
+	ciphertext, err := sealAESGCM(dataKey, pem, []byte(kid))
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := sealAESGCM(kek.Key, dataKey, []byte(kek.ID))
+	if err != nil {
+		return nil, err
+	}
+	return encryptedPEM{
+		kekID:      kek.ID,
+		wrappedKey: wrappedKey,
+		ciphertext: ciphertext,
+	}.marshal(), nil
+}
+
+// unwrapDataKey decrypts the data key of an encrypted private key.
+func unwrapDataKey(p KEKProvider, e *encryptedPEM) ([]byte, error) {
+	kek, err := p.KEK(e.kekID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := openAESGCM(kek.Key, e.wrappedKey, []byte(kek.ID))
+	return dataKey, errors.Wrap(err, "failed to unwrap data key")
+}
+
+// decryptPEM decrypts a private key encrypted with encryptPEM.
+func decryptPEM(p KEKProvider, kid string, data []byte) ([]byte, error) {
+	e, err := parseEncryptedPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := unwrapDataKey(p, e)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := openAESGCM(dataKey, e.ciphertext, []byte(kid))
+	return pem, errors.Wrap(err, "failed to decrypt private key")
+}
+
+// rewrapPEM wraps the data key of an encrypted private key with the current
+// key-encryption key; the encrypted private key itself is unchanged.
+func rewrapPEM(p KEKProvider, data []byte) ([]byte, error) {
+	e, err := parseEncryptedPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := p.CurrentKEK()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get key-encryption key")
+	}
+	if e.kekID == kek.ID {
+		return data, nil
+	}
+	dataKey, err := unwrapDataKey(p, e)
+	if err != nil {
+		return nil, err
+	}
+	if e.wrappedKey, err = sealAESGCM(kek.Key, dataKey, []byte(kek.ID)); err != nil {
+		return nil, err
+	}
+	e.kekID = kek.ID
+	return e.marshal(), nil
+}
+
+// sealAESGCM encrypts data with AES-GCM; the nonce is prepended to the
+// ciphertext.
+func sealAESGCM(key, data, additionalData []byte) ([]byte, error) {
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return gcm.Seal(nonce, nonce, data, additionalData), nil
+}
+
+// openAESGCM decrypts data encrypted with sealAESGCM.
+func openAESGCM(key, data, additionalData []byte) ([]byte, error) {
+	gcm, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	return plain, errors.WithStack(err)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"database/sql"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"time"
 
@@ -34,6 +36,12 @@ func (s *StatsStorage) InsertBatch(entries []*stats.RequestLog) error {
 		return nil
 	}
 
+	for _, e := range entries {
+		if e.SampleRate < 1 {
+			e.SampleRate = 1
+		}
+	}
+
 	// Use batch size based on driver
 	batchSize := 500
 	if s.driver == "sqlite" {
@@ -48,16 +56,17 @@ func (s *StatsStorage) GetSummary(from, to time.Time) (*stats.Summary, error) {
 	var summary stats.Summary
 
 	// Get total counts
+	// Sampled requests count sample_rate times
 	var result struct {
 		TotalRequests int64
 		TotalErrors   int64
-		AvgLatency    float64
+		DurationSum   float64
 	}
 
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("COUNT(*) as total_requests, "+
-			"SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END) as total_errors, "+
-			"AVG(duration_ms) as avg_latency").
+		Select("SUM(sample_rate) as total_requests, "+
+			"SUM(CASE WHEN status_code >= 400 THEN sample_rate ELSE 0 END) as total_errors, "+
+			"SUM(duration_ms * sample_rate) as duration_sum").
 		Where("timestamp BETWEEN ? AND ?", from, to).
 		Scan(&result).Error
 	if err != nil {
@@ -66,10 +75,10 @@ func (s *StatsStorage) GetSummary(from, to time.Time) (*stats.Summary, error) {
 
 	summary.TotalRequests = result.TotalRequests
 	summary.TotalErrors = result.TotalErrors
-	summary.AvgLatencyMs = result.AvgLatency
 
 	if summary.TotalRequests > 0 {
 		summary.ErrorRate = float64(summary.TotalErrors) / float64(summary.TotalRequests)
+		summary.AvgLatencyMs = result.DurationSum / float64(summary.TotalRequests)
 	}
 
 	// Get unique counts
@@ -102,7 +111,7 @@ func (s *StatsStorage) GetSummary(from, to time.Time) (*stats.Summary, error) {
 		Count      int64
 	}
 	err = s.db.Model(&stats.RequestLog{}).
-		Select("status_code, COUNT(*) as count").
+		Select("status_code, SUM(sample_rate) as count").
 		Where("timestamp BETWEEN ? AND ?", from, to).
 		Group("status_code").
 		Scan(&statusCounts).Error
@@ -121,7 +130,7 @@ func (s *StatsStorage) GetSummary(from, to time.Time) (*stats.Summary, error) {
 		Count    int64
 	}
 	err = s.db.Model(&stats.RequestLog{}).
-		Select("endpoint, COUNT(*) as count").
+		Select("endpoint, SUM(sample_rate) as count").
 		Where("timestamp BETWEEN ? AND ?", from, to).
 		Group("endpoint").
 		Scan(&endpointCounts).Error
@@ -149,7 +158,7 @@ func (s *StatsStorage) GetTopEndpoints(from, to time.Time, limit int) ([]stats.T
 	}
 
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("endpoint, COUNT(*) as count").
+		Select("endpoint, SUM(sample_rate) as count").
 		Where("timestamp BETWEEN ? AND ?", from, to).
 		Group("endpoint").
 		Order("count DESC").
@@ -178,7 +187,7 @@ func (s *StatsStorage) GetTopUserAgents(from, to time.Time, limit int) ([]stats.
 	}
 
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("user_agent, COUNT(*) as count").
+		Select("user_agent, SUM(sample_rate) as count").
 		Where("timestamp BETWEEN ? AND ? AND user_agent != ''", from, to).
 		Group("user_agent").
 		Order("count DESC").
@@ -207,7 +216,7 @@ func (s *StatsStorage) GetTopClients(from, to time.Time, limit int) ([]stats.Top
 	}
 
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("client_ip, COUNT(*) as count").
+		Select("client_ip, SUM(sample_rate) as count").
 		Where("timestamp BETWEEN ? AND ? AND client_ip != ''", from, to).
 		Group("client_ip").
 		Order("count DESC").
@@ -236,7 +245,7 @@ func (s *StatsStorage) GetTopCountries(from, to time.Time, limit int) ([]stats.T
 	}
 
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("country_code, COUNT(*) as count").
+		Select("country_code, SUM(sample_rate) as count").
 		Where("timestamp BETWEEN ? AND ? AND country_code != ''", from, to).
 		Group("country_code").
 		Order("count DESC").
@@ -264,7 +273,7 @@ func (s *StatsStorage) GetTopQueryParams(from, to time.Time, endpoint string, li
 	var logs []stats.RequestLog
 
 	query := s.db.Model(&stats.RequestLog{}).
-		Select("query_params").
+		Select("query_params, sample_rate").
 		Where("timestamp BETWEEN ? AND ? AND query_params IS NOT NULL", from, to)
 
 	if endpoint != "" {
@@ -282,7 +291,7 @@ func (s *StatsStorage) GetTopQueryParams(from, to time.Time, endpoint string, li
 	for _, log := range logs {
 		if log.QueryParams != nil {
 			paramStr := string(log.QueryParams)
-			paramCounts[paramStr]++
+			paramCounts[paramStr] += int64(max(log.SampleRate, 1))
 		}
 	}
 
@@ -311,32 +320,175 @@ func (s *StatsStorage) GetTopQueryParams(from, to time.Time, endpoint string, li
 	return entries, nil
 }
 
-// GetTimeSeries returns time series data for the given time range.
+// GetTimeSeries returns time series data for the given time range. Complete
+// days and hours are read from the daily and hourly aggregates where these
+// are available, only the remaining parts of the range from the detailed
+// logs.
 func (s *StatsStorage) GetTimeSeries(from, to time.Time, endpoint string, interval stats.Interval) ([]stats.TimeSeriesPoint, error) {
+	series := newTimeSeries(interval)
+	if err := s.addTimeSeries(series, from, to, true, endpoint, s.timeSeriesTiers(interval)); err != nil {
+		return nil, err
+	}
+	return series.points(), nil
+}
+
+// statsTier is an aggregation tier that covers complete periods between its
+// watermarks. Aggregates before the lower watermark have been purged or were
+// never aggregated.
+type statsTier struct {
+	period    time.Duration
+	watermark func() (lower, upper time.Time, err error)
+}
+
+// hourlyTier returns the tier of the hourly aggregates.
+func (s *StatsStorage) hourlyTier() statsTier {
+	return statsTier{
+		period: time.Hour,
+		watermark: func() (time.Time, time.Time, error) {
+			return s.tierWatermarks(&stats.HourlyStats{}, "hour", time.Hour)
+		},
+	}
+}
+
+// dailyTier returns the tier of the daily aggregates.
+func (s *StatsStorage) dailyTier() statsTier {
+	return statsTier{
+		period: 24 * time.Hour,
+		watermark: func() (time.Time, time.Time, error) {
+			return s.tierWatermarks(&stats.DailyStats{}, "date", 24*time.Hour)
+		},
+	}
+}
+
+// tierWatermarks returns the start of the first and the end of the last
+// aggregated period of a tier, or zero times if nothing is aggregated.
+func (s *StatsStorage) tierWatermarks(model any, column string, period time.Duration) (
+	lower, upper time.Time, err error,
+) {
+	if upper, err = s.maxTime(model, column); err != nil || upper.IsZero() {
+		return time.Time{}, time.Time{}, err
+	}
+	if lower, err = s.minTime(model, column); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return lower, upper.Add(period), nil
+}
+
+// timeSeriesTiers returns the aggregation tiers that can serve a time series
+// with the given interval, the cheapest first. Buckets smaller than the
+// period of a tier can only be served from the detailed logs.
+func (s *StatsStorage) timeSeriesTiers(interval stats.Interval) []statsTier {
+	switch interval {
+	case stats.IntervalMinute:
+		return nil
+	case stats.IntervalDay, stats.IntervalWeek, stats.IntervalMonth:
+		return []statsTier{
+			s.dailyTier(),
+			s.hourlyTier(),
+		}
+	default:
+		return []statsTier{s.hourlyTier()}
+	}
+}
+
+// splitByTier splits the range from - to into the part that is covered by
+// complete periods of the tier, and the remaining edges. ok is false if the
+// tier does not cover any part of the range.
+func splitByTier(from, to time.Time, tier statsTier) (start, end time.Time, ok bool, err error) {
+	start = from.UTC().Truncate(tier.period)
+	if start.Before(from) {
+		start = start.Add(tier.period)
+	}
+	end = to.UTC().Truncate(tier.period)
+	lower, upper, err := tier.watermark()
+	if err != nil {
+		return
+	}
+	if start.Before(lower) {
+		start = lower.UTC().Truncate(tier.period)
+	}
+	if end.After(upper) {
+		end = upper.UTC().Truncate(tier.period)
+	}
+	ok = start.Before(end)
+	return
+}
+
+// addTimeSeries adds the requests of the range from - to to series. The first
+// tier is used for the part of the range that it covers, the edges are
+// handled by the next tiers and finally by the detailed logs. toInclusive
+// controls whether requests at exactly to are included.
+func (s *StatsStorage) addTimeSeries(
+	series *timeSeries, from, to time.Time, toInclusive bool, endpoint string, tiers []statsTier,
+) error {
+	if len(tiers) == 0 {
+		return s.addDetailedTimeSeries(series, from, to, toInclusive, endpoint)
+	}
+	start, end, ok, err := splitByTier(from, to, tiers[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.addTimeSeries(series, from, to, toInclusive, endpoint, tiers[1:])
+	}
+	if from.Before(start) {
+		if err = s.addTimeSeries(series, from, start, false, endpoint, tiers[1:]); err != nil {
+			return err
+		}
+	}
+	if tiers[0].period == time.Hour {
+		err = s.addHourlyTimeSeries(series, start, end, endpoint)
+	} else {
+		err = s.addDailyTimeSeries(series, start, end, endpoint)
+	}
+	if err != nil {
+		return err
+	}
+	if end.Before(to) || (toInclusive && end.Equal(to)) {
+		return s.addTimeSeries(series, end, to, toInclusive, endpoint, tiers[1:])
+	}
+	return nil
+}
+
+// addDetailedTimeSeries adds the requests of the range from - to from the
+// detailed logs to series.
+func (s *StatsStorage) addDetailedTimeSeries(
+	series *timeSeries, from, to time.Time, toInclusive bool, endpoint string,
+) error {
+	// Weeks and months are grouped by day in the database and merged into
+	// their buckets by the series, so all tiers use the same bucket start.
+	interval := series.interval
+	if interval == stats.IntervalWeek || interval == stats.IntervalMonth {
+		interval = stats.IntervalDay
+	}
 	// Build the date truncation based on driver and interval
 	var truncExpr string
 	switch s.driver {
 	case "postgres":
-		truncExpr = s.postgresDateTrunc(interval)
+		truncExpr = s.postgresDateTrunc(interval, "timestamp")
 	case "mysql":
-		truncExpr = s.mysqlDateTrunc(interval)
+		truncExpr = s.mysqlDateTrunc(interval, "timestamp")
 	default: // sqlite
-		truncExpr = s.sqliteDateTrunc(interval)
+		truncExpr = s.sqliteDateTrunc(interval, "timestamp")
 	}
 
 	var results []struct {
-		Bucket       time.Time
+		Bucket       string
 		RequestCount int64
 		ErrorCount   int64
-		AvgLatency   float64
+		DurationSum  float64
 	}
 
 	query := s.db.Model(&stats.RequestLog{}).
-		Select(truncExpr+" as bucket, "+
-			"COUNT(*) as request_count, "+
-			"SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END) as error_count, "+
-			"AVG(duration_ms) as avg_latency").
-		Where("timestamp BETWEEN ? AND ?", from, to)
+		Select(truncExpr + " as bucket, " +
+			"SUM(sample_rate) as request_count, " +
+			"SUM(CASE WHEN status_code >= 400 THEN sample_rate ELSE 0 END) as error_count, " +
+			"SUM(duration_ms * sample_rate) as duration_sum")
+	if toInclusive {
+		query = query.Where("timestamp BETWEEN ? AND ?", from, to)
+	} else {
+		query = query.Where("timestamp >= ? AND timestamp < ?", from, to)
+	}
 
 	if endpoint != "" {
 		query = query.Where("endpoint = ?", endpoint)
@@ -346,130 +498,600 @@ func (s *StatsStorage) GetTimeSeries(from, to time.Time, endpoint string, interv
 		Order("bucket ASC").
 		Scan(&results).Error
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	points := make([]stats.TimeSeriesPoint, len(results))
-	for i, r := range results {
-		points[i] = stats.TimeSeriesPoint{
-			Timestamp:    r.Bucket,
-			RequestCount: r.RequestCount,
-			ErrorCount:   r.ErrorCount,
-			AvgLatencyMs: r.AvgLatency,
+	for _, r := range results {
+		series.add(parseDBTime(r.Bucket), r.RequestCount, r.ErrorCount, r.DurationSum)
+	}
+	return nil
+}
+
+// addHourlyTimeSeries adds the hourly aggregates of the hours in start - end
+// (exclusive) to series.
+func (s *StatsStorage) addHourlyTimeSeries(series *timeSeries, start, end time.Time, endpoint string) error {
+	var rows []stats.HourlyStats
+	query := s.db.Select("hour, request_count, error_count, duration_sum_ms").
+		Where("hour >= ? AND hour < ?", start, end)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		series.add(r.Hour, r.RequestCount, r.ErrorCount, float64(r.DurationSumMs))
+	}
+	return nil
+}
+
+// addDailyTimeSeries adds the daily aggregates of the days in start - end
+// (exclusive) to series.
+func (s *StatsStorage) addDailyTimeSeries(series *timeSeries, start, end time.Time, endpoint string) error {
+	var rows []stats.DailyStats
+	query := s.db.Select("date, request_count, error_count, duration_avg_ms").
+		Where("date >= ? AND date < ?", start, end)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		series.add(
+			r.Date, r.RequestCount, r.ErrorCount, float64(r.DurationAvgMs)*float64(r.RequestCount),
+		)
+	}
+	return nil
+}
+
+// timeSeries merges the requests of the different tiers into the buckets of
+// a time series.
+type timeSeries struct {
+	interval stats.Interval
+	buckets  map[int64]*timeSeriesBucket
+}
+
+type timeSeriesBucket struct {
+	point       stats.TimeSeriesPoint
+	durationSum float64
+}
+
+func newTimeSeries(interval stats.Interval) *timeSeries {
+	return &timeSeries{
+		interval: interval,
+		buckets:  make(map[int64]*timeSeriesBucket),
+	}
+}
+
+// add adds requests at t to the bucket that contains t.
+func (ts *timeSeries) add(t time.Time, requests, errors int64, durationSum float64) {
+	t = truncateToInterval(t, ts.interval)
+	b, ok := ts.buckets[t.Unix()]
+	if !ok {
+		b = &timeSeriesBucket{point: stats.TimeSeriesPoint{Timestamp: t}}
+		ts.buckets[t.Unix()] = b
+	}
+	b.point.RequestCount += requests
+	b.point.ErrorCount += errors
+	b.durationSum += durationSum
+}
+
+// points returns the points of the time series in chronological order.
+func (ts *timeSeries) points() []stats.TimeSeriesPoint {
+	points := make([]stats.TimeSeriesPoint, 0, len(ts.buckets))
+	for _, b := range ts.buckets {
+		p := b.point
+		if p.RequestCount > 0 {
+			p.AvgLatencyMs = b.durationSum / float64(p.RequestCount)
 		}
+		points = append(points, p)
+	}
+	sort.Slice(
+		points, func(i, j int) bool {
+			return points[i].Timestamp.Before(points[j].Timestamp)
+		},
+	)
+	return points
+}
+
+// truncateToInterval returns the start of the bucket of the interval that
+// contains t. Weeks start on Monday.
+func truncateToInterval(t time.Time, interval stats.Interval) time.Time {
+	t = t.UTC()
+	switch interval {
+	case stats.IntervalMinute:
+		return t.Truncate(time.Minute)
+	case stats.IntervalDay:
+		return t.Truncate(24 * time.Hour)
+	case stats.IntervalWeek:
+		day := t.Truncate(24 * time.Hour)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case stats.IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t.Truncate(time.Hour)
 	}
-	return points, nil
 }
 
 // Date truncation helpers for different databases
-func (*StatsStorage) postgresDateTrunc(interval stats.Interval) string {
+func (*StatsStorage) postgresDateTrunc(interval stats.Interval, column string) string {
 	switch interval {
 	case stats.IntervalMinute:
-		return "date_trunc('minute', timestamp)"
+		return "date_trunc('minute', " + column + ")"
 	case stats.IntervalHour:
-		return "date_trunc('hour', timestamp)"
+		return "date_trunc('hour', " + column + ")"
 	case stats.IntervalDay:
-		return "date_trunc('day', timestamp)"
+		return "date_trunc('day', " + column + ")"
 	case stats.IntervalWeek:
-		return "date_trunc('week', timestamp)"
+		return "date_trunc('week', " + column + ")"
 	case stats.IntervalMonth:
-		return "date_trunc('month', timestamp)"
+		return "date_trunc('month', " + column + ")"
 	default:
-		return "date_trunc('hour', timestamp)"
+		return "date_trunc('hour', " + column + ")"
 	}
 }
 
-func (*StatsStorage) mysqlDateTrunc(interval stats.Interval) string {
+func (*StatsStorage) mysqlDateTrunc(interval stats.Interval, column string) string {
 	switch interval {
 	case stats.IntervalMinute:
-		return "DATE_FORMAT(timestamp, '%Y-%m-%d %H:%i:00')"
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d %H:%i:00')"
 	case stats.IntervalHour:
-		return "DATE_FORMAT(timestamp, '%Y-%m-%d %H:00:00')"
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d %H:00:00')"
 	case stats.IntervalDay:
-		return "DATE(timestamp)"
+		return "DATE(" + column + ")"
 	case stats.IntervalWeek:
-		return "DATE(DATE_SUB(timestamp, INTERVAL WEEKDAY(timestamp) DAY))"
+		return "DATE(DATE_SUB(" + column + ", INTERVAL WEEKDAY(" + column + ") DAY))"
 	case stats.IntervalMonth:
-		return "DATE_FORMAT(timestamp, '%Y-%m-01')"
+		return "DATE_FORMAT(" + column + ", '%Y-%m-01')"
 	default:
-		return "DATE_FORMAT(timestamp, '%Y-%m-%d %H:00:00')"
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d %H:00:00')"
 	}
 }
 
-func (*StatsStorage) sqliteDateTrunc(interval stats.Interval) string {
+func (*StatsStorage) sqliteDateTrunc(interval stats.Interval, column string) string {
 	switch interval {
 	case stats.IntervalMinute:
-		return "strftime('%Y-%m-%d %H:%M:00', timestamp)"
+		return "strftime('%Y-%m-%d %H:%M:00', " + column + ")"
 	case stats.IntervalHour:
-		return "strftime('%Y-%m-%d %H:00:00', timestamp)"
+		return "strftime('%Y-%m-%d %H:00:00', " + column + ")"
 	case stats.IntervalDay:
-		return "date(timestamp)"
+		return "date(" + column + ")"
 	case stats.IntervalWeek:
-		return "date(timestamp, 'weekday 0', '-7 days')"
+		return "date(" + column + ", 'weekday 0', '-7 days')"
 	case stats.IntervalMonth:
-		return "strftime('%Y-%m-01', timestamp)"
+		return "strftime('%Y-%m-01', " + column + ")"
 	default:
-		return "strftime('%Y-%m-%d %H:00:00', timestamp)"
+		return "strftime('%Y-%m-%d %H:00:00', " + column + ")"
 	}
 }
 
-// GetLatencyPercentiles calculates latency percentiles for the given time range.
+// GetLatencyPercentiles calculates latency percentiles for the given time
+// range. Complete hours are read from the latency histograms of the hourly
+// aggregates where these are available; the percentiles are then estimates,
+// while min, max and average stay exact. Ranges without aggregated hours are
+// calculated exactly from the detailed logs.
 func (s *StatsStorage) GetLatencyPercentiles(from, to time.Time, endpoint string) (*stats.LatencyStats, error) {
+	var (
+		durations []durationSample
+		hourly    *hourlyLatency
+	)
+	start, end, ok, err := splitByTier(from, to, s.hourlyTier())
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if hourly, err = s.getHourlyLatency(start, end, endpoint); err != nil {
+			return nil, err
+		}
+		if from.Before(start) {
+			if durations, err = s.getDurations(from, start, false, endpoint); err != nil {
+				return nil, err
+			}
+		}
+		if !end.After(to) {
+			upper, err := s.getDurations(end, to, true, endpoint)
+			if err != nil {
+				return nil, err
+			}
+			durations = append(durations, upper...)
+		}
+	} else if durations, err = s.getDurations(from, to, true, endpoint); err != nil {
+		return nil, err
+	}
+
+	if hourly == nil || hourly.histogram.Count() == 0 {
+		return exactLatencyStats(durations), nil
+	}
+	for _, d := range durations {
+		hourly.add(d)
+	}
+	h := hourly.histogram
+	return &stats.LatencyStats{
+		MinMs: hourly.min,
+		MaxMs: hourly.max,
+		AvgMs: float64(hourly.sum) / float64(h.Count()),
+		P50Ms: h.Percentile(50, hourly.min, hourly.max),
+		P75Ms: h.Percentile(75, hourly.min, hourly.max),
+		P90Ms: h.Percentile(90, hourly.min, hourly.max),
+		P95Ms: h.Percentile(95, hourly.min, hourly.max),
+		P99Ms: h.Percentile(99, hourly.min, hourly.max),
+	}, nil
+}
+
+// durationSample is the duration of a recorded request and the number of
+// requests it represents.
+type durationSample struct {
+	DurationMs int
+	SampleRate int
+}
+
+// getDurations returns the durations of the requests in the range from - to
+// from the detailed logs.
+func (s *StatsStorage) getDurations(
+	from, to time.Time, toInclusive bool, endpoint string,
+) ([]durationSample, error) {
 	// Fetch all durations and calculate percentiles in Go
 	// This is more portable across databases than using DB-specific percentile functions
-	var durations []int
+	var durations []durationSample
 
 	query := s.db.Model(&stats.RequestLog{}).
-		Select("duration_ms").
-		Where("timestamp BETWEEN ? AND ?", from, to)
+		Select("duration_ms, sample_rate")
+	if toInclusive {
+		query = query.Where("timestamp BETWEEN ? AND ?", from, to)
+	} else {
+		query = query.Where("timestamp >= ? AND timestamp < ?", from, to)
+	}
 
 	if endpoint != "" {
 		query = query.Where("endpoint = ?", endpoint)
 	}
 
 	// Limit to reasonable sample
-	err := query.Limit(100000).Pluck("duration_ms", &durations).Error
+	err := query.Limit(100000).Scan(&durations).Error
+	return durations, err
+}
+
+// exactLatencyStats calculates the latency stats of the given durations.
+// Sampled requests are weighted with their sample rate.
+func exactLatencyStats(durations []durationSample) *stats.LatencyStats {
+	if len(durations) == 0 {
+		return &stats.LatencyStats{}
+	}
+
+	// Sort for percentile calculation
+	sort.Slice(
+		durations, func(i, j int) bool {
+			return durations[i].DurationMs < durations[j].DurationMs
+		},
+	)
+
+	// Calculate total weight and average
+	var total, sum int64
+	for _, d := range durations {
+		w := d.weight()
+		total += w
+		sum += int64(d.DurationMs) * w
+	}
+
+	return &stats.LatencyStats{
+		MinMs: durations[0].DurationMs,
+		MaxMs: durations[len(durations)-1].DurationMs,
+		AvgMs: float64(sum) / float64(total),
+		P50Ms: percentile(durations, total, 50),
+		P75Ms: percentile(durations, total, 75),
+		P90Ms: percentile(durations, total, 90),
+		P95Ms: percentile(durations, total, 95),
+		P99Ms: percentile(durations, total, 99),
+	}
+}
+
+// weight returns the number of requests the sample represents.
+func (d durationSample) weight() int64 {
+	return int64(max(d.SampleRate, 1))
+}
+
+// hourlyLatency is the merged latency of several hourly aggregates.
+type hourlyLatency struct {
+	histogram stats.LatencyHistogram
+	sum       int64
+	min, max  int
+}
+
+func (l *hourlyLatency) add(d durationSample) {
+	if l.histogram.Count() == 0 || d.DurationMs < l.min {
+		l.min = d.DurationMs
+	}
+	l.max = max(l.max, d.DurationMs)
+	l.sum += int64(d.DurationMs) * d.weight()
+	l.histogram.Add(d.DurationMs, d.weight())
+}
+
+// getHourlyLatency merges the latency of the hourly aggregates of the hours
+// in start - end (exclusive).
+func (s *StatsStorage) getHourlyLatency(start, end time.Time, endpoint string) (*hourlyLatency, error) {
+	var rows []stats.HourlyStats
+	query := s.db.Select("request_count, duration_sum_ms, duration_min_ms, duration_max_ms, latency_histogram").
+		Where("hour >= ? AND hour < ?", start, end)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	latency := &hourlyLatency{histogram: stats.NewLatencyHistogram()}
+	for _, r := range rows {
+		var h stats.LatencyHistogram
+		if err := json.Unmarshal(r.LatencyHistogram, &h); err != nil || r.RequestCount == 0 {
+			continue
+		}
+		if latency.histogram.Count() == 0 || r.DurationMinMs < latency.min {
+			latency.min = r.DurationMinMs
+		}
+		latency.max = max(latency.max, r.DurationMaxMs)
+		latency.sum += r.DurationSumMs
+		latency.histogram.Merge(h)
+	}
+	return latency, nil
+}
+
+// GetEndpointStats returns the request and error counts per endpoint.
+func (s *StatsStorage) GetEndpointStats(from, to time.Time) ([]stats.EndpointStats, error) {
+	var results []stats.EndpointStats
+	err := s.db.Model(&stats.RequestLog{}).
+		Select("endpoint, SUM(sample_rate) as request_count, "+
+			"SUM(CASE WHEN status_code >= 400 THEN sample_rate ELSE 0 END) as error_count").
+		Where("timestamp BETWEEN ? AND ?", from, to).
+		Group("endpoint").
+		Order("endpoint ASC").
+		Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
+	return results, nil
+}
 
-	if len(durations) == 0 {
-		return &stats.LatencyStats{}, nil
+// GetTopSubjects returns the most requested subjects, optionally restricted
+// to an endpoint.
+func (s *StatsStorage) GetTopSubjects(from, to time.Time, endpoint string, limit int) ([]stats.SubjectStats, error) {
+	if limit <= 0 {
+		limit = 10
 	}
 
-	// Sort for percentile calculation
-	sort.Ints(durations)
+	query := s.db.Model(&stats.RequestLog{}).
+		Select("subject, SUM(sample_rate) as request_count, "+
+			"SUM(CASE WHEN status_code >= 400 THEN sample_rate ELSE 0 END) as error_count, "+
+			"MAX(timestamp) as last_requested").
+		Where("timestamp BETWEEN ? AND ? AND subject != ''", from, to)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	return s.scanSubjectStats(query.Group("subject").Order("request_count DESC").Limit(limit))
+}
 
-	result := &stats.LatencyStats{
-		MinMs: durations[0],
-		MaxMs: durations[len(durations)-1],
-		P50Ms: percentile(durations, 50),
-		P75Ms: percentile(durations, 75),
-		P90Ms: percentile(durations, 90),
-		P95Ms: percentile(durations, 95),
-		P99Ms: percentile(durations, 99),
+// GetTopTrustMarkSubjects returns the most requested combinations of trust
+// mark type and subject, optionally restricted to a trust mark type.
+func (s *StatsStorage) GetTopTrustMarkSubjects(
+	from, to time.Time, trustMarkType string, limit int,
+) ([]stats.SubjectStats, error) {
+	if limit <= 0 {
+		limit = 10
 	}
 
-	// Calculate average
-	var sum int64
-	for _, d := range durations {
-		sum += int64(d)
+	query := s.db.Model(&stats.RequestLog{}).
+		Select("trust_mark_type, subject, SUM(sample_rate) as request_count, "+
+			"SUM(CASE WHEN status_code >= 400 THEN sample_rate ELSE 0 END) as error_count, "+
+			"MAX(timestamp) as last_requested").
+		Where("timestamp BETWEEN ? AND ? AND trust_mark_type != ''", from, to)
+	if trustMarkType != "" {
+		query = query.Where("trust_mark_type = ?", trustMarkType)
+	}
+	return s.scanSubjectStats(
+		query.Group("trust_mark_type, subject").Order("request_count DESC").Limit(limit),
+	)
+}
+
+// GetRequestedSubjects returns the distinct subjects requested at an
+// endpoint.
+func (s *StatsStorage) GetRequestedSubjects(from, to time.Time, endpoint string) ([]string, error) {
+	var subjects []string
+	query := s.db.Model(&stats.RequestLog{}).
+		Distinct("subject").
+		Where("timestamp BETWEEN ? AND ? AND subject != ''", from, to)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	err := query.Pluck("subject", &subjects).Error
+	return subjects, err
+}
+
+// FirstRequestLogTime returns the time of the oldest retained request log, or
+// the zero time if there are none.
+func (s *StatsStorage) FirstRequestLogTime() (time.Time, error) {
+	return s.minTime(&stats.RequestLog{}, "timestamp")
+}
+
+// HasSampledRequests reports whether requests to endpoint between from and to
+// were recorded with sampling.
+func (s *StatsStorage) HasSampledRequests(from, to time.Time, endpoint string) (bool, error) {
+	var count int64
+	query := s.db.Model(&stats.RequestLog{}).
+		Where("timestamp BETWEEN ? AND ? AND sample_rate > 1", from, to)
+	if endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	err := query.Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// scanSubjectStats scans the results of a subject query. The last request
+// time is scanned as string, since sqlite returns aggregated timestamps as
+// text.
+func (*StatsStorage) scanSubjectStats(query *gorm.DB) ([]stats.SubjectStats, error) {
+	var results []struct {
+		Subject       string
+		TrustMarkType string
+		RequestCount  int64
+		ErrorCount    int64
+		LastRequested string
+	}
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	entries := make([]stats.SubjectStats, len(results))
+	for i, r := range results {
+		entries[i] = stats.SubjectStats{
+			Subject:       r.Subject,
+			TrustMarkType: r.TrustMarkType,
+			RequestCount:  r.RequestCount,
+			ErrorCount:    r.ErrorCount,
+			LastRequested: parseDBTime(r.LastRequested),
+		}
 	}
-	result.AvgMs = float64(sum) / float64(len(durations))
+	return entries, nil
+}
 
-	return result, nil
+// dbTimeLayouts are the layouts in which the databases return timestamps as
+// text.
+var dbTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
 }
 
-// percentile calculates the p-th percentile of a sorted slice.
-func percentile(sorted []int, p int) int {
+// parseDBTime parses a timestamp returned as text; it returns the zero time
+// if the timestamp cannot be parsed.
+func parseDBTime(v string) time.Time {
+	for _, layout := range dbTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// percentile calculates the p-th percentile of a sorted slice, in which each
+// duration counts with its weight; total is the sum of the weights.
+func percentile(sorted []durationSample, total int64, p int) int {
 	if len(sorted) == 0 {
 		return 0
 	}
-	idx := (p * len(sorted)) / 100
-	if idx >= len(sorted) {
-		idx = len(sorted) - 1
+	target := int64(p) * total / 100
+	var seen int64
+	for _, d := range sorted {
+		seen += d.weight()
+		if seen > target {
+			return d.DurationMs
+		}
+	}
+	return sorted[len(sorted)-1].DurationMs
+}
+
+// AggregateHourlyStats aggregates the detailed logs of the hour that contains
+// hour into hourly statistics. Existing statistics of the hour are replaced.
+// Hours without detailed logs are left untouched, so hours whose logs were
+// already purged keep their statistics.
+func (s *StatsStorage) AggregateHourlyStats(hour time.Time) error {
+	hour = hour.UTC().Truncate(time.Hour)
+	end := hour.Add(time.Hour)
+
+	rows, err := s.db.Model(&stats.RequestLog{}).
+		Select("endpoint, status_code, duration_ms, sample_rate").
+		Where("timestamp >= ? AND timestamp < ?", hour, end).
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type hourlyKey struct {
+		endpoint   string
+		statusCode int
+	}
+	aggregates := make(map[hourlyKey]*stats.HourlyStats)
+	histograms := make(map[hourlyKey]stats.LatencyHistogram)
+	for rows.Next() {
+		var (
+			key      hourlyKey
+			duration int
+			weight   int64
+		)
+		if err = rows.Scan(&key.endpoint, &key.statusCode, &duration, &weight); err != nil {
+			return err
+		}
+		// Sampled requests count sample_rate times
+		weight = max(weight, 1)
+		a, ok := aggregates[key]
+		if !ok {
+			a = &stats.HourlyStats{
+				Hour:          hour,
+				Endpoint:      key.endpoint,
+				StatusCode:    key.statusCode,
+				DurationMinMs: duration,
+			}
+			aggregates[key] = a
+			histograms[key] = stats.NewLatencyHistogram()
+		}
+		a.RequestCount += weight
+		if key.statusCode >= 400 {
+			a.ErrorCount += weight
+		}
+		a.DurationSumMs += int64(duration) * weight
+		a.DurationMinMs = min(a.DurationMinMs, duration)
+		a.DurationMaxMs = max(a.DurationMaxMs, duration)
+		histograms[key].Add(duration, weight)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(aggregates) == 0 {
+		return nil
+	}
+
+	records := make([]*stats.HourlyStats, 0, len(aggregates))
+	for key, a := range aggregates {
+		if a.LatencyHistogram, err = json.Marshal(histograms[key]); err != nil {
+			return err
+		}
+		records = append(records, a)
+	}
+	return s.db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := tx.Where("hour = ?", hour).Delete(&stats.HourlyStats{}).Error; err != nil {
+				return err
+			}
+			return tx.CreateInBatches(records, 100).Error
+		},
+	)
+}
+
+// LastHourlyAggregation returns the start of the latest aggregated hour, or
+// the zero time if no hour was aggregated yet.
+func (s *StatsStorage) LastHourlyAggregation() (time.Time, error) {
+	return s.maxTime(&stats.HourlyStats{}, "hour")
+}
+
+// maxTime returns the maximum of a time column, or the zero time if the table
+// is empty.
+func (s *StatsStorage) maxTime(model any, column string) (time.Time, error) {
+	return s.aggregateTime(model, "MAX("+column+")")
+}
+
+// minTime returns the minimum of a time column, or the zero time if the table
+// is empty.
+func (s *StatsStorage) minTime(model any, column string) (time.Time, error) {
+	return s.aggregateTime(model, "MIN("+column+")")
+}
+
+func (s *StatsStorage) aggregateTime(model any, expr string) (time.Time, error) {
+	var t sql.NullString
+	err := s.db.Model(model).Select(expr).Row().Scan(&t)
+	if err != nil || !t.Valid {
+		return time.Time{}, err
 	}
-	return sorted[idx]
+	return parseDBTime(t.String).UTC(), nil
 }
 
 // AggregateDailyStats aggregates detailed logs into daily statistics.
@@ -506,8 +1128,8 @@ func (s *StatsStorage) AggregateDailyStats(date time.Time) error {
 			ErrorCount   int64
 		}
 		err = s.db.Model(&stats.RequestLog{}).
-			Select("COUNT(*) as request_count, "+
-				"SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END) as error_count").
+			Select("SUM(sample_rate) as request_count, "+
+				"SUM(CASE WHEN status_code >= 400 THEN sample_rate ELSE 0 END) as error_count").
 			Where("timestamp >= ? AND timestamp < ? AND endpoint = ? AND status_code = ?",
 				date, endDate, combo.Endpoint, combo.StatusCode).
 			Scan(&counts).Error
@@ -562,31 +1184,18 @@ func (s *StatsStorage) AggregateDailyStats(date time.Time) error {
 
 // Helper methods for aggregation
 func (s *StatsStorage) getLatencyForEndpointStatus(from, to time.Time, endpoint string, statusCode int) (*stats.LatencyStats, error) {
-	var durations []int
+	var durations []durationSample
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("duration_ms").
+		Select("duration_ms, sample_rate").
 		Where("timestamp >= ? AND timestamp < ? AND endpoint = ? AND status_code = ?",
 			from, to, endpoint, statusCode).
 		Limit(10000).
-		Pluck("duration_ms", &durations).Error
+		Scan(&durations).Error
 	if err != nil || len(durations) == 0 {
 		return nil, err
 	}
 
-	sort.Ints(durations)
-	var sum int64
-	for _, d := range durations {
-		sum += int64(d)
-	}
-
-	return &stats.LatencyStats{
-		MinMs: durations[0],
-		MaxMs: durations[len(durations)-1],
-		P50Ms: percentile(durations, 50),
-		P95Ms: percentile(durations, 95),
-		P99Ms: percentile(durations, 99),
-		AvgMs: float64(sum) / float64(len(durations)),
-	}, nil
+	return exactLatencyStats(durations), nil
 }
 
 func (s *StatsStorage) getTopUserAgentsForDay(from, to time.Time, endpoint string, limit int) ([]stats.TopEntry, error) {
@@ -595,7 +1204,7 @@ func (s *StatsStorage) getTopUserAgentsForDay(from, to time.Time, endpoint strin
 		Count     int64
 	}
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("user_agent, COUNT(*) as count").
+		Select("user_agent, SUM(sample_rate) as count").
 		Where("timestamp >= ? AND timestamp < ? AND endpoint = ? AND user_agent != ''",
 			from, to, endpoint).
 		Group("user_agent").
@@ -618,7 +1227,7 @@ func (s *StatsStorage) getTopCountriesForDay(from, to time.Time, endpoint string
 		Count       int64
 	}
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("country_code, COUNT(*) as count").
+		Select("country_code, SUM(sample_rate) as count").
 		Where("timestamp >= ? AND timestamp < ? AND endpoint = ? AND country_code != ''",
 			from, to, endpoint).
 		Group("country_code").
@@ -641,7 +1250,7 @@ func (s *StatsStorage) getTopClientsForDay(from, to time.Time, endpoint string,
 		Count    int64
 	}
 	err := s.db.Model(&stats.RequestLog{}).
-		Select("client_ip, COUNT(*) as count").
+		Select("client_ip, SUM(sample_rate) as count").
 		Where("timestamp >= ? AND timestamp < ? AND endpoint = ? AND client_ip != ''",
 			from, to, endpoint).
 		Group("client_ip").
@@ -667,18 +1276,153 @@ func (s *StatsStorage) GetDailyStats(from, to time.Time) ([]stats.DailyStats, er
 	return results, err
 }
 
+// GetRequestLogsAfter returns up to limit request logs with an ID greater
+// than afterID in ascending order. If afterID is 0, the latest logs are
+// returned.
+func (s *StatsStorage) GetRequestLogsAfter(afterID uint64, limit int) ([]stats.RequestLog, error) {
+	var logs []stats.RequestLog
+	if afterID == 0 {
+		if err := s.db.Order("id DESC").Limit(limit).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		slices.Reverse(logs)
+		return logs, nil
+	}
+	err := s.db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
 // PurgeDetailedLogs deletes request logs older than the given time.
 func (s *StatsStorage) PurgeDetailedLogs(before time.Time) (int64, error) {
 	result := s.db.Where("timestamp < ?", before).Delete(&stats.RequestLog{})
 	return result.RowsAffected, result.Error
 }
 
+// PurgeHourlyStats deletes hourly stats older than the given time.
+func (s *StatsStorage) PurgeHourlyStats(before time.Time) (int64, error) {
+	result := s.db.Where("hour < ?", before).Delete(&stats.HourlyStats{})
+	return result.RowsAffected, result.Error
+}
+
 // PurgeAggregatedStats deletes daily stats older than the given time.
 func (s *StatsStorage) PurgeAggregatedStats(before time.Time) (int64, error) {
 	result := s.db.Where("date < ?", before).Delete(&stats.DailyStats{})
 	return result.RowsAffected, result.Error
 }
 
+// AnonymizeRequestLogs applies anonymize to the request logs in the given
+// time range and stores the logs for which it returns true. It returns the
+// number of changed logs.
+func (s *StatsStorage) AnonymizeRequestLogs(
+	from, to time.Time, anonymize func(entry *stats.RequestLog) bool,
+) (int64, error) {
+	const batchSize = 500
+	var (
+		changed int64
+		lastID  uint64
+	)
+	for {
+		var batch []*stats.RequestLog
+		err := s.db.Where("timestamp BETWEEN ? AND ? AND id > ?", from, to, lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&batch).Error
+		if err != nil {
+			return changed, err
+		}
+		if len(batch) == 0 {
+			return changed, nil
+		}
+		err = s.db.Transaction(
+			func(tx *gorm.DB) error {
+				for _, entry := range batch {
+					if !anonymize(entry) {
+						continue
+					}
+					err := tx.Model(&stats.RequestLog{}).
+						Where("id = ?", entry.ID).
+						Updates(
+							map[string]any{
+								"client_ip":       entry.ClientIP,
+								"user_agent":      entry.UserAgent,
+								"user_agent_hash": entry.UserAgentHash,
+								"query_params":    entry.QueryParams,
+							},
+						).Error
+					if err != nil {
+						return err
+					}
+					changed++
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return changed, err
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// AnonymizeDailyStats replaces the client IPs in the top clients of the daily
+// stats in the given time range with the result of clientIP. Clients that
+// end up with the same value are merged, clients with an empty value are
+// removed. It returns the number of changed
+// daily stats.
+func (s *StatsStorage) AnonymizeDailyStats(
+	from, to time.Time, clientIP func(ip string, date time.Time) string,
+) (int64, error) {
+	var rows []stats.DailyStats
+	err := s.db.Select("id, date, top_client_ips").
+		Where("date >= ? AND date <= ?", from, to).
+		Find(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var changed int64
+	for _, row := range rows {
+		var clients []stats.TopEntry
+		if len(row.TopClientIPs) == 0 || json.Unmarshal(row.TopClientIPs, &clients) != nil {
+			continue
+		}
+		counts := make(map[string]int64, len(clients))
+		modified := false
+		for _, c := range clients {
+			ip := clientIP(c.Value, row.Date)
+			if ip != c.Value {
+				modified = true
+			}
+			if ip != "" {
+				counts[ip] += c.Count
+			}
+		}
+		if !modified {
+			continue
+		}
+		merged := make([]stats.TopEntry, 0, len(counts))
+		for ip, count := range counts {
+			merged = append(merged, stats.TopEntry{Value: ip, Count: count})
+		}
+		sort.Slice(
+			merged, func(i, j int) bool {
+				return merged[i].Count > merged[j].Count
+			},
+		)
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return changed, err
+		}
+		err = s.db.Model(&stats.DailyStats{}).
+			Where("id = ?", row.ID).
+			Update("top_client_ips", json.RawMessage(data)).Error
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
 // ExportCSV exports request logs to CSV format.
 func (s *StatsStorage) ExportCSV(from, to time.Time, w io.Writer) error {
 	writer := csv.NewWriter(w)
@@ -688,7 +1432,7 @@ func (s *StatsStorage) ExportCSV(from, to time.Time, w io.Writer) error {
 	header := []string{
 		"timestamp", "endpoint", "method", "status_code", "duration_ms",
 		"client_ip", "country_code", "user_agent", "query_params",
-		"request_size", "response_size", "error_type",
+		"request_size", "response_size", "error_type", "sample_rate",
 	}
 	if err := writer.Write(header); err != nil {
 		return err
@@ -723,6 +1467,7 @@ func (s *StatsStorage) ExportCSV(from, to time.Time, w io.Writer) error {
 			fmt.Sprintf("%d", log.RequestSize),
 			fmt.Sprintf("%d", log.ResponseSize),
 			log.ErrorType,
+			fmt.Sprintf("%d", log.SampleRate),
 		}
 		if err := writer.Write(record); err != nil {
 			return err
@@ -0,0 +1,401 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	apistats "github.com/go-oidfed/lighthouse/api/stats"
+	"github.com/go-oidfed/lighthouse/internal/stats"
+)
+
+func newStatsTestStorage(t *testing.T) *StatsStorage {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", url.PathEscape(t.Name()))
+	db, err := Connect(
+		Config{
+			Driver: DriverSQLite,
+			DSN:    dsn,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err = MigrateStats(db); err != nil {
+		t.Fatalf("Failed to migrate stats: %v", err)
+	}
+	return NewStatsStorage(db)
+}
+
+// statsTestDay is a Monday.
+var statsTestDay = time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// fillStatsTestStorage adds request logs in the hours 10 to 12 of
+// statsTestDay.
+func fillStatsTestStorage(t *testing.T, s *StatsStorage) {
+	t.Helper()
+	entries := []*stats.RequestLog{
+		{Timestamp: statsTestDay.Add(10*time.Hour + 15*time.Minute), StatusCode: 200, DurationMs: 10},
+		{Timestamp: statsTestDay.Add(10*time.Hour + 45*time.Minute), StatusCode: 200, DurationMs: 30},
+		{Timestamp: statsTestDay.Add(11*time.Hour + 30*time.Minute), StatusCode: 500, DurationMs: 100},
+		{Timestamp: statsTestDay.Add(12*time.Hour + 10*time.Minute), StatusCode: 200, DurationMs: 20},
+	}
+	for _, e := range entries {
+		e.Endpoint = "/fetch"
+		e.Method = "GET"
+	}
+	if err := s.InsertBatch(entries); err != nil {
+		t.Fatalf("Failed to insert logs: %v", err)
+	}
+}
+
+func assertTimeSeries(t *testing.T, got []stats.TimeSeriesPoint, expected []stats.TimeSeriesPoint) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d points, got %d: %+v", len(expected), len(got), got)
+	}
+	for i, p := range expected {
+		g := got[i]
+		if !g.Timestamp.Equal(p.Timestamp) || g.RequestCount != p.RequestCount ||
+			g.ErrorCount != p.ErrorCount || g.AvgLatencyMs != p.AvgLatencyMs {
+			t.Errorf("Point %d: expected %+v, got %+v", i, p, g)
+		}
+	}
+}
+
+func TestStatsHourlyTier(t *testing.T) {
+	s := newStatsTestStorage(t)
+	fillStatsTestStorage(t, s)
+
+	from := statsTestDay.Add(10 * time.Hour)
+	to := statsTestDay.Add(12*time.Hour + 30*time.Minute)
+	expected := []stats.TimeSeriesPoint{
+		{Timestamp: statsTestDay.Add(10 * time.Hour), RequestCount: 2, AvgLatencyMs: 20},
+		{Timestamp: statsTestDay.Add(11 * time.Hour), RequestCount: 1, ErrorCount: 1, AvgLatencyMs: 100},
+		{Timestamp: statsTestDay.Add(12 * time.Hour), RequestCount: 1, AvgLatencyMs: 20},
+	}
+
+	// Before the aggregation, the detailed logs are used
+	points, err := s.GetTimeSeries(from, to, "", stats.IntervalHour)
+	if err != nil {
+		t.Fatalf("GetTimeSeries failed: %v", err)
+	}
+	assertTimeSeries(t, points, expected)
+
+	for _, hour := range []time.Duration{10, 11} {
+		if err = s.AggregateHourlyStats(statsTestDay.Add(hour * time.Hour)); err != nil {
+			t.Fatalf("AggregateHourlyStats failed: %v", err)
+		}
+	}
+	last, err := s.LastHourlyAggregation()
+	if err != nil {
+		t.Fatalf("LastHourlyAggregation failed: %v", err)
+	}
+	if !last.Equal(statsTestDay.Add(11 * time.Hour)) {
+		t.Errorf("Expected last aggregated hour 11:00, got %s", last)
+	}
+
+	// Without the detailed logs of the aggregated hours, the hourly stats
+	// must be used
+	if _, err = s.PurgeDetailedLogs(statsTestDay.Add(12 * time.Hour)); err != nil {
+		t.Fatalf("PurgeDetailedLogs failed: %v", err)
+	}
+	points, err = s.GetTimeSeries(from, to, "", stats.IntervalHour)
+	if err != nil {
+		t.Fatalf("GetTimeSeries failed: %v", err)
+	}
+	assertTimeSeries(t, points, expected)
+
+	points, err = s.GetTimeSeries(from, to, "/fetch", stats.IntervalDay)
+	if err != nil {
+		t.Fatalf("GetTimeSeries failed: %v", err)
+	}
+	assertTimeSeries(
+		t, points, []stats.TimeSeriesPoint{
+			{Timestamp: statsTestDay, RequestCount: 4, ErrorCount: 1, AvgLatencyMs: 40},
+		},
+	)
+
+	latency, err := s.GetLatencyPercentiles(from, to, "")
+	if err != nil {
+		t.Fatalf("GetLatencyPercentiles failed: %v", err)
+	}
+	if latency.MinMs != 10 || latency.MaxMs != 100 || latency.AvgMs != 40 {
+		t.Errorf("Expected exact min, max and avg, got %+v", latency)
+	}
+	if latency.P50Ms < 10 || latency.P50Ms > 30 || latency.P99Ms < 90 || latency.P99Ms > 100 {
+		t.Errorf("Unexpected percentile estimates: %+v", latency)
+	}
+
+	// Aggregating an hour without detailed logs keeps its stats
+	if err = s.AggregateHourlyStats(statsTestDay.Add(10 * time.Hour)); err != nil {
+		t.Fatalf("AggregateHourlyStats failed: %v", err)
+	}
+	purged, err := s.PurgeHourlyStats(statsTestDay.Add(11 * time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeHourlyStats failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged hourly stat, got %d", purged)
+	}
+}
+
+func TestStatsHourlyTierRetention(t *testing.T) {
+	s := newStatsTestStorage(t)
+	fillStatsTestStorage(t, s)
+	for _, hour := range []time.Duration{10, 11, 12} {
+		if err := s.AggregateHourlyStats(statsTestDay.Add(hour * time.Hour)); err != nil {
+			t.Fatalf("AggregateHourlyStats failed: %v", err)
+		}
+	}
+	// The hourly stats are kept shorter than the detailed logs
+	if _, err := s.PurgeHourlyStats(statsTestDay.Add(12 * time.Hour)); err != nil {
+		t.Fatalf("PurgeHourlyStats failed: %v", err)
+	}
+
+	// Hours before the oldest hourly stats are read from the detailed logs
+	from := statsTestDay.Add(10 * time.Hour)
+	to := statsTestDay.Add(13 * time.Hour)
+	points, err := s.GetTimeSeries(from, to, "", stats.IntervalHour)
+	if err != nil {
+		t.Fatalf("GetTimeSeries failed: %v", err)
+	}
+	assertTimeSeries(
+		t, points, []stats.TimeSeriesPoint{
+			{Timestamp: statsTestDay.Add(10 * time.Hour), RequestCount: 2, AvgLatencyMs: 20},
+			{Timestamp: statsTestDay.Add(11 * time.Hour), RequestCount: 1, ErrorCount: 1, AvgLatencyMs: 100},
+			{Timestamp: statsTestDay.Add(12 * time.Hour), RequestCount: 1, AvgLatencyMs: 20},
+		},
+	)
+
+	latency, err := s.GetLatencyPercentiles(from, to, "")
+	if err != nil {
+		t.Fatalf("GetLatencyPercentiles failed: %v", err)
+	}
+	if latency.MinMs != 10 || latency.MaxMs != 100 || latency.AvgMs != 40 {
+		t.Errorf("Expected the latencies of all hours, got %+v", latency)
+	}
+}
+
+func TestStatsDailyTier(t *testing.T) {
+	s := newStatsTestStorage(t)
+	fillStatsTestStorage(t, s)
+	if err := s.AggregateDailyStats(statsTestDay); err != nil {
+		t.Fatalf("AggregateDailyStats failed: %v", err)
+	}
+	if _, err := s.PurgeDetailedLogs(statsTestDay.Add(24 * time.Hour)); err != nil {
+		t.Fatalf("PurgeDetailedLogs failed: %v", err)
+	}
+
+	points, err := s.GetTimeSeries(
+		statsTestDay.Add(-24*time.Hour), statsTestDay.Add(48*time.Hour), "", stats.IntervalWeek,
+	)
+	if err != nil {
+		t.Fatalf("GetTimeSeries failed: %v", err)
+	}
+	// The daily stats store rounded averages per status code
+	assertTimeSeries(
+		t, points, []stats.TimeSeriesPoint{
+			{Timestamp: statsTestDay, RequestCount: 4, ErrorCount: 1, AvgLatencyMs: 40},
+		},
+	)
+}
+
+func TestStatsAnonymize(t *testing.T) {
+	s := newStatsTestStorage(t)
+	entries := []*stats.RequestLog{
+		{
+			Timestamp:   statsTestDay.Add(time.Hour),
+			ClientIP:    "192.0.2.17",
+			QueryParams: []byte(`{"sub":"https://rp.example.org","iss":"https://ta.example.org"}`),
+		},
+		{Timestamp: statsTestDay.Add(2 * time.Hour), ClientIP: "192.0.2.200"},
+		{Timestamp: statsTestDay.Add(48 * time.Hour), ClientIP: "2001:db8:1:2::1"},
+	}
+	for _, e := range entries {
+		e.Endpoint = "/fetch"
+		e.Method = "GET"
+		e.StatusCode = 200
+	}
+	if err := s.InsertBatch(entries); err != nil {
+		t.Fatalf("Failed to insert logs: %v", err)
+	}
+	if err := s.AggregateDailyStats(statsTestDay); err != nil {
+		t.Fatalf("AggregateDailyStats failed: %v", err)
+	}
+
+	anonymizer := stats.NewAnonymizer(
+		apistats.PrivacyConfig{
+			ClientIPMode:    apistats.ClientIPModeTruncate,
+			DropQueryParams: []string{"sub"},
+		},
+	)
+	from, to := statsTestDay, statsTestDay.Add(24*time.Hour)
+	changed, err := s.AnonymizeRequestLogs(from, to, anonymizer.Apply)
+	if err != nil {
+		t.Fatalf("AnonymizeRequestLogs failed: %v", err)
+	}
+	if changed != 2 {
+		t.Errorf("Expected 2 changed logs, got %d", changed)
+	}
+	// Anonymizing again does not change anything
+	if changed, _ = s.AnonymizeRequestLogs(from, to, anonymizer.Apply); changed != 0 {
+		t.Errorf("Expected anonymization to be idempotent, got %d changed logs", changed)
+	}
+
+	var logs []stats.RequestLog
+	if err = s.db.Order("id ASC").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if logs[0].ClientIP != "192.0.2.0" || logs[1].ClientIP != "192.0.2.0" {
+		t.Errorf("Expected truncated IPs, got %q and %q", logs[0].ClientIP, logs[1].ClientIP)
+	}
+	if string(logs[0].QueryParams) != `{"iss":"https://ta.example.org"}` {
+		t.Errorf("Expected dropped query param, got %s", logs[0].QueryParams)
+	}
+	if logs[2].ClientIP != "2001:db8:1:2::1" {
+		t.Errorf("Expected log outside of the range to be unchanged, got %q", logs[2].ClientIP)
+	}
+
+	changed, err = s.AnonymizeDailyStats(from, to, anonymizer.ClientIP)
+	if err != nil {
+		t.Fatalf("AnonymizeDailyStats failed: %v", err)
+	}
+	if changed != 1 {
+		t.Errorf("Expected 1 changed daily stat, got %d", changed)
+	}
+	daily, err := s.GetDailyStats(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(daily[0].TopClientIPs) != `[{"value":"192.0.2.0","count":2}]` {
+		t.Errorf("Expected merged top clients, got %s", daily[0].TopClientIPs)
+	}
+}
+
+func TestStatsSampleRate(t *testing.T) {
+	s := newStatsTestStorage(t)
+	entries := []*stats.RequestLog{
+		{Timestamp: statsTestDay.Add(10 * time.Hour), StatusCode: 200, DurationMs: 10, SampleRate: 10},
+		{Timestamp: statsTestDay.Add(10 * time.Hour), StatusCode: 500, DurationMs: 100, SampleRate: 10},
+		{Timestamp: statsTestDay.Add(10 * time.Hour), StatusCode: 200, DurationMs: 40},
+	}
+	for _, e := range entries {
+		e.Endpoint = "/fetch"
+		e.Method = "GET"
+	}
+	if err := s.InsertBatch(entries); err != nil {
+		t.Fatalf("Failed to insert logs: %v", err)
+	}
+
+	from, to := statsTestDay, statsTestDay.Add(24*time.Hour)
+	summary, err := s.GetSummary(from, to)
+	if err != nil {
+		t.Fatalf("GetSummary failed: %v", err)
+	}
+	if summary.TotalRequests != 21 || summary.TotalErrors != 10 {
+		t.Errorf("Expected extrapolated counts 21/10, got %d/%d", summary.TotalRequests, summary.TotalErrors)
+	}
+	if summary.AvgLatencyMs != 1140.0/21 {
+		t.Errorf("Expected weighted average latency, got %f", summary.AvgLatencyMs)
+	}
+	if summary.RequestsByStatus[200] != 11 {
+		t.Errorf("Expected 11 extrapolated requests with status 200, got %d", summary.RequestsByStatus[200])
+	}
+
+	if err = s.AggregateHourlyStats(statsTestDay.Add(10 * time.Hour)); err != nil {
+		t.Fatalf("AggregateHourlyStats failed: %v", err)
+	}
+	if _, err = s.PurgeDetailedLogs(to); err != nil {
+		t.Fatalf("PurgeDetailedLogs failed: %v", err)
+	}
+	points, err := s.GetTimeSeries(from, to, "", stats.IntervalHour)
+	if err != nil {
+		t.Fatalf("GetTimeSeries failed: %v", err)
+	}
+	assertTimeSeries(
+		t, points, []stats.TimeSeriesPoint{
+			{
+				Timestamp: statsTestDay.Add(10 * time.Hour), RequestCount: 21, ErrorCount: 10,
+				AvgLatencyMs: 1140.0 / 21,
+			},
+		},
+	)
+}
+
+func TestStatsRequestLogsAfter(t *testing.T) {
+	s := newStatsTestStorage(t)
+	fillStatsTestStorage(t, s)
+
+	latest, err := s.GetRequestLogsAfter(0, 2)
+	if err != nil {
+		t.Fatalf("GetRequestLogsAfter failed: %v", err)
+	}
+	if len(latest) != 2 || latest[0].ID >= latest[1].ID || latest[1].DurationMs != 20 {
+		t.Fatalf("Expected the latest 2 logs in ascending order, got %+v", latest)
+	}
+
+	newer, err := s.GetRequestLogsAfter(latest[0].ID-2, 10)
+	if err != nil {
+		t.Fatalf("GetRequestLogsAfter failed: %v", err)
+	}
+	if len(newer) != 3 || newer[0].ID != latest[0].ID-1 || newer[2].ID != latest[1].ID {
+		t.Fatalf("Expected the 3 logs after ID %d, got %+v", latest[0].ID-2, newer)
+	}
+
+	none, err := s.GetRequestLogsAfter(latest[1].ID, 10)
+	if err != nil {
+		t.Fatalf("GetRequestLogsAfter failed: %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("Expected no logs after the latest, got %+v", none)
+	}
+}
+
+func TestStatsRequestLogCoverage(t *testing.T) {
+	s := newStatsTestStorage(t)
+	first, err := s.FirstRequestLogTime()
+	if err != nil {
+		t.Fatalf("FirstRequestLogTime failed: %v", err)
+	}
+	if !first.IsZero() {
+		t.Errorf("Expected zero time without logs, got %v", first)
+	}
+
+	fillStatsTestStorage(t, s)
+	sampled := &stats.RequestLog{
+		Timestamp: statsTestDay.Add(13 * time.Hour), Endpoint: "/list", Method: "GET", StatusCode: 200,
+		SampleRate: 10,
+	}
+	if err = s.InsertBatch([]*stats.RequestLog{sampled}); err != nil {
+		t.Fatalf("Failed to insert logs: %v", err)
+	}
+	first, err = s.FirstRequestLogTime()
+	if err != nil {
+		t.Fatalf("FirstRequestLogTime failed: %v", err)
+	}
+	if expected := statsTestDay.Add(10*time.Hour + 15*time.Minute); !first.Equal(expected) {
+		t.Errorf("Expected first log at %v, got %v", expected, first)
+	}
+
+	from, to := statsTestDay, statsTestDay.Add(24*time.Hour)
+	for _, tc := range []struct {
+		endpoint string
+		from     time.Time
+		expected bool
+	}{
+		{"/fetch", from, false},
+		{"/list", from, true},
+		{"", from, true},
+		{"/list", statsTestDay.Add(14 * time.Hour), false},
+	} {
+		got, err := s.HasSampledRequests(tc.from, to, tc.endpoint)
+		if err != nil {
+			t.Fatalf("HasSampledRequests failed: %v", err)
+		}
+		if got != tc.expected {
+			t.Errorf("HasSampledRequests(%v, %q): expected %v, got %v", tc.from, tc.endpoint, tc.expected, got)
+		}
+	}
+}
@@ -26,7 +26,9 @@ type Storage struct {
 var models = []any{
 	&model.ExtendedSubordinateInfo{},
 	&model.SubordinateEntityType{},
+	&model.SubordinateLabel{},
 	&model.SubordinateEvent{},
+	&model.KeyEvent{},
 	&model.JWKS{},
 	&model.KeyValue{},
 	&model.PolicyOperator{},
@@ -48,6 +50,7 @@ var models = []any{
 // These are migrated separately when stats is enabled.
 var statsModels = []any{
 	&stats.RequestLog{},
+	&stats.HourlyStats{},
 	&stats.DailyStats{},
 }
 
@@ -129,6 +132,11 @@ func (s *Storage) SubordinateEventsStorage() *SubordinateEventsStorage {
 	return NewSubordinateEventsStorage(s.db)
 }
 
+// KeyEventsStorage returns a KeyEventsStorage
+func (s *Storage) KeyEventsStorage() *KeyEventsStorage {
+	return NewKeyEventsStorage(s.db)
+}
+
 // DBPublicKeyStorage returns a DBPublicKeyStorage
 func (s *Storage) DBPublicKeyStorage(typeID string) *DBPublicKeyStorage {
 	return NewDBPublicKeyStorage(s.db, typeID)
@@ -307,6 +315,21 @@ func (s *TrustMarkTypesStorage) List() ([]model.TrustMarkType, error) {
 	return items, nil
 }
 
+// ListPage returns a single page of trust mark types, sorted and searched according
+// to the list options.
+func (s *TrustMarkTypesStorage) ListPage(opts model.ListOptions) ([]model.TrustMarkType, model.PageInfo, error) {
+	items, page, err := findPage(
+		s.db.Model(&model.TrustMarkType{}), opts, listColumns{entityID: "trust_mark_type", search: []string{"trust_mark_type", "description"}},
+		func(item model.TrustMarkType) model.ListCursor {
+			return listCursor(opts.Sort, item.ID, item.CreatedAt, item.UpdatedAt, item.TrustMarkType)
+		},
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "trust_mark_types: list failed")
+	}
+	return items, page, nil
+}
+
 func (s *TrustMarkTypesStorage) Create(req model.AddTrustMarkType) (*model.TrustMarkType, error) {
 	var existing model.TrustMarkType
 	result := s.db.Unscoped().Where("trust_mark_type = ?", req.TrustMarkType).First(&existing)
@@ -356,6 +379,7 @@ func (s *TrustMarkTypesStorage) Update(ident string, req model.AddTrustMarkType)
 		return nil, err
 	}
 	item.TrustMarkType = req.TrustMarkType
+	item.Description = req.Description
 	if err = s.db.Save(item).Error; err != nil {
 		if isUniqueConstraintError(err) {
 			return nil, model.AlreadyExistsError("trust mark type already exists")
@@ -664,6 +688,21 @@ func (s *TrustMarkOwnersStorage) List() ([]model.TrustMarkOwner, error) {
 	return items, nil
 }
 
+// ListPage returns a single page of trust mark owners, sorted and searched according
+// to the list options.
+func (s *TrustMarkOwnersStorage) ListPage(opts model.ListOptions) ([]model.TrustMarkOwner, model.PageInfo, error) {
+	items, page, err := findPage(
+		s.db.Model(&model.TrustMarkOwner{}), opts, entityListColumns,
+		func(item model.TrustMarkOwner) model.ListCursor {
+			return listCursor(opts.Sort, item.ID, item.CreatedAt, item.UpdatedAt, item.EntityID)
+		}, "JWKS",
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "trust_mark_owners: list failed")
+	}
+	return items, page, nil
+}
+
 func (s *TrustMarkOwnersStorage) Create(req model.AddTrustMarkOwner) (*model.TrustMarkOwner, error) {
 	var existing model.TrustMarkOwner
 	result := s.db.Unscoped().Where("entity_id = ?", req.EntityID).First(&existing)
@@ -824,6 +863,21 @@ func (s *TrustMarkIssuersStorage) List() ([]model.TrustMarkIssuer, error) {
 	return items, nil
 }
 
+// ListPage returns a single page of trust mark issuers, sorted and searched according
+// to the list options.
+func (s *TrustMarkIssuersStorage) ListPage(opts model.ListOptions) ([]model.TrustMarkIssuer, model.PageInfo, error) {
+	items, page, err := findPage(
+		s.db.Model(&model.TrustMarkIssuer{}), opts, listColumns{entityID: "issuer", search: []string{"issuer", "description"}},
+		func(item model.TrustMarkIssuer) model.ListCursor {
+			return listCursor(opts.Sort, item.ID, item.CreatedAt, item.UpdatedAt, item.Issuer)
+		},
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "trust_mark_issuers: list failed")
+	}
+	return items, page, nil
+}
+
 func (s *TrustMarkIssuersStorage) Create(req model.AddTrustMarkIssuer) (*model.TrustMarkIssuer, error) {
 	if req.Issuer == "" {
 		return nil, model.AlreadyExistsError("issuer is required")
@@ -1161,21 +1215,29 @@ func (s *TrustMarkSpecStorage) Delete(ident string) error {
 	return nil
 }
 
-// ListSubjects returns all TrustMarkSubjects for a TrustMarkSpec
-func (s *TrustMarkSpecStorage) ListSubjects(specIdent string, status *model.Status) ([]model.TrustMarkSubject, error) {
+// ListSubjects returns a single page of TrustMarkSubjects for a
+// TrustMarkSpec, sorted and searched according to the list options.
+func (s *TrustMarkSpecStorage) ListSubjects(specIdent string, status *model.Status, opts model.ListOptions) (
+	[]model.TrustMarkSubject, model.PageInfo, error,
+) {
 	spec, err := s.findByIdent(specIdent)
 	if err != nil {
-		return nil, err
+		return nil, model.PageInfo{}, err
 	}
-	query := s.db.Where("trust_mark_spec_id = ?", spec.ID)
+	query := s.db.Model(&model.TrustMarkSubject{}).Where("trust_mark_spec_id = ?", spec.ID)
 	if status != nil {
 		query = query.Where("status = ?", *status)
 	}
-	var subjects []model.TrustMarkSubject
-	if err = query.Find(&subjects).Error; err != nil {
-		return nil, errors.Wrap(err, "trust_mark_specs: list subjects failed")
+	subjects, page, err := findPage(
+		query, opts, entityListColumns,
+		func(subject model.TrustMarkSubject) model.ListCursor {
+			return listCursor(opts.Sort, subject.ID, subject.CreatedAt, subject.UpdatedAt, subject.EntityID)
+		},
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "trust_mark_specs: list subjects failed")
 	}
-	return subjects, nil
+	return subjects, page, nil
 }
 
 // CreateSubject creates a new TrustMarkSubject for a TrustMarkSpec.
@@ -1281,7 +1343,7 @@ func (s *TrustMarkSpecStorage) DeleteSubject(specIdent, subjectIdent string) err
 	}
 
 	// Revoke all issued trust mark instances for this subject before deletion
-	s.revokeInstancesForSubject(existing.ID, existing.EntityID, specIdent)
+	s.revokeInstancesForSubject(existing.ID, existing.EntityID, specIdent, "subject deleted")
 
 	if err = s.db.Delete(existing).Error; err != nil {
 		return errors.Wrap(err, "trust_mark_specs: delete subject failed")
@@ -1303,7 +1365,9 @@ func (s *TrustMarkSpecStorage) ChangeSubjectStatus(specIdent, subjectIdent strin
 
 	// Revoke all issued trust mark instances if status is blocked or inactive
 	if status == model.StatusBlocked || status == model.StatusInactive {
-		s.revokeInstancesForSubject(existing.ID, existing.EntityID, specIdent)
+		s.revokeInstancesForSubject(
+			existing.ID, existing.EntityID, specIdent, fmt.Sprintf("subject status changed to %s", status),
+		)
 	}
 
 	return existing, nil
@@ -1311,14 +1375,11 @@ func (s *TrustMarkSpecStorage) ChangeSubjectStatus(specIdent, subjectIdent strin
 
 // revokeInstancesForSubject revokes all issued trust mark instances for a subject.
 // This is called when a subject's status changes to blocked/inactive or when deleted.
-func (s *TrustMarkSpecStorage) revokeInstancesForSubject(subjectID uint, entityID, specIdent string) {
+func (s *TrustMarkSpecStorage) revokeInstancesForSubject(subjectID uint, entityID, specIdent, reason string) {
 	now := int(time.Now().Unix())
 	result := s.db.Model(&model.IssuedTrustMarkInstance{}).
 		Where("trust_mark_subject_id = ? AND revoked = ?", subjectID, false).
-		Updates(map[string]any{
-			"revoked":    true,
-			"updated_at": now,
-		})
+		Updates(revocationUpdates(model.TrustMarkRevocation{Reason: reason}, now))
 
 	if result.Error != nil {
 		log.WithError(result.Error).WithFields(log.Fields{
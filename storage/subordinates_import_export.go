@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/go-oidfed/lighthouse/storage/model"
+)
+
+// SubordinateImportMode defines how an import handles subordinates that
+// already exist.
+type SubordinateImportMode string
+
+const (
+	// SubordinateImportModeFail aborts the whole import if a subordinate
+	// already exists.
+	SubordinateImportModeFail SubordinateImportMode = "fail"
+	// SubordinateImportModeSkip leaves existing subordinates unchanged.
+	SubordinateImportModeSkip SubordinateImportMode = "skip"
+	// SubordinateImportModeUpsert replaces existing subordinates with the
+	// imported data.
+	SubordinateImportModeUpsert SubordinateImportMode = "upsert"
+)
+
+// ParseSubordinateImportMode parses a SubordinateImportMode; an empty string
+// results in SubordinateImportModeFail.
+func ParseSubordinateImportMode(s string) (SubordinateImportMode, error) {
+	switch m := SubordinateImportMode(s); m {
+	case "":
+		return SubordinateImportModeFail, nil
+	case SubordinateImportModeFail, SubordinateImportModeSkip, SubordinateImportModeUpsert:
+		return m, nil
+	default:
+		return "", model.ValidationErrorFmt("invalid import mode '%s' (valid values: fail, skip, upsert)", s)
+	}
+}
+
+// SubordinateImportOptions configures ImportSubordinates.
+type SubordinateImportOptions struct {
+	// Mode defines how existing subordinates are handled.
+	Mode SubordinateImportMode
+	// DryRun only reports what would be done without changing anything.
+	DryRun bool
+	// Actor is recorded in the events created by the import.
+	Actor string
+}
+
+// SubordinateImportReport describes the outcome of an import.
+type SubordinateImportReport struct {
+	DryRun  bool     `json:"dry_run"`
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+// errDryRun is used to roll back the import transaction on a dry run.
+var errDryRun = errors.New("dry run")
+
+// ExportSubordinates returns all subordinates matching the filter in their
+// portable representation.
+func ExportSubordinates(
+	subordinates model.SubordinateStorageBackend, filter model.SubordinateFilter,
+) ([]model.SubordinateExport, error) {
+	infos, err := subordinates.FindExtended(filter)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]model.SubordinateExport, len(infos))
+	for i, info := range infos {
+		records[i] = model.NewSubordinateExport(info)
+	}
+	return records, nil
+}
+
+// EncodeSubordinateExports writes the records to w, either as a JSON array or
+// as newline-delimited JSON.
+func EncodeSubordinateExports(w io.Writer, records []model.SubordinateExport, ndjson bool) error {
+	if !ndjson {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeSubordinateExports parses records that are either a JSON array or
+// newline-delimited JSON. Records without a status get
+// model.DefaultSubordinateStatus.
+func DecodeSubordinateExports(data []byte) ([]model.SubordinateExport, error) {
+	data = bytes.TrimSpace(data)
+	var raws []json.RawMessage
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, model.ValidationErrorFmt("invalid JSON: %s", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			l := bytes.TrimSpace(scanner.Bytes())
+			if len(l) == 0 {
+				continue
+			}
+			if !json.Valid(l) {
+				return nil, model.ValidationErrorFmt("invalid JSON in line %d", line)
+			}
+			raws = append(raws, json.RawMessage(bytes.Clone(l)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	records := make([]model.SubordinateExport, len(raws))
+	for i, raw := range raws {
+		records[i].Status = model.DefaultSubordinateStatus
+		if err := json.Unmarshal(raw, &records[i]); err != nil {
+			return nil, model.ValidationErrorFmt("invalid record %d: %s", i+1, err)
+		}
+	}
+	return records, nil
+}
+
+// validateSubordinateExports checks that all records can be imported.
+func validateSubordinateExports(records []model.SubordinateExport) error {
+	seen := make(map[string]struct{}, len(records))
+	for i, r := range records {
+		if r.EntityID == "" {
+			return model.ValidationErrorFmt("record %d: missing entity_id", i+1)
+		}
+		if _, ok := seen[r.EntityID]; ok {
+			return model.ValidationErrorFmt("record %d: duplicate entity_id '%s'", i+1, r.EntityID)
+		}
+		seen[r.EntityID] = struct{}{}
+		if !r.Status.Valid() {
+			return model.ValidationErrorFmt("record %d (%s): invalid status", i+1, r.EntityID)
+		}
+		if r.Status == model.StatusActive && !r.HasKeys() {
+			return model.ValidationErrorFmt(
+				"record %d (%s): status cannot be active without keys", i+1, r.EntityID,
+			)
+		}
+		if err := model.ValidateLabels(r.Labels); err != nil {
+			return model.ValidationErrorFmt("record %d (%s): %s", i+1, r.EntityID, err)
+		}
+	}
+	return nil
+}
+
+// ImportSubordinates imports the passed records within a single transaction.
+// If any record fails, nothing is imported. For each created subordinate a
+// created event and for each replaced subordinate an updated event is recorded.
+// On a dry run the transaction is always rolled back, so the report describes
+// what would have been done.
+func ImportSubordinates(
+	backends model.Backends, records []model.SubordinateExport, opts SubordinateImportOptions,
+) (*SubordinateImportReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = SubordinateImportModeFail
+	}
+	if opts.DryRun && backends.Transaction == nil {
+		return nil, errors.New("dry run requires transaction support")
+	}
+	if err := validateSubordinateExports(records); err != nil {
+		return nil, err
+	}
+
+	var report SubordinateImportReport
+	err := backends.InTransaction(
+		func(tx *model.Backends) error {
+			report = SubordinateImportReport{
+				DryRun:  opts.DryRun,
+				Created: []string{},
+				Updated: []string{},
+				Skipped: []string{},
+			}
+			for _, r := range records {
+				if err := importSubordinate(tx, r, opts, &report); err != nil {
+					return err
+				}
+			}
+			if opts.DryRun {
+				return errDryRun
+			}
+			return nil
+		},
+	)
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// importSubordinate imports a single record and updates the report.
+func importSubordinate(
+	tx *model.Backends, r model.SubordinateExport, opts SubordinateImportOptions, report *SubordinateImportReport,
+) error {
+	existing, err := tx.Subordinates.Get(r.EntityID)
+	if err != nil {
+		return err
+	}
+	info := r.ExtendedSubordinateInfo()
+
+	eventType := model.EventTypeCreated
+	message := fmt.Sprintf("subordinate imported: %s", r.EntityID)
+	if existing != nil {
+		switch opts.Mode {
+		case SubordinateImportModeSkip:
+			report.Skipped = append(report.Skipped, r.EntityID)
+			return nil
+		case SubordinateImportModeUpsert:
+			eventType = model.EventTypeUpdated
+			message = fmt.Sprintf("subordinate replaced by import: %s", r.EntityID)
+		default:
+			return model.AlreadyExistsErrorFmt("subordinate with entity_id %s already exists", r.EntityID)
+		}
+	} else {
+		if err = tx.Subordinates.Add(
+			model.ExtendedSubordinateInfo{
+				BasicSubordinateInfo: model.BasicSubordinateInfo{
+					EntityID: r.EntityID,
+					Status:   r.Status,
+				},
+			},
+		); err != nil {
+			return err
+		}
+	}
+	if err = tx.Subordinates.Replace(r.EntityID, info); err != nil {
+		return err
+	}
+	stored, err := tx.Subordinates.Get(r.EntityID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return model.NotFoundErrorFmt("subordinate '%s' not found after import", r.EntityID)
+	}
+	if err = model.RecordEvent(
+		tx.SubordinateEvents,
+		stored.ID,
+		eventType,
+		model.WithStatus(stored.Status),
+		model.WithMessage(message),
+		model.WithActor(opts.Actor),
+	); err != nil {
+		return err
+	}
+	if existing != nil {
+		report.Updated = append(report.Updated, r.EntityID)
+	} else {
+		report.Created = append(report.Created, r.EntityID)
+	}
+	return nil
+}
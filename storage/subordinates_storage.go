@@ -67,6 +67,11 @@ func (s *SubordinateStorage) Add(info model.ExtendedSubordinateInfo) error {
 						}
 					}
 
+					// Replace old labels with the new ones
+					if err := replaceLabels(tx, existing.ID, info.Labels); err != nil {
+						return err
+					}
+
 					// Copy back the ID for the caller
 					info.ID = existing.ID
 					return nil
@@ -80,12 +85,18 @@ func (s *SubordinateStorage) Add(info model.ExtendedSubordinateInfo) error {
 			// Save entity types separately to handle them with their own ON CONFLICT clause
 			entityTypes := info.SubordinateEntityTypes
 			info.SubordinateEntityTypes = nil // Prevent GORM from auto-creating associations
+			labels := info.Labels
+			info.Labels = nil
 
 			// Create the subordinate info (without associations)
 			if err := tx.Create(&info).Error; err != nil {
 				return err
 			}
 
+			if err := replaceLabels(tx, info.ID, labels); err != nil {
+				return err
+			}
+
 			// Insert entity type rows separately
 			if len(entityTypes) > 0 {
 				for i := range entityTypes {
@@ -180,7 +191,7 @@ func (s *SubordinateStorage) Get(entityID string) (*model.ExtendedSubordinateInf
 	var dbInfo model.ExtendedSubordinateInfo
 	result := s.db.Where(
 		"entity_id = ?", entityID,
-	).Preload("SubordinateEntityTypes").Preload("SubordinateAdditionalClaims").Preload("JWKS").First(&dbInfo)
+	).Preload("SubordinateEntityTypes").Preload("Labels").Preload("SubordinateAdditionalClaims").Preload("JWKS").First(&dbInfo)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -196,7 +207,7 @@ func (s *SubordinateStorage) Get(entityID string) (*model.ExtendedSubordinateInf
 // GetByDBID retrieves a subordinate by DB primary key
 func (s *SubordinateStorage) GetByDBID(id string) (*model.ExtendedSubordinateInfo, error) {
 	var dbInfo model.ExtendedSubordinateInfo
-	result := s.db.Preload("SubordinateEntityTypes").Preload("SubordinateAdditionalClaims").Preload("JWKS").First(
+	result := s.db.Preload("SubordinateEntityTypes").Preload("Labels").Preload("SubordinateAdditionalClaims").Preload("JWKS").First(
 		&dbInfo, id,
 	)
 	if result.Error != nil {
@@ -275,6 +286,8 @@ func (s *SubordinateStorage) Update(entityID string, info model.ExtendedSubordin
 			// Save entity types separately to handle them with their own ON CONFLICT clause
 			entityTypes := info.SubordinateEntityTypes
 			info.SubordinateEntityTypes = nil // Prevent GORM from auto-creating associations
+			labels := info.Labels
+			info.Labels = nil
 
 			// Upsert the subordinate info (without associations)
 			if err := tx.Clauses(
@@ -314,11 +327,126 @@ func (s *SubordinateStorage) Update(entityID string, info model.ExtendedSubordin
 					return errors.Wrap(err, "failed to insert subordinate entity types")
 				}
 			}
+
+			// Labels are only replaced if they were passed
+			if labels != nil {
+				if err := replaceLabels(tx, info.ID, labels); err != nil {
+					return err
+				}
+			}
 			return nil
 		},
 	)
 }
 
+// Replace overwrites all data of the subordinate identified by entityID with
+// the passed info. Unlike Update, this also replaces the entity types, labels,
+// JWKS, and additional claims, so that afterwards the stored subordinate
+// exactly reflects info.
+func (s *SubordinateStorage) Replace(entityID string, info model.ExtendedSubordinateInfo) error {
+	return s.db.Transaction(
+		func(tx *gorm.DB) error {
+			var existing model.ExtendedSubordinateInfo
+			if err := tx.Where("entity_id = ?", entityID).First(&existing).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return model.NotFoundErrorFmt("subordinate '%s' not found", entityID)
+				}
+				return err
+			}
+			oldJWKSID := existing.JWKSID
+
+			existing.JWKSID = nil
+			if info.JWKS.Keys.Set != nil && info.JWKS.Keys.Len() > 0 {
+				jwks := model.NewJWKS(info.JWKS.Keys)
+				if err := tx.Create(&jwks).Error; err != nil {
+					return errors.Wrap(err, "failed to create JWKS")
+				}
+				existing.JWKSID = &jwks.ID
+			}
+			existing.Status = info.Status
+			existing.Description = info.Description
+			existing.Metadata = info.Metadata
+			existing.MetadataPolicy = info.MetadataPolicy
+			existing.Constraints = info.Constraints
+			if err := tx.Omit(clause.Associations).Save(&existing).Error; err != nil {
+				return errors.Wrap(err, "failed to update subordinate")
+			}
+			if oldJWKSID != nil {
+				if err := tx.Unscoped().Delete(&model.JWKS{}, *oldJWKSID).Error; err != nil {
+					return errors.Wrap(err, "failed to delete old JWKS")
+				}
+			}
+
+			if err := tx.Where(
+				"subordinate_id = ?", existing.ID,
+			).Delete(&model.SubordinateEntityType{}).Error; err != nil {
+				return errors.Wrap(err, "failed to delete old entity types")
+			}
+			if len(info.SubordinateEntityTypes) > 0 {
+				entityTypes := make([]model.SubordinateEntityType, len(info.SubordinateEntityTypes))
+				for i, et := range info.SubordinateEntityTypes {
+					entityTypes[i] = model.SubordinateEntityType{
+						SubordinateID: existing.ID,
+						EntityType:    et.EntityType,
+					}
+				}
+				if err := tx.Create(&entityTypes).Error; err != nil {
+					return errors.Wrap(err, "failed to insert entity types")
+				}
+			}
+
+			if err := replaceLabels(tx, existing.ID, info.Labels); err != nil {
+				return err
+			}
+
+			if err := tx.Unscoped().Where(
+				"subordinate_id = ?", existing.ID,
+			).Delete(&model.SubordinateAdditionalClaim{}).Error; err != nil {
+				return errors.Wrap(err, "failed to delete old additional claims")
+			}
+			if len(info.SubordinateAdditionalClaims) > 0 {
+				claims := make([]model.SubordinateAdditionalClaim, len(info.SubordinateAdditionalClaims))
+				for i, c := range info.SubordinateAdditionalClaims {
+					claims[i] = model.SubordinateAdditionalClaim{
+						SubordinateID: existing.ID,
+						Claim:         c.Claim,
+						Value:         c.Value,
+						Crit:          c.Crit,
+					}
+				}
+				if err := tx.Create(&claims).Error; err != nil {
+					return errors.Wrap(err, "failed to insert additional claims")
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// replaceLabels replaces all labels of the subordinate with the passed ones.
+func replaceLabels(tx *gorm.DB, subordinateID uint, labels model.SubordinateLabels) error {
+	if err := tx.Where(
+		"subordinate_id = ?", subordinateID,
+	).Delete(&model.SubordinateLabel{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete old labels")
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	rows := make([]model.SubordinateLabel, len(labels))
+	for i, l := range labels {
+		rows[i] = model.SubordinateLabel{
+			SubordinateID: subordinateID,
+			Key:           l.Key,
+			Value:         l.Value,
+		}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return errors.Wrap(err, "failed to insert subordinate labels")
+	}
+	return nil
+}
+
 // UpdateStatusByDBID updates status by DB primary key
 func (s *SubordinateStorage) UpdateStatusByDBID(id string, status model.Status) error {
 	return s.db.Transaction(
@@ -375,7 +503,7 @@ func (s *SubordinateStorage) UpdateJWKSByDBID(id string, jwks model.JWKS) (*mode
 // GetAll returns all subordinates
 func (s *SubordinateStorage) GetAll() ([]model.BasicSubordinateInfo, error) {
 	var infos []model.ExtendedSubordinateInfo
-	if err := s.db.Preload("SubordinateEntityTypes").Preload("SubordinateAdditionalClaims").Find(&infos).Error; err != nil {
+	if err := s.db.Preload("SubordinateEntityTypes").Preload("Labels").Preload("SubordinateAdditionalClaims").Find(&infos).Error; err != nil {
 		return nil, errors.Wrap(err, "failed to get all subordinates")
 	}
 	basics := make([]model.BasicSubordinateInfo, len(infos))
@@ -391,7 +519,7 @@ func (s *SubordinateStorage) GetByStatus(status model.Status) ([]model.BasicSubo
 	var infos []model.ExtendedSubordinateInfo
 	if err := s.db.Where(
 		"status = ?", status,
-	).Preload("SubordinateEntityTypes").Preload("SubordinateAdditionalClaims").Find(&infos).Error; err != nil {
+	).Preload("SubordinateEntityTypes").Preload("Labels").Preload("SubordinateAdditionalClaims").Find(&infos).Error; err != nil {
 		return nil, errors.Wrap(err, "failed to get subordinates by status")
 	}
 	basics := make([]model.BasicSubordinateInfo, len(infos))
@@ -445,6 +573,93 @@ func (s *SubordinateStorage) GetByStatusAndAnyEntityType(
 	return s.fetchByIDsBasic(ids)
 }
 
+// Find returns all subordinates matching the passed filter.
+func (s *SubordinateStorage) Find(filter model.SubordinateFilter) ([]model.BasicSubordinateInfo, error) {
+	var infos []model.ExtendedSubordinateInfo
+	if err := s.filterQuery(filter).Preload("SubordinateEntityTypes").Preload("Labels").Find(&infos).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to find subordinates")
+	}
+	basics := make([]model.BasicSubordinateInfo, len(infos))
+	for i := range infos {
+		basics[i] = infos[i].BasicSubordinateInfo
+	}
+	return basics, nil
+}
+
+// FindPage returns a single page of subordinates matching the passed filter,
+// sorted and searched according to the list options.
+func (s *SubordinateStorage) FindPage(filter model.SubordinateFilter, opts model.ListOptions) (
+	[]model.BasicSubordinateInfo, model.PageInfo, error,
+) {
+	infos, page, err := findPage(
+		s.filterQuery(filter), opts, entityListColumns,
+		func(info model.ExtendedSubordinateInfo) model.ListCursor {
+			return listCursor(opts.Sort, info.ID, info.CreatedAt, info.UpdatedAt, info.EntityID)
+		},
+		"SubordinateEntityTypes", "Labels",
+	)
+	if err != nil {
+		return nil, page, errors.Wrap(err, "failed to find subordinates")
+	}
+	basics := make([]model.BasicSubordinateInfo, len(infos))
+	for i := range infos {
+		basics[i] = infos[i].BasicSubordinateInfo
+	}
+	return basics, page, nil
+}
+
+// FindExtended returns the full info of all subordinates matching the passed
+// filter. In contrast to Get, no general fallbacks are applied, i.e. only the
+// subordinate-specific values are returned.
+func (s *SubordinateStorage) FindExtended(filter model.SubordinateFilter) ([]model.ExtendedSubordinateInfo, error) {
+	var infos []model.ExtendedSubordinateInfo
+	if err := s.filterQuery(filter).Order("entity_id").Preload("SubordinateEntityTypes").Preload("Labels").Preload(
+		"SubordinateAdditionalClaims",
+	).Preload("JWKS").Find(&infos).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to find subordinates")
+	}
+	return infos, nil
+}
+
+// filterQuery returns a query on the subordinates table restricted by the
+// passed filter.
+func (s *SubordinateStorage) filterQuery(filter model.SubordinateFilter) *gorm.DB {
+	db := s.db.Model(&model.ExtendedSubordinateInfo{})
+	if filter.Status != nil {
+		db = db.Where("status = ?", *filter.Status)
+	}
+	if len(filter.EntityTypes) > 0 {
+		db = db.Where(
+			"id IN (?)",
+			s.db.Model(&model.SubordinateEntityType{}).Select("subordinate_id").Where(
+				"entity_type IN ?", filter.EntityTypes,
+			),
+		)
+	}
+	return s.applyLabelSelector(db, filter.Labels)
+}
+
+// applyLabelSelector adds a sub query condition for each requirement of the
+// label selector to the passed query.
+func (s *SubordinateStorage) applyLabelSelector(db *gorm.DB, selector model.LabelSelector) *gorm.DB {
+	for _, r := range selector {
+		conds := map[string]any{"key": r.Key}
+		switch r.Operator {
+		case model.LabelSelectorOpEquals, model.LabelSelectorOpNotEquals,
+			model.LabelSelectorOpIn, model.LabelSelectorOpNotIn:
+			conds["value"] = r.Values
+		}
+		sub := s.db.Model(&model.SubordinateLabel{}).Select("subordinate_id").Where(conds)
+		switch r.Operator {
+		case model.LabelSelectorOpNotEquals, model.LabelSelectorOpNotIn, model.LabelSelectorOpDoesNotExist:
+			db = db.Where("id NOT IN (?)", sub)
+		default:
+			db = db.Where("id IN (?)", sub)
+		}
+	}
+	return db
+}
+
 // buildEntityTypeJoin returns matching subordinate IDs for a given optional status and entity types filter.
 // If status is provided and entityTypes is empty, returns nil IDs to signal status-only filtering.
 func (s *SubordinateStorage) buildEntityTypeJoin(status *model.Status, entityTypes []string, requireAll bool) (
@@ -483,7 +698,7 @@ func (s *SubordinateStorage) fetchByIDs(ids []uint) ([]model.ExtendedSubordinate
 	var infos []model.ExtendedSubordinateInfo
 	if err := s.db.Where(
 		"id IN ?", ids,
-	).Preload("SubordinateEntityTypes").Preload("SubordinateAdditionalClaims").Find(&infos).Error; err != nil {
+	).Preload("SubordinateEntityTypes").Preload("Labels").Preload("SubordinateAdditionalClaims").Find(&infos).Error; err != nil {
 		return nil, errors.Wrap(err, "failed to load subordinates by ids")
 	}
 	return infos, nil
@@ -9,10 +9,33 @@ import (
 	"github.com/go-oidfed/lighthouse/storage/model"
 )
 
+// SubordinateListingConfig holds configuration for the subordinate listing endpoint
+type SubordinateListingConfig struct {
+	// Store is used to look up the subordinates
+	Store model.SubordinateStorageBackend
+	// TrustMarkStore is used for the trust_marked and trust_mark_type parameters
+	TrustMarkStore model.TrustMarkedEntitiesStorageBackend
+	// AllowLabelSelector enables the non-standard label_selector parameter,
+	// which filters subordinates by their labels
+	AllowLabelSelector bool
+}
+
 // AddSubordinateListingEndpoint adds a subordinate listing endpoint
 func (fed *LightHouse) AddSubordinateListingEndpoint(
 	endpoint EndpointConf, store model.SubordinateStorageBackend,
 	trustMarkStore model.TrustMarkedEntitiesStorageBackend,
+) {
+	fed.AddSubordinateListingEndpointWithConfig(
+		endpoint, SubordinateListingConfig{
+			Store:          store,
+			TrustMarkStore: trustMarkStore,
+		},
+	)
+}
+
+// AddSubordinateListingEndpointWithConfig adds a subordinate listing endpoint with full configuration
+func (fed *LightHouse) AddSubordinateListingEndpointWithConfig(
+	endpoint EndpointConf, config SubordinateListingConfig,
 ) {
 	fed.fedMetadata.FederationListEndpoint = endpoint.ValidateURL(fed.FederationEntity.EntityID())
 	if endpoint.Path == "" {
@@ -20,7 +43,7 @@ func (fed *LightHouse) AddSubordinateListingEndpoint(
 	}
 	fed.server.Get(
 		endpoint.Path, func(ctx *fiber.Ctx) error {
-			return handleSubordinateListing(ctx, store, trustMarkStore)
+			return handleSubordinateListing(ctx, config)
 		},
 	)
 }
@@ -30,12 +53,12 @@ type SubordinateListingRequest struct {
 	Intermediate  bool     `json:"intermediate" query:"intermediate"`
 	TrustMarked   bool     `json:"trust_marked" query:"trust_marked"`
 	TrustMarkType string   `json:"trust_mark_type" query:"trust_mark_type"`
+	LabelSelector string   `json:"label_selector" query:"label_selector"`
 }
 
-func handleSubordinateListing(
-	ctx *fiber.Ctx, subordinates model.SubordinateStorageBackend,
-	trustMarkedEntitiesStorage model.TrustMarkedEntitiesStorageBackend,
-) error {
+func handleSubordinateListing(ctx *fiber.Ctx, config SubordinateListingConfig) error {
+	subordinates := config.Store
+	trustMarkedEntitiesStorage := config.TrustMarkStore
 	var req SubordinateListingRequest
 	if err := ctx.QueryParser(&req); err != nil {
 		ctx.Status(fiber.StatusBadRequest)
@@ -45,6 +68,15 @@ func handleSubordinateListing(
 		ctx.Status(fiber.StatusBadRequest)
 		return ctx.JSON(oidfed.ErrorUnsupportedParameter("parameter 'intermediate' is not supported"))
 	}
+	if req.LabelSelector != "" && !config.AllowLabelSelector {
+		ctx.Status(fiber.StatusBadRequest)
+		return ctx.JSON(oidfed.ErrorUnsupportedParameter("parameter 'label_selector' is not supported"))
+	}
+	selector, err := model.ParseLabelSelector(req.LabelSelector)
+	if err != nil {
+		ctx.Status(fiber.StatusBadRequest)
+		return ctx.JSON(oidfed.ErrorInvalidRequest(err.Error()))
+	}
 	if trustMarkedEntitiesStorage == nil {
 		if req.TrustMarked {
 			ctx.Status(fiber.StatusBadRequest)
@@ -56,10 +88,19 @@ func handleSubordinateListing(
 		}
 	}
 	var infos []model.BasicSubordinateInfo
-	var err error
-	if req.EntityType != nil {
+	switch {
+	case !selector.Empty():
+		active := model.StatusActive
+		infos, err = subordinates.Find(
+			model.SubordinateFilter{
+				Status:      &active,
+				EntityTypes: req.EntityType,
+				Labels:      selector,
+			},
+		)
+	case req.EntityType != nil:
 		infos, err = subordinates.GetByStatusAndAnyEntityType(model.StatusActive, req.EntityType)
-	} else {
+	default:
 		infos, err = subordinates.GetByStatus(model.StatusActive)
 	}
 	if err != nil {
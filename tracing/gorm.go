@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormPluginName is the name of the GORM plugin and prefix of its callbacks.
+const gormPluginName = "lighthouse:tracing"
+
+// gormSpanKey is the instance key under which the span of an operation is
+// stored between the before and after callbacks.
+const gormSpanKey = gormPluginName + ":span"
+
+// GormPlugin is a gorm.Plugin that records a client span for each database
+// operation. The span is a child of the span in the statement's context, so
+// storage calls are linked to a request if the context is passed with
+// db.WithContext.
+type GormPlugin struct{}
+
+// NewGormPlugin returns a new GormPlugin.
+func NewGormPlugin() *GormPlugin {
+	return &GormPlugin{}
+}
+
+// Name implements the gorm.Plugin interface.
+func (*GormPlugin) Name() string {
+	return gormPluginName
+}
+
+// Initialize implements the gorm.Plugin interface by registering callbacks
+// around all operations.
+func (*GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	before := gormPluginName + ":before"
+	after := gormPluginName + ":after"
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register(before, startGormSpan("create")),
+		cb.Create().After("gorm:create").Register(after, endGormSpan),
+		cb.Query().Before("gorm:query").Register(before, startGormSpan("select")),
+		cb.Query().After("gorm:query").Register(after, endGormSpan),
+		cb.Update().Before("gorm:update").Register(before, startGormSpan("update")),
+		cb.Update().After("gorm:update").Register(after, endGormSpan),
+		cb.Delete().Before("gorm:delete").Register(before, startGormSpan("delete")),
+		cb.Delete().After("gorm:delete").Register(after, endGormSpan),
+		cb.Row().Before("gorm:row").Register(before, startGormSpan("row")),
+		cb.Row().After("gorm:row").Register(after, endGormSpan),
+		cb.Raw().Before("gorm:raw").Register(before, startGormSpan("raw")),
+		cb.Raw().After("gorm:raw").Register(after, endGormSpan),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startGormSpan returns a callback that starts a span for the operation and
+// stores it in the statement's context and the instance.
+func startGormSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		name := "db." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := Tracer().Start(
+			db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemNameKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// endGormSpan ends the span started by startGormSpan and records the query
+// (without values) and the error, if any.
+func endGormSpan(db *gorm.DB) {
+	v, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(semconv.DBQueryText(db.Statement.SQL.String()))
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fiberHeaderCarrier adapts the request and response headers of a fiber
+// context to a propagation.TextMapCarrier.
+type fiberHeaderCarrier struct {
+	c *fiber.Ctx
+}
+
+// Get returns the value of the request header key.
+func (h fiberHeaderCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+// Set sets the response header key.
+func (h fiberHeaderCarrier) Set(key, value string) {
+	h.c.Set(key, value)
+}
+
+// Keys returns the names of the request headers.
+func (h fiberHeaderCarrier) Keys() []string {
+	keys := make([]string, 0)
+	for key := range h.c.GetReqHeaders() {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Middleware returns a fiber middleware that records a server span for each
+// request. The trace context of the caller is taken from the traceparent and
+// tracestate headers. The span is stored in the user context of the fiber
+// context, so handlers can create child spans from ctx.UserContext().
+// server is recorded as attribute to distinguish the servers, e.g. "main" or
+// "admin".
+func Middleware(server string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), fiberHeaderCarrier{c})
+		method := strings.Clone(c.Method())
+		path := strings.Clone(c.Path())
+		ctx, span := Tracer().Start(
+			ctx, method+" "+path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("lighthouse.server", server),
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(path),
+				semconv.ClientAddress(strings.Clone(c.IP())),
+				semconv.UserAgentOriginal(strings.Clone(string(c.Request().Header.UserAgent()))),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		if route := c.Route(); route != nil && route.Path != "" {
+			span.SetName(method + " " + route.Path)
+			span.SetAttributes(semconv.HTTPRoute(route.Path))
+		}
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+			span.RecordError(err)
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return err
+	}
+}
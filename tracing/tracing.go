@@ -0,0 +1,200 @@
+// Package tracing sets up OpenTelemetry tracing for LightHouse. It provides
+// the tracer provider with an OTLP or stdout exporter, a fiber middleware for
+// incoming requests, an http.RoundTripper for outbound requests, and a GORM
+// plugin for storage calls. Trace context is propagated with the W3C
+// traceparent and tracestate headers.
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-oidfed/lighthouse/internal/version"
+)
+
+// instrumentationName is the name of the tracer used for all spans created
+// by LightHouse.
+const instrumentationName = "github.com/go-oidfed/lighthouse"
+
+// Exporter names
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// OTLP protocols
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// DefaultServiceName is the service name used if none is configured.
+const DefaultServiceName = "lighthouse"
+
+// Config holds the tracing configuration.
+type Config struct {
+	// Enabled controls whether spans are recorded and exported.
+	Enabled bool
+	// ServiceName is reported as service.name; defaults to DefaultServiceName.
+	ServiceName string
+	// Exporter is ExporterOTLP or ExporterStdout.
+	Exporter string
+	// SampleRatio is the ratio of new traces that are sampled. Requests
+	// with a sampled parent are always sampled.
+	SampleRatio float64
+	// OTLP configures the OTLP exporter. If fields are empty, the standard
+	// OTEL_EXPORTER_OTLP_* environment variables apply.
+	OTLP OTLPConfig
+	// Output is the writer for the stdout exporter; defaults to os.Stdout.
+	Output io.Writer
+}
+
+// OTLPConfig configures the OTLP exporter.
+type OTLPConfig struct {
+	// Protocol is ProtocolGRPC or ProtocolHTTP; defaults to ProtocolHTTP.
+	Protocol string
+	// Endpoint is the collector address, e.g. localhost:4318 for http or
+	// localhost:4317 for grpc.
+	Endpoint string
+	// Insecure disables TLS for the connection to the collector.
+	Insecure bool
+	// Headers are sent with each export request, e.g. for authentication.
+	Headers map[string]string
+}
+
+// enabled is set by Init if tracing is enabled.
+var enabled bool
+
+// Enabled returns true if tracing was enabled with Init.
+func Enabled() bool {
+	return enabled
+}
+
+// ShutdownFunc flushes pending spans and shuts down the tracer provider.
+type ShutdownFunc func(ctx context.Context) error
+
+// Init sets up the global tracer provider and the W3C trace context
+// propagator according to cfg. If tracing is disabled, the global no-op
+// provider is kept, so instrumented code has (almost) no overhead.
+func Init(ctx context.Context, cfg Config) (ShutdownFunc, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version.VERSION),
+		),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create tracing resource")
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	enabled = true
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(cfg.Exporter) {
+	case ExporterStdout:
+		out := cfg.Output
+		if out == nil {
+			out = os.Stdout
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(out))
+	case ExporterOTLP, "":
+		return newOTLPExporter(ctx, cfg.OTLP)
+	default:
+		return nil, errors.Errorf("unsupported tracing exporter '%s'", cfg.Exporter)
+	}
+}
+
+func newOTLPExporter(ctx context.Context, cfg OTLPConfig) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(cfg.Protocol) {
+	case ProtocolGRPC:
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ProtocolHTTP, "":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, errors.Errorf("unsupported otlp protocol '%s'", cfg.Protocol)
+	}
+}
+
+// Tracer returns the LightHouse tracer of the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a new internal span as child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if not nil, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
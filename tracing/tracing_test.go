@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceParent = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+)
+
+// setupRecorder installs a tracer provider that records spans in memory.
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(
+		func() {
+			otel.SetTracerProvider(prevProvider)
+			otel.SetTextMapPropagator(prevPropagator)
+		},
+	)
+	return recorder
+}
+
+func findSpan(t *testing.T, recorder *tracetest.SpanRecorder, prefix string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if strings.HasPrefix(span.Name(), prefix) {
+			return span
+		}
+	}
+	t.Fatalf("No span with name prefix %q recorded", prefix)
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	var handlerSpan trace.SpanContext
+	app := fiber.New()
+	app.Use(Middleware("main"))
+	app.Get(
+		"/items/:id", func(c *fiber.Ctx) error {
+			handlerSpan = trace.SpanContextFromContext(c.UserContext())
+			return c.Status(fiber.StatusTeapot).SendString("ok")
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", http.NoBody)
+	req.Header.Set("traceparent", testTraceParent)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	span := findSpan(t, recorder, "GET ")
+	if span.Name() != "GET /items/:id" {
+		t.Errorf("Expected span to be named after the route, got %q", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Expected server span, got %s", span.SpanKind())
+	}
+	if got := span.SpanContext().TraceID().String(); got != testTraceID {
+		t.Errorf("Expected trace id from traceparent, got %s", got)
+	}
+	if !span.Parent().IsRemote() {
+		t.Error("Expected remote parent")
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Error("Expected request span in the user context of the handler")
+	}
+	attrs := map[string]any{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["http.response.status_code"] != int64(fiber.StatusTeapot) {
+		t.Errorf("Expected status code attribute, got %v", attrs["http.response.status_code"])
+	}
+	if attrs["lighthouse.server"] != "main" {
+		t.Errorf("Expected server attribute, got %v", attrs["lighthouse.server"])
+	}
+}
+
+func TestTransport(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	var traceparent string
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				traceparent = r.Header.Get("traceparent")
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer srv.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewClient(nil).Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	span := findSpan(t, recorder, "HTTP GET")
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("Expected client span, got %s", span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Expected client span to be a child of the parent span")
+	}
+	if !strings.Contains(traceparent, span.SpanContext().SpanID().String()) {
+		t.Errorf("Expected traceparent with the client span, got %q", traceparent)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Error("Expected the request of the caller not to be modified")
+	}
+}
+
+type tracedModel struct {
+	ID   uint
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Use(NewGormPlugin()); err != nil {
+		t.Fatalf("Failed to register plugin: %v", err)
+	}
+	if err = db.AutoMigrate(&tracedModel{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := Start(context.Background(), "parent")
+	if err = db.WithContext(ctx).Create(&tracedModel{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var m tracedModel
+	if err = db.WithContext(ctx).First(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	create := findSpan(t, recorder, "db.create traced_models")
+	query := findSpan(t, recorder, "db.select traced_models")
+	for _, span := range []sdktrace.ReadOnlySpan{create, query} {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected %q to be a child of the parent span", span.Name())
+		}
+	}
+	var queryText string
+	for _, kv := range query.Attributes() {
+		if kv.Key == "db.query.text" {
+			queryText = kv.Value.AsString()
+		}
+	}
+	if !strings.Contains(queryText, "SELECT") {
+		t.Errorf("Expected query text attribute, got %q", queryText)
+	}
+}
+
+func TestInitStdout(t *testing.T) {
+	prevProvider := otel.GetTracerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	t.Cleanup(
+		func() {
+			otel.SetTracerProvider(prevProvider)
+			otel.SetTextMapPropagator(prevPropagator)
+			enabled = false
+		},
+	)
+
+	var out bytes.Buffer
+	shutdown, err := Init(
+		context.Background(), Config{
+			Enabled:     true,
+			ServiceName: "lighthouse-test",
+			Exporter:    ExporterStdout,
+			Output:      &out,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if !Enabled() {
+		t.Error("Expected tracing to be enabled")
+	}
+	_, span := Start(context.Background(), "test-span")
+	span.End()
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	for _, expected := range []string{"test-span", "lighthouse-test"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected exported span to contain %q", expected)
+		}
+	}
+}
+
+func TestInitDisabled(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if Enabled() {
+		t.Error("Expected tracing to be disabled")
+	}
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+}
+
+func TestInitInvalidExporter(t *testing.T) {
+	if _, err := Init(context.Background(), Config{Enabled: true, Exporter: "zipkin"}); err == nil {
+		t.Fatal("Expected error for unsupported exporter")
+	}
+}
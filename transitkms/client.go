@@ -0,0 +1,297 @@
+// Package transitkms implements a key management system that keeps private
+// keys in a remote signing service with an HTTP API compatible with the
+// HashiCorp Vault (or OpenBao) transit secrets engine. Private keys never
+// leave the signing service; LightHouse only obtains the public keys and
+// requests signatures.
+package transitkms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMount is the default mount path of the transit engine.
+const DefaultMount = "transit"
+
+// ErrKeyNotFound is returned if a key does not exist in the signing service.
+var ErrKeyNotFound = errors.New("transit key not found")
+
+// ClientConfig holds the configuration for connecting to the signing
+// service. Either Token or a client certificate (mTLS) must be set.
+type ClientConfig struct {
+	// Address is the base URL of the signing service, e.g.
+	// https://vault.example.org:8200
+	Address string
+	// Mount is the mount path of the transit engine; defaults to DefaultMount.
+	Mount string
+	// Token is sent in the X-Vault-Token header.
+	Token string
+	// Namespace is sent in the X-Vault-Namespace header, if set.
+	Namespace string
+	// CACertFile is a PEM file with the CA certificates to verify the server.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are the PEM encoded client certificate
+	// and key for mTLS authentication.
+	ClientCertFile string
+	ClientKeyFile  string
+	// Timeout is the timeout for requests; defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// Client is a client for the transit engine API.
+type Client struct {
+	baseURL   string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+// NewClient creates a new Client.
+func NewClient(conf ClientConfig) (*Client, error) {
+	if conf.Address == "" {
+		return nil, errors.New("transit: address must be set")
+	}
+	if conf.Token == "" && conf.ClientCertFile == "" {
+		return nil, errors.New("transit: token or client certificate must be set")
+	}
+	if (conf.ClientCertFile == "") != (conf.ClientKeyFile == "") {
+		return nil, errors.New("transit: client certificate and key must be set together")
+	}
+	mount := strings.Trim(conf.Mount, "/")
+	if mount == "" {
+		mount = DefaultMount
+	}
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if conf.CACertFile != "" {
+		caData, err := os.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "transit: failed to read ca certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, errors.New("transit: no certificates found in ca certificate file")
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.ClientCertFile, conf.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "transit: failed to load client certificate")
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	return &Client{
+		baseURL:   strings.TrimRight(conf.Address, "/") + "/v1/" + mount,
+		token:     conf.Token,
+		namespace: conf.Namespace,
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+	}, nil
+}
+
+type apiResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []string        `json:"errors"`
+}
+
+// do performs a request and decodes the data of the response into out.
+func (c *Client) do(method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "transit: request failed")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "transit: failed to read response")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrKeyNotFound
+	}
+	var res apiResponse
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &res); err != nil && resp.StatusCode < 300 {
+			return errors.Wrap(err, "transit: failed to decode response")
+		}
+	}
+	if resp.StatusCode >= 300 {
+		if len(res.Errors) > 0 {
+			return errors.Errorf("transit: %s %s: %s", method, path, strings.Join(res.Errors, "; "))
+		}
+		return errors.Errorf("transit: %s %s: unexpected status %d", method, path, resp.StatusCode)
+	}
+	if out == nil || len(res.Data) == 0 {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(res.Data, out), "transit: failed to decode response data")
+}
+
+// ListKeys returns the names of all keys.
+func (c *Client) ListKeys() ([]string, error) {
+	var res struct {
+		Keys []string `json:"keys"`
+	}
+	err := c.do("LIST", "/keys", nil, &res)
+	if errors.Is(err, ErrKeyNotFound) {
+		// The transit engine returns 404 if there are no keys
+		return nil, nil
+	}
+	return res.Keys, err
+}
+
+// KeyInfo describes a key and its versions.
+type KeyInfo struct {
+	Name string
+	// Type is the key type, e.g. ecdsa-p256 or rsa-2048
+	Type string
+	// LatestVersion is the version used if no version is passed
+	LatestVersion int
+	// PublicKeys holds the public key of each version
+	PublicKeys map[int]crypto.PublicKey
+}
+
+// ReadKey returns the key with the passed name including the public keys of
+// all versions; it returns ErrKeyNotFound if the key does not exist.
+func (c *Client) ReadKey(name string) (*KeyInfo, error) {
+	var res struct {
+		Name          string `json:"name"`
+		Type          string `json:"type"`
+		LatestVersion int    `json:"latest_version"`
+		Keys          map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if err := c.do(http.MethodGet, "/keys/"+url.PathEscape(name), nil, &res); err != nil {
+		return nil, err
+	}
+	info := &KeyInfo{
+		Name:          name,
+		Type:          res.Type,
+		LatestVersion: res.LatestVersion,
+		PublicKeys:    make(map[int]crypto.PublicKey, len(res.Keys)),
+	}
+	for v, k := range res.Keys {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Errorf("transit: invalid version '%s' of key '%s'", v, name)
+		}
+		pk, err := parsePublicKey(k.PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "transit: invalid public key of key '%s' version %d", name, version)
+		}
+		info.PublicKeys[version] = pk
+	}
+	return info, nil
+}
+
+// parsePublicKey parses a public key as returned by the transit engine:
+// a PEM encoded public key, or the base64 encoded raw key for ed25519.
+func parsePublicKey(data string) (crypto.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(data)); block != nil {
+		pk, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return pk, errors.WithStack(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("unknown public key format")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// CreateKey creates a new key of the passed type, e.g. ecdsa-p256.
+func (c *Client) CreateKey(name, keyType string) error {
+	return c.do(
+		http.MethodPost, "/keys/"+url.PathEscape(name), map[string]any{"type": keyType}, nil,
+	)
+}
+
+// RotateKey creates a new version of the key.
+func (c *Client) RotateKey(name string) error {
+	return c.do(http.MethodPost, "/keys/"+url.PathEscape(name)+"/rotate", nil, nil)
+}
+
+// SignRequest holds the parameters of a sign request.
+type SignRequest struct {
+	Input              string `json:"input"`
+	KeyVersion         int    `json:"key_version,omitempty"`
+	HashAlgorithm      string `json:"hash_algorithm,omitempty"`
+	Prehashed          bool   `json:"prehashed,omitempty"`
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
+	SaltLength         string `json:"salt_length,omitempty"`
+	// MarshalingAlgorithm is the encoding of ECDSA signatures; asn1 is the
+	// default.
+	MarshalingAlgorithm string `json:"marshaling_algorithm,omitempty"`
+}
+
+// Sign signs with the passed key and returns the raw signature.
+func (c *Client) Sign(name string, req SignRequest) ([]byte, error) {
+	var res struct {
+		Signature string `json:"signature"`
+	}
+	if err := c.do(http.MethodPost, "/sign/"+url.PathEscape(name), req, &res); err != nil {
+		return nil, err
+	}
+	// The signature has the format vault:v<version>:<base64 signature>
+	i := strings.LastIndex(res.Signature, ":")
+	if i < 0 {
+		return nil, errors.Errorf("transit: malformed signature '%s'", res.Signature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(res.Signature[i+1:])
+	if err != nil {
+		return nil, errors.Wrap(err, "transit: malformed signature")
+	}
+	return sig, nil
+}
+
+// keyRef returns a reference to a key version for log and error messages.
+func keyRef(name string, version int) string {
+	return fmt.Sprintf("%s:v%d", name, version)
+}
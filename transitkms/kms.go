@@ -0,0 +1,839 @@
+package transitkms
+
+import (
+	"cmp"
+	"crypto"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx"
+	"github.com/go-oidfed/lib/jwx/keymanagement/kms"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/go-oidfed/lib/unixtime"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/zachmann/go-utils/sliceutils"
+)
+
+// KMS implements kms.KeyManagementSystem with the private keys kept in the
+// transit engine. There is one transit key per signing algorithm; each
+// version of a transit key is a separate signing key with its own kid.
+// Generating a key creates the transit key or rotates it to a new version.
+// The lifecycle (nbf, exp, revocation) of the keys is tracked in the public
+// key storage, as for the other KMS implementations.
+type KMS struct {
+	kms.KMSConfig
+
+	client      *Client
+	keyPrefix   string
+	stateStorer kms.KMSStateStorer
+
+	PKs public.PublicKeyStorage
+
+	mu sync.RWMutex
+	// signers is a map of all loaded signers, where the key is the kid
+	signers map[string]crypto.Signer
+
+	// automatic rotation control
+	rotationStop chan struct{}
+	rotationWG   sync.WaitGroup
+}
+
+var _ kms.KeyManagementSystem = (*KMS)(nil)
+
+// NewKMS creates a new KMS. The transit keys are named keyPrefix followed
+// by the lower-cased algorithm, e.g. "lighthouse-federation-es256". The
+// caller must call Load() to initialize the keys.
+func NewKMS(
+	config kms.KMSConfig, client *Client, keyPrefix string, stateStorer kms.KMSStateStorer,
+	pks public.PublicKeyStorage,
+) *KMS {
+	return &KMS{
+		KMSConfig:   config,
+		client:      client,
+		keyPrefix:   keyPrefix,
+		stateStorer: stateStorer,
+		PKs:         pks,
+		signers:     make(map[string]crypto.Signer),
+	}
+}
+
+// KeyName returns the name of the transit key for the algorithm.
+func (k *KMS) KeyName(alg jwa.SignatureAlgorithm) string {
+	return k.keyPrefix + strings.ToLower(alg.String())
+}
+
+// GetPendingChanges returns the pending alg and default change, if any.
+func (k *KMS) GetPendingChanges() (*kms.PendingAlgChange, *kms.PendingDefaultChange) {
+	st, err := k.stateStorer.LoadScheduledState()
+	if err != nil {
+		log.WithError(err).Error("TransitKMS: failed to load scheduled state")
+		return nil, nil
+	}
+	return st.PendingAlgChange, st.PendingDefaultChange
+}
+
+// GetDefaultAlg returns the default algorithm
+func (k *KMS) GetDefaultAlg() jwa.SignatureAlgorithm {
+	return k.DefaultAlg
+}
+
+// GetAlgs returns the configured algorithms
+func (k *KMS) GetAlgs() []jwa.SignatureAlgorithm {
+	return k.Algs
+}
+
+// ChangeAlgs updates the set of configured signature algorithms and
+// loads or generates keys for new algorithms.
+func (k *KMS) ChangeAlgs(algs []jwa.SignatureAlgorithm) error {
+	if slices.Equal(k.Algs, algs) {
+		return nil
+	}
+	if !k.GenerateKeys {
+		return errors.New("changing algorithms dynamically (without a restart) is not supported when key generation is disabled")
+	}
+	k.StopAutomaticRotation()
+	k.Algs = algs
+	if err := k.Load(); err != nil {
+		return err
+	}
+	if k.KeyRotation.Enabled {
+		return k.StartAutomaticRotation()
+	}
+	return nil
+}
+
+// ChangeGenerateKeys toggles whether the KMS is allowed to create and rotate
+// transit keys.
+func (k *KMS) ChangeGenerateKeys(generate bool) error {
+	if k.GenerateKeys == generate {
+		return nil
+	}
+	k.GenerateKeys = generate
+	if generate {
+		return k.Load()
+	}
+	return nil
+}
+
+// ChangeRSAKeyLength sets the RSA key length; it only applies to transit
+// keys that are created afterwards, since the key type of an existing
+// transit key cannot be changed.
+func (k *KMS) ChangeRSAKeyLength(length int) error {
+	if _, err := keyType(jwa.RS256(), length); err != nil {
+		return err
+	}
+	k.RSAKeyLen = length
+	return nil
+}
+
+// ChangeDefaultAlgorithm sets the default algorithm; it must be part of the
+// configured algorithms.
+func (k *KMS) ChangeDefaultAlgorithm(alg jwa.SignatureAlgorithm) error {
+	if k.DefaultAlg.String() == alg.String() {
+		return nil
+	}
+	if !slices.ContainsFunc(
+		k.Algs,
+		func(a jwa.SignatureAlgorithm) bool { return a.String() == alg.String() },
+	) {
+		return errors.Errorf("algorithm '%s' not in configured algs '%v'", alg, k.Algs)
+	}
+	k.DefaultAlg = alg
+	return nil
+}
+
+// ChangeDefaultAlgorithmAt schedules a change of the default algorithm at a
+// specific time, after ensuring that a key for the algorithm is valid then.
+func (k *KMS) ChangeDefaultAlgorithmAt(alg jwa.SignatureAlgorithm, effectiveAt unixtime.Unixtime) error {
+	if alg.String() == "" {
+		return errors.New("invalid algorithm")
+	}
+	st, err := k.stateStorer.LoadScheduledState()
+	if err != nil {
+		return err
+	}
+	pending := st.PendingDefaultChange
+	if pending != nil && pending.Alg.String() == alg.String() && pending.EffectiveAt.Equal(effectiveAt.Time) {
+		return nil
+	}
+	if k.DefaultAlg.String() == alg.String() && pending == nil {
+		return nil
+	}
+	if !k.GenerateKeys {
+		return errors.New("scheduling default algorithm change requires key generation to be enabled")
+	}
+	if err = k.ensureFutureKey(alg, effectiveAt.Time); err != nil {
+		return err
+	}
+	st.PendingDefaultChange = &kms.PendingDefaultChange{
+		Alg:         alg,
+		EffectiveAt: effectiveAt,
+	}
+	return k.stateStorer.SaveScheduledState(st)
+}
+
+// ChangeKeyRotationConfig updates the automatic rotation settings and
+// restarts the rotation loop if needed.
+func (k *KMS) ChangeKeyRotationConfig(config kms.KeyRotationConfig) error {
+	prev := k.KeyRotation
+	k.KeyRotation = config
+	if prev.Enabled == config.Enabled &&
+		prev.Interval.Duration() == config.Interval.Duration() &&
+		prev.Overlap.Duration() == config.Overlap.Duration() {
+		return nil
+	}
+	if prev.Enabled {
+		k.StopAutomaticRotation()
+	}
+	if config.Enabled {
+		return k.StartAutomaticRotation()
+	}
+	return nil
+}
+
+// ChangeAlgsAt schedules a change of the algorithm set at a specific time.
+// It creates future keys for the new algorithms and shortens the expiration
+// of keys for removed algorithms to effectiveAt + overlap.
+func (k *KMS) ChangeAlgsAt(
+	algs []jwa.SignatureAlgorithm, effectiveAt unixtime.Unixtime, overlap time.Duration,
+) error {
+	if len(algs) == 0 {
+		return errors.New("algs must not be empty")
+	}
+	st, err := k.stateStorer.LoadScheduledState()
+	if err != nil {
+		return err
+	}
+	algString := func(a jwa.SignatureAlgorithm) string { return a.String() }
+	samePending := st.PendingAlgChange != nil &&
+		sliceutils.EqualSetsFunc(algs, st.PendingAlgChange.Algs, algString) &&
+		st.PendingAlgChange.EffectiveAt.Equal(effectiveAt.Time) &&
+		st.PendingAlgChange.Overlap.Duration == overlap
+	if samePending {
+		return nil
+	}
+	if sliceutils.EqualSetsFunc(algs, k.Algs, algString) && st.PendingAlgChange == nil {
+		return nil
+	}
+	if !k.GenerateKeys {
+		return errors.New("scheduling alg changes requires key generation to be enabled")
+	}
+	for _, alg := range algs {
+		if err = k.ensureFutureKey(alg, effectiveAt.Time); err != nil {
+			return err
+		}
+	}
+	active, err := k.PKs.GetActive()
+	if err != nil {
+		return err
+	}
+	targetExp := &unixtime.Unixtime{Time: effectiveAt.Add(overlap)}
+	for alg, list := range active.ByAlg() {
+		if slices.ContainsFunc(algs, func(a jwa.SignatureAlgorithm) bool { return a.String() == alg.String() }) {
+			continue
+		}
+		for _, pk := range list {
+			if pk.ExpiresAt == nil || pk.ExpiresAt.IsZero() || targetExp.Before(pk.ExpiresAt.Time) {
+				pk.ExpiresAt = targetExp
+				if err = k.PKs.Update(pk.KID, pk.UpdateablePublicKeyMetadata); err != nil {
+					log.WithError(err).Error("TransitKMS: schedule algs: failed to update old key exp")
+				}
+			}
+		}
+	}
+	st.PendingAlgChange = &kms.PendingAlgChange{
+		Algs:        algs,
+		EffectiveAt: effectiveAt,
+		Overlap:     unixtime.DurationInSeconds{Duration: overlap},
+	}
+	return k.stateStorer.SaveScheduledState(st)
+}
+
+// GetDefault returns a crypto.Signer and the corresponding jwa.SignatureAlgorithm
+func (k *KMS) GetDefault() (crypto.Signer, jwa.SignatureAlgorithm) {
+	if len(k.Algs) == 0 {
+		return nil, jwa.SignatureAlgorithm{}
+	}
+	var algs []string
+	if k.DefaultAlg.String() != "" {
+		algs = []string{k.DefaultAlg.String()}
+	}
+	for _, a := range k.Algs {
+		algs = append(algs, a.String())
+	}
+	return k.GetForAlgs(algs...)
+}
+
+// GetForAlgs takes a list of acceptable signature algorithms and returns a
+// usable crypto.Signer or nil as well as the corresponding
+// jwa.SignatureAlgorithm
+func (k *KMS) GetForAlgs(algs ...string) (crypto.Signer, jwa.SignatureAlgorithm) {
+	activePKs, err := k.PKs.GetActive()
+	if err != nil {
+		log.WithError(err).Error("TransitKMS: failed to get active public keys")
+		return nil, jwa.SignatureAlgorithm{}
+	}
+	pksByAlg := activePKs.ByAlg()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, alg := range k.Algs {
+		if !slices.Contains(algs, alg.String()) {
+			continue
+		}
+		pk, ok := currentSigningKey(pksByAlg[alg], k.KeyRotation.Overlap.Duration())
+		if !ok {
+			continue
+		}
+		s, ok := k.signers[pk.KID]
+		if !ok {
+			continue
+		}
+		return s, alg
+	}
+	return nil, jwa.SignatureAlgorithm{}
+}
+
+// currentSigningKey selects the key to sign with from the active keys of an
+// algorithm: the key with the latest expiration that has been valid for at
+// least half the overlap (so that verifiers had a chance to learn it), then
+// the key with the latest expiration, then a key without expiration.
+func currentSigningKey(algPKs []public.PublicKeyEntry, overlap time.Duration) (public.PublicKeyEntry, bool) {
+	if len(algPKs) == 0 {
+		return public.PublicKeyEntry{}, false
+	}
+	if len(algPKs) == 1 {
+		return algPKs[0], true
+	}
+	maxExp := unixtime.Now()
+	maxExpWithNbf := maxExp
+	noExpIndex, maxExpIndex, maxExpWithNbfIndex := -1, -1, -1
+	nbfThreshold := time.Now().Add(-overlap / 2)
+	for i, it := range algPKs {
+		if it.ExpiresAt == nil {
+			noExpIndex = i
+			continue
+		}
+		if it.NotBefore != nil && it.NotBefore.Before(nbfThreshold) && it.ExpiresAt.After(maxExpWithNbf.Time) {
+			maxExpWithNbf = *it.ExpiresAt
+			maxExpWithNbfIndex = i
+		} else if maxExpIndex == -1 && it.ExpiresAt.After(maxExp.Time) {
+			maxExp = *it.ExpiresAt
+			maxExpIndex = i
+		}
+	}
+	switch {
+	case maxExpWithNbfIndex != -1:
+		return algPKs[maxExpWithNbfIndex], true
+	case maxExpIndex != -1:
+		return algPKs[maxExpIndex], true
+	case noExpIndex != -1:
+		return algPKs[noExpIndex], true
+	default:
+		return algPKs[0], true
+	}
+}
+
+// Load loads the versions of the transit keys of all configured algorithms.
+// If there is no active key for an algorithm, the latest version of the
+// transit key is used if it is not yet known; otherwise a new key version is
+// generated if key generation is enabled.
+func (k *KMS) Load() error {
+	log.Debug("TransitKMS: loading keys")
+	for _, alg := range k.Algs {
+		if _, err := k.loadKey(alg); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+	}
+	activePKs, err := k.PKs.GetActive()
+	if err != nil {
+		return err
+	}
+	loadedAlgs := make(map[string]struct{})
+	k.mu.RLock()
+	for _, pk := range activePKs {
+		kalg, _ := pk.Key.Algorithm()
+		if _, ok := k.signers[pk.KID]; ok {
+			loadedAlgs[kalg.String()] = struct{}{}
+		} else {
+			log.WithField("kid", pk.KID).Warn("TransitKMS: no transit key version found for active key")
+		}
+	}
+	k.mu.RUnlock()
+
+	for _, alg := range k.Algs {
+		if _, ok := loadedAlgs[alg.String()]; ok {
+			continue
+		}
+		adopted, err := k.adoptLatestVersion(alg)
+		if err != nil {
+			return err
+		}
+		if adopted {
+			continue
+		}
+		if !k.GenerateKeys {
+			return errors.Errorf(
+				"no signing key for alg '%s' in transit key '%s'; create the key or enable key generation",
+				alg, k.KeyName(alg),
+			)
+		}
+		log.WithField("alg", alg.String()).Info("TransitKMS: generating new signing key")
+		if _, err = k.generateNewSigner(alg, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadKey loads all versions of the transit key for the algorithm and
+// returns the kid of the latest version.
+func (k *KMS) loadKey(alg jwa.SignatureAlgorithm) (string, error) {
+	name := k.KeyName(alg)
+	info, err := k.client.ReadKey(name)
+	if err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var latestKID string
+	for version, pub := range info.PublicKeys {
+		s := &signer{
+			client:  k.client,
+			name:    name,
+			version: version,
+			public:  pub,
+		}
+		_, kid, err := jwx.SignerToPublicJWK(s, alg)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid public key of %s", keyRef(name, version))
+		}
+		k.signers[kid] = s
+		if version == info.LatestVersion {
+			latestKID = kid
+		}
+	}
+	if latestKID == "" {
+		return "", errors.Errorf("transit key '%s' has no public key for its latest version", name)
+	}
+	return latestKID, nil
+}
+
+// adoptLatestVersion adds the latest version of an existing transit key to
+// the public key storage, if it is not yet known there, e.g. because the
+// transit key was created outside of LightHouse.
+func (k *KMS) adoptLatestVersion(alg jwa.SignatureAlgorithm) (bool, error) {
+	kid, err := k.loadKey(alg)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if existing, err := k.PKs.Get(kid); err != nil || existing != nil {
+		return false, err
+	}
+	if _, err = k.addPublicKey(alg, kid, time.Now()); err != nil {
+		return false, err
+	}
+	log.WithFields(
+		log.Fields{
+			"alg": alg.String(),
+			"kid": kid,
+		},
+	).Info("TransitKMS: using existing transit key")
+	return true, nil
+}
+
+// generateNewSigner creates or rotates the transit key for the algorithm and
+// adds the new key version with the passed nbf to the public key storage.
+func (k *KMS) generateNewSigner(alg jwa.SignatureAlgorithm, nbf time.Time) (*public.PublicKeyEntry, error) {
+	name := k.KeyName(alg)
+	_, err := k.client.ReadKey(name)
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		var kt string
+		if kt, err = keyType(alg, k.RSAKeyLen); err != nil {
+			return nil, err
+		}
+		err = k.client.CreateKey(name, kt)
+	case err == nil:
+		err = k.client.RotateKey(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kid, err := k.loadKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	return k.addPublicKey(alg, kid, nbf)
+}
+
+func (k *KMS) addPublicKey(alg jwa.SignatureAlgorithm, kid string, nbf time.Time) (*public.PublicKeyEntry, error) {
+	k.mu.RLock()
+	s := k.signers[kid]
+	k.mu.RUnlock()
+	pk, _, err := jwx.SignerToPublicJWK(s, alg)
+	if err != nil {
+		return nil, err
+	}
+	now := unixtime.Now()
+	var exp *unixtime.Unixtime
+	if k.KeyRotation.Enabled {
+		exp = &unixtime.Unixtime{Time: nbf.Add(k.KeyRotation.Interval.Duration())}
+	}
+	pke := public.PublicKeyEntry{
+		KID:       kid,
+		Key:       public.JWKKey{Key: pk},
+		IssuedAt:  &now,
+		NotBefore: &unixtime.Unixtime{Time: nbf},
+		UpdateablePublicKeyMetadata: public.UpdateablePublicKeyMetadata{
+			ExpiresAt: exp,
+		},
+	}
+	if err = k.PKs.Add(pke); err != nil {
+		return nil, err
+	}
+	return &pke, nil
+}
+
+// nextNbf returns the nbf for a key that replaces a current key: after the
+// lifetime of the entity configuration, so that it is published before it is
+// used.
+func (k *KMS) nextNbf() (time.Time, error) {
+	if k.KeyRotation.EntityConfigurationLifetimeFunc == nil {
+		return time.Now(), nil
+	}
+	lifetime, err := k.KeyRotation.EntityConfigurationLifetimeFunc()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get entity configuration lifetime")
+	}
+	return time.Now().Add(lifetime), nil
+}
+
+func (k *KMS) ensureFutureKey(alg jwa.SignatureAlgorithm, effectiveAt time.Time) error {
+	valid, err := k.PKs.GetValid()
+	if err != nil {
+		return err
+	}
+	for _, pk := range valid {
+		a, _ := pk.Key.Algorithm()
+		if a.String() != alg.String() {
+			continue
+		}
+		if pk.RevokedAt != nil && !pk.RevokedAt.IsZero() && pk.RevokedAt.Before(time.Now()) {
+			continue
+		}
+		if pk.NotBefore != nil && !pk.NotBefore.IsZero() && !pk.NotBefore.Before(effectiveAt) {
+			return nil
+		}
+	}
+	_, err = k.generateNewSigner(alg, effectiveAt)
+	return err
+}
+
+func (k *KMS) rotateKeys(kids []string, revoked bool, reason string) error {
+	log.WithFields(
+		log.Fields{
+			"kids":    kids,
+			"revoked": revoked,
+		},
+	).Info("TransitKMS: rotation: start")
+	ks := make([]*public.PublicKeyEntry, len(kids))
+	var signingAlg jwa.SignatureAlgorithm
+	latestExp := time.Time{}
+	for i, kid := range kids {
+		pk, err := k.PKs.Get(kid)
+		if err != nil {
+			return err
+		}
+		if pk == nil {
+			return errors.Errorf("unknown key '%s'", kid)
+		}
+		alg, _ := pk.Key.Algorithm()
+		if signingAlg.String() == "" {
+			signingAlg, _ = alg.(jwa.SignatureAlgorithm)
+		} else if signingAlg.String() != alg.String() {
+			return errors.New("all keys must be of the same algorithm")
+		}
+		ks[i] = pk
+		if pk.ExpiresAt != nil && !pk.ExpiresAt.IsZero() && (latestExp.IsZero() || pk.ExpiresAt.After(latestExp)) {
+			latestExp = pk.ExpiresAt.Time
+		}
+	}
+	nbf := time.Now()
+	if !revoked {
+		next, err := k.nextNbf()
+		if err != nil {
+			return err
+		}
+		// If the current keys expire before the next key could be used, the
+		// next key is used immediately.
+		if !latestExp.IsZero() && !next.After(latestExp) {
+			nbf = next
+		}
+	}
+	pk, err := k.generateNewSigner(signingAlg, nbf)
+	if err != nil {
+		return err
+	}
+	log.WithFields(
+		log.Fields{
+			"alg":     signingAlg.String(),
+			"new_kid": pk.KID,
+		},
+	).Info("TransitKMS: rotation: generated new key")
+	newExpForOldKey := &unixtime.Unixtime{Time: pk.NotBefore.Add(k.KeyRotation.Overlap.Duration())}
+	for _, old := range ks {
+		if revoked {
+			now := unixtime.Now()
+			old.RevokedAt = &now
+			old.Reason = reason
+		}
+		if old.ExpiresAt == nil || old.ExpiresAt.IsZero() || newExpForOldKey.Before(old.ExpiresAt.Time) {
+			old.ExpiresAt = newExpForOldKey
+		}
+		if err = k.PKs.Update(old.KID, old.UpdateablePublicKeyMetadata); err != nil {
+			log.WithError(err).Error("TransitKMS: rotation: failed to update key")
+		}
+	}
+	return nil
+}
+
+// RotateKey rotates a single key, optionally marking it revoked and recording
+// a reason. The transit key is rotated to a new version; old versions are
+// kept in the transit engine.
+func (k *KMS) RotateKey(kid string, revoked bool, reason string) error {
+	return k.rotateKeys([]string{kid}, revoked, reason)
+}
+
+// RotateAllKeys rotates all active keys per configured algorithm, optionally
+// marking them revoked and recording a reason.
+func (k *KMS) RotateAllKeys(revoked bool, reason string) error {
+	activePKs, err := k.PKs.GetActive()
+	if err != nil {
+		return err
+	}
+	pksByAlg := activePKs.ByAlg()
+	for _, alg := range k.Algs {
+		algPKs := pksByAlg[alg]
+		if len(algPKs) == 0 {
+			if _, err = k.generateNewSigner(alg, time.Now()); err != nil {
+				return err
+			}
+			continue
+		}
+		kids := make([]string, len(algPKs))
+		for i, pk := range algPKs {
+			kids[i] = pk.KID
+		}
+		if err = k.rotateKeys(kids, revoked, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartAutomaticRotation starts a background loop that rotates keys ahead of
+// their expiration and applies scheduled algorithm changes.
+func (k *KMS) StartAutomaticRotation() error {
+	if !k.KeyRotation.Enabled || k.rotationStop != nil {
+		return nil
+	}
+	log.Info("TransitKMS: automatic rotation: starting")
+	k.rotationStop = make(chan struct{})
+	k.rotationWG.Add(1)
+	go func() {
+		defer k.rotationWG.Done()
+		for {
+			nextSleep, didRotate := k.rotationStep(time.Now())
+			if didRotate {
+				continue
+			}
+			if nextSleep <= 0 {
+				nextSleep = time.Second
+			}
+			timer := time.NewTimer(nextSleep)
+			select {
+			case <-k.rotationStop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// StopAutomaticRotation stops the background rotation loop and waits for it
+// to exit.
+func (k *KMS) StopAutomaticRotation() {
+	if k.rotationStop == nil {
+		return
+	}
+	close(k.rotationStop)
+	k.rotationWG.Wait()
+	log.Info("TransitKMS: automatic rotation: stopped")
+	k.rotationStop = nil
+}
+
+// rotationStep performs one evaluation/rotation cycle and returns the next
+// sleep interval and whether a key was rotated or a change was applied.
+func (k *KMS) rotationStep(now time.Time) (time.Duration, bool) {
+	const minSleep = time.Second
+	nextSleep := max(k.KeyRotation.Overlap.Duration()/2, minSleep)
+	didRotate := false
+	wake := func(at time.Time) {
+		if wait := at.Sub(now); wait > 0 && wait < nextSleep {
+			nextSleep = max(wait, minSleep)
+		}
+	}
+
+	activePKs, err := k.PKs.GetActive()
+	if err != nil {
+		log.WithError(err).Error("TransitKMS: automatic rotation: failed to get active public keys")
+		return nextSleep, false
+	}
+	pksByAlg := activePKs.ByAlg()
+	for _, alg := range k.Algs {
+		at, rotated := k.rotationEvaluationForAlg(pksByAlg[alg], alg, now)
+		didRotate = didRotate || rotated
+		wake(at)
+	}
+
+	st, err := k.stateStorer.LoadScheduledState()
+	if err != nil {
+		log.WithError(err).Error("TransitKMS: scheduled state load failed")
+		return nextSleep, didRotate
+	}
+	changed := false
+	if p := st.PendingAlgChange; p != nil {
+		if now.Before(p.EffectiveAt.Time) {
+			wake(p.EffectiveAt.Time)
+		} else {
+			k.Algs = p.Algs
+			if err = k.Load(); err != nil {
+				log.WithError(err).Error("TransitKMS: scheduled alg change: load failed")
+			}
+			st.PendingAlgChange = nil
+			changed = true
+		}
+	}
+	if p := st.PendingDefaultChange; p != nil {
+		if now.Before(p.EffectiveAt.Time) {
+			wake(p.EffectiveAt.Time)
+		} else {
+			k.DefaultAlg = p.Alg
+			st.PendingDefaultChange = nil
+			changed = true
+		}
+	}
+	if changed {
+		if err = k.stateStorer.SaveScheduledState(st); err != nil {
+			log.WithError(err).Error("TransitKMS: scheduled change: save state failed")
+		}
+		didRotate = true
+	}
+	return nextSleep, didRotate
+}
+
+// rotationEvaluationForAlg rotates the keys of an algorithm if needed. It
+// returns the time of the next action point and whether a key was rotated.
+func (k *KMS) rotationEvaluationForAlg(
+	algPKs []public.PublicKeyEntry, alg jwa.SignatureAlgorithm, now time.Time,
+) (time.Time, bool) {
+	futureNbf, hasFuture, err := k.earliestFutureNbf(alg, now)
+	if err != nil {
+		log.WithError(err).Error("TransitKMS: automatic rotation: failed to get valid public keys")
+		return time.Time{}, false
+	}
+	if len(algPKs) == 0 {
+		if hasFuture {
+			return futureNbf, false
+		}
+		if _, err = k.generateNewSigner(alg, now); err != nil {
+			log.WithError(err).Error("TransitKMS: automatic rotation: failed to seed key for alg")
+			return time.Time{}, false
+		}
+		return time.Time{}, true
+	}
+
+	current := slices.MaxFunc(
+		algPKs, func(a, b public.PublicKeyEntry) int {
+			return cmp.Compare(expUnixNano(a), expUnixNano(b))
+		},
+	)
+	var lifetime time.Duration
+	if k.KeyRotation.EntityConfigurationLifetimeFunc != nil {
+		if lifetime, err = k.KeyRotation.EntityConfigurationLifetimeFunc(); err != nil {
+			log.WithError(err).Warn("TransitKMS: automatic rotation: failed to get lifetime; using 0")
+			lifetime = 0
+		}
+	}
+	if current.ExpiresAt == nil || current.ExpiresAt.IsZero() {
+		current.ExpiresAt = &unixtime.Unixtime{Time: now.Add(lifetime)}
+		if err = k.PKs.Update(current.KID, current.UpdateablePublicKeyMetadata); err != nil {
+			log.WithError(err).Error("TransitKMS: automatic rotation: failed to update key expiration")
+		}
+	}
+	threshold := current.ExpiresAt.Add(-k.KeyRotation.Overlap.Duration()).Add(-lifetime)
+	if threshold.After(now) {
+		return threshold, false
+	}
+	if hasFuture {
+		// A key is already scheduled; keep the current keys valid until it is used
+		newExp := &unixtime.Unixtime{Time: futureNbf.Add(k.KeyRotation.Overlap.Duration())}
+		for _, pk := range algPKs {
+			if pk.ExpiresAt == nil || pk.ExpiresAt.IsZero() || newExp.Before(pk.ExpiresAt.Time) {
+				pk.ExpiresAt = newExp
+				if err = k.PKs.Update(pk.KID, pk.UpdateablePublicKeyMetadata); err != nil {
+					log.WithError(err).Error("TransitKMS: automatic rotation: failed to update old key exp")
+				}
+			}
+		}
+		return futureNbf, false
+	}
+	kids := make([]string, len(algPKs))
+	for i, pk := range algPKs {
+		kids[i] = pk.KID
+	}
+	if err = k.rotateKeys(kids, false, ""); err != nil {
+		log.WithError(err).Error("TransitKMS: automatic rotation: rotate failed")
+		return time.Time{}, false
+	}
+	return time.Time{}, true
+}
+
+func expUnixNano(pk public.PublicKeyEntry) int64 {
+	if pk.ExpiresAt == nil {
+		return 0
+	}
+	return pk.ExpiresAt.UnixNano()
+}
+
+// earliestFutureNbf returns the earliest nbf of a valid, not yet active key
+// for the algorithm.
+func (k *KMS) earliestFutureNbf(alg jwa.SignatureAlgorithm, now time.Time) (time.Time, bool, error) {
+	valid, err := k.PKs.GetValid()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var earliest time.Time
+	for _, pk := range valid {
+		a, set := pk.Key.Algorithm()
+		if !set || a.String() != alg.String() {
+			continue
+		}
+		if pk.RevokedAt != nil && !pk.RevokedAt.IsZero() && pk.RevokedAt.Before(now) {
+			continue
+		}
+		if pk.NotBefore != nil && pk.NotBefore.After(now) && (earliest.IsZero() || pk.NotBefore.Before(earliest)) {
+			earliest = pk.NotBefore.Time
+		}
+	}
+	return earliest, !earliest.IsZero(), nil
+}
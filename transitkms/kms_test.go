@@ -0,0 +1,310 @@
+package transitkms
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-oidfed/lib/jwx/keymanagement/kms"
+	"github.com/go-oidfed/lib/jwx/keymanagement/public"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"github.com/pkg/errors"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	srv := newTransitServer(t, "secret")
+	c := srv.client(t)
+
+	keys, err := c.ListKeys()
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("Expected no keys, got %v (%v)", keys, err)
+	}
+	if _, err = c.ReadKey("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if err = c.CreateKey("k", "ecdsa-p256"); err != nil {
+		t.Fatalf("CreateKey failed: %v", err)
+	}
+	if err = c.RotateKey("k"); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	info, err := c.ReadKey("k")
+	if err != nil {
+		t.Fatalf("ReadKey failed: %v", err)
+	}
+	if info.LatestVersion != 2 || len(info.PublicKeys) != 2 {
+		t.Errorf("Expected 2 versions, got %+v", info)
+	}
+	if keys, err = c.ListKeys(); err != nil || len(keys) != 1 {
+		t.Errorf("Expected 1 key, got %v (%v)", keys, err)
+	}
+
+	wrongToken, err := NewClient(ClientConfig{Address: srv.URL, Token: "wrong"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err = wrongToken.ReadKey("k"); err == nil || errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+	if _, err = NewClient(ClientConfig{Address: srv.URL}); err == nil {
+		t.Error("Expected error without authentication")
+	}
+}
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+	srv := newTransitServer(t, "secret")
+	c := srv.client(t)
+	for _, alg := range []jwa.SignatureAlgorithm{
+		jwa.ES256(), jwa.ES384(), jwa.EdDSA(), jwa.RS256(), jwa.PS256(),
+	} {
+		t.Run(
+			alg.String(), func(t *testing.T) {
+				kt, err := keyType(alg, 2048)
+				if err != nil {
+					t.Fatalf("keyType failed: %v", err)
+				}
+				name := "key-" + alg.String()
+				if err = c.CreateKey(name, kt); err != nil {
+					t.Fatalf("CreateKey failed: %v", err)
+				}
+				info, err := c.ReadKey(name)
+				if err != nil {
+					t.Fatalf("ReadKey failed: %v", err)
+				}
+				s := &signer{
+					client:  c,
+					name:    name,
+					version: 1,
+					public:  info.PublicKeys[1],
+				}
+				signed, err := jws.Sign([]byte("payload"), jws.WithKey(alg, s))
+				if err != nil {
+					t.Fatalf("Sign failed: %v", err)
+				}
+				if _, err = jws.Verify(signed, jws.WithKey(alg, s.Public())); err != nil {
+					t.Errorf("Verify failed: %v", err)
+				}
+			},
+		)
+	}
+}
+
+func newTestKMS(t *testing.T, c *Client, dir string, generate bool) *KMS {
+	t.Helper()
+	pks := &public.FilesystemPublicKeyStorage{
+		Dir:    dir,
+		TypeID: "federation",
+	}
+	if err := pks.Load(); err != nil {
+		t.Fatalf("Failed to load public key storage: %v", err)
+	}
+	return NewKMS(
+		kms.KMSConfig{
+			GenerateKeys: generate,
+			Algs:         []jwa.SignatureAlgorithm{jwa.ES256(), jwa.EdDSA()},
+			DefaultAlg:   jwa.ES256(),
+		},
+		c, "lighthouse-federation-", &kms.FilesystemStateStorer{Dir: dir}, pks,
+	)
+}
+
+func TestKMS(t *testing.T) {
+	t.Parallel()
+	srv := newTransitServer(t, "secret")
+	c := srv.client(t)
+	dir := t.TempDir()
+
+	k := newTestKMS(t, c, dir, true)
+	if err := k.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(srv.keys) != 2 {
+		t.Fatalf("Expected 2 transit keys, got %d", len(srv.keys))
+	}
+	sk, alg := k.GetDefault()
+	if sk == nil || alg.String() != jwa.ES256().String() {
+		t.Fatalf("Expected ES256 signer, got %v", alg)
+	}
+	if _, err := jws.Sign([]byte("payload"), jws.WithKey(alg, sk)); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if srv.signed["lighthouse-federation-es256"] != 1 {
+		t.Errorf("Expected a sign request for the es256 key, got %v", srv.signed)
+	}
+	if sk, alg = k.GetForAlgs(jwa.EdDSA().String()); sk == nil || alg.String() != jwa.EdDSA().String() {
+		t.Errorf("Expected EdDSA signer, got %v", alg)
+	}
+
+	t.Run(
+		"Restart", func(t *testing.T) {
+			restarted := newTestKMS(t, c, dir, false)
+			if err := restarted.Load(); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if v := len(srv.keys["lighthouse-federation-es256"].versions); v != 1 {
+				t.Errorf("Expected no new key version, got %d versions", v)
+			}
+			if s, _ := restarted.GetDefault(); s == nil {
+				t.Error("Expected signer after restart")
+			}
+		},
+	)
+
+	t.Run(
+		"RotateRevoked", func(t *testing.T) {
+			active, err := k.PKs.GetActive()
+			if err != nil {
+				t.Fatalf("GetActive failed: %v", err)
+			}
+			var oldKID string
+			for _, pk := range active {
+				if a, _ := pk.Key.Algorithm(); a.String() == jwa.ES256().String() {
+					oldKID = pk.KID
+				}
+			}
+			if err = k.RotateKey(oldKID, true, "compromised"); err != nil {
+				t.Fatalf("RotateKey failed: %v", err)
+			}
+			if v := len(srv.keys["lighthouse-federation-es256"].versions); v != 2 {
+				t.Errorf("Expected a new key version, got %d versions", v)
+			}
+			old, err := k.PKs.Get(oldKID)
+			if err != nil || old == nil || old.RevokedAt == nil || old.Reason != "compromised" {
+				t.Errorf("Expected old key to be revoked, got %+v (%v)", old, err)
+			}
+			s, _ := k.GetDefault()
+			if s == nil || s.(*signer).version != 2 {
+				t.Errorf("Expected the new key version to be used, got %v", s)
+			}
+		},
+	)
+
+	t.Run(
+		"AdoptExistingKey", func(t *testing.T) {
+			other := newTransitServer(t, "secret")
+			oc := other.client(t)
+			if err := oc.CreateKey("lighthouse-federation-es256", "ecdsa-p256"); err != nil {
+				t.Fatalf("CreateKey failed: %v", err)
+			}
+			if err := oc.CreateKey("lighthouse-federation-eddsa", "ed25519"); err != nil {
+				t.Fatalf("CreateKey failed: %v", err)
+			}
+			adopting := newTestKMS(t, oc, t.TempDir(), false)
+			if err := adopting.Load(); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if v := len(other.keys["lighthouse-federation-es256"].versions); v != 1 {
+				t.Errorf("Expected the existing key version to be used, got %d versions", v)
+			}
+			if s, _ := adopting.GetDefault(); s == nil {
+				t.Error("Expected signer for existing key")
+			}
+		},
+	)
+
+	t.Run(
+		"NoKeysWithoutGeneration", func(t *testing.T) {
+			empty := newTransitServer(t, "secret")
+			if err := newTestKMS(t, empty.client(t), t.TempDir(), false).Load(); err == nil {
+				t.Error("Expected error without keys and key generation")
+			}
+		},
+	)
+}
+
+// writeTestCertificate writes a self-signed client certificate and its key
+// to dir.
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "lighthouse"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, sk.Public(), sk)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	skDER, err := x509.MarshalECPrivateKey(sk)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: skDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestClientMTLS(t *testing.T) {
+	t.Parallel()
+	stub := &transitServer{
+		keys:   make(map[string]*transitKey),
+		signed: make(map[string]int),
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(stub.handle))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(
+		caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600,
+	); err != nil {
+		t.Fatalf("Failed to write ca certificate: %v", err)
+	}
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	c, err := NewClient(
+		ClientConfig{
+			Address:        srv.URL,
+			CACertFile:     caFile,
+			ClientCertFile: certFile,
+			ClientKeyFile:  keyFile,
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err = c.CreateKey("k", "ecdsa-p256"); err != nil {
+		t.Errorf("CreateKey with client certificate failed: %v", err)
+	}
+
+	withoutCert, err := NewClient(
+		ClientConfig{
+			Address:    srv.URL,
+			Token:      "unused",
+			CACertFile: caFile,
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if _, err = withoutCert.ReadKey("k"); err == nil {
+		t.Error("Expected TLS error without client certificate")
+	}
+}
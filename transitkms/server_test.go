@@ -0,0 +1,229 @@
+package transitkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// transitServer is a minimal in-memory stand-in for the transit engine.
+type transitServer struct {
+	*httptest.Server
+	token string
+
+	mu   sync.Mutex
+	keys map[string]*transitKey
+	// signed counts the sign requests per key
+	signed map[string]int
+}
+
+type transitKey struct {
+	keyType  string
+	versions []crypto.Signer
+}
+
+func newTransitServer(t *testing.T, token string) *transitServer {
+	t.Helper()
+	s := &transitServer{
+		token:  token,
+		keys:   make(map[string]*transitKey),
+		signed: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *transitServer) client(t *testing.T) *Client {
+	t.Helper()
+	c, err := NewClient(
+		ClientConfig{
+			Address: s.URL,
+			Token:   s.token,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func writeTransitError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{msg}})
+}
+
+func writeTransitData(w http.ResponseWriter, data any) {
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func (s *transitServer) handle(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && r.Header.Get("X-Vault-Token") != s.token {
+		writeTransitError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v1/transit")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case r.Method == "LIST" && path == "/keys":
+		if len(s.keys) == 0 {
+			writeTransitError(w, http.StatusNotFound, "")
+			return
+		}
+		var names []string
+		for name := range s.keys {
+			names = append(names, name)
+		}
+		writeTransitData(w, map[string]any{"keys": names})
+	case len(parts) == 2 && parts[0] == "keys" && r.Method == http.MethodGet:
+		s.readKey(w, parts[1])
+	case len(parts) == 2 && parts[0] == "keys" && r.Method == http.MethodPost:
+		var req struct {
+			Type string `json:"type"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if _, ok := s.keys[parts[1]]; !ok {
+			s.keys[parts[1]] = &transitKey{keyType: req.Type}
+			if err := s.keys[parts[1]].rotate(); err != nil {
+				writeTransitError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[0] == "keys" && parts[2] == "rotate" && r.Method == http.MethodPost:
+		key, ok := s.keys[parts[1]]
+		if !ok {
+			writeTransitError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		if err := key.rotate(); err != nil {
+			writeTransitError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[0] == "sign" && r.Method == http.MethodPost:
+		s.sign(w, r, parts[1])
+	default:
+		writeTransitError(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func (k *transitKey) rotate() error {
+	var sk crypto.Signer
+	var err error
+	switch k.keyType {
+	case "ecdsa-p256":
+		sk, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ecdsa-p384":
+		sk, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ed25519":
+		_, sk, err = ed25519.GenerateKey(rand.Reader)
+	case "rsa-2048":
+		sk, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return fmt.Errorf("unsupported key type %s", k.keyType)
+	}
+	if err != nil {
+		return err
+	}
+	k.versions = append(k.versions, sk)
+	return nil
+}
+
+func (s *transitServer) readKey(w http.ResponseWriter, name string) {
+	key, ok := s.keys[name]
+	if !ok {
+		writeTransitError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	keys := make(map[string]any)
+	for i, sk := range key.versions {
+		var pub string
+		if edPub, ok := sk.Public().(ed25519.PublicKey); ok {
+			pub = base64.StdEncoding.EncodeToString(edPub)
+		} else {
+			der, _ := x509.MarshalPKIXPublicKey(sk.Public())
+			pub = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		}
+		keys[strconv.Itoa(i+1)] = map[string]any{"public_key": pub}
+	}
+	writeTransitData(
+		w, map[string]any{
+			"name":           name,
+			"type":           key.keyType,
+			"latest_version": len(key.versions),
+			"keys":           keys,
+		},
+	)
+}
+
+func (s *transitServer) sign(w http.ResponseWriter, r *http.Request, name string) {
+	key, ok := s.keys[name]
+	if !ok {
+		writeTransitError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	var req SignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeTransitError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	version := req.KeyVersion
+	if version == 0 {
+		version = len(key.versions)
+	}
+	if version < 1 || version > len(key.versions) {
+		writeTransitError(w, http.StatusBadRequest, "invalid key version")
+		return
+	}
+	input, err := base64.StdEncoding.DecodeString(req.Input)
+	if err != nil {
+		writeTransitError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hash := map[string]crypto.Hash{
+		"sha2-256": crypto.SHA256,
+		"sha2-384": crypto.SHA384,
+		"sha2-512": crypto.SHA512,
+	}[req.HashAlgorithm]
+	var sig []byte
+	switch sk := key.versions[version-1].(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(sk, input)
+	case *ecdsa.PrivateKey:
+		sig, err = ecdsa.SignASN1(rand.Reader, sk, input)
+	case *rsa.PrivateKey:
+		if req.SignatureAlgorithm == "pss" {
+			sig, err = rsa.SignPSS(rand.Reader, sk, hash, input, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, sk, hash, input)
+		}
+	}
+	if err != nil {
+		writeTransitError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.signed[name]++
+	writeTransitData(
+		w, map[string]any{
+			"signature":   fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(sig)),
+			"key_version": version,
+		},
+	)
+}
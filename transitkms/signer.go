@@ -0,0 +1,98 @@
+package transitkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"io"
+	"strconv"
+
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/pkg/errors"
+)
+
+// signer is a crypto.Signer for a single version of a transit key.
+type signer struct {
+	client  *Client
+	name    string
+	version int
+	public  crypto.PublicKey
+}
+
+// Public implements the crypto.Signer interface.
+func (s *signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign implements the crypto.Signer interface. ECDSA signatures are returned
+// ASN.1 encoded, as for the standard library signers.
+func (s *signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := SignRequest{
+		Input:      base64.StdEncoding.EncodeToString(digest),
+		KeyVersion: s.version,
+	}
+	if _, ok := s.public.(ed25519.PublicKey); !ok {
+		hash, err := hashAlgorithm(opts.HashFunc())
+		if err != nil {
+			return nil, err
+		}
+		req.HashAlgorithm = hash
+		req.Prehashed = true
+	}
+	switch s.public.(type) {
+	case *rsa.PublicKey:
+		req.SignatureAlgorithm = "pkcs1v15"
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			req.SignatureAlgorithm = "pss"
+			// JWS requires the salt length to equal the hash length
+			if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != opts.HashFunc().Size() {
+				return nil, errors.New("transit: only PSS salt length equal to the hash length is supported")
+			}
+			req.SaltLength = "hash"
+		}
+	case *ecdsa.PublicKey:
+		req.MarshalingAlgorithm = "asn1"
+	}
+	sig, err := s.client.Sign(s.name, req)
+	return sig, errors.Wrapf(err, "transit: failed to sign with %s", keyRef(s.name, s.version))
+}
+
+func hashAlgorithm(h crypto.Hash) (string, error) {
+	switch h {
+	case crypto.SHA256:
+		return "sha2-256", nil
+	case crypto.SHA384:
+		return "sha2-384", nil
+	case crypto.SHA512:
+		return "sha2-512", nil
+	default:
+		return "", errors.Errorf("transit: unsupported hash function %v", h)
+	}
+}
+
+// keyType returns the transit key type for the signing algorithm.
+func keyType(alg jwa.SignatureAlgorithm, rsaKeyLen int) (string, error) {
+	switch alg {
+	case jwa.ES256():
+		return "ecdsa-p256", nil
+	case jwa.ES384():
+		return "ecdsa-p384", nil
+	case jwa.ES512():
+		return "ecdsa-p521", nil
+	case jwa.EdDSA():
+		return "ed25519", nil
+	case jwa.RS256(), jwa.RS384(), jwa.RS512(), jwa.PS256(), jwa.PS384(), jwa.PS512():
+		switch rsaKeyLen {
+		case 0:
+			return "rsa-2048", nil
+		case 2048, 3072, 4096:
+			return "rsa-" + strconv.Itoa(rsaKeyLen), nil
+		default:
+			return "", errors.Errorf("transit: unsupported rsa key length %d", rsaKeyLen)
+		}
+	default:
+		return "", errors.Errorf("transit: unsupported signing algorithm '%s'", alg)
+	}
+}
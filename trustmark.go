@@ -1,16 +1,19 @@
 package lighthouse
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-oidfed/lib/oidfedconst"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	oidfed "github.com/go-oidfed/lib"
 
 	"github.com/go-oidfed/lighthouse/storage/model"
+	"github.com/go-oidfed/lighthouse/tracing"
 )
 
 // TrustMarkEndpointConfig holds configuration for the trust mark endpoint
@@ -128,7 +131,9 @@ func (fed *LightHouse) handleTrustMarkRequest(
 	}
 
 	// Run eligibility check based on mode
-	eligible, httpCode, reason := fed.checkEligibility(trustMarkType, sub, eligibilityConfig, config)
+	eligible, httpCode, reason := fed.checkEligibility(
+		ctx.UserContext(), trustMarkType, sub, eligibilityConfig, config,
+	)
 
 	// Cache result if caching is enabled
 	if config.Cache != nil && eligibilityConfig.CheckCacheTTL > 0 {
@@ -153,6 +158,7 @@ func (fed *LightHouse) handleTrustMarkRequest(
 
 // checkEligibility checks if a subject is eligible for a trust mark based on the eligibility mode
 func (fed *LightHouse) checkEligibility(
+	ctx context.Context,
 	trustMarkType, sub string,
 	eligibilityConfig *model.EligibilityConfig,
 	config TrustMarkEndpointConfig,
@@ -162,24 +168,24 @@ func (fed *LightHouse) checkEligibility(
 		return fed.checkDBEligibility(trustMarkType, sub, config)
 
 	case model.EligibilityModeCheckOnly:
-		return fed.runChecker(trustMarkType, sub, eligibilityConfig.Checker, config)
+		return fed.runChecker(ctx, trustMarkType, sub, eligibilityConfig.Checker, config)
 
 	case model.EligibilityModeDBOrCheck:
 		// DB first, then checker
 		if ok, _, _ := fed.checkDBEligibility(trustMarkType, sub, config); ok {
 			return true, 0, ""
 		}
-		return fed.runChecker(trustMarkType, sub, eligibilityConfig.Checker, config)
+		return fed.runChecker(ctx, trustMarkType, sub, eligibilityConfig.Checker, config)
 
 	case model.EligibilityModeDBAndCheck:
 		// Must pass both
 		if ok, code, reason := fed.checkDBEligibility(trustMarkType, sub, config); !ok {
 			return false, code, reason
 		}
-		return fed.runChecker(trustMarkType, sub, eligibilityConfig.Checker, config)
+		return fed.runChecker(ctx, trustMarkType, sub, eligibilityConfig.Checker, config)
 
 	case model.EligibilityModeCustom:
-		return fed.runChecker(trustMarkType, sub, eligibilityConfig.Checker, config)
+		return fed.runChecker(ctx, trustMarkType, sub, eligibilityConfig.Checker, config)
 
 	default:
 		return false, fiber.StatusInternalServerError, "unknown eligibility mode"
@@ -214,6 +220,7 @@ func (*LightHouse) checkDBEligibility(
 
 // runChecker runs an entity checker against a subject
 func (*LightHouse) runChecker(
+	ctx context.Context,
 	trustMarkType, sub string,
 	checkerConfig *model.CheckerConfig,
 	config TrustMarkEndpointConfig,
@@ -245,13 +252,20 @@ func (*LightHouse) runChecker(
 	}
 
 	// Get entity configuration
-	entityConfig, err := oidfed.GetEntityConfiguration(sub)
+	entityConfig, err := fetchEntityConfiguration(ctx, sub)
 	if err != nil {
 		return false, fiber.StatusBadRequest, "could not obtain entity configuration: " + err.Error()
 	}
 
 	// Run the checker
+	_, span := tracing.Start(
+		ctx, "lighthouse.EntityCheck",
+		attribute.String("oidfed.subject", sub),
+		attribute.String("oidfed.trust_mark_type", trustMarkType),
+	)
 	ok, code, errResponse := checker.Check(entityConfig, entityConfig.Metadata.GuessEntityTypes())
+	span.SetAttributes(attribute.Bool("lighthouse.eligible", ok))
+	span.End()
 	if !ok {
 		httpCode := fiber.StatusForbidden
 		if code != 0 {
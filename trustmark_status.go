@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/pkg/errors"
 
 	oidfed "github.com/go-oidfed/lib"
 	"github.com/go-oidfed/lib/oidfedconst"
@@ -24,6 +25,10 @@ type TrustMarkStatusResponse struct {
 	IssuedAt  int64  `json:"iat"`
 	TrustMark string `json:"trust_mark"`
 	Status    string `json:"status"`
+	// RevokedAt is the time of the revocation, if the trust mark was revoked
+	RevokedAt int64 `json:"revoked_at,omitempty"`
+	// RevocationReason is the recorded reason of the revocation
+	RevocationReason string `json:"revocation_reason,omitempty"`
 }
 
 // AddTrustMarkStatusEndpoint adds a trust mark status endpoint compliant with OIDC Federation spec.
@@ -60,10 +65,13 @@ func (fed *LightHouse) handleTrustMarkStatusRequest(
 	}
 
 	// Parse and validate the trust mark JWT
-	status, err := fed.determineTrustMarkStatus(trustMarkJWT, config)
+	status, instance, err := fed.determineTrustMarkStatus(trustMarkJWT, config)
 	if err != nil {
-		// If we can't parse the trust mark at all, it's invalid
-		return fed.sendTrustMarkStatusResponse(ctx, trustMarkJWT, model.TrustMarkStatusInvalid)
+		// The status could not be determined, e.g. because the storage is
+		// unavailable; a signed 'invalid' status could be cached by relying
+		// parties, so no status is returned.
+		ctx.Status(fiber.StatusInternalServerError)
+		return ctx.JSON(oidfed.ErrorServerError("failed to determine trust mark status: " + err.Error()))
 	}
 
 	// If the trust mark is not found (unknown JTI), return 404
@@ -72,42 +80,45 @@ func (fed *LightHouse) handleTrustMarkStatusRequest(
 		return ctx.JSON(oidfed.ErrorNotFound("trust mark not found"))
 	}
 
-	return fed.sendTrustMarkStatusResponse(ctx, trustMarkJWT, status)
+	return fed.sendTrustMarkStatusResponse(ctx, trustMarkJWT, status, instance)
 }
 
-// determineTrustMarkStatus parses the trust mark JWT and determines its status
+// determineTrustMarkStatus parses the trust mark JWT and determines its status.
+// If the trust mark is tracked in the instance store, the instance is returned
+// as well. Trust marks that cannot be parsed or verified are invalid; an error
+// is only returned if the status cannot be determined.
 func (fed *LightHouse) determineTrustMarkStatus(
 	trustMarkJWT string,
 	config TrustMarkStatusConfig,
-) (model.TrustMarkInstanceStatus, error) {
+) (model.TrustMarkInstanceStatus, *model.IssuedTrustMarkInstance, error) {
 	// Parse the trust mark JWT to extract claims
 	parsedTM, err := oidfed.ParseTrustMark([]byte(trustMarkJWT))
 	if err != nil {
-		return model.TrustMarkStatusInvalid, err
+		return model.TrustMarkStatusInvalid, nil, nil
 	}
 
 	// Check that we are the issuer
 	if parsedTM.Issuer != fed.FederationEntity.EntityID() {
-		return model.TrustMarkStatusInvalid, nil
+		return model.TrustMarkStatusInvalid, nil, nil
 	}
 
-	// Verify the signature using our federation keys
+	// Verify the signature using our trust mark keys
 	// Get the signer to access the JWKS for verification
-	signer := fed.GeneralJWTSigner.TrustMarkSigner()
+	signer := fed.KeySetSigner(KeySetTrustMarks).TrustMarkSigner()
 	if signer == nil {
-		return model.TrustMarkStatusInvalid, nil
+		return model.TrustMarkStatusInvalid, nil, nil
 	}
 
 	// Parse and verify the JWT signature
 	jwks, err := signer.JWKS()
 	if err != nil {
-		return model.TrustMarkStatusInvalid, err
+		return "", nil, err
 	}
 
 	_, err = jwt.Parse([]byte(trustMarkJWT), jwt.WithKeySet(jwks.Set))
 	if err != nil {
 		// Signature verification failed
-		return model.TrustMarkStatusInvalid, nil
+		return model.TrustMarkStatusInvalid, nil, nil
 	}
 
 	// Extract JTI from the trust mark
@@ -116,39 +127,38 @@ func (fed *LightHouse) determineTrustMarkStatus(
 		// Trust marks without JTI can still be validated based on expiration
 		// Check expiration
 		if parsedTM.ExpiresAt != nil && time.Now().After(parsedTM.ExpiresAt.Time) {
-			return model.TrustMarkStatusExpired, nil
+			return model.TrustMarkStatusExpired, nil, nil
 		}
 		// No JTI but signature valid and not expired - consider active
-		return model.TrustMarkStatusActive, nil
+		return model.TrustMarkStatusActive, nil, nil
 	}
 
 	// Look up the instance in the database
 	if config.InstanceStore == nil {
 		// No instance store configured, fall back to basic validation
 		if parsedTM.ExpiresAt != nil && time.Now().After(parsedTM.ExpiresAt.Time) {
-			return model.TrustMarkStatusExpired, nil
+			return model.TrustMarkStatusExpired, nil, nil
 		}
-		return model.TrustMarkStatusActive, nil
+		return model.TrustMarkStatusActive, nil, nil
 	}
 
-	// Get status from the instance store
-	status, err := config.InstanceStore.GetStatus(jti)
+	// Get the instance from the instance store
+	instance, err := config.InstanceStore.GetByJTI(jti)
 	if err != nil {
-		// Instance not found - this trust mark was not issued by us (or before tracking was enabled)
 		var notFound model.NotFoundError
-		if isNotFound := err; isNotFound != nil {
+		if errors.As(err, &notFound) {
+			// Instance not found - this trust mark was not issued by us (or before tracking was enabled)
 			// Try to determine status from the JWT itself
 			if parsedTM.ExpiresAt != nil && time.Now().After(parsedTM.ExpiresAt.Time) {
-				return model.TrustMarkStatusExpired, nil
+				return model.TrustMarkStatusExpired, nil, nil
 			}
 			// We verified the signature, so it's valid but we don't track it
-			return model.TrustMarkStatusActive, nil
+			return model.TrustMarkStatusActive, nil, nil
 		}
-		_ = notFound // suppress unused warning
-		return "", err
+		return "", nil, err
 	}
 
-	return status, nil
+	return instance.Status(), instance, nil
 }
 
 // sendTrustMarkStatusResponse creates and sends a signed trust mark status response JWT
@@ -156,6 +166,7 @@ func (fed *LightHouse) sendTrustMarkStatusResponse(
 	ctx *fiber.Ctx,
 	trustMarkJWT string,
 	status model.TrustMarkInstanceStatus,
+	instance *model.IssuedTrustMarkInstance,
 ) error {
 	// Build the response payload
 	response := TrustMarkStatusResponse{
@@ -164,6 +175,10 @@ func (fed *LightHouse) sendTrustMarkStatusResponse(
 		TrustMark: trustMarkJWT,
 		Status:    string(status),
 	}
+	if instance != nil && status == model.TrustMarkStatusRevoked {
+		response.RevokedAt = int64(instance.RevokedAt)
+		response.RevocationReason = instance.RevocationReason
+	}
 
 	// Sign the response using our general JWT signer with the correct type header
 	signedJWT, err := fed.GeneralJWTSigner.JWT(response, oidfedconst.JWTTypeTrustMarkStatusResponse)